@@ -0,0 +1,140 @@
+package ddns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// DefaultTimeout is the default time to wait for a response.
+const DefaultTimeout = 5 * time.Second
+
+// Client sends DNS UPDATE messages to a server.
+type Client struct {
+	// Server is the address of the DNS server, as host:port. If the port
+	// is omitted, 53 is used.
+	Server string
+	// Net is the network to use, "udp" or "tcp". If empty, "udp" is used.
+	Net string
+	// Key, if not nil, is used to sign requests and verify responses.
+	Key *Key
+	// Timeout is the time to wait for a response. If zero,
+	// DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+func (c *Client) addr() string {
+	if _, _, err := net.SplitHostPort(c.Server); err != nil {
+		return net.JoinHostPort(c.Server, "53")
+	}
+	return c.Server
+}
+
+// Exchange sends m and returns the response. If the response code is not
+// RCodeSuccess, the response is returned with the RCode as error.
+func (c *Client) Exchange(ctx context.Context, m *Message) (*Message, error) {
+	m.ID = uint16(rand.Intn(1 << 16))
+	req, err := m.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	var reqMAC []byte
+	if c.Key != nil {
+		req, reqMAC, err = c.Key.Sign(req, nil, time.Now())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	network := c.Net
+	if network == "" {
+		network = "udp"
+	}
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, network, c.addr())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(timeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	_, stream := conn.(*net.TCPConn)
+	if err := writeMsg(conn, req, stream); err != nil {
+		return nil, err
+	}
+	for {
+		b, err := readMsg(conn, stream)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		resp, err := ParseMessage(b)
+		if err != nil || !resp.Response || resp.ID != m.ID {
+			// Not a response to m, keep waiting.
+			continue
+		}
+		if c.Key != nil {
+			if _, err := c.Key.Verify(b, reqMAC, time.Now()); err != nil {
+				// Servers do not sign responses to requests they
+				// could not authenticate.
+				if errors.Is(err, ErrNotSigned) && resp.RCode != RCodeSuccess {
+					return resp, resp.RCode
+				}
+				return nil, fmt.Errorf("invalid response signature: %w", err)
+			}
+		}
+		if resp.RCode != RCodeSuccess {
+			return resp, resp.RCode
+		}
+		return resp, nil
+	}
+}
+
+// writeMsg writes a message, with the two-octet length prefix of RFC 1035,
+// Section 4.2.2, on streams.
+func writeMsg(w io.Writer, b []byte, stream bool) error {
+	if stream {
+		b = append(binary.BigEndian.AppendUint16(nil, uint16(len(b))), b...)
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+func readMsg(r io.Reader, stream bool) ([]byte, error) {
+	if !stream {
+		b := make([]byte, 65535)
+		n, err := r.Read(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,283 @@
+// Package ddnstest provides an in-process DNS server that applies dynamic
+// updates, for testing DDNS clients.
+package ddnstest
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/ddns"
+)
+
+type rrsetKey struct {
+	name string
+	typ  uint16
+}
+
+// Server is a DNS server listening on a loopback UDP port, that applies
+// RFC 2136 updates to in-memory zones.
+type Server struct {
+	// Addr is the address of the server.
+	Addr string
+
+	key   *ddns.Key
+	conn  net.PacketConn
+	zones []string
+
+	mu       sync.Mutex
+	records  map[rrsetKey][][]byte
+	requests []*ddns.Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server for the given zones. If key is not nil, updates
+// must be signed with it.
+func NewServer(key *ddns.Key, zones ...string) (*Server, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:    conn.LocalAddr().String(),
+		key:     key,
+		conn:    conn,
+		records: make(map[rrsetKey][][]byte),
+	}
+	for _, z := range zones {
+		s.zones = append(s.zones, canonical(z))
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.conn.Close()
+	s.wg.Wait()
+	return err
+}
+
+func canonical(name string) string {
+	return strings.ToLower(ddns.Fqdn(name))
+}
+
+// Add adds a record, e.g. to simulate records of another client.
+func (s *Server) Add(name string, typ uint16, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(rrsetKey{canonical(name), typ}, data)
+}
+
+// Records returns the data of the records of the given name and type.
+func (s *Server) Records(name string, typ uint16) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out [][]byte
+	for _, d := range s.records[rrsetKey{canonical(name), typ}] {
+		out = append(out, append([]byte(nil), d...))
+	}
+	return out
+}
+
+// Names returns the names that have records, sorted.
+func (s *Server) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	var names []string
+	for k := range s.records {
+		if !seen[k.name] {
+			seen[k.name] = true
+			names = append(names, k.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Requests returns the update requests received so far.
+func (s *Server) Requests() []*ddns.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*ddns.Message(nil), s.requests...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, peer, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if resp := s.handle(append([]byte(nil), buf[:n]...)); resp != nil {
+			_, _ = s.conn.WriteTo(resp, peer)
+		}
+	}
+}
+
+func (s *Server) handle(b []byte) []byte {
+	m, err := ddns.ParseMessage(b)
+	if err != nil || m.Response {
+		return nil
+	}
+	var reqMAC []byte
+	if s.key != nil {
+		reqMAC, err = s.key.Verify(b, nil, time.Now())
+		if err != nil {
+			resp, _ := m.Reply(ddns.RCodeNotAuth).ToBytes()
+			return resp
+		}
+		// Drop the TSIG record.
+		m.Additional = m.Additional[:len(m.Additional)-1]
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, m)
+	rcode := s.update(m)
+	s.mu.Unlock()
+
+	resp, err := m.Reply(rcode).ToBytes()
+	if err != nil {
+		return nil
+	}
+	if s.key != nil {
+		resp, _, err = s.key.Sign(resp, reqMAC, time.Now())
+		if err != nil {
+			return nil
+		}
+	}
+	return resp
+}
+
+func (s *Server) inZone(name, zone string) bool {
+	name = canonical(name)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+// update applies an UPDATE message, as described in RFC 2136, Section 3.
+func (s *Server) update(m *ddns.Message) ddns.RCode {
+	if m.Opcode != ddns.OpcodeUpdate {
+		return ddns.RCodeNotImplemented
+	}
+	if len(m.Zone) != 1 {
+		return ddns.RCodeFormatError
+	}
+	zone := canonical(m.Zone[0].Name)
+	served := false
+	for _, z := range s.zones {
+		served = served || z == zone
+	}
+	if !served {
+		return ddns.RCodeNotAuth
+	}
+	for _, rr := range append(append([]ddns.RR(nil), m.Prerequisites...), m.Updates...) {
+		if !s.inZone(rr.Name, zone) {
+			return ddns.RCodeNotZone
+		}
+	}
+	if rcode := s.checkPrerequisites(m.Prerequisites); rcode != ddns.RCodeSuccess {
+		return rcode
+	}
+	for _, rr := range m.Updates {
+		k := rrsetKey{canonical(rr.Name), rr.Type}
+		switch rr.Class {
+		case ddns.ClassINET:
+			s.add(k, rr.Data)
+		case ddns.ClassANY:
+			for key := range s.records {
+				if key.name == k.name && (rr.Type == ddns.TypeANY || key.typ == rr.Type) {
+					delete(s.records, key)
+				}
+			}
+		case ddns.ClassNONE:
+			var kept [][]byte
+			for _, d := range s.records[k] {
+				if !bytes.Equal(d, rr.Data) {
+					kept = append(kept, d)
+				}
+			}
+			s.set(k, kept)
+		default:
+			return ddns.RCodeFormatError
+		}
+	}
+	return ddns.RCodeSuccess
+}
+
+func (s *Server) add(k rrsetKey, data []byte) {
+	for _, d := range s.records[k] {
+		if bytes.Equal(d, data) {
+			return
+		}
+	}
+	s.records[k] = append(s.records[k], append([]byte(nil), data...))
+}
+
+func (s *Server) set(k rrsetKey, data [][]byte) {
+	if len(data) == 0 {
+		delete(s.records, k)
+	} else {
+		s.records[k] = data
+	}
+}
+
+func (s *Server) nameInUse(name string) bool {
+	for k := range s.records {
+		if k.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) checkPrerequisites(prereqs []ddns.RR) ddns.RCode {
+	// Value-dependent prerequisites must match RRsets exactly.
+	want := make(map[rrsetKey][][]byte)
+	for _, rr := range prereqs {
+		k := rrsetKey{canonical(rr.Name), rr.Type}
+		switch rr.Class {
+		case ddns.ClassANY:
+			if rr.Type == ddns.TypeANY {
+				if !s.nameInUse(k.name) {
+					return ddns.RCodeNameError
+				}
+			} else if len(s.records[k]) == 0 {
+				return ddns.RCodeNXRRSet
+			}
+		case ddns.ClassNONE:
+			if rr.Type == ddns.TypeANY {
+				if s.nameInUse(k.name) {
+					return ddns.RCodeYXDomain
+				}
+			} else if len(s.records[k]) > 0 {
+				return ddns.RCodeYXRRSet
+			}
+		case ddns.ClassINET:
+			want[k] = append(want[k], rr.Data)
+		default:
+			return ddns.RCodeFormatError
+		}
+	}
+	for k, data := range want {
+		have := s.records[k]
+		if len(have) != len(data) {
+			return ddns.RCodeNXRRSet
+		}
+		for _, d := range data {
+			found := false
+			for _, h := range have {
+				found = found || bytes.Equal(d, h)
+			}
+			if !found {
+				return ddns.RCodeNXRRSet
+			}
+		}
+	}
+	return ddns.RCodeSuccess
+}
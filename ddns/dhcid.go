@@ -0,0 +1,68 @@
+package ddns
+
+import (
+	"crypto/sha256"
+	"net"
+	"strings"
+
+	"github.com/u-root/uio/uio"
+)
+
+// DHCIDType is the identifier type of a DHCID record, RFC 4701, Section 3.3.
+type DHCIDType uint16
+
+// DHCID identifier types.
+const (
+	// DHCIDTypeHWAddr identifies DHCPv4 clients by their hardware type
+	// and address.
+	DHCIDTypeHWAddr DHCIDType = 0x0000
+	// DHCIDTypeClientID identifies DHCPv4 clients by their client
+	// identifier option.
+	DHCIDTypeClientID DHCIDType = 0x0001
+	// DHCIDTypeDUID identifies clients by their DUID.
+	DHCIDTypeDUID DHCIDType = 0x0002
+)
+
+// dhcidDigestSHA256 is the SHA-256 digest type code.
+const dhcidDigestSHA256 = 1
+
+// DHCID returns the data of a DHCID record for a client with the given
+// identifier that is assigned the name fqdn, as described in RFC 4701,
+// Section 3.
+func DHCID(idType DHCIDType, identifier []byte, fqdn string) []byte {
+	h := sha256.New()
+	h.Write(identifier)
+	// Updates for invalid names cannot be encoded, so their DHCID is
+	// never sent and the name is left out.
+	name, _ := EncodeName(strings.ToLower(Fqdn(fqdn)))
+	h.Write(name)
+
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(idType))
+	buf.Write8(dhcidDigestSHA256)
+	buf.WriteBytes(h.Sum(nil))
+	return buf.Data()
+}
+
+// DHCIDFromHardwareAddr returns the DHCID record data for a DHCPv4 client
+// without client identifier. htype is the hardware type of the client.
+func DHCIDFromHardwareAddr(htype uint8, hwaddr net.HardwareAddr, fqdn string) []byte {
+	return DHCID(DHCIDTypeHWAddr, append([]byte{htype}, hwaddr...), fqdn)
+}
+
+// DHCIDFromClientID returns the DHCID record data for a DHCPv4 client with
+// the given client identifier option data. Node-specific identifiers of RFC
+// 4361, which contain a DUID, use the DUID identifier type.
+func DHCIDFromClientID(clientID []byte, fqdn string) []byte {
+	// RFC 4361, Section 6.1: type 255, IAID, DUID.
+	if len(clientID) > 5 && clientID[0] == 255 {
+		return DHCID(DHCIDTypeDUID, clientID[5:], fqdn)
+	}
+	return DHCID(DHCIDTypeClientID, clientID, fqdn)
+}
+
+// DHCIDFromDUID returns the DHCID record data for a client with the given
+// DUID.
+func DHCIDFromDUID(duid []byte, fqdn string) []byte {
+	return DHCID(DHCIDTypeDUID, duid, fqdn)
+}
@@ -0,0 +1,44 @@
+package ddns
+
+import (
+	"encoding/base64"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Examples from RFC 4701, Section 3.6.
+func TestDHCID(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		dhcid []byte
+		want  string
+	}{
+		{
+			name:  "DUID",
+			dhcid: DHCIDFromDUID([]byte{0, 1, 0, 6, 0x41, 0x2d, 0xf1, 0x66, 1, 2, 3, 4, 5, 6}, "chi6.example.com"),
+			want:  "AAIBY2/AuCccgoJbsaxcQc9TUapptP69lOjxfNuVAA2kjEA=",
+		},
+		{
+			name:  "client identifier",
+			dhcid: DHCIDFromClientID([]byte{1, 7, 8, 9, 10, 11, 12}, "chi.example.com."),
+			want:  "AAEBOSD+XR3Os/0LozeXVqcNc7FwCfQdWL3b/NaiUDlW2No=",
+		},
+		{
+			name:  "hardware address",
+			dhcid: DHCIDFromHardwareAddr(1, net.HardwareAddr{1, 2, 3, 4, 5, 6}, "Client.Example.com"),
+			want:  "AAABxLmlskllE0MVjd57zHcWmEH3pCQ6VytcKD//7es/deY=",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, base64.StdEncoding.EncodeToString(tt.dhcid))
+		})
+	}
+}
+
+func TestDHCIDFromClientIDDUID(t *testing.T) {
+	duid := []byte{0, 1, 0, 6, 0x41, 0x2d, 0xf1, 0x66, 1, 2, 3, 4, 5, 6}
+	clientID := append([]byte{255, 0, 0, 0, 1}, duid...)
+	require.Equal(t, DHCIDFromDUID(duid, "host.example.com"), DHCIDFromClientID(clientID, "host.example.com"))
+}
@@ -0,0 +1,355 @@
+// Package ddns implements dynamic DNS updates for DHCP servers: RFC 2136 DNS
+// UPDATE messages signed with TSIG (RFC 8945), DHCID records (RFC 4701), and
+// the conflict resolution procedure of RFC 4703.
+package ddns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/u-root/uio/uio"
+)
+
+// Resource record types used in DNS updates.
+const (
+	TypeA     uint16 = 1
+	TypePTR   uint16 = 12
+	TypeAAAA  uint16 = 28
+	TypeDHCID uint16 = 49
+	TypeTSIG  uint16 = 250
+	TypeANY   uint16 = 255
+)
+
+// Classes used in DNS updates, RFC 2136, Section 2.4.
+const (
+	ClassINET uint16 = 1
+	ClassNONE uint16 = 254
+	ClassANY  uint16 = 255
+)
+
+// OpcodeUpdate is the opcode of DNS UPDATE messages.
+const OpcodeUpdate uint8 = 5
+
+// RCode is a DNS response code.
+type RCode uint16
+
+// Response codes, RFC 1035, RFC 2136 and RFC 8945.
+const (
+	RCodeSuccess        RCode = 0
+	RCodeFormatError    RCode = 1
+	RCodeServerFailure  RCode = 2
+	RCodeNameError      RCode = 3
+	RCodeNotImplemented RCode = 4
+	RCodeRefused        RCode = 5
+	RCodeYXDomain       RCode = 6
+	RCodeYXRRSet        RCode = 7
+	RCodeNXRRSet        RCode = 8
+	RCodeNotAuth        RCode = 9
+	RCodeNotZone        RCode = 10
+	RCodeBadSig         RCode = 16
+	RCodeBadKey         RCode = 17
+	RCodeBadTime        RCode = 18
+)
+
+var rcodeToString = map[RCode]string{
+	RCodeSuccess:        "NOERROR",
+	RCodeFormatError:    "FORMERR",
+	RCodeServerFailure:  "SERVFAIL",
+	RCodeNameError:      "NXDOMAIN",
+	RCodeNotImplemented: "NOTIMP",
+	RCodeRefused:        "REFUSED",
+	RCodeYXDomain:       "YXDOMAIN",
+	RCodeYXRRSet:        "YXRRSET",
+	RCodeNXRRSet:        "NXRRSET",
+	RCodeNotAuth:        "NOTAUTH",
+	RCodeNotZone:        "NOTZONE",
+	RCodeBadSig:         "BADSIG",
+	RCodeBadKey:         "BADKEY",
+	RCodeBadTime:        "BADTIME",
+}
+
+// String implements fmt.Stringer.
+func (r RCode) String() string {
+	if s, ok := rcodeToString[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("RCODE%d", uint16(r))
+}
+
+// Error implements error, so that RCodes can be returned as errors.
+func (r RCode) Error() string {
+	return "dns: " + r.String()
+}
+
+// RR is a resource record. Data is the wire format of the record data.
+type RR struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	Data  []byte
+}
+
+// Question is an entry of the question section, the zone section of UPDATE
+// messages.
+type Question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+}
+
+// Message is a DNS message. The sections are named after their meaning in
+// UPDATE messages: Prerequisites is the answer section and Updates the
+// authority section.
+type Message struct {
+	ID       uint16
+	Response bool
+	Opcode   uint8
+	RCode    RCode
+
+	Zone          []Question
+	Prerequisites []RR
+	Updates       []RR
+	Additional    []RR
+}
+
+// NewUpdate returns an UPDATE message for zone.
+func NewUpdate(zone string) *Message {
+	return &Message{
+		Opcode: OpcodeUpdate,
+		Zone:   []Question{{Name: zone, Type: 6 /* SOA */, Class: ClassINET}},
+	}
+}
+
+// Reply returns an empty response to m with the given response code.
+func (m *Message) Reply(rcode RCode) *Message {
+	return &Message{
+		ID:       m.ID,
+		Response: true,
+		Opcode:   m.Opcode,
+		RCode:    rcode,
+		Zone:     m.Zone,
+	}
+}
+
+// ToBytes returns the wire format of m. Names are not compressed. An error is
+// returned if m contains an invalid name.
+func (m *Message) ToBytes() ([]byte, error) {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(m.ID)
+	var flags uint16
+	if m.Response {
+		flags |= 1 << 15
+	}
+	flags |= uint16(m.Opcode&0xf) << 11
+	flags |= uint16(m.RCode & 0xf)
+	buf.Write16(flags)
+	buf.Write16(uint16(len(m.Zone)))
+	buf.Write16(uint16(len(m.Prerequisites)))
+	buf.Write16(uint16(len(m.Updates)))
+	buf.Write16(uint16(len(m.Additional)))
+	for _, q := range m.Zone {
+		if err := writeName(buf, q.Name); err != nil {
+			return nil, err
+		}
+		buf.Write16(q.Type)
+		buf.Write16(q.Class)
+	}
+	for _, section := range [][]RR{m.Prerequisites, m.Updates, m.Additional} {
+		for _, rr := range section {
+			if err := writeRR(buf, &rr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return buf.Data(), nil
+}
+
+func writeRR(buf *uio.Lexer, rr *RR) error {
+	if err := writeName(buf, rr.Name); err != nil {
+		return err
+	}
+	buf.Write16(rr.Type)
+	buf.Write16(rr.Class)
+	buf.Write32(rr.TTL)
+	buf.Write16(uint16(len(rr.Data)))
+	buf.WriteBytes(rr.Data)
+	return nil
+}
+
+// ParseMessage parses a DNS message.
+func ParseMessage(b []byte) (*Message, error) {
+	m, _, err := parseMessage(b)
+	return m, err
+}
+
+// parseMessage parses a DNS message, and also returns the offset of its last
+// resource record, which is where a TSIG record is.
+func parseMessage(b []byte) (*Message, int, error) {
+	if len(b) < 12 {
+		return nil, 0, errors.New("dns: message too short")
+	}
+	buf := uio.NewBigEndianBuffer(b)
+	m := &Message{ID: buf.Read16()}
+	flags := buf.Read16()
+	m.Response = flags&(1<<15) != 0
+	m.Opcode = uint8(flags>>11) & 0xf
+	m.RCode = RCode(flags & 0xf)
+	counts := [4]uint16{buf.Read16(), buf.Read16(), buf.Read16(), buf.Read16()}
+
+	off := 12
+	for i := 0; i < int(counts[0]); i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return nil, 0, errors.New("dns: question too short")
+		}
+		q := Question{Name: name}
+		q.Type = uint16(b[off])<<8 | uint16(b[off+1])
+		q.Class = uint16(b[off+2])<<8 | uint16(b[off+3])
+		off += 4
+		m.Zone = append(m.Zone, q)
+	}
+	last := off
+	for s, section := range []*[]RR{&m.Prerequisites, &m.Updates, &m.Additional} {
+		for i := 0; i < int(counts[s+1]); i++ {
+			last = off
+			rr, n, err := readRR(b, off)
+			if err != nil {
+				return nil, 0, err
+			}
+			off = n
+			*section = append(*section, *rr)
+		}
+	}
+	if off != len(b) {
+		return nil, 0, errors.New("dns: trailing data after message")
+	}
+	return m, last, nil
+}
+
+func readRR(b []byte, off int) (*RR, int, error) {
+	name, off, err := readName(b, off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if off+10 > len(b) {
+		return nil, 0, errors.New("dns: resource record too short")
+	}
+	buf := uio.NewBigEndianBuffer(b[off : off+10])
+	rr := &RR{Name: name, Type: buf.Read16(), Class: buf.Read16(), TTL: buf.Read32()}
+	n := int(buf.Read16())
+	off += 10
+	if off+n > len(b) {
+		return nil, 0, errors.New("dns: resource record data too short")
+	}
+	rr.Data = append([]byte(nil), b[off:off+n]...)
+	return rr, off + n, nil
+}
+
+// Fqdn returns name with a trailing dot.
+func Fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// Name length limits, RFC 1035, Section 2.3.4.
+const (
+	maxLabelLen = 63
+	maxNameLen  = 255
+)
+
+// ValidateName checks that name, which is taken to be fully qualified, can be
+// encoded in a DNS message: its labels must be non-empty and at most 63
+// octets long, and its wire format at most 255 octets long.
+func ValidateName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return nil
+	}
+	// The wire format ends with the empty root label.
+	n := 1
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return fmt.Errorf("dns: empty label in name %q", name)
+		}
+		if len(label) > maxLabelLen {
+			return fmt.Errorf("dns: label of %d octets in name %q, maximum is %d", len(label), name, maxLabelLen)
+		}
+		n += 1 + len(label)
+	}
+	if n > maxNameLen {
+		return fmt.Errorf("dns: name of %d octets, maximum is %d", n, maxNameLen)
+	}
+	return nil
+}
+
+// EncodeName returns the uncompressed wire format of name, which is taken to
+// be fully qualified.
+func EncodeName(name string) ([]byte, error) {
+	buf := uio.NewBigEndianBuffer(nil)
+	if err := writeName(buf, name); err != nil {
+		return nil, err
+	}
+	return buf.Data(), nil
+}
+
+func writeName(buf *uio.Lexer, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf.Write8(uint8(len(label)))
+			buf.WriteBytes([]byte(label))
+		}
+	}
+	buf.Write8(0)
+	return nil
+}
+
+// readName reads a possibly compressed name at off, and returns it and the
+// offset following it.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for hops := 0; ; {
+		if off >= len(b) {
+			return "", 0, errors.New("dns: name too short")
+		}
+		n := int(b[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, ".") + ".", end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, errors.New("dns: name pointer too short")
+			}
+			if hops++; hops > 16 {
+				return "", 0, errors.New("dns: too many name pointers")
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = (n&0x3f)<<8 | int(b[off+1])
+		case n > 63:
+			return "", 0, fmt.Errorf("dns: invalid label length %d", n)
+		default:
+			if off+1+n > len(b) {
+				return "", 0, errors.New("dns: label too short")
+			}
+			labels = append(labels, string(b[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package ddns
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	m := NewUpdate("example.com.")
+	m.ID = 0x1234
+	m.Prerequisites = []RR{{Name: "host.example.com.", Type: TypeANY, Class: ClassNONE}}
+	m.Updates = []RR{{Name: "host.example.com.", Type: TypeA, Class: ClassINET, TTL: 300, Data: []byte{192, 0, 2, 1}}}
+	b, err := m.ToBytes()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x12, 0x34, 0x28, 0, 0, 1, 0, 1, 0, 1, 0, 0}, b[:12])
+
+	got, err := ParseMessage(b)
+	require.NoError(t, err)
+	require.Equal(t, m, got)
+
+	r, err := m.Reply(RCodeYXDomain).ToBytes()
+	require.NoError(t, err)
+	got, err = ParseMessage(r)
+	require.NoError(t, err)
+	require.True(t, got.Response)
+	require.Equal(t, RCodeYXDomain, got.RCode)
+	require.Equal(t, m.Zone, got.Zone)
+
+	_, err = ParseMessage(b[:len(b)-1])
+	require.Error(t, err)
+	_, err = ParseMessage(append(b, 0))
+	require.Error(t, err)
+}
+
+func TestReadNameCompressed(t *testing.T) {
+	b := []byte{
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		4, 'h', 'o', 's', 't', 0xc0, 0,
+	}
+	name, off, err := readName(b, 13)
+	require.NoError(t, err)
+	require.Equal(t, "host.example.com.", name)
+	require.Equal(t, len(b), off)
+
+	// Pointer loops are rejected.
+	_, _, err = readName([]byte{0xc0, 0}, 0)
+	require.Error(t, err)
+}
+
+func mustToBytes(t *testing.T, m *Message) []byte {
+	t.Helper()
+	b, err := m.ToBytes()
+	require.NoError(t, err)
+	return b
+}
+
+func TestEncodeName(t *testing.T) {
+	b, err := EncodeName(".")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0}, b)
+	b, err = EncodeName("a.bc")
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 'a', 2, 'b', 'c', 0}, b)
+
+	// The longest valid label and name.
+	_, err = EncodeName(strings.Repeat("a", 63) + ".example.com.")
+	require.NoError(t, err)
+	long := strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("a", 61) + "."
+	_, err = EncodeName(long)
+	require.NoError(t, err)
+}
+
+func TestInvalidNames(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fqdn string
+	}{
+		{name: "empty label", fqdn: "a..evil.example.com."},
+		{name: "leading dot", fqdn: ".example.com."},
+		{name: "64 octet label", fqdn: strings.Repeat("a", 64) + ".example.com."},
+		{name: "200 octet label", fqdn: strings.Repeat("a", 200) + ".example.com."},
+		{name: "256 octet label", fqdn: strings.Repeat("a", 256) + ".example.com."},
+		{name: "256 octet name", fqdn: strings.Repeat(strings.Repeat("a", 63)+".", 3) + strings.Repeat("a", 62) + "."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, ValidateName(tt.fqdn))
+			_, err := EncodeName(tt.fqdn)
+			require.Error(t, err)
+
+			m := NewUpdate("example.com.")
+			m.Updates = []RR{{Name: tt.fqdn, Type: TypeA, Class: ClassINET, TTL: 300, Data: []byte{192, 0, 2, 1}}}
+			_, err = m.ToBytes()
+			require.Error(t, err)
+
+			m = NewUpdate(tt.fqdn)
+			_, err = m.ToBytes()
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	require.Equal(t, "1.2.0.192.in-addr.arpa.", ReverseName(net.ParseIP("192.0.2.1")))
+	require.Equal(t,
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+		ReverseName(net.ParseIP("2001:db8::1")))
+}
+
+func TestRCode(t *testing.T) {
+	require.Equal(t, "NXRRSET", RCodeNXRRSet.String())
+	require.Equal(t, "RCODE42", RCode(42).String())
+	require.Equal(t, "dns: YXDOMAIN", RCodeYXDomain.Error())
+}
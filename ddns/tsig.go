@@ -0,0 +1,234 @@
+package ddns
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// TSIG algorithm names, RFC 8945, Section 6.
+const (
+	HMACMD5    = "hmac-md5.sig-alg.reg.int."
+	HMACSHA1   = "hmac-sha1."
+	HMACSHA256 = "hmac-sha256."
+	HMACSHA512 = "hmac-sha512."
+)
+
+// DefaultFudge is the permitted clock skew of TSIG signatures.
+const DefaultFudge = 300 * time.Second
+
+var (
+	// ErrNotSigned is returned when a message has no TSIG record.
+	ErrNotSigned = errors.New("dns: message is not signed")
+	// ErrBadKey is returned when a message is signed with another key.
+	ErrBadKey = errors.New("dns: message is signed with an unknown key")
+	// ErrBadSig is returned when the signature of a message is wrong.
+	ErrBadSig = errors.New("dns: bad message signature")
+	// ErrBadTime is returned when a message was signed outside of the
+	// permitted time window.
+	ErrBadTime = errors.New("dns: message signed outside of the permitted time window")
+)
+
+// Key is a TSIG key.
+type Key struct {
+	// Name is the name of the key.
+	Name string
+	// Algorithm is the name of the HMAC algorithm. If empty, HMACSHA256
+	// is used.
+	Algorithm string
+	Secret    []byte
+}
+
+func (k *Key) algorithm() string {
+	if k.Algorithm == "" {
+		return HMACSHA256
+	}
+	return strings.ToLower(Fqdn(k.Algorithm))
+}
+
+func (k *Key) hash() (func() hash.Hash, error) {
+	switch k.algorithm() {
+	case HMACMD5:
+		return md5.New, nil
+	case HMACSHA1:
+		return sha1.New, nil
+	case HMACSHA256:
+		return sha256.New, nil
+	case HMACSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("dns: unsupported TSIG algorithm %q", k.Algorithm)
+}
+
+// tsig is the data of a TSIG record.
+type tsig struct {
+	algorithm  string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	originalID uint16
+	err        RCode
+	other      []byte
+}
+
+func (t *tsig) toBytes() ([]byte, error) {
+	buf := uio.NewBigEndianBuffer(nil)
+	if err := writeName(buf, t.algorithm); err != nil {
+		return nil, err
+	}
+	buf.Write16(uint16(t.timeSigned >> 32))
+	buf.Write32(uint32(t.timeSigned))
+	buf.Write16(t.fudge)
+	buf.Write16(uint16(len(t.mac)))
+	buf.WriteBytes(t.mac)
+	buf.Write16(t.originalID)
+	buf.Write16(uint16(t.err))
+	buf.Write16(uint16(len(t.other)))
+	buf.WriteBytes(t.other)
+	return buf.Data(), nil
+}
+
+func parseTSIG(data []byte) (*tsig, error) {
+	name, off, err := readName(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	buf := uio.NewBigEndianBuffer(data[off:])
+	t := &tsig{algorithm: strings.ToLower(name)}
+	t.timeSigned = uint64(buf.Read16())<<32 | uint64(buf.Read32())
+	t.fudge = buf.Read16()
+	t.mac = buf.CopyN(int(buf.Read16()))
+	t.originalID = buf.Read16()
+	t.err = RCode(buf.Read16())
+	t.other = buf.CopyN(int(buf.Read16()))
+	if err := buf.FinError(); err != nil {
+		return nil, fmt.Errorf("dns: invalid TSIG record: %w", err)
+	}
+	return t, nil
+}
+
+// mac computes the MAC of msg, a message without its TSIG record, as
+// described in RFC 8945, Section 4.3.3.
+func (k *Key) mac(msg []byte, requestMAC []byte, t *tsig) ([]byte, error) {
+	h, err := k.hash()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(h, k.Secret)
+	buf := uio.NewBigEndianBuffer(nil)
+	if requestMAC != nil {
+		buf.Write16(uint16(len(requestMAC)))
+		buf.WriteBytes(requestMAC)
+	}
+	buf.WriteBytes(msg)
+	if err := writeName(buf, strings.ToLower(Fqdn(k.Name))); err != nil {
+		return nil, err
+	}
+	buf.Write16(ClassANY)
+	buf.Write32(0)
+	if err := writeName(buf, t.algorithm); err != nil {
+		return nil, err
+	}
+	buf.Write16(uint16(t.timeSigned >> 32))
+	buf.Write32(uint32(t.timeSigned))
+	buf.Write16(t.fudge)
+	buf.Write16(uint16(t.err))
+	buf.Write16(uint16(len(t.other)))
+	buf.WriteBytes(t.other)
+	mac.Write(buf.Data())
+	return mac.Sum(nil), nil
+}
+
+// Sign appends a TSIG record to msg, a message in wire format, and returns
+// the signed message and its MAC. requestMAC is the MAC of the request when
+// signing a response, and nil otherwise.
+func (k *Key) Sign(msg []byte, requestMAC []byte, now time.Time) ([]byte, []byte, error) {
+	if len(msg) < 12 {
+		return nil, nil, errors.New("dns: message too short")
+	}
+	t := &tsig{
+		algorithm:  k.algorithm(),
+		timeSigned: uint64(now.Unix()),
+		fudge:      uint16(DefaultFudge / time.Second),
+		originalID: uint16(msg[0])<<8 | uint16(msg[1]),
+	}
+	mac, err := k.mac(msg, requestMAC, t)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.mac = mac
+	data, err := t.toBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := uio.NewBigEndianBuffer(append([]byte(nil), msg...))
+	if err := writeRR(buf, &RR{Name: Fqdn(k.Name), Type: TypeTSIG, Class: ClassANY, Data: data}); err != nil {
+		return nil, nil, err
+	}
+	signed := buf.Data()
+	arcount := uint16(signed[10])<<8 | uint16(signed[11]) + 1
+	signed[10], signed[11] = byte(arcount>>8), byte(arcount)
+	return signed, mac, nil
+}
+
+// Verify checks the TSIG record of msg, a signed message in wire format, and
+// returns the MAC. requestMAC is the MAC of the request when verifying a
+// response, and nil otherwise.
+func (k *Key) Verify(msg []byte, requestMAC []byte, now time.Time) ([]byte, error) {
+	m, off, err := parseMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Additional) == 0 || m.Additional[len(m.Additional)-1].Type != TypeTSIG {
+		return nil, ErrNotSigned
+	}
+	rr := m.Additional[len(m.Additional)-1]
+	t, err := parseTSIG(rr.Data)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(Fqdn(rr.Name), Fqdn(k.Name)) || t.algorithm != k.algorithm() {
+		return nil, ErrBadKey
+	}
+	switch t.err {
+	case RCodeSuccess:
+	case RCodeBadKey:
+		return nil, ErrBadKey
+	case RCodeBadSig:
+		return nil, ErrBadSig
+	case RCodeBadTime:
+		return nil, ErrBadTime
+	default:
+		return nil, fmt.Errorf("dns: TSIG error %s", t.err)
+	}
+
+	// The MAC covers the message without the TSIG record, with its
+	// original ID.
+	unsigned := append([]byte(nil), msg[:off]...)
+	arcount := uint16(unsigned[10])<<8 | uint16(unsigned[11]) - 1
+	unsigned[10], unsigned[11] = byte(arcount>>8), byte(arcount)
+	unsigned[0], unsigned[1] = byte(t.originalID>>8), byte(t.originalID)
+	mac, err := k.mac(unsigned, requestMAC, t)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, t.mac) {
+		return nil, ErrBadSig
+	}
+	signed := time.Unix(int64(t.timeSigned), 0)
+	fudge := time.Duration(t.fudge) * time.Second
+	if now.Before(signed.Add(-fudge)) || now.After(signed.Add(fudge)) {
+		return nil, ErrBadTime
+	}
+	return mac, nil
+}
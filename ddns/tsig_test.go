@@ -0,0 +1,64 @@
+package ddns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = &Key{Name: "dhcp-key.", Secret: []byte("0123456789abcdef")}
+
+func TestSignVerify(t *testing.T) {
+	now := time.Now()
+	for _, alg := range []string{"", HMACMD5, HMACSHA1, HMACSHA256, HMACSHA512} {
+		t.Run(alg, func(t *testing.T) {
+			key := &Key{Name: testKey.Name, Algorithm: alg, Secret: testKey.Secret}
+			m := NewUpdate("example.com.")
+			m.ID = 42
+			signed, mac, err := key.Sign(mustToBytes(t, m), nil, now)
+			require.NoError(t, err)
+			got, err := key.Verify(signed, nil, now)
+			require.NoError(t, err)
+			require.Equal(t, mac, got)
+
+			parsed, err := ParseMessage(signed)
+			require.NoError(t, err)
+			require.Len(t, parsed.Additional, 1)
+			require.Equal(t, TypeTSIG, parsed.Additional[0].Type)
+
+			// Responses are signed over the request MAC.
+			resp, _, err := key.Sign(mustToBytes(t, m.Reply(RCodeSuccess)), mac, now)
+			require.NoError(t, err)
+			_, err = key.Verify(resp, mac, now)
+			require.NoError(t, err)
+			_, err = key.Verify(resp, nil, now)
+			require.Equal(t, ErrBadSig, err)
+		})
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	now := time.Now()
+	m := NewUpdate("example.com.")
+	_, err := testKey.Verify(mustToBytes(t, m), nil, now)
+	require.Equal(t, ErrNotSigned, err)
+
+	signed, _, err := testKey.Sign(mustToBytes(t, m), nil, now)
+	require.NoError(t, err)
+
+	_, err = (&Key{Name: "other.", Secret: testKey.Secret}).Verify(signed, nil, now)
+	require.Equal(t, ErrBadKey, err)
+	_, err = (&Key{Name: testKey.Name, Secret: []byte("wrong")}).Verify(signed, nil, now)
+	require.Equal(t, ErrBadSig, err)
+	_, err = testKey.Verify(signed, nil, now.Add(DefaultFudge+time.Second))
+	require.Equal(t, ErrBadTime, err)
+
+	// Tampering with the message breaks the signature.
+	signed[2] ^= 0x80
+	_, err = testKey.Verify(signed, nil, now)
+	require.Equal(t, ErrBadSig, err)
+
+	_, _, err = (&Key{Name: "k.", Algorithm: "hmac-foo"}).Sign(mustToBytes(t, m), nil, now)
+	require.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package ddns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DefaultTTL is the default TTL of the records added by an Updater.
+const DefaultTTL = 5 * time.Minute
+
+// ErrConflict is returned when the name to update is owned by another client,
+// as determined by the DHCID record of the name, RFC 4703, Section 5.3.
+var ErrConflict = errors.New("ddns: name is in use by another client")
+
+// Updater adds and removes the address and PTR records of DHCP clients,
+// following the procedures of RFC 4703.
+type Updater struct {
+	Client *Client
+	// ForwardZone is the zone of the address records, e.g.
+	// "example.com.".
+	ForwardZone string
+	// ReverseZone is the zone of the PTR records, e.g.
+	// "2.0.192.in-addr.arpa.".
+	ReverseZone string
+	// TTL is the TTL of added records. If zero, DefaultTTL is used.
+	TTL time.Duration
+	// DisableConflictResolution makes the updater replace the address
+	// records of names owned by other clients, as allowed by RFC 4703,
+	// Section 5.
+	DisableConflictResolution bool
+}
+
+func (u *Updater) ttl() uint32 {
+	if u.TTL == 0 {
+		return uint32(DefaultTTL / time.Second)
+	}
+	return uint32(u.TTL / time.Second)
+}
+
+// addressRecord returns the type and data of the address record for ip.
+func addressRecord(ip net.IP) (uint16, []byte, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return TypeA, ip4, nil
+	}
+	if len(ip) == net.IPv6len {
+		return TypeAAAA, ip, nil
+	}
+	return 0, nil, fmt.Errorf("ddns: invalid address %v", ip)
+}
+
+// ReverseName returns the name of the PTR record of ip, in in-addr.arpa. or
+// ip6.arpa.
+func ReverseName(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", ip4[3], ip4[2], ip4[1], ip4[0])
+	}
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip[i]&0xf, ip[i]>>4)
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+func (u *Updater) exchange(ctx context.Context, m *Message) error {
+	_, err := u.Client.Exchange(ctx, m)
+	return err
+}
+
+// AddForward adds the address record of ip for name, owned by the client
+// with the given DHCID record data.
+//
+// If name is not in use, the address and DHCID records are added. Otherwise,
+// the address records of the family of ip are replaced if the DHCID record of
+// name matches dhcid, and ErrConflict is returned if it does not.
+func (u *Updater) AddForward(ctx context.Context, name string, ip net.IP, dhcid []byte) error {
+	return u.AddForwardAddrs(ctx, name, []net.IP{ip}, dhcid)
+}
+
+// AddForwardAddrs is like AddForward, but sets the address records of name
+// to all of ips, e.g. for DHCPv6 clients with several bindings.
+func (u *Updater) AddForwardAddrs(ctx context.Context, name string, ips []net.IP, dhcid []byte) error {
+	if len(ips) == 0 {
+		return errors.New("ddns: no address to add")
+	}
+	name = Fqdn(name)
+	var addrs, deleteAddrs []RR
+	deleted := make(map[uint16]bool)
+	for _, ip := range ips {
+		typ, data, err := addressRecord(ip)
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, RR{Name: name, Type: typ, Class: ClassINET, TTL: u.ttl(), Data: data})
+		if !deleted[typ] {
+			deleted[typ] = true
+			deleteAddrs = append(deleteAddrs, RR{Name: name, Type: typ, Class: ClassANY})
+		}
+	}
+
+	if u.DisableConflictResolution {
+		m := NewUpdate(u.ForwardZone)
+		m.Updates = append(append(deleteAddrs, addrs...),
+			RR{Name: name, Type: TypeDHCID, Class: ClassANY},
+			RR{Name: name, Type: TypeDHCID, Class: ClassINET, TTL: u.ttl(), Data: dhcid},
+		)
+		return u.exchange(ctx, m)
+	}
+
+	// RFC 4703, Section 5.3.1: add the records if the name is not in use.
+	m := NewUpdate(u.ForwardZone)
+	m.Prerequisites = []RR{{Name: name, Type: TypeANY, Class: ClassNONE}}
+	m.Updates = append(append([]RR(nil), addrs...),
+		RR{Name: name, Type: TypeDHCID, Class: ClassINET, TTL: u.ttl(), Data: dhcid},
+	)
+	err := u.exchange(ctx, m)
+	if !errors.Is(err, RCodeYXDomain) {
+		return err
+	}
+
+	// RFC 4703, Section 5.3.2: the name is in use, replace the address
+	// records if the client owns it.
+	m = NewUpdate(u.ForwardZone)
+	m.Prerequisites = []RR{{Name: name, Type: TypeDHCID, Class: ClassINET, Data: dhcid}}
+	m.Updates = append(deleteAddrs, addrs...)
+	err = u.exchange(ctx, m)
+	if errors.Is(err, RCodeNXRRSet) {
+		return ErrConflict
+	}
+	return err
+}
+
+// RemoveForward removes the address record of ip for name, if the DHCID
+// record of name matches dhcid, as described in RFC 4703, Section 5.5. The
+// DHCID record is removed too if name has no address records left.
+func (u *Updater) RemoveForward(ctx context.Context, name string, ip net.IP, dhcid []byte) error {
+	name = Fqdn(name)
+	typ, data, err := addressRecord(ip)
+	if err != nil {
+		return err
+	}
+	owned := RR{Name: name, Type: TypeDHCID, Class: ClassINET, Data: dhcid}
+	m := NewUpdate(u.ForwardZone)
+	if !u.DisableConflictResolution {
+		m.Prerequisites = []RR{owned}
+	}
+	m.Updates = []RR{{Name: name, Type: typ, Class: ClassNONE, Data: data}}
+	err = u.exchange(ctx, m)
+	if errors.Is(err, RCodeNXRRSet) {
+		return ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+
+	m = NewUpdate(u.ForwardZone)
+	m.Prerequisites = []RR{
+		owned,
+		{Name: name, Type: TypeA, Class: ClassNONE},
+		{Name: name, Type: TypeAAAA, Class: ClassNONE},
+	}
+	m.Updates = []RR{{Name: name, Type: TypeDHCID, Class: ClassANY}}
+	err = u.exchange(ctx, m)
+	if errors.Is(err, RCodeNXRRSet) || errors.Is(err, RCodeYXRRSet) {
+		// Other address records remain.
+		return nil
+	}
+	return err
+}
+
+// AddReverse sets the PTR record of ip to name, replacing existing records,
+// as described in RFC 4703, Section 5.4.
+func (u *Updater) AddReverse(ctx context.Context, ip net.IP, name string) error {
+	data, err := EncodeName(Fqdn(name))
+	if err != nil {
+		return err
+	}
+	ptr := ReverseName(ip)
+	m := NewUpdate(u.ReverseZone)
+	m.Updates = []RR{
+		{Name: ptr, Type: TypePTR, Class: ClassANY},
+		{Name: ptr, Type: TypePTR, Class: ClassINET, TTL: u.ttl(), Data: data},
+	}
+	return u.exchange(ctx, m)
+}
+
+// RemoveReverse removes the PTR record of ip pointing to name.
+func (u *Updater) RemoveReverse(ctx context.Context, ip net.IP, name string) error {
+	data, err := EncodeName(Fqdn(name))
+	if err != nil {
+		return err
+	}
+	ptr := ReverseName(ip)
+	m := NewUpdate(u.ReverseZone)
+	m.Updates = []RR{{Name: ptr, Type: TypePTR, Class: ClassNONE, Data: data}}
+	return u.exchange(ctx, m)
+}
@@ -0,0 +1,160 @@
+package ddns_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/insomniacslk/dhcp/ddns/ddnstest"
+	"github.com/stretchr/testify/require"
+)
+
+var key = &ddns.Key{Name: "dhcp-key.", Secret: []byte("0123456789abcdef")}
+
+func newUpdater(t *testing.T) (*ddns.Updater, *ddnstest.Server) {
+	s, err := ddnstest.NewServer(key, "example.com.", "2.0.192.in-addr.arpa.")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return &ddns.Updater{
+		Client:      &ddns.Client{Server: s.Addr, Key: key, Timeout: time.Second},
+		ForwardZone: "example.com.",
+		ReverseZone: "2.0.192.in-addr.arpa.",
+	}, s
+}
+
+func TestUpdaterForward(t *testing.T) {
+	u, s := newUpdater(t)
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.10")
+	dhcid := ddns.DHCIDFromHardwareAddr(1, net.HardwareAddr{1, 2, 3, 4, 5, 6}, "host.example.com.")
+
+	require.NoError(t, u.AddForward(ctx, "host.example.com", ip, dhcid))
+	require.Equal(t, [][]byte{ip.To4()}, s.Records("host.example.com.", ddns.TypeA))
+	require.Equal(t, [][]byte{dhcid}, s.Records("host.example.com.", ddns.TypeDHCID))
+
+	// The owner can move its name to another address.
+	ip2 := net.ParseIP("192.0.2.11")
+	require.NoError(t, u.AddForward(ctx, "host.example.com", ip2, dhcid))
+	require.Equal(t, [][]byte{ip2.To4()}, s.Records("host.example.com.", ddns.TypeA))
+
+	// Other clients cannot take it.
+	other := ddns.DHCIDFromHardwareAddr(1, net.HardwareAddr{1, 2, 3, 4, 5, 7}, "host.example.com.")
+	require.Equal(t, ddns.ErrConflict, u.AddForward(ctx, "host.example.com", ip, other))
+	require.Equal(t, ddns.ErrConflict, u.RemoveForward(ctx, "host.example.com", ip2, other))
+	require.Equal(t, [][]byte{ip2.To4()}, s.Records("host.example.com.", ddns.TypeA))
+
+	require.NoError(t, u.RemoveForward(ctx, "host.example.com", ip2, dhcid))
+	require.Empty(t, s.Names())
+}
+
+func TestUpdaterForwardDualStack(t *testing.T) {
+	u, s := newUpdater(t)
+	ctx := context.Background()
+	dhcid := ddns.DHCIDFromDUID([]byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6}, "host.example.com.")
+	require.NoError(t, u.AddForward(ctx, "host.example.com.", net.ParseIP("192.0.2.10"), dhcid))
+	require.NoError(t, u.AddForward(ctx, "host.example.com.", net.ParseIP("2001:db8::10"), dhcid))
+	require.Len(t, s.Records("host.example.com.", ddns.TypeA), 1)
+	require.Len(t, s.Records("host.example.com.", ddns.TypeAAAA), 1)
+
+	// The DHCID stays while the AAAA record exists.
+	require.NoError(t, u.RemoveForward(ctx, "host.example.com.", net.ParseIP("192.0.2.10"), dhcid))
+	require.Empty(t, s.Records("host.example.com.", ddns.TypeA))
+	require.Len(t, s.Records("host.example.com.", ddns.TypeDHCID), 1)
+	require.NoError(t, u.RemoveForward(ctx, "host.example.com.", net.ParseIP("2001:db8::10"), dhcid))
+	require.Empty(t, s.Names())
+}
+
+func TestUpdaterForwardAddrs(t *testing.T) {
+	u, s := newUpdater(t)
+	ctx := context.Background()
+	dhcid := ddns.DHCIDFromDUID([]byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6}, "host.example.com.")
+	ip1, ip2 := net.ParseIP("2001:db8::10"), net.ParseIP("2001:db8::11")
+	require.NoError(t, u.AddForwardAddrs(ctx, "host.example.com.", []net.IP{ip1}, dhcid))
+	require.NoError(t, u.AddForwardAddrs(ctx, "host.example.com.", []net.IP{ip1, ip2}, dhcid))
+	require.Equal(t, [][]byte{ip1, ip2}, s.Records("host.example.com.", ddns.TypeAAAA))
+
+	require.NoError(t, u.RemoveForward(ctx, "host.example.com.", ip1, dhcid))
+	require.Equal(t, [][]byte{dhcid}, s.Records("host.example.com.", ddns.TypeDHCID))
+	require.NoError(t, u.RemoveForward(ctx, "host.example.com.", ip2, dhcid))
+	require.Empty(t, s.Names())
+	require.Error(t, u.AddForwardAddrs(ctx, "host.example.com.", nil, dhcid))
+}
+
+func TestUpdaterForwardNameWithoutDHCID(t *testing.T) {
+	u, s := newUpdater(t)
+	ctx := context.Background()
+	s.Add("static.example.com.", ddns.TypeA, []byte{192, 0, 2, 1})
+	dhcid := ddns.DHCIDFromHardwareAddr(1, net.HardwareAddr{1, 2, 3, 4, 5, 6}, "static.example.com.")
+	require.Equal(t, ddns.ErrConflict, u.AddForward(ctx, "static.example.com.", net.ParseIP("192.0.2.10"), dhcid))
+
+	u.DisableConflictResolution = true
+	require.NoError(t, u.AddForward(ctx, "static.example.com.", net.ParseIP("192.0.2.10"), dhcid))
+	require.Equal(t, [][]byte{{192, 0, 2, 10}}, s.Records("static.example.com.", ddns.TypeA))
+}
+
+func TestUpdaterReverse(t *testing.T) {
+	u, s := newUpdater(t)
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.10")
+	require.NoError(t, u.AddReverse(ctx, ip, "old.example.com."))
+	require.NoError(t, u.AddReverse(ctx, ip, "host.example.com."))
+	ptr, err := ddns.EncodeName("host.example.com.")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{ptr}, s.Records("10.2.0.192.in-addr.arpa.", ddns.TypePTR))
+
+	require.NoError(t, u.RemoveReverse(ctx, ip, "host.example.com."))
+	require.Empty(t, s.Names())
+}
+
+func TestUpdaterErrors(t *testing.T) {
+	u, _ := newUpdater(t)
+	ctx := context.Background()
+	ip := net.ParseIP("192.0.2.10")
+
+	// Names outside of the zone are rejected.
+	err := u.AddForward(ctx, "host.example.net.", ip, []byte{0})
+	require.Equal(t, ddns.RCodeNotZone, err)
+
+	u.ForwardZone = "example.net."
+	require.Equal(t, ddns.RCodeNotAuth, u.AddForward(ctx, "host.example.net.", ip, []byte{0}))
+
+	u.Client.Key = &ddns.Key{Name: key.Name, Secret: []byte("wrong")}
+	require.Equal(t, ddns.RCodeNotAuth, u.AddReverse(ctx, ip, "host.example.com."))
+}
+
+func TestClientTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var l [2]byte
+		if _, err := conn.Read(l[:]); err != nil {
+			return
+		}
+		b := make([]byte, int(l[0])<<8|int(l[1]))
+		if _, err := conn.Read(b); err != nil {
+			return
+		}
+		m, err := ddns.ParseMessage(b)
+		if err != nil {
+			return
+		}
+		resp, err := m.Reply(ddns.RCodeSuccess).ToBytes()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write(append([]byte{byte(len(resp) >> 8), byte(len(resp))}, resp...))
+	}()
+
+	c := &ddns.Client{Server: ln.Addr().String(), Net: "tcp", Timeout: time.Second}
+	resp, err := c.Exchange(context.Background(), ddns.NewUpdate("example.com."))
+	require.NoError(t, err)
+	require.True(t, resp.Response)
+}
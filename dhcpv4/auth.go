@@ -0,0 +1,275 @@
+package dhcpv4
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/internal/replay"
+)
+
+// Errors returned when authenticating messages.
+var (
+	ErrAuthMissing    = errors.New("dhcpv4: message is not authenticated")
+	ErrAuthProtocol   = errors.New("dhcpv4: unsupported authentication protocol")
+	ErrAuthUnknownKey = errors.New("dhcpv4: unknown authentication key")
+	ErrAuthInvalid    = errors.New("dhcpv4: invalid authentication")
+	ErrAuthReplay     = errors.New("dhcpv4: replayed message")
+)
+
+// delayedAuthInfoLen is the length of the delayed authentication
+// information: a 32-bit secret ID and an HMAC-MD5, RFC 3118, Section 5.
+const delayedAuthInfoLen = 4 + md5.Size
+
+// ReplayCounter generates replay detection values with the monotonic
+// method. Values are NTP timestamps, so that they keep increasing across
+// restarts, as suggested by RFC 3118, Section 2. The zero value is ready to
+// use.
+type ReplayCounter struct {
+	c replay.Counter
+}
+
+// Next returns a value greater than any value previously returned.
+func (c *ReplayCounter) Next() uint64 {
+	return c.c.Next()
+}
+
+// DefaultMaxReplayPeers is the default number of peers a ReplayDetector
+// tracks.
+const DefaultMaxReplayPeers = 4096
+
+// ReplayDetector tracks the last replay detection values received from
+// peers, and rejects values that do not increase. The zero value is ready to
+// use.
+//
+// Once MaxPeers peers are tracked, the peer heard from least recently is
+// forgotten. Peers can also be forgotten explicitly with Forget, e.g. when
+// their lease ends.
+type ReplayDetector struct {
+	// MaxPeers is the number of peers tracked. If zero,
+	// DefaultMaxReplayPeers is used.
+	MaxPeers int
+
+	mu   sync.Mutex
+	last map[string]*list.Element
+	// lru orders the peers by last use, most recent first.
+	lru list.List
+}
+
+type replayEntry struct {
+	peer  string
+	value uint64
+}
+
+func (r *ReplayDetector) maxPeers() int {
+	if r.MaxPeers == 0 {
+		return DefaultMaxReplayPeers
+	}
+	return r.MaxPeers
+}
+
+// Check returns ErrAuthReplay if value is not greater than the last value
+// received from peer, and records it otherwise.
+func (r *ReplayDetector) Check(peer string, value uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.last[peer]; ok {
+		entry := e.Value.(*replayEntry)
+		if value <= entry.value {
+			return ErrAuthReplay
+		}
+		entry.value = value
+		r.lru.MoveToFront(e)
+		return nil
+	}
+	if r.last == nil {
+		r.last = make(map[string]*list.Element)
+	}
+	for len(r.last) >= r.maxPeers() {
+		oldest := r.lru.Back()
+		delete(r.last, oldest.Value.(*replayEntry).peer)
+		r.lru.Remove(oldest)
+	}
+	r.last[peer] = r.lru.PushFront(&replayEntry{peer: peer, value: value})
+	return nil
+}
+
+// Forget forgets the last value received from peer.
+func (r *ReplayDetector) Forget(peer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.last[peer]; ok {
+		delete(r.last, peer)
+		r.lru.Remove(e)
+	}
+}
+
+// DelayedAuth signs and verifies messages with the delayed authentication
+// protocol of RFC 3118, Section 5, using HMAC-MD5 and monotonic replay
+// detection.
+type DelayedAuth struct {
+	// Keys are the shared secrets, by secret ID.
+	Keys map[uint32][]byte
+	// KeyID is the ID of the default secret.
+	KeyID uint32
+	// Counter generates the replay detection values of signed messages.
+	Counter ReplayCounter
+	// Replay tracks the replay detection values of verified messages.
+	Replay ReplayDetector
+}
+
+// NewDelayedAuth returns a DelayedAuth with a single secret.
+func NewDelayedAuth(id uint32, secret []byte) *DelayedAuth {
+	return &DelayedAuth{Keys: map[uint32][]byte{id: secret}, KeyID: id}
+}
+
+// Sign adds a delayed Authentication option to d, authenticated with the
+// secret of the given ID. It must be the last change made to d.
+func (a *DelayedAuth) Sign(d *DHCPv4, id uint32) error {
+	secret, ok := a.Keys[id]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrAuthUnknownKey, id)
+	}
+	info := make([]byte, delayedAuthInfoLen)
+	binary.BigEndian.PutUint32(info, id)
+	auth := &Authentication{
+		Protocol:        AuthProtocolDelayed,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: a.Counter.Next(),
+		Information:     info,
+	}
+	d.UpdateOption(OptAuthentication(auth))
+	mac, err := delayedAuthMAC(d.ToBytes(), secret)
+	if err != nil {
+		return err
+	}
+	copy(info[4:], mac)
+	d.UpdateOption(OptAuthentication(auth))
+	return nil
+}
+
+// Verify checks the delayed Authentication option of d, as received from
+// peer, and returns the ID of the secret it was authenticated with.
+//
+// d is serialized again to compute the HMAC, which only matches the received
+// message if the sender serializes messages the same way as this package. Use
+// VerifyBytes for messages of other implementations.
+func (a *DelayedAuth) Verify(d *DHCPv4, peer string) (uint32, error) {
+	return a.VerifyBytes(d.ToBytes(), peer)
+}
+
+// VerifyBytes is like Verify, for a message as received on the wire.
+func (a *DelayedAuth) VerifyBytes(msg []byte, peer string) (uint32, error) {
+	d, err := FromBytes(msg)
+	if err != nil {
+		return 0, err
+	}
+	auth := d.Authentication()
+	if auth == nil || len(auth.Information) == 0 {
+		return 0, ErrAuthMissing
+	}
+	if auth.Protocol != AuthProtocolDelayed || auth.Algorithm != AuthAlgorithmHMACMD5 || auth.RDM != AuthRDMMonotonic {
+		return 0, fmt.Errorf("%w: %s", ErrAuthProtocol, auth)
+	}
+	if len(auth.Information) != delayedAuthInfoLen {
+		return 0, ErrAuthInvalid
+	}
+	id := binary.BigEndian.Uint32(auth.Information)
+	secret, ok := a.Keys[id]
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrAuthUnknownKey, id)
+	}
+	mac, err := delayedAuthMAC(msg, secret)
+	if err != nil {
+		return 0, err
+	}
+	if !hmac.Equal(mac, auth.Information[4:]) {
+		return 0, ErrAuthInvalid
+	}
+	if err := a.Replay.Check(peer, auth.ReplayDetection); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// WithDelayedAuthRequest adds an Authentication option without information,
+// with which clients request delayed authentication in DISCOVER messages, as
+// described in RFC 3118, Section 5.2. Other messages are signed with Sign,
+// after all the modifiers are applied. nclient4.WithDelayedAuth does both.
+func WithDelayedAuthRequest(a *DelayedAuth) Modifier {
+	return func(d *DHCPv4) {
+		d.UpdateOption(OptAuthentication(&Authentication{
+			Protocol:        AuthProtocolDelayed,
+			Algorithm:       AuthAlgorithmHMACMD5,
+			RDM:             AuthRDMMonotonic,
+			ReplayDetection: a.Counter.Next(),
+		}))
+	}
+}
+
+// delayedAuthMAC computes the HMAC-MD5 of msg, with the hops and giaddr
+// fields and the HMAC of the Authentication option set to zero, as described
+// in RFC 3118, Section 5.4.
+func delayedAuthMAC(msg []byte, secret []byte) ([]byte, error) {
+	return authMAC(msg, secret, delayedAuthInfoLen, true)
+}
+
+// authMAC computes the HMAC-MD5 of msg, with the HMAC at the end of the
+// information of the Authentication option set to zero. The option must have
+// infoLen bytes of information. If zeroRelay is set, the hops and giaddr
+// fields are set to zero too.
+func authMAC(msg []byte, secret []byte, infoLen int, zeroRelay bool) ([]byte, error) {
+	b := append([]byte(nil), msg...)
+	off, err := authOptionOffset(b)
+	if err != nil {
+		return nil, err
+	}
+	if zeroRelay {
+		// Hops.
+		b[3] = 0
+		// Giaddr.
+		copy(b[24:28], []byte{0, 0, 0, 0})
+	}
+	// The value of the option starts with protocol, algorithm, RDM and
+	// replay detection, then the information.
+	valueLen := 11 + infoLen
+	if int(b[off+1]) != valueLen {
+		return nil, ErrAuthInvalid
+	}
+	mac := b[off+2+valueLen-md5.Size : off+2+valueLen]
+	for i := range mac {
+		mac[i] = 0
+	}
+	h := hmac.New(md5.New, secret)
+	h.Write(b)
+	return h.Sum(nil), nil
+}
+
+// authOptionOffset returns the offset of the Authentication option in msg.
+func authOptionOffset(msg []byte) (int, error) {
+	// Fixed header and magic cookie.
+	const optionsOffset = 240
+	for i := optionsOffset; i < len(msg); {
+		code := msg[i]
+		if code == optEnd {
+			break
+		}
+		if code == optPad {
+			i++
+			continue
+		}
+		if i+1 >= len(msg) || i+2+int(msg[i+1]) > len(msg) {
+			return 0, ErrAuthInvalid
+		}
+		if code == uint8(OptionAuthentication) {
+			return i, nil
+		}
+		i += 2 + int(msg[i+1])
+	}
+	return 0, ErrAuthMissing
+}
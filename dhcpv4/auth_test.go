@@ -0,0 +1,107 @@
+package dhcpv4
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayCounter(t *testing.T) {
+	var c ReplayCounter
+	a := c.Next()
+	b := c.Next()
+	require.Greater(t, b, a)
+	// NTP timestamps have the seconds since 1900 in the upper 32 bits.
+	require.Greater(t, a>>32, uint64(3900000000))
+}
+
+func TestReplayDetector(t *testing.T) {
+	var r ReplayDetector
+	require.NoError(t, r.Check("a", 10))
+	require.NoError(t, r.Check("b", 5))
+	require.Equal(t, ErrAuthReplay, r.Check("a", 10))
+	require.Equal(t, ErrAuthReplay, r.Check("a", 9))
+	require.NoError(t, r.Check("a", 11))
+}
+
+func TestReplayDetectorBounded(t *testing.T) {
+	r := ReplayDetector{MaxPeers: 2}
+	require.NoError(t, r.Check("a", 10))
+	require.NoError(t, r.Check("b", 10))
+	require.NoError(t, r.Check("a", 11))
+	// b is the least recently used peer, and is forgotten.
+	require.NoError(t, r.Check("c", 10))
+	require.Len(t, r.last, 2)
+	require.NoError(t, r.Check("b", 10))
+	require.Equal(t, ErrAuthReplay, r.Check("b", 10))
+
+	r.Forget("b")
+	require.NoError(t, r.Check("b", 1))
+	require.Len(t, r.last, 2)
+}
+
+func TestDelayedAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	client := NewDelayedAuth(42, secret)
+	server := NewDelayedAuth(42, secret)
+
+	m, err := NewDiscovery(net.HardwareAddr{2, 0, 0, 0, 0, 1}, WithDelayedAuthRequest(client))
+	require.NoError(t, err)
+	auth := m.Authentication()
+	require.NotNil(t, auth)
+	require.Equal(t, AuthProtocolDelayed, auth.Protocol)
+	require.Empty(t, auth.Information)
+	_, err = server.Verify(m, "client")
+	require.Equal(t, ErrAuthMissing, err)
+
+	m.UpdateOption(OptMessageType(MessageTypeRequest))
+	require.NoError(t, client.Sign(m, client.KeyID))
+	auth = m.Authentication()
+	require.Len(t, auth.Information, 20)
+	require.Equal(t, uint32(42), binary.BigEndian.Uint32(auth.Information))
+
+	// The HMAC is computed with the HMAC, hops and giaddr zeroed.
+	m.HopCount = 3
+	m.GatewayIPAddr = net.IPv4(192, 0, 2, 1)
+	b := m.ToBytes()
+	zeroed, err := FromBytes(b)
+	require.NoError(t, err)
+	zeroed.HopCount = 0
+	zeroed.GatewayIPAddr = net.IPv4zero
+	zeroed.UpdateOption(OptAuthentication(&Authentication{
+		Protocol:        auth.Protocol,
+		Algorithm:       auth.Algorithm,
+		RDM:             auth.RDM,
+		ReplayDetection: auth.ReplayDetection,
+		Information:     append(auth.Information[:4:4], make([]byte, 16)...),
+	}))
+	h := hmac.New(md5.New, secret)
+	h.Write(zeroed.ToBytes())
+	require.Equal(t, h.Sum(nil), auth.Information[4:])
+
+	id, err := server.VerifyBytes(b, "client")
+	require.NoError(t, err)
+	require.Equal(t, uint32(42), id)
+	_, err = server.VerifyBytes(b, "client")
+	require.Equal(t, ErrAuthReplay, err)
+
+	// Tampered messages.
+	m.ClientIPAddr = net.IPv4(192, 0, 2, 10)
+	_, err = server.Verify(m, "client")
+	require.Equal(t, ErrAuthInvalid, err)
+
+	other := NewDelayedAuth(43, secret)
+	require.NoError(t, other.Sign(m, other.KeyID))
+	_, err = server.Verify(m, "client")
+	require.True(t, errors.Is(err, ErrAuthUnknownKey))
+
+	require.True(t, errors.Is(client.Sign(m, 43), ErrAuthUnknownKey))
+	m.UpdateOption(OptAuthentication(&Authentication{Protocol: AuthProtocolConfigurationToken, Information: []byte("token")}))
+	_, err = server.Verify(m, "client")
+	require.True(t, errors.Is(err, ErrAuthProtocol))
+}
@@ -302,6 +302,25 @@ func TestDHCPv4NewRenewFromAckWithModifier(t *testing.T) {
 	require.Contains(t, req.UserClass(), "linuxboot")
 }
 
+func TestDHCPv4NewDeclineFromACK(t *testing.T) {
+	ack, err := New()
+	require.NoError(t, err)
+	ack.UpdateOption(OptMessageType(MessageTypeAck))
+	ack.UpdateOption(OptServerIdentifier(net.IPv4(192, 168, 0, 1)))
+	ack.ClientHWAddr = net.HardwareAddr{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}
+	ack.YourIPAddr = net.IPv4(192, 168, 0, 10)
+
+	decline, err := NewDeclineFromACK(ack, WithOption(OptMessage("address in use")))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeDecline, decline.MessageType())
+	require.True(t, decline.ClientIPAddr.IsUnspecified())
+	require.Equal(t, ack.ClientHWAddr, decline.ClientHWAddr)
+	require.True(t, decline.RequestedIPAddress().Equal(ack.YourIPAddr))
+	require.True(t, decline.ServerIdentifier().Equal(net.IPv4(192, 168, 0, 1)))
+	require.Equal(t, "address in use", decline.Message())
+	require.Nil(t, decline.GetOneOption(OptionParameterRequestList))
+}
+
 func TestNewReplyFromRequest(t *testing.T) {
 	discover, err := New()
 	require.NoError(t, err)
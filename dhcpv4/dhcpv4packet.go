@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 	"time"
 
@@ -267,9 +268,42 @@ func NewReleaseFromACK(ack *DHCPv4, modifiers ...Modifier) (*DHCPv4, error) {
 	)...)
 }
 
+// NewDeclineFromACK creates a DHCPv4 Decline message from ACK, telling the
+// server that the address it assigned is already in use.
+// default Decline message without any Modifer is created as following:
+//   - option Message Type is Decline
+//   - ClientIP is 0
+//   - ClientHWAddr is set to ack.ClientHWAddr
+//   - option Requested IP Address is set to ack.YourIPAddr
+//   - option Server Identifier is set to ack's ServerIdentifier
+//
+// See RFC 2131, Section 4.4.1, Table 5.
+func NewDeclineFromACK(ack *DHCPv4, modifiers ...Modifier) (*DHCPv4, error) {
+	return New(PrependModifiers(modifiers,
+		WithMessageType(MessageTypeDecline),
+		WithHwAddr(ack.ClientHWAddr),
+		WithOption(OptRequestedIPAddress(ack.YourIPAddr)),
+		WithOptionCopied(ack, OptionServerIdentifier),
+	)...)
+}
+
 // FromBytes decodes a DHCPv4 packet from a sequence of bytes, and returns an
 // error if the packet is not valid.
 func FromBytes(q []byte) (*DHCPv4, error) {
+	p, opts, err := fromBytesHeader(q)
+	if err != nil {
+		return nil, err
+	}
+	p.Options = make(Options)
+	if err := p.Options.fromBytesCheckEnd(opts, true); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// fromBytesHeader decodes the fixed-length part of a DHCPv4 packet up to and
+// including the magic cookie, and returns the remaining option bytes.
+func fromBytesHeader(q []byte) (*DHCPv4, []byte, error) {
 	var p DHCPv4
 	buf := uio.NewBigEndianBuffer(q)
 
@@ -316,17 +350,12 @@ func FromBytes(q []byte) (*DHCPv4, error) {
 	buf.ReadBytes(cookie[:])
 
 	if err := buf.Error(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if cookie != magicCookie {
-		return nil, fmt.Errorf("malformed DHCP packet: got magic cookie %v, want %v", cookie[:], magicCookie[:])
-	}
-
-	p.Options = make(Options)
-	if err := p.Options.fromBytesCheckEnd(buf.Data(), true); err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("malformed DHCP packet: got magic cookie %v, want %v", cookie[:], magicCookie[:])
 	}
-	return &p, nil
+	return &p, buf.Data(), nil
 }
 
 // FlagsToString returns a human-readable representation of the flags field.
@@ -475,6 +504,30 @@ func writeIP(b *uio.Lexer, ip net.IP) {
 // ToBytes writes the packet to binary.
 func (d *DHCPv4) ToBytes() []byte {
 	buf := uio.NewBigEndianBuffer(make([]byte, 0, minPacketLen))
+	d.marshalHeader(buf)
+
+	// Write all options.
+	d.Options.Marshal(buf)
+
+	// Finish the options.
+	buf.Write8(OptionEnd.Code())
+
+	// DHCP is based on BOOTP, and BOOTP messages have a minimum length of
+	// 300 bytes per RFC 951. This not stated explicitly, but if you sum up
+	// all the bytes in the message layout, you'll get 300 bytes.
+	//
+	// Some DHCP servers and relay agents care about this BOOTP legacy B.S.
+	// and "conveniently" drop messages that are less than 300 bytes long.
+	if buf.Len() < bootpMinLen {
+		buf.WriteBytes(bytes.Repeat([]byte{OptionPad.Code()}, bootpMinLen-buf.Len()))
+	}
+
+	return buf.Data()
+}
+
+// marshalHeader writes the fixed-length part of the packet, up to and
+// including the magic cookie, to buf.
+func (d *DHCPv4) marshalHeader(buf *uio.Lexer) {
 	buf.Write8(uint8(d.OpCode))
 	buf.Write8(uint8(d.HWType))
 
@@ -502,24 +555,6 @@ func (d *DHCPv4) ToBytes() []byte {
 
 	// The magic cookie.
 	buf.WriteBytes(magicCookie[:])
-
-	// Write all options.
-	d.Options.Marshal(buf)
-
-	// Finish the options.
-	buf.Write8(OptionEnd.Code())
-
-	// DHCP is based on BOOTP, and BOOTP messages have a minimum length of
-	// 300 bytes per RFC 951. This not stated explicitly, but if you sum up
-	// all the bytes in the message layout, you'll get 300 bytes.
-	//
-	// Some DHCP servers and relay agents care about this BOOTP legacy B.S.
-	// and "conveniently" drop messages that are less than 300 bytes long.
-	if buf.Len() < bootpMinLen {
-		buf.WriteBytes(bytes.Repeat([]byte{OptionPad.Code()}, bootpMinLen-buf.Len()))
-	}
-
-	return buf.Data()
 }
 
 // GetBroadcastAddress returns the DHCPv4 Broadcast Address value in d.
@@ -601,6 +636,83 @@ func (d *DHCPv4) HostName() string {
 	return strings.TrimRight(name, "\x00")
 }
 
+// FQDN parses the DHCPv4 Client FQDN option if present.
+//
+// The Client FQDN option is described by RFC 4702.
+func (d *DHCPv4) FQDN() *FQDN {
+	v := d.Options.Get(OptionFQDN)
+	if v == nil {
+		return nil
+	}
+	var f FQDN
+	if err := f.FromBytes(v); err != nil {
+		return nil
+	}
+	return &f
+}
+
+// SixRD parses the DHCPv4 6rd option if present.
+//
+// The 6rd option is described by RFC 5969, Section 7.1.1.
+func (d *DHCPv4) SixRD() *SixRD {
+	v := d.Options.Get(OptionOPTION6RD)
+	if v == nil {
+		return nil
+	}
+	var s SixRD
+	if err := s.FromBytes(v); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// DNR parses the DHCPv4 Encrypted DNS option if present, and returns its
+// encrypted DNS resolvers ordered by service priority.
+//
+// The Encrypted DNS option is described by RFC 9463, Section 5.1.
+func (d *DHCPv4) DNR() []*DNR {
+	v := d.Options.Get(OptionV4DNR)
+	if v == nil {
+		return nil
+	}
+	var dnrs DNRs
+	if err := dnrs.FromBytes(v); err != nil {
+		return nil
+	}
+	sort.SliceStable(dnrs, func(i, j int) bool {
+		return dnrs[i].ServicePriority < dnrs[j].ServicePriority
+	})
+	return dnrs
+}
+
+// CaptivePortal returns the captive portal API URI of the DHCPv4
+// Captive-Portal option, or "" if it is not present or invalid. It may be
+// CaptivePortalUnrestricted.
+//
+// The Captive-Portal option is described by RFC 8910, Section 2.1.
+func (d *DHCPv4) CaptivePortal() string {
+	uri := GetString(OptionCaptivePortal, d.Options)
+	if ValidCaptivePortalURI(uri) != nil {
+		return ""
+	}
+	return uri
+}
+
+// Authentication parses the DHCPv4 Authentication option if present.
+//
+// The Authentication option is described by RFC 3118.
+func (d *DHCPv4) Authentication() *Authentication {
+	v := d.Options.Get(OptionAuthentication)
+	if v == nil {
+		return nil
+	}
+	var a Authentication
+	if err := a.FromBytes(v); err != nil {
+		return nil
+	}
+	return &a
+}
+
 // RootPath parses the DHCPv4 Root Path option if present.
 //
 // The Root Path option is described by RFC 2132, Section 3.19.
@@ -644,6 +756,38 @@ func (d *DHCPv4) ClientArch() []iana.Arch {
 	return archs
 }
 
+// ClientNetworkInterfaceIdentifier returns the client network interface
+// identifier option if present.
+//
+// The option is described by RFC 4578, Section 2.2.
+func (d *DHCPv4) ClientNetworkInterfaceIdentifier() *NetworkInterfaceIdentifier {
+	v := d.Options.Get(OptionClientNetworkInterfaceIdentifier)
+	if v == nil {
+		return nil
+	}
+	var n NetworkInterfaceIdentifier
+	if err := n.FromBytes(v); err != nil {
+		return nil
+	}
+	return &n
+}
+
+// ClientMachineIdentifier returns the client machine identifier option if
+// present.
+//
+// The option is described by RFC 4578, Section 2.3.
+func (d *DHCPv4) ClientMachineIdentifier() *MachineIdentifier {
+	v := d.Options.Get(OptionClientMachineIdentifier)
+	if v == nil {
+		return nil
+	}
+	var m MachineIdentifier
+	if err := m.FromBytes(v); err != nil {
+		return nil
+	}
+	return &m
+}
+
 // DomainSearch returns the domain search list if present.
 //
 // The domain search option is described by RFC 3397, Section 2.
@@ -730,6 +874,20 @@ func (d *DHCPv4) MaxMessageSize() (uint16, error) {
 	return GetUint16(OptionMaximumDHCPMessageSize, d.Options)
 }
 
+// InterfaceMTU returns the Interface MTU option if present and valid.
+//
+// The Interface MTU option is described by RFC 2132, Section 5.1.
+func (d *DHCPv4) InterfaceMTU() (uint16, error) {
+	mtu, err := GetUint16(OptionInterfaceMTU, d.Options)
+	if err != nil {
+		return 0, err
+	}
+	if mtu < 68 {
+		return 0, fmt.Errorf("interface MTU %d is below the minimum of 68", mtu)
+	}
+	return mtu, nil
+}
+
 // AutoConfigure returns the value of the AutoConfigure option, and a
 // boolean indicating if it was present.
 //
@@ -831,3 +989,32 @@ func (d *DHCPv4) VIVC() VIVCIdentifiers {
 	}
 	return ids
 }
+
+// VIVS returns the vendor-identifying vendor-specific information option if
+// present.
+//
+// Use VendorRegistry.DecodeVIVS to decode the sub-options of an enterprise.
+func (d *DHCPv4) VIVS() VIVSOptions {
+	v := d.Options.Get(OptionVendorIdentifyingVendorSpecific)
+	if v == nil {
+		return nil
+	}
+	var vivs VIVSOptions
+	if err := vivs.FromBytes(v); err != nil {
+		return nil
+	}
+	return vivs
+}
+
+// VendorSpecific returns the vendor-specific information option decoded with
+// the decoder globally registered for d's class identifier, e.g. a
+// *PXEOptions for PXE clients.
+//
+// It returns nil if the option is not present or cannot be decoded.
+func (d *DHCPv4) VendorSpecific() OptionDecoder {
+	v, err := defaultVendorRegistry.VendorSpecific(d)
+	if err != nil {
+		return nil
+	}
+	return v
+}
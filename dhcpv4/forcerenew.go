@@ -0,0 +1,197 @@
+package dhcpv4
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/u-root/uio/uio"
+)
+
+// Types of the Authentication information of the reconfigure key protocol,
+// RFC 6704, Section 3.
+const (
+	// ForcerenewNonceValue is the type of the nonce sent in ACKs.
+	ForcerenewNonceValue uint8 = 1
+	// ForcerenewHMACMD5Digest is the type of the HMAC sent in FORCERENEW
+	// messages.
+	ForcerenewHMACMD5Digest uint8 = 2
+)
+
+// ForcerenewNonceLen is the length of the nonces of RFC 6704.
+const ForcerenewNonceLen = 16
+
+// AuthAlgorithms is the value of the Forcerenew Nonce Capable option: the
+// algorithms a client supports for the nonce authentication of FORCERENEW
+// messages.
+type AuthAlgorithms []AuthAlgorithm
+
+// ToBytes returns the serialized option.
+func (a AuthAlgorithms) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, alg := range a {
+		buf.Write8(uint8(alg))
+	}
+	return buf.Data()
+}
+
+// FromBytes parses the option from data.
+func (a *AuthAlgorithms) FromBytes(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("%w: empty Forcerenew Nonce Capable option", uio.ErrBufferTooShort)
+	}
+	*a = make(AuthAlgorithms, 0, len(data))
+	for _, b := range data {
+		*a = append(*a, AuthAlgorithm(b))
+	}
+	return nil
+}
+
+// String returns a human-readable representation of the option.
+func (a AuthAlgorithms) String() string {
+	s := make([]string, 0, len(a))
+	for _, alg := range a {
+		s = append(s, alg.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+// Supports returns whether alg is one of a.
+func (a AuthAlgorithms) Supports(alg AuthAlgorithm) bool {
+	for _, b := range a {
+		if b == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// OptForcerenewNonceCapable returns a new DHCPv4 Forcerenew Nonce Capable
+// option.
+//
+// The Forcerenew Nonce Capable option is described by RFC 6704, Section 3.1.1.
+func OptForcerenewNonceCapable(algs ...AuthAlgorithm) Option {
+	return Option{Code: OptionForcerenewNonceCapable, Value: AuthAlgorithms(algs)}
+}
+
+// ForcerenewNonceCapable parses the DHCPv4 Forcerenew Nonce Capable option if
+// present.
+//
+// The Forcerenew Nonce Capable option is described by RFC 6704, Section
+// 3.1.1.
+func (d *DHCPv4) ForcerenewNonceCapable() AuthAlgorithms {
+	v := d.Options.Get(OptionForcerenewNonceCapable)
+	if v == nil {
+		return nil
+	}
+	var a AuthAlgorithms
+	if err := a.FromBytes(v); err != nil {
+		return nil
+	}
+	return a
+}
+
+// WithForcerenewNonceCapable tells the server that the client accepts
+// FORCERENEW messages authenticated with an HMAC-MD5 nonce.
+func WithForcerenewNonceCapable() Modifier {
+	return WithOption(OptForcerenewNonceCapable(AuthAlgorithmHMACMD5))
+}
+
+// NewForcerenewNonce returns a random nonce.
+func NewForcerenewNonce() ([]byte, error) {
+	nonce := make([]byte, ForcerenewNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// OptForcerenewNonce returns an Authentication option that gives nonce to a
+// client, to be included in an ACK, as described in RFC 6704, Section 3.1.2.
+func OptForcerenewNonce(nonce []byte, replay uint64) Option {
+	return OptAuthentication(&Authentication{
+		Protocol:        AuthProtocolReconfigureKey,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: replay,
+		Information:     append([]byte{ForcerenewNonceValue}, nonce...),
+	})
+}
+
+// ForcerenewNonce returns the nonce the server gave in an ACK, or nil.
+func (d *DHCPv4) ForcerenewNonce() []byte {
+	a := d.Authentication()
+	if a == nil || a.Protocol != AuthProtocolReconfigureKey || a.Algorithm != AuthAlgorithmHMACMD5 ||
+		len(a.Information) != 1+ForcerenewNonceLen || a.Information[0] != ForcerenewNonceValue {
+		return nil
+	}
+	return a.Information[1:]
+}
+
+// NewForceRenew creates a FORCERENEW message from the server with the given
+// identifier, to the client with the given hardware address, as described in
+// RFC 3203.
+func NewForceRenew(serverID net.IP, clientHWAddr net.HardwareAddr, modifiers ...Modifier) (*DHCPv4, error) {
+	return New(PrependModifiers(modifiers,
+		func(d *DHCPv4) { d.OpCode = OpcodeBootReply },
+		WithMessageType(MessageTypeForceRenew),
+		WithHwAddr(clientHWAddr),
+		WithOption(OptServerIdentifier(serverID)),
+	)...)
+}
+
+// SignForceRenew adds an Authentication option to the FORCERENEW message d,
+// authenticated with the nonce given to the client, as described in RFC 6704,
+// Section 3.2. It must be the last change made to d.
+func SignForceRenew(d *DHCPv4, nonce []byte, replay uint64) error {
+	info := make([]byte, 1+ForcerenewNonceLen)
+	info[0] = ForcerenewHMACMD5Digest
+	auth := &Authentication{
+		Protocol:        AuthProtocolReconfigureKey,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: replay,
+		Information:     info,
+	}
+	d.UpdateOption(OptAuthentication(auth))
+	mac, err := authMAC(d.ToBytes(), nonce, len(info), false)
+	if err != nil {
+		return err
+	}
+	copy(info[1:], mac)
+	d.UpdateOption(OptAuthentication(auth))
+	return nil
+}
+
+// VerifyForceRenew parses the FORCERENEW message msg, as received on the
+// wire, and checks that it is authenticated with nonce. Callers must check
+// that the replay detection value of the Authentication option increases.
+func VerifyForceRenew(msg []byte, nonce []byte) (*DHCPv4, error) {
+	d, err := FromBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	if d.MessageType() != MessageTypeForceRenew {
+		return nil, fmt.Errorf("dhcpv4: not a FORCERENEW message: %s", d.MessageType())
+	}
+	auth := d.Authentication()
+	if auth == nil {
+		return nil, ErrAuthMissing
+	}
+	if auth.Protocol != AuthProtocolReconfigureKey || auth.Algorithm != AuthAlgorithmHMACMD5 || auth.RDM != AuthRDMMonotonic {
+		return nil, fmt.Errorf("%w: %s", ErrAuthProtocol, auth)
+	}
+	if len(auth.Information) != 1+ForcerenewNonceLen || auth.Information[0] != ForcerenewHMACMD5Digest {
+		return nil, ErrAuthInvalid
+	}
+	mac, err := authMAC(msg, nonce, len(auth.Information), false)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, auth.Information[1:]) {
+		return nil, ErrAuthInvalid
+	}
+	return d, nil
+}
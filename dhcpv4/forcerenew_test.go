@@ -0,0 +1,80 @@
+package dhcpv4
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestForcerenewNonceCapable(t *testing.T) {
+	m, err := New(WithForcerenewNonceCapable())
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, m.Options.Get(OptionForcerenewNonceCapable))
+	require.Equal(t, AuthAlgorithms{AuthAlgorithmHMACMD5}, m.ForcerenewNonceCapable())
+	require.True(t, m.ForcerenewNonceCapable().Supports(AuthAlgorithmHMACMD5))
+	require.Contains(t, m.Summary(), "Forcerenew Nonce Capable: HMAC-MD5")
+
+	var a AuthAlgorithms
+	require.Error(t, a.FromBytes(nil))
+	m.UpdateOption(OptGeneric(OptionForcerenewNonceCapable, nil))
+	require.Nil(t, m.ForcerenewNonceCapable())
+	require.False(t, m.ForcerenewNonceCapable().Supports(AuthAlgorithmHMACMD5))
+}
+
+func TestForcerenewNonce(t *testing.T) {
+	nonce, err := NewForcerenewNonce()
+	require.NoError(t, err)
+	require.Len(t, nonce, ForcerenewNonceLen)
+
+	ack, err := New(WithMessageType(MessageTypeAck), WithOption(OptForcerenewNonce(nonce, 5)))
+	require.NoError(t, err)
+	require.Equal(t, nonce, ack.ForcerenewNonce())
+	require.Equal(t, uint64(5), ack.Authentication().ReplayDetection)
+
+	ack.UpdateOption(OptAuthentication(&Authentication{Protocol: AuthProtocolDelayed, Algorithm: AuthAlgorithmHMACMD5}))
+	require.Nil(t, ack.ForcerenewNonce())
+}
+
+func TestForceRenew(t *testing.T) {
+	serverID := net.IPv4(192, 0, 2, 1)
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	nonce := []byte("0123456789abcdef")
+
+	m, err := NewForceRenew(serverID, hwaddr)
+	require.NoError(t, err)
+	require.Equal(t, OpcodeBootReply, m.OpCode)
+	require.Equal(t, MessageTypeForceRenew, m.MessageType())
+	require.Equal(t, "FORCERENEW", m.MessageType().String())
+	require.True(t, m.ServerIdentifier().Equal(serverID))
+	require.Equal(t, hwaddr, m.ClientHWAddr)
+
+	_, err = VerifyForceRenew(m.ToBytes(), nonce)
+	require.Equal(t, ErrAuthMissing, err)
+
+	require.NoError(t, SignForceRenew(m, nonce, 10))
+	auth := m.Authentication()
+	require.Equal(t, AuthProtocolReconfigureKey, auth.Protocol)
+	require.Equal(t, ForcerenewHMACMD5Digest, auth.Information[0])
+	got, err := VerifyForceRenew(m.ToBytes(), nonce)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), got.Authentication().ReplayDetection)
+
+	_, err = VerifyForceRenew(m.ToBytes(), []byte("fedcba9876543210"))
+	require.Equal(t, ErrAuthInvalid, err)
+	m.ClientIPAddr = net.IPv4(192, 0, 2, 10)
+	_, err = VerifyForceRenew(m.ToBytes(), nonce)
+	require.Equal(t, ErrAuthInvalid, err)
+
+	m.UpdateOption(OptForcerenewNonce(nonce, 11))
+	_, err = VerifyForceRenew(m.ToBytes(), nonce)
+	require.Equal(t, ErrAuthInvalid, err)
+	m.UpdateOption(OptAuthentication(&Authentication{Protocol: AuthProtocolDelayed, Algorithm: AuthAlgorithmHMACMD5}))
+	_, err = VerifyForceRenew(m.ToBytes(), nonce)
+	require.True(t, errors.Is(err, ErrAuthProtocol))
+
+	m.UpdateOption(OptMessageType(MessageTypeAck))
+	_, err = VerifyForceRenew(m.ToBytes(), nonce)
+	require.Error(t, err)
+}
@@ -0,0 +1,468 @@
+package dhcpv4
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/u-root/uio/uio"
+)
+
+// StatusCode is a status code of the Status Code option, as defined by RFC
+// 6926, Section 6.2.2, and RFC 7724, Section 5.2.2.
+type StatusCode uint8
+
+// Status codes.
+const (
+	StatusSuccess         StatusCode = 0
+	StatusUnspecFail      StatusCode = 1
+	StatusQueryTerminated StatusCode = 2
+	StatusMalformedQuery  StatusCode = 3
+	StatusNotAllowed      StatusCode = 4
+	// Active leasequery status codes.
+	StatusDataMissing          StatusCode = 5
+	StatusConnectionActive     StatusCode = 6
+	StatusCatchUpComplete      StatusCode = 7
+	StatusTLSConnectionRefused StatusCode = 8
+)
+
+var statusCodeToString = map[StatusCode]string{
+	StatusSuccess:              "Success",
+	StatusUnspecFail:           "UnspecFail",
+	StatusQueryTerminated:      "QueryTerminated",
+	StatusMalformedQuery:       "MalformedQuery",
+	StatusNotAllowed:           "NotAllowed",
+	StatusDataMissing:          "DataMissing",
+	StatusConnectionActive:     "ConnectionActive",
+	StatusCatchUpComplete:      "CatchUpComplete",
+	StatusTLSConnectionRefused: "TLSConnectionRefused",
+}
+
+// String returns the name of the status code.
+func (s StatusCode) String() string {
+	if name, ok := statusCodeToString[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(s))
+}
+
+// Status is the value of the Status Code option: a status code, and an
+// optional UTF-8 message.
+type Status struct {
+	Code    StatusCode
+	Message string
+}
+
+// ToBytes returns the serialized option.
+func (s *Status) ToBytes() []byte {
+	return append([]byte{uint8(s.Code)}, s.Message...)
+}
+
+// FromBytes parses the option from data.
+func (s *Status) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	s.Code = StatusCode(buf.Read8())
+	s.Message = string(buf.ReadAll())
+	return buf.FinError()
+}
+
+// String returns a human-readable representation of the option.
+func (s *Status) String() string {
+	if s.Message == "" {
+		return s.Code.String()
+	}
+	return fmt.Sprintf("%s (%s)", s.Code, s.Message)
+}
+
+// OptStatusCode returns a new DHCPv4 Status Code option.
+//
+// The Status Code option is described by RFC 6926, Section 6.2.2.
+func OptStatusCode(code StatusCode, message string) Option {
+	return Option{Code: OptionStatusCode, Value: &Status{Code: code, Message: message}}
+}
+
+// Status returns the Status Code option, or nil if not present.
+//
+// The Status Code option is described by RFC 6926, Section 6.2.2.
+func (d *DHCPv4) Status() *Status {
+	v := d.Options.Get(OptionStatusCode)
+	if v == nil {
+		return nil
+	}
+	var s Status
+	if err := s.FromBytes(v); err != nil {
+		return nil
+	}
+	return &s
+}
+
+// Time is the value of options carrying an absolute time, in seconds since
+// the Unix epoch, as defined by RFC 6926, Section 6.2.3.
+type Time time.Time
+
+// ToBytes returns the serialized option.
+func (t Time) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write32(uint32(time.Time(t).Unix()))
+	return buf.Data()
+}
+
+// FromBytes parses the option from data.
+func (t *Time) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	*t = Time(time.Unix(int64(buf.Read32()), 0))
+	return buf.FinError()
+}
+
+// String returns a human-readable representation of the option.
+func (t Time) String() string {
+	return time.Time(t).UTC().Format(time.RFC3339)
+}
+
+// OptBaseTime returns a new DHCPv4 Base Time option: the current time of the
+// server, which other times of the message are relative to.
+//
+// The Base Time option is described by RFC 6926, Section 6.2.3.
+func OptBaseTime(t time.Time) Option {
+	return Option{Code: OptionBaseTime, Value: Time(t)}
+}
+
+// OptQueryStartTime returns a new DHCPv4 Query Start Time option.
+//
+// The Query Start Time option is described by RFC 6926, Section 6.2.5.
+func OptQueryStartTime(t time.Time) Option {
+	return Option{Code: OptionQueryStartTime, Value: Time(t)}
+}
+
+// OptQueryEndTime returns a new DHCPv4 Query End Time option.
+//
+// The Query End Time option is described by RFC 6926, Section 6.2.6.
+func OptQueryEndTime(t time.Time) Option {
+	return Option{Code: OptionQueryEndTime, Value: Time(t)}
+}
+
+func (d *DHCPv4) time(code OptionCode) (time.Time, bool) {
+	v := d.Options.Get(code)
+	if v == nil {
+		return time.Time{}, false
+	}
+	var t Time
+	if err := t.FromBytes(v); err != nil {
+		return time.Time{}, false
+	}
+	return time.Time(t), true
+}
+
+// BaseTime returns the time of the Base Time option, if present.
+//
+// The Base Time option is described by RFC 6926, Section 6.2.3.
+func (d *DHCPv4) BaseTime() (time.Time, bool) {
+	return d.time(OptionBaseTime)
+}
+
+// QueryStartTime returns the time of the Query Start Time option, if present.
+//
+// The Query Start Time option is described by RFC 6926, Section 6.2.5.
+func (d *DHCPv4) QueryStartTime() (time.Time, bool) {
+	return d.time(OptionQueryStartTime)
+}
+
+// QueryEndTime returns the time of the Query End Time option, if present.
+//
+// The Query End Time option is described by RFC 6926, Section 6.2.6.
+func (d *DHCPv4) QueryEndTime() (time.Time, bool) {
+	return d.time(OptionQueryEndTime)
+}
+
+// OptClientLastTransactionTime returns a new DHCPv4 Client Last Transaction
+// Time option: how long ago the server last heard from the client.
+//
+// The Client Last Transaction Time option is described by RFC 4388, Section
+// 6.1.
+func OptClientLastTransactionTime(d time.Duration) Option {
+	return Option{Code: OptionClientLastTransactionTime, Value: Duration(d)}
+}
+
+// ClientLastTransactionTime returns the Client Last Transaction Time option,
+// if present.
+//
+// The Client Last Transaction Time option is described by RFC 4388, Section
+// 6.1.
+func (d *DHCPv4) ClientLastTransactionTime() (time.Duration, bool) {
+	return d.duration(OptionClientLastTransactionTime)
+}
+
+// OptStartTimeOfState returns a new DHCPv4 Start Time of State option: how
+// long before the base time the lease entered its current state.
+//
+// The Start Time of State option is described by RFC 6926, Section 6.2.4.
+func OptStartTimeOfState(d time.Duration) Option {
+	return Option{Code: OptionStartTimeOfState, Value: Duration(d)}
+}
+
+// StartTimeOfState returns the Start Time of State option, if present.
+//
+// The Start Time of State option is described by RFC 6926, Section 6.2.4.
+func (d *DHCPv4) StartTimeOfState() (time.Duration, bool) {
+	return d.duration(OptionStartTimeOfState)
+}
+
+func (d *DHCPv4) duration(code OptionCode) (time.Duration, bool) {
+	v := d.Options.Get(code)
+	if v == nil {
+		return 0, false
+	}
+	var dur Duration
+	if err := dur.FromBytes(v); err != nil {
+		return 0, false
+	}
+	return time.Duration(dur), true
+}
+
+// OptAssociatedIP returns a new DHCPv4 Associated IP option: all the
+// addresses bound to the client a lease query is about.
+//
+// The Associated IP option is described by RFC 4388, Section 6.1.
+func OptAssociatedIP(ips ...net.IP) Option {
+	return Option{Code: OptionAssociatedIP, Value: IPs(ips)}
+}
+
+// AssociatedIP returns the addresses of the Associated IP option, if present.
+//
+// The Associated IP option is described by RFC 4388, Section 6.1.
+func (d *DHCPv4) AssociatedIP() []net.IP {
+	return GetIPs(OptionAssociatedIP, d.Options)
+}
+
+// DHCPState is the value of the DHCP State option.
+type DHCPState uint8
+
+// DHCP states, as defined by RFC 6926, Section 6.2.7.
+const (
+	DHCPStateAvailable     DHCPState = 1
+	DHCPStateActive        DHCPState = 2
+	DHCPStateExpired       DHCPState = 3
+	DHCPStateReleased      DHCPState = 4
+	DHCPStateAbandoned     DHCPState = 5
+	DHCPStateReset         DHCPState = 6
+	DHCPStateRemote        DHCPState = 7
+	DHCPStateTransitioning DHCPState = 8
+)
+
+var dhcpStateToString = map[DHCPState]string{
+	DHCPStateAvailable:     "AVAILABLE",
+	DHCPStateActive:        "ACTIVE",
+	DHCPStateExpired:       "EXPIRED",
+	DHCPStateReleased:      "RELEASED",
+	DHCPStateAbandoned:     "ABANDONED",
+	DHCPStateReset:         "RESET",
+	DHCPStateRemote:        "REMOTE",
+	DHCPStateTransitioning: "TRANSITIONING",
+}
+
+// ToBytes returns the serialized option.
+func (s DHCPState) ToBytes() []byte {
+	return []byte{uint8(s)}
+}
+
+// FromBytes parses the option from data.
+func (s *DHCPState) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	*s = DHCPState(buf.Read8())
+	return buf.FinError()
+}
+
+// String returns the name of the state.
+func (s DHCPState) String() string {
+	if name, ok := dhcpStateToString[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(s))
+}
+
+// OptDHCPState returns a new DHCPv4 DHCP State option.
+//
+// The DHCP State option is described by RFC 6926, Section 6.2.7.
+func OptDHCPState(s DHCPState) Option {
+	return Option{Code: OptionDHCPState, Value: s}
+}
+
+// DHCPState returns the DHCP State option, if present.
+//
+// The DHCP State option is described by RFC 6926, Section 6.2.7.
+func (d *DHCPv4) DHCPState() (DHCPState, bool) {
+	v := d.Options.Get(OptionDHCPState)
+	if v == nil {
+		return 0, false
+	}
+	var s DHCPState
+	if err := s.FromBytes(v); err != nil {
+		return 0, false
+	}
+	return s, true
+}
+
+// DataSource is the value of the Data Source option.
+type DataSource uint8
+
+// DataSourceRemote means the information of the message comes from another
+// server, RFC 6926, Section 6.2.8.
+const DataSourceRemote DataSource = 1 << 0
+
+// ToBytes returns the serialized option.
+func (s DataSource) ToBytes() []byte {
+	return []byte{uint8(s)}
+}
+
+// FromBytes parses the option from data.
+func (s *DataSource) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	*s = DataSource(buf.Read8())
+	return buf.FinError()
+}
+
+// String returns a human-readable representation of the option.
+func (s DataSource) String() string {
+	if s&DataSourceRemote != 0 {
+		return fmt.Sprintf("REMOTE (%#x)", uint8(s))
+	}
+	return fmt.Sprintf("LOCAL (%#x)", uint8(s))
+}
+
+// OptDataSource returns a new DHCPv4 Data Source option.
+//
+// The Data Source option is described by RFC 6926, Section 6.2.8.
+func OptDataSource(s DataSource) Option {
+	return Option{Code: OptionDataSource, Value: s}
+}
+
+// DataSource returns the Data Source option, if present.
+//
+// The Data Source option is described by RFC 6926, Section 6.2.8.
+func (d *DHCPv4) DataSource() (DataSource, bool) {
+	v := d.Options.Get(OptionDataSource)
+	if v == nil {
+		return 0, false
+	}
+	var s DataSource
+	if err := s.FromBytes(v); err != nil {
+		return 0, false
+	}
+	return s, true
+}
+
+// leasequeryOptions are the options requested by lease queries.
+var leasequeryOptions = []OptionCode{
+	OptionIPAddressLeaseTime,
+	OptionClientLastTransactionTime,
+	OptionAssociatedIP,
+	OptionClientIdentifier,
+	OptionRelayAgentInformation,
+}
+
+// NewLeasequeryByIP builds a DHCPLEASEQUERY message asking about the lease of
+// ip, as described in RFC 4388, Section 6.1.
+//
+// giaddr must be set to an address of the requestor, e.g. with WithGatewayIP.
+func NewLeasequeryByIP(ip net.IP, modifiers ...Modifier) (*DHCPv4, error) {
+	if ip.To4() == nil {
+		return nil, errors.New("lease query address must be an IPv4 address")
+	}
+	return newLeasequery(PrependModifiers(modifiers, WithClientIP(ip))...)
+}
+
+// NewLeasequeryByHardwareAddr builds a DHCPLEASEQUERY message asking about the
+// leases of the client with the given hardware address, as described in RFC
+// 4388, Section 6.1.
+//
+// giaddr must be set to an address of the requestor, e.g. with WithGatewayIP.
+func NewLeasequeryByHardwareAddr(hwaddr net.HardwareAddr, modifiers ...Modifier) (*DHCPv4, error) {
+	if len(hwaddr) == 0 {
+		return nil, errors.New("lease query hardware address cannot be empty")
+	}
+	return newLeasequery(PrependModifiers(modifiers,
+		WithHWType(iana.HWTypeEthernet),
+		WithHwAddr(hwaddr),
+	)...)
+}
+
+// NewLeasequeryByClientID builds a DHCPLEASEQUERY message asking about the
+// leases of the client with the given client identifier, as described in RFC
+// 4388, Section 6.1.
+//
+// giaddr must be set to an address of the requestor, e.g. with WithGatewayIP.
+func NewLeasequeryByClientID(clientID []byte, modifiers ...Modifier) (*DHCPv4, error) {
+	if len(clientID) == 0 {
+		return nil, errors.New("lease query client identifier cannot be empty")
+	}
+	return newLeasequery(PrependModifiers(modifiers,
+		WithOption(OptClientIdentifier(clientID)))...)
+}
+
+func newLeasequery(modifiers ...Modifier) (*DHCPv4, error) {
+	return New(PrependModifiers(modifiers,
+		// Queries that are not by hardware address have zero htype,
+		// hlen and chaddr fields, RFC 4388, Section 6.1.
+		WithHWType(0),
+		WithHwAddr(nil),
+		WithMessageType(MessageTypeLeaseQuery),
+		WithRequestedOptions(leasequeryOptions...),
+	)...)
+}
+
+// NewBulkLeasequery builds a DHCPBULKLEASEQUERY message, to be sent over TCP
+// with WriteTCPMessage, as described in RFC 6926, Section 7.2. Without
+// modifiers, it asks about all the addresses the server is configured with.
+//
+// Queries by address, hardware address or client identifier are built with
+// NewLeasequeryByIP, NewLeasequeryByHardwareAddr or NewLeasequeryByClientID
+// and WithMessageType(MessageTypeBulkLeaseQuery). Any query may be
+// restricted to the bindings that changed between OptQueryStartTime and
+// OptQueryEndTime.
+func NewBulkLeasequery(modifiers ...Modifier) (*DHCPv4, error) {
+	return newLeasequery(PrependModifiers(modifiers,
+		WithMessageType(MessageTypeBulkLeaseQuery))...)
+}
+
+// NewBulkLeasequeryByRelayID builds a DHCPBULKLEASEQUERY message asking about
+// the bindings made through the relay agent with the given Relay Identifier,
+// as described in RFC 6926, Section 7.2.
+func NewBulkLeasequeryByRelayID(relayID []byte, modifiers ...Modifier) (*DHCPv4, error) {
+	if len(relayID) == 0 {
+		return nil, errors.New("lease query relay identifier cannot be empty")
+	}
+	return NewBulkLeasequery(PrependModifiers(modifiers,
+		WithOption(OptRelayAgentInfo(OptGeneric(RelayIDSubOption, relayID))))...)
+}
+
+// NewBulkLeasequeryByRemoteID builds a DHCPBULKLEASEQUERY message asking about
+// the bindings with the given relay agent Remote ID, as described in RFC
+// 6926, Section 7.2.
+func NewBulkLeasequeryByRemoteID(remoteID []byte, modifiers ...Modifier) (*DHCPv4, error) {
+	if len(remoteID) == 0 {
+		return nil, errors.New("lease query remote ID cannot be empty")
+	}
+	return NewBulkLeasequery(PrependModifiers(modifiers,
+		WithOption(OptRelayAgentInfo(OptGeneric(AgentRemoteIDSubOption, remoteID))))...)
+}
+
+// NewActiveLeasequery builds a DHCPACTIVELEASEQUERY message, to be sent over
+// TCP with WriteTCPMessage, as described in RFC 7724, Section 6.1. The server
+// then sends the changes of its bindings as they happen.
+//
+// Queries with OptQueryStartTime also get the bindings that changed since
+// that time. They may be restricted to the bindings of a relay agent with
+// the relay-id or remote-id sub-options of a Relay Agent Information option.
+func NewActiveLeasequery(modifiers ...Modifier) (*DHCPv4, error) {
+	return newLeasequery(PrependModifiers(modifiers,
+		WithMessageType(MessageTypeActiveLeaseQuery))...)
+}
+
+// NewLeasequeryTLS builds a DHCPTLS message, asking to secure a leasequery
+// connection with TLS, as described in RFC 7724, Section 6.3.
+func NewLeasequeryTLS(modifiers ...Modifier) (*DHCPv4, error) {
+	return newLeasequery(PrependModifiers(modifiers,
+		WithMessageType(MessageTypeTLS))...)
+}
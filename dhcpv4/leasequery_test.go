@@ -0,0 +1,171 @@
+package dhcpv4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeasequeryOptions(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	m, err := New(
+		WithMessageType(MessageTypeLeaseActive),
+		WithOption(OptStatusCode(StatusNotAllowed, "go away")),
+		WithOption(OptBaseTime(base)),
+		WithOption(OptQueryStartTime(base.Add(-time.Hour))),
+		WithOption(OptQueryEndTime(base.Add(time.Hour))),
+		WithOption(OptClientLastTransactionTime(30*time.Second)),
+		WithOption(OptStartTimeOfState(time.Minute)),
+		WithOption(OptAssociatedIP(net.IPv4(10, 0, 0, 1), net.IPv4(10, 0, 0, 2))),
+		WithOption(OptDHCPState(DHCPStateActive)),
+		WithOption(OptDataSource(DataSourceRemote)),
+	)
+	require.NoError(t, err)
+	m, err = FromBytes(m.ToBytes())
+	require.NoError(t, err)
+
+	require.Equal(t, &Status{Code: StatusNotAllowed, Message: "go away"}, m.Status())
+	bt, ok := m.BaseTime()
+	require.True(t, ok)
+	require.True(t, base.Equal(bt))
+	st, ok := m.QueryStartTime()
+	require.True(t, ok)
+	require.True(t, base.Add(-time.Hour).Equal(st))
+	et, ok := m.QueryEndTime()
+	require.True(t, ok)
+	require.True(t, base.Add(time.Hour).Equal(et))
+	cltt, ok := m.ClientLastTransactionTime()
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, cltt)
+	sts, ok := m.StartTimeOfState()
+	require.True(t, ok)
+	require.Equal(t, time.Minute, sts)
+	require.Equal(t, []net.IP{net.IPv4(10, 0, 0, 1).To4(), net.IPv4(10, 0, 0, 2).To4()}, m.AssociatedIP())
+	state, ok := m.DHCPState()
+	require.True(t, ok)
+	require.Equal(t, DHCPStateActive, state)
+	src, ok := m.DataSource()
+	require.True(t, ok)
+	require.Equal(t, DataSourceRemote, src)
+
+	summary := m.Summary()
+	for _, s := range []string{
+		"LEASEACTIVE",
+		"Status Code: NotAllowed (go away)",
+		"Base Time: 2023-11-14T22:13:20Z",
+		"Client Last Transaction Time: 30s",
+		"Associated IP: 10.0.0.1, 10.0.0.2",
+		"DHCP State: ACTIVE",
+		"Data Source: REMOTE (0x1)",
+	} {
+		require.Contains(t, summary, s)
+	}
+
+	m, err = New()
+	require.NoError(t, err)
+	require.Nil(t, m.Status())
+	_, ok = m.BaseTime()
+	require.False(t, ok)
+	_, ok = m.ClientLastTransactionTime()
+	require.False(t, ok)
+	_, ok = m.DHCPState()
+	require.False(t, ok)
+	_, ok = m.DataSource()
+	require.False(t, ok)
+	m.UpdateOption(OptGeneric(OptionDHCPState, []byte{1, 2}))
+	_, ok = m.DHCPState()
+	require.False(t, ok)
+	m.UpdateOption(OptGeneric(OptionStatusCode, nil))
+	require.Nil(t, m.Status())
+
+	require.Equal(t, "Success", StatusSuccess.String())
+	require.Equal(t, "unknown (9)", StatusCode(9).String())
+	require.Equal(t, "unknown (9)", DHCPState(9).String())
+	require.Equal(t, "LOCAL (0x0)", DataSource(0).String())
+}
+
+func TestNewLeasequery(t *testing.T) {
+	giaddr := net.IPv4(192, 0, 2, 1)
+	q, err := NewLeasequeryByIP(net.IPv4(10, 0, 0, 1), WithGatewayIP(giaddr))
+	require.NoError(t, err)
+	require.Equal(t, OpcodeBootRequest, q.OpCode)
+	require.Equal(t, MessageTypeLeaseQuery, q.MessageType())
+	require.Equal(t, "LEASEQUERY", q.MessageType().String())
+	require.True(t, q.ClientIPAddr.Equal(net.IPv4(10, 0, 0, 1)))
+	require.True(t, q.GatewayIPAddr.Equal(giaddr))
+	require.Equal(t, iana.HWType(0), q.HWType)
+	require.Empty(t, q.ClientHWAddr)
+	require.True(t, q.IsOptionRequested(OptionClientLastTransactionTime))
+	require.True(t, q.IsOptionRequested(OptionAssociatedIP))
+
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	q, err = NewLeasequeryByHardwareAddr(hwaddr)
+	require.NoError(t, err)
+	require.Equal(t, iana.HWTypeEthernet, q.HWType)
+	require.Equal(t, hwaddr, q.ClientHWAddr)
+	require.True(t, q.ClientIPAddr.IsUnspecified())
+
+	q, err = NewLeasequeryByClientID([]byte("client"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("client"), q.Options.Get(OptionClientIdentifier))
+	require.Empty(t, q.ClientHWAddr)
+
+	_, err = NewLeasequeryByIP(net.ParseIP("2001:db8::1"))
+	require.Error(t, err)
+	_, err = NewLeasequeryByHardwareAddr(nil)
+	require.Error(t, err)
+	_, err = NewLeasequeryByClientID(nil)
+	require.Error(t, err)
+}
+
+func TestNewBulkLeasequery(t *testing.T) {
+	q, err := NewBulkLeasequery()
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeBulkLeaseQuery, q.MessageType())
+	require.Equal(t, "BULKLEASEQUERY", q.MessageType().String())
+	require.Equal(t, "LEASEQUERYDONE", MessageTypeLeaseQueryDone.String())
+	require.Empty(t, q.ClientHWAddr)
+	require.True(t, q.ClientIPAddr.IsUnspecified())
+	require.Nil(t, q.RelayAgentInfo())
+
+	q, err = NewBulkLeasequeryByRelayID([]byte("relay"))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeBulkLeaseQuery, q.MessageType())
+	require.Equal(t, []byte("relay"), q.RelayAgentInfo().Get(RelayIDSubOption))
+
+	q, err = NewBulkLeasequeryByRemoteID([]byte("remote"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("remote"), q.RelayAgentInfo().Get(AgentRemoteIDSubOption))
+	require.Nil(t, q.RelayAgentInfo().Get(RelayIDSubOption))
+
+	q, err = NewLeasequeryByIP(net.IPv4(10, 0, 0, 1), WithMessageType(MessageTypeBulkLeaseQuery))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeBulkLeaseQuery, q.MessageType())
+
+	_, err = NewBulkLeasequeryByRelayID(nil)
+	require.Error(t, err)
+	_, err = NewBulkLeasequeryByRemoteID(nil)
+	require.Error(t, err)
+}
+
+func TestNewActiveLeasequery(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	q, err := NewActiveLeasequery(WithOption(OptQueryStartTime(start)))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeActiveLeaseQuery, q.MessageType())
+	require.Equal(t, "ACTIVELEASEQUERY", q.MessageType().String())
+	got, ok := q.QueryStartTime()
+	require.True(t, ok)
+	require.True(t, start.Equal(got))
+	require.Empty(t, q.ClientHWAddr)
+
+	q, err = NewLeasequeryTLS()
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeTLS, q.MessageType())
+	require.Equal(t, "LEASEQUERYSTATUS", MessageTypeLeaseQueryStatus.String())
+	require.Equal(t, "CatchUpComplete", StatusCatchUpComplete.String())
+	require.Equal(t, "TLSConnectionRefused", StatusTLSConnectionRefused.String())
+}
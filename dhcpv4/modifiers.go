@@ -171,6 +171,40 @@ func WithDomainSearchList(searchList ...string) Modifier {
 	}))
 }
 
+// WithFQDN adds or updates an OptFQDN with the given flags and domain name.
+func WithFQDN(flags uint8, domainName string) Modifier {
+	return WithOption(OptFQDN(&FQDN{Flags: flags, DomainName: domainName}))
+}
+
+// With6RD adds or updates an Opt6RD.
+func With6RD(s *SixRD) Modifier {
+	return WithOption(Opt6RD(s))
+}
+
+// WithDNR adds or updates an OptDNR with the given encrypted DNS resolvers.
+func WithDNR(dnrs ...*DNR) Modifier {
+	return WithOption(OptDNR(dnrs...))
+}
+
+// WithCaptivePortalByClass adds or updates an OptCaptivePortal with the
+// captive portal API URI of the client class of request, if it requested the
+// option: the URI of the first of its user classes that has one, or else of
+// its class identifier, or else the default URI of uris.
+func WithCaptivePortalByClass(request *DHCPv4, uris *CaptivePortalClasses) Modifier {
+	return func(d *DHCPv4) {
+		if !request.IsOptionRequested(OptionCaptivePortal) {
+			return
+		}
+		classes := request.UserClass()
+		if ci := request.ClassIdentifier(); ci != "" {
+			classes = append(classes, ci)
+		}
+		if uri, ok := uris.Lookup(classes); ok {
+			d.UpdateOption(Option{Code: OptionCaptivePortal, Value: CaptivePortalURI(uri)})
+		}
+	}
+}
+
 func WithGeneric(code OptionCode, value []byte) Modifier {
 	return WithOption(OptGeneric(code, value))
 }
@@ -0,0 +1,255 @@
+package nclient4
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/mdlayher/packet"
+)
+
+// acdTimings holds the address conflict detection timing constants.
+type acdTimings struct {
+	probeWait         time.Duration
+	probeNum          int
+	probeMin          time.Duration
+	probeMax          time.Duration
+	announceWait      time.Duration
+	announceNum       int
+	announceInterval  time.Duration
+	maxConflicts      int
+	rateLimitInterval time.Duration
+	// declineWait is how long to wait after a DECLINE before restarting
+	// the configuration, RFC 2131, Section 3.1.
+	declineWait time.Duration
+}
+
+// defaultACDTimings are the values from RFC 5227, Section 1.1.
+var defaultACDTimings = acdTimings{
+	probeWait:         1 * time.Second,
+	probeNum:          3,
+	probeMin:          1 * time.Second,
+	probeMax:          2 * time.Second,
+	announceWait:      2 * time.Second,
+	announceNum:       2,
+	announceInterval:  2 * time.Second,
+	maxConflicts:      10,
+	rateLimitInterval: 60 * time.Second,
+	declineWait:       10 * time.Second,
+}
+
+var (
+	// ErrNoARPConn is returned when conflict detection is used without an
+	// ARP connection.
+	ErrNoARPConn = errors.New("no ARP connection for address conflict detection")
+)
+
+// ErrAddressConflict is returned by ProbeAddress if another host uses the
+// probed address.
+type ErrAddressConflict struct {
+	IP net.IP
+	// HardwareAddr is the hardware address of the conflicting host.
+	HardwareAddr net.HardwareAddr
+}
+
+// Error implements error.Error.
+func (e *ErrAddressConflict) Error() string {
+	return fmt.Sprintf("address %s is already in use by %s", e.IP, e.HardwareAddr)
+}
+
+// WithConflictDetection enables address conflict detection (RFC 5227) in
+// Request: the leased address is probed with ARP before Request returns, and
+// if another host uses it, the lease is declined and the configuration is
+// restarted. Once the address is bound, it is announced with gratuitous ARP.
+//
+// The ARP socket is opened on the interface given to New. Clients created with
+// NewWithConn must use WithARPConn instead.
+func WithConflictDetection() ClientOpt {
+	return func(c *Client) (err error) {
+		c.acd = true
+		return nil
+	}
+}
+
+// WithARPConn enables address conflict detection like WithConflictDetection,
+// using conn to send and receive ARP packets, e.g. a socket returned by
+// NewRawARPConn. The client closes conn when it is closed.
+func WithARPConn(conn net.PacketConn) ClientOpt {
+	return func(c *Client) (err error) {
+		c.acd = true
+		c.arpConn = conn
+		return nil
+	}
+}
+
+func randDuration(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + time.Duration(rand.Int63n(int64(max-min)))
+}
+
+func (c *Client) sendARP(p *arpPacket) error {
+	_, err := c.arpConn.WriteTo(p.ToBytes(), &packet.Addr{HardwareAddr: BroadcastMac})
+	return err
+}
+
+// ProbeAddress checks whether ip is used by another host on the link, as
+// described in RFC 5227, Section 2.1: it sends ARP probes for ip and listens
+// for ARP packets from hosts using or probing for it.
+//
+// ProbeAddress returns an *ErrAddressConflict if ip is in use, and nil if it
+// is free to use. It takes several seconds.
+func (c *Client) ProbeAddress(ctx context.Context, ip net.IP) error {
+	if c.arpConn == nil {
+		return ErrNoARPConn
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	conflict := make(chan error, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conflict <- c.watchARP(ctx, ip)
+	}()
+	defer func() {
+		// Unblock watchARP and wait for it to return.
+		cancel()
+		_ = c.arpConn.SetReadDeadline(time.Now())
+		wg.Wait()
+		_ = c.arpConn.SetReadDeadline(time.Time{})
+	}()
+
+	t := c.acdTimings
+	wait := func(d time.Duration) error {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return nil
+		case err := <-conflict:
+			if err == nil {
+				return ctx.Err()
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.done:
+			return net.ErrClosed
+		}
+	}
+
+	if err := wait(randDuration(0, t.probeWait)); err != nil {
+		return err
+	}
+	for i := 0; i < t.probeNum; i++ {
+		if err := c.sendARP(newARPProbe(c.ifaceHWAddr, ip)); err != nil {
+			return fmt.Errorf("unable to send ARP probe: %w", err)
+		}
+		if i < t.probeNum-1 {
+			if err := wait(randDuration(t.probeMin, t.probeMax)); err != nil {
+				return err
+			}
+		}
+	}
+	return wait(t.announceWait)
+}
+
+// watchARP reads ARP packets until one reveals a conflict for ip, which is
+// returned, or ctx is canceled.
+func (c *Client) watchARP(ctx context.Context, ip net.IP) error {
+	buf := make([]byte, 128)
+	for {
+		n, _, err := c.arpConn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		p, err := parseARPPacket(buf[:n])
+		if err != nil || bytes.Equal(p.senderHWAddr, c.ifaceHWAddr) {
+			continue
+		}
+		// RFC 5227, Section 2.1.1: any ARP packet with ip as sender
+		// address, or a probe for ip from another host, is a conflict.
+		if p.senderIP.Equal(ip) || (p.op == arpOpRequest && p.senderIP.IsUnspecified() && p.targetIP.Equal(ip)) {
+			return &ErrAddressConflict{IP: ip, HardwareAddr: p.senderHWAddr}
+		}
+	}
+}
+
+// Announce sends gratuitous ARP announcements for ip, as described in RFC
+// 5227, Section 2.3, so that other hosts update stale ARP cache entries.
+func (c *Client) Announce(ctx context.Context, ip net.IP) error {
+	if c.arpConn == nil {
+		return ErrNoARPConn
+	}
+	for i := 0; i < c.acdTimings.announceNum; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(c.acdTimings.announceInterval):
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.done:
+				return net.ErrClosed
+			}
+		}
+		if err := c.sendARP(newARPAnnouncement(c.ifaceHWAddr, ip)); err != nil {
+			return fmt.Errorf("unable to send ARP announcement: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkLease probes the address of a new lease. If the address is in use, it
+// declines the lease and waits as required before the configuration can be
+// restarted, and returns true. conflicts is the number of conflicts seen so
+// far.
+func (c *Client) checkLease(ctx context.Context, lease *Lease, conflicts int) (restart bool, err error) {
+	ip := lease.ACK.YourIPAddr
+	err = c.ProbeAddress(ctx, ip)
+	var conflict *ErrAddressConflict
+	if err == nil {
+		if c.isClosed() {
+			return false, nil
+		}
+		// Announce in the background, Request has waited long enough.
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if err := c.Announce(context.Background(), ip); err != nil {
+				c.logger.Printf("unable to announce %s: %v", ip, err)
+			}
+		}()
+		return false, nil
+	}
+	if !errors.As(err, &conflict) {
+		return false, fmt.Errorf("unable to probe %s: %w", ip, err)
+	}
+
+	c.logger.Printf("%v, declining lease", conflict)
+	if err := c.Decline(lease, dhcpv4.WithOption(dhcpv4.OptMessage(conflict.Error()))); err != nil {
+		return false, err
+	}
+	// RFC 5227, Section 2.1.1: after MAX_CONFLICTS conflicts, try at
+	// most one new address per RATE_LIMIT_INTERVAL.
+	wait := c.acdTimings.declineWait
+	if conflicts+1 >= c.acdTimings.maxConflicts {
+		wait = c.acdTimings.rateLimitInterval
+	}
+	select {
+	case <-time.After(wait):
+		return true, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	case <-c.done:
+		return false, net.ErrClosed
+	}
+}
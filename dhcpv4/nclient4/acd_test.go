@@ -0,0 +1,262 @@
+package nclient4
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/server4"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	acdClientMAC = net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}
+	acdOtherMAC  = net.HardwareAddr{0xa, 0xb, 0xc, 0x1, 0x2, 0x3}
+)
+
+var testACDTimings = acdTimings{
+	probeWait:        10 * time.Millisecond,
+	probeNum:         3,
+	probeMin:         10 * time.Millisecond,
+	probeMax:         20 * time.Millisecond,
+	announceWait:     50 * time.Millisecond,
+	announceNum:      2,
+	announceInterval: 10 * time.Millisecond,
+	maxConflicts:     10,
+	declineWait:      10 * time.Millisecond,
+}
+
+// newACDClient returns a client with conflict detection, and the other end of
+// its ARP connection.
+func newACDClient(t *testing.T, conn net.PacketConn) (*Client, net.PacketConn) {
+	clientARP, network, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	t.Cleanup(func() { network.Close() })
+	if conn == nil {
+		conn, _, err = socketpair.PacketSocketPair()
+		require.NoError(t, err)
+	}
+	c, err := NewWithConn(conn, acdClientMAC, WithARPConn(clientARP), WithRetry(1), WithTimeout(time.Second))
+	require.NoError(t, err)
+	c.acdTimings = testACDTimings
+	t.Cleanup(func() { c.Close() })
+	return c, network
+}
+
+// readARP reads ARP packets from conn until one matches, and returns it.
+func readARP(t *testing.T, conn net.PacketConn, match func(*arpPacket) bool) *arpPacket {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 128)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		p, err := parseARPPacket(buf[:n])
+		require.NoError(t, err)
+		if match(p) {
+			return p
+		}
+	}
+}
+
+func isProbe(ip net.IP) func(*arpPacket) bool {
+	return func(p *arpPacket) bool {
+		return p.op == arpOpRequest && p.senderIP.IsUnspecified() && p.targetIP.Equal(ip)
+	}
+}
+
+func TestARPPacket(t *testing.T) {
+	ip := net.IPv4(192, 168, 0, 10)
+	p := newARPProbe(acdClientMAC, ip)
+	b := p.ToBytes()
+	require.Equal(t, []byte{
+		0, 1, 8, 0, 6, 4, 0, 1,
+		0xa, 0xb, 0xc, 0xd, 0xe, 0xf, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 192, 168, 0, 10,
+	}, b)
+
+	got, err := parseARPPacket(b)
+	require.NoError(t, err)
+	require.Equal(t, uint16(arpOpRequest), got.op)
+	require.Equal(t, acdClientMAC, got.senderHWAddr)
+	require.True(t, got.senderIP.IsUnspecified())
+	require.True(t, got.targetIP.Equal(ip))
+
+	a := newARPAnnouncement(acdClientMAC, ip)
+	got, err = parseARPPacket(a.ToBytes())
+	require.NoError(t, err)
+	require.True(t, got.senderIP.Equal(ip))
+	require.True(t, got.targetIP.Equal(ip))
+
+	_, err = parseARPPacket(b[:20])
+	require.Error(t, err)
+	_, err = parseARPPacket([]byte{0, 1, 0x86, 0xdd, 6, 16})
+	require.Error(t, err)
+}
+
+func TestProbeAddress(t *testing.T) {
+	c, network := newACDClient(t, nil)
+	ip := net.IPv4(192, 168, 0, 10)
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.ProbeAddress(context.Background(), ip) }()
+	for i := 0; i < testACDTimings.probeNum; i++ {
+		p := readARP(t, network, isProbe(ip))
+		require.Equal(t, acdClientMAC, p.senderHWAddr)
+	}
+	// ARP traffic for other addresses is not a conflict.
+	_, err := network.WriteTo(newARPAnnouncement(acdOtherMAC, net.IPv4(192, 168, 0, 11)).ToBytes(), nil)
+	require.NoError(t, err)
+	require.NoError(t, <-errc)
+}
+
+func TestProbeAddressConflict(t *testing.T) {
+	ip := net.IPv4(192, 168, 0, 10)
+	for _, tt := range []struct {
+		name  string
+		reply *arpPacket
+	}{
+		{
+			name: "reply",
+			reply: &arpPacket{
+				op:           arpOpReply,
+				senderHWAddr: acdOtherMAC,
+				senderIP:     ip,
+				targetHWAddr: acdClientMAC,
+				targetIP:     net.IPv4zero,
+			},
+		},
+		{
+			name:  "simultaneous probe",
+			reply: newARPProbe(acdOtherMAC, ip),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, network := newACDClient(t, nil)
+			errc := make(chan error, 1)
+			go func() { errc <- c.ProbeAddress(context.Background(), ip) }()
+			readARP(t, network, isProbe(ip))
+			_, err := network.WriteTo(tt.reply.ToBytes(), nil)
+			require.NoError(t, err)
+
+			err = <-errc
+			var conflict *ErrAddressConflict
+			require.True(t, errors.As(err, &conflict), err)
+			require.True(t, conflict.IP.Equal(ip))
+			require.Equal(t, acdOtherMAC, conflict.HardwareAddr)
+		})
+	}
+}
+
+func TestProbeAddressNoARPConn(t *testing.T) {
+	conn, _, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	c, err := NewWithConn(conn, acdClientMAC)
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, ErrNoARPConn, c.ProbeAddress(context.Background(), net.IPv4(192, 168, 0, 10)))
+	require.Equal(t, ErrNoARPConn, c.Announce(context.Background(), net.IPv4(192, 168, 0, 10)))
+}
+
+func TestAnnounce(t *testing.T) {
+	c, network := newACDClient(t, nil)
+	ip := net.IPv4(192, 168, 0, 10)
+	require.NoError(t, c.Announce(context.Background(), ip))
+	for i := 0; i < testACDTimings.announceNum; i++ {
+		p := readARP(t, network, func(*arpPacket) bool { return true })
+		require.Equal(t, uint16(arpOpRequest), p.op)
+		require.True(t, p.senderIP.Equal(ip))
+		require.True(t, p.targetIP.Equal(ip))
+	}
+}
+
+// declineServer offers addresses from a list, moving to the next one when
+// the client declines.
+type declineServer struct {
+	mu       sync.Mutex
+	addrs    []net.IP
+	declines []*dhcpv4.DHCPv4
+}
+
+func (s *declineServer) handle(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reply, err := dhcpv4.NewReplyFromRequest(m,
+		dhcpv4.WithServerIP(net.IPv4(1, 2, 3, 4)),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(net.IPv4(1, 2, 3, 4))),
+		dhcpv4.WithYourIP(s.addrs[0]),
+	)
+	if err != nil {
+		return
+	}
+	switch m.MessageType() {
+	case dhcpv4.MessageTypeDiscover:
+		reply.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer))
+	case dhcpv4.MessageTypeRequest:
+		reply.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeAck))
+	case dhcpv4.MessageTypeDecline:
+		s.declines = append(s.declines, m)
+		s.addrs = s.addrs[1:]
+		return
+	default:
+		return
+	}
+	_, _ = conn.WriteTo(reply.ToBytes(), peer)
+}
+
+func TestRequestDeclinesConflict(t *testing.T) {
+	inUse := net.IPv4(192, 168, 0, 10).To4()
+	free := net.IPv4(192, 168, 0, 11).To4()
+
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	clientConn := NewBroadcastUDPConn(clientRawConn, &net.UDPAddr{Port: ClientPort})
+	serverConn := NewBroadcastUDPConn(serverRawConn, &net.UDPAddr{Port: ServerPort})
+	srv := &declineServer{addrs: []net.IP{inUse, free}}
+	s, err := server4.NewServer("", nil, srv.handle, server4.WithConn(serverConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, network := newACDClient(t, clientConn)
+
+	// Play the host owning inUse.
+	go func() {
+		buf := make([]byte, 128)
+		for {
+			n, _, err := network.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			p, err := parseARPPacket(buf[:n])
+			if err != nil || !isProbe(inUse)(p) {
+				continue
+			}
+			reply := &arpPacket{
+				op:           arpOpReply,
+				senderHWAddr: acdOtherMAC,
+				senderIP:     inUse,
+				targetHWAddr: p.senderHWAddr,
+				targetIP:     net.IPv4zero,
+			}
+			_, _ = network.WriteTo(reply.ToBytes(), nil)
+		}
+	}()
+
+	lease, err := c.Request(context.Background())
+	require.NoError(t, err)
+	require.True(t, lease.ACK.YourIPAddr.Equal(free))
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	require.Len(t, srv.declines, 1)
+	decline := srv.declines[0]
+	require.True(t, decline.RequestedIPAddress().Equal(inUse))
+	require.True(t, decline.ServerIdentifier().Equal(net.IPv4(1, 2, 3, 4)))
+	require.True(t, decline.ClientIPAddr.IsUnspecified())
+	require.Equal(t, acdClientMAC, decline.ClientHWAddr)
+}
@@ -0,0 +1,248 @@
+package nclient4
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// StartTLS secures the leasequery connection conn with TLS, as described in
+// RFC 7724, Section 6.3, and returns the secured connection. The server may
+// refuse, with an ErrLeasequeryStatus error: conn may then be used as is.
+func StartTLS(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	query, err := dhcpv4.NewLeasequeryTLS()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if err := dhcpv4.WriteTCPMessage(conn, query); err != nil {
+		return nil, err
+	}
+	for {
+		m, err := dhcpv4.ReadTCPMessage(conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if m.OpCode != dhcpv4.OpcodeBootReply || m.TransactionID != query.TransactionID {
+			continue
+		}
+		if m.MessageType() != dhcpv4.MessageTypeTLS {
+			return nil, fmt.Errorf("unexpected reply to DHCPTLS: %s", m.MessageType())
+		}
+		if s := m.Status(); s != nil && s.Code != dhcpv4.StatusSuccess {
+			return nil, &ErrLeasequeryStatus{Status: s}
+		}
+		break
+	}
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// ActiveRequestor maintains a copy of the bindings of a DHCP server, updated
+// with active leasequery, as described in RFC 7724.
+//
+// A requestor without bindings may first fill them with a bulk leasequery:
+// BulkLeasequery(ctx, conn, query, r.Update). Run then only needs the
+// changes since.
+type ActiveRequestor struct {
+	// OnUpdate, if not nil, is called with each update, after it is
+	// applied to the bindings.
+	OnUpdate func(*dhcpv4.DHCPv4)
+
+	mu       sync.Mutex
+	bindings map[string]activeBinding
+	last     time.Time
+	synced   bool
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// activeBinding is an active binding, as last received.
+type activeBinding struct {
+	m      *dhcpv4.DHCPv4
+	expiry time.Time
+}
+
+// NewActiveRequestor returns an ActiveRequestor without bindings.
+func NewActiveRequestor() *ActiveRequestor {
+	return &ActiveRequestor{bindings: make(map[string]activeBinding)}
+}
+
+func (r *ActiveRequestor) time() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// Update applies a DHCPLEASEACTIVE, DHCPLEASEUNASSIGNED or DHCPLEASEUNKNOWN
+// message to the bindings.
+func (r *ActiveRequestor) Update(m *dhcpv4.DHCPv4) error {
+	key := string(m.ClientIPAddr.To4())
+	r.mu.Lock()
+	switch m.MessageType() {
+	case dhcpv4.MessageTypeLeaseActive:
+		r.bindings[key] = activeBinding{m: m, expiry: r.time().Add(m.IPAddressLeaseTime(0))}
+	case dhcpv4.MessageTypeLeaseUnassigned, dhcpv4.MessageTypeLeaseUnknown:
+		delete(r.bindings, key)
+	default:
+		r.mu.Unlock()
+		return fmt.Errorf("not a leasequery reply: %s", m.MessageType())
+	}
+	if base, ok := m.BaseTime(); ok && base.After(r.last) {
+		r.last = base
+	}
+	r.mu.Unlock()
+	if r.OnUpdate != nil {
+		r.OnUpdate(m)
+	}
+	return nil
+}
+
+// Reset removes all the bindings, and forgets the last update.
+func (r *ActiveRequestor) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings = make(map[string]activeBinding)
+	r.last = time.Time{}
+	r.synced = false
+}
+
+// Bindings returns the last DHCPLEASEACTIVE messages of the bindings that
+// have not expired, ordered by address.
+func (r *ActiveRequestor) Bindings() []*dhcpv4.DHCPv4 {
+	now := r.time()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*dhcpv4.DHCPv4
+	for _, b := range r.bindings {
+		if now.Before(b.expiry) {
+			out = append(out, b.m)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return bytes.Compare(out[i].ClientIPAddr.To4(), out[j].ClientIPAddr.To4()) < 0
+	})
+	return out
+}
+
+// Get returns the last DHCPLEASEACTIVE message of the binding of ip, or nil
+// if ip is not bound.
+func (r *ActiveRequestor) Get(ip net.IP) *dhcpv4.DHCPv4 {
+	now := r.time()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.bindings[string(ip.To4())]; ok && now.Before(b.expiry) {
+		return b.m
+	}
+	return nil
+}
+
+// LastUpdate returns the server time of the last update, or the zero time if
+// there was none.
+func (r *ActiveRequestor) LastUpdate() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Synced returns whether the bindings are up to date: the server sent the
+// changes missed since the last update, and now sends them as they happen.
+func (r *ActiveRequestor) Synced() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.synced
+}
+
+// Run sends a DHCPACTIVELEASEQUERY message over conn, a TCP connection to
+// port 67 of a server, and applies the updates it receives, until ctx is
+// done, the connection fails or the server ends the query. conn must then
+// be closed.
+//
+// If there was a previous update, the server is first asked about the
+// changes since. If it cannot provide them, Run returns an
+// ErrLeasequeryStatus error with the DataMissing status: the bindings must
+// then be reset with Reset, and reloaded with a bulk leasequery.
+func (r *ActiveRequestor) Run(ctx context.Context, conn net.Conn, modifiers ...dhcpv4.Modifier) error {
+	last := r.LastUpdate()
+	r.mu.Lock()
+	r.synced = last.IsZero()
+	r.mu.Unlock()
+	if !last.IsZero() {
+		modifiers = dhcpv4.PrependModifiers(modifiers, dhcpv4.WithOption(dhcpv4.OptQueryStartTime(last)))
+	}
+	query, err := dhcpv4.NewActiveLeasequery(modifiers...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	err = r.run(conn, query)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (r *ActiveRequestor) run(conn net.Conn, query *dhcpv4.DHCPv4) error {
+	if err := dhcpv4.WriteTCPMessage(conn, query); err != nil {
+		return err
+	}
+	for {
+		m, err := dhcpv4.ReadTCPMessage(conn)
+		if err != nil {
+			return err
+		}
+		if m.OpCode != dhcpv4.OpcodeBootReply || m.TransactionID != query.TransactionID {
+			continue
+		}
+		switch m.MessageType() {
+		case dhcpv4.MessageTypeLeaseQueryStatus:
+			s := m.Status()
+			if s == nil || s.Code != dhcpv4.StatusCatchUpComplete {
+				if s == nil {
+					s = &dhcpv4.Status{Code: dhcpv4.StatusSuccess}
+				}
+				return &ErrLeasequeryStatus{Status: s}
+			}
+			r.mu.Lock()
+			r.synced = true
+			r.mu.Unlock()
+		case dhcpv4.MessageTypeLeaseActive, dhcpv4.MessageTypeLeaseUnassigned, dhcpv4.MessageTypeLeaseUnknown:
+			if err := r.Update(m); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected active leasequery reply: %s", m.MessageType())
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package nclient4
+
+import (
+	"errors"
+	"net"
+
+	"github.com/u-root/uio/uio"
+)
+
+// ARP constants for IPv4 over Ethernet, RFC 826.
+const (
+	arpHardwareEthernet = 1
+	arpProtocolIPv4     = 0x0800
+
+	arpOpRequest = 1
+	arpOpReply   = 2
+)
+
+var errInvalidARPPacket = errors.New("invalid ARP packet")
+
+// arpPacket is an ARP packet for IPv4 addresses.
+type arpPacket struct {
+	op           uint16
+	senderHWAddr net.HardwareAddr
+	senderIP     net.IP
+	targetHWAddr net.HardwareAddr
+	targetIP     net.IP
+}
+
+// newARPProbe returns an ARP probe for ip, as described in RFC 5227, Section
+// 2.1.1: a request with an all-zero sender IP address.
+func newARPProbe(hwaddr net.HardwareAddr, ip net.IP) *arpPacket {
+	return &arpPacket{
+		op:           arpOpRequest,
+		senderHWAddr: hwaddr,
+		senderIP:     net.IPv4zero,
+		targetHWAddr: make(net.HardwareAddr, len(hwaddr)),
+		targetIP:     ip,
+	}
+}
+
+// newARPAnnouncement returns an ARP announcement for ip, as described in RFC
+// 5227, Section 2.3: a request with both sender and target IP set to ip.
+func newARPAnnouncement(hwaddr net.HardwareAddr, ip net.IP) *arpPacket {
+	return &arpPacket{
+		op:           arpOpRequest,
+		senderHWAddr: hwaddr,
+		senderIP:     ip,
+		targetHWAddr: make(net.HardwareAddr, len(hwaddr)),
+		targetIP:     ip,
+	}
+}
+
+// ToBytes returns the wire format of p, without the link layer header.
+func (p *arpPacket) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(arpHardwareEthernet)
+	buf.Write16(arpProtocolIPv4)
+	buf.Write8(uint8(len(p.senderHWAddr)))
+	buf.Write8(net.IPv4len)
+	buf.Write16(p.op)
+	buf.WriteBytes(p.senderHWAddr)
+	buf.WriteBytes(p.senderIP.To4())
+	buf.WriteBytes(p.targetHWAddr)
+	buf.WriteBytes(p.targetIP.To4())
+	return buf.Data()
+}
+
+// parseARPPacket parses an ARP packet for IPv4 addresses.
+func parseARPPacket(b []byte) (*arpPacket, error) {
+	buf := uio.NewBigEndianBuffer(b)
+	// The hardware type is not checked, only the address length
+	// matters.
+	buf.Read16()
+	ptype := buf.Read16()
+	hlen := int(buf.Read8())
+	plen := int(buf.Read8())
+	if buf.Error() != nil || ptype != arpProtocolIPv4 || plen != net.IPv4len {
+		return nil, errInvalidARPPacket
+	}
+	p := &arpPacket{op: buf.Read16()}
+	p.senderHWAddr = net.HardwareAddr(buf.CopyN(hlen))
+	p.senderIP = net.IP(buf.CopyN(plen))
+	p.targetHWAddr = net.HardwareAddr(buf.CopyN(hlen))
+	p.targetIP = net.IP(buf.CopyN(plen))
+	if buf.Error() != nil {
+		return nil, errInvalidARPPacket
+	}
+	return p, nil
+}
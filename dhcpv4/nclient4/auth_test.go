@@ -0,0 +1,73 @@
+package nclient4
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/server4"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthServer serves leases of an allocator, authenticated with a.
+func newAuthServer(t *testing.T, a *dhcpv4.DelayedAuth) net.PacketConn {
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	clientConn := NewBroadcastUDPConn(clientRawConn, &net.UDPAddr{Port: ClientPort})
+	serverConn := NewBroadcastUDPConn(serverRawConn, &net.UDPAddr{Port: ServerPort})
+
+	alloc, err := server4.NewAllocator(net.IPv4(192, 168, 0, 10), net.IPv4(192, 168, 0, 20), nil)
+	require.NoError(t, err)
+	auth := server4.NewAuth(a)
+	h := auth.RawHandler(alloc.Handler(net.IPv4(192, 168, 0, 1)))
+	s, err := server4.NewServer("", nil, nil, server4.WithConn(serverConn), server4.WithRawHandler(h))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	t.Cleanup(func() { s.Close() })
+	return clientConn
+}
+
+func TestDelayedAuth(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	conn := newAuthServer(t, dhcpv4.NewDelayedAuth(7, secret))
+	client, err := NewWithConn(conn, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		WithRetry(1), WithTimeout(time.Second), WithDelayedAuth(dhcpv4.NewDelayedAuth(7, secret)))
+	require.NoError(t, err)
+	defer client.Close()
+
+	// The server drops the REQUEST unless it is signed.
+	lease, err := client.Request(context.Background())
+	require.NoError(t, err)
+	require.NotNil(t, lease.Offer.Authentication())
+	require.NotNil(t, lease.ACK.Authentication())
+	require.Equal(t, net.IPv4(192, 168, 0, 10).To4(), lease.ACK.YourIPAddr.To4())
+}
+
+func TestDelayedAuthForgedReply(t *testing.T) {
+	conn := newAuthServer(t, dhcpv4.NewDelayedAuth(7, []byte("forged")))
+	client, err := NewWithConn(conn, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		WithRetry(1), WithTimeout(100*time.Millisecond), WithDelayedAuth(dhcpv4.NewDelayedAuth(7, []byte("0123456789abcdef"))))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.DiscoverOffer(context.Background())
+	require.True(t, errors.Is(err, ErrNoResponse), err)
+}
+
+func TestDelayedAuthUnsignedReply(t *testing.T) {
+	offer := newPacket(dhcpv4.OpcodeBootReply, [4]byte{0x33, 0x33, 0x33, 0x33})
+	offer.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeOffer))
+	client, _ := serveAndClient(context.Background(), [][]*dhcpv4.DHCPv4{{offer}},
+		WithTimeout(100*time.Millisecond), WithDelayedAuth(dhcpv4.NewDelayedAuth(7, []byte("0123456789abcdef"))))
+	defer client.Close()
+
+	discover, err := dhcpv4.NewDiscovery(client.ifaceHWAddr, dhcpv4.WithTransactionID(offer.TransactionID))
+	require.NoError(t, err)
+	_, err = client.SendAndRead(context.Background(), DefaultServers, discover, nil)
+	require.True(t, errors.Is(err, ErrNoResponse), err)
+	require.NotNil(t, discover.Authentication())
+}
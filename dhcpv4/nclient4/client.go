@@ -148,6 +148,11 @@ type Client struct {
 	// This may be an actual broadcast address, or a unicast address.
 	serverAddr *net.UDPAddr
 
+	// acd enables address conflict detection on arpConn.
+	acd        bool
+	arpConn    net.PacketConn
+	acdTimings acdTimings
+
 	// closed is an atomic bool set to 1 when done is closed.
 	closed uint32
 
@@ -157,6 +162,14 @@ type Client struct {
 	// wg protects any spawned goroutines, namely the receiveLoop.
 	wg sync.WaitGroup
 
+	// forceRenew receives the FORCERENEW messages, as received on the
+	// wire. They are dropped when it is full.
+	forceRenew chan []byte
+
+	// auth, if not nil, authenticates the messages of the client and the
+	// replies of servers.
+	auth *dhcpv4.DelayedAuth
+
 	pendingMu sync.Mutex
 	// pending stores the distribution channels for each pending
 	// TransactionID. receiveLoop uses this map to determine which channel
@@ -184,9 +197,11 @@ func new(iface string, conn net.PacketConn, ifaceHWAddr net.HardwareAddr, opts .
 		bufferCap:   defaultBufferCap,
 		conn:        conn,
 		logger:      EmptyLogger{},
+		acdTimings:  defaultACDTimings,
 
-		done:    make(chan struct{}),
-		pending: make(map[dhcpv4.TransactionID]*pendingCh),
+		done:       make(chan struct{}),
+		pending:    make(map[dhcpv4.TransactionID]*pendingCh),
+		forceRenew: make(chan []byte, defaultBufferCap),
 	}
 
 	for _, opt := range opts {
@@ -219,6 +234,19 @@ func new(iface string, conn net.PacketConn, ifaceHWAddr net.HardwareAddr, opts .
 			return nil, fmt.Errorf("unable to open a broadcasting socket: %w", err)
 		}
 	}
+
+	if c.acd && c.arpConn == nil {
+		if iface == `` {
+			c.conn.Close()
+			return nil, ErrNoARPConn
+		}
+		var err error
+		c.arpConn, err = NewRawARPConn(iface)
+		if err != nil {
+			c.conn.Close()
+			return nil, fmt.Errorf("unable to open an ARP socket: %w", err)
+		}
+	}
 	c.wg.Add(1)
 	go c.receiveLoop()
 	return c, nil
@@ -232,6 +260,11 @@ func (c *Client) Close() error {
 	}
 
 	err := c.conn.Close()
+	if c.arpConn != nil {
+		if aerr := c.arpConn.Close(); err == nil {
+			err = aerr
+		}
+	}
 
 	// Closing c.done sets off a chain reaction:
 	//
@@ -281,11 +314,33 @@ func (c *Client) receiveLoop() {
 		// This is a somewhat non-standard check, by the looks
 		// of RFC 2131. It should work as long as the DHCP
 		// server is spec-compliant for the HWAddr field.
-		if c.ifaceHWAddr != nil && !bytes.Equal(c.ifaceHWAddr, msg.ClientHWAddr) {
+		//
+		// Leasequery replies are about other clients, they are only
+		// matched by transaction ID.
+		if c.ifaceHWAddr != nil && !bytes.Equal(c.ifaceHWAddr, msg.ClientHWAddr) && !isLeasequeryReply(msg) {
 			// Not for us.
 			continue
 		}
 
+		if c.auth != nil && isAuthenticatedReply(msg) {
+			// RFC 3118, Section 5.3: the HMAC covers the message as
+			// sent by the server.
+			if _, err := c.auth.VerifyBytes(b[:n], msg.ServerIdentifier().String()); err != nil {
+				c.logger.Printf("dropping %s from %s: %v", msg.MessageType(), msg.ServerIdentifier(), err)
+				continue
+			}
+		}
+
+		if msg.MessageType() == dhcpv4.MessageTypeForceRenew {
+			// FORCERENEW messages are not replies to the client
+			// messages, they are matched by WaitForceRenew.
+			select {
+			case c.forceRenew <- b[:n]:
+			default:
+			}
+			continue
+		}
+
 		c.pendingMu.Lock()
 		p, ok := c.pending[msg.TransactionID]
 		if ok {
@@ -302,6 +357,33 @@ func (c *Client) receiveLoop() {
 	}
 }
 
+// isAuthenticatedReply returns whether m is a reply that servers sign with
+// delayed authentication.
+func isAuthenticatedReply(m *dhcpv4.DHCPv4) bool {
+	switch m.MessageType() {
+	case dhcpv4.MessageTypeOffer, dhcpv4.MessageTypeAck, dhcpv4.MessageTypeNak:
+		return true
+	}
+	return false
+}
+
+// authenticate adds the delayed Authentication option to msg, if the client
+// uses delayed authentication: DISCOVER messages request authentication, and
+// the other messages are signed. It must be the last change made to msg.
+func (c *Client) authenticate(msg *dhcpv4.DHCPv4) error {
+	if c.auth == nil {
+		return nil
+	}
+	switch msg.MessageType() {
+	case dhcpv4.MessageTypeDiscover:
+		dhcpv4.WithDelayedAuthRequest(c.auth)(msg)
+	case dhcpv4.MessageTypeRequest, dhcpv4.MessageTypeDecline,
+		dhcpv4.MessageTypeRelease, dhcpv4.MessageTypeInform:
+		return c.auth.Sign(msg, c.auth.KeyID)
+	}
+	return nil
+}
+
 // ClientOpt is a function that configures the Client.
 type ClientOpt func(c *Client) error
 
@@ -388,6 +470,18 @@ func WithRetry(r int) ClientOpt {
 	}
 }
 
+// WithDelayedAuth configures the client to use the delayed authentication
+// protocol of RFC 3118, Section 5, with a. DISCOVER messages request
+// authentication, the other messages are signed with the KeyID secret of a
+// after all modifiers are applied, and OFFER, ACK and NAK messages that fail
+// verification are dropped.
+func WithDelayedAuth(a *dhcpv4.DelayedAuth) ClientOpt {
+	return func(c *Client) error {
+		c.auth = a
+		return nil
+	}
+}
+
 // WithServerAddr configures the address to send messages to.
 func WithServerAddr(n *net.UDPAddr) ClientOpt {
 	return func(c *Client) (err error) {
@@ -469,13 +563,28 @@ func (c *Client) DiscoverOffer(ctx context.Context, modifiers ...dhcpv4.Modifier
 // Request completes the 4-way Discover-Offer-Request-Ack handshake.
 //
 // Note that modifiers will be applied *both* to Discover and Request packets.
+//
+// If conflict detection is enabled, the leased address is probed before it is
+// returned, and leases of addresses in use are declined and requested again.
+// See WithConflictDetection.
 func (c *Client) Request(ctx context.Context, modifiers ...dhcpv4.Modifier) (lease *Lease, err error) {
-	offer, err := c.DiscoverOffer(ctx, modifiers...)
-	if err != nil {
-		err = fmt.Errorf("unable to receive an offer: %w", err)
-		return
+	for conflicts := 0; ; conflicts++ {
+		offer, err := c.DiscoverOffer(ctx, modifiers...)
+		if err != nil {
+			return nil, fmt.Errorf("unable to receive an offer: %w", err)
+		}
+		lease, err = c.RequestFromOffer(ctx, offer, modifiers...)
+		if err != nil || !c.acd {
+			return lease, err
+		}
+		restart, err := c.checkLease(ctx, lease, conflicts)
+		if err != nil {
+			return nil, err
+		}
+		if !restart {
+			return lease, nil
+		}
 	}
-	return c.RequestFromOffer(ctx, offer, modifiers...)
 }
 
 // Inform sends an INFORM request using the given local IP.
@@ -563,6 +672,12 @@ func (err *ErrTransactionIDInUse) Error() string {
 // The returned lambda function must be called after all desired responses have
 // been received in order to return the Transaction ID to the usable pool.
 func (c *Client) send(dest *net.UDPAddr, msg *dhcpv4.DHCPv4) (resp <-chan *dhcpv4.DHCPv4, cancel func(), err error) {
+	// Retransmissions are signed again, with a new replay detection
+	// value.
+	if err := c.authenticate(msg); err != nil {
+		return nil, nil, fmt.Errorf("unable to authenticate message: %w", err)
+	}
+
 	c.pendingMu.Lock()
 	if _, ok := c.pending[msg.TransactionID]; ok {
 		c.pendingMu.Unlock()
@@ -46,6 +46,19 @@ func NewRawUDPConn(iface string, port int) (net.PacketConn, error) {
 	return NewBroadcastUDPConn(rawConn, &net.UDPAddr{Port: port}), nil
 }
 
+// NewRawARPConn returns a packet socket bound to the interface given, that
+// reads and writes ARP packets without their link layer header. Packets must
+// be written to a *packet.Addr.
+//
+// It is used for address conflict detection, see WithConflictDetection.
+func NewRawARPConn(iface string) (net.PacketConn, error) {
+	ifc, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	return packet.Listen(ifc, packet.Datagram, unix.ETH_P_ARP, nil)
+}
+
 // BroadcastRawUDPConn uses a raw socket to send UDP packets to the broadcast
 // MAC address.
 type BroadcastRawUDPConn struct {
@@ -0,0 +1,90 @@
+package nclient4
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// DHCP4o6Conn is a net.PacketConn that carries DHCPv4 messages over DHCPv6,
+// as described in RFC 7341, for use with NewWithConn on IPv6-only networks.
+//
+// The DHCPv4 messages written to it are sent in DHCPv4-QUERY messages to the
+// DHCP 4o6 servers, and the DHCPv4 messages read from it are the ones
+// received in DHCPv4-RESPONSE messages.
+type DHCP4o6Conn struct {
+	// PacketConn is a UDPv6 connection on the DHCPv6 client port 546.
+	net.PacketConn
+
+	servers []*net.UDPAddr
+}
+
+// NewDHCP4o6Conn returns a DHCP4o6Conn that sends queries over conn, on the
+// network interface iface, to the given DHCP 4o6 servers, as learned from the
+// DHCP 4o6 Server Address option (see nclient6.Client.DHCP4oDHCP6Servers).
+// Without servers, queries are sent to All_DHCP_Relay_Agents_and_Servers,
+// RFC 7341, Section 7.
+//
+// iface is the zone of the link-local addresses, such as
+// All_DHCP_Relay_Agents_and_Servers.
+func NewDHCP4o6Conn(conn net.PacketConn, iface string, servers ...net.IP) *DHCP4o6Conn {
+	c := &DHCP4o6Conn{PacketConn: conn}
+	if len(servers) == 0 {
+		servers = []net.IP{dhcpv6.AllDHCPRelayAgentsAndServers}
+	}
+	for _, s := range servers {
+		addr := &net.UDPAddr{IP: s, Port: dhcpv6.DefaultServerPort}
+		if s.IsLinkLocalMulticast() || s.IsLinkLocalUnicast() {
+			addr.Zone = iface
+		}
+		c.servers = append(c.servers, addr)
+	}
+	return c
+}
+
+// WriteTo sends the DHCPv4 message b to the DHCP 4o6 servers. The message is
+// flagged as unicast if addr is not the IPv4 broadcast address.
+func (c *DHCP4o6Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	m, err := dhcpv4.FromBytes(b)
+	if err != nil {
+		return 0, fmt.Errorf("invalid DHCPv4 message: %w", err)
+	}
+	unicast := false
+	if ua, ok := addr.(*net.UDPAddr); ok {
+		unicast = !ua.IP.Equal(net.IPv4bcast)
+	}
+	q, err := dhcpv6.NewDHCPv4Query(m, unicast)
+	if err != nil {
+		return 0, err
+	}
+	pkt := q.ToBytes()
+	for _, s := range c.servers {
+		if _, err := c.PacketConn.WriteTo(pkt, s); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// ReadFrom reads the DHCPv4 message of the next DHCPv4-RESPONSE message into
+// b. addr is the IPv6 address of the server or relay agent the response was
+// received from. Other packets are discarded.
+func (c *DHCP4o6Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	// Room for the DHCPv6 header, and the DHCPv4 Message option header.
+	buf := make([]byte, len(b)+dhcpv6.MessageHeaderSize+4)
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		m, err := dhcpv6.MessageFromBytes(buf[:n])
+		if err != nil || m.MessageType != dhcpv6.MessageTypeDHCPv4Response {
+			continue
+		}
+		if m4 := m.Options.DHCPv4Msg(); m4 != nil {
+			return copy(b, m4.ToBytes()), addr, nil
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package nclient4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCP4o6Conn(t *testing.T) {
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	defer serverRawConn.Close()
+	c := NewDHCP4o6Conn(clientRawConn, "eth0")
+	defer c.Close()
+	require.Len(t, c.servers, 1)
+	require.True(t, dhcpv6.AllDHCPRelayAgentsAndServers.Equal(c.servers[0].IP))
+	require.Equal(t, "eth0", c.servers[0].Zone)
+
+	// Only link-local servers are scoped to the interface.
+	servers := NewDHCP4o6Conn(clientRawConn, "eth0", net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1")).servers
+	require.Equal(t, "", servers[0].Zone)
+	require.Equal(t, "eth0", servers[1].Zone)
+
+	m, err := dhcpv4.NewDiscovery(net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	require.NoError(t, err)
+	b := make([]byte, MaxMessageSize)
+	for _, unicast := range []bool{false, true} {
+		addr := DefaultServers
+		if unicast {
+			addr = &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: ServerPort}
+		}
+		_, err = c.WriteTo(m.ToBytes(), addr)
+		require.NoError(t, err)
+		n, _, err := serverRawConn.ReadFrom(b)
+		require.NoError(t, err)
+		q, err := dhcpv6.MessageFromBytes(b[:n])
+		require.NoError(t, err)
+		require.Equal(t, dhcpv6.MessageTypeDHCPv4Query, q.MessageType)
+		require.Equal(t, unicast, q.IsDHCPv4Unicast())
+		require.Equal(t, m.TransactionID, q.Options.DHCPv4Msg().TransactionID)
+	}
+	_, err = c.WriteTo([]byte{1, 2, 3}, DefaultServers)
+	require.Error(t, err)
+
+	// Other packets are discarded.
+	_, err = serverRawConn.WriteTo([]byte{1, 2, 3}, nil)
+	require.NoError(t, err)
+	q, err := dhcpv6.NewDHCPv4Query(m, false)
+	require.NoError(t, err)
+	offer, err := dhcpv4.NewReplyFromRequest(m, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer))
+	require.NoError(t, err)
+	resp, err := dhcpv6.NewDHCPv4Response(q, offer)
+	require.NoError(t, err)
+	_, err = serverRawConn.WriteTo(resp.ToBytes(), nil)
+	require.NoError(t, err)
+	n, _, err := c.ReadFrom(b)
+	require.NoError(t, err)
+	got, err := dhcpv4.FromBytes(b[:n])
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeOffer, got.MessageType())
+}
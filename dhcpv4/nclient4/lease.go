@@ -4,6 +4,7 @@ package nclient4
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"time"
@@ -31,6 +32,9 @@ func (c *Client) Release(lease *Lease, modifiers ...dhcpv4.Modifier) error {
 	if err != nil {
 		return fmt.Errorf("fail to create release request,%w", err)
 	}
+	if err := c.authenticate(req); err != nil {
+		return fmt.Errorf("unable to authenticate release request: %w", err)
+	}
 	_, err = c.conn.WriteTo(req.ToBytes(), &net.UDPAddr{IP: lease.ACK.Options.Get(dhcpv4.OptionServerIdentifier), Port: ServerPort})
 	if err == nil {
 		c.logger.PrintMessage("sent message:", req)
@@ -38,6 +42,27 @@ func (c *Client) Release(lease *Lease, modifiers ...dhcpv4.Modifier) error {
 	return err
 }
 
+// Decline sends a DHCPv4 decline message to the server of the specified lease,
+// telling it that the leased address is already in use, see RFC2131, section
+// 3.1. The decline is broadcast, since the client must not use the address.
+func (c *Client) Decline(lease *Lease, modifiers ...dhcpv4.Modifier) error {
+	if lease == nil {
+		return fmt.Errorf("lease is nil")
+	}
+	req, err := dhcpv4.NewDeclineFromACK(lease.ACK, modifiers...)
+	if err != nil {
+		return fmt.Errorf("fail to create decline request,%w", err)
+	}
+	if err := c.authenticate(req); err != nil {
+		return fmt.Errorf("unable to authenticate decline request: %w", err)
+	}
+	_, err = c.conn.WriteTo(req.ToBytes(), c.serverAddr)
+	if err == nil {
+		c.logger.PrintMessage("sent message:", req)
+	}
+	return err
+}
+
 // Renew sends a DHCPv4 request to the server to renew the given lease. The renewal information is
 // sourced from the initial offer in the lease, and the ACK of the lease is updated to the ACK of
 // the latest renewal. This avoids issues with DHCP servers that omit information needed to build a
@@ -77,3 +102,60 @@ func (c *Client) Renew(ctx context.Context, lease *Lease, modifiers ...dhcpv4.Mo
 		CreationTime: time.Now(),
 	}, nil
 }
+
+// ErrNoForcerenewNonce is returned by WaitForceRenew if the server did not
+// give a nonce to authenticate FORCERENEW messages in the ACK of the lease.
+var ErrNoForcerenewNonce = errors.New("lease has no forcerenew nonce")
+
+// WaitForceRenew waits for a FORCERENEW message from the server of the given
+// lease, as described in RFC 3203. Messages that are not authenticated with
+// the nonce of the lease, as described in RFC 6704, or that are replayed, are
+// discarded.
+//
+// Servers only give nonces to clients that ask for them, with
+// dhcpv4.WithForcerenewNonceCapable in their requests.
+func (c *Client) WaitForceRenew(ctx context.Context, lease *Lease) (*dhcpv4.DHCPv4, error) {
+	if lease == nil {
+		return nil, fmt.Errorf("lease is nil")
+	}
+	nonce := lease.ACK.ForcerenewNonce()
+	if nonce == nil {
+		return nil, ErrNoForcerenewNonce
+	}
+	// Replay detection values must increase from the ACK, RFC 6704,
+	// Section 3.2.3.
+	last := lease.ACK.Authentication().ReplayDetection
+	serverID := lease.ACK.ServerIdentifier()
+	for {
+		select {
+		case <-c.done:
+			return nil, ErrNoResponse
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case b := <-c.forceRenew:
+			m, err := dhcpv4.VerifyForceRenew(b, nonce)
+			if err != nil {
+				c.logger.Printf("discarding FORCERENEW: %v", err)
+				continue
+			}
+			if serverID != nil && !serverID.Equal(m.ServerIdentifier()) {
+				continue
+			}
+			if m.Authentication().ReplayDetection <= last {
+				c.logger.Printf("discarding replayed FORCERENEW")
+				continue
+			}
+			c.logger.PrintMessage("received message", m)
+			return m, nil
+		}
+	}
+}
+
+// ForceRenew waits for a valid FORCERENEW message from the server of the
+// given lease with WaitForceRenew, then renews the lease with Renew.
+func (c *Client) ForceRenew(ctx context.Context, lease *Lease, modifiers ...dhcpv4.Modifier) (*Lease, error) {
+	if _, err := c.WaitForceRenew(ctx, lease); err != nil {
+		return nil, err
+	}
+	return c.Renew(ctx, lease, modifiers...)
+}
@@ -0,0 +1,130 @@
+package nclient4
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+func isLeasequeryReply(m *dhcpv4.DHCPv4) bool {
+	switch m.MessageType() {
+	case dhcpv4.MessageTypeLeaseActive, dhcpv4.MessageTypeLeaseUnassigned, dhcpv4.MessageTypeLeaseUnknown:
+		return true
+	}
+	return false
+}
+
+// Leasequery sends the DHCPLEASEQUERY message query to the server at dest, as
+// described in RFC 4388, and returns its reply: DHCPLEASEACTIVE,
+// DHCPLEASEUNASSIGNED or DHCPLEASEUNKNOWN.
+//
+// Queries are built with dhcpv4.NewLeasequeryByIP,
+// dhcpv4.NewLeasequeryByHardwareAddr or dhcpv4.NewLeasequeryByClientID, and
+// must have the giaddr field set to an address of the requestor.
+func (c *Client) Leasequery(ctx context.Context, dest *net.UDPAddr, query *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, error) {
+	if query.MessageType() != dhcpv4.MessageTypeLeaseQuery {
+		return nil, fmt.Errorf("not a DHCPLEASEQUERY message: %s", query.MessageType())
+	}
+	return c.SendAndRead(ctx, dest, query, isLeasequeryReply)
+}
+
+// LeasequeryByIP asks the server at dest about the lease of ip.
+func (c *Client) LeasequeryByIP(ctx context.Context, dest *net.UDPAddr, ip net.IP, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	query, err := dhcpv4.NewLeasequeryByIP(ip, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Leasequery(ctx, dest, query)
+}
+
+// LeasequeryByHardwareAddr asks the server at dest about the leases of the
+// client with the given hardware address.
+func (c *Client) LeasequeryByHardwareAddr(ctx context.Context, dest *net.UDPAddr, hwaddr net.HardwareAddr, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	query, err := dhcpv4.NewLeasequeryByHardwareAddr(hwaddr, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Leasequery(ctx, dest, query)
+}
+
+// LeasequeryByClientID asks the server at dest about the leases of the client
+// with the given client identifier.
+func (c *Client) LeasequeryByClientID(ctx context.Context, dest *net.UDPAddr, clientID []byte, modifiers ...dhcpv4.Modifier) (*dhcpv4.DHCPv4, error) {
+	query, err := dhcpv4.NewLeasequeryByClientID(clientID, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Leasequery(ctx, dest, query)
+}
+
+// ErrLeasequeryStatus is returned by BulkLeasequery if the server ends its
+// replies with a DHCPLEASEQUERYDONE message carrying an error status, and by
+// StartTLS and ActiveRequestor.Run if the server refuses or ends the query.
+type ErrLeasequeryStatus struct {
+	Status *dhcpv4.Status
+}
+
+// Error implements error.Error.
+func (e *ErrLeasequeryStatus) Error() string {
+	return fmt.Sprintf("leasequery failed: %s", e.Status)
+}
+
+// BulkLeasequery sends the DHCPBULKLEASEQUERY message query over conn, a TCP
+// connection to port 67 of a server, as described in RFC 6926. It calls fn
+// with each reply, DHCPLEASEACTIVE or DHCPLEASEUNASSIGNED, until the server
+// sends DHCPLEASEQUERYDONE.
+//
+// Queries are built with dhcpv4.NewBulkLeasequery and its variants. Several
+// queries may be sent over the same connection, one after the other. If fn
+// returns an error or ctx is done, BulkLeasequery returns without reading the
+// other replies, and conn must be closed.
+func BulkLeasequery(ctx context.Context, conn net.Conn, query *dhcpv4.DHCPv4, fn func(*dhcpv4.DHCPv4) error) error {
+	if query.MessageType() != dhcpv4.MessageTypeBulkLeaseQuery {
+		return fmt.Errorf("not a DHCPBULKLEASEQUERY message: %s", query.MessageType())
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	err := bulkLeasequery(conn, query, fn)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func bulkLeasequery(conn net.Conn, query *dhcpv4.DHCPv4, fn func(*dhcpv4.DHCPv4) error) error {
+	if err := dhcpv4.WriteTCPMessage(conn, query); err != nil {
+		return err
+	}
+	for {
+		m, err := dhcpv4.ReadTCPMessage(conn)
+		if err != nil {
+			return err
+		}
+		if m.OpCode != dhcpv4.OpcodeBootReply || m.TransactionID != query.TransactionID {
+			continue
+		}
+		switch m.MessageType() {
+		case dhcpv4.MessageTypeLeaseQueryDone:
+			if s := m.Status(); s != nil && s.Code != dhcpv4.StatusSuccess {
+				return &ErrLeasequeryStatus{Status: s}
+			}
+			return nil
+		case dhcpv4.MessageTypeLeaseActive, dhcpv4.MessageTypeLeaseUnassigned, dhcpv4.MessageTypeLeaseUnknown:
+			if err := fn(m); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected bulk leasequery reply: %s", m.MessageType())
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/uio/uio"
+)
+
+// SixRD is the value of the 6rd option, the configuration of an IPv6 Rapid
+// Deployment CE.
+type SixRD struct {
+	// IPv4MaskLen is the number of high bits the CEs of the 6rd domain
+	// have in common in their IPv4 addresses, and that are not embedded
+	// in their delegated prefix.
+	IPv4MaskLen uint8
+	// Prefix is the 6rd prefix of the domain.
+	Prefix net.IPNet
+	// BRAddrs are the IPv4 addresses of the 6rd Border Relays.
+	BRAddrs []net.IP
+}
+
+// DelegatedPrefix returns the 6rd delegated prefix of the CE with the IPv4
+// address ip: the 6rd prefix, followed by the low 32 - IPv4MaskLen bits of ip,
+// as described in RFC 5969, Section 4.
+func (s *SixRD) DelegatedPrefix(ip net.IP) (*net.IPNet, error) {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return nil, fmt.Errorf("%v is not an IPv4 address", ip)
+	}
+	ones, _ := s.Prefix.Mask.Size()
+	suffix := 32 - int(s.IPv4MaskLen)
+	if s.IPv4MaskLen > 32 || ones+suffix > 128 {
+		return nil, fmt.Errorf("invalid 6rd prefix length %d with IPv4 mask length %d", ones, s.IPv4MaskLen)
+	}
+	prefix := make(net.IP, net.IPv6len)
+	copy(prefix, s.Prefix.IP.To16().Mask(s.Prefix.Mask))
+	for i := 0; i < suffix; i++ {
+		bit := int(s.IPv4MaskLen) + i
+		if ip4[bit/8]&(0x80>>uint(bit%8)) != 0 {
+			pos := ones + i
+			prefix[pos/8] |= 0x80 >> uint(pos%8)
+		}
+	}
+	return &net.IPNet{IP: prefix, Mask: net.CIDRMask(ones+suffix, 128)}, nil
+}
+
+// ToBytes returns the serialized option.
+func (s *SixRD) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(s.IPv4MaskLen)
+	ones, _ := s.Prefix.Mask.Size()
+	buf.Write8(uint8(ones))
+	if ip := s.Prefix.IP.To16(); ip != nil {
+		buf.WriteBytes(ip)
+	} else {
+		buf.WriteBytes(net.IPv6zero)
+	}
+	for _, br := range s.BRAddrs {
+		buf.WriteBytes(br.To4())
+	}
+	return buf.Data()
+}
+
+// FromBytes parses the option from data.
+func (s *SixRD) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	s.IPv4MaskLen = buf.Read8()
+	ones := buf.Read8()
+	s.Prefix.IP = net.IP(buf.CopyN(net.IPv6len))
+	if err := buf.Error(); err != nil {
+		return err
+	}
+	if s.IPv4MaskLen > 32 || ones > 128 {
+		return fmt.Errorf("invalid 6rd option: IPv4 mask length %d, prefix length %d", s.IPv4MaskLen, ones)
+	}
+	s.Prefix.Mask = net.CIDRMask(int(ones), 128)
+	// At least one border relay address is required, RFC 5969, Section
+	// 7.1.1.
+	s.BRAddrs = []net.IP{net.IP(buf.CopyN(net.IPv4len))}
+	for buf.Has(net.IPv4len) {
+		s.BRAddrs = append(s.BRAddrs, net.IP(buf.CopyN(net.IPv4len)))
+	}
+	return buf.FinError()
+}
+
+// String returns a human-readable representation of the option.
+func (s *SixRD) String() string {
+	return fmt.Sprintf("IPv4MaskLen=%d Prefix=%s BRAddrs=%v", s.IPv4MaskLen, s.Prefix.String(), s.BRAddrs)
+}
+
+// Opt6RD returns a new DHCPv4 6rd option.
+//
+// The 6rd option is described by RFC 5969, Section 7.1.1.
+func Opt6RD(s *SixRD) Option {
+	return Option{Code: OptionOPTION6RD, Value: s}
+}
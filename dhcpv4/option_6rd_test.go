@@ -0,0 +1,60 @@
+package dhcpv4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpt6RD(t *testing.T) {
+	_, prefix, err := net.ParseCIDR("2001:db8::/32")
+	require.NoError(t, err)
+	s := &SixRD{
+		IPv4MaskLen: 8,
+		Prefix:      *prefix,
+		BRAddrs:     []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2)},
+	}
+	o := Opt6RD(s)
+	require.Equal(t, OptionOPTION6RD, o.Code)
+	want := []byte{
+		8,  // IPv4MaskLen
+		32, // 6rdPrefixLen
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		192, 0, 2, 1,
+		192, 0, 2, 2,
+	}
+	require.Equal(t, want, o.Value.ToBytes())
+
+	var got SixRD
+	require.NoError(t, got.FromBytes(want))
+	require.Equal(t, uint8(8), got.IPv4MaskLen)
+	require.Equal(t, "2001:db8::/32", got.Prefix.String())
+	require.Len(t, got.BRAddrs, 2)
+	require.True(t, got.BRAddrs[1].Equal(net.IPv4(192, 0, 2, 2)))
+	require.Contains(t, got.String(), "Prefix=2001:db8::/32")
+
+	m, err := New(With6RD(s))
+	require.NoError(t, err)
+	require.Equal(t, s.Prefix.String(), m.SixRD().Prefix.String())
+
+	// A border relay address is required.
+	require.Error(t, got.FromBytes(want[:18]))
+	require.Error(t, got.FromBytes(want[:25]))
+}
+
+func TestSixRDDelegatedPrefix(t *testing.T) {
+	_, prefix, err := net.ParseCIDR("2001:db8::/32")
+	require.NoError(t, err)
+	s := &SixRD{IPv4MaskLen: 8, Prefix: *prefix}
+	p, err := s.DelegatedPrefix(net.IPv4(10, 100, 100, 1))
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8:6464:100::/56", p.String())
+
+	_, err = s.DelegatedPrefix(net.ParseIP("2001:db8::1"))
+	require.Error(t, err)
+	s.IPv4MaskLen = 0
+	s.Prefix.Mask = net.CIDRMask(100, 128)
+	_, err = s.DelegatedPrefix(net.IPv4(10, 0, 0, 1))
+	require.Error(t, err)
+}
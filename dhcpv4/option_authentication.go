@@ -0,0 +1,109 @@
+package dhcpv4
+
+import (
+	"fmt"
+
+	"github.com/u-root/uio/uio"
+)
+
+// AuthProtocol is the protocol field of the Authentication option.
+type AuthProtocol uint8
+
+// Authentication protocols, as registered by IANA.
+const (
+	// AuthProtocolConfigurationToken is described by RFC 3118, Section 4.
+	AuthProtocolConfigurationToken AuthProtocol = 0
+	// AuthProtocolDelayed is described by RFC 3118, Section 5.
+	AuthProtocolDelayed AuthProtocol = 1
+	// AuthProtocolReconfigureKey is described by RFC 6704.
+	AuthProtocolReconfigureKey AuthProtocol = 3
+)
+
+var authProtocolToString = map[AuthProtocol]string{
+	AuthProtocolConfigurationToken: "Configuration Token",
+	AuthProtocolDelayed:            "Delayed Authentication",
+	AuthProtocolReconfigureKey:     "Reconfigure Key",
+}
+
+// String returns the name of the protocol.
+func (p AuthProtocol) String() string {
+	if s, ok := authProtocolToString[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(p))
+}
+
+// AuthAlgorithm is the algorithm field of the Authentication option.
+type AuthAlgorithm uint8
+
+// AuthAlgorithmHMACMD5 is the HMAC-MD5 algorithm, used by the delayed
+// authentication and reconfigure key protocols.
+const AuthAlgorithmHMACMD5 AuthAlgorithm = 1
+
+// String returns the name of the algorithm.
+func (a AuthAlgorithm) String() string {
+	if a == AuthAlgorithmHMACMD5 {
+		return "HMAC-MD5"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(a))
+}
+
+// AuthRDM is the replay detection method field of the Authentication option.
+type AuthRDM uint8
+
+// AuthRDMMonotonic means the replay detection field is a monotonically
+// increasing counter, RFC 3118, Section 2.
+const AuthRDMMonotonic AuthRDM = 0
+
+// String returns the name of the replay detection method.
+func (r AuthRDM) String() string {
+	if r == AuthRDMMonotonic {
+		return "Monotonic"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(r))
+}
+
+// Authentication is the value of the Authentication option, as described in
+// RFC 3118, Section 2. The format of Information depends on the protocol.
+type Authentication struct {
+	Protocol        AuthProtocol
+	Algorithm       AuthAlgorithm
+	RDM             AuthRDM
+	ReplayDetection uint64
+	Information     []byte
+}
+
+// ToBytes returns the serialized option.
+func (a *Authentication) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(uint8(a.Protocol))
+	buf.Write8(uint8(a.Algorithm))
+	buf.Write8(uint8(a.RDM))
+	buf.Write64(a.ReplayDetection)
+	buf.WriteBytes(a.Information)
+	return buf.Data()
+}
+
+// FromBytes parses the option from data.
+func (a *Authentication) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	a.Protocol = AuthProtocol(buf.Read8())
+	a.Algorithm = AuthAlgorithm(buf.Read8())
+	a.RDM = AuthRDM(buf.Read8())
+	a.ReplayDetection = buf.Read64()
+	a.Information = buf.ReadAll()
+	return buf.FinError()
+}
+
+// String returns a human-readable representation of the option.
+func (a *Authentication) String() string {
+	return fmt.Sprintf("Protocol=%s Algorithm=%s RDM=%s ReplayDetection=%#x Information=%#x",
+		a.Protocol, a.Algorithm, a.RDM, a.ReplayDetection, a.Information)
+}
+
+// OptAuthentication returns a new DHCPv4 Authentication option.
+//
+// The Authentication option is described by RFC 3118.
+func OptAuthentication(a *Authentication) Option {
+	return Option{Code: OptionAuthentication, Value: a}
+}
@@ -0,0 +1,43 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAuthentication(t *testing.T) {
+	data := []byte{
+		1, 1, 0, // protocol, algorithm, RDM
+		0, 0, 0, 0, 0, 0, 1, 2, // replay detection
+		0, 0, 0, 7, 0xaa, 0xbb, // information
+	}
+	var a Authentication
+	require.NoError(t, a.FromBytes(data))
+	require.Equal(t, Authentication{
+		Protocol:        AuthProtocolDelayed,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: 0x102,
+		Information:     []byte{0, 0, 0, 7, 0xaa, 0xbb},
+	}, a)
+	require.Equal(t, data, a.ToBytes())
+	require.Equal(t, "Protocol=Delayed Authentication Algorithm=HMAC-MD5 RDM=Monotonic ReplayDetection=0x102 Information=0x00000007aabb", a.String())
+
+	require.Error(t, a.FromBytes(data[:10]))
+}
+
+func TestAuthenticationAccessor(t *testing.T) {
+	m, err := New()
+	require.NoError(t, err)
+	require.Nil(t, m.Authentication())
+
+	want := &Authentication{Protocol: AuthProtocolConfigurationToken, Information: []byte("token")}
+	m.UpdateOption(OptAuthentication(want))
+	got := m.Authentication()
+	require.Equal(t, want, got)
+	require.Contains(t, m.Summary(), "Authentication: Protocol=Configuration Token")
+
+	m.UpdateOption(OptGeneric(OptionAuthentication, []byte{1}))
+	require.Nil(t, m.Authentication())
+}
@@ -0,0 +1,99 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// OptionCaptivePortal is the Captive-Portal option, RFC 8910, Section 2.1,
+// which took over the code of the URL option.
+const OptionCaptivePortal = OptionURL
+
+// CaptivePortalUnrestricted is the captive portal API URI of networks without
+// a captive portal, RFC 8910, Section 2.
+const CaptivePortalUnrestricted = "urn:ietf:params:capport:unrestricted"
+
+// ValidCaptivePortalURI returns an error if uri is not a valid captive portal
+// API URI: an absolute https URI, RFC 8908, Section 3, or
+// CaptivePortalUnrestricted.
+func ValidCaptivePortalURI(uri string) error {
+	if uri == CaptivePortalUnrestricted {
+		return nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("invalid captive portal API URI: %w", err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid captive portal API URI %q: must be an absolute https URI", uri)
+	}
+	return nil
+}
+
+// CaptivePortalClasses maps client classes to captive portal API URIs, for
+// servers that send a different URI to each class of clients. It is used by
+// the WithCaptivePortalByClass modifiers of the dhcpv4 and dhcpv6 packages.
+type CaptivePortalClasses struct {
+	uris map[string]string
+}
+
+// NewCaptivePortalClasses returns the CaptivePortalClasses of uris, which maps
+// client classes to their URI. The URI of the empty class is the default. It
+// returns an error if one of the URIs is not valid, see ValidCaptivePortalURI.
+func NewCaptivePortalClasses(uris map[string]string) (*CaptivePortalClasses, error) {
+	c := &CaptivePortalClasses{uris: make(map[string]string, len(uris))}
+	for class, uri := range uris {
+		if err := ValidCaptivePortalURI(uri); err != nil {
+			return nil, fmt.Errorf("class %q: %w", class, err)
+		}
+		c.uris[class] = uri
+	}
+	return c, nil
+}
+
+// Lookup returns the URI of the first of classes that has one, or else the
+// default URI. It returns false if there is none, or if c is nil.
+func (c *CaptivePortalClasses) Lookup(classes []string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	for _, class := range classes {
+		if uri, ok := c.uris[class]; ok {
+			return uri, true
+		}
+	}
+	uri, ok := c.uris[""]
+	return uri, ok
+}
+
+// CaptivePortalURI is the value of the Captive-Portal option.
+type CaptivePortalURI string
+
+// ToBytes returns a serialized stream of bytes for this option.
+func (o CaptivePortalURI) ToBytes() []byte {
+	return []byte(o)
+}
+
+// String returns a human-readable string.
+func (o CaptivePortalURI) String() string {
+	return string(o)
+}
+
+// FromBytes parses a serialized stream of bytes into o.
+//
+// The URI is not validated here, DHCPv4.CaptivePortal ignores invalid URIs.
+func (o *CaptivePortalURI) FromBytes(data []byte) error {
+	*o = CaptivePortalURI(data)
+	return nil
+}
+
+// OptCaptivePortal returns a new DHCPv4 Captive-Portal option, or an error if
+// uri is not valid, see ValidCaptivePortalURI.
+//
+// The Captive-Portal option is described by RFC 8910, Section 2.1.
+func OptCaptivePortal(uri string) (Option, error) {
+	if err := ValidCaptivePortalURI(uri); err != nil {
+		return Option{}, err
+	}
+	return Option{Code: OptionCaptivePortal, Value: CaptivePortalURI(uri)}, nil
+}
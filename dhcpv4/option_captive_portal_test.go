@@ -0,0 +1,78 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptCaptivePortal(t *testing.T) {
+	o, err := OptCaptivePortal("https://portal.example.net/api")
+	require.NoError(t, err)
+	require.Equal(t, OptionURL, o.Code)
+	require.Equal(t, []byte("https://portal.example.net/api"), o.Value.ToBytes())
+	require.Equal(t, "URL: https://portal.example.net/api", o.String())
+
+	m, err := New(WithOption(o))
+	require.NoError(t, err)
+	require.Equal(t, "https://portal.example.net/api", m.CaptivePortal())
+
+	o, err = OptCaptivePortal(CaptivePortalUnrestricted)
+	require.NoError(t, err)
+	m, err = New(WithOption(o))
+	require.NoError(t, err)
+	require.Equal(t, CaptivePortalUnrestricted, m.CaptivePortal())
+
+	for _, uri := range []string{"http://portal.example.net/api", "/api", "portal.example.net", "https://%zz"} {
+		require.Error(t, ValidCaptivePortalURI(uri), uri)
+		_, err = OptCaptivePortal(uri)
+		require.Error(t, err, uri)
+		// Invalid URIs are parsed, and ignored by the accessor.
+		m, err = New(WithGeneric(OptionCaptivePortal, []byte(uri)))
+		require.NoError(t, err)
+		m, err = FromBytes(m.ToBytes())
+		require.NoError(t, err)
+		require.Empty(t, m.CaptivePortal(), uri)
+	}
+}
+
+func TestWithCaptivePortalByClass(t *testing.T) {
+	uris, err := NewCaptivePortalClasses(map[string]string{
+		"":       "https://portal.example.net/api",
+		"guest":  "https://guest.example.net/api",
+		"vendor": CaptivePortalUnrestricted,
+	})
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		name      string
+		modifiers []Modifier
+		want      string
+	}{
+		{"default", nil, "https://portal.example.net/api"},
+		{"user class", []Modifier{WithUserClass("guest", true), WithOption(OptClassIdentifier("vendor"))}, "https://guest.example.net/api"},
+		{"class identifier", []Modifier{WithUserClass("other", true), WithOption(OptClassIdentifier("vendor"))}, CaptivePortalUnrestricted},
+		{"no class identifier", []Modifier{WithUserClass("other", true)}, "https://portal.example.net/api"},
+		{"not requested", []Modifier{WithRequestedOptions(OptionDomainNameServer)}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := New(tt.modifiers...)
+			require.NoError(t, err)
+			reply, err := NewReplyFromRequest(request, WithCaptivePortalByClass(request, uris))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, reply.CaptivePortal())
+		})
+	}
+
+	_, err = NewCaptivePortalClasses(map[string]string{"plain": "http://portal.example.net/api"})
+	require.Error(t, err)
+	// Without a default URI, other classes get no option.
+	uris, err = NewCaptivePortalClasses(map[string]string{"guest": "https://guest.example.net/api"})
+	require.NoError(t, err)
+	_, ok := uris.Lookup([]string{"other"})
+	require.False(t, ok)
+	request, err := New(WithUserClass("other", true))
+	require.NoError(t, err)
+	reply, err := NewReplyFromRequest(request, WithCaptivePortalByClass(request, uris))
+	require.NoError(t, err)
+	require.Nil(t, reply.GetOneOption(OptionCaptivePortal))
+}
@@ -0,0 +1,155 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/insomniacslk/dhcp/svcb"
+	"github.com/u-root/uio/uio"
+)
+
+// DNR is an encrypted DNS resolver designated by the network, a DNR instance
+// of the Encrypted DNS option as per RFC 9463, Section 5.1.
+//
+// An instance without addresses is in ADN-only mode: the client resolves the
+// name of the resolver itself. Otherwise, Params must include the alpn
+// SvcParam.
+type DNR struct {
+	// ServicePriority orders the resolvers, lower values first.
+	ServicePriority uint16
+	// ADN is the Authentication Domain Name of the resolver.
+	ADN    string
+	Addrs  []net.IP
+	Params svcb.Params
+}
+
+// maxDNRAddrs is the number of IPv4 addresses that fit in the one byte
+// address length of a DNR instance.
+const maxDNRAddrs = math.MaxUint8 / net.IPv4len
+
+// ADNOnly returns whether the instance is in ADN-only mode.
+func (r *DNR) ADNOnly() bool {
+	return len(r.Addrs) == 0
+}
+
+// Marshal implements uio.Marshaler. Addresses that are not IPv4 addresses
+// are left out, and an instance without IPv4 addresses is marshaled in
+// ADN-only mode. Only the first 63 IPv4 addresses fit in an instance.
+//
+// An instance whose ADN is longer than 255 bytes once encoded cannot be
+// marshaled and is left out.
+func (r *DNR) Marshal(buf *uio.Lexer) {
+	adn := (&rfc1035label.Labels{Labels: []string{r.ADN}}).ToBytes()
+	if len(adn) > math.MaxUint8 {
+		return
+	}
+	data := uio.NewBigEndianBuffer(nil)
+	data.Write16(r.ServicePriority)
+	data.Write8(uint8(len(adn)))
+	data.WriteBytes(adn)
+	var addrs []net.IP
+	for _, addr := range r.Addrs {
+		if ip4 := addr.To4(); ip4 != nil {
+			addrs = append(addrs, ip4)
+		}
+	}
+	if len(addrs) > maxDNRAddrs {
+		addrs = addrs[:maxDNRAddrs]
+	}
+	if len(addrs) > 0 {
+		data.Write8(uint8(len(addrs) * net.IPv4len))
+		for _, addr := range addrs {
+			data.WriteBytes(addr)
+		}
+		data.WriteBytes(r.Params.ToBytes())
+	}
+	buf.Write16(uint16(len(data.Data())))
+	buf.WriteBytes(data.Data())
+}
+
+// Unmarshal implements uio.Unmarshaler.
+func (r *DNR) Unmarshal(buf *uio.Lexer) error {
+	data := uio.NewBigEndianBuffer(buf.Consume(int(buf.Read16())))
+	if err := buf.Error(); err != nil {
+		return err
+	}
+	r.ServicePriority = data.Read16()
+	adn := data.Consume(int(data.Read8()))
+	if err := data.Error(); err != nil {
+		return err
+	}
+	labels, err := rfc1035label.FromBytes(adn)
+	if err != nil {
+		return err
+	}
+	if len(labels.Labels) != 1 {
+		return fmt.Errorf("DNR instance must contain exactly one authentication domain name, got %d", len(labels.Labels))
+	}
+	r.ADN = labels.Labels[0]
+	r.Addrs, r.Params = nil, nil
+	if !data.Has(1) {
+		// ADN-only mode.
+		return nil
+	}
+	n := int(data.Read8())
+	if n == 0 || n%net.IPv4len != 0 {
+		return fmt.Errorf("invalid DNR instance address length %d", n)
+	}
+	for i := 0; i < n/net.IPv4len; i++ {
+		r.Addrs = append(r.Addrs, net.IP(data.CopyN(net.IPv4len)))
+	}
+	if err := data.Error(); err != nil {
+		return err
+	}
+	r.Params, err = svcb.FromBytes(data.ReadAll())
+	return err
+}
+
+// String returns a human-readable representation of the instance.
+func (r *DNR) String() string {
+	return fmt.Sprintf("ServicePriority=%d ADN=%s Addrs=%v Params=%s", r.ServicePriority, r.ADN, r.Addrs, r.Params)
+}
+
+// DNRs is the value of the Encrypted DNS option: a list of DNR instances.
+type DNRs []*DNR
+
+// FromBytes parses the DNR instances from data, as described by RFC 9463.
+func (d *DNRs) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(1) {
+		var r DNR
+		if err := r.Unmarshal(buf); err != nil {
+			return err
+		}
+		*d = append(*d, &r)
+	}
+	return buf.FinError()
+}
+
+// ToBytes marshals the DNR instances as described by RFC 9463.
+func (d DNRs) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, r := range d {
+		r.Marshal(buf)
+	}
+	return buf.Data()
+}
+
+// String prints all DNR instances.
+func (d DNRs) String() string {
+	s := make([]string, 0, len(d))
+	for _, r := range d {
+		s = append(s, "{"+r.String()+"}")
+	}
+	return strings.Join(s, ", ")
+}
+
+// OptDNR returns a new DHCPv4 Encrypted DNS option.
+//
+// The Encrypted DNS option is described by RFC 9463, Section 5.1.
+func OptDNR(dnrs ...*DNR) Option {
+	return Option{Code: OptionV4DNR, Value: DNRs(dnrs)}
+}
@@ -0,0 +1,94 @@
+package dhcpv4
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/svcb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptDNR(t *testing.T) {
+	params := make(svcb.Params)
+	params.SetALPN("dot")
+	o := OptDNR(
+		&DNR{ServicePriority: 2, ADN: "dot.example.net", Addrs: []net.IP{net.IPv4(192, 0, 2, 53)}, Params: params},
+		&DNR{ServicePriority: 1, ADN: "a.net"},
+	)
+	require.Equal(t, OptionV4DNR, o.Code)
+	want := []byte{
+		0, 33, // instance data length
+		0, 2, // service priority
+		17, // ADN length
+		3, 'd', 'o', 't', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'n', 'e', 't', 0,
+		4, // addr length
+		192, 0, 2, 53,
+		0, 1, 0, 4, 3, 'd', 'o', 't', // alpn=dot
+		0, 10, // instance data length
+		0, 1, // service priority
+		7, // ADN length
+		1, 'a', 3, 'n', 'e', 't', 0,
+	}
+	require.Equal(t, want, o.Value.ToBytes())
+
+	var dnrs DNRs
+	require.NoError(t, dnrs.FromBytes(want))
+	require.Len(t, dnrs, 2)
+	require.Equal(t, "dot.example.net", dnrs[0].ADN)
+	require.Equal(t, []string{"dot"}, dnrs[0].Params.ALPN())
+	require.True(t, dnrs[1].ADNOnly())
+	require.Contains(t, dnrs.String(), "ADN=a.net")
+
+	m, err := New(WithDNR(dnrs...))
+	require.NoError(t, err)
+	got := m.DNR()
+	require.Len(t, got, 2)
+	// Ordered by service priority.
+	require.Equal(t, "a.net", got[0].ADN)
+	require.True(t, net.IPv4(192, 0, 2, 53).Equal(got[1].Addrs[0]))
+
+	// Truncated instance.
+	dnrs = nil
+	require.Error(t, dnrs.FromBytes(want[:10]))
+	// Invalid address length.
+	dnrs = nil
+	require.Error(t, dnrs.FromBytes([]byte{0, 6, 0, 1, 2, 1, 'a', 0, 3}))
+}
+
+func TestOptDNRNonIPv4Addrs(t *testing.T) {
+	params := make(svcb.Params)
+	params.SetALPN("dot")
+	dnrs := DNRs{
+		{ServicePriority: 1, ADN: "dot.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::53"), net.IPv4(192, 0, 2, 53)}, Params: params},
+		{ServicePriority: 2, ADN: "v6.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::53")}, Params: params},
+	}
+	var got DNRs
+	require.NoError(t, got.FromBytes(dnrs.ToBytes()))
+	require.Len(t, got, 2)
+	require.Equal(t, []net.IP{{192, 0, 2, 53}}, got[0].Addrs)
+	require.Equal(t, []string{"dot"}, got[0].Params.ALPN())
+	require.Equal(t, "v6.example.net", got[1].ADN)
+	require.True(t, got[1].ADNOnly())
+}
+
+func TestOptDNRLong(t *testing.T) {
+	params := make(svcb.Params)
+	params.SetALPN("dot")
+	var addrs []net.IP
+	for i := 0; i < 70; i++ {
+		addrs = append(addrs, net.IPv4(192, 0, 2, byte(i)))
+	}
+	dnrs := DNRs{
+		{ServicePriority: 1, ADN: "dot.example.net", Addrs: addrs, Params: params},
+		{ServicePriority: 2, ADN: strings.Repeat("a.", 128) + "net", Addrs: addrs[:1], Params: params},
+		{ServicePriority: 3, ADN: "doh.example.net"},
+	}
+	var got DNRs
+	require.NoError(t, got.FromBytes(dnrs.ToBytes()))
+	require.Len(t, got, 2)
+	require.Len(t, got[0].Addrs, 63)
+	require.Equal(t, net.IP{192, 0, 2, 62}, got[0].Addrs[62])
+	require.Equal(t, []string{"dot"}, got[0].Params.ALPN())
+	require.Equal(t, "doh.example.net", got[1].ADN)
+}
@@ -0,0 +1,137 @@
+package dhcpv4
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/u-root/uio/uio"
+)
+
+// Client FQDN option flags, as described in RFC 4702, Section 2.1.
+const (
+	// FQDNFlagS is set if the server should perform the A RR update.
+	FQDNFlagS uint8 = 1 << 0
+	// FQDNFlagO is set by the server if it overrode the client's
+	// preference for the S flag.
+	FQDNFlagO uint8 = 1 << 1
+	// FQDNFlagE is set if the domain name is in canonical wire format.
+	FQDNFlagE uint8 = 1 << 2
+	// FQDNFlagN is set if the server should not perform any DNS updates.
+	FQDNFlagN uint8 = 1 << 3
+)
+
+// FQDNServerRCode is the value servers use for the deprecated RCODE1 and
+// RCODE2 fields, RFC 4702, Section 2.2.
+const FQDNServerRCode = 255
+
+// FQDN is the value of the Client FQDN option.
+//
+// DomainName is a fully qualified domain name if it ends with a dot, and a
+// partial name otherwise. It is encoded in canonical wire format if the E flag
+// is set, and in ASCII otherwise.
+type FQDN struct {
+	Flags uint8
+	// RCode1 and RCode2 are deprecated, clients set them to 0 and servers
+	// to 255.
+	RCode1     uint8
+	RCode2     uint8
+	DomainName string
+}
+
+// FullyQualified returns whether f contains a fully qualified domain name.
+func (f *FQDN) FullyQualified() bool {
+	return strings.HasSuffix(f.DomainName, ".")
+}
+
+// ToBytes returns the serialized option. Names that cannot be encoded in
+// canonical wire format, such as names with empty or oversized labels, are
+// encoded in ASCII with the E flag cleared.
+func (f *FQDN) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	flags := f.Flags
+	if flags&FQDNFlagE != 0 && ddns.ValidateName(f.DomainName) != nil {
+		flags &^= FQDNFlagE
+	}
+	buf.Write8(flags)
+	buf.Write8(f.RCode1)
+	buf.Write8(f.RCode2)
+	if flags&FQDNFlagE == 0 {
+		buf.WriteBytes([]byte(f.DomainName))
+		return buf.Data()
+	}
+	name := strings.TrimSuffix(f.DomainName, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf.Write8(uint8(len(label)))
+			buf.WriteBytes([]byte(label))
+		}
+	}
+	if f.FullyQualified() {
+		buf.Write8(0)
+	}
+	return buf.Data()
+}
+
+// FromBytes parses the option from data.
+func (f *FQDN) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	f.Flags = buf.Read8()
+	f.RCode1 = buf.Read8()
+	f.RCode2 = buf.Read8()
+	if buf.Error() != nil {
+		return buf.Error()
+	}
+	if f.Flags&FQDNFlagE == 0 {
+		f.DomainName = string(buf.ReadAll())
+		return buf.FinError()
+	}
+
+	// Canonical wire format is not compressed, RFC 4702, Section 2.3.1.
+	var labels []string
+	fullyQualified := false
+	for buf.Has(1) {
+		n := int(buf.Read8())
+		if n == 0 {
+			fullyQualified = true
+			break
+		}
+		if n > 63 {
+			return fmt.Errorf("invalid label length %d in FQDN option", n)
+		}
+		labels = append(labels, string(buf.CopyN(n)))
+	}
+	if err := buf.FinError(); err != nil {
+		return err
+	}
+	if fullyQualified && len(labels) == 0 {
+		return errors.New("empty fully qualified name in FQDN option")
+	}
+	f.DomainName = strings.Join(labels, ".")
+	if fullyQualified {
+		f.DomainName += "."
+	}
+	return nil
+}
+
+// String returns a human-readable representation of the option.
+func (f *FQDN) String() string {
+	var flags []string
+	for _, fl := range []struct {
+		flag uint8
+		name string
+	}{{FQDNFlagS, "S"}, {FQDNFlagO, "O"}, {FQDNFlagE, "E"}, {FQDNFlagN, "N"}} {
+		if f.Flags&fl.flag != 0 {
+			flags = append(flags, fl.name)
+		}
+	}
+	return fmt.Sprintf("Flags=[%s] RCode1=%d RCode2=%d DomainName=%s", strings.Join(flags, "|"), f.RCode1, f.RCode2, f.DomainName)
+}
+
+// OptFQDN returns a new DHCPv4 Client FQDN option.
+//
+// The Client FQDN option is described by RFC 4702.
+func OptFQDN(f *FQDN) Option {
+	return Option{Code: OptionFQDN, Value: f}
+}
@@ -0,0 +1,103 @@
+package dhcpv4
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFQDN(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		want    *FQDN
+		wantErr bool
+	}{
+		{
+			name: "ascii",
+			data: []byte{FQDNFlagS, 0, 0, 'h', 'o', 's', 't'},
+			want: &FQDN{Flags: FQDNFlagS, DomainName: "host"},
+		},
+		{
+			name: "canonical fully qualified",
+			data: []byte{FQDNFlagS | FQDNFlagE, 0, 0, 4, 'h', 'o', 's', 't', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0},
+			want: &FQDN{Flags: FQDNFlagS | FQDNFlagE, DomainName: "host.example."},
+		},
+		{
+			name: "canonical partial",
+			data: []byte{FQDNFlagE, 255, 255, 4, 'h', 'o', 's', 't'},
+			want: &FQDN{Flags: FQDNFlagE, RCode1: 255, RCode2: 255, DomainName: "host"},
+		},
+		{
+			name: "empty",
+			data: []byte{FQDNFlagE | FQDNFlagN, 0, 0},
+			want: &FQDN{Flags: FQDNFlagE | FQDNFlagN},
+		},
+		{
+			name:    "short",
+			data:    []byte{FQDNFlagS, 0},
+			wantErr: true,
+		},
+		{
+			name:    "truncated label",
+			data:    []byte{FQDNFlagE, 0, 0, 4, 'h', 'o'},
+			wantErr: true,
+		},
+		{
+			name:    "trailing data",
+			data:    []byte{FQDNFlagE, 0, 0, 1, 'h', 0, 1},
+			wantErr: true,
+		},
+		{
+			name:    "compression",
+			data:    []byte{FQDNFlagE, 0, 0, 0xc0, 0},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var f FQDN
+			err := f.FromBytes(tt.data)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, &f)
+			require.Equal(t, tt.data, f.ToBytes())
+		})
+	}
+}
+
+func TestFQDNInvalidCanonicalName(t *testing.T) {
+	for _, name := range []string{
+		"a..evil.example.com.",
+		strings.Repeat("a", 64) + ".example.com.",
+		strings.Repeat("a", 200),
+	} {
+		f := &FQDN{Flags: FQDNFlagS | FQDNFlagE, RCode1: 255, RCode2: 255, DomainName: name}
+		var got FQDN
+		require.NoError(t, got.FromBytes(f.ToBytes()))
+		require.Equal(t, &FQDN{Flags: FQDNFlagS, RCode1: 255, RCode2: 255, DomainName: name}, &got)
+	}
+}
+
+func TestFQDNString(t *testing.T) {
+	f := &FQDN{Flags: FQDNFlagS | FQDNFlagE, DomainName: "host.example."}
+	require.Equal(t, "Flags=[S|E] RCode1=0 RCode2=0 DomainName=host.example.", f.String())
+	require.True(t, f.FullyQualified())
+}
+
+func TestGetFQDN(t *testing.T) {
+	m, _ := New(WithFQDN(FQDNFlagS|FQDNFlagE, "host.example.com."))
+	f := m.FQDN()
+	require.NotNil(t, f)
+	require.Equal(t, "host.example.com.", f.DomainName)
+	require.Equal(t, FQDNFlagS|FQDNFlagE, f.Flags)
+	require.Contains(t, m.Summary(), "FQDN: Flags=[S|E]")
+
+	m, _ = New()
+	require.Nil(t, m.FQDN())
+	m, _ = New(WithGeneric(OptionFQDN, []byte{0}))
+	require.Nil(t, m.FQDN())
+}
@@ -0,0 +1,8 @@
+package dhcpv4
+
+// OptInterfaceMTU returns a new Interface MTU option.
+//
+// The Interface MTU option is described by RFC 2132, Section 5.1.
+func OptInterfaceMTU(mtu uint16) Option {
+	return Option{Code: OptionInterfaceMTU, Value: Uint16(mtu)}
+}
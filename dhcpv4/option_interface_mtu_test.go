@@ -0,0 +1,34 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptInterfaceMTU(t *testing.T) {
+	o := OptInterfaceMTU(1400)
+	require.Equal(t, OptionInterfaceMTU, o.Code, "Code")
+	require.Equal(t, []byte{5, 120}, o.Value.ToBytes(), "ToBytes")
+	require.Equal(t, "Interface MTU: 1400", o.String())
+}
+
+func TestGetInterfaceMTU(t *testing.T) {
+	m, _ := New(WithOption(OptInterfaceMTU(9000)))
+	mtu, err := m.InterfaceMTU()
+	require.NoError(t, err)
+	require.Equal(t, uint16(9000), mtu)
+
+	m, _ = New()
+	_, err = m.InterfaceMTU()
+	require.Error(t, err, "should get error if option is missing")
+
+	m, _ = New(WithGeneric(OptionInterfaceMTU, []byte{2}))
+	_, err = m.InterfaceMTU()
+	require.Error(t, err, "should get error from short byte stream")
+
+	// RFC 2132 sets a minimum of 68 bytes.
+	m, _ = New(WithOption(OptInterfaceMTU(60)))
+	_, err = m.InterfaceMTU()
+	require.Error(t, err, "should get error for MTU below 68")
+}
@@ -0,0 +1,98 @@
+package dhcpv4
+
+import (
+	"fmt"
+
+	"github.com/u-root/uio/uio"
+)
+
+// NetworkInterfaceTypeUNDI is the only network interface type defined by
+// RFC 4578: the Universal Network Device Interface.
+const NetworkInterfaceTypeUNDI = 1
+
+// NetworkInterfaceIdentifier implements the client network interface
+// identifier option described by RFC 4578, Section 2.2.
+type NetworkInterfaceIdentifier struct {
+	Type  uint8
+	Major uint8
+	Minor uint8
+}
+
+// ToBytes returns a serialized stream of bytes for this option.
+func (n NetworkInterfaceIdentifier) ToBytes() []byte {
+	return []byte{n.Type, n.Major, n.Minor}
+}
+
+// String returns a human-readable string for this option.
+func (n NetworkInterfaceIdentifier) String() string {
+	if n.Type == NetworkInterfaceTypeUNDI {
+		return fmt.Sprintf("UNDI %d.%d", n.Major, n.Minor)
+	}
+	return fmt.Sprintf("type %d, %d.%d", n.Type, n.Major, n.Minor)
+}
+
+// FromBytes parses data into n per RFC 4578, Section 2.2.
+func (n *NetworkInterfaceIdentifier) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	n.Type = buf.Read8()
+	n.Major = buf.Read8()
+	n.Minor = buf.Read8()
+	return buf.FinError()
+}
+
+// OptClientNetworkInterfaceIdentifier returns a new client network interface
+// identifier option for an UNDI interface of the given version.
+//
+// The option is described by RFC 4578, Section 2.2.
+func OptClientNetworkInterfaceIdentifier(major, minor uint8) Option {
+	return Option{
+		Code:  OptionClientNetworkInterfaceIdentifier,
+		Value: NetworkInterfaceIdentifier{Type: NetworkInterfaceTypeUNDI, Major: major, Minor: minor},
+	}
+}
+
+// MachineIdentifierTypeGUID is the only machine identifier type defined by
+// RFC 4578.
+const MachineIdentifierTypeGUID = 0
+
+// MachineIdentifier implements the client machine identifier option
+// described by RFC 4578, Section 2.3.
+type MachineIdentifier struct {
+	Type uint8
+	// GUID is the client UUID, in the byte order sent by the client.
+	GUID [16]byte
+}
+
+// ToBytes returns a serialized stream of bytes for this option.
+func (m MachineIdentifier) ToBytes() []byte {
+	return append([]byte{m.Type}, m.GUID[:]...)
+}
+
+// String returns a human-readable string for this option.
+func (m MachineIdentifier) String() string {
+	g := m.GUID
+	s := fmt.Sprintf("%x-%x-%x-%x-%x", g[0:4], g[4:6], g[6:8], g[8:10], g[10:16])
+	if m.Type != MachineIdentifierTypeGUID {
+		return fmt.Sprintf("type %d, %s", m.Type, s)
+	}
+	return s
+}
+
+// FromBytes parses data into m per RFC 4578, Section 2.3.
+func (m *MachineIdentifier) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	m.Type = buf.Read8()
+	buf.ReadBytes(m.GUID[:])
+	return buf.FinError()
+}
+
+// OptClientMachineIdentifier returns a new client machine identifier option
+// holding the given GUID.
+//
+// The option is described by RFC 4578, Section 2.3.
+func OptClientMachineIdentifier(guid [16]byte) Option {
+	return Option{
+		Code:  OptionClientMachineIdentifier,
+		Value: MachineIdentifier{Type: MachineIdentifierTypeGUID, GUID: guid},
+	}
+}
@@ -0,0 +1,44 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptClientNetworkInterfaceIdentifier(t *testing.T) {
+	opt := OptClientNetworkInterfaceIdentifier(3, 16)
+	require.Equal(t, OptionClientNetworkInterfaceIdentifier, opt.Code)
+	require.Equal(t, []byte{1, 3, 16}, opt.Value.ToBytes())
+	require.Equal(t, "Client Network Interface Identifier: UNDI 3.16", opt.String())
+
+	m, _ := New(WithOption(opt))
+	require.Equal(t, &NetworkInterfaceIdentifier{Type: NetworkInterfaceTypeUNDI, Major: 3, Minor: 16}, m.ClientNetworkInterfaceIdentifier())
+	require.Contains(t, m.Summary(), "Client Network Interface Identifier: UNDI 3.16")
+
+	m, _ = New(WithGeneric(OptionClientNetworkInterfaceIdentifier, []byte{1, 3}))
+	require.Nil(t, m.ClientNetworkInterfaceIdentifier())
+	m, _ = New()
+	require.Nil(t, m.ClientNetworkInterfaceIdentifier())
+
+	require.Equal(t, "type 2, 1.0", NetworkInterfaceIdentifier{Type: 2, Major: 1}.String())
+}
+
+func TestOptClientMachineIdentifier(t *testing.T) {
+	guid := [16]byte{0x4c, 0x4c, 0x45, 0x44, 0x00, 0x37, 0x39, 0x10, 0x80, 0x44, 0xb4, 0xc0, 0x4f, 0x4d, 0x39, 0x32}
+	opt := OptClientMachineIdentifier(guid)
+	require.Equal(t, OptionClientMachineIdentifier, opt.Code)
+	require.Equal(t, append([]byte{0}, guid[:]...), opt.Value.ToBytes())
+	require.Equal(t, "Client Machine Identifier: 4c4c4544-0037-3910-8044-b4c04f4d3932", opt.String())
+
+	m, _ := New(WithOption(opt))
+	require.Equal(t, &MachineIdentifier{Type: MachineIdentifierTypeGUID, GUID: guid}, m.ClientMachineIdentifier())
+	require.Contains(t, m.Summary(), "Client Machine Identifier: 4c4c4544-0037-3910-8044-b4c04f4d3932")
+
+	m, _ = New(WithGeneric(OptionClientMachineIdentifier, []byte{0, 1, 2}))
+	require.Nil(t, m.ClientMachineIdentifier())
+	m, _ = New()
+	require.Nil(t, m.ClientMachineIdentifier())
+
+	require.Equal(t, "type 1, 00000000-0000-0000-0000-000000000000", MachineIdentifier{Type: 1}.String())
+}
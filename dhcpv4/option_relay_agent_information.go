@@ -78,6 +78,7 @@ const (
 	VendorSpecificInformationSubOption     raiSubOptionCode = 9   // RFC 4243
 	RelayAgentFlagsSubOption               raiSubOptionCode = 10  // RFC 5010
 	ServerIdentifierOverrideSubOption      raiSubOptionCode = 11  // RFC 5107
+	RelayIDSubOption                       raiSubOptionCode = 12  // RFC 6925
 	RelaySourcePortSubOption               raiSubOptionCode = 19  // RFC 8357
 	VirtualSubnetSelectionSubOption        raiSubOptionCode = 151 // RFC 6607
 	VirtualSubnetSelectionControlSubOption raiSubOptionCode = 152 // RFC 6607
@@ -94,6 +95,7 @@ var raiSubOptionCodeToString = map[raiSubOptionCode]string{
 	VendorSpecificInformationSubOption:     "Vendor Specific Sub-option",
 	RelayAgentFlagsSubOption:               "Relay Agent Flags Sub-option",
 	ServerIdentifierOverrideSubOption:      "Server Identifier Override Sub-option",
+	RelayIDSubOption:                       "Relay Identifier Sub-option",
 	RelaySourcePortSubOption:               "Relay Source Port Sub-option",
 	VirtualSubnetSelectionSubOption:        "Virtual Subnet Selection Sub-option",
 	VirtualSubnetSelectionControlSubOption: "Virtual Subnet Selection Control Sub-option",
@@ -0,0 +1,61 @@
+package dhcpv4
+
+import (
+	"fmt"
+)
+
+// AristaOptions are Arista vendor-specific sub-options, carried in the
+// Vendor Specific Information option when the class identifier starts with
+// "Arista;" or in the Vendor-Identifying Vendor-Specific Information option.
+//
+// Arista does not assign names to the sub-option codes; their values are
+// text and are printed as such.
+type AristaOptions struct {
+	Options
+}
+
+var aristaHumanizer = OptionHumanizer{
+	ValueHumanizer: func(code OptionCode, data []byte) fmt.Stringer {
+		return raiSubOptionValue{data}
+	},
+	CodeHumanizer: func(c uint8) OptionCode {
+		return aristaSubOptionCode(c)
+	},
+}
+
+// String prints the contained options as text.
+func (a AristaOptions) String() string {
+	return "\n" + a.Options.ToString(aristaHumanizer)
+}
+
+// FromBytes parses Arista sub-options from data.
+func (a *AristaOptions) FromBytes(data []byte) error {
+	a.Options = make(Options)
+	return a.Options.FromBytes(data)
+}
+
+// Value returns the text value of the given sub-option.
+func (a AristaOptions) Value(code uint8) string {
+	return string(a.Options[code])
+}
+
+// OptAristaVendorOptions returns a new Vendor Specific Information option
+// holding Arista sub-options.
+func OptAristaVendorOptions(o ...Option) Option {
+	return Option{Code: OptionVendorSpecificInformation, Value: AristaOptions{OptionsFromList(o...)}}
+}
+
+// OptAristaSubOption returns an Arista sub-option with the given text value.
+func OptAristaSubOption(code uint8, value string) Option {
+	return Option{Code: aristaSubOptionCode(code), Value: String(value)}
+}
+
+type aristaSubOptionCode uint8
+
+func (o aristaSubOptionCode) Code() uint8 {
+	return uint8(o)
+}
+
+func (o aristaSubOptionCode) String() string {
+	return fmt.Sprintf("Arista sub-option %d", uint8(o))
+}
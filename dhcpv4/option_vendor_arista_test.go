@@ -0,0 +1,25 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptAristaVendorOptions(t *testing.T) {
+	opt := OptAristaVendorOptions(OptAristaSubOption(1, "http://ztp/startup"))
+	require.Equal(t, OptionVendorSpecificInformation, opt.Code)
+
+	m, err := New(WithOption(OptClassIdentifier("Arista;DCS-7050S-64;01.23;JPE12221671")), WithOption(opt))
+	require.NoError(t, err)
+	a, ok := m.VendorSpecific().(*AristaOptions)
+	require.True(t, ok)
+	require.Equal(t, "http://ztp/startup", a.Value(1))
+	require.Equal(t, "", a.Value(2))
+	require.Equal(t, "\n    Arista sub-option 1: \"http://ztp/startup\" ([104 116 116 112 58 47 47 122 116 112 47 115 116 97 114 116 117 112])\n", a.String())
+
+	d, err := NewVendorRegistry().DecodeVIVS(VIVSOption{EntID: iana.EnterpriseIDAristaNetworks, Options: a.Options})
+	require.NoError(t, err)
+	require.IsType(t, &AristaOptions{}, d)
+}
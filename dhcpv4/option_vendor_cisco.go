@@ -0,0 +1,101 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"net"
+)
+
+// CiscoOptions are Cisco vendor-specific sub-options.
+//
+// They are carried in the Vendor Specific Information option by Cisco access
+// points, whose class identifier starts with "Cisco AP", and in the
+// Vendor-Identifying Vendor-Specific Information option by devices using
+// Smart Install and auto-install.
+type CiscoOptions struct {
+	Options
+}
+
+var ciscoHumanizer = OptionHumanizer{
+	ValueHumanizer: func(code OptionCode, data []byte) fmt.Stringer {
+		var d OptionDecoder
+		switch code {
+		case CiscoWLCAddressesSubOption:
+			d = &IPs{}
+		case CiscoImageListFileSubOption:
+			var s String
+			d = &s
+		}
+		if d != nil && d.FromBytes(data) == nil {
+			return d
+		}
+		return OptionGeneric{data}
+	},
+	CodeHumanizer: func(c uint8) OptionCode {
+		return ciscoSubOptionCode(c)
+	},
+}
+
+// String prints the contained options using Cisco-specific option code
+// parsing.
+func (c CiscoOptions) String() string {
+	return "\n" + c.Options.ToString(ciscoHumanizer)
+}
+
+// FromBytes parses Cisco sub-options from data.
+func (c *CiscoOptions) FromBytes(data []byte) error {
+	c.Options = make(Options)
+	return c.Options.FromBytes(data)
+}
+
+// WLCAddresses returns the addresses of the wireless LAN controllers the
+// access point should join.
+func (c CiscoOptions) WLCAddresses() []net.IP {
+	return GetIPs(CiscoWLCAddressesSubOption, c.Options)
+}
+
+// ImageListFile returns the name of the Smart Install image list file.
+func (c CiscoOptions) ImageListFile() string {
+	return GetString(CiscoImageListFileSubOption, c.Options)
+}
+
+// OptCiscoVendorOptions returns a new Vendor Specific Information option
+// holding Cisco sub-options.
+func OptCiscoVendorOptions(o ...Option) Option {
+	return Option{Code: OptionVendorSpecificInformation, Value: CiscoOptions{OptionsFromList(o...)}}
+}
+
+// OptCiscoWLCAddresses returns a sub-option listing the wireless LAN
+// controllers access points should join.
+func OptCiscoWLCAddresses(ips ...net.IP) Option {
+	return Option{Code: CiscoWLCAddressesSubOption, Value: IPs(ips)}
+}
+
+// OptCiscoImageListFile returns a sub-option with the name of the Smart
+// Install image list file.
+func OptCiscoImageListFile(name string) Option {
+	return Option{Code: CiscoImageListFileSubOption, Value: String(name)}
+}
+
+type ciscoSubOptionCode uint8
+
+func (o ciscoSubOptionCode) Code() uint8 {
+	return uint8(o)
+}
+
+func (o ciscoSubOptionCode) String() string {
+	if s, ok := ciscoSubOptionCodeToString[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", o)
+}
+
+// Cisco vendor-specific sub-options.
+const (
+	CiscoImageListFileSubOption ciscoSubOptionCode = 5
+	CiscoWLCAddressesSubOption  ciscoSubOptionCode = 241
+)
+
+var ciscoSubOptionCodeToString = map[ciscoSubOptionCode]string{
+	CiscoImageListFileSubOption: "Cisco Image List File",
+	CiscoWLCAddressesSubOption:  "Cisco WLC Addresses",
+}
@@ -0,0 +1,36 @@
+package dhcpv4
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptCiscoVendorOptions(t *testing.T) {
+	opt := OptCiscoVendorOptions(OptCiscoWLCAddresses(net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}))
+	require.Equal(t, OptionVendorSpecificInformation, opt.Code)
+	require.Equal(t, []byte{241, 8, 10, 0, 0, 1, 10, 0, 0, 2}, opt.Value.ToBytes())
+
+	m, err := New(WithOption(OptClassIdentifier("Cisco AP c2700")), WithOption(opt))
+	require.NoError(t, err)
+	c, ok := m.VendorSpecific().(*CiscoOptions)
+	require.True(t, ok)
+	require.Equal(t, []net.IP{{10, 0, 0, 1}, {10, 0, 0, 2}}, c.WLCAddresses())
+	require.Equal(t, "\n    Cisco WLC Addresses: 10.0.0.1, 10.0.0.2\n", c.String())
+}
+
+func TestCiscoVIVS(t *testing.T) {
+	m, err := New(WithOption(OptVIVS(VIVSOption{
+		EntID:   iana.EnterpriseIDCiscoSystems,
+		Options: OptionsFromList(OptCiscoImageListFile("imagelist.txt")),
+	})))
+	require.NoError(t, err)
+	d, err := NewVendorRegistry().DecodeVIVS(m.VIVS()[0])
+	require.NoError(t, err)
+	c, ok := d.(*CiscoOptions)
+	require.True(t, ok)
+	require.Equal(t, "imagelist.txt", c.ImageListFile())
+	require.Nil(t, c.WLCAddresses())
+}
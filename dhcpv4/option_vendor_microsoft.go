@@ -0,0 +1,145 @@
+package dhcpv4
+
+import (
+	"fmt"
+
+	"github.com/u-root/uio/uio"
+)
+
+// MicrosoftOptions are the Microsoft vendor-specific sub-options carried in
+// the Vendor Specific Information option when the class identifier starts
+// with "MSFT", as described by [MS-DHCPE], Section 2.2.
+type MicrosoftOptions struct {
+	Options
+}
+
+var microsoftHumanizer = OptionHumanizer{
+	ValueHumanizer: func(code OptionCode, data []byte) fmt.Stringer {
+		switch code {
+		case MicrosoftDisableNetBIOSSubOption, MicrosoftReleaseOnShutdownSubOption, MicrosoftDefaultRouterMetricBaseSubOption:
+			var u microsoftUint32
+			if u.FromBytes(data) == nil {
+				return u
+			}
+		}
+		return OptionGeneric{data}
+	},
+	CodeHumanizer: func(c uint8) OptionCode {
+		return microsoftSubOptionCode(c)
+	},
+}
+
+// String prints the contained options using Microsoft-specific option code
+// parsing.
+func (m MicrosoftOptions) String() string {
+	return "\n" + m.Options.ToString(microsoftHumanizer)
+}
+
+// FromBytes parses Microsoft sub-options from data.
+func (m *MicrosoftOptions) FromBytes(data []byte) error {
+	m.Options = make(Options)
+	return m.Options.FromBytes(data)
+}
+
+func (m MicrosoftOptions) getUint32(code OptionCode) (uint32, bool) {
+	var u microsoftUint32
+	v := m.Options.Get(code)
+	if v == nil || u.FromBytes(v) != nil {
+		return 0, false
+	}
+	return uint32(u), true
+}
+
+// NetBIOSDisabled returns whether the server disables NetBIOS over TCP/IP on
+// the client.
+func (m MicrosoftOptions) NetBIOSDisabled() bool {
+	v, ok := m.getUint32(MicrosoftDisableNetBIOSSubOption)
+	return ok && v == microsoftDisableNetBIOS
+}
+
+// ReleaseOnShutdown returns whether the client should release its lease
+// when shutting down.
+func (m MicrosoftOptions) ReleaseOnShutdown() bool {
+	v, ok := m.getUint32(MicrosoftReleaseOnShutdownSubOption)
+	return ok && v == microsoftReleaseOnShutdown
+}
+
+// DefaultRouterMetricBase returns the metric base of the default routes, if
+// present.
+func (m MicrosoftOptions) DefaultRouterMetricBase() (uint32, bool) {
+	return m.getUint32(MicrosoftDefaultRouterMetricBaseSubOption)
+}
+
+// OptMicrosoftVendorOptions returns a new Vendor Specific Information option
+// holding Microsoft sub-options.
+func OptMicrosoftVendorOptions(o ...Option) Option {
+	return Option{Code: OptionVendorSpecificInformation, Value: MicrosoftOptions{OptionsFromList(o...)}}
+}
+
+// OptMicrosoftDisableNetBIOS returns a sub-option that disables NetBIOS over
+// TCP/IP on the client.
+func OptMicrosoftDisableNetBIOS() Option {
+	return Option{Code: MicrosoftDisableNetBIOSSubOption, Value: microsoftUint32(microsoftDisableNetBIOS)}
+}
+
+// OptMicrosoftReleaseOnShutdown returns a sub-option that makes the client
+// release its lease when shutting down.
+func OptMicrosoftReleaseOnShutdown() Option {
+	return Option{Code: MicrosoftReleaseOnShutdownSubOption, Value: microsoftUint32(microsoftReleaseOnShutdown)}
+}
+
+// OptMicrosoftDefaultRouterMetricBase returns a sub-option that sets the
+// metric base of the client's default routes.
+func OptMicrosoftDefaultRouterMetricBase(metric uint32) Option {
+	return Option{Code: MicrosoftDefaultRouterMetricBaseSubOption, Value: microsoftUint32(metric)}
+}
+
+// Values of the Microsoft sub-options that enable a feature.
+const (
+	microsoftDisableNetBIOS    = 2
+	microsoftReleaseOnShutdown = 1
+)
+
+type microsoftUint32 uint32
+
+func (u microsoftUint32) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write32(uint32(u))
+	return buf.Data()
+}
+
+func (u microsoftUint32) String() string {
+	return fmt.Sprintf("%d", uint32(u))
+}
+
+func (u *microsoftUint32) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	*u = microsoftUint32(buf.Read32())
+	return buf.FinError()
+}
+
+type microsoftSubOptionCode uint8
+
+func (o microsoftSubOptionCode) Code() uint8 {
+	return uint8(o)
+}
+
+func (o microsoftSubOptionCode) String() string {
+	if s, ok := microsoftSubOptionCodeToString[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", o)
+}
+
+// Microsoft vendor-specific sub-options, [MS-DHCPE] Section 2.2.
+const (
+	MicrosoftDisableNetBIOSSubOption          microsoftSubOptionCode = 1
+	MicrosoftReleaseOnShutdownSubOption       microsoftSubOptionCode = 2
+	MicrosoftDefaultRouterMetricBaseSubOption microsoftSubOptionCode = 3
+)
+
+var microsoftSubOptionCodeToString = map[microsoftSubOptionCode]string{
+	MicrosoftDisableNetBIOSSubOption:          "Microsoft Disable NetBIOS",
+	MicrosoftReleaseOnShutdownSubOption:       "Microsoft Release DHCP Lease On Shutdown",
+	MicrosoftDefaultRouterMetricBaseSubOption: "Microsoft Default Router Metric Base",
+}
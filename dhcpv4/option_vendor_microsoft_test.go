@@ -0,0 +1,42 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptMicrosoftVendorOptions(t *testing.T) {
+	opt := OptMicrosoftVendorOptions(
+		OptMicrosoftDisableNetBIOS(),
+		OptMicrosoftReleaseOnShutdown(),
+		OptMicrosoftDefaultRouterMetricBase(10),
+	)
+	require.Equal(t, OptionVendorSpecificInformation, opt.Code)
+	require.Equal(t, []byte{
+		1, 4, 0, 0, 0, 2,
+		2, 4, 0, 0, 0, 1,
+		3, 4, 0, 0, 0, 10,
+	}, opt.Value.ToBytes())
+
+	var m MicrosoftOptions
+	require.NoError(t, m.FromBytes(opt.Value.ToBytes()))
+	require.True(t, m.NetBIOSDisabled())
+	require.True(t, m.ReleaseOnShutdown())
+	metric, ok := m.DefaultRouterMetricBase()
+	require.True(t, ok)
+	require.Equal(t, uint32(10), metric)
+	require.Equal(t, "\n"+
+		"    Microsoft Disable NetBIOS: 2\n"+
+		"    Microsoft Release DHCP Lease On Shutdown: 1\n"+
+		"    Microsoft Default Router Metric Base: 10\n", m.String())
+}
+
+func TestMicrosoftOptionsDisabled(t *testing.T) {
+	var m MicrosoftOptions
+	require.NoError(t, m.FromBytes([]byte{1, 4, 0, 0, 0, 1, 2, 1, 1}))
+	require.False(t, m.NetBIOSDisabled())
+	require.False(t, m.ReleaseOnShutdown())
+	_, ok := m.DefaultRouterMetricBase()
+	require.False(t, ok)
+}
@@ -0,0 +1,437 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/u-root/uio/uio"
+)
+
+// PXEOptions are the PXE vendor-specific sub-options carried in the Vendor
+// Specific Information option when the class identifier starts with
+// "PXEClient".
+//
+// The sub-options are defined by the Preboot Execution Environment
+// specification, version 2.1, which RFC 4578 refers to.
+type PXEOptions struct {
+	Options
+}
+
+var pxeHumanizer = OptionHumanizer{
+	ValueHumanizer: func(code OptionCode, data []byte) fmt.Stringer {
+		var d OptionDecoder
+		switch code {
+		case PXEMTFTPIPSubOption, PXEDiscoveryMulticastAddrSubOption:
+			d = &IP{}
+		case PXEMTFTPClientPortSubOption, PXEMTFTPServerPortSubOption:
+			var u Uint16
+			d = &u
+		case PXEDiscoveryControlSubOption:
+			var c PXEDiscoveryControl
+			d = &c
+		case PXEBootServersSubOption:
+			d = &PXEBootServers{}
+		case PXEBootMenuSubOption:
+			d = &PXEBootMenu{}
+		case PXEMenuPromptSubOption:
+			d = &PXEMenuPrompt{}
+		case PXEBootItemSubOption:
+			d = &PXEBootItem{}
+		}
+		if d != nil && d.FromBytes(data) == nil {
+			return d
+		}
+		return OptionGeneric{data}
+	},
+	CodeHumanizer: func(c uint8) OptionCode {
+		return pxeSubOptionCode(c)
+	},
+}
+
+// String prints the contained options using PXE-specific option code parsing.
+func (p PXEOptions) String() string {
+	return "\n" + p.Options.ToString(pxeHumanizer)
+}
+
+// FromBytes parses PXE sub-options from data.
+func (p *PXEOptions) FromBytes(data []byte) error {
+	p.Options = make(Options)
+	return p.Options.FromBytes(data)
+}
+
+// DiscoveryControl returns the discovery control sub-option, if present.
+func (p PXEOptions) DiscoveryControl() (PXEDiscoveryControl, bool) {
+	v := p.Options.Get(PXEDiscoveryControlSubOption)
+	var c PXEDiscoveryControl
+	if v == nil || c.FromBytes(v) != nil {
+		return 0, false
+	}
+	return c, true
+}
+
+// DiscoveryMulticastAddr returns the boot server discovery multicast address,
+// if present.
+func (p PXEOptions) DiscoveryMulticastAddr() net.IP {
+	return GetIP(PXEDiscoveryMulticastAddrSubOption, p.Options)
+}
+
+// BootServers returns the boot servers sub-option, if present.
+func (p PXEOptions) BootServers() PXEBootServers {
+	v := p.Options.Get(PXEBootServersSubOption)
+	if v == nil {
+		return nil
+	}
+	var s PXEBootServers
+	if err := s.FromBytes(v); err != nil {
+		return nil
+	}
+	return s
+}
+
+// BootMenu returns the boot menu sub-option, if present.
+func (p PXEOptions) BootMenu() PXEBootMenu {
+	v := p.Options.Get(PXEBootMenuSubOption)
+	if v == nil {
+		return nil
+	}
+	var m PXEBootMenu
+	if err := m.FromBytes(v); err != nil {
+		return nil
+	}
+	return m
+}
+
+// MenuPrompt returns the menu prompt sub-option, if present.
+func (p PXEOptions) MenuPrompt() *PXEMenuPrompt {
+	v := p.Options.Get(PXEMenuPromptSubOption)
+	if v == nil {
+		return nil
+	}
+	var m PXEMenuPrompt
+	if err := m.FromBytes(v); err != nil {
+		return nil
+	}
+	return &m
+}
+
+// BootItem returns the boot item sub-option, if present.
+func (p PXEOptions) BootItem() *PXEBootItem {
+	v := p.Options.Get(PXEBootItemSubOption)
+	if v == nil {
+		return nil
+	}
+	var i PXEBootItem
+	if err := i.FromBytes(v); err != nil {
+		return nil
+	}
+	return &i
+}
+
+// OptPXEVendorOptions returns a new Vendor Specific Information option
+// holding PXE sub-options.
+//
+// PXE clients only process it if the reply also carries a class identifier
+// of "PXEClient".
+func OptPXEVendorOptions(o ...Option) Option {
+	return Option{Code: OptionVendorSpecificInformation, Value: PXEOptions{OptionsFromList(o...)}}
+}
+
+// OptPXEDiscoveryControl returns a new PXE discovery control sub-option.
+func OptPXEDiscoveryControl(c PXEDiscoveryControl) Option {
+	return Option{Code: PXEDiscoveryControlSubOption, Value: c}
+}
+
+// OptPXEDiscoveryMulticastAddr returns a new PXE boot server discovery
+// multicast address sub-option.
+func OptPXEDiscoveryMulticastAddr(ip net.IP) Option {
+	return Option{Code: PXEDiscoveryMulticastAddrSubOption, Value: IP(ip)}
+}
+
+// OptPXEBootServers returns a new PXE boot servers sub-option.
+func OptPXEBootServers(servers ...PXEBootServer) Option {
+	return Option{Code: PXEBootServersSubOption, Value: PXEBootServers(servers)}
+}
+
+// OptPXEBootMenu returns a new PXE boot menu sub-option.
+func OptPXEBootMenu(items ...PXEBootMenuItem) Option {
+	return Option{Code: PXEBootMenuSubOption, Value: PXEBootMenu(items)}
+}
+
+// OptPXEMenuPrompt returns a new PXE menu prompt sub-option.
+func OptPXEMenuPrompt(timeout uint8, prompt string) Option {
+	return Option{Code: PXEMenuPromptSubOption, Value: PXEMenuPrompt{Timeout: timeout, Prompt: prompt}}
+}
+
+// OptPXEBootItem returns a new PXE boot item sub-option.
+func OptPXEBootItem(typ, layer uint16) Option {
+	return Option{Code: PXEBootItemSubOption, Value: PXEBootItem{Type: typ, Layer: layer}}
+}
+
+// PXEDiscoveryControl is the value of the PXE discovery control sub-option.
+type PXEDiscoveryControl uint8
+
+// PXE discovery control bits.
+const (
+	// PXEDisableBroadcast disables broadcast discovery.
+	PXEDisableBroadcast PXEDiscoveryControl = 1 << 0
+	// PXEDisableMulticast disables multicast discovery.
+	PXEDisableMulticast PXEDiscoveryControl = 1 << 1
+	// PXEServerListOnly only uses and accepts servers in the boot servers
+	// sub-option.
+	PXEServerListOnly PXEDiscoveryControl = 1 << 2
+	// PXEBootFileOnly downloads the boot file from the offer without
+	// prompting or discovery, if a boot file name is present.
+	PXEBootFileOnly PXEDiscoveryControl = 1 << 3
+)
+
+// ToBytes returns a serialized stream of bytes for this option.
+func (c PXEDiscoveryControl) ToBytes() []byte {
+	return []byte{byte(c)}
+}
+
+// String returns a human-readable string for this option.
+func (c PXEDiscoveryControl) String() string {
+	var flags []string
+	for _, f := range []struct {
+		bit  PXEDiscoveryControl
+		name string
+	}{
+		{PXEDisableBroadcast, "DisableBroadcast"},
+		{PXEDisableMulticast, "DisableMulticast"},
+		{PXEServerListOnly, "ServerListOnly"},
+		{PXEBootFileOnly, "BootFileOnly"},
+	} {
+		if c&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	return fmt.Sprintf("%#02x [%s]", uint8(c), strings.Join(flags, " "))
+}
+
+// FromBytes parses the discovery control bits.
+func (c *PXEDiscoveryControl) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	*c = PXEDiscoveryControl(buf.Read8())
+	return buf.FinError()
+}
+
+// PXEBootServer is a boot server type and the addresses of the servers of
+// that type.
+type PXEBootServer struct {
+	Type  uint16
+	Addrs []net.IP
+}
+
+// PXEBootServers is the value of the PXE boot servers sub-option.
+type PXEBootServers []PXEBootServer
+
+// ToBytes returns a serialized stream of bytes for this option.
+//
+// Addresses that are not IPv4 addresses are left out. Servers with more than
+// 255 addresses are split into several entries of the same type.
+func (s PXEBootServers) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, srv := range s {
+		var addrs []net.IP
+		for _, ip := range srv.Addrs {
+			if ip4 := ip.To4(); ip4 != nil {
+				addrs = append(addrs, ip4)
+			}
+		}
+		for first := true; first || len(addrs) > 0; first = false {
+			n := len(addrs)
+			if n > math.MaxUint8 {
+				n = math.MaxUint8
+			}
+			buf.Write16(srv.Type)
+			buf.Write8(uint8(n))
+			for _, ip := range addrs[:n] {
+				buf.WriteBytes(ip)
+			}
+			addrs = addrs[n:]
+		}
+	}
+	return buf.Data()
+}
+
+// String returns a human-readable string for this option.
+func (s PXEBootServers) String() string {
+	var items []string
+	for _, srv := range s {
+		items = append(items, fmt.Sprintf("type %d: %s", srv.Type, IPs(srv.Addrs)))
+	}
+	return strings.Join(items, "; ")
+}
+
+// FromBytes parses a list of boot servers.
+func (s *PXEBootServers) FromBytes(data []byte) error {
+	*s = nil
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(3) {
+		srv := PXEBootServer{Type: buf.Read16()}
+		n := int(buf.Read8())
+		for i := 0; i < n; i++ {
+			srv.Addrs = append(srv.Addrs, net.IP(buf.CopyN(net.IPv4len)))
+		}
+		*s = append(*s, srv)
+	}
+	return buf.FinError()
+}
+
+// PXEBootMenuItem is a boot server type and its description in the boot
+// menu.
+type PXEBootMenuItem struct {
+	Type        uint16
+	Description string
+}
+
+// PXEBootMenu is the value of the PXE boot menu sub-option.
+type PXEBootMenu []PXEBootMenuItem
+
+// ToBytes returns a serialized stream of bytes for this option.
+//
+// Descriptions longer than 255 bytes are truncated.
+func (m PXEBootMenu) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, item := range m {
+		desc := truncateString(item.Description, math.MaxUint8)
+		buf.Write16(item.Type)
+		buf.Write8(uint8(len(desc)))
+		buf.WriteBytes([]byte(desc))
+	}
+	return buf.Data()
+}
+
+// String returns a human-readable string for this option.
+func (m PXEBootMenu) String() string {
+	var items []string
+	for _, item := range m {
+		items = append(items, fmt.Sprintf("%d: %q", item.Type, item.Description))
+	}
+	return strings.Join(items, ", ")
+}
+
+// FromBytes parses a boot menu.
+func (m *PXEBootMenu) FromBytes(data []byte) error {
+	*m = nil
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(3) {
+		item := PXEBootMenuItem{Type: buf.Read16()}
+		item.Description = string(buf.CopyN(int(buf.Read8())))
+		*m = append(*m, item)
+	}
+	return buf.FinError()
+}
+
+// PXEMenuPrompt is the value of the PXE menu prompt sub-option.
+type PXEMenuPrompt struct {
+	// Timeout is the number of seconds the prompt is shown for. 0 selects
+	// the first menu item immediately, 255 waits for a key press.
+	Timeout uint8
+	Prompt  string
+}
+
+// ToBytes returns a serialized stream of bytes for this option.
+//
+// Prompts longer than 255 bytes are truncated.
+func (m PXEMenuPrompt) ToBytes() []byte {
+	return append([]byte{m.Timeout}, truncateString(m.Prompt, math.MaxUint8)...)
+}
+
+// truncateString returns the longest prefix of s of at most n bytes that
+// does not split a UTF-8 encoded rune.
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// String returns a human-readable string for this option.
+func (m PXEMenuPrompt) String() string {
+	return fmt.Sprintf("%q (timeout %ds)", m.Prompt, m.Timeout)
+}
+
+// FromBytes parses a menu prompt.
+func (m *PXEMenuPrompt) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	m.Timeout = buf.Read8()
+	m.Prompt = string(buf.ReadAll())
+	return buf.FinError()
+}
+
+// PXEBootItem is the value of the PXE boot item sub-option: the boot server
+// type and layer selected by the client.
+type PXEBootItem struct {
+	Type  uint16
+	Layer uint16
+}
+
+// ToBytes returns a serialized stream of bytes for this option.
+func (i PXEBootItem) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(i.Type)
+	buf.Write16(i.Layer)
+	return buf.Data()
+}
+
+// String returns a human-readable string for this option.
+func (i PXEBootItem) String() string {
+	return fmt.Sprintf("type %d, layer %d", i.Type, i.Layer)
+}
+
+// FromBytes parses a boot item.
+func (i *PXEBootItem) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	i.Type = buf.Read16()
+	i.Layer = buf.Read16()
+	return buf.FinError()
+}
+
+type pxeSubOptionCode uint8
+
+func (o pxeSubOptionCode) Code() uint8 {
+	return uint8(o)
+}
+
+func (o pxeSubOptionCode) String() string {
+	if s, ok := pxeSubOptionCodeToString[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", o)
+}
+
+// PXE vendor-specific sub-options.
+const (
+	PXEMTFTPIPSubOption                pxeSubOptionCode = 1
+	PXEMTFTPClientPortSubOption        pxeSubOptionCode = 2
+	PXEMTFTPServerPortSubOption        pxeSubOptionCode = 3
+	PXEMTFTPTimeoutSubOption           pxeSubOptionCode = 4
+	PXEMTFTPDelaySubOption             pxeSubOptionCode = 5
+	PXEDiscoveryControlSubOption       pxeSubOptionCode = 6
+	PXEDiscoveryMulticastAddrSubOption pxeSubOptionCode = 7
+	PXEBootServersSubOption            pxeSubOptionCode = 8
+	PXEBootMenuSubOption               pxeSubOptionCode = 9
+	PXEMenuPromptSubOption             pxeSubOptionCode = 10
+	PXEBootItemSubOption               pxeSubOptionCode = 71
+)
+
+var pxeSubOptionCodeToString = map[pxeSubOptionCode]string{
+	PXEMTFTPIPSubOption:                "PXE MTFTP IP",
+	PXEMTFTPClientPortSubOption:        "PXE MTFTP Client Port",
+	PXEMTFTPServerPortSubOption:        "PXE MTFTP Server Port",
+	PXEMTFTPTimeoutSubOption:           "PXE MTFTP Timeout",
+	PXEMTFTPDelaySubOption:             "PXE MTFTP Delay",
+	PXEDiscoveryControlSubOption:       "PXE Discovery Control",
+	PXEDiscoveryMulticastAddrSubOption: "PXE Discovery Multicast Address",
+	PXEBootServersSubOption:            "PXE Boot Servers",
+	PXEBootMenuSubOption:               "PXE Boot Menu",
+	PXEMenuPromptSubOption:             "PXE Menu Prompt",
+	PXEBootItemSubOption:               "PXE Boot Item",
+}
@@ -0,0 +1,123 @@
+package dhcpv4
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var samplePXEOptionsRaw = []byte{
+	6, 1, 0x0b, // discovery control
+	7, 4, 224, 0, 1, 2, // multicast address
+	8, 11, 0, 1, 2, 10, 0, 0, 1, 10, 0, 0, 2, // boot servers
+	9, 12, 0, 0, 5, 'L', 'o', 'c', 'a', 'l', 0, 1, 1, 'N', // boot menu
+	10, 4, 5, 'P', 'X', 'E', // menu prompt
+	71, 4, 0, 1, 0, 0, // boot item
+}
+
+func TestPXEOptionsFromBytes(t *testing.T) {
+	var p PXEOptions
+	require.NoError(t, p.FromBytes(samplePXEOptionsRaw))
+
+	c, ok := p.DiscoveryControl()
+	require.True(t, ok)
+	require.Equal(t, PXEDisableBroadcast|PXEDisableMulticast|PXEBootFileOnly, c)
+	require.Equal(t, net.IP{224, 0, 1, 2}, p.DiscoveryMulticastAddr())
+	require.Equal(t, PXEBootServers{{Type: 1, Addrs: []net.IP{{10, 0, 0, 1}, {10, 0, 0, 2}}}}, p.BootServers())
+	require.Equal(t, PXEBootMenu{{Type: 0, Description: "Local"}, {Type: 1, Description: "N"}}, p.BootMenu())
+	require.Equal(t, &PXEMenuPrompt{Timeout: 5, Prompt: "PXE"}, p.MenuPrompt())
+	require.Equal(t, &PXEBootItem{Type: 1, Layer: 0}, p.BootItem())
+
+	require.Equal(t, samplePXEOptionsRaw, p.ToBytes())
+	require.Equal(t, "\n"+
+		"    PXE Discovery Control: 0x0b [DisableBroadcast DisableMulticast BootFileOnly]\n"+
+		"    PXE Discovery Multicast Address: 224.0.1.2\n"+
+		"    PXE Boot Servers: type 1: 10.0.0.1, 10.0.0.2\n"+
+		"    PXE Boot Menu: 0: \"Local\", 1: \"N\"\n"+
+		"    PXE Menu Prompt: \"PXE\" (timeout 5s)\n"+
+		"    PXE Boot Item: type 1, layer 0\n", p.String())
+}
+
+func TestPXEOptionsMissing(t *testing.T) {
+	var p PXEOptions
+	require.NoError(t, p.FromBytes([]byte{8, 3, 0, 1, 2, 9, 1, 0, 71, 1, 0, 10, 0}))
+	_, ok := p.DiscoveryControl()
+	require.False(t, ok)
+	require.Nil(t, p.DiscoveryMulticastAddr())
+	require.Nil(t, p.BootServers())
+	require.Nil(t, p.BootMenu())
+	require.Nil(t, p.MenuPrompt())
+	require.Nil(t, p.BootItem())
+
+	require.Error(t, p.FromBytes([]byte{6, 2, 0}))
+}
+
+func TestPXEDecodeFromPacket(t *testing.T) {
+	m, err := New(
+		WithOption(OptClassIdentifier("PXEClient:Arch:00007:UNDI:003016")),
+		WithGeneric(OptionVendorSpecificInformation, samplePXEOptionsRaw),
+	)
+	require.NoError(t, err)
+	p, ok := m.VendorSpecific().(*PXEOptions)
+	require.True(t, ok)
+	require.Equal(t, &PXEBootItem{Type: 1}, p.BootItem())
+	require.Contains(t, m.Summary(), "PXE Boot Menu: 0: \"Local\", 1: \"N\"")
+}
+
+func TestOptPXEVendorOptions(t *testing.T) {
+	opt := OptPXEVendorOptions(
+		OptPXEDiscoveryControl(PXEDisableBroadcast|PXEDisableMulticast|PXEBootFileOnly),
+		OptPXEDiscoveryMulticastAddr(net.IP{224, 0, 1, 2}),
+		OptPXEBootServers(PXEBootServer{Type: 1, Addrs: []net.IP{{10, 0, 0, 1}, {10, 0, 0, 2}}}),
+		OptPXEBootMenu(PXEBootMenuItem{Type: 0, Description: "Local"}, PXEBootMenuItem{Type: 1, Description: "N"}),
+		OptPXEMenuPrompt(5, "PXE"),
+		OptPXEBootItem(1, 0),
+	)
+	require.Equal(t, OptionVendorSpecificInformation, opt.Code)
+	require.Equal(t, samplePXEOptionsRaw, opt.Value.ToBytes())
+}
+
+func TestPXEBootServersLong(t *testing.T) {
+	var addrs []net.IP
+	for i := 0; i < 300; i++ {
+		addrs = append(addrs, net.IP{10, 0, byte(i >> 8), byte(i)})
+	}
+	s := PXEBootServers{
+		{Type: 1, Addrs: append(addrs, net.ParseIP("2001:db8::1"))},
+		{Type: 2, Addrs: []net.IP{{10, 1, 0, 1}}},
+	}
+	var got PXEBootServers
+	require.NoError(t, got.FromBytes(s.ToBytes()))
+	require.Equal(t, PXEBootServers{
+		{Type: 1, Addrs: addrs[:255]},
+		{Type: 1, Addrs: addrs[255:]},
+		{Type: 2, Addrs: []net.IP{{10, 1, 0, 1}}},
+	}, got)
+}
+
+func TestPXEBootMenuLong(t *testing.T) {
+	m := PXEBootMenu{
+		{Type: 0, Description: strings.Repeat("a", 256)},
+		{Type: 1, Description: strings.Repeat("b", 255)},
+	}
+	var got PXEBootMenu
+	require.NoError(t, got.FromBytes(m.ToBytes()))
+	require.Equal(t, PXEBootMenu{
+		{Type: 0, Description: strings.Repeat("a", 255)},
+		{Type: 1, Description: strings.Repeat("b", 255)},
+	}, got)
+
+	// Runes are not split.
+	m = PXEBootMenu{{Type: 2, Description: strings.Repeat("a", 254) + "é"}}
+	require.NoError(t, got.FromBytes(m.ToBytes()))
+	require.Equal(t, PXEBootMenu{{Type: 2, Description: strings.Repeat("a", 254)}}, got)
+}
+
+func TestPXEMenuPromptLong(t *testing.T) {
+	p := PXEMenuPrompt{Timeout: 10, Prompt: strings.Repeat("a", 300)}
+	var got PXEMenuPrompt
+	require.NoError(t, got.FromBytes(p.ToBytes()))
+	require.Equal(t, PXEMenuPrompt{Timeout: 10, Prompt: strings.Repeat("a", 255)}, got)
+}
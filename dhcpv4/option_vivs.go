@@ -0,0 +1,149 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/u-root/uio/uio"
+)
+
+// VIVSOption is one entry of the vendor-identifying vendor-specific
+// information option described by RFC 3925: the sub-options of a single
+// enterprise.
+type VIVSOption struct {
+	// EntID is the enterprise ID.
+	EntID   iana.EnterpriseID
+	Options Options
+}
+
+// String returns a human-readable string for this entry, decoding the
+// sub-options with the global vendor registry if possible.
+func (v VIVSOption) String() string {
+	var s string
+	if d, err := defaultVendorRegistry.DecodeVIVS(v); err == nil {
+		s = d.String()
+	} else {
+		s = v.Options.ToString(vendorHumanizer)
+		if s != "" {
+			s = "\n" + s
+		}
+	}
+	return fmt.Sprintf("%s (%d):%s", v.EntID, v.EntID, strings.TrimRight(s, "\n"))
+}
+
+// vendorHumanizer prints vendor-specific sub-options of unknown vendors.
+var vendorHumanizer = OptionHumanizer{
+	ValueHumanizer: func(code OptionCode, data []byte) fmt.Stringer {
+		return OptionGeneric{data}
+	},
+	CodeHumanizer: func(c uint8) OptionCode {
+		return GenericOptionCode(c)
+	},
+}
+
+// OptVIVS returns a new vendor-identifying vendor-specific information
+// option.
+//
+// The option is described by RFC 3925.
+func OptVIVS(entries ...VIVSOption) Option {
+	return Option{
+		Code:  OptionVendorIdentifyingVendorSpecific,
+		Value: VIVSOptions(entries),
+	}
+}
+
+// VIVSOptions implements encoding and decoding methods for the DHCP option
+// described in RFC 3925, Section 4.
+type VIVSOptions []VIVSOption
+
+// FromBytes parses data into v per RFC 3925. The sub-options of entries of
+// the same enterprise are merged.
+func (v *VIVSOptions) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(5) {
+		entID := iana.EnterpriseID(buf.Read32())
+		length := int(buf.Read8())
+		opts := v.Get(entID)
+		if opts == nil {
+			opts = make(Options)
+			*v = append(*v, VIVSOption{EntID: entID, Options: opts})
+		}
+		if err := opts.FromBytes(buf.Consume(length)); err != nil {
+			return err
+		}
+	}
+	return buf.FinError()
+}
+
+// ToBytes returns a serialized stream of bytes for this option.
+//
+// The data of an entry is limited to 255 bytes, so larger entries are split
+// into several entries of the same enterprise. Sub-options longer than the
+// space left in an entry are split as described in RFC 3396.
+func (v VIVSOptions) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, e := range v {
+		for _, data := range vivsData(e.Options) {
+			buf.Write32(uint32(e.EntID))
+			buf.Write8(uint8(len(data)))
+			buf.WriteBytes(data)
+		}
+	}
+	return buf.Data()
+}
+
+// vivsData returns the serialized sub-options o, split into chunks that fit
+// the data of an entry.
+func vivsData(o Options) [][]byte {
+	var chunks [][]byte
+	var data []byte
+	for _, c := range o.sortedKeys() {
+		code := uint8(c)
+		if code == optEnd || code == optPad {
+			continue
+		}
+		value := o[code]
+		for first := true; first || len(value) > 0; first = false {
+			// Start a new chunk if this one has no room for the
+			// header and at least one byte of the value.
+			need := 2
+			if len(value) > 0 {
+				need = 3
+			}
+			if len(data)+need > math.MaxUint8 {
+				chunks = append(chunks, data)
+				data = nil
+			}
+			n := len(value)
+			if left := math.MaxUint8 - len(data) - 2; n > left {
+				n = left
+			}
+			data = append(data, code, uint8(n))
+			data = append(data, value[:n]...)
+			value = value[n:]
+		}
+	}
+	return append(chunks, data)
+}
+
+// String returns a human-readable string for this option.
+func (v VIVSOptions) String() string {
+	var s strings.Builder
+	for _, e := range v {
+		s.WriteString("\n  ")
+		s.WriteString(strings.Replace(e.String(), "\n", "\n  ", -1))
+	}
+	return s.String()
+}
+
+// Get returns the sub-options of the given enterprise, or nil.
+func (v VIVSOptions) Get(id iana.EnterpriseID) Options {
+	for _, e := range v {
+		if e.EntID == id {
+			return e.Options
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dhcpv4
+
+import (
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	sampleVIVSOpt = VIVSOptions{
+		VIVSOption{EntID: iana.EnterpriseIDCiscoSystems, Options: OptionsFromList(OptCiscoImageListFile("list.txt"))},
+		VIVSOption{EntID: 1234, Options: OptionsFromList(OptGeneric(GenericOptionCode(1), []byte{0xab}))},
+	}
+	sampleVIVSOptRaw = []byte{
+		0x0, 0x0, 0x0, 0x9, // enterprise id 9
+		0xa,    // length
+		0x5, 8, // image list file
+		'l', 'i', 's', 't', '.', 't', 'x', 't',
+		0x0, 0x0, 0x4, 0xd2, // enterprise id 1234
+		0x3, // length
+		0x1, 0x1, 0xab,
+	}
+)
+
+func TestOptVIVSInterfaceMethods(t *testing.T) {
+	opt := OptVIVS(sampleVIVSOpt...)
+	require.Equal(t, OptionVendorIdentifyingVendorSpecific, opt.Code, "Code")
+	require.Equal(t, sampleVIVSOptRaw, opt.Value.ToBytes(), "ToBytes")
+	require.Equal(t, "Vendor-Identifying Vendor-Specific:\n\n"+
+		"  Cisco Systems (9):\n"+
+		"      Cisco Image List File: list.txt\n"+
+		"  Unknown (1234):\n"+
+		"      unknown (1): [171]",
+		opt.String())
+}
+
+func TestParseOptVIVS(t *testing.T) {
+	m, _ := New(WithGeneric(OptionVendorIdentifyingVendorSpecific, sampleVIVSOptRaw))
+	o := m.VIVS()
+	require.Equal(t, sampleVIVSOpt, o)
+	require.Equal(t, "list.txt", GetString(CiscoImageListFileSubOption, o.Get(iana.EnterpriseIDCiscoSystems)))
+	require.Nil(t, o.Get(iana.EnterpriseIDMicrosoft))
+
+	// Data len too long
+	data := append([]byte{}, sampleVIVSOptRaw...)
+	data[4] = 40
+	m, _ = New(WithGeneric(OptionVendorIdentifyingVendorSpecific, data))
+	require.Nil(t, m.VIVS())
+
+	// Malformed sub-options
+	m, _ = New(WithGeneric(OptionVendorIdentifyingVendorSpecific, []byte{0, 0, 0, 9, 2, 5, 8}))
+	require.Nil(t, m.VIVS())
+
+	m, _ = New()
+	require.Equal(t, VIVSOptions(nil), m.VIVS())
+}
+
+func TestOptVIVSLongEntry(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	v := VIVSOptions{
+		VIVSOption{EntID: 1234, Options: OptionsFromList(
+			OptGeneric(GenericOptionCode(1), []byte{0xab}),
+			OptGeneric(GenericOptionCode(2), long),
+			OptGeneric(GenericOptionCode(3), nil),
+		)},
+		VIVSOption{EntID: iana.EnterpriseIDCiscoSystems, Options: OptionsFromList(OptCiscoImageListFile("list.txt"))},
+	}
+	data := v.ToBytes()
+
+	// Every entry fits its data-len.
+	var entries []iana.EnterpriseID
+	for b := data; len(b) > 0; {
+		require.GreaterOrEqual(t, len(b), 5)
+		entries = append(entries, iana.EnterpriseID(uint32(b[0])<<24|uint32(b[1])<<16|uint32(b[2])<<8|uint32(b[3])))
+		n := int(b[4])
+		require.LessOrEqual(t, 5+n, len(b))
+		b = b[5+n:]
+	}
+	require.Equal(t, []iana.EnterpriseID{1234, 1234, iana.EnterpriseIDCiscoSystems}, entries)
+
+	var got VIVSOptions
+	require.NoError(t, got.FromBytes(data))
+	require.Equal(t, v, got)
+}
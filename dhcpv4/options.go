@@ -238,7 +238,12 @@ func (o Options) String() string {
 // Summary prints options in human-readable values.
 //
 // Summary uses vendorParser to interpret the OptionVendorSpecificInformation option.
+// If vendorDecoder is nil, the decoder registered in the global vendor
+// registry for the OptionClassIdentifier option is used, if any.
 func (o Options) Summary(vendorDecoder OptionDecoder) string {
+	if vendorDecoder == nil {
+		vendorDecoder = defaultVendorRegistry.decoderForClass(GetString(OptionClassIdentifier, o))
+	}
 	return o.ToString(OptionHumanizer{
 		ValueHumanizer: parserFor(vendorDecoder),
 		CodeHumanizer: func(c uint8) OptionCode {
@@ -311,7 +316,8 @@ type OptionDecoder interface {
 func getOption(code OptionCode, data []byte, vendorDecoder OptionDecoder) fmt.Stringer {
 	var d OptionDecoder
 	switch code {
-	case OptionRouter, OptionDomainNameServer, OptionNTPServers, OptionServerIdentifier:
+	case OptionRouter, OptionDomainNameServer, OptionNTPServers, OptionServerIdentifier,
+		OptionAssociatedIP:
 		d = &IPs{}
 
 	case OptionBroadcastAddress, OptionRequestedIPAddress:
@@ -320,6 +326,12 @@ func getOption(code OptionCode, data []byte, vendorDecoder OptionDecoder) fmt.St
 	case OptionClientSystemArchitectureType:
 		d = &iana.Archs{}
 
+	case OptionClientNetworkInterfaceIdentifier:
+		d = &NetworkInterfaceIdentifier{}
+
+	case OptionClientMachineIdentifier:
+		d = &MachineIdentifier{}
+
 	case OptionSubnetMask:
 		d = &IPMask{}
 
@@ -344,11 +356,11 @@ func getOption(code OptionCode, data []byte, vendorDecoder OptionDecoder) fmt.St
 
 	case OptionIPAddressLeaseTime, OptionRenewTimeValue,
 		OptionRebindingTimeValue, OptionIPv6OnlyPreferred, OptionArpCacheTimeout,
-		OptionTimeOffset:
+		OptionTimeOffset, OptionClientLastTransactionTime, OptionStartTimeOfState:
 		var dur Duration
 		d = &dur
 
-	case OptionMaximumDHCPMessageSize:
+	case OptionMaximumDHCPMessageSize, OptionInterfaceMTU:
 		var u Uint16
 		d = &u
 
@@ -367,11 +379,48 @@ func getOption(code OptionCode, data []byte, vendorDecoder OptionDecoder) fmt.St
 	case OptionVendorIdentifyingVendorClass:
 		d = &VIVCIdentifiers{}
 
+	case OptionVendorIdentifyingVendorSpecific:
+		d = &VIVSOptions{}
+
 	case OptionVendorSpecificInformation:
 		d = vendorDecoder
 
 	case OptionClasslessStaticRoute:
 		d = &Routes{}
+
+	case OptionFQDN:
+		d = &FQDN{}
+
+	case OptionAuthentication:
+		d = &Authentication{}
+
+	case OptionForcerenewNonceCapable:
+		d = &AuthAlgorithms{}
+
+	case OptionStatusCode:
+		d = &Status{}
+
+	case OptionBaseTime, OptionQueryStartTime, OptionQueryEndTime:
+		var t Time
+		d = &t
+
+	case OptionDHCPState:
+		var s DHCPState
+		d = &s
+
+	case OptionDataSource:
+		var s DataSource
+		d = &s
+
+	case OptionOPTION6RD:
+		d = &SixRD{}
+
+	case OptionV4DNR:
+		d = &DNRs{}
+
+	case OptionCaptivePortal:
+		var u CaptivePortalURI
+		d = &u
 	}
 	if d != nil && d.FromBytes(data) == nil {
 		return d
@@ -0,0 +1,296 @@
+package dhcpv4
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/u-root/uio/uio"
+)
+
+// RawOption is a single option instance exactly as it appeared on the wire.
+//
+// Pad options carry no length byte and their Data is always nil. For the End
+// option, Data holds whatever bytes followed it on the wire (usually zero
+// padding), so that packets can be reproduced byte-for-byte.
+type RawOption struct {
+	Code uint8
+	Data []byte
+}
+
+// String returns a human-readable representation of the raw option.
+func (r RawOption) String() string {
+	switch r.Code {
+	case optPad:
+		return OptionPad.String()
+	case optEnd:
+		return OptionEnd.String()
+	}
+	return dhcpHumanizer.Stringify(r.Code, r.Data)
+}
+
+// OrderedOptions is an alternative representation of a DHCPv4 option stream
+// that preserves the original wire order, duplicated options, the original
+// splitting of long options (RFC 3396), Pad options and trailing bytes.
+//
+// Unlike Options, which is keyed by option code and re-sorts options when
+// marshaling, OrderedOptions round-trips through FromBytes and ToBytes
+// byte-for-byte. This makes it suitable for fingerprinting and for proxies
+// that must forward packets unchanged.
+type OrderedOptions []RawOption
+
+// OrderedOptionsFromOptions converts o to an ordered option list, using the
+// same order and splitting that Options.Marshal would produce.
+func OrderedOptionsFromOptions(o Options) OrderedOptions {
+	buf := uio.NewBigEndianBuffer(nil)
+	o.Marshal(buf)
+	var oo OrderedOptions
+	// Marshal always produces a well-formed stream.
+	_ = oo.FromBytes(buf.Data())
+	return oo
+}
+
+// FromBytes parses a sequence of bytes into o, keeping every option instance
+// in wire order.
+//
+// The sequence should not contain the DHCP magic cookie. Parsing stops at
+// the End option; any bytes after it are kept in the End option's Data.
+func (o *OrderedOptions) FromBytes(data []byte) error {
+	*o = (*o)[:0]
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Len() >= 1 {
+		code := buf.Read8()
+		switch code {
+		case optPad:
+			*o = append(*o, RawOption{Code: code})
+			continue
+		case optEnd:
+			*o = append(*o, RawOption{Code: code, Data: buf.CopyN(buf.Len())})
+			return nil
+		}
+		length := int(buf.Read8())
+		data := buf.CopyN(length)
+		if data == nil {
+			return fmt.Errorf("error collecting options: %v", buf.Error())
+		}
+		*o = append(*o, RawOption{Code: code, Data: data})
+	}
+	return buf.Error()
+}
+
+// ToBytes serializes o exactly in the order it is stored.
+//
+// Option values longer than 255 bytes are split into consecutive instances
+// of the same option, as described in RFC 3396.
+func (o OrderedOptions) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, opt := range o {
+		switch opt.Code {
+		case optPad:
+			buf.Write8(opt.Code)
+			continue
+		case optEnd:
+			buf.Write8(opt.Code)
+			buf.WriteBytes(opt.Data)
+			continue
+		}
+		data := opt.Data
+		for {
+			n := len(data)
+			if n > math.MaxUint8 {
+				n = math.MaxUint8
+			}
+			buf.Write8(opt.Code)
+			buf.Write8(uint8(n))
+			buf.WriteBytes(data[:n])
+			data = data[n:]
+			if len(data) == 0 {
+				break
+			}
+		}
+	}
+	return buf.Data()
+}
+
+// String prints the options in wire order using DHCP-specified option codes.
+// Pad and End options are omitted.
+func (o OrderedOptions) String() string {
+	var s strings.Builder
+	for _, opt := range o {
+		if opt.Code == optPad || opt.Code == optEnd {
+			continue
+		}
+		optString := opt.String()
+		if strings.Contains(optString, "\n") {
+			optString = strings.Replace(optString, "\n  ", "\n      ", -1)
+		}
+		fmt.Fprintf(&s, "    %v\n", optString)
+	}
+	return s.String()
+}
+
+// Codes returns the code of every option instance in wire order, including
+// duplicates but excluding Pad and End.
+func (o OrderedOptions) Codes() []uint8 {
+	var codes []uint8
+	for _, opt := range o {
+		if opt.Code == optPad || opt.Code == optEnd {
+			continue
+		}
+		codes = append(codes, opt.Code)
+	}
+	return codes
+}
+
+// Has checks whether o contains at least one instance of the given option.
+func (o OrderedOptions) Has(code OptionCode) bool {
+	for _, opt := range o {
+		if opt.Code == code.Code() {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAll returns the values of every instance of the given option, in wire
+// order and without concatenating them.
+func (o OrderedOptions) GetAll(code OptionCode) [][]byte {
+	var ret [][]byte
+	for _, opt := range o {
+		if opt.Code == code.Code() {
+			ret = append(ret, opt.Data)
+		}
+	}
+	return ret
+}
+
+// Get returns the value of the given option. Multiple instances are
+// concatenated in wire order as required by RFC 3396, Section 7. Get returns
+// nil if the option is not present.
+func (o OrderedOptions) Get(code OptionCode) []byte {
+	all := o.GetAll(code)
+	if all == nil {
+		return nil
+	}
+	// Always return a non-nil slice so zero-length options are reported as
+	// present, like Options.Get does.
+	return append([]byte{}, bytes.Join(all, nil)...)
+}
+
+// Options merges o into an Options map, concatenating repeated options as
+// described by RFC 3396. The result can be used with all of the typed
+// accessors of this package (GetIPs, GetString, ...).
+func (o OrderedOptions) Options() Options {
+	opts := make(Options)
+	for _, opt := range o {
+		if opt.Code == optPad || opt.Code == optEnd {
+			continue
+		}
+		opts[opt.Code] = append(opts[opt.Code], opt.Data...)
+	}
+	return opts
+}
+
+// endIndex returns the position of the End option, or len(o).
+func (o OrderedOptions) endIndex() int {
+	for i, opt := range o {
+		if opt.Code == optEnd {
+			return i
+		}
+	}
+	return len(o)
+}
+
+// Add appends option to o, before the End option if there is one.
+//
+// Values longer than 255 bytes are split into multiple consecutive instances
+// as described by RFC 3396.
+func (o *OrderedOptions) Add(option Option) {
+	code := option.Code.Code()
+	data := option.Value.ToBytes()
+	var add []RawOption
+	if len(data) == 0 {
+		add = append(add, RawOption{Code: code, Data: []byte{}})
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > math.MaxUint8 {
+			n = math.MaxUint8
+		}
+		add = append(add, RawOption{Code: code, Data: data[:n:n]})
+		data = data[n:]
+	}
+
+	end := o.endIndex()
+	tail := append(add, (*o)[end:]...)
+	*o = append((*o)[:end:end], tail...)
+}
+
+// Del removes every instance of the given option.
+func (o *OrderedOptions) Del(code OptionCode) {
+	kept := (*o)[:0]
+	for _, opt := range *o {
+		if opt.Code != code.Code() {
+			kept = append(kept, opt)
+		}
+	}
+	*o = kept
+}
+
+// Update replaces all instances of option's code with option, keeping the
+// position of the first instance. If the option is not present, it is added
+// as with Add.
+func (o *OrderedOptions) Update(option Option) {
+	code := option.Code.Code()
+	first := -1
+	for i, opt := range *o {
+		if opt.Code == code {
+			first = i
+			break
+		}
+	}
+	if first == -1 {
+		o.Add(option)
+		return
+	}
+	rest := append(OrderedOptions{}, (*o)[first:]...)
+	rest.Del(option.Code)
+	// Reuse Add on a list that has no End so the replacement lands at the
+	// head of rest.
+	var repl OrderedOptions
+	repl.Add(option)
+	*o = append(append((*o)[:first], repl...), rest...)
+}
+
+// FromBytesOrdered decodes a DHCPv4 packet like FromBytes, but additionally
+// returns its options in wire order.
+//
+// The returned packet's Options are the RFC 3396 concatenation of the ordered
+// options, so all typed accessors work on it as usual. Use ToBytesOrdered to
+// serialize the packet with the original option layout.
+func FromBytesOrdered(q []byte) (*DHCPv4, OrderedOptions, error) {
+	p, data, err := fromBytesHeader(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	var oo OrderedOptions
+	if err := oo.FromBytes(data); err != nil {
+		return nil, nil, err
+	}
+	p.Options = oo.Options()
+	return p, oo, nil
+}
+
+// ToBytesOrdered writes the packet header of d followed by opts verbatim.
+//
+// d.Options is ignored. No End option or padding is added: opts is expected
+// to carry its own End option, as returned by FromBytesOrdered. Packets that
+// were parsed with FromBytesOrdered and whose server host name and boot file
+// name fields are NUL-padded are reproduced byte-for-byte.
+func (d *DHCPv4) ToBytesOrdered(opts OrderedOptions) []byte {
+	buf := uio.NewBigEndianBuffer(make([]byte, 0, minPacketLen))
+	d.marshalHeader(buf)
+	buf.WriteBytes(opts.ToBytes())
+	return buf.Data()
+}
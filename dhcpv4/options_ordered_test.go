@@ -0,0 +1,148 @@
+package dhcpv4
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedOptionsRoundTrip(t *testing.T) {
+	data := []byte{
+		53, 1, 1, // DHCP message type, out of order
+		0,                         // Pad
+		12, 4, 'h', 'o', 's', 't', // Host name
+		55, 2, 1, 3, // Parameter request list (first half)
+		55, 2, 6, 15, // Parameter request list (second half)
+		3, 4, 192, 168, 0, 1, // Router
+		255,     // End
+		0, 0, 0, // trailing padding
+	}
+	var oo OrderedOptions
+	require.NoError(t, oo.FromBytes(data))
+	require.Equal(t, data, oo.ToBytes())
+	require.Equal(t, []uint8{53, 12, 55, 55, 3}, oo.Codes())
+
+	require.True(t, oo.Has(OptionParameterRequestList))
+	require.False(t, oo.Has(OptionDomainName))
+	require.Equal(t, [][]byte{{1, 3}, {6, 15}}, oo.GetAll(OptionParameterRequestList))
+	require.Equal(t, []byte{1, 3, 6, 15}, oo.Get(OptionParameterRequestList))
+	require.Nil(t, oo.Get(OptionDomainName))
+
+	opts := oo.Options()
+	require.Equal(t, []net.IP{{192, 168, 0, 1}}, GetIPs(OptionRouter, opts))
+	require.Equal(t, "host", GetString(OptionHostName, opts))
+}
+
+func TestOrderedOptionsFromBytesErrors(t *testing.T) {
+	var oo OrderedOptions
+	require.Error(t, oo.FromBytes([]byte{12, 4, 'h'}))
+	require.Error(t, oo.FromBytes([]byte{12}))
+	require.NoError(t, oo.FromBytes(nil))
+	require.Empty(t, oo)
+}
+
+func TestOrderedOptionsAddSplits(t *testing.T) {
+	var oo OrderedOptions
+	require.NoError(t, oo.FromBytes([]byte{53, 1, 1, 255}))
+
+	long := bytes.Repeat([]byte{'a'}, 300)
+	oo.Add(OptGeneric(OptionUserClassInformation, long))
+	oo.Add(OptGeneric(OptionRapidCommit, nil))
+
+	require.Equal(t, []uint8{53, 77, 77, 80}, oo.Codes())
+	require.Len(t, oo.GetAll(OptionUserClassInformation)[0], 255)
+	require.Equal(t, long, oo.Get(OptionUserClassInformation))
+	require.Equal(t, []byte{}, oo.Get(OptionRapidCommit))
+	// End stays last.
+	require.Equal(t, uint8(255), oo[len(oo)-1].Code)
+}
+
+func TestOrderedOptionsToBytesSplits(t *testing.T) {
+	long := bytes.Repeat([]byte{'a'}, 300)
+	oo := OrderedOptions{{Code: 77, Data: long}, {Code: 80}, {Code: 255}}
+	b := oo.ToBytes()
+	require.Equal(t, []byte{77, 255}, b[:2])
+	require.Equal(t, []byte{77, 45}, b[257:259])
+	require.Equal(t, []byte{80, 0, 255}, b[304:])
+
+	var parsed OrderedOptions
+	require.NoError(t, parsed.FromBytes(b))
+	require.Equal(t, []uint8{77, 77, 80}, parsed.Codes())
+	require.Equal(t, long, parsed.Get(OptionUserClassInformation))
+}
+
+func TestOrderedOptionsUpdateAndDel(t *testing.T) {
+	var oo OrderedOptions
+	require.NoError(t, oo.FromBytes([]byte{
+		53, 1, 1,
+		12, 1, 'a',
+		3, 4, 10, 0, 0, 1,
+		12, 1, 'b',
+		255,
+	}))
+
+	oo.Update(OptHostName("new"))
+	require.Equal(t, []uint8{53, 12, 3}, oo.Codes())
+	require.Equal(t, []byte("new"), oo.Get(OptionHostName))
+
+	oo.Update(OptDomainName("example.org"))
+	require.Equal(t, []uint8{53, 12, 3, 15}, oo.Codes())
+
+	oo.Del(OptionRouter)
+	require.Equal(t, []uint8{53, 12, 15}, oo.Codes())
+	require.Equal(t, uint8(255), oo[len(oo)-1].Code)
+}
+
+func TestOrderedOptionsFromOptions(t *testing.T) {
+	opts := OptionsFromList(
+		OptRelayAgentInfo(OptGeneric(GenericOptionCode(1), []byte("eth0"))),
+		OptMessageType(MessageTypeAck),
+		OptRouter(net.IP{10, 0, 0, 1}),
+	)
+	oo := OrderedOptionsFromOptions(opts)
+	require.Equal(t, []uint8{3, 53, 82}, oo.Codes())
+	require.Equal(t, opts, oo.Options())
+}
+
+func TestOrderedOptionsString(t *testing.T) {
+	var oo OrderedOptions
+	require.NoError(t, oo.FromBytes([]byte{53, 1, 5, 0, 3, 4, 10, 0, 0, 1, 255}))
+	require.Equal(t, "    DHCP Message Type: ACK\n    Router: 10.0.0.1\n", oo.String())
+}
+
+func TestPacketOrderedRoundTrip(t *testing.T) {
+	d, err := New(WithMessageType(MessageTypeDiscover))
+	require.NoError(t, err)
+	header := d.ToBytes()[:minPacketLen+len(magicCookie)]
+
+	opts := []byte{
+		55, 3, 1, 3, 6,
+		53, 1, 1,
+		55, 1, 15,
+		60, 4, 'M', 'S', 'F', 'T',
+		255,
+		0, 0, 0, 0,
+	}
+	raw := append(append([]byte{}, header...), opts...)
+
+	p, oo, err := FromBytesOrdered(raw)
+	require.NoError(t, err)
+	require.Equal(t, raw, p.ToBytesOrdered(oo))
+
+	// Typed accessors see the RFC 3396 concatenation.
+	require.Equal(t, MessageTypeDiscover, p.MessageType())
+	require.Equal(t, "MSFT", p.ClassIdentifier())
+	require.Equal(t, OptionCodeList{
+		OptionSubnetMask, OptionRouter, OptionDomainNameServer, OptionDomainName,
+	}, p.ParameterRequestList())
+
+	// The canonical encoding merges and re-sorts the options.
+	require.NotEqual(t, raw, p.ToBytes())
+
+	_, _, err = FromBytesOrdered(raw[:10])
+	require.Error(t, err)
+	_, _, err = FromBytesOrdered(append(append([]byte{}, header...), 12, 5, 'a'))
+	require.Error(t, err)
+}
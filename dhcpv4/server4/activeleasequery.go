@@ -0,0 +1,431 @@
+package server4
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// activeQueueLen is the number of changes queued for a requestor. Requestors
+// that fall further behind are disconnected.
+const activeQueueLen = 256
+
+// DefaultActiveHistory is the default ActiveLeasequery.History.
+const DefaultActiveHistory = 24 * time.Hour
+
+// ActiveLeasequery streams the changes of the bindings of a LeaseStore to
+// requestors, as described in RFC 7724. It is a LeaseStore itself, wrapping
+// Store: the changes made through it, typically by an Allocator, are sent to
+// the requestors connected with Serve.
+//
+// Requestors may ask for the changes since a given time, to catch up after a
+// disconnection. Bindings that expire are not sent as they expire, including
+// the leases the Allocator stores as expired: requestors expire them from the
+// lease time of the last update.
+type ActiveLeasequery struct {
+	Store LeaseStore
+	// ServerID is the server identifier of the replies.
+	ServerID net.IP
+	// TLSConfig, if not nil, is used to secure the connections of the
+	// requestors that ask for it with a DHCPTLS message. Such requests are
+	// refused otherwise.
+	TLSConfig *tls.Config
+	// Allow, if not nil, returns whether to answer the query m sent by
+	// peer. Other queries are refused with the NotAllowed status.
+	Allow func(peer net.Addr, m *dhcpv4.DHCPv4) bool
+	// Logger, if not nil, logs failed connections.
+	Logger Printfer
+	// History is how long deleted bindings are remembered for the
+	// requestors that catch up. Queries that start earlier are answered
+	// with the DataMissing status. If zero, DefaultActiveHistory is used.
+	History time.Duration
+
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu sync.Mutex
+	// since is the time from which all the changes of the bindings are
+	// known.
+	since time.Time
+	// deleted holds the leases deleted from Store since then, by address,
+	// with the time of deletion as update time.
+	deleted map[string]*Lease
+	// deletions holds the same leases, and those deleted again or stored
+	// since, in order of deletion.
+	deletions []*Lease
+	subs      map[*activeSub]struct{}
+	closed    bool
+}
+
+// NewActiveLeasequery returns an ActiveLeasequery that wraps store.
+func NewActiveLeasequery(store LeaseStore, serverID net.IP) *ActiveLeasequery {
+	return &ActiveLeasequery{
+		Store:    store,
+		ServerID: serverID,
+		since:    time.Now(),
+		deleted:  make(map[string]*Lease),
+		subs:     make(map[*activeSub]struct{}),
+	}
+}
+
+func (a *ActiveLeasequery) time() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+func (a *ActiveLeasequery) printf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+	}
+}
+
+// Get implements LeaseStore.Get.
+func (a *ActiveLeasequery) Get(ip net.IP) (*Lease, error) {
+	return a.Store.Get(ip)
+}
+
+// GetByClient implements LeaseStore.GetByClient.
+func (a *ActiveLeasequery) GetByClient(clientID []byte, hwaddr net.HardwareAddr) (*Lease, error) {
+	return a.Store.GetByClient(clientID, hwaddr)
+}
+
+// GetByHardwareAddr implements LeaseStore.GetByHardwareAddr.
+func (a *ActiveLeasequery) GetByHardwareAddr(hwaddr net.HardwareAddr) ([]*Lease, error) {
+	return a.Store.GetByHardwareAddr(hwaddr)
+}
+
+// All implements LeaseStore.All.
+func (a *ActiveLeasequery) All() ([]*Lease, error) {
+	return a.Store.All()
+}
+
+// Put implements LeaseStore.Put, and sends the lease to the requestors.
+// Offers and expired leases are not sent.
+func (a *ActiveLeasequery) Put(l *Lease) error {
+	// The lock is held across the update of Store, so that the changes are
+	// sent in the order they are made.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.Store.Put(l); err != nil {
+		return err
+	}
+	delete(a.deleted, string(l.IP.To4()))
+	if l.State != LeaseStateOffered && l.State != LeaseStateExpired {
+		a.publish(l)
+	}
+	return nil
+}
+
+// Delete implements LeaseStore.Delete, and sends the address as unassigned
+// to the requestors.
+func (a *ActiveLeasequery) Delete(ip net.IP) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, err := a.Store.Get(ip)
+	if errors.Is(err, ErrNoLease) {
+		return a.Store.Delete(ip)
+	} else if err != nil {
+		return err
+	}
+	if err := a.Store.Delete(ip); err != nil {
+		return err
+	}
+	l := &Lease{IP: old.IP, Updated: a.time(), RelayAgentInfo: old.RelayAgentInfo}
+	a.forget(l.Updated)
+	a.deleted[string(l.IP.To4())] = l
+	a.deletions = append(a.deletions, l)
+	a.publish(l)
+	return nil
+}
+
+// forget drops the deleted bindings older than the history, and moves since
+// forward accordingly. It must be called with a.mu held.
+func (a *ActiveLeasequery) forget(now time.Time) {
+	history := a.History
+	if history == 0 {
+		history = DefaultActiveHistory
+	}
+	cutoff := now.Add(-history)
+	for len(a.deletions) > 0 && a.deletions[0].Updated.Before(cutoff) {
+		l := a.deletions[0]
+		a.deletions[0] = nil
+		a.deletions = a.deletions[1:]
+		if key := string(l.IP.To4()); a.deleted[key] == l {
+			delete(a.deleted, key)
+		}
+		if a.since.Before(cutoff) {
+			a.since = cutoff
+		}
+	}
+}
+
+// publish queues l for the requestors. It must be called with a.mu held.
+func (a *ActiveLeasequery) publish(l *Lease) {
+	for sub := range a.subs {
+		if !sub.match(l) {
+			continue
+		}
+		select {
+		case sub.updates <- l.Clone():
+		default:
+			sub.end(dhcpv4.StatusDataMissing)
+		}
+	}
+}
+
+// Close ends the active leasequeries, with the QueryTerminated status, and
+// refuses new ones. It does not close the listeners given to Serve.
+func (a *ActiveLeasequery) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	for sub := range a.subs {
+		sub.end(dhcpv4.StatusQueryTerminated)
+	}
+}
+
+// activeSub is an active leasequery.
+type activeSub struct {
+	match   func(*Lease) bool
+	updates chan *Lease
+	// done is closed, after status is set, when the query must end.
+	done   chan struct{}
+	status dhcpv4.StatusCode
+}
+
+// end ends the query with the given status. It must be called with the
+// ActiveLeasequery mutex held.
+func (s *activeSub) end(status dhcpv4.StatusCode) {
+	select {
+	case <-s.done:
+	default:
+		s.status = status
+		close(s.done)
+	}
+}
+
+func (a *ActiveLeasequery) subscribe(match func(*Lease) bool) (*activeSub, time.Time, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil, time.Time{}, false
+	}
+	a.forget(a.time())
+	sub := &activeSub{match: match, updates: make(chan *Lease, activeQueueLen), done: make(chan struct{})}
+	a.subs[sub] = struct{}{}
+	return sub, a.since, true
+}
+
+func (a *ActiveLeasequery) unsubscribe(sub *activeSub) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.subs, sub)
+}
+
+// Serve accepts active leasequery connections on l, and serves each of them
+// with ServeConn, until l fails. Servers listen on TCP port 67.
+func (a *ActiveLeasequery) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.ServeConn(conn)
+	}
+}
+
+// ServeConn serves an active leasequery connection, as described in RFC 7724,
+// Section 7, and closes it.
+//
+// The requestor may first secure the connection with a DHCPTLS message. Its
+// DHCPACTIVELEASEQUERY message is then answered with the bindings that
+// changed since its query start time, if any, followed by a
+// DHCPLEASEQUERYSTATUS message with the CatchUpComplete status. The changes
+// are then sent as they happen, until the requestor closes the connection
+// or the query ends with a DHCPLEASEQUERYSTATUS message.
+func (a *ActiveLeasequery) ServeConn(conn net.Conn) {
+	defer func() { conn.Close() }()
+	peer := conn.RemoteAddr()
+	secured := false
+	for {
+		m, err := dhcpv4.ReadTCPMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				a.printf("active leasequery: cannot read from %s: %v", peer, err)
+			}
+			return
+		}
+		if m.OpCode != dhcpv4.OpcodeBootRequest {
+			continue
+		}
+		switch t := m.MessageType(); {
+		case t == dhcpv4.MessageTypeTLS && !secured:
+			c, err := a.startTLS(conn, m)
+			if err != nil {
+				a.printf("active leasequery: cannot start TLS with %s: %v", peer, err)
+				return
+			}
+			conn, secured = c, a.TLSConfig != nil
+		case t == dhcpv4.MessageTypeActiveLeaseQuery:
+			if err := a.active(conn, peer, m); err != nil {
+				a.printf("active leasequery: cannot reply to %s: %v", peer, err)
+			}
+			return
+		default:
+			a.printf("active leasequery: ignoring %s from %s", t, peer)
+		}
+	}
+}
+
+// startTLS answers the DHCPTLS message m, and returns the connection to use
+// from then on.
+func (a *ActiveLeasequery) startTLS(conn net.Conn, m *dhcpv4.DHCPv4) (net.Conn, error) {
+	send := func(reply *dhcpv4.DHCPv4) error {
+		return dhcpv4.WriteTCPMessage(conn, reply)
+	}
+	if a.TLSConfig == nil {
+		return conn, sendStatus(send, a.ServerID, m, dhcpv4.MessageTypeTLS, dhcpv4.StatusTLSConnectionRefused, "")
+	}
+	if err := sendStatus(send, a.ServerID, m, dhcpv4.MessageTypeTLS, dhcpv4.StatusSuccess, ""); err != nil {
+		return nil, err
+	}
+	tc := tls.Server(conn, a.TLSConfig)
+	if err := tc.Handshake(); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// active answers the DHCPACTIVELEASEQUERY message m.
+func (a *ActiveLeasequery) active(conn net.Conn, peer net.Addr, m *dhcpv4.DHCPv4) error {
+	var mu sync.Mutex
+	send := func(reply *dhcpv4.DHCPv4) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return dhcpv4.WriteTCPMessage(conn, reply)
+	}
+	status := func(code dhcpv4.StatusCode, message string) error {
+		return sendStatus(send, a.ServerID, m, dhcpv4.MessageTypeLeaseQueryStatus, code, message)
+	}
+	if a.Allow != nil && !a.Allow(peer, m) {
+		a.printf("active leasequery: refusing query from %s", peer)
+		return status(dhcpv4.StatusNotAllowed, "")
+	}
+	match := activeFilter(m)
+	sub, since, ok := a.subscribe(match)
+	if !ok {
+		return status(dhcpv4.StatusQueryTerminated, "")
+	}
+	defer a.unsubscribe(sub)
+
+	// The connection is only read to answer other queries, and to find
+	// out when the requestor closes it.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			q, err := dhcpv4.ReadTCPMessage(conn)
+			if err != nil {
+				return
+			}
+			if q.OpCode == dhcpv4.OpcodeBootRequest && q.MessageType() == dhcpv4.MessageTypeActiveLeaseQuery {
+				_ = sendStatus(send, a.ServerID, q, dhcpv4.MessageTypeLeaseQueryStatus, dhcpv4.StatusConnectionActive, "")
+			}
+		}
+	}()
+
+	if start, ok := m.QueryStartTime(); ok {
+		// RFC 7724, Section 7.3.
+		if start.Before(since.Truncate(time.Second)) {
+			return status(dhcpv4.StatusDataMissing, fmt.Sprintf("no data before %s", since.UTC().Format(time.RFC3339)))
+		}
+		now := a.time()
+		changes, err := a.changesSince(start, now, match)
+		if err != nil {
+			return status(dhcpv4.StatusUnspecFail, err.Error())
+		}
+		for _, l := range changes {
+			reply, err := bindingReply(a.ServerID, m, l, now)
+			if err != nil {
+				return err
+			}
+			if err := send(reply); err != nil {
+				return err
+			}
+		}
+		if err := status(dhcpv4.StatusCatchUpComplete, ""); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case l := <-sub.updates:
+			reply, err := bindingReply(a.ServerID, m, l, a.time())
+			if err != nil {
+				return err
+			}
+			if err := send(reply); err != nil {
+				return err
+			}
+		case <-sub.done:
+			return status(sub.status, "")
+		case <-gone:
+			return nil
+		}
+	}
+}
+
+// changesSince returns the bindings that changed since start, and match,
+// ordered by time of change.
+func (a *ActiveLeasequery) changesSince(start, now time.Time, match func(*Lease) bool) ([]*Lease, error) {
+	leases, err := a.Store.All()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	for _, l := range a.deleted {
+		leases = append(leases, l.Clone())
+	}
+	a.mu.Unlock()
+	leases = filterLeases(leases, func(l *Lease) bool {
+		return l.State != LeaseStateOffered && !stateStart(l, now).Before(start) && match(l)
+	})
+	sort.SliceStable(leases, func(i, j int) bool {
+		ti, tj := stateStart(leases[i], now), stateStart(leases[j], now)
+		if ti.Equal(tj) {
+			return bytes.Compare(leases[i].IP.To4(), leases[j].IP.To4()) < 0
+		}
+		return ti.Before(tj)
+	})
+	return leases, nil
+}
+
+// activeFilter returns whether bindings match the active leasequery m: it may
+// be restricted to a relay identifier or remote ID, RFC 7724, Section 6.1.
+func activeFilter(m *dhcpv4.DHCPv4) func(*Lease) bool {
+	var relayID, remoteID []byte
+	if relay := m.RelayAgentInfo(); relay != nil {
+		relayID = relay.Get(dhcpv4.RelayIDSubOption)
+		remoteID = relay.Get(dhcpv4.AgentRemoteIDSubOption)
+	}
+	return func(l *Lease) bool {
+		switch {
+		case len(relayID) > 0:
+			return bytes.Equal(relayAgentSubOption(l, dhcpv4.RelayIDSubOption), relayID)
+		case len(remoteID) > 0:
+			return bytes.Equal(relayAgentSubOption(l, dhcpv4.AgentRemoteIDSubOption), remoteID)
+		}
+		return true
+	}
+}
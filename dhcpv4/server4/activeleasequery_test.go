@@ -0,0 +1,285 @@
+package server4
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/stretchr/testify/require"
+)
+
+// testTLSConfigs returns the TLS configurations of a server with a
+// self-signed certificate, and of a client trusting it.
+func testTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dhcp.example.com"},
+		DNSNames:     []string{"dhcp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool, ServerName: "dhcp.example.com"}
+	return server, client
+}
+
+// newTestActiveLeasequery returns an ActiveLeasequery configured by setup, if
+// not nil, and serving on a TCP listener, and the address of the listener.
+func newTestActiveLeasequery(t *testing.T, setup func(*ActiveLeasequery)) (*ActiveLeasequery, string) {
+	a := NewActiveLeasequery(NewMemoryLeaseStore(), net.IPv4(10, 0, 0, 1))
+	if setup != nil {
+		setup(a)
+	}
+	// Catch-up starts from whole seconds.
+	a.since = a.since.Add(-time.Second)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() { _ = a.Serve(l) }()
+	return a, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// runRequestor runs r on conn, until the returned function is called. The
+// function closes conn, and returns the error of Run.
+func runRequestor(t *testing.T, r *nclient4.ActiveRequestor, conn net.Conn, modifiers ...dhcpv4.Modifier) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- r.Run(ctx, conn, modifiers...) }()
+	return func() error {
+		cancel()
+		err := <-errc
+		conn.Close()
+		return err
+	}
+}
+
+// waitQueries waits until a has n active queries.
+func waitQueries(t *testing.T, a *ActiveLeasequery, n int) {
+	require.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return len(a.subs) == n
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func bound(ip net.IP, hwaddr net.HardwareAddr, now time.Time) *Lease {
+	return &Lease{IP: ip, HardwareAddr: hwaddr, State: LeaseStateBound, Updated: now, Expiry: now.Add(time.Hour)}
+}
+
+func TestActiveLeasequery(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, nil)
+	ip1, ip2, ip3 := net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 11), net.IPv4(10, 0, 0, 12)
+	require.NoError(t, a.Put(bound(ip1, mac1, time.Now())))
+
+	// The requestor loads the bindings with a bulk leasequery.
+	r := nclient4.NewActiveRequestor()
+	client, server := net.Pipe()
+	defer client.Close()
+	go NewLeasequery(a, a.ServerID).ServeConn(server)
+	query, err := dhcpv4.NewBulkLeasequery()
+	require.NoError(t, err)
+	require.NoError(t, nclient4.BulkLeasequery(context.Background(), client, query, r.Update))
+	require.NotNil(t, r.Get(ip1))
+	require.False(t, r.LastUpdate().IsZero())
+
+	stop := runRequestor(t, r, dial(t, addr))
+	require.Eventually(t, r.Synced, 5*time.Second, 10*time.Millisecond)
+	// Offers are not sent.
+	require.NoError(t, a.Put(&Lease{IP: ip3, HardwareAddr: mac2, State: LeaseStateOffered, Updated: time.Now(), Expiry: time.Now().Add(time.Minute)}))
+	require.NoError(t, a.Put(bound(ip2, mac2, time.Now())))
+	require.Eventually(t, func() bool { return r.Get(ip2) != nil }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, mac2, r.Get(ip2).ClientHWAddr)
+	require.Nil(t, r.Get(ip3))
+
+	l := bound(ip1, mac1, time.Now())
+	l.State = LeaseStateReleased
+	require.NoError(t, a.Put(l))
+	require.Eventually(t, func() bool { return r.Get(ip1) == nil }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, context.Canceled, stop())
+
+	// The changes made while disconnected are caught up.
+	require.NoError(t, a.Delete(ip2))
+	require.NoError(t, a.Put(bound(ip1, mac1, time.Now())))
+	stop = runRequestor(t, r, dial(t, addr))
+	require.Eventually(t, func() bool {
+		return r.Synced() && r.Get(ip1) != nil && r.Get(ip2) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, r.Bindings(), 1)
+	require.Equal(t, context.Canceled, stop())
+	waitQueries(t, a, 0)
+
+	// Other queries on an active connection are refused.
+	conn := dial(t, addr)
+	for i := 0; i < 2; i++ {
+		query, err := dhcpv4.NewActiveLeasequery()
+		require.NoError(t, err)
+		require.NoError(t, dhcpv4.WriteTCPMessage(conn, query))
+	}
+	m, err := dhcpv4.ReadTCPMessage(conn)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeLeaseQueryStatus, m.MessageType())
+	require.Equal(t, dhcpv4.StatusConnectionActive, m.Status().Code)
+
+	// Queries are ended when the server closes.
+	r = nclient4.NewActiveRequestor()
+	errc := make(chan error, 1)
+	go func() { errc <- r.Run(context.Background(), dial(t, addr)) }()
+	waitQueries(t, a, 2)
+	a.Close()
+	var status *nclient4.ErrLeasequeryStatus
+	err = <-errc
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusQueryTerminated, status.Status.Code)
+}
+
+func TestActiveLeasequeryDataMissing(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, nil)
+	r := nclient4.NewActiveRequestor()
+	old, err := dhcpv4.New(
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseUnassigned),
+		dhcpv4.WithOption(dhcpv4.OptBaseTime(time.Now().Add(-time.Hour))),
+	)
+	require.NoError(t, err)
+	require.NoError(t, r.Update(old))
+
+	err = r.Run(context.Background(), dial(t, addr))
+	var status *nclient4.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusDataMissing, status.Status.Code)
+
+	r.Reset()
+	require.True(t, r.LastUpdate().IsZero())
+	require.NoError(t, a.Put(bound(net.IPv4(10, 0, 0, 10), mac1, time.Now())))
+	// Without a previous update, only the new changes are sent.
+	stop := runRequestor(t, r, dial(t, addr))
+	waitQueries(t, a, 1)
+	require.True(t, r.Synced())
+	require.NoError(t, a.Put(bound(net.IPv4(10, 0, 0, 11), mac1, time.Now())))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, r.Get(net.IPv4(10, 0, 0, 10)))
+	require.Equal(t, context.Canceled, stop())
+}
+
+func TestActiveLeasequeryHistory(t *testing.T) {
+	a := NewActiveLeasequery(NewMemoryLeaseStore(), net.IPv4(10, 0, 0, 1))
+	a.History = time.Hour
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	ip1, ip2 := net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 11)
+	for _, ip := range []net.IP{ip1, ip2} {
+		require.NoError(t, a.Put(bound(ip, mac1, now)))
+		require.NoError(t, a.Delete(ip))
+	}
+	// ip2 is deleted again later, its first deletion is not forgotten
+	// alone.
+	now = now.Add(30 * time.Minute)
+	require.NoError(t, a.Put(bound(ip2, mac1, now)))
+	require.NoError(t, a.Delete(ip2))
+	require.Len(t, a.deleted, 2)
+
+	now = now.Add(45 * time.Minute)
+	sub, since, ok := a.subscribe(func(*Lease) bool { return true })
+	require.True(t, ok)
+	a.unsubscribe(sub)
+	require.Equal(t, now.Add(-time.Hour), since)
+	require.Len(t, a.deleted, 1)
+	require.NotNil(t, a.deleted[string(ip2.To4())])
+	require.Len(t, a.deletions, 1)
+}
+
+func TestActiveLeasequeryExpiry(t *testing.T) {
+	a := NewActiveLeasequery(NewMemoryLeaseStore(), net.IPv4(10, 0, 0, 1))
+	alloc, err := NewAllocator(net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 20), a)
+	require.NoError(t, err)
+	now := time.Now()
+	alloc.now = func() time.Time { return now }
+	sub, _, ok := a.subscribe(func(*Lease) bool { return true })
+	require.True(t, ok)
+	defer a.unsubscribe(sub)
+
+	_, err = alloc.Bind(newDiscover(t, mac1), net.IPv4(10, 0, 0, 10))
+	require.NoError(t, err)
+	require.Len(t, sub.updates, 1)
+	require.Equal(t, LeaseStateBound, (<-sub.updates).State)
+
+	// Requestors expire the binding themselves.
+	now = now.Add(DefaultLeaseTime)
+	n, err := alloc.ExpireLeases()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	require.Empty(t, sub.updates)
+}
+
+func TestActiveLeasequeryFilter(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, func(a *ActiveLeasequery) {
+		a.Allow = func(peer net.Addr, m *dhcpv4.DHCPv4) bool {
+			return m.RelayAgentInfo() != nil
+		}
+	})
+	err := nclient4.NewActiveRequestor().Run(context.Background(), dial(t, addr))
+	var status *nclient4.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusNotAllowed, status.Status.Code)
+
+	r := nclient4.NewActiveRequestor()
+	stop := runRequestor(t, r, dial(t, addr),
+		dhcpv4.WithOption(dhcpv4.OptRelayAgentInfo(dhcpv4.OptGeneric(dhcpv4.RelayIDSubOption, []byte("relay1")))))
+	l := bound(net.IPv4(10, 0, 0, 10), mac1, time.Now())
+	l.RelayAgentInfo = testRelayAgentInfo
+	waitQueries(t, a, 1)
+	require.NoError(t, a.Put(bound(net.IPv4(10, 0, 0, 11), mac1, time.Now())))
+	require.NoError(t, a.Put(l))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.NotNil(t, r.Get(l.IP))
+	require.Equal(t, context.Canceled, stop())
+}
+
+func TestActiveLeasequeryTLS(t *testing.T) {
+	serverConfig, clientConfig := testTLSConfigs(t)
+	ctx := context.Background()
+
+	// TLS is refused without a configuration.
+	_, addr := newTestActiveLeasequery(t, nil)
+	_, err := nclient4.StartTLS(ctx, dial(t, addr), clientConfig)
+	var status *nclient4.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusTLSConnectionRefused, status.Status.Code)
+
+	a, addr := newTestActiveLeasequery(t, func(a *ActiveLeasequery) { a.TLSConfig = serverConfig })
+	conn, err := nclient4.StartTLS(ctx, dial(t, addr), clientConfig)
+	require.NoError(t, err)
+	r := nclient4.NewActiveRequestor()
+	stop := runRequestor(t, r, conn)
+	waitQueries(t, a, 1)
+	require.NoError(t, a.Put(bound(net.IPv4(10, 0, 0, 10), mac1, time.Now())))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, context.Canceled, stop())
+}
@@ -0,0 +1,521 @@
+package server4
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// Default Allocator settings.
+const (
+	DefaultLeaseTime = time.Hour
+	DefaultOfferTime = 30 * time.Second
+	DefaultMaxChecks = 4
+)
+
+var (
+	// ErrNoAddressAvailable is returned by Allocator.Offer when the pool is
+	// exhausted.
+	ErrNoAddressAvailable = errors.New("no address available")
+	// ErrAddressUnavailable is returned by Allocator.Bind when the
+	// requested address cannot be leased to the client.
+	ErrAddressUnavailable = errors.New("requested address is not available")
+)
+
+// Allocator leases addresses from a range to clients, and keeps track of the
+// leases in a LeaseStore.
+//
+// If Checker is set, addresses are checked before they are offered, and
+// those in use are marked abandoned: they are not offered again until their
+// abandonment expires after LeaseTime, or the pool is otherwise exhausted.
+type Allocator struct {
+	// LeaseTime is the lease duration. If zero, DefaultLeaseTime is used.
+	LeaseTime time.Duration
+	// OfferTime is how long offered addresses are reserved. If zero,
+	// DefaultOfferTime is used.
+	OfferTime time.Duration
+	// Checker, if not nil, checks addresses before they are offered.
+	Checker ConflictChecker
+	// MaxChecks is the number of addresses checked for one DISCOVER. Once
+	// it is reached, the next address is offered without a check, so that
+	// a pool full of hosts that answer does not delay offers. If zero,
+	// DefaultMaxChecks is used.
+	MaxChecks int
+	// Hooks are notified of lease changes.
+	Hooks []Hook
+	// Logger, if not nil, logs the addresses that Checker fails to check.
+	Logger Printfer
+
+	store      LeaseStore
+	start, end uint32
+
+	mu   sync.Mutex
+	next uint32
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// Hook is notified by an Allocator when leases change.
+type Hook interface {
+	// LeaseChanged is called after l was bound, released, declined,
+	// abandoned or expired. req is the message that caused the change, or
+	// nil.
+	LeaseChanged(req *dhcpv4.DHCPv4, l *Lease)
+}
+
+// ReplyHook is a Hook that also amends the replies sent by
+// Allocator.Handler.
+type ReplyHook interface {
+	Hook
+	// Reply is called with each OFFER and ACK for l before it is sent.
+	Reply(req, reply *dhcpv4.DHCPv4, l *Lease)
+}
+
+func (a *Allocator) printf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+	}
+}
+
+func (a *Allocator) notify(req *dhcpv4.DHCPv4, l *Lease) {
+	for _, h := range a.Hooks {
+		h.LeaseChanged(req, l.Clone())
+	}
+}
+
+// NewAllocator returns an Allocator for the addresses from start to end,
+// inclusive, that stores leases in store. If store is nil, a
+// MemoryLeaseStore is used.
+func NewAllocator(start, end net.IP, store LeaseStore) (*Allocator, error) {
+	if start.To4() == nil || end.To4() == nil {
+		return nil, errors.New("range must consist of IPv4 addresses")
+	}
+	s, e := ipToUint32(start), ipToUint32(end)
+	if s > e {
+		return nil, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+	if store == nil {
+		store = NewMemoryLeaseStore()
+	}
+	return &Allocator{store: store, start: s, end: e, next: s, now: time.Now}, nil
+}
+
+// Store returns the lease store of a.
+func (a *Allocator) Store() LeaseStore {
+	return a.store
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	return binary.BigEndian.Uint32(ip.To4())
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+func (a *Allocator) maxChecks() int {
+	if a.MaxChecks == 0 {
+		return DefaultMaxChecks
+	}
+	return a.MaxChecks
+}
+
+func (a *Allocator) leaseTime() time.Duration {
+	if a.LeaseTime == 0 {
+		return DefaultLeaseTime
+	}
+	return a.LeaseTime
+}
+
+func (a *Allocator) offerTime() time.Duration {
+	if a.OfferTime == 0 {
+		return DefaultOfferTime
+	}
+	return a.OfferTime
+}
+
+// Contains returns whether ip is in the range of a.
+func (a *Allocator) Contains(ip net.IP) bool {
+	if ip.To4() == nil {
+		return false
+	}
+	n := ipToUint32(ip)
+	return n >= a.start && n <= a.end
+}
+
+func clientOf(m *dhcpv4.DHCPv4) ([]byte, net.HardwareAddr) {
+	return m.Options.Get(dhcpv4.OptionClientIdentifier), m.ClientHWAddr
+}
+
+// available returns whether the address of l, which may be nil, can be
+// leased to the client.
+func available(l *Lease, now time.Time, clientID []byte, hwaddr net.HardwareAddr) bool {
+	switch {
+	case l == nil:
+		return true
+	case l.State == LeaseStateAbandoned:
+		return !now.Before(l.Expiry)
+	default:
+		return !l.Active(now) || l.BelongsTo(clientID, hwaddr)
+	}
+}
+
+func (a *Allocator) get(ip net.IP) (*Lease, error) {
+	l, err := a.store.Get(ip)
+	if errors.Is(err, ErrNoLease) {
+		return nil, nil
+	}
+	return l, err
+}
+
+// candidate returns an address to offer to the client, or nil. Addresses in
+// skip are not considered.
+func (a *Allocator) candidate(now time.Time, clientID []byte, hwaddr net.HardwareAddr, requested net.IP, skip map[uint32]bool) (net.IP, error) {
+	// Prefer the previous address of the client, then the one it asks
+	// for, as RFC 2131, Section 4.3.1 suggests.
+	if l, err := a.store.GetByClient(clientID, hwaddr); err == nil {
+		if a.Contains(l.IP) && !skip[ipToUint32(l.IP)] && l.State != LeaseStateAbandoned {
+			return l.IP, nil
+		}
+	} else if !errors.Is(err, ErrNoLease) {
+		return nil, err
+	}
+	if a.Contains(requested) && !skip[ipToUint32(requested)] {
+		l, err := a.get(requested)
+		if err != nil {
+			return nil, err
+		}
+		if available(l, now, clientID, hwaddr) {
+			return requested.To4(), nil
+		}
+	}
+
+	var abandoned *Lease
+	n := a.next
+	for i := uint64(0); i <= uint64(a.end-a.start); i++ {
+		ip := uint32ToIP(n)
+		cur := n
+		if n == a.end {
+			n = a.start
+		} else {
+			n++
+		}
+		if skip[cur] {
+			continue
+		}
+		l, err := a.get(ip)
+		if err != nil {
+			return nil, err
+		}
+		if available(l, now, clientID, hwaddr) {
+			a.next = n
+			return ip, nil
+		}
+		if l.State == LeaseStateAbandoned && (abandoned == nil || l.Updated.Before(abandoned.Updated)) {
+			abandoned = l
+		}
+	}
+	// The pool is exhausted, reclaim the oldest abandoned address.
+	if abandoned != nil {
+		return abandoned.IP, nil
+	}
+	return nil, nil
+}
+
+// Offer selects an address for the client that sent the DISCOVER req, and
+// reserves it for OfferTime.
+func (a *Allocator) Offer(ctx context.Context, req *dhcpv4.DHCPv4) (*Lease, error) {
+	clientID, hwaddr := clientOf(req)
+	skip := make(map[uint32]bool)
+	for checks := 0; ; checks++ {
+		a.mu.Lock()
+		now := a.now()
+		ip, err := a.candidate(now, clientID, hwaddr, req.RequestedIPAddress(), skip)
+		if err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+		if ip == nil {
+			a.mu.Unlock()
+			return nil, ErrNoAddressAvailable
+		}
+		prev, err := a.get(ip)
+		if err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+		lease := &Lease{
+			IP:           ip,
+			HardwareAddr: hwaddr,
+			ClientID:     clientID,
+			Hostname:     req.HostName(),
+			State:        LeaseStateOffered,
+			Updated:      now,
+			Expiry:       now.Add(a.offerTime()),
+		}
+		if prev != nil && prev.BelongsTo(clientID, hwaddr) && prev.Active(now) {
+			// Keep the binding of a client that is already bound.
+			lease.State = prev.State
+			if prev.Expiry.After(lease.Expiry) {
+				lease.Expiry = prev.Expiry
+			}
+		}
+		// Reserve the address while it is checked.
+		if err := a.store.Put(lease); err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+		a.mu.Unlock()
+
+		// Addresses the client is bound to are in use by the client
+		// itself.
+		if a.Checker == nil || lease.State == LeaseStateBound {
+			return lease, nil
+		}
+		if checks >= a.maxChecks() {
+			a.printf("allocator: %d addresses in use, offering %s without a check", checks, ip)
+			return lease, nil
+		}
+		inUse, err := a.Checker.InUse(ctx, ip)
+		if err != nil {
+			// Like ISC dhcpd, treat addresses that cannot be checked as
+			// free.
+			a.printf("allocator: cannot check %s, offering it: %v", ip, err)
+			return lease, nil
+		}
+		if !inUse {
+			return lease, nil
+		}
+		if err := a.Abandon(ip); err != nil {
+			return nil, err
+		}
+		skip[ipToUint32(ip)] = true
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Bind leases ip to the client that sent the REQUEST req.
+func (a *Allocator) Bind(req *dhcpv4.DHCPv4, ip net.IP) (*Lease, error) {
+	if !a.Contains(ip) {
+		return nil, ErrAddressUnavailable
+	}
+	lease, err := a.bind(req, ip)
+	if err != nil {
+		return nil, err
+	}
+	a.notify(req, lease)
+	return lease, nil
+}
+
+func (a *Allocator) bind(req *dhcpv4.DHCPv4, ip net.IP) (*Lease, error) {
+	clientID, hwaddr := clientOf(req)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := a.now()
+	prev, err := a.get(ip)
+	if err != nil {
+		return nil, err
+	}
+	if !available(prev, now, clientID, hwaddr) {
+		return nil, ErrAddressUnavailable
+	}
+	lease := &Lease{
+		IP:           ip.To4(),
+		HardwareAddr: hwaddr,
+		ClientID:     clientID,
+		Hostname:     req.HostName(),
+		State:        LeaseStateBound,
+		Updated:      now,
+		Expiry:       now.Add(a.leaseTime()),
+		// Kept for leasequery replies, RFC 4388, Section 6.4.1.
+		RelayAgentInfo: req.Options.Get(dhcpv4.OptionRelayAgentInformation),
+	}
+	if err := a.store.Put(lease); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// update changes the state of the lease of ip, if it belongs to the client
+// that sent m.
+func (a *Allocator) update(m *dhcpv4.DHCPv4, ip net.IP, state LeaseState, expiry time.Duration) (*Lease, error) {
+	clientID, hwaddr := clientOf(m)
+	a.mu.Lock()
+	l, err := a.store.Get(ip)
+	if err != nil {
+		a.mu.Unlock()
+		return nil, err
+	}
+	if !l.BelongsTo(clientID, hwaddr) || !l.Active(a.now()) {
+		a.mu.Unlock()
+		return nil, ErrNoLease
+	}
+	l.State = state
+	l.Updated = a.now()
+	l.Expiry = l.Updated.Add(expiry)
+	err = a.store.Put(l)
+	a.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	a.notify(m, l)
+	return l, nil
+}
+
+// Release ends the lease of the client that sent the RELEASE req.
+func (a *Allocator) Release(req *dhcpv4.DHCPv4) (*Lease, error) {
+	return a.update(req, req.ClientIPAddr, LeaseStateReleased, 0)
+}
+
+// Decline marks the address declined by the client that sent the DECLINE
+// req as abandoned.
+func (a *Allocator) Decline(req *dhcpv4.DHCPv4) (*Lease, error) {
+	return a.update(req, req.RequestedIPAddress(), LeaseStateAbandoned, a.leaseTime())
+}
+
+// Abandon marks ip as in use by an unknown host, for LeaseTime.
+func (a *Allocator) Abandon(ip net.IP) error {
+	a.mu.Lock()
+	now := a.now()
+	l := &Lease{
+		IP:      ip,
+		State:   LeaseStateAbandoned,
+		Updated: now,
+		Expiry:  now.Add(a.leaseTime()),
+	}
+	err := a.store.Put(l)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	a.notify(nil, l)
+	return nil
+}
+
+// ExpireLeases marks the leases and offers that ran out as expired, notifies
+// the hooks of the expired leases, and returns how many leases expired. It
+// should be called periodically.
+func (a *Allocator) ExpireLeases() (int, error) {
+	a.mu.Lock()
+	leases, err := a.store.All()
+	if err != nil {
+		a.mu.Unlock()
+		return 0, err
+	}
+	now := a.now()
+	var expired []*Lease
+	for _, l := range leases {
+		if (l.State != LeaseStateOffered && l.State != LeaseStateBound) || now.Before(l.Expiry) {
+			continue
+		}
+		bound := l.State == LeaseStateBound
+		l.State = LeaseStateExpired
+		if err := a.store.Put(l); err != nil {
+			a.mu.Unlock()
+			return 0, err
+		}
+		if bound {
+			expired = append(expired, l)
+		}
+	}
+	a.mu.Unlock()
+	for _, l := range expired {
+		a.notify(nil, l)
+	}
+	return len(expired), nil
+}
+
+// Handler returns a Handler that answers DISCOVER, REQUEST, RELEASE, DECLINE
+// and INFORM messages with addresses from a. Replies carry the server
+// identifier serverID, and are further customized with modifiers, e.g. to
+// set the subnet mask and routers.
+func (a *Allocator) Handler(serverID net.IP, modifiers ...dhcpv4.Modifier) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+		if m.OpCode != dhcpv4.OpcodeBootRequest {
+			return
+		}
+		var (
+			mods  []dhcpv4.Modifier
+			lease *Lease
+			err   error
+		)
+		switch m.MessageType() {
+		case dhcpv4.MessageTypeDiscover:
+			lease, err = a.Offer(context.Background(), m)
+			if err != nil {
+				return
+			}
+			mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer))
+		case dhcpv4.MessageTypeRequest:
+			if sid := m.ServerIdentifier(); sid != nil && !sid.Equal(serverID) {
+				// The client selected another server.
+				a.cancelOffer(m)
+				return
+			}
+			ip := m.RequestedIPAddress()
+			if ip == nil {
+				ip = m.ClientIPAddr
+			}
+			lease, err = a.Bind(m, ip)
+			if err != nil {
+				mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeNak))
+				break
+			}
+			mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeAck))
+		case dhcpv4.MessageTypeRelease:
+			_, _ = a.Release(m)
+			return
+		case dhcpv4.MessageTypeDecline:
+			_, _ = a.Decline(m)
+			return
+		case dhcpv4.MessageTypeInform:
+			mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeAck))
+		default:
+			return
+		}
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptServerIdentifier(serverID)))
+		if lease != nil {
+			mods = append(mods,
+				dhcpv4.WithYourIP(lease.IP),
+				dhcpv4.WithLeaseTime(uint32(a.leaseTime().Seconds())),
+			)
+		}
+		if err == nil {
+			mods = append(mods, modifiers...)
+		}
+		reply, err := dhcpv4.NewReplyFromRequest(m, mods...)
+		if err != nil {
+			return
+		}
+		if lease != nil {
+			for _, h := range a.Hooks {
+				if rh, ok := h.(ReplyHook); ok {
+					rh.Reply(m, reply, lease)
+				}
+			}
+		}
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+// cancelOffer frees the address offered to the client that sent req.
+func (a *Allocator) cancelOffer(req *dhcpv4.DHCPv4) {
+	clientID, hwaddr := clientOf(req)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l, err := a.store.GetByClient(clientID, hwaddr)
+	if err != nil || l.State != LeaseStateOffered {
+		return
+	}
+	_ = a.store.Delete(l.IP)
+}
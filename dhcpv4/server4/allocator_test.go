@@ -0,0 +1,260 @@
+package server4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChecker reports the addresses in inUse as used.
+type fakeChecker struct {
+	mu      sync.Mutex
+	inUse   map[string]bool
+	checked []net.IP
+	err     error
+}
+
+func (c *fakeChecker) InUse(ctx context.Context, ip net.IP) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checked = append(c.checked, ip)
+	return c.inUse[ip.String()], c.err
+}
+
+func newDiscover(t *testing.T, hwaddr net.HardwareAddr, modifiers ...dhcpv4.Modifier) *dhcpv4.DHCPv4 {
+	m, err := dhcpv4.NewDiscovery(hwaddr, modifiers...)
+	require.NoError(t, err)
+	return m
+}
+
+func newTestAllocator(t *testing.T, start, end string) *Allocator {
+	a, err := NewAllocator(net.ParseIP(start), net.ParseIP(end), nil)
+	require.NoError(t, err)
+	return a
+}
+
+var (
+	mac1 = net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	mac2 = net.HardwareAddr{2, 0, 0, 0, 0, 2}
+)
+
+func TestNewAllocator(t *testing.T) {
+	_, err := NewAllocator(net.ParseIP("10.0.0.10"), net.ParseIP("10.0.0.1"), nil)
+	require.Error(t, err)
+	_, err = NewAllocator(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), nil)
+	require.Error(t, err)
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	require.True(t, a.Contains(net.ParseIP("10.0.0.20")))
+	require.False(t, a.Contains(net.ParseIP("10.0.0.21")))
+}
+
+func TestAllocatorOfferBind(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.11")
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.10", lease.IP.String())
+	require.Equal(t, LeaseStateOffered, lease.State)
+
+	// The offer is reserved for the client.
+	other, err := a.Offer(context.Background(), newDiscover(t, mac2))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", other.IP.String())
+	_, err = a.Offer(context.Background(), newDiscover(t, net.HardwareAddr{2, 0, 0, 0, 0, 3}))
+	require.Equal(t, ErrNoAddressAvailable, err)
+	_, err = a.Bind(newDiscover(t, mac2), lease.IP)
+	require.Equal(t, ErrAddressUnavailable, err)
+
+	bound, err := a.Bind(newDiscover(t, mac1, dhcpv4.WithGeneric(dhcpv4.OptionRelayAgentInformation, testRelayAgentInfo)), lease.IP)
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateBound, bound.State)
+	stored, err := a.Store().Get(lease.IP)
+	require.NoError(t, err)
+	require.Equal(t, mac1, stored.HardwareAddr)
+	require.Equal(t, testRelayAgentInfo, stored.RelayAgentInfo)
+
+	// A returning client gets its address back.
+	again, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.True(t, again.IP.Equal(lease.IP))
+	require.Equal(t, LeaseStateBound, again.State)
+
+	release := newDiscover(t, mac1, dhcpv4.WithMessageType(dhcpv4.MessageTypeRelease), dhcpv4.WithClientIP(lease.IP))
+	_, err = a.Release(release)
+	require.NoError(t, err)
+	stored, err = a.Store().Get(lease.IP)
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateReleased, stored.State)
+}
+
+func TestAllocatorOfferExpired(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.10")
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	_, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	_, err = a.Offer(context.Background(), newDiscover(t, mac2))
+	require.Equal(t, ErrNoAddressAvailable, err)
+
+	now = now.Add(DefaultOfferTime)
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac2))
+	require.NoError(t, err)
+	require.Equal(t, mac2, lease.HardwareAddr)
+}
+
+func TestAllocatorClientID(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	id := dhcpv4.WithOption(dhcpv4.OptClientIdentifier([]byte("client")))
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1, id))
+	require.NoError(t, err)
+	// Same client identifier, different hardware address.
+	_, err = a.Bind(newDiscover(t, mac2, id), lease.IP)
+	require.NoError(t, err)
+	_, err = a.Bind(newDiscover(t, mac1), lease.IP)
+	require.Equal(t, ErrAddressUnavailable, err)
+}
+
+func TestAllocatorRequestedAddress(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1,
+		dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(net.ParseIP("10.0.0.15")))))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.15", lease.IP.String())
+
+	lease, err = a.Offer(context.Background(), newDiscover(t, mac2,
+		dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(net.ParseIP("192.168.0.1")))))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.10", lease.IP.String())
+}
+
+func TestAllocatorConflict(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.12")
+	checker := &fakeChecker{inUse: map[string]bool{"10.0.0.10": true}}
+	a.Checker = checker
+
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", lease.IP.String())
+	abandoned, err := a.Store().Get(net.ParseIP("10.0.0.10"))
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateAbandoned, abandoned.State)
+	require.Len(t, checker.checked, 2)
+
+	// Bound addresses are not checked again.
+	_, err = a.Bind(newDiscover(t, mac1), lease.IP)
+	require.NoError(t, err)
+	_, err = a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Len(t, checker.checked, 2)
+
+	// Abandoned addresses are reclaimed once the pool is exhausted.
+	lease, err = a.Offer(context.Background(), newDiscover(t, mac2))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.12", lease.IP.String())
+	checker.inUse = nil
+	lease, err = a.Offer(context.Background(), newDiscover(t, net.HardwareAddr{2, 0, 0, 0, 0, 3}))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.10", lease.IP.String())
+}
+
+func TestAllocatorConflictAllInUse(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.11")
+	a.Checker = &fakeChecker{inUse: map[string]bool{"10.0.0.10": true, "10.0.0.11": true}}
+	_, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.Equal(t, ErrNoAddressAvailable, err)
+}
+
+func TestAllocatorConflictMaxChecks(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.19")
+	inUse := make(map[string]bool)
+	for i := 10; i <= 19; i++ {
+		inUse[fmt.Sprintf("10.0.0.%d", i)] = true
+	}
+	checker := &fakeChecker{inUse: inUse}
+	a.Checker = checker
+	a.MaxChecks = 3
+	var logs strings.Builder
+	a.Logger = log.New(&logs, "", 0)
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.13", lease.IP.String())
+	require.Len(t, checker.checked, 3)
+	require.Equal(t, "allocator: 3 addresses in use, offering 10.0.0.13 without a check\n", logs.String())
+}
+
+func TestAllocatorConflictCheckError(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.11")
+	a.Checker = &fakeChecker{err: errors.New("no ICMP")}
+	var logs strings.Builder
+	a.Logger = log.New(&logs, "", 0)
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.10", lease.IP.String())
+	require.Equal(t, "allocator: cannot check 10.0.0.10, offering it: no ICMP\n", logs.String())
+}
+
+func TestAllocatorDecline(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.11")
+	lease, err := a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	_, err = a.Bind(newDiscover(t, mac1), lease.IP)
+	require.NoError(t, err)
+
+	decline := newDiscover(t, mac2, dhcpv4.WithMessageType(dhcpv4.MessageTypeDecline),
+		dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(lease.IP)))
+	_, err = a.Decline(decline)
+	require.Equal(t, ErrNoLease, err)
+
+	decline.ClientHWAddr = mac1
+	_, err = a.Decline(decline)
+	require.NoError(t, err)
+	lease, err = a.Offer(context.Background(), newDiscover(t, mac1))
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", lease.IP.String())
+}
+
+func TestAllocatorHandler(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	a.Checker = &fakeChecker{inUse: map[string]bool{"10.0.0.10": true}}
+	serverID := net.ParseIP("10.0.0.1").To4()
+
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	clientConn := nclient4.NewBroadcastUDPConn(clientRawConn, &net.UDPAddr{Port: nclient4.ClientPort})
+	serverConn := nclient4.NewBroadcastUDPConn(serverRawConn, &net.UDPAddr{Port: nclient4.ServerPort})
+	s, err := NewServer("", nil, a.Handler(serverID, dhcpv4.WithNetmask(net.CIDRMask(24, 32))), WithConn(serverConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, err := nclient4.NewWithConn(clientConn, mac1, nclient4.WithRetry(1), nclient4.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+
+	lease, err := c.Request(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.11", lease.ACK.YourIPAddr.String())
+	require.True(t, lease.ACK.ServerIdentifier().Equal(serverID))
+	require.Equal(t, net.CIDRMask(24, 32), lease.ACK.SubnetMask())
+	require.Equal(t, DefaultLeaseTime, lease.ACK.IPAddressLeaseTime(0))
+
+	stored, err := a.Store().Get(lease.ACK.YourIPAddr)
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateBound, stored.State)
+
+	require.NoError(t, c.Release(lease))
+	require.Eventually(t, func() bool {
+		stored, err := a.Store().Get(lease.ACK.YourIPAddr)
+		return err == nil && stored.State == LeaseStateReleased
+	}, time.Second, 10*time.Millisecond)
+}
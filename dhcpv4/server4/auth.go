@@ -0,0 +1,109 @@
+package server4
+
+import (
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// Auth authenticates clients with the delayed authentication protocol of
+// RFC 3118, Section 5. It wraps a Handler into a RawHandler: requests that
+// fail authentication are dropped, and the replies the handler sends are
+// signed with the secret of the client.
+//
+// Auth is also a Hook, that forgets the replay detection values of clients
+// when their lease ends. Clients are tracked until then, or until
+// DelayedAuth.Replay evicts them.
+type Auth struct {
+	*dhcpv4.DelayedAuth
+	// Optional lets clients that do not use authentication through, and
+	// their replies are not signed. Requests with an invalid
+	// Authentication option are always dropped.
+	Optional bool
+	// ClientKeyID returns the ID of the secret of the client that sent a
+	// DISCOVER, used to sign the OFFER. If nil, or if it returns false,
+	// the KeyID of DelayedAuth is used.
+	ClientKeyID func(req *dhcpv4.DHCPv4) (uint32, bool)
+	// Logger, if not nil, logs dropped requests.
+	Logger Printfer
+}
+
+// NewAuth returns an Auth that uses a.
+func NewAuth(a *dhcpv4.DelayedAuth) *Auth {
+	return &Auth{DelayedAuth: a}
+}
+
+func (a *Auth) printf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+	}
+}
+
+// RawHandler returns a RawHandler, for servers configured with
+// WithRawHandler, that authenticates requests before calling next, and signs
+// the replies next sends. Requests are verified over the raw bytes received,
+// as the HMAC covers the encoding of the client, RFC 3118, Section 5.4.
+//
+// There is no Handler variant: parsed messages cannot be verified, since
+// serializing them again loses the option order and padding of the client.
+func (a *Auth) RawHandler(next Handler) RawHandler {
+	return func(conn net.PacketConn, peer net.Addr, raw []byte, m *dhcpv4.DHCPv4) {
+		auth := m.Authentication()
+		if auth == nil {
+			if a.Optional {
+				next(conn, peer, m)
+			} else {
+				a.printf("auth: dropping unauthenticated %s from %s", m.MessageType(), m.ClientHWAddr)
+			}
+			return
+		}
+
+		var id uint32
+		if m.MessageType() == dhcpv4.MessageTypeDiscover && len(auth.Information) == 0 {
+			// RFC 3118, Section 5.2: the client requests
+			// authentication, the server chooses the secret.
+			id = a.KeyID
+			if a.ClientKeyID != nil {
+				if cid, ok := a.ClientKeyID(m); ok {
+					id = cid
+				}
+			}
+		} else {
+			var err error
+			id, err = a.VerifyBytes(raw, clientKey(m.Options.Get(dhcpv4.OptionClientIdentifier), m.ClientHWAddr))
+			if err != nil {
+				a.printf("auth: dropping %s from %s: %v", m.MessageType(), m.ClientHWAddr, err)
+				return
+			}
+		}
+		next(&authConn{PacketConn: conn, a: a, id: id}, peer, m)
+	}
+}
+
+// LeaseChanged implements Hook.LeaseChanged. It forgets the replay detection
+// value of clients whose lease is no longer bound, so that the values of
+// departed clients are not kept.
+func (a *Auth) LeaseChanged(req *dhcpv4.DHCPv4, l *Lease) {
+	if l.State == LeaseStateBound {
+		return
+	}
+	a.Replay.Forget(clientKey(l.ClientID, l.HardwareAddr))
+}
+
+// authConn signs the replies written to it.
+type authConn struct {
+	net.PacketConn
+	a  *Auth
+	id uint32
+}
+
+func (c *authConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	reply, err := dhcpv4.FromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.a.Sign(reply, c.id); err != nil {
+		return 0, err
+	}
+	return c.PacketConn.WriteTo(reply.ToBytes(), addr)
+}
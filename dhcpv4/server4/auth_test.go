@@ -0,0 +1,131 @@
+package server4
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthHandler(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	client := dhcpv4.NewDelayedAuth(7, secret)
+	auth := NewAuth(&dhcpv4.DelayedAuth{Keys: map[uint32][]byte{1: []byte("other"), 7: secret}, KeyID: 1})
+	auth.ClientKeyID = func(req *dhcpv4.DHCPv4) (uint32, bool) {
+		return 7, req.ClientHWAddr.String() == mac1.String()
+	}
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	raw := auth.RawHandler(a.Handler(net.IPv4(10, 0, 0, 1)))
+	h := func(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+		raw(conn, peer, m.ToBytes(), m)
+	}
+	conn := &recordConn{}
+
+	// Unauthenticated clients are dropped.
+	h(conn, &net.UDPAddr{}, newDiscover(t, mac2))
+	require.Empty(t, conn.sent)
+
+	req := newDiscover(t, mac1, dhcpv4.WithDelayedAuthRequest(client))
+	h(conn, &net.UDPAddr{}, req)
+	require.Len(t, conn.sent, 1)
+	offer := conn.sent[0]
+	id, err := client.Verify(offer, "server")
+	require.NoError(t, err)
+	require.Equal(t, uint32(7), id)
+
+	req, err = dhcpv4.NewRequestFromOffer(offer)
+	require.NoError(t, err)
+	require.NoError(t, client.Sign(req, client.KeyID))
+	h(conn, &net.UDPAddr{}, req)
+	require.Len(t, conn.sent, 2)
+	require.Equal(t, dhcpv4.MessageTypeAck, conn.sent[1].MessageType())
+	_, err = client.Verify(conn.sent[1], "server")
+	require.NoError(t, err)
+
+	// Replays and tampered requests are dropped.
+	h(conn, &net.UDPAddr{}, req)
+	tampered, err := dhcpv4.NewRequestFromOffer(offer)
+	require.NoError(t, err)
+	require.NoError(t, client.Sign(tampered, client.KeyID))
+	tampered.ClientIPAddr = net.IPv4(10, 0, 0, 99)
+	h(conn, &net.UDPAddr{}, tampered)
+	require.Len(t, conn.sent, 2)
+
+	// Optional authentication lets other clients through, unsigned.
+	auth.Optional = true
+	h(conn, &net.UDPAddr{}, newDiscover(t, mac2))
+	require.Len(t, conn.sent, 3)
+	require.Nil(t, conn.sent[2].Authentication())
+}
+
+// resign recomputes the delayed authentication HMAC of msg in place.
+func resign(t *testing.T, msg []byte, secret []byte) {
+	b := append([]byte(nil), msg...)
+	b[3] = 0
+	copy(b[24:28], []byte{0, 0, 0, 0})
+	off := 240
+	for b[off] != byte(dhcpv4.OptionAuthentication.Code()) {
+		require.NotEqual(t, byte(dhcpv4.OptionEnd.Code()), b[off])
+		if b[off] == byte(dhcpv4.OptionPad.Code()) {
+			off++
+		} else {
+			off += 2 + int(b[off+1])
+		}
+	}
+	end := off + 2 + int(b[off+1])
+	for i := end - md5.Size; i < end; i++ {
+		b[i] = 0
+	}
+	h := hmac.New(md5.New, secret)
+	h.Write(b)
+	copy(msg[end-md5.Size:end], h.Sum(nil))
+}
+
+func TestAuthRawHandler(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	client := dhcpv4.NewDelayedAuth(7, secret)
+	auth := NewAuth(dhcpv4.NewDelayedAuth(7, secret))
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	raw := auth.RawHandler(a.Handler(net.IPv4(10, 0, 0, 1)))
+	conn := &recordConn{}
+
+	discover := newDiscover(t, mac1, dhcpv4.WithDelayedAuthRequest(client))
+	raw(conn, &net.UDPAddr{}, discover.ToBytes(), discover)
+	require.Len(t, conn.sent, 1)
+	req, err := dhcpv4.NewRequestFromOffer(conn.sent[0])
+	require.NoError(t, err)
+	require.NoError(t, client.Sign(req, client.KeyID))
+
+	// The client puts a pad option after the magic cookie, which is lost
+	// when the message is serialized again.
+	b := req.ToBytes()
+	b = append(append(append([]byte(nil), b[:240]...), byte(dhcpv4.OptionPad.Code())), b[240:]...)
+	resign(t, b, secret)
+	m, err := dhcpv4.FromBytes(b)
+	require.NoError(t, err)
+	require.NotEqual(t, b, m.ToBytes())
+
+	// The serialized message does not verify, the raw one does.
+	raw(conn, &net.UDPAddr{}, m.ToBytes(), m)
+	require.Len(t, conn.sent, 1)
+	raw(conn, &net.UDPAddr{}, b, m)
+	require.Len(t, conn.sent, 2)
+	require.Equal(t, dhcpv4.MessageTypeAck, conn.sent[1].MessageType())
+}
+
+func TestAuthLeaseChanged(t *testing.T) {
+	auth := NewAuth(dhcpv4.NewDelayedAuth(7, []byte("0123456789abcdef")))
+	peer := clientKey(nil, mac1)
+	require.NoError(t, auth.Replay.Check(peer, 10))
+
+	l := &Lease{IP: net.IPv4(10, 0, 0, 10), HardwareAddr: mac1, State: LeaseStateBound}
+	auth.LeaseChanged(nil, l)
+	require.Equal(t, dhcpv4.ErrAuthReplay, auth.Replay.Check(peer, 10))
+
+	l.State = LeaseStateReleased
+	auth.LeaseChanged(nil, l)
+	require.NoError(t, auth.Replay.Check(peer, 10))
+}
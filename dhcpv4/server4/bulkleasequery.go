@@ -0,0 +1,264 @@
+package server4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// ServeBulk accepts bulk leasequery connections on l, as described in RFC
+// 6926, and serves each of them with ServeConn, until l fails. Servers listen
+// on TCP port 67.
+func (q *Leasequery) ServeBulk(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go q.ServeConn(conn)
+	}
+}
+
+// ServeConn answers the DHCPBULKLEASEQUERY messages received on conn, one at
+// a time, until the requestor closes the connection. It then closes conn.
+//
+// The replies to a query are streamed: one DHCPLEASEACTIVE or
+// DHCPLEASEUNASSIGNED message per binding, followed by a DHCPLEASEQUERYDONE
+// message. Queries that are not allowed or cannot be answered only get a
+// DHCPLEASEQUERYDONE message, with a Status Code option.
+func (q *Leasequery) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	peer := conn.RemoteAddr()
+	for {
+		m, err := dhcpv4.ReadTCPMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				q.printf("bulk leasequery: cannot read from %s: %v", peer, err)
+			}
+			return
+		}
+		if m.OpCode != dhcpv4.OpcodeBootRequest || m.MessageType() != dhcpv4.MessageTypeBulkLeaseQuery {
+			q.printf("bulk leasequery: ignoring %s from %s", m.MessageType(), peer)
+			continue
+		}
+		send := func(reply *dhcpv4.DHCPv4) error {
+			return dhcpv4.WriteTCPMessage(conn, reply)
+		}
+		if q.Allow != nil && !q.Allow(peer, m) {
+			q.printf("bulk leasequery: refusing query from %s", peer)
+			err = q.done(send, m, dhcpv4.StatusNotAllowed, "")
+		} else {
+			err = q.bulk(m, send)
+		}
+		if err != nil {
+			q.printf("bulk leasequery: cannot reply to %s: %v", peer, err)
+			return
+		}
+	}
+}
+
+// bulk sends the replies to the DHCPBULKLEASEQUERY message m, as described in
+// RFC 6926, Section 7.4.
+func (q *Leasequery) bulk(m *dhcpv4.DHCPv4, send func(*dhcpv4.DHCPv4) error) error {
+	now := q.time()
+	var relayID, remoteID []byte
+	if relay := m.RelayAgentInfo(); relay != nil {
+		relayID = relay.Get(dhcpv4.RelayIDSubOption)
+		remoteID = relay.Get(dhcpv4.AgentRemoteIDSubOption)
+	}
+	clientID := m.Options.Get(dhcpv4.OptionClientIdentifier)
+	byIP := m.ClientIPAddr != nil && !m.ClientIPAddr.IsUnspecified()
+
+	var n int
+	for _, set := range []bool{byIP, len(m.ClientHWAddr) > 0, len(clientID) > 0, len(relayID) > 0, len(remoteID) > 0} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return q.done(send, m, dhcpv4.StatusMalformedQuery, "more than one query type")
+	}
+
+	var (
+		leases []*Lease
+		err    error
+	)
+	switch {
+	case byIP:
+		var l *Lease
+		l, err = q.Store.Get(m.ClientIPAddr)
+		switch {
+		case errors.Is(err, ErrNoLease) || (err == nil && l.State == LeaseStateOffered):
+			t := dhcpv4.MessageTypeLeaseUnknown
+			if q.Contains != nil && q.Contains(m.ClientIPAddr) {
+				t = dhcpv4.MessageTypeLeaseUnassigned
+			}
+			reply, err := dhcpv4.NewReplyFromRequest(m,
+				dhcpv4.WithMessageType(t),
+				dhcpv4.WithClientIP(m.ClientIPAddr),
+				dhcpv4.WithOption(dhcpv4.OptServerIdentifier(q.ServerID)),
+			)
+			if err != nil {
+				return err
+			}
+			if err := send(reply); err != nil {
+				return err
+			}
+			return q.done(send, m, dhcpv4.StatusSuccess, "")
+		case err == nil:
+			leases = []*Lease{l}
+		}
+	case len(m.ClientHWAddr) > 0:
+		leases, err = q.Store.GetByHardwareAddr(m.ClientHWAddr)
+	default:
+		leases, err = q.Store.All()
+		leases = filterLeases(leases, func(l *Lease) bool {
+			switch {
+			case len(clientID) > 0:
+				return bytes.Equal(l.ClientID, clientID)
+			case len(relayID) > 0:
+				return bytes.Equal(relayAgentSubOption(l, dhcpv4.RelayIDSubOption), relayID)
+			case len(remoteID) > 0:
+				return bytes.Equal(relayAgentSubOption(l, dhcpv4.AgentRemoteIDSubOption), remoteID)
+			}
+			return true
+		})
+	}
+	if err != nil {
+		return q.done(send, m, dhcpv4.StatusUnspecFail, err.Error())
+	}
+
+	start, hasStart := m.QueryStartTime()
+	end, hasEnd := m.QueryEndTime()
+	for _, l := range leases {
+		// Offered addresses are not bound.
+		if l.State == LeaseStateOffered {
+			continue
+		}
+		changed := stateStart(l, now)
+		if (hasStart && changed.Before(start)) || (hasEnd && changed.After(end)) {
+			continue
+		}
+		reply, err := bindingReply(q.ServerID, m, l, now)
+		if err != nil {
+			return err
+		}
+		if err := send(reply); err != nil {
+			return err
+		}
+	}
+	return q.done(send, m, dhcpv4.StatusSuccess, "")
+}
+
+// bindingReply returns the reply of the server serverID to the bulk or active
+// leasequery m about the binding l: DHCPLEASEACTIVE for active bindings, and
+// DHCPLEASEUNASSIGNED otherwise.
+func bindingReply(serverID net.IP, m *dhcpv4.DHCPv4, l *Lease, now time.Time) (*dhcpv4.DHCPv4, error) {
+	state := leaseDHCPState(l, now)
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(serverID)),
+		dhcpv4.WithClientIP(l.IP),
+		dhcpv4.WithHWType(iana.HWTypeEthernet),
+		dhcpv4.WithHwAddr(l.HardwareAddr),
+		// Options of the query are not copied.
+		dhcpv4.WithoutOption(dhcpv4.OptionClientIdentifier),
+		dhcpv4.WithoutOption(dhcpv4.OptionRelayAgentInformation),
+		dhcpv4.WithOption(dhcpv4.OptBaseTime(now)),
+		dhcpv4.WithOption(dhcpv4.OptClientLastTransactionTime(now.Sub(l.Updated).Truncate(time.Second))),
+		dhcpv4.WithOption(dhcpv4.OptStartTimeOfState(now.Sub(stateStart(l, now)).Truncate(time.Second))),
+		dhcpv4.WithOption(dhcpv4.OptDHCPState(state)),
+	}
+	if state == dhcpv4.DHCPStateActive {
+		mods = append(mods,
+			dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseActive),
+			dhcpv4.WithOption(dhcpv4.OptIPAddressLeaseTime(l.Expiry.Sub(now).Truncate(time.Second))),
+		)
+	} else {
+		mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseUnassigned))
+	}
+	if len(l.ClientID) > 0 {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClientIdentifier(l.ClientID)))
+	}
+	if len(l.RelayAgentInfo) > 0 {
+		mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionRelayAgentInformation, l.RelayAgentInfo))
+	}
+	return dhcpv4.NewReplyFromRequest(m, mods...)
+}
+
+// done sends the DHCPLEASEQUERYDONE message that ends the replies to m.
+func (q *Leasequery) done(send func(*dhcpv4.DHCPv4) error, m *dhcpv4.DHCPv4, code dhcpv4.StatusCode, message string) error {
+	return sendStatus(send, q.ServerID, m, dhcpv4.MessageTypeLeaseQueryDone, code, message)
+}
+
+// sendStatus sends the reply of type t of the server serverID to m, with a
+// Status Code option if code is not StatusSuccess.
+func sendStatus(send func(*dhcpv4.DHCPv4) error, serverID net.IP, m *dhcpv4.DHCPv4, t dhcpv4.MessageType, code dhcpv4.StatusCode, message string) error {
+	mods := []dhcpv4.Modifier{
+		dhcpv4.WithMessageType(t),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(serverID)),
+		dhcpv4.WithoutOption(dhcpv4.OptionClientIdentifier),
+		dhcpv4.WithoutOption(dhcpv4.OptionRelayAgentInformation),
+	}
+	if code != dhcpv4.StatusSuccess {
+		mods = append(mods, dhcpv4.WithOption(dhcpv4.OptStatusCode(code, message)))
+	}
+	reply, err := dhcpv4.NewReplyFromRequest(m, mods...)
+	if err != nil {
+		return err
+	}
+	return send(reply)
+}
+
+// leaseDHCPState returns the state of the binding l at time now.
+func leaseDHCPState(l *Lease, now time.Time) dhcpv4.DHCPState {
+	switch l.State {
+	case LeaseStateBound:
+		if l.Active(now) {
+			return dhcpv4.DHCPStateActive
+		}
+		return dhcpv4.DHCPStateExpired
+	case LeaseStateReleased:
+		return dhcpv4.DHCPStateReleased
+	case LeaseStateAbandoned:
+		return dhcpv4.DHCPStateAbandoned
+	case LeaseStateExpired:
+		return dhcpv4.DHCPStateExpired
+	}
+	return dhcpv4.DHCPStateAvailable
+}
+
+// stateStart returns when the binding l entered its state at time now.
+func stateStart(l *Lease, now time.Time) time.Time {
+	if l.State == LeaseStateBound && !l.Active(now) {
+		return l.Expiry
+	}
+	return l.Updated
+}
+
+func filterLeases(leases []*Lease, keep func(*Lease) bool) []*Lease {
+	var out []*Lease
+	for _, l := range leases {
+		if keep(l) {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// relayAgentSubOption returns a sub-option of the relay agent information
+// of l.
+func relayAgentSubOption(l *Lease, code dhcpv4.OptionCode) []byte {
+	if len(l.RelayAgentInfo) == 0 {
+		return nil
+	}
+	var relay dhcpv4.RelayOptions
+	if err := relay.FromBytes(l.RelayAgentInfo); err != nil {
+		return nil
+	}
+	return relay.Get(code)
+}
@@ -0,0 +1,201 @@
+package server4
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/stretchr/testify/require"
+)
+
+// bulkRequestor sends bulk leasequeries over conn.
+type bulkRequestor struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+// query sends query, and returns the replies.
+func (r bulkRequestor) query(query *dhcpv4.DHCPv4, err error) ([]*dhcpv4.DHCPv4, error) {
+	require.NoError(r.t, err)
+	var replies []*dhcpv4.DHCPv4
+	err = nclient4.BulkLeasequery(context.Background(), r.conn, query, func(m *dhcpv4.DHCPv4) error {
+		require.Equal(r.t, query.TransactionID, m.TransactionID)
+		replies = append(replies, m)
+		return nil
+	})
+	return replies, err
+}
+
+func replyIPs(replies []*dhcpv4.DHCPv4) []string {
+	var ips []string
+	for _, m := range replies {
+		ips = append(ips, m.ClientIPAddr.String())
+	}
+	return ips
+}
+
+func TestBulkLeasequery(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	q := newTestLeasequery(t, now)
+	// An expired binding.
+	require.NoError(t, q.Store.Put(&Lease{IP: net.IPv4(10, 0, 0, 14), HardwareAddr: mac2, State: LeaseStateBound, Updated: now.Add(-2 * time.Hour), Expiry: now.Add(-time.Hour)}))
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go q.ServeConn(server)
+	r := bulkRequestor{t: t, conn: client}
+
+	// All the bindings.
+	replies, err := r.query(dhcpv4.NewBulkLeasequery())
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.10", "10.0.0.11", "10.0.0.12", "10.0.0.14"}, replyIPs(replies))
+
+	active := replies[0]
+	require.Equal(t, dhcpv4.MessageTypeLeaseActive, active.MessageType())
+	require.Equal(t, mac1, active.ClientHWAddr)
+	require.Equal(t, []byte("c1"), active.Options.Get(dhcpv4.OptionClientIdentifier))
+	require.Equal(t, testRelayAgentInfo, active.Options.Get(dhcpv4.OptionRelayAgentInformation))
+	require.Equal(t, time.Hour, active.IPAddressLeaseTime(0))
+	state, ok := active.DHCPState()
+	require.True(t, ok)
+	require.Equal(t, dhcpv4.DHCPStateActive, state)
+	base, ok := active.BaseTime()
+	require.True(t, ok)
+	require.True(t, now.Equal(base))
+	cltt, ok := active.ClientLastTransactionTime()
+	require.True(t, ok)
+	require.Equal(t, 10*time.Second, cltt)
+
+	for i, want := range []dhcpv4.DHCPState{dhcpv4.DHCPStateReleased, dhcpv4.DHCPStateExpired} {
+		m := replies[2+i]
+		require.Equal(t, dhcpv4.MessageTypeLeaseUnassigned, m.MessageType())
+		state, ok := m.DHCPState()
+		require.True(t, ok)
+		require.Equal(t, want, state)
+		require.Nil(t, m.Options.Get(dhcpv4.OptionIPAddressLeaseTime))
+	}
+	// The expired binding changed state when it expired.
+	sos, ok := replies[3].StartTimeOfState()
+	require.True(t, ok)
+	require.Equal(t, time.Hour, sos)
+
+	for _, tt := range []struct {
+		name  string
+		query func() (*dhcpv4.DHCPv4, error)
+		want  []string
+	}{
+		{
+			name:  "relay identifier",
+			query: func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewBulkLeasequeryByRelayID([]byte("relay1")) },
+			want:  []string{"10.0.0.10"},
+		},
+		{
+			name:  "unknown relay identifier",
+			query: func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewBulkLeasequeryByRelayID([]byte("relay2")) },
+		},
+		{
+			name:  "remote ID",
+			query: func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewBulkLeasequeryByRemoteID([]byte("remote1")) },
+			want:  []string{"10.0.0.10"},
+		},
+		{
+			name: "client identifier",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewLeasequeryByClientID([]byte("c1"), dhcpv4.WithMessageType(dhcpv4.MessageTypeBulkLeaseQuery))
+			},
+			want: []string{"10.0.0.10", "10.0.0.11"},
+		},
+		{
+			name: "hardware address",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewLeasequeryByHardwareAddr(mac2, dhcpv4.WithMessageType(dhcpv4.MessageTypeBulkLeaseQuery))
+			},
+			want: []string{"10.0.0.12", "10.0.0.14"},
+		},
+		{
+			name: "address",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 11), dhcpv4.WithMessageType(dhcpv4.MessageTypeBulkLeaseQuery))
+			},
+			want: []string{"10.0.0.11"},
+		},
+		{
+			name: "free address",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 13), dhcpv4.WithMessageType(dhcpv4.MessageTypeBulkLeaseQuery))
+			},
+			want: []string{"10.0.0.13"},
+		},
+		{
+			name: "query start time",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewBulkLeasequery(dhcpv4.WithOption(dhcpv4.OptQueryStartTime(now.Add(-15 * time.Second))))
+			},
+			want: []string{"10.0.0.10"},
+		},
+		{
+			name: "query end time",
+			query: func() (*dhcpv4.DHCPv4, error) {
+				return dhcpv4.NewBulkLeasequery(dhcpv4.WithOption(dhcpv4.OptQueryEndTime(now.Add(-30 * time.Minute))))
+			},
+			want: []string{"10.0.0.12", "10.0.0.14"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bulkRequestor{t: t, conn: client}
+			replies, err := r.query(tt.query())
+			require.NoError(t, err)
+			require.Equal(t, tt.want, replyIPs(replies))
+		})
+	}
+
+	// Queries of more than one type are malformed.
+	_, err = r.query(dhcpv4.NewBulkLeasequeryByRelayID([]byte("relay1"), dhcpv4.WithClientIP(net.IPv4(10, 0, 0, 10))))
+	var status *nclient4.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusMalformedQuery, status.Status.Code)
+
+	// The connection is still usable.
+	replies, err = r.query(dhcpv4.NewBulkLeasequeryByRelayID([]byte("relay1")))
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+
+	_, err = r.query(dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 10)))
+	require.Error(t, err)
+}
+
+func TestServeBulk(t *testing.T) {
+	q := newTestLeasequery(t, time.Now())
+	q.Allow = func(peer net.Addr, m *dhcpv4.DHCPv4) bool {
+		return len(m.ClientHWAddr) == 0
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() { _ = q.ServeBulk(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bulkRequestor{t: t, conn: conn}
+
+	replies, err := r.query(dhcpv4.NewBulkLeasequery())
+	require.NoError(t, err)
+	require.Len(t, replies, 3)
+
+	_, err = r.query(dhcpv4.NewLeasequeryByHardwareAddr(mac1, dhcpv4.WithMessageType(dhcpv4.MessageTypeBulkLeaseQuery)))
+	var status *nclient4.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, dhcpv4.StatusNotAllowed, status.Status.Code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	query, err := dhcpv4.NewBulkLeasequery()
+	require.NoError(t, err)
+	err = nclient4.BulkLeasequery(ctx, conn, query, func(*dhcpv4.DHCPv4) error { return nil })
+	require.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,191 @@
+package server4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// ConflictChecker checks whether an address is in use before it is offered.
+type ConflictChecker interface {
+	// InUse returns whether a host on the network already uses ip.
+	InUse(ctx context.Context, ip net.IP) (bool, error)
+}
+
+// Default PingChecker settings.
+const (
+	DefaultPingTimeout  = 500 * time.Millisecond
+	DefaultPingCacheTTL = time.Minute
+)
+
+// PingChecker is a ConflictChecker that sends an ICMP echo request to the
+// address, like ISC dhcpd does, and considers it in use if it answers.
+//
+// It uses unprivileged ICMP datagram sockets where available (on Linux, see
+// the net.ipv4.ping_group_range sysctl), and raw sockets otherwise.
+type PingChecker struct {
+	// Timeout is how long to wait for an answer. If zero,
+	// DefaultPingTimeout is used.
+	Timeout time.Duration
+	// CacheTTL is how long the result for an address is remembered, so
+	// that retransmitted DISCOVERs do not delay the server again. If zero,
+	// DefaultPingCacheTTL is used; if negative, results are not cached.
+	CacheTTL time.Duration
+
+	mu    sync.Mutex
+	cache map[string]pingResult
+	// ping is replaced in tests.
+	ping func(ctx context.Context, ip net.IP, timeout time.Duration) (bool, error)
+}
+
+type pingResult struct {
+	inUse   bool
+	expires time.Time
+}
+
+// NewPingChecker returns a PingChecker with the given timeout and cache TTL.
+func NewPingChecker(timeout, cacheTTL time.Duration) *PingChecker {
+	return &PingChecker{Timeout: timeout, CacheTTL: cacheTTL}
+}
+
+// InUse implements ConflictChecker.InUse.
+func (p *PingChecker) InUse(ctx context.Context, ip net.IP) (bool, error) {
+	ip = ip.To4()
+	if ip == nil {
+		return false, errors.New("not an IPv4 address")
+	}
+	ttl := p.CacheTTL
+	if ttl == 0 {
+		ttl = DefaultPingCacheTTL
+	}
+	now := time.Now()
+	if ttl > 0 {
+		p.mu.Lock()
+		r, ok := p.cache[string(ip)]
+		p.mu.Unlock()
+		if ok && now.Before(r.expires) {
+			return r.inUse, nil
+		}
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultPingTimeout
+	}
+	ping := p.ping
+	if ping == nil {
+		ping = pingICMP
+	}
+	inUse, err := ping(ctx, ip, timeout)
+	if err != nil {
+		return false, err
+	}
+	if ttl > 0 {
+		p.mu.Lock()
+		if p.cache == nil {
+			p.cache = make(map[string]pingResult)
+		}
+		// Drop stale entries, so that the cache does not grow beyond the
+		// addresses checked within one TTL.
+		for k, r := range p.cache {
+			if !now.Before(r.expires) {
+				delete(p.cache, k)
+			}
+		}
+		p.cache[string(ip)] = pingResult{inUse: inUse, expires: now.Add(ttl)}
+		p.mu.Unlock()
+	}
+	return inUse, nil
+}
+
+// listenICMP opens an unprivileged ICMP datagram socket, or a raw socket if
+// the former is not permitted.
+func listenICMP() (conn *icmp.PacketConn, privileged bool, err error) {
+	conn, err = icmp.ListenPacket("udp4", "0.0.0.0")
+	if err == nil {
+		return conn, false, nil
+	}
+	conn, rawErr := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if rawErr != nil {
+		return nil, false, fmt.Errorf("unable to open ICMP socket: %v (datagram), %v (raw)", err, rawErr)
+	}
+	return conn, true, nil
+}
+
+func pingICMP(ctx context.Context, ip net.IP, timeout time.Duration) (bool, error) {
+	conn, privileged, err := listenICMP()
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Datagram sockets rewrite the identifier to their port, so replies
+	// are matched on sequence number and source address, and on the
+	// identifier with raw sockets, which see all echo replies.
+	id, seq := rand.Intn(1<<16), rand.Intn(1<<16)
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("dhcp conflict check")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if privileged {
+		dst = &net.IPAddr{IP: ip}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return false, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return false, fmt.Errorf("unable to send ICMP echo request: %w", err)
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		reply, err := icmp.ParseMessage(ipv4.ICMPTypeEcho.Protocol(), buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (privileged && echo.ID != id) {
+			continue
+		}
+		var from net.IP
+		switch a := peer.(type) {
+		case *net.UDPAddr:
+			from = a.IP
+		case *net.IPAddr:
+			from = a.IP
+		}
+		if from.Equal(ip) {
+			return true, nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server4
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPingCheckerCache(t *testing.T) {
+	var pings int
+	p := NewPingChecker(0, 0)
+	p.ping = func(ctx context.Context, ip net.IP, timeout time.Duration) (bool, error) {
+		pings++
+		require.Equal(t, DefaultPingTimeout, timeout)
+		return ip.Equal(net.IPv4(10, 0, 0, 1)), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		inUse, err := p.InUse(context.Background(), net.IPv4(10, 0, 0, 1))
+		require.NoError(t, err)
+		require.True(t, inUse)
+		inUse, err = p.InUse(context.Background(), net.IPv4(10, 0, 0, 2))
+		require.NoError(t, err)
+		require.False(t, inUse)
+	}
+	require.Equal(t, 2, pings)
+
+	// Expired entries are checked again.
+	p.cache[string(net.IPv4(10, 0, 0, 1).To4())] = pingResult{inUse: true, expires: time.Now()}
+	_, err := p.InUse(context.Background(), net.IPv4(10, 0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, 3, pings)
+}
+
+func TestPingCheckerNoCache(t *testing.T) {
+	var pings int
+	p := NewPingChecker(time.Second, -1)
+	p.ping = func(ctx context.Context, ip net.IP, timeout time.Duration) (bool, error) {
+		pings++
+		return false, nil
+	}
+	for i := 0; i < 2; i++ {
+		_, err := p.InUse(context.Background(), net.IPv4(10, 0, 0, 1))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, pings)
+}
+
+func TestPingCheckerErrors(t *testing.T) {
+	p := NewPingChecker(0, 0)
+	p.ping = func(ctx context.Context, ip net.IP, timeout time.Duration) (bool, error) {
+		return false, errors.New("boom")
+	}
+	_, err := p.InUse(context.Background(), net.IPv4(10, 0, 0, 1))
+	require.Error(t, err)
+	// Errors are not cached.
+	require.Empty(t, p.cache)
+
+	_, err = p.InUse(context.Background(), net.ParseIP("2001:db8::1"))
+	require.Error(t, err)
+}
+
+func TestPingCheckerLoopback(t *testing.T) {
+	conn, _, err := listenICMP()
+	if err != nil {
+		t.Skipf("cannot open ICMP socket: %v", err)
+	}
+	conn.Close()
+
+	p := NewPingChecker(time.Second, 0)
+	inUse, err := p.InUse(context.Background(), net.IPv4(127, 0, 0, 1))
+	require.NoError(t, err)
+	require.True(t, inUse)
+}
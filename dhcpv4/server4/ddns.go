@@ -0,0 +1,275 @@
+package server4
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// DDNS is an allocator ReplyHook that updates the DNS records of clients, as
+// described in RFC 4702 and RFC 4703: it adds A, PTR and DHCID records when
+// leases are bound, and removes them when they are released, declined or
+// expire.
+//
+// Clients that send the Client FQDN option get it back with the flags set to
+// tell whether the server updates their A record. The PTR record is updated
+// whenever Updater has a ReverseZone.
+type DDNS struct {
+	Updater *ddns.Updater
+	// Domain is appended to partial client names. If empty, the forward
+	// zone of Updater is used.
+	Domain string
+	// AllowClientUpdates lets clients update their A record themselves
+	// when they ask to. Otherwise, the server overrides their preference.
+	AllowClientUpdates bool
+	// UseHostName makes the server register the Host Name option of
+	// clients that do not send the Client FQDN option.
+	UseHostName bool
+	// Logger, if not nil, logs failed updates.
+	Logger Printfer
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	// pending are the updates to send, by address, and order the
+	// addresses in the order they were queued. Only the latest update of
+	// an address is kept: each update brings the records of the address
+	// in line with its lease.
+	pending map[string]func()
+	order   []string
+	wg      sync.WaitGroup
+	// regs are the registered names, by address. They are only accessed
+	// by the worker goroutine.
+	regs map[string]registration
+}
+
+type registration struct {
+	name    string
+	dhcid   []byte
+	forward bool
+	reverse bool
+}
+
+// NewDDNS returns a DDNS hook that uses u. Updates are sent in the
+// background, in order, until Close is called. When the DNS server is slower
+// than lease changes, the pending updates of an address are merged, so that
+// only its latest state is sent.
+func NewDDNS(u *ddns.Updater) *DDNS {
+	d := &DDNS{
+		Updater: u,
+		pending: make(map[string]func()),
+		regs:    make(map[string]registration),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	d.wg.Add(1)
+	go d.work()
+	return d
+}
+
+func (d *DDNS) work() {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		for len(d.order) == 0 {
+			if d.closed {
+				d.mu.Unlock()
+				return
+			}
+			d.cond.Wait()
+		}
+		key := d.order[0]
+		d.order = d.order[1:]
+		f := d.pending[key]
+		delete(d.pending, key)
+		d.mu.Unlock()
+		f()
+	}
+}
+
+// Close waits for pending updates and stops d.
+func (d *DDNS) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	d.wg.Wait()
+}
+
+// enqueue queues the update f of the address key, replacing the pending
+// update of the address, if any. It never waits for DNS servers.
+func (d *DDNS) enqueue(key string, f func()) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	if _, ok := d.pending[key]; !ok {
+		d.order = append(d.order, key)
+	}
+	d.pending[key] = f
+	d.cond.Signal()
+}
+
+func (d *DDNS) printf(format string, v ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Printf(format, v...)
+	}
+}
+
+func (d *DDNS) domain() string {
+	if d.Domain != "" {
+		return ddns.Fqdn(d.Domain)
+	}
+	return ddns.Fqdn(d.Updater.ForwardZone)
+}
+
+// qualify returns the fully qualified form of a client supplied name, or an
+// empty string if the name cannot be registered.
+func (d *DDNS) qualify(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	fqdn := name
+	if !strings.HasSuffix(name, ".") {
+		fqdn = name + "." + d.domain()
+	}
+	if err := ddns.ValidateName(fqdn); err != nil {
+		d.printf("ddns: ignoring invalid client name %q: %v", name, err)
+		return ""
+	}
+	return strings.ToLower(fqdn)
+}
+
+// intent is what the server does for a client.
+type intent struct {
+	name    string
+	forward bool
+	reverse bool
+	// flags are the flags of the Client FQDN option in replies.
+	flags uint8
+}
+
+func (d *DDNS) intent(req *dhcpv4.DHCPv4) (*intent, *dhcpv4.FQDN) {
+	f := req.FQDN()
+	if f == nil {
+		if !d.UseHostName || req.HostName() == "" {
+			return nil, nil
+		}
+		name := d.qualify(req.HostName())
+		if name == "" {
+			return nil, nil
+		}
+		return &intent{name: name, forward: true, reverse: d.Updater.ReverseZone != ""}, nil
+	}
+	in := &intent{name: d.qualify(f.DomainName), flags: f.Flags & dhcpv4.FQDNFlagE}
+	switch {
+	case f.Flags&dhcpv4.FQDNFlagN != 0:
+		// RFC 4702, Section 4: the client asked for no updates at all.
+		in.flags |= dhcpv4.FQDNFlagN
+		return in, f
+	case f.Flags&dhcpv4.FQDNFlagS != 0:
+		in.forward = true
+		in.flags |= dhcpv4.FQDNFlagS
+	case !d.AllowClientUpdates:
+		in.forward = true
+		in.flags |= dhcpv4.FQDNFlagS | dhcpv4.FQDNFlagO
+	}
+	in.reverse = d.Updater.ReverseZone != ""
+	return in, f
+}
+
+// Reply implements ReplyHook.Reply. It answers the Client FQDN option, as
+// described in RFC 4702, Section 4.
+func (d *DDNS) Reply(req, reply *dhcpv4.DHCPv4, l *Lease) {
+	in, f := d.intent(req)
+	if f == nil {
+		return
+	}
+	reply.UpdateOption(dhcpv4.OptFQDN(&dhcpv4.FQDN{
+		Flags:      in.flags,
+		RCode1:     dhcpv4.FQDNServerRCode,
+		RCode2:     dhcpv4.FQDNServerRCode,
+		DomainName: in.name,
+	}))
+}
+
+// dhcid returns the DHCID record data of the client that sent req.
+func dhcid(req *dhcpv4.DHCPv4, name string) []byte {
+	if id := req.Options.Get(dhcpv4.OptionClientIdentifier); len(id) > 0 {
+		return ddns.DHCIDFromClientID(id, name)
+	}
+	return ddns.DHCIDFromHardwareAddr(uint8(req.HWType), req.ClientHWAddr, name)
+}
+
+// LeaseChanged implements Hook.LeaseChanged.
+func (d *DDNS) LeaseChanged(req *dhcpv4.DHCPv4, l *Lease) {
+	key := string(l.IP.To4())
+	var add *registration
+	if l.State == LeaseStateBound && req != nil {
+		if in, _ := d.intent(req); in != nil && in.name != "" && (in.forward || in.reverse) {
+			add = &registration{
+				name:    in.name,
+				dhcid:   dhcid(req, in.name),
+				forward: in.forward,
+				reverse: in.reverse,
+			}
+		}
+	}
+	d.enqueue(key, func() {
+		ctx := context.Background()
+		old, ok := d.regs[key]
+		if ok && add != nil && old.name == add.name && old.forward == add.forward && old.reverse == add.reverse {
+			// Renewal.
+			return
+		}
+		if ok {
+			d.remove(ctx, l, old)
+			delete(d.regs, key)
+		}
+		if add != nil {
+			if reg, ok := d.add(ctx, l, *add); ok {
+				d.regs[key] = reg
+			}
+		}
+	})
+}
+
+func (d *DDNS) add(ctx context.Context, l *Lease, reg registration) (registration, bool) {
+	if reg.forward {
+		err := d.Updater.AddForward(ctx, reg.name, l.IP, reg.dhcid)
+		if err != nil {
+			d.printf("ddns: unable to add %s for %s: %v", reg.name, l.IP, err)
+			// RFC 4703, Section 5.3.2: the PTR record is not updated
+			// for names of other clients.
+			if errors.Is(err, ddns.ErrConflict) {
+				return reg, false
+			}
+			reg.forward = false
+		}
+	}
+	if reg.reverse {
+		if err := d.Updater.AddReverse(ctx, l.IP, reg.name); err != nil {
+			d.printf("ddns: unable to add PTR for %s: %v", l.IP, err)
+			reg.reverse = false
+		}
+	}
+	return reg, reg.forward || reg.reverse
+}
+
+func (d *DDNS) remove(ctx context.Context, l *Lease, reg registration) {
+	if reg.forward {
+		if err := d.Updater.RemoveForward(ctx, reg.name, l.IP, reg.dhcid); err != nil {
+			d.printf("ddns: unable to remove %s for %s: %v", reg.name, l.IP, err)
+		}
+	}
+	if reg.reverse {
+		if err := d.Updater.RemoveReverse(ctx, l.IP, reg.name); err != nil {
+			d.printf("ddns: unable to remove PTR for %s: %v", l.IP, err)
+		}
+	}
+}
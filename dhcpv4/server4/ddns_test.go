@@ -0,0 +1,277 @@
+package server4
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/insomniacslk/dhcp/ddns/ddnstest"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDDNS(t *testing.T) (*DDNS, *ddnstest.Server) {
+	key := &ddns.Key{Name: "dhcp-key.", Secret: []byte("0123456789abcdef")}
+	s, err := ddnstest.NewServer(key, "example.com.", "0.0.10.in-addr.arpa.")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	d := NewDDNS(&ddns.Updater{
+		Client:      &ddns.Client{Server: s.Addr, Key: key, Timeout: time.Second},
+		ForwardZone: "example.com.",
+		ReverseZone: "0.0.10.in-addr.arpa.",
+	})
+	t.Cleanup(d.Close)
+	return d, s
+}
+
+func TestDDNSReply(t *testing.T) {
+	d, _ := newTestDDNS(t)
+	for _, tt := range []struct {
+		name       string
+		allow      bool
+		flags      uint8
+		domainName string
+		want       *dhcpv4.FQDN
+	}{
+		{
+			name:       "server updates",
+			flags:      dhcpv4.FQDNFlagS | dhcpv4.FQDNFlagE,
+			domainName: "host",
+			want:       &dhcpv4.FQDN{Flags: dhcpv4.FQDNFlagS | dhcpv4.FQDNFlagE, RCode1: 255, RCode2: 255, DomainName: "host.example.com."},
+		},
+		{
+			name:       "override",
+			flags:      0,
+			domainName: "Host.Example.Com.",
+			want:       &dhcpv4.FQDN{Flags: dhcpv4.FQDNFlagS | dhcpv4.FQDNFlagO, RCode1: 255, RCode2: 255, DomainName: "host.example.com."},
+		},
+		{
+			name:       "client updates",
+			allow:      true,
+			flags:      dhcpv4.FQDNFlagE,
+			domainName: "host",
+			want:       &dhcpv4.FQDN{Flags: dhcpv4.FQDNFlagE, RCode1: 255, RCode2: 255, DomainName: "host.example.com."},
+		},
+		{
+			name:       "no updates",
+			flags:      dhcpv4.FQDNFlagN,
+			domainName: "host",
+			want:       &dhcpv4.FQDN{Flags: dhcpv4.FQDNFlagN, RCode1: 255, RCode2: 255, DomainName: "host.example.com."},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d.AllowClientUpdates = tt.allow
+			req := newDiscover(t, mac1, dhcpv4.WithFQDN(tt.flags, tt.domainName))
+			reply, err := dhcpv4.NewReplyFromRequest(req)
+			require.NoError(t, err)
+			d.Reply(req, reply, &Lease{IP: net.IPv4(10, 0, 0, 10)})
+			require.Equal(t, tt.want, reply.FQDN())
+		})
+	}
+
+	// Clients without the option do not get it.
+	req := newDiscover(t, mac1)
+	reply, err := dhcpv4.NewReplyFromRequest(req)
+	require.NoError(t, err)
+	d.Reply(req, reply, &Lease{IP: net.IPv4(10, 0, 0, 10)})
+	require.Nil(t, reply.FQDN())
+}
+
+func TestDDNSLeases(t *testing.T) {
+	d, s := newTestDDNS(t)
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	a.Hooks = []Hook{d}
+
+	req := newDiscover(t, mac1, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "host"))
+	lease, err := a.Offer(context.Background(), req)
+	require.NoError(t, err)
+	_, err = a.Bind(req, lease.IP)
+	require.NoError(t, err)
+	// Pending updates of an address are merged, wait for this one.
+	require.Eventually(t, func() bool {
+		return len(s.Records("host.example.com.", ddns.TypeA)) == 1
+	}, time.Second, time.Millisecond)
+	// Renewals do not cause updates.
+	_, err = a.Bind(req, lease.IP)
+	require.NoError(t, err)
+
+	release := newDiscover(t, mac1, dhcpv4.WithMessageType(dhcpv4.MessageTypeRelease), dhcpv4.WithClientIP(lease.IP))
+	_, err = a.Release(release)
+	require.NoError(t, err)
+	d.Close()
+
+	// Add, then remove: A and DHCID, then PTR.
+	require.Len(t, s.Requests(), 5)
+	require.Empty(t, s.Names())
+}
+
+func TestDDNSRecords(t *testing.T) {
+	d, s := newTestDDNS(t)
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	a.Hooks = []Hook{d}
+
+	id := dhcpv4.WithOption(dhcpv4.OptClientIdentifier([]byte{1, 2, 3}))
+	req := newDiscover(t, mac1, id, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS|dhcpv4.FQDNFlagE, "host"))
+	lease, err := a.Bind(req, net.IPv4(10, 0, 0, 10))
+	require.NoError(t, err)
+
+	// The name of another client is not taken.
+	other := newDiscover(t, mac2, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "host"))
+	_, err = a.Bind(other, net.IPv4(10, 0, 0, 11))
+	require.NoError(t, err)
+
+	// Host names are only used if enabled.
+	d.UseHostName = true
+	named := newDiscover(t, net.HardwareAddr{2, 0, 0, 0, 0, 3}, dhcpv4.WithOption(dhcpv4.OptHostName("printer")))
+	_, err = a.Bind(named, net.IPv4(10, 0, 0, 12))
+	require.NoError(t, err)
+	d.Close()
+
+	require.Equal(t, [][]byte{lease.IP.To4()}, s.Records("host.example.com.", ddns.TypeA))
+	require.Equal(t, [][]byte{ddns.DHCIDFromClientID([]byte{1, 2, 3}, "host.example.com.")}, s.Records("host.example.com.", ddns.TypeDHCID))
+	ptr, err := ddns.EncodeName("host.example.com.")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{ptr}, s.Records("10.0.0.10.in-addr.arpa.", ddns.TypePTR))
+	require.Empty(t, s.Records("11.0.0.10.in-addr.arpa.", ddns.TypePTR))
+	require.Equal(t, [][]byte{{10, 0, 0, 12}}, s.Records("printer.example.com.", ddns.TypeA))
+}
+
+func TestDDNSInvalidNames(t *testing.T) {
+	d, s := newTestDDNS(t)
+	d.UseHostName = true
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	a.Hooks = []Hook{d}
+	var logs strings.Builder
+	d.Logger = log.New(&logs, "", 0)
+
+	for i, name := range []string{
+		"a..evil.example.com.",
+		strings.Repeat("a", 64),
+		strings.Repeat("a", 200),
+	} {
+		hwaddr := net.HardwareAddr{2, 0, 0, 0, 1, byte(i)}
+		req := newDiscover(t, hwaddr, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, name))
+		_, err := a.Bind(req, net.IPv4(10, 0, 0, byte(10+i)))
+		require.NoError(t, err)
+
+		hwaddr = net.HardwareAddr{2, 0, 0, 0, 2, byte(i)}
+		req = newDiscover(t, hwaddr, dhcpv4.WithOption(dhcpv4.OptHostName(name)))
+		_, err = a.Bind(req, net.IPv4(10, 0, 0, byte(15+i)))
+		require.NoError(t, err)
+	}
+	d.Close()
+
+	require.Empty(t, s.Requests())
+	require.Contains(t, logs.String(), "invalid client name")
+	require.NotContains(t, logs.String(), "unable to add")
+}
+
+func TestDDNSCoalesce(t *testing.T) {
+	d, s := newTestDDNS(t)
+	ip1, ip2 := net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 11)
+	bound := func(ip net.IP, hwaddr net.HardwareAddr) *Lease {
+		return &Lease{IP: ip, HardwareAddr: hwaddr, State: LeaseStateBound}
+	}
+	released := func(ip net.IP, hwaddr net.HardwareAddr) *Lease {
+		return &Lease{IP: ip, HardwareAddr: hwaddr, State: LeaseStateReleased}
+	}
+	d.LeaseChanged(newDiscover(t, mac2, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "other")), bound(ip2, mac2))
+	require.Eventually(t, func() bool {
+		return len(s.Records("11.0.0.10.in-addr.arpa.", ddns.TypePTR)) == 1
+	}, time.Second, time.Millisecond)
+	sent := len(s.Requests())
+
+	// Block the worker, and change the leases much more often than the
+	// DNS server can follow: lease changes must not wait for it, and the
+	// last state of each address must be sent.
+	block := make(chan struct{})
+	d.enqueue("block", func() { <-block })
+	for i := 0; i < 200; i++ {
+		req := newDiscover(t, mac1, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, fmt.Sprintf("host%d", i)))
+		d.LeaseChanged(req, bound(ip1, mac1))
+		d.LeaseChanged(nil, released(ip1, mac1))
+		d.LeaseChanged(nil, released(ip2, mac2))
+		d.LeaseChanged(newDiscover(t, mac2, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "other")), bound(ip2, mac2))
+	}
+	d.LeaseChanged(newDiscover(t, mac1, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "last")), bound(ip1, mac1))
+	d.LeaseChanged(nil, released(ip2, mac2))
+	close(block)
+	d.Close()
+
+	require.Equal(t, [][]byte{ip1.To4()}, s.Records("last.example.com.", ddns.TypeA))
+	require.Empty(t, s.Records("other.example.com.", ddns.TypeA))
+	require.Empty(t, s.Records("11.0.0.10.in-addr.arpa.", ddns.TypePTR))
+	// Add last, forward then reverse records, and remove other: address,
+	// DHCID and reverse records.
+	require.Len(t, s.Requests(), sent+5)
+}
+
+func TestDDNSExpiry(t *testing.T) {
+	d, s := newTestDDNS(t)
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	a.Hooks = []Hook{d}
+
+	req := newDiscover(t, mac1, dhcpv4.WithFQDN(dhcpv4.FQDNFlagS, "host.example.com."))
+	_, err := a.Bind(req, net.IPv4(10, 0, 0, 10))
+	require.NoError(t, err)
+	n, err := a.ExpireLeases()
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	now = now.Add(DefaultLeaseTime)
+	n, err = a.ExpireLeases()
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	l, err := a.Store().Get(net.IPv4(10, 0, 0, 10))
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateExpired, l.State)
+	d.Close()
+	require.Empty(t, s.Names())
+}
+
+func TestAllocatorHandlerFQDN(t *testing.T) {
+	d, s := newTestDDNS(t)
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	a.Hooks = []Hook{d}
+	h := a.Handler(net.IPv4(10, 0, 0, 1))
+
+	conn := &recordConn{}
+	req := newDiscover(t, mac1, dhcpv4.WithFQDN(0, "host"))
+	h(conn, &net.UDPAddr{}, req)
+	require.Len(t, conn.sent, 1)
+	offer := conn.sent[0]
+	require.Equal(t, dhcpv4.FQDNFlagS|dhcpv4.FQDNFlagO, offer.FQDN().Flags)
+
+	req.UpdateOption(dhcpv4.OptMessageType(dhcpv4.MessageTypeRequest))
+	req.UpdateOption(dhcpv4.OptServerIdentifier(net.IPv4(10, 0, 0, 1)))
+	req.UpdateOption(dhcpv4.OptRequestedIPAddress(offer.YourIPAddr))
+	h(conn, &net.UDPAddr{}, req)
+	require.Len(t, conn.sent, 2)
+	require.Equal(t, dhcpv4.MessageTypeAck, conn.sent[1].MessageType())
+	require.Equal(t, "host.example.com.", conn.sent[1].FQDN().DomainName)
+	d.Close()
+	require.Len(t, s.Records("host.example.com.", ddns.TypeA), 1)
+}
+
+// recordConn is a net.PacketConn that records the DHCPv4 messages written
+// to it.
+type recordConn struct {
+	net.PacketConn
+	sent []*dhcpv4.DHCPv4
+}
+
+func (c *recordConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	m, err := dhcpv4.FromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	c.sent = append(c.sent, m)
+	return len(b), nil
+}
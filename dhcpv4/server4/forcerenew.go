@@ -0,0 +1,102 @@
+package server4
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// ErrNoForcerenewNonce is returned by Forcerenew.ForceRenew if the client did
+// not get a nonce to authenticate FORCERENEW messages.
+var ErrNoForcerenewNonce = errors.New("client has no forcerenew nonce")
+
+// Forcerenew sends FORCERENEW messages to bound clients, as described in RFC
+// 3203, to make them renew their lease immediately, e.g. after a
+// configuration change.
+//
+// Messages are authenticated with the nonces of RFC 6704. Forcerenew is an
+// allocator ReplyHook that gives a nonce to the clients that support them in
+// their ACKs, so it cannot be used together with Auth.
+type Forcerenew struct {
+	// ServerID is the server identifier of FORCERENEW messages, which
+	// must be the one of the ACKs.
+	ServerID net.IP
+
+	conn    net.PacketConn
+	counter dhcpv4.ReplayCounter
+
+	mu sync.Mutex
+	// nonces are the nonces of clients, by address.
+	nonces map[string]forcerenewNonce
+}
+
+type forcerenewNonce struct {
+	clientID []byte
+	hwaddr   net.HardwareAddr
+	nonce    []byte
+}
+
+// NewForcerenew returns a Forcerenew that sends messages on conn.
+func NewForcerenew(conn net.PacketConn, serverID net.IP) *Forcerenew {
+	return &Forcerenew{
+		ServerID: serverID,
+		conn:     conn,
+		nonces:   make(map[string]forcerenewNonce),
+	}
+}
+
+// Reply implements ReplyHook.Reply. It adds the nonce of the client to ACKs,
+// if the client supports HMAC-MD5 nonces, as described in RFC 6704, Section
+// 3.1.2.
+func (f *Forcerenew) Reply(req, reply *dhcpv4.DHCPv4, l *Lease) {
+	if reply.MessageType() != dhcpv4.MessageTypeAck || l.State != LeaseStateBound ||
+		!req.ForcerenewNonceCapable().Supports(dhcpv4.AuthAlgorithmHMACMD5) {
+		return
+	}
+	key := string(l.IP.To4())
+	f.mu.Lock()
+	n, ok := f.nonces[key]
+	if !ok || !l.BelongsTo(n.clientID, n.hwaddr) {
+		nonce, err := dhcpv4.NewForcerenewNonce()
+		if err != nil {
+			f.mu.Unlock()
+			return
+		}
+		n = forcerenewNonce{clientID: l.ClientID, hwaddr: l.HardwareAddr, nonce: nonce}
+		f.nonces[key] = n
+	}
+	f.mu.Unlock()
+	reply.UpdateOption(dhcpv4.OptForcerenewNonce(n.nonce, f.counter.Next()))
+}
+
+// LeaseChanged implements Hook.LeaseChanged. It forgets the nonces of leases
+// that are no longer bound.
+func (f *Forcerenew) LeaseChanged(req *dhcpv4.DHCPv4, l *Lease) {
+	if l.State == LeaseStateBound {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.nonces, string(l.IP.To4()))
+}
+
+// ForceRenew sends a FORCERENEW message to the client holding l.
+func (f *Forcerenew) ForceRenew(l *Lease) error {
+	f.mu.Lock()
+	n, ok := f.nonces[string(l.IP.To4())]
+	f.mu.Unlock()
+	if !ok || !l.BelongsTo(n.clientID, n.hwaddr) {
+		return ErrNoForcerenewNonce
+	}
+	m, err := dhcpv4.NewForceRenew(f.ServerID, l.HardwareAddr, dhcpv4.WithClientIP(l.IP))
+	if err != nil {
+		return err
+	}
+	if err := dhcpv4.SignForceRenew(m, n.nonce, f.counter.Next()); err != nil {
+		return err
+	}
+	_, err = f.conn.WriteTo(m.ToBytes(), &net.UDPAddr{IP: l.IP, Port: dhcpv4.ClientPort})
+	return err
+}
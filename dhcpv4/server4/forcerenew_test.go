@@ -0,0 +1,74 @@
+package server4
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForcerenew(t *testing.T) {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	serverID := net.ParseIP("10.0.0.1").To4()
+
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	clientConn := nclient4.NewBroadcastUDPConn(clientRawConn, &net.UDPAddr{Port: nclient4.ClientPort})
+	serverConn := nclient4.NewBroadcastUDPConn(serverRawConn, &net.UDPAddr{Port: nclient4.ServerPort})
+	f := NewForcerenew(serverConn, serverID)
+	a.Hooks = []Hook{f}
+	s, err := NewServer("", nil, a.Handler(serverID), WithConn(serverConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, err := nclient4.NewWithConn(clientConn, mac1, nclient4.WithRetry(1), nclient4.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+
+	lease, err := c.Request(context.Background(), dhcpv4.WithForcerenewNonceCapable())
+	require.NoError(t, err)
+	nonce := lease.ACK.ForcerenewNonce()
+	require.Len(t, nonce, dhcpv4.ForcerenewNonceLen)
+	stored, err := a.Store().Get(lease.ACK.YourIPAddr)
+	require.NoError(t, err)
+
+	// Unauthenticated and replayed messages are discarded.
+	forged, err := dhcpv4.NewForceRenew(serverID, mac1)
+	require.NoError(t, err)
+	require.NoError(t, dhcpv4.SignForceRenew(forged, []byte("0123456789abcdef"), ^uint64(0)))
+	_, err = serverConn.WriteTo(forged.ToBytes(), &net.UDPAddr{IP: stored.IP, Port: dhcpv4.ClientPort})
+	require.NoError(t, err)
+	replayed, err := dhcpv4.NewForceRenew(serverID, mac1)
+	require.NoError(t, err)
+	require.NoError(t, dhcpv4.SignForceRenew(replayed, nonce, lease.ACK.Authentication().ReplayDetection))
+	_, err = serverConn.WriteTo(replayed.ToBytes(), &net.UDPAddr{IP: stored.IP, Port: dhcpv4.ClientPort})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitForceRenew(ctx, lease)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	require.NoError(t, f.ForceRenew(stored))
+	renewed, err := c.ForceRenew(context.Background(), lease, dhcpv4.WithForcerenewNonceCapable())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeAck, renewed.ACK.MessageType())
+	require.Equal(t, nonce, renewed.ACK.ForcerenewNonce())
+
+	// Clients that are not nonce capable cannot be forced to renew.
+	_, err = a.Release(newDiscover(t, mac1, dhcpv4.WithMessageType(dhcpv4.MessageTypeRelease), dhcpv4.WithClientIP(stored.IP)))
+	require.NoError(t, err)
+	lease, err = c.Request(context.Background())
+	require.NoError(t, err)
+	require.Nil(t, lease.ACK.ForcerenewNonce())
+	stored, err = a.Store().Get(lease.ACK.YourIPAddr)
+	require.NoError(t, err)
+	require.Equal(t, ErrNoForcerenewNonce, f.ForceRenew(stored))
+	_, err = c.WaitForceRenew(context.Background(), lease)
+	require.Equal(t, nclient4.ErrNoForcerenewNonce, err)
+}
@@ -0,0 +1,209 @@
+package server4
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LeaseState is the state of an address binding.
+type LeaseState int
+
+// Lease states.
+const (
+	// LeaseStateOffered means the address was offered to a client and is
+	// reserved until the offer expires.
+	LeaseStateOffered LeaseState = iota + 1
+	// LeaseStateBound means the address is leased to a client.
+	LeaseStateBound
+	// LeaseStateReleased means the client released the address.
+	LeaseStateReleased
+	// LeaseStateExpired means the lease expired.
+	LeaseStateExpired
+	// LeaseStateAbandoned means the address was found in use by another
+	// host, and is not handed out until the pool is exhausted.
+	LeaseStateAbandoned
+)
+
+var leaseStateToString = map[LeaseState]string{
+	LeaseStateOffered:   "offered",
+	LeaseStateBound:     "bound",
+	LeaseStateReleased:  "released",
+	LeaseStateExpired:   "expired",
+	LeaseStateAbandoned: "abandoned",
+}
+
+// String implements fmt.Stringer.
+func (s LeaseState) String() string {
+	if name, ok := leaseStateToString[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Lease is the binding of an address to a client.
+type Lease struct {
+	IP           net.IP
+	HardwareAddr net.HardwareAddr
+	// ClientID is the client identifier (option 61) of the client, if it
+	// sent one.
+	ClientID []byte
+	Hostname string
+	State    LeaseState
+	// Updated is the time of the last transaction with the client.
+	Updated time.Time
+	// Expiry is the time when the lease, or the offer, expires.
+	Expiry time.Time
+	// RelayAgentInfo is the value of the Relay Agent Information option
+	// (option 82) of the request that bound the address, if any.
+	RelayAgentInfo []byte
+}
+
+// Clone returns a deep copy of l.
+func (l *Lease) Clone() *Lease {
+	c := *l
+	c.IP = append(net.IP(nil), l.IP...)
+	c.HardwareAddr = append(net.HardwareAddr(nil), l.HardwareAddr...)
+	c.ClientID = append([]byte(nil), l.ClientID...)
+	c.RelayAgentInfo = append([]byte(nil), l.RelayAgentInfo...)
+	return &c
+}
+
+// Active returns whether l binds or reserves its address at time now.
+func (l *Lease) Active(now time.Time) bool {
+	return (l.State == LeaseStateOffered || l.State == LeaseStateBound) && now.Before(l.Expiry)
+}
+
+// BelongsTo returns whether l is held by the client with the given
+// identifier and hardware address. Clients are identified by their client
+// identifier if they have one, and by their hardware address otherwise, as
+// described in RFC 2131, Section 4.2.
+func (l *Lease) BelongsTo(clientID []byte, hwaddr net.HardwareAddr) bool {
+	return clientKey(l.ClientID, l.HardwareAddr) == clientKey(clientID, hwaddr)
+}
+
+func clientKey(clientID []byte, hwaddr net.HardwareAddr) string {
+	if len(clientID) > 0 {
+		return "id:" + string(clientID)
+	}
+	return "hw:" + string(hwaddr)
+}
+
+// ErrNoLease is returned by LeaseStore methods if there is no matching lease.
+var ErrNoLease = errors.New("no such lease")
+
+// LeaseStore stores leases, keyed by address.
+//
+// Implementations must be safe for concurrent use, and must not retain or
+// return leases that callers can modify.
+type LeaseStore interface {
+	// Get returns the lease of ip, or ErrNoLease.
+	Get(ip net.IP) (*Lease, error)
+	// GetByClient returns the most recently updated lease of a client, or
+	// ErrNoLease.
+	GetByClient(clientID []byte, hwaddr net.HardwareAddr) (*Lease, error)
+	// GetByHardwareAddr returns all the leases with the given hardware
+	// address.
+	GetByHardwareAddr(hwaddr net.HardwareAddr) ([]*Lease, error)
+	// Put adds or replaces the lease of l.IP.
+	Put(l *Lease) error
+	// Delete removes the lease of ip.
+	Delete(ip net.IP) error
+	// All returns all the leases, ordered by address.
+	All() ([]*Lease, error)
+}
+
+// MemoryLeaseStore is a LeaseStore that keeps leases in memory.
+type MemoryLeaseStore struct {
+	mu     sync.RWMutex
+	leases map[string]*Lease
+}
+
+// NewMemoryLeaseStore returns an empty MemoryLeaseStore.
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{leases: make(map[string]*Lease)}
+}
+
+// Get implements LeaseStore.Get.
+func (s *MemoryLeaseStore) Get(ip net.IP) (*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.leases[string(ip.To4())]
+	if !ok {
+		return nil, ErrNoLease
+	}
+	return l.Clone(), nil
+}
+
+// GetByClient implements LeaseStore.GetByClient.
+func (s *MemoryLeaseStore) GetByClient(clientID []byte, hwaddr net.HardwareAddr) (*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found *Lease
+	for _, l := range s.leases {
+		if l.BelongsTo(clientID, hwaddr) && (found == nil || l.Updated.After(found.Updated)) {
+			found = l
+		}
+	}
+	if found == nil {
+		return nil, ErrNoLease
+	}
+	return found.Clone(), nil
+}
+
+// GetByHardwareAddr implements LeaseStore.GetByHardwareAddr.
+func (s *MemoryLeaseStore) GetByHardwareAddr(hwaddr net.HardwareAddr) ([]*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var leases []*Lease
+	for _, l := range s.leases {
+		if bytes.Equal(l.HardwareAddr, hwaddr) {
+			leases = append(leases, l.Clone())
+		}
+	}
+	sortLeases(leases)
+	return leases, nil
+}
+
+// Put implements LeaseStore.Put.
+func (s *MemoryLeaseStore) Put(l *Lease) error {
+	ip := l.IP.To4()
+	if ip == nil {
+		return errors.New("lease address is not an IPv4 address")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := l.Clone()
+	c.IP = ip
+	s.leases[string(ip)] = c
+	return nil
+}
+
+// Delete implements LeaseStore.Delete.
+func (s *MemoryLeaseStore) Delete(ip net.IP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.leases, string(ip.To4()))
+	return nil
+}
+
+// All implements LeaseStore.All.
+func (s *MemoryLeaseStore) All() ([]*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	leases := make([]*Lease, 0, len(s.leases))
+	for _, l := range s.leases {
+		leases = append(leases, l.Clone())
+	}
+	sortLeases(leases)
+	return leases, nil
+}
+
+func sortLeases(leases []*Lease) {
+	sort.Slice(leases, func(i, j int) bool {
+		return bytes.Compare(leases[i].IP.To4(), leases[j].IP.To4()) < 0
+	})
+}
@@ -0,0 +1,56 @@
+package server4
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLeaseStore(t *testing.T) {
+	s := NewMemoryLeaseStore()
+	now := time.Now()
+	require.NoError(t, s.Put(&Lease{IP: net.ParseIP("10.0.0.2"), HardwareAddr: mac1, State: LeaseStateReleased, Updated: now}))
+	require.NoError(t, s.Put(&Lease{IP: net.ParseIP("10.0.0.1"), HardwareAddr: mac1, State: LeaseStateBound, Updated: now.Add(time.Second)}))
+	require.NoError(t, s.Put(&Lease{IP: net.ParseIP("10.0.0.3"), HardwareAddr: mac2, ClientID: []byte("id"), State: LeaseStateBound}))
+	require.Error(t, s.Put(&Lease{IP: net.ParseIP("2001:db8::1")}))
+
+	l, err := s.Get(net.IPv4(10, 0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, LeaseStateBound, l.State)
+	// Leases returned by the store are copies.
+	l.HardwareAddr[0] = 0xff
+	l, err = s.Get(net.IPv4(10, 0, 0, 1))
+	require.NoError(t, err)
+	require.Equal(t, mac1, l.HardwareAddr)
+
+	l, err = s.GetByClient(nil, mac1)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", l.IP.String())
+	l, err = s.GetByClient([]byte("id"), nil)
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.3", l.IP.String())
+	// Clients with a client identifier are not found by hardware address.
+	_, err = s.GetByClient(nil, mac2)
+	require.Equal(t, ErrNoLease, err)
+
+	leases, err := s.GetByHardwareAddr(mac1)
+	require.NoError(t, err)
+	require.Len(t, leases, 2)
+	require.Equal(t, "10.0.0.1", leases[0].IP.String())
+
+	require.NoError(t, s.Delete(net.IPv4(10, 0, 0, 1)))
+	_, err = s.Get(net.IPv4(10, 0, 0, 1))
+	require.Equal(t, ErrNoLease, err)
+	leases, err = s.All()
+	require.NoError(t, err)
+	require.Len(t, leases, 2)
+	require.Equal(t, "10.0.0.2", leases[0].IP.String())
+	require.Equal(t, "10.0.0.3", leases[1].IP.String())
+}
+
+func TestLeaseState(t *testing.T) {
+	require.Equal(t, "abandoned", LeaseStateAbandoned.String())
+	require.Equal(t, "unknown", LeaseState(0).String())
+}
@@ -0,0 +1,206 @@
+package server4
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// ErrMalformedLeasequery is returned by Leasequery.Reply for queries without
+// an address, a client identifier or a hardware address.
+var ErrMalformedLeasequery = errors.New("leasequery has no address, client identifier or hardware address")
+
+// Leasequery answers DHCPLEASEQUERY messages from a LeaseStore, as described
+// in RFC 4388. It wraps a Handler: other messages are passed to it.
+//
+// Queries may be by address (ciaddr), by client identifier (option 61) or by
+// hardware address (chaddr). Replies are sent to the requestor.
+type Leasequery struct {
+	Store LeaseStore
+	// ServerID is the server identifier of the replies.
+	ServerID net.IP
+	// Contains returns whether the server is authoritative for ip.
+	// Addresses without a lease are DHCPLEASEUNASSIGNED if it returns
+	// true, and DHCPLEASEUNKNOWN otherwise. If nil, they are all unknown.
+	// Allocator.Contains may be used.
+	Contains func(ip net.IP) bool
+	// Allow, if not nil, returns whether to answer the query m sent by
+	// peer. Other queries are dropped.
+	Allow func(peer net.Addr, m *dhcpv4.DHCPv4) bool
+	// Logger, if not nil, logs dropped queries.
+	Logger Printfer
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewLeasequery returns a Leasequery that answers from store.
+func NewLeasequery(store LeaseStore, serverID net.IP) *Leasequery {
+	return &Leasequery{Store: store, ServerID: serverID}
+}
+
+func (q *Leasequery) time() time.Time {
+	if q.now != nil {
+		return q.now()
+	}
+	return time.Now()
+}
+
+func (q *Leasequery) printf(format string, v ...interface{}) {
+	if q.Logger != nil {
+		q.Logger.Printf(format, v...)
+	}
+}
+
+// Handler returns a Handler that answers DHCPLEASEQUERY messages, and passes
+// the other messages to next.
+func (q *Leasequery) Handler(next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+		if m.OpCode != dhcpv4.OpcodeBootRequest || m.MessageType() != dhcpv4.MessageTypeLeaseQuery {
+			next(conn, peer, m)
+			return
+		}
+		// RFC 4388, Section 6.3: queries come from relay agents, or
+		// act as such.
+		if m.GatewayIPAddr == nil || m.GatewayIPAddr.IsUnspecified() {
+			q.printf("leasequery: dropping query without giaddr from %s", peer)
+			return
+		}
+		if q.Allow != nil && !q.Allow(peer, m) {
+			q.printf("leasequery: dropping query from %s", peer)
+			return
+		}
+		reply, err := q.Reply(m)
+		if err != nil {
+			q.printf("leasequery: cannot answer query from %s: %v", peer, err)
+			return
+		}
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+// Reply returns the reply to the DHCPLEASEQUERY message m:
+// DHCPLEASEACTIVE, DHCPLEASEUNASSIGNED or DHCPLEASEUNKNOWN.
+func (q *Leasequery) Reply(m *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, error) {
+	now := q.time()
+	var (
+		active []*Lease
+		known  bool
+	)
+	switch clientID := m.Options.Get(dhcpv4.OptionClientIdentifier); {
+	case m.ClientIPAddr != nil && !m.ClientIPAddr.IsUnspecified():
+		l, err := q.Store.Get(m.ClientIPAddr)
+		switch {
+		case err == ErrNoLease:
+			known = q.Contains != nil && q.Contains(m.ClientIPAddr)
+		case err != nil:
+			return nil, err
+		default:
+			known = true
+			if l.State == LeaseStateBound && l.Active(now) {
+				// RFC 4388, Section 6.4.1: the other addresses of
+				// the client are associated too.
+				active, err = q.clientLeases(now, l.ClientID, l.HardwareAddr)
+				if err != nil {
+					return nil, err
+				}
+				active = moveFirst(active, l)
+			}
+		}
+	case len(clientID) > 0:
+		leases, err := q.clientLeases(now, clientID, nil)
+		if err != nil {
+			return nil, err
+		}
+		active = leases
+	case len(m.ClientHWAddr) > 0:
+		leases, err := q.Store.GetByHardwareAddr(m.ClientHWAddr)
+		if err != nil {
+			return nil, err
+		}
+		active = activeLeases(now, leases)
+	default:
+		return nil, ErrMalformedLeasequery
+	}
+
+	mods := []dhcpv4.Modifier{dhcpv4.WithOption(dhcpv4.OptServerIdentifier(q.ServerID))}
+	switch {
+	case len(active) > 0:
+		l := active[0]
+		mods = append(mods,
+			dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseActive),
+			dhcpv4.WithClientIP(l.IP),
+			dhcpv4.WithHWType(iana.HWTypeEthernet),
+			dhcpv4.WithHwAddr(l.HardwareAddr),
+			dhcpv4.WithOption(dhcpv4.OptIPAddressLeaseTime(l.Expiry.Sub(now).Truncate(time.Second))),
+			dhcpv4.WithOption(dhcpv4.OptClientLastTransactionTime(now.Sub(l.Updated).Truncate(time.Second))),
+		)
+		if len(l.ClientID) > 0 {
+			mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClientIdentifier(l.ClientID)))
+		}
+		if len(l.RelayAgentInfo) > 0 {
+			mods = append(mods, dhcpv4.WithGeneric(dhcpv4.OptionRelayAgentInformation, l.RelayAgentInfo))
+		}
+		if len(active) > 1 {
+			ips := make([]net.IP, 0, len(active))
+			for _, a := range active {
+				ips = append(ips, a.IP)
+			}
+			mods = append(mods, dhcpv4.WithOption(dhcpv4.OptAssociatedIP(ips...)))
+		}
+	case known:
+		mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseUnassigned))
+	default:
+		mods = append(mods, dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseUnknown))
+	}
+	return dhcpv4.NewReplyFromRequest(m, dhcpv4.PrependModifiers(mods,
+		// Replies to queries by address carry the address, RFC
+		// 4388, Section 6.4.
+		dhcpv4.WithClientIP(m.ClientIPAddr),
+	)...)
+}
+
+// clientLeases returns the active bound leases of a client, most recently
+// updated first.
+func (q *Leasequery) clientLeases(now time.Time, clientID []byte, hwaddr net.HardwareAddr) ([]*Lease, error) {
+	all, err := q.Store.All()
+	if err != nil {
+		return nil, err
+	}
+	var leases []*Lease
+	for _, l := range all {
+		if l.BelongsTo(clientID, hwaddr) {
+			leases = append(leases, l)
+		}
+	}
+	return activeLeases(now, leases), nil
+}
+
+// activeLeases returns the active bound leases among leases, most recently
+// updated first.
+func activeLeases(now time.Time, leases []*Lease) []*Lease {
+	var active []*Lease
+	for _, l := range leases {
+		if l.State == LeaseStateBound && l.Active(now) {
+			active = append(active, l)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Updated.After(active[j].Updated) })
+	return active
+}
+
+// moveFirst moves the lease of l.IP to the front of leases.
+func moveFirst(leases []*Lease, l *Lease) []*Lease {
+	out := []*Lease{l}
+	for _, o := range leases {
+		if !bytes.Equal(o.IP.To4(), l.IP.To4()) {
+			out = append(out, o)
+		}
+	}
+	return out
+}
@@ -0,0 +1,203 @@
+package server4
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/stretchr/testify/require"
+)
+
+var testGIAddr = dhcpv4.WithGatewayIP(net.IPv4(192, 0, 2, 1))
+
+var testRelayAgentInfo = dhcpv4.OptRelayAgentInfo(
+	dhcpv4.OptGeneric(dhcpv4.AgentRemoteIDSubOption, []byte("remote1")),
+	dhcpv4.OptGeneric(dhcpv4.RelayIDSubOption, []byte("relay1")),
+).Value.ToBytes()
+
+func newTestLeasequery(t *testing.T, now time.Time) *Leasequery {
+	a := newTestAllocator(t, "10.0.0.10", "10.0.0.20")
+	for _, l := range []*Lease{
+		{IP: net.IPv4(10, 0, 0, 10), HardwareAddr: mac1, ClientID: []byte("c1"), State: LeaseStateBound, Updated: now.Add(-10 * time.Second), Expiry: now.Add(time.Hour), RelayAgentInfo: testRelayAgentInfo},
+		{IP: net.IPv4(10, 0, 0, 11), HardwareAddr: mac1, ClientID: []byte("c1"), State: LeaseStateBound, Updated: now.Add(-20 * time.Second), Expiry: now.Add(time.Minute)},
+		{IP: net.IPv4(10, 0, 0, 12), HardwareAddr: mac2, State: LeaseStateReleased, Updated: now.Add(-time.Hour), Expiry: now.Add(-time.Hour)},
+		{IP: net.IPv4(10, 0, 0, 13), HardwareAddr: mac2, State: LeaseStateOffered, Updated: now, Expiry: now.Add(time.Minute)},
+	} {
+		require.NoError(t, a.Store().Put(l))
+	}
+	q := NewLeasequery(a.Store(), net.IPv4(10, 0, 0, 1))
+	q.Contains = a.Contains
+	q.now = func() time.Time { return now }
+	return q
+}
+
+func TestLeasequeryReply(t *testing.T) {
+	now := time.Now()
+	q := newTestLeasequery(t, now)
+
+	for _, tt := range []struct {
+		name       string
+		query      func() (*dhcpv4.DHCPv4, error)
+		wantType   dhcpv4.MessageType
+		wantIP     net.IP
+		associated []net.IP
+	}{
+		{
+			name:       "active address",
+			query:      func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 10)) },
+			wantType:   dhcpv4.MessageTypeLeaseActive,
+			wantIP:     net.IPv4(10, 0, 0, 10),
+			associated: []net.IP{net.IPv4(10, 0, 0, 10).To4(), net.IPv4(10, 0, 0, 11).To4()},
+		},
+		{
+			name:       "other address of the client",
+			query:      func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 11)) },
+			wantType:   dhcpv4.MessageTypeLeaseActive,
+			wantIP:     net.IPv4(10, 0, 0, 11),
+			associated: []net.IP{net.IPv4(10, 0, 0, 11).To4(), net.IPv4(10, 0, 0, 10).To4()},
+		},
+		{
+			name:     "released address",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 12)) },
+			wantType: dhcpv4.MessageTypeLeaseUnassigned,
+			wantIP:   net.IPv4(10, 0, 0, 12),
+		},
+		{
+			name:     "offered address",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 13)) },
+			wantType: dhcpv4.MessageTypeLeaseUnassigned,
+			wantIP:   net.IPv4(10, 0, 0, 13),
+		},
+		{
+			name:     "free address",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 0, 0, 15)) },
+			wantType: dhcpv4.MessageTypeLeaseUnassigned,
+			wantIP:   net.IPv4(10, 0, 0, 15),
+		},
+		{
+			name:     "foreign address",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByIP(net.IPv4(10, 1, 0, 1)) },
+			wantType: dhcpv4.MessageTypeLeaseUnknown,
+			wantIP:   net.IPv4(10, 1, 0, 1),
+		},
+		{
+			name:       "client identifier",
+			query:      func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByClientID([]byte("c1")) },
+			wantType:   dhcpv4.MessageTypeLeaseActive,
+			wantIP:     net.IPv4(10, 0, 0, 10),
+			associated: []net.IP{net.IPv4(10, 0, 0, 10).To4(), net.IPv4(10, 0, 0, 11).To4()},
+		},
+		{
+			name:     "unknown client identifier",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByClientID([]byte("c2")) },
+			wantType: dhcpv4.MessageTypeLeaseUnknown,
+			wantIP:   net.IPv4zero,
+		},
+		{
+			name:       "hardware address",
+			query:      func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByHardwareAddr(mac1) },
+			wantType:   dhcpv4.MessageTypeLeaseActive,
+			wantIP:     net.IPv4(10, 0, 0, 10),
+			associated: []net.IP{net.IPv4(10, 0, 0, 10).To4(), net.IPv4(10, 0, 0, 11).To4()},
+		},
+		{
+			name:     "hardware address without active lease",
+			query:    func() (*dhcpv4.DHCPv4, error) { return dhcpv4.NewLeasequeryByHardwareAddr(mac2) },
+			wantType: dhcpv4.MessageTypeLeaseUnknown,
+			wantIP:   net.IPv4zero,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.query()
+			require.NoError(t, err)
+			reply, err := q.Reply(m)
+			require.NoError(t, err)
+			require.Equal(t, dhcpv4.OpcodeBootReply, reply.OpCode)
+			require.Equal(t, m.TransactionID, reply.TransactionID)
+			require.Equal(t, tt.wantType, reply.MessageType())
+			require.True(t, tt.wantIP.Equal(reply.ClientIPAddr), "got %s", reply.ClientIPAddr)
+			require.True(t, reply.ServerIdentifier().Equal(net.IPv4(10, 0, 0, 1)))
+			require.Equal(t, tt.associated, reply.AssociatedIP())
+			if tt.wantType != dhcpv4.MessageTypeLeaseActive {
+				_, ok := reply.ClientLastTransactionTime()
+				require.False(t, ok)
+				return
+			}
+			require.Equal(t, mac1, reply.ClientHWAddr)
+			require.Equal(t, []byte("c1"), reply.Options.Get(dhcpv4.OptionClientIdentifier))
+			cltt, ok := reply.ClientLastTransactionTime()
+			require.True(t, ok)
+			lt := reply.IPAddressLeaseTime(0)
+			if tt.wantIP.Equal(net.IPv4(10, 0, 0, 11)) {
+				require.Equal(t, 20*time.Second, cltt)
+				require.Equal(t, time.Minute, lt)
+				require.Nil(t, reply.RelayAgentInfo())
+			} else {
+				require.Equal(t, 10*time.Second, cltt)
+				require.Equal(t, time.Hour, lt)
+				require.Equal(t, testRelayAgentInfo, reply.Options.Get(dhcpv4.OptionRelayAgentInformation))
+			}
+		})
+	}
+
+	m, err := dhcpv4.New(dhcpv4.WithMessageType(dhcpv4.MessageTypeLeaseQuery), dhcpv4.WithHwAddr(nil))
+	require.NoError(t, err)
+	_, err = q.Reply(m)
+	require.Equal(t, ErrMalformedLeasequery, err)
+}
+
+func TestLeasequeryHandler(t *testing.T) {
+	q := newTestLeasequery(t, time.Now())
+	q.Allow = func(peer net.Addr, m *dhcpv4.DHCPv4) bool {
+		return m.GatewayIPAddr.Equal(net.IPv4(192, 0, 2, 1))
+	}
+	serverID := net.IPv4(10, 0, 0, 1).To4()
+	a, err := NewAllocator(net.IPv4(10, 0, 0, 10), net.IPv4(10, 0, 0, 20), q.Store)
+	require.NoError(t, err)
+
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	clientConn := nclient4.NewBroadcastUDPConn(clientRawConn, &net.UDPAddr{Port: nclient4.ClientPort})
+	serverConn := nclient4.NewBroadcastUDPConn(serverRawConn, &net.UDPAddr{Port: nclient4.ServerPort})
+	s, err := NewServer("", nil, q.Handler(a.Handler(serverID)), WithConn(serverConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, err := nclient4.NewWithConn(clientConn, net.HardwareAddr{2, 0, 0, 0, 0, 3}, nclient4.WithRetry(1), nclient4.WithTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close()
+	dest := &net.UDPAddr{IP: serverID, Port: nclient4.ServerPort}
+
+	// Queries that are not allowed, or without giaddr, are dropped.
+	_, err = c.LeasequeryByIP(context.Background(), dest, net.IPv4(10, 0, 0, 10), dhcpv4.WithGatewayIP(net.IPv4(192, 0, 2, 2)))
+	require.Equal(t, nclient4.ErrNoResponse, err)
+	_, err = c.LeasequeryByIP(context.Background(), dest, net.IPv4(10, 0, 0, 10))
+	require.Equal(t, nclient4.ErrNoResponse, err)
+
+	reply, err := c.LeasequeryByIP(context.Background(), dest, net.IPv4(10, 0, 0, 10), testGIAddr)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeLeaseActive, reply.MessageType())
+	require.Equal(t, mac1, reply.ClientHWAddr)
+	reply, err = c.LeasequeryByHardwareAddr(context.Background(), dest, mac2, testGIAddr)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeLeaseUnknown, reply.MessageType())
+	reply, err = c.LeasequeryByClientID(context.Background(), dest, []byte("c1"), testGIAddr)
+	require.NoError(t, err)
+	require.True(t, reply.ClientIPAddr.Equal(net.IPv4(10, 0, 0, 10)))
+
+	// Other messages are passed through.
+	lease, err := c.Request(context.Background())
+	require.NoError(t, err)
+	reply, err = c.LeasequeryByIP(context.Background(), dest, lease.ACK.YourIPAddr, testGIAddr)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeLeaseActive, reply.MessageType())
+	require.Equal(t, c.InterfaceAddr(), reply.ClientHWAddr)
+
+	_, err = c.Leasequery(context.Background(), dest, lease.ACK)
+	require.Error(t, err)
+}
@@ -64,11 +64,15 @@ import (
 // valid DHCPv4 message is received
 type Handler func(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4)
 
+// RawHandler is like Handler, and is also given the message as received, raw.
+type RawHandler func(conn net.PacketConn, peer net.Addr, raw []byte, m *dhcpv4.DHCPv4)
+
 // Server represents a DHCPv4 server object
 type Server struct {
-	conn    net.PacketConn
-	Handler Handler
-	logger  Logger
+	conn       net.PacketConn
+	Handler    Handler
+	rawHandler RawHandler
+	logger     Logger
 }
 
 // Serve serves requests.
@@ -104,7 +108,11 @@ func (s *Server) Serve() error {
 				Port: upeer.Port,
 			}
 		}
-		go s.Handler(s.conn, upeer, m)
+		if s.rawHandler != nil {
+			go s.rawHandler(s.conn, upeer, rbuf[:n], m)
+		} else {
+			go s.Handler(s.conn, upeer, m)
+		}
 	}
 }
 
@@ -123,6 +131,14 @@ func WithConn(c net.PacketConn) ServerOpt {
 	}
 }
 
+// WithRawHandler configures the server to call h, instead of the Handler, with
+// every valid DHCPv4 message received.
+func WithRawHandler(h RawHandler) ServerOpt {
+	return func(s *Server) {
+		s.rawHandler = h
+	}
+}
+
 // NewServer initializes and returns a new Server object
 func NewServer(ifname string, addr *net.UDPAddr, handler Handler, opt ...ServerOpt) (*Server, error) {
 	s := &Server{
@@ -129,3 +129,33 @@ func TestBadAddrFamily(t *testing.T) {
 		t.Fatal("Expected server4.NewServer to fail with an IPv6 address")
 	}
 }
+
+func TestServerRawHandler(t *testing.T) {
+	type received struct {
+		raw []byte
+		m   *dhcpv4.DHCPv4
+	}
+	got := make(chan received, 1)
+	h := func(conn net.PacketConn, peer net.Addr, raw []byte, m *dhcpv4.DHCPv4) {
+		got <- received{raw, m}
+	}
+	s, err := NewServer("", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, nil, WithRawHandler(h))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	m, err := dhcpv4.New()
+	require.NoError(t, err)
+	// A pad option the parsed message does not keep.
+	b := m.ToBytes()
+	b = append(append(append([]byte(nil), b[:240]...), 0), b[240:]...)
+	conn, err := net.Dial("udp", s.conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(b)
+	require.NoError(t, err)
+
+	r := <-got
+	require.Equal(t, b, r.raw)
+	require.Equal(t, m.TransactionID, r.m.TransactionID)
+}
@@ -0,0 +1,40 @@
+package dhcpv4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Messages sent over TCP, such as bulk leasequery messages, are framed by a
+// 2-byte message size in network byte order, as described in RFC 6926,
+// Section 6.3.
+
+// ReadTCPMessage reads a message framed for TCP from r.
+func ReadTCPMessage(r io.Reader) (*DHCPv4, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	b := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return FromBytes(b)
+}
+
+// WriteTCPMessage writes m, framed for TCP, to w.
+func WriteTCPMessage(w io.Writer, m *DHCPv4) error {
+	b := m.ToBytes()
+	if len(b) > 0xffff {
+		return fmt.Errorf("message too large for TCP: %d bytes", len(b))
+	}
+	out := make([]byte, 2, 2+len(b))
+	binary.BigEndian.PutUint16(out, uint16(len(b)))
+	_, err := w.Write(append(out, b...))
+	return err
+}
@@ -0,0 +1,36 @@
+package dhcpv4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPMessage(t *testing.T) {
+	m, err := NewBulkLeasequery()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, WriteTCPMessage(&buf, m))
+	require.NoError(t, WriteTCPMessage(&buf, m))
+	b := m.ToBytes()
+	require.Equal(t, 2*(2+len(b)), buf.Len())
+	require.Equal(t, []byte{byte(len(b) >> 8), byte(len(b))}, buf.Bytes()[:2])
+
+	for i := 0; i < 2; i++ {
+		got, err := ReadTCPMessage(&buf)
+		require.NoError(t, err)
+		require.Equal(t, b, got.ToBytes())
+	}
+	_, err = ReadTCPMessage(&buf)
+	require.Equal(t, io.EOF, err)
+
+	// Truncated messages.
+	_, err = ReadTCPMessage(bytes.NewReader([]byte{0}))
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = ReadTCPMessage(bytes.NewReader([]byte{0, 10}))
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	_, err = ReadTCPMessage(bytes.NewReader([]byte{0, 2, 1, 1}))
+	require.Error(t, err)
+}
@@ -36,6 +36,22 @@ const (
 	MessageTypeNak      MessageType = 6
 	MessageTypeRelease  MessageType = 7
 	MessageTypeInform   MessageType = 8
+	// MessageTypeForceRenew is described by RFC 3203.
+	MessageTypeForceRenew MessageType = 9
+	// Leasequery message types are described by RFC 4388, Section 6.1.
+	MessageTypeLeaseQuery      MessageType = 10
+	MessageTypeLeaseUnassigned MessageType = 11
+	MessageTypeLeaseUnknown    MessageType = 12
+	MessageTypeLeaseActive     MessageType = 13
+	// Bulk leasequery message types are described by RFC 6926, Section
+	// 6.2.1.
+	MessageTypeBulkLeaseQuery MessageType = 14
+	MessageTypeLeaseQueryDone MessageType = 15
+	// Active leasequery message types are described by RFC 7724, Section
+	// 5.2.1.
+	MessageTypeActiveLeaseQuery MessageType = 16
+	MessageTypeLeaseQueryStatus MessageType = 17
+	MessageTypeTLS              MessageType = 18
 )
 
 // ToBytes returns the serialized version of this option described by RFC 2132,
@@ -61,14 +77,24 @@ func (m *MessageType) FromBytes(data []byte) error {
 }
 
 var messageTypeToString = map[MessageType]string{
-	MessageTypeDiscover: "DISCOVER",
-	MessageTypeOffer:    "OFFER",
-	MessageTypeRequest:  "REQUEST",
-	MessageTypeDecline:  "DECLINE",
-	MessageTypeAck:      "ACK",
-	MessageTypeNak:      "NAK",
-	MessageTypeRelease:  "RELEASE",
-	MessageTypeInform:   "INFORM",
+	MessageTypeDiscover:         "DISCOVER",
+	MessageTypeOffer:            "OFFER",
+	MessageTypeRequest:          "REQUEST",
+	MessageTypeDecline:          "DECLINE",
+	MessageTypeAck:              "ACK",
+	MessageTypeNak:              "NAK",
+	MessageTypeRelease:          "RELEASE",
+	MessageTypeInform:           "INFORM",
+	MessageTypeForceRenew:       "FORCERENEW",
+	MessageTypeLeaseQuery:       "LEASEQUERY",
+	MessageTypeLeaseUnassigned:  "LEASEUNASSIGNED",
+	MessageTypeLeaseUnknown:     "LEASEUNKNOWN",
+	MessageTypeLeaseActive:      "LEASEACTIVE",
+	MessageTypeBulkLeaseQuery:   "BULKLEASEQUERY",
+	MessageTypeLeaseQueryDone:   "LEASEQUERYDONE",
+	MessageTypeActiveLeaseQuery: "ACTIVELEASEQUERY",
+	MessageTypeLeaseQueryStatus: "LEASEQUERYSTATUS",
+	MessageTypeTLS:              "TLS",
 }
 
 // OpcodeType represents a DHCPv4 opcode.
@@ -272,7 +298,9 @@ const (
 	OptionSIPUAConfigurationServiceDomains      optionCode = 141
 	OptionOPTIONIPv4AddressANDSF                optionCode = 142
 	OptionOPTIONIPv6AddressANDSF                optionCode = 143
-	// Options 144-149 returned in RFC 3679
+	// Option 144 returned in RFC 3679
+	OptionForcerenewNonceCapable optionCode = 145
+	// Options 146-149 returned in RFC 3679
 	OptionTFTPServerAddress optionCode = 150
 	OptionStatusCode        optionCode = 151
 	OptionBaseTime          optionCode = 152
@@ -439,14 +467,16 @@ var optionCodeToString = map[OptionCode]string{
 	OptionSIPUAConfigurationServiceDomains:      "SIP UA Configuration Service Domains",
 	OptionOPTIONIPv4AddressANDSF:                "OPTION-IPv4_Address-ANDSF",
 	OptionOPTIONIPv6AddressANDSF:                "OPTION-IPv6_Address-ANDSF",
-	// Options 144-149 returned in RFC 3679
+	// Option 144 returned in RFC 3679
+	OptionForcerenewNonceCapable: "Forcerenew Nonce Capable",
+	// Options 146-149 returned in RFC 3679
 	OptionTFTPServerAddress: "TFTP Server Address",
 	OptionStatusCode:        "Status Code",
 	OptionBaseTime:          "Base Time",
 	OptionStartTimeOfState:  "Start Time of State",
 	OptionQueryStartTime:    "Query Start Time",
 	OptionQueryEndTime:      "Query End Time",
-	OptionDHCPState:         "DHCP Staet",
+	OptionDHCPState:         "DHCP State",
 	OptionDataSource:        "Data Source",
 	// Options 158-161 returned in RFC 3679
 	OptionV4DNR: "Encrypted DNS",
@@ -0,0 +1,184 @@
+package dhcpv4
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// ErrNoVendorDecoder is returned when no decoder is registered for the vendor
+// of a vendor-specific option.
+var ErrNoVendorDecoder = errors.New("no decoder registered for vendor")
+
+// VendorDecoderConstructor returns a new, empty decoder for vendor-specific
+// sub-options. The decoder's FromBytes method is called with the option data
+// after construction.
+type VendorDecoderConstructor func() OptionDecoder
+
+// VendorRegistry maps vendors to the decoders of their vendor-specific
+// sub-options.
+//
+// The Vendor Specific Information option (43) is decoded according to the
+// Class Identifier option (60), matched by prefix: the longest registered
+// prefix wins. Each entry of the Vendor-Identifying Vendor-Specific
+// Information option (125) is decoded according to its enterprise number.
+//
+// Lookups that find no decoder in a registry fall back to the global registry
+// (see RegisterVendorClassDecoder and RegisterEnterpriseDecoder), which
+// comes with decoders for PXE, Cisco, Arista and Microsoft. A VendorRegistry
+// is safe for concurrent use.
+type VendorRegistry struct {
+	mu          sync.RWMutex
+	parent      *VendorRegistry
+	classes     map[string]VendorDecoderConstructor
+	enterprises map[iana.EnterpriseID]VendorDecoderConstructor
+}
+
+// defaultVendorRegistry is used by the accessors and by Summary.
+var defaultVendorRegistry = func() *VendorRegistry {
+	r := &VendorRegistry{}
+	r.RegisterVendorClass("PXEClient", func() OptionDecoder { return &PXEOptions{} })
+	r.RegisterVendorClass("Cisco AP", func() OptionDecoder { return &CiscoOptions{} })
+	r.RegisterEnterprise(iana.EnterpriseIDCiscoSystems, func() OptionDecoder { return &CiscoOptions{} })
+	r.RegisterVendorClass("Arista;", func() OptionDecoder { return &AristaOptions{} })
+	r.RegisterEnterprise(iana.EnterpriseIDAristaNetworks, func() OptionDecoder { return &AristaOptions{} })
+	r.RegisterVendorClass("MSFT", func() OptionDecoder { return &MicrosoftOptions{} })
+	r.RegisterEnterprise(iana.EnterpriseIDMicrosoft, func() OptionDecoder { return &MicrosoftOptions{} })
+	return r
+}()
+
+// NewVendorRegistry returns an empty registry that falls back to the global
+// registry.
+func NewVendorRegistry() *VendorRegistry {
+	return &VendorRegistry{parent: defaultVendorRegistry}
+}
+
+// RegisterVendorClassDecoder globally registers ctor as the decoder of the
+// Vendor Specific Information option for clients whose class identifier
+// starts with prefix.
+func RegisterVendorClassDecoder(prefix string, ctor VendorDecoderConstructor) {
+	defaultVendorRegistry.RegisterVendorClass(prefix, ctor)
+}
+
+// RegisterEnterpriseDecoder globally registers ctor as the decoder of the
+// Vendor-Identifying Vendor-Specific Information entries of the given
+// enterprise.
+func RegisterEnterpriseDecoder(id iana.EnterpriseID, ctor VendorDecoderConstructor) {
+	defaultVendorRegistry.RegisterEnterprise(id, ctor)
+}
+
+// RegisterVendorClass registers ctor as the decoder of the Vendor Specific
+// Information option for clients whose class identifier starts with prefix.
+// A nil ctor removes a previous registration.
+func (r *VendorRegistry) RegisterVendorClass(prefix string, ctor VendorDecoderConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctor == nil {
+		delete(r.classes, prefix)
+		return
+	}
+	if r.classes == nil {
+		r.classes = make(map[string]VendorDecoderConstructor)
+	}
+	r.classes[prefix] = ctor
+}
+
+// RegisterEnterprise registers ctor as the decoder of the Vendor-Identifying
+// Vendor-Specific Information entries of the given enterprise. A nil ctor
+// removes a previous registration.
+func (r *VendorRegistry) RegisterEnterprise(id iana.EnterpriseID, ctor VendorDecoderConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctor == nil {
+		delete(r.enterprises, id)
+		return
+	}
+	if r.enterprises == nil {
+		r.enterprises = make(map[iana.EnterpriseID]VendorDecoderConstructor)
+	}
+	r.enterprises[id] = ctor
+}
+
+func (r *VendorRegistry) lookupClass(class string) VendorDecoderConstructor {
+	r.mu.RLock()
+	var (
+		ctor    VendorDecoderConstructor
+		longest = -1
+	)
+	for prefix, c := range r.classes {
+		if len(prefix) > longest && strings.HasPrefix(class, prefix) {
+			ctor, longest = c, len(prefix)
+		}
+	}
+	r.mu.RUnlock()
+	if ctor == nil && r.parent != nil {
+		return r.parent.lookupClass(class)
+	}
+	return ctor
+}
+
+func (r *VendorRegistry) lookupEnterprise(id iana.EnterpriseID) VendorDecoderConstructor {
+	r.mu.RLock()
+	ctor := r.enterprises[id]
+	r.mu.RUnlock()
+	if ctor == nil && r.parent != nil {
+		return r.parent.lookupEnterprise(id)
+	}
+	return ctor
+}
+
+// DecodeVendorSpecific decodes data, the value of a Vendor Specific
+// Information option, for a client with the given class identifier.
+//
+// It returns ErrNoVendorDecoder if no decoder is registered for the class.
+func (r *VendorRegistry) DecodeVendorSpecific(class string, data []byte) (OptionDecoder, error) {
+	ctor := r.lookupClass(class)
+	if ctor == nil {
+		return nil, ErrNoVendorDecoder
+	}
+	d := ctor()
+	if err := d.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DecodeVIVS decodes the sub-options of a Vendor-Identifying Vendor-Specific
+// Information entry.
+//
+// It returns ErrNoVendorDecoder if no decoder is registered for the
+// enterprise.
+func (r *VendorRegistry) DecodeVIVS(v VIVSOption) (OptionDecoder, error) {
+	ctor := r.lookupEnterprise(v.EntID)
+	if ctor == nil {
+		return nil, ErrNoVendorDecoder
+	}
+	d := ctor()
+	if err := d.FromBytes(v.Options.ToBytes()); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// VendorSpecific decodes the Vendor Specific Information option of d
+// according to d's Class Identifier option.
+//
+// It returns nil and no error if d has no Vendor Specific Information option.
+func (r *VendorRegistry) VendorSpecific(d *DHCPv4) (OptionDecoder, error) {
+	v := d.Options.Get(OptionVendorSpecificInformation)
+	if v == nil {
+		return nil, nil
+	}
+	return r.DecodeVendorSpecific(d.ClassIdentifier(), v)
+}
+
+// decoderForClass returns a new decoder for the given class identifier, or
+// nil.
+func (r *VendorRegistry) decoderForClass(class string) OptionDecoder {
+	if ctor := r.lookupClass(class); ctor != nil {
+		return ctor()
+	}
+	return nil
+}
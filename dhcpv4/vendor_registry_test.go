@@ -0,0 +1,113 @@
+package dhcpv4
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+// testVendorDecoder records the data it was decoded from.
+type testVendorDecoder struct {
+	data []byte
+}
+
+func (d *testVendorDecoder) String() string {
+	return fmt.Sprintf("test %v", d.data)
+}
+
+func (d *testVendorDecoder) FromBytes(data []byte) error {
+	d.data = data
+	return nil
+}
+
+func newTestVendorDecoder() OptionDecoder {
+	return &testVendorDecoder{}
+}
+
+func TestVendorRegistryClass(t *testing.T) {
+	r := NewVendorRegistry()
+	r.RegisterVendorClass("PXEClient:Arch:00007", newTestVendorDecoder)
+
+	// The longest prefix wins, including across registries.
+	d, err := r.DecodeVendorSpecific("PXEClient:Arch:00007:UNDI:003016", []byte{1, 1, 1})
+	require.NoError(t, err)
+	require.Equal(t, &testVendorDecoder{data: []byte{1, 1, 1}}, d)
+
+	d, err = r.DecodeVendorSpecific("PXEClient:Arch:00000:UNDI:002001", []byte{6, 1, 8})
+	require.NoError(t, err)
+	require.IsType(t, &PXEOptions{}, d)
+
+	_, err = r.DecodeVendorSpecific("udhcp 1.30", []byte{1, 1, 1})
+	require.Equal(t, ErrNoVendorDecoder, err)
+
+	_, err = r.DecodeVendorSpecific("PXEClient", []byte{1, 5})
+	require.Error(t, err)
+
+	// Removing the registration restores the fallback.
+	r.RegisterVendorClass("PXEClient:Arch:00007", nil)
+	d, err = r.DecodeVendorSpecific("PXEClient:Arch:00007:UNDI:003016", []byte{1, 1, 1})
+	require.NoError(t, err)
+	require.IsType(t, &PXEOptions{}, d)
+}
+
+func TestVendorRegistryEnterprise(t *testing.T) {
+	r := NewVendorRegistry()
+	r.RegisterEnterprise(1234, newTestVendorDecoder)
+	v := VIVSOption{EntID: 1234, Options: OptionsFromList(OptGeneric(GenericOptionCode(1), []byte{2}))}
+
+	d, err := r.DecodeVIVS(v)
+	require.NoError(t, err)
+	require.Equal(t, &testVendorDecoder{data: []byte{1, 1, 2}}, d)
+
+	_, err = NewVendorRegistry().DecodeVIVS(v)
+	require.Equal(t, ErrNoVendorDecoder, err)
+
+	d, err = r.DecodeVIVS(VIVSOption{EntID: iana.EnterpriseIDMicrosoft})
+	require.NoError(t, err)
+	require.IsType(t, &MicrosoftOptions{}, d)
+}
+
+func TestVendorRegistryPacket(t *testing.T) {
+	m, err := New(
+		WithOption(OptClassIdentifier("MSFT 5.0")),
+		WithOption(OptMicrosoftVendorOptions(OptMicrosoftDisableNetBIOS())),
+	)
+	require.NoError(t, err)
+
+	r := NewVendorRegistry()
+	d, err := r.VendorSpecific(m)
+	require.NoError(t, err)
+	require.True(t, d.(*MicrosoftOptions).NetBIOSDisabled())
+
+	r.RegisterVendorClass("MSFT 5.0", newTestVendorDecoder)
+	d, err = r.VendorSpecific(m)
+	require.NoError(t, err)
+	require.IsType(t, &testVendorDecoder{}, d)
+	// The global registry is not affected.
+	require.IsType(t, &MicrosoftOptions{}, m.VendorSpecific())
+
+	m.Options.Del(OptionVendorSpecificInformation)
+	d, err = r.VendorSpecific(m)
+	require.NoError(t, err)
+	require.Nil(t, d)
+	require.Nil(t, m.VendorSpecific())
+}
+
+func TestRegisterVendorClassDecoder(t *testing.T) {
+	RegisterVendorClassDecoder("test-vendor", newTestVendorDecoder)
+	defer RegisterVendorClassDecoder("test-vendor", nil)
+	RegisterEnterpriseDecoder(4321, newTestVendorDecoder)
+	defer RegisterEnterpriseDecoder(4321, nil)
+
+	m, err := New(
+		WithOption(OptClassIdentifier("test-vendor")),
+		WithGeneric(OptionVendorSpecificInformation, []byte{1, 2, 3}),
+		WithOption(OptVIVS(VIVSOption{EntID: 4321, Options: OptionsFromList(OptGeneric(GenericOptionCode(9), nil))})),
+	)
+	require.NoError(t, err)
+	require.Equal(t, &testVendorDecoder{data: []byte{1, 2, 3}}, m.VendorSpecific())
+	require.Contains(t, m.Summary(), "Vendor Specific Information: test [1 2 3]")
+	require.Contains(t, m.Summary(), "Unknown (4321):test [9 0]")
+}
@@ -0,0 +1,59 @@
+package dhcpv6
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// flagDHCPv4Unicast is the unicast flag of DHCPv4-QUERY messages, RFC 7341,
+// Section 6.1. The flags of DHCPv4-QUERY and DHCPv4-RESPONSE messages take
+// the place of the transaction ID.
+const flagDHCPv4Unicast = 0x80
+
+// NewDHCPv4Query creates a DHCPv4-QUERY message carrying the DHCPv4 message
+// m, as described in RFC 7341, Section 6.1. unicast is set if m would have
+// been sent to a unicast address over IPv4: the server then answers as if
+// it had received m by unicast.
+func NewDHCPv4Query(m *dhcpv4.DHCPv4, unicast bool, modifiers ...Modifier) (*Message, error) {
+	if m == nil {
+		return nil, errors.New("DHCPv4 message cannot be nil")
+	}
+	q := &Message{MessageType: MessageTypeDHCPv4Query}
+	if unicast {
+		q.TransactionID[0] = flagDHCPv4Unicast
+	}
+	q.AddOption(&OptDHCPv4Msg{Msg: m})
+	for _, mod := range modifiers {
+		mod(q)
+	}
+	return q, nil
+}
+
+// NewDHCPv4Response creates a DHCPv4-RESPONSE message answering the
+// DHCPv4-QUERY message query with the DHCPv4 message m, as described in RFC
+// 7341, Section 6.2.
+func NewDHCPv4Response(query *Message, m *dhcpv4.DHCPv4, modifiers ...Modifier) (*Message, error) {
+	if query == nil {
+		return nil, errors.New("DHCPv4-QUERY cannot be nil")
+	}
+	if query.MessageType != MessageTypeDHCPv4Query {
+		return nil, fmt.Errorf("cannot create DHCPv4-RESPONSE from %s", query.MessageType)
+	}
+	if m == nil {
+		return nil, errors.New("DHCPv4 message cannot be nil")
+	}
+	r := &Message{MessageType: MessageTypeDHCPv4Response}
+	r.AddOption(&OptDHCPv4Msg{Msg: m})
+	for _, mod := range modifiers {
+		mod(r)
+	}
+	return r, nil
+}
+
+// IsDHCPv4Unicast returns whether m is a DHCPv4-QUERY message with the
+// unicast flag set.
+func (m *Message) IsDHCPv4Unicast() bool {
+	return m.MessageType == MessageTypeDHCPv4Query && m.TransactionID[0]&flagDHCPv4Unicast != 0
+}
@@ -0,0 +1,43 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDHCPv4Query(t *testing.T) {
+	m4, err := dhcpv4.NewDiscovery([]byte{2, 0, 0, 0, 0, 1})
+	require.NoError(t, err)
+	_, err = NewDHCPv4Query(nil, false)
+	require.Error(t, err)
+
+	q, err := NewDHCPv4Query(m4, true)
+	require.NoError(t, err)
+	got, err := MessageFromBytes(q.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeDHCPv4Query, got.MessageType)
+	require.True(t, got.IsDHCPv4Unicast())
+	require.Equal(t, m4.TransactionID, got.Options.DHCPv4Msg().TransactionID)
+
+	q, err = NewDHCPv4Query(m4, false)
+	require.NoError(t, err)
+	require.False(t, q.IsDHCPv4Unicast())
+
+	offer, err := dhcpv4.NewReplyFromRequest(m4, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer))
+	require.NoError(t, err)
+	r, err := NewDHCPv4Response(q, offer)
+	require.NoError(t, err)
+	got, err = MessageFromBytes(r.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeDHCPv4Response, got.MessageType)
+	require.False(t, got.IsDHCPv4Unicast())
+	require.Equal(t, dhcpv4.MessageTypeOffer, got.Options.DHCPv4Msg().MessageType())
+
+	_, err = NewDHCPv4Response(r, offer)
+	require.Error(t, err)
+	_, err = NewDHCPv4Response(q, nil)
+	require.Error(t, err)
+	require.Nil(t, (&Message{}).Options.DHCPv4Msg())
+}
@@ -35,6 +35,10 @@ type Modifier func(d DHCPv6)
 
 // MessageFromBytes parses a DHCPv6 message from a byte stream.
 func MessageFromBytes(data []byte) (*Message, error) {
+	return messageFromBytes(data, defaultOptionRegistry)
+}
+
+func messageFromBytes(data []byte, r *OptionRegistry) (*Message, error) {
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 
@@ -49,7 +53,7 @@ func MessageFromBytes(data []byte) (*Message, error) {
 	if buf.Error() != nil {
 		return nil, fmt.Errorf("failed to parse DHCPv6 header: %w", buf.Error())
 	}
-	if err := d.Options.FromBytes(buf.Data()); err != nil {
+	if err := d.Options.FromBytesWithParser(buf.Data(), r.Parser(OptionSpaceMessage)); err != nil {
 		return nil, err
 	}
 	return d, nil
@@ -57,6 +61,10 @@ func MessageFromBytes(data []byte) (*Message, error) {
 
 // RelayMessageFromBytes parses a relay message from a byte stream.
 func RelayMessageFromBytes(data []byte) (*RelayMessage, error) {
+	return relayMessageFromBytes(data, defaultOptionRegistry)
+}
+
+func relayMessageFromBytes(data []byte, r *OptionRegistry) (*RelayMessage, error) {
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 
@@ -75,7 +83,7 @@ func RelayMessageFromBytes(data []byte) (*RelayMessage, error) {
 		return nil, fmt.Errorf("Error parsing RelayMessage header: %v", buf.Error())
 	}
 	// TODO: fail if no OptRelayMessage is present.
-	if err := d.Options.FromBytes(buf.Data()); err != nil {
+	if err := d.Options.FromBytesWithParser(buf.Data(), r.Parser(OptionSpaceMessage)); err != nil {
 		return nil, err
 	}
 	return d, nil
@@ -83,6 +91,13 @@ func RelayMessageFromBytes(data []byte) (*RelayMessage, error) {
 
 // FromBytes reads a DHCPv6 message from a byte stream.
 func FromBytes(data []byte) (DHCPv6, error) {
+	return FromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+// FromBytesWithRegistry reads a DHCPv6 message from a byte stream, parsing
+// its options, including the options nested in other options and in relayed
+// messages, with the constructors registered in r.
+func FromBytesWithRegistry(data []byte, r *OptionRegistry) (DHCPv6, error) {
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 	if buf.Error() != nil {
@@ -90,9 +105,9 @@ func FromBytes(data []byte) (DHCPv6, error) {
 	}
 
 	if messageType == MessageTypeRelayForward || messageType == MessageTypeRelayReply {
-		return RelayMessageFromBytes(data)
+		return relayMessageFromBytes(data, r)
 	} else {
-		return MessageFromBytes(data)
+		return messageFromBytes(data, r)
 	}
 }
 
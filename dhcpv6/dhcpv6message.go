@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/insomniacslk/dhcp/rfc1035label"
 	"github.com/u-root/uio/rand"
@@ -153,14 +155,7 @@ func (mo MessageOptions) RequestedOptions() OptionCodes {
 
 // DNS returns the DNS Recursive Name Server option as defined by RFC 3646.
 func (mo MessageOptions) DNS() []net.IP {
-	opt := mo.Options.GetOne(OptionDNSRecursiveNameServer)
-	if opt == nil {
-		return nil
-	}
-	if dns, ok := opt.(*optDNS); ok {
-		return dns.NameServers
-	}
-	return nil
+	return addressList(mo.Options, OptionDNSRecursiveNameServer)
 }
 
 // DomainSearchList returns the Domain List option as defined by RFC 3646.
@@ -315,6 +310,15 @@ func (mo MessageOptions) FQDN() *OptFQDN {
 	return nil
 }
 
+// DHCPv4Msg returns the DHCPv4 message of a DHCPv4-QUERY or DHCPv4-RESPONSE
+// message, as defined by RFC 7341, or nil.
+func (mo MessageOptions) DHCPv4Msg() *dhcpv4.DHCPv4 {
+	if opt, ok := mo.Options.GetOne(OptionDHCPv4Msg).(*OptDHCPv4Msg); ok {
+		return opt.Msg
+	}
+	return nil
+}
+
 // DHCP4oDHCP6Server returns the DHCP 4o6 Server Address option as
 // defined by RFC 7341.
 func (mo MessageOptions) DHCP4oDHCP6Server() *OptDHCP4oDHCP6Server {
@@ -366,6 +370,224 @@ func (mo MessageOptions) SNTP() []net.IP {
 	return nil
 }
 
+// Auth returns the Authentication option as defined by RFC 8415 Section
+// 21.11.
+func (mo MessageOptions) Auth() *OptAuth {
+	opt := mo.Options.GetOne(OptionAuth)
+	if opt == nil {
+		return nil
+	}
+	if auth, ok := opt.(*OptAuth); ok {
+		return auth
+	}
+	return nil
+}
+
+// ReconfigureMessage returns the message type of the Reconfigure Message
+// option as defined by RFC 8415 Section 21.19.
+//
+// ReconfigureMessage returns MessageTypeNone if the option is not present.
+func (mo MessageOptions) ReconfigureMessage() MessageType {
+	opt := mo.Options.GetOne(OptionReconfMessage)
+	if opt == nil {
+		return MessageTypeNone
+	}
+	if rm, ok := opt.(*OptReconfigureMessage); ok {
+		return rm.MessageType
+	}
+	return MessageTypeNone
+}
+
+// ReconfigureAccept returns whether the Reconfigure Accept option, as defined
+// by RFC 8415 Section 21.20, is present.
+func (mo MessageOptions) ReconfigureAccept() bool {
+	return mo.Options.GetOne(OptionReconfAccept) != nil
+}
+
+// LQQuery returns the LQ Query option of a LEASEQUERY message, as defined by
+// RFC 5007 Section 4.1.2.1.
+func (mo MessageOptions) LQQuery() *OptLQQuery {
+	if opt, ok := mo.Options.GetOne(OptionLQQuery).(*OptLQQuery); ok {
+		return opt
+	}
+	return nil
+}
+
+// ClientData returns the Client Data option of a leasequery reply, as defined
+// by RFC 5007 Section 4.1.2.2.
+func (mo MessageOptions) ClientData() *OptClientData {
+	if opt, ok := mo.Options.GetOne(OptionClientData).(*OptClientData); ok {
+		return opt
+	}
+	return nil
+}
+
+// LQClientLink returns the links on which the client of a leasequery reply
+// has bindings, as defined by RFC 5007 Section 4.1.2.5.
+func (mo MessageOptions) LQClientLink() []net.IP {
+	if opt, ok := mo.Options.GetOne(OptionLQClientLink).(*optLQClientLink); ok {
+		return opt.LinkAddresses
+	}
+	return nil
+}
+
+// LQBaseTime returns the current time of the server that sent a leasequery
+// reply, as defined by RFC 7653 Section 7.1, if present.
+func (mo MessageOptions) LQBaseTime() (time.Time, bool) {
+	if opt, ok := mo.Options.GetOne(OptionLQBaseTime).(*optLQTime); ok {
+		return opt.Time, true
+	}
+	return time.Time{}, false
+}
+
+// SIPServersDomainNameList returns the SIP Servers Domain Name List option
+// as defined by RFC 3319 Section 3.1.
+func (mo MessageOptions) SIPServersDomainNameList() *rfc1035label.Labels {
+	if opt, ok := mo.Options.GetOne(OptionSIPServersDomainNameList).(*optSIPServersDomainNameList); ok {
+		return opt.DomainNameList
+	}
+	return nil
+}
+
+// SIPServers returns the SIP Servers IPv6 Address List option as defined by
+// RFC 3319 Section 3.2.
+func (mo MessageOptions) SIPServers() []net.IP {
+	return addressList(mo.Options, OptionSIPServersIPv6AddressList)
+}
+
+// NISServers returns the NIS Servers option as defined by RFC 3898 Section 3.
+func (mo MessageOptions) NISServers() []net.IP {
+	return addressList(mo.Options, OptionNISServers)
+}
+
+// NISPServers returns the NIS+ Servers option as defined by RFC 3898 Section
+// 4.
+func (mo MessageOptions) NISPServers() []net.IP {
+	return addressList(mo.Options, OptionNISPServers)
+}
+
+// NISDomainName returns the NIS Domain Name option as defined by RFC 3898
+// Section 5, or "" if absent.
+func (mo MessageOptions) NISDomainName() string {
+	return domainName(mo.Options, OptionNISDomainName)
+}
+
+// NISPDomainName returns the NIS+ Domain Name option as defined by RFC 3898
+// Section 6, or "" if absent.
+func (mo MessageOptions) NISPDomainName() string {
+	return domainName(mo.Options, OptionNISPDomainName)
+}
+
+// POSIXTimezone returns the New POSIX Timezone option as defined by RFC 4833
+// Section 3, or "" if absent.
+func (mo MessageOptions) POSIXTimezone() string {
+	if opt, ok := mo.Options.GetOne(OptionNewPOSIXTimezone).(*optTimezone); ok {
+		return opt.Timezone
+	}
+	return ""
+}
+
+// TZDBTimezone returns the New TZDB Timezone option as defined by RFC 4833
+// Section 3, or "" if absent.
+func (mo MessageOptions) TZDBTimezone() string {
+	if opt, ok := mo.Options.GetOne(OptionNewTZDBTimezone).(*optTimezone); ok {
+		return opt.Timezone
+	}
+	return ""
+}
+
+// PCPServers returns the addresses of each PCP server of the PCP Server
+// options, as defined by RFC 7291 Section 4.
+func (mo MessageOptions) PCPServers() [][]net.IP {
+	var servers [][]net.IP
+	for _, o := range mo.Options.Get(OptionV6PCPServer) {
+		if opt, ok := o.(*optAddressList); ok {
+			servers = append(servers, opt.Addrs)
+		}
+	}
+	return servers
+}
+
+// SolMaxRT returns the SOL_MAX_RT option as defined by RFC 8415 Section
+// 21.24, and whether it is present and within its valid range.
+func (mo MessageOptions) SolMaxRT() (time.Duration, bool) {
+	return maxRT(mo.Options, OptionSolMaxRT)
+}
+
+// InfMaxRT returns the INF_MAX_RT option as defined by RFC 8415 Section
+// 21.25, and whether it is present and within its valid range.
+func (mo MessageOptions) InfMaxRT() (time.Duration, bool) {
+	return maxRT(mo.Options, OptionInfMaxRT)
+}
+
+// AFTRName returns the name of the DS-Lite Address Family Transition Router
+// from the AFTR-Name option, as defined by RFC 6334, or "" if absent.
+func (mo MessageOptions) AFTRName() string {
+	if opt, ok := mo.Options.GetOne(OptionAFTRName).(*optAFTRName); ok {
+		return opt.Name
+	}
+	return ""
+}
+
+// V6Prefix64 returns the V6 Prefix64 option, as defined by RFC 8115, if
+// present.
+func (mo MessageOptions) V6Prefix64() *OptV6Prefix64 {
+	if opt, ok := mo.Options.GetOne(OptionV6Prefix64).(*OptV6Prefix64); ok {
+		return opt
+	}
+	return nil
+}
+
+// DNR returns the encrypted DNS resolvers of the Encrypted DNS options, as
+// defined by RFC 9463 Section 4.1, ordered by service priority.
+func (mo MessageOptions) DNR() []*OptDNR {
+	var dnrs []*OptDNR
+	for _, o := range mo.Options.Get(OptionV6DNR) {
+		if dnr, ok := o.(*OptDNR); ok {
+			dnrs = append(dnrs, dnr)
+		}
+	}
+	sort.SliceStable(dnrs, func(i, j int) bool {
+		return dnrs[i].ServicePriority < dnrs[j].ServicePriority
+	})
+	return dnrs
+}
+
+// CaptivePortal returns the captive portal API URI of the Captive Portal
+// option, as defined by RFC 8910 Section 2.2, or "" if absent or invalid. It
+// may be dhcpv4.CaptivePortalUnrestricted.
+func (mo MessageOptions) CaptivePortal() string {
+	if opt, ok := mo.Options.GetOne(OptionCaptivePortal).(*optCaptivePortal); ok && dhcpv4.ValidCaptivePortalURI(opt.URI) == nil {
+		return opt.URI
+	}
+	return ""
+}
+
+// S46ContMapE returns the S46 MAP-E Container option, as defined by RFC 7598
+// Section 5.1, if present.
+func (mo MessageOptions) S46ContMapE() *OptS46Container {
+	return mo.s46Container(OptionS46ContMapE)
+}
+
+// S46ContMapT returns the S46 MAP-T Container option, as defined by RFC 7598
+// Section 5.2, if present.
+func (mo MessageOptions) S46ContMapT() *OptS46Container {
+	return mo.s46Container(OptionS46ContMapT)
+}
+
+// S46ContLW returns the S46 Lightweight 4over6 Container option, as defined
+// by RFC 7598 Section 5.3, if present.
+func (mo MessageOptions) S46ContLW() *OptS46Container {
+	return mo.s46Container(OptionS46ContLW)
+}
+
+func (mo MessageOptions) s46Container(code OptionCode) *OptS46Container {
+	if opt, ok := mo.Options.GetOne(code).(*OptS46Container); ok {
+		return opt
+	}
+	return nil
+}
+
 // Message represents a DHCPv6 Message as defined by RFC 3315 Section 6.
 type Message struct {
 	MessageType   MessageType
@@ -56,6 +56,15 @@ func (ro RelayOptions) RemoteID() *OptRemoteID {
 	return nil
 }
 
+// RelayID returns the DUID of the relay agent of this relay message, as
+// defined by RFC 5460 Section 5.4.1.
+func (ro RelayOptions) RelayID() DUID {
+	if rid, ok := ro.Options.GetOne(OptionRelayID).(*optRelayID); ok {
+		return rid.DUID
+	}
+	return nil
+}
+
 // ClientLinkLayerAddress returns the Hardware Type and
 // Link Layer Address of the requesting client in this relay message.
 func (ro RelayOptions) ClientLinkLayerAddress() (iana.HWType, net.HardwareAddr) {
@@ -4,6 +4,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/insomniacslk/dhcp/rfc1035label"
 )
@@ -131,6 +132,11 @@ func WithRapidCommit(d DHCPv6) {
 	d.UpdateOption(&OptionGeneric{OptionCode: OptionRapidCommit})
 }
 
+// WithReconfigureAccept adds the Reconfigure Accept option to a message.
+func WithReconfigureAccept(d DHCPv6) {
+	d.UpdateOption(OptReconfigureAccept())
+}
+
 // WithRequestedOptions adds requested options to the packet
 func WithRequestedOptions(codes ...OptionCode) Modifier {
 	return func(d DHCPv6) {
@@ -184,3 +190,107 @@ func WithClientLinkLayerAddress(ht iana.HWType, lla net.HardwareAddr) Modifier {
 func WithInformationRefreshTime(irt time.Duration) Modifier {
 	return WithOption(OptInformationRefreshTime(irt))
 }
+
+// WithAFTRName adds or updates an AFTR-Name option with the name of the
+// DS-Lite Address Family Transition Router.
+func WithAFTRName(name string) Modifier {
+	return WithOption(OptAFTRName(name))
+}
+
+// WithV6Prefix64 adds or updates an OptV6Prefix64 with the given ASM, SSM and
+// unicast prefixes. Zero prefixes are omitted.
+func WithV6Prefix64(asm, ssm, unicast net.IPNet) Modifier {
+	return WithOption(&OptV6Prefix64{ASMPrefix64: asm, SSMPrefix64: ssm, UnicastPrefix64: unicast})
+}
+
+// WithDNR adds an Encrypted DNS option for each of the given resolvers.
+func WithDNR(dnrs ...*OptDNR) Modifier {
+	return func(d DHCPv6) {
+		for _, dnr := range dnrs {
+			d.AddOption(dnr)
+		}
+	}
+}
+
+// WithCaptivePortalByClass adds or updates a Captive Portal option with the
+// captive portal API URI of the client class of request, if it requested the
+// option: the URI of the first of its user classes that has one, or else of
+// its vendor classes, or else the default URI of uris.
+func WithCaptivePortalByClass(request *Message, uris *dhcpv4.CaptivePortalClasses) Modifier {
+	return func(d DHCPv6) {
+		if !request.IsOptionRequested(OptionCaptivePortal) {
+			return
+		}
+		var classes []string
+		for _, c := range request.Options.UserClasses() {
+			classes = append(classes, string(c))
+		}
+		for _, vc := range request.Options.VendorClasses() {
+			for _, c := range vc.Data {
+				classes = append(classes, string(c))
+			}
+		}
+		if uri, ok := uris.Lookup(classes); ok {
+			d.UpdateOption(&optCaptivePortal{URI: uri})
+		}
+	}
+}
+
+// WithSIPServersDomainNameList adds or updates a SIP Servers Domain Name List
+// option.
+func WithSIPServersDomainNameList(domains ...string) Modifier {
+	return WithOption(OptSIPServersDomainNameList(&rfc1035label.Labels{Labels: domains}))
+}
+
+// WithSIPServers adds or updates a SIP Servers IPv6 Address List option.
+func WithSIPServers(ips ...net.IP) Modifier {
+	return WithOption(OptSIPServers(ips...))
+}
+
+// WithNISServers adds or updates a NIS Servers option.
+func WithNISServers(ips ...net.IP) Modifier {
+	return WithOption(OptNISServers(ips...))
+}
+
+// WithNISPServers adds or updates a NIS+ Servers option.
+func WithNISPServers(ips ...net.IP) Modifier {
+	return WithOption(OptNISPServers(ips...))
+}
+
+// WithNISDomainName adds or updates a NIS Domain Name option.
+func WithNISDomainName(name string) Modifier {
+	return WithOption(OptNISDomainName(name))
+}
+
+// WithNISPDomainName adds or updates a NIS+ Domain Name option.
+func WithNISPDomainName(name string) Modifier {
+	return WithOption(OptNISPDomainName(name))
+}
+
+// WithPOSIXTimezone adds or updates a New POSIX Timezone option.
+func WithPOSIXTimezone(tz string) Modifier {
+	return WithOption(OptPOSIXTimezone(tz))
+}
+
+// WithTZDBTimezone adds or updates a New TZDB Timezone option.
+func WithTZDBTimezone(tz string) Modifier {
+	return WithOption(OptTZDBTimezone(tz))
+}
+
+// WithPCPServer adds a PCP Server option with the addresses of one PCP
+// server.
+func WithPCPServer(ips ...net.IP) Modifier {
+	return func(d DHCPv6) {
+		d.AddOption(OptPCPServer(ips...))
+	}
+}
+
+// WithSolMaxRT adds or updates a SOL_MAX_RT option.
+func WithSolMaxRT(d time.Duration) Modifier {
+	return WithOption(OptSolMaxRT(d))
+}
+
+// WithInfMaxRT adds or updates an INF_MAX_RT option.
+func WithInfMaxRT(d time.Duration) Modifier {
+	return WithOption(OptInfMaxRT(d))
+}
@@ -0,0 +1,327 @@
+package nclient6
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// StartTLS secures the leasequery connection conn with TLS, as described in
+// RFC 7653, Section 8.2, and returns the secured connection. The server may
+// refuse, with an ErrLeasequeryStatus error: conn may then be used as is.
+func StartTLS(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	query, err := dhcpv6.NewStartTLS()
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if err := dhcpv6.WriteTCPMessage(conn, query); err != nil {
+		return nil, err
+	}
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if m.TransactionID != query.TransactionID {
+			continue
+		}
+		if m.MessageType != dhcpv6.MessageTypeStartTLS && m.MessageType != dhcpv6.MessageTypeLeaseQueryReply {
+			return nil, fmt.Errorf("unexpected reply to STARTTLS: %s", m.MessageType)
+		}
+		if err := leasequeryStatus(m); err != nil {
+			return nil, err
+		}
+		break
+	}
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// ActiveBinding is the binding of an address, or of a delegated prefix, as
+// last received by an ActiveRequestor.
+type ActiveBinding struct {
+	ClientID dhcpv6.DUID
+	// Addr is the bound address, or nil for a delegated prefix.
+	Addr net.IP
+	// Prefix is the delegated prefix, or nil for an address.
+	Prefix *net.IPNet
+	// Expiry is when the valid lifetime of the binding ends.
+	Expiry time.Time
+	// ClientData is the Client Data option the binding was received in.
+	ClientData *dhcpv6.OptClientData
+}
+
+// key identifies the binding of an address or prefix.
+func bindingKey(addr net.IP, prefix *net.IPNet) string {
+	if prefix != nil {
+		return "prefix:" + prefix.String()
+	}
+	return "addr:" + string(addr.To16())
+}
+
+func (b *ActiveBinding) ip() net.IP {
+	if b.Prefix != nil {
+		return b.Prefix.IP.To16()
+	}
+	return b.Addr.To16()
+}
+
+// ActiveRequestor maintains a copy of the bindings of a DHCPv6 server, updated
+// with active leasequery, as described in RFC 7653.
+//
+// A requestor without bindings may first fill them with a bulk leasequery:
+// BulkLeasequery(ctx, conn, query, r.Update). Run then only needs the
+// changes since.
+type ActiveRequestor struct {
+	// ClientID is the DUID of the requestor, sent in its queries.
+	ClientID dhcpv6.DUID
+	// OnUpdate, if not nil, is called with each message carrying bindings,
+	// after they are applied.
+	OnUpdate func(*dhcpv6.Message)
+
+	mu       sync.Mutex
+	bindings map[string]*ActiveBinding
+	last     time.Time
+	synced   bool
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewActiveRequestor returns an ActiveRequestor without bindings, that
+// identifies itself with duid.
+func NewActiveRequestor(duid dhcpv6.DUID) *ActiveRequestor {
+	return &ActiveRequestor{ClientID: duid, bindings: make(map[string]*ActiveBinding)}
+}
+
+func (r *ActiveRequestor) time() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+// Update applies the bindings of a LEASEQUERY-REPLY or LEASEQUERY-DATA
+// message. Bindings with a zero valid lifetime are removed. Messages without
+// a Client Data option only update the time of the last update.
+func (r *ActiveRequestor) Update(m *dhcpv6.Message) error {
+	return r.update(m, true)
+}
+
+// update applies m, and, if advance is set, its base time.
+func (r *ActiveRequestor) update(m *dhcpv6.Message, advance bool) error {
+	if m.MessageType != dhcpv6.MessageTypeLeaseQueryReply && m.MessageType != dhcpv6.MessageTypeLeaseQueryData {
+		return fmt.Errorf("not a leasequery reply: %s", m.MessageType)
+	}
+	now := r.time()
+	cd := m.Options.ClientData()
+	r.mu.Lock()
+	if cd != nil {
+		duid := cd.Options.ClientID()
+		set := func(addr net.IP, prefix *net.IPNet, valid time.Duration) {
+			key := bindingKey(addr, prefix)
+			if valid == 0 {
+				delete(r.bindings, key)
+				return
+			}
+			r.bindings[key] = &ActiveBinding{ClientID: duid, Addr: addr, Prefix: prefix, Expiry: now.Add(valid), ClientData: cd}
+		}
+		for _, a := range cd.Options.Addresses() {
+			set(a.IPv6Addr, nil, a.ValidLifetime)
+		}
+		for _, p := range cd.Options.Prefixes() {
+			set(nil, p.Prefix, p.ValidLifetime)
+		}
+	}
+	if base, ok := m.Options.LQBaseTime(); ok && advance && base.After(r.last) {
+		r.last = base
+	}
+	r.mu.Unlock()
+	if cd != nil && r.OnUpdate != nil {
+		r.OnUpdate(m)
+	}
+	return nil
+}
+
+// Reset removes all the bindings, and forgets the last update.
+func (r *ActiveRequestor) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bindings = make(map[string]*ActiveBinding)
+	r.last = time.Time{}
+	r.synced = false
+}
+
+// Bindings returns the bindings that have not expired, ordered by address.
+func (r *ActiveRequestor) Bindings() []*ActiveBinding {
+	now := r.time()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*ActiveBinding
+	for _, b := range r.bindings {
+		if now.Before(b.Expiry) {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return bytes.Compare(out[i].ip(), out[j].ip()) < 0
+	})
+	return out
+}
+
+// Get returns the binding of ip, or of the delegated prefix that contains
+// ip, or nil if ip is not bound.
+func (r *ActiveRequestor) Get(ip net.IP) *ActiveBinding {
+	now := r.time()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.bindings[bindingKey(ip, nil)]; ok && now.Before(b.Expiry) {
+		return b
+	}
+	for _, b := range r.bindings {
+		if b.Prefix != nil && b.Prefix.Contains(ip) && now.Before(b.Expiry) {
+			return b
+		}
+	}
+	return nil
+}
+
+// LastUpdate returns the server time of the last update, or the zero time if
+// there was none.
+func (r *ActiveRequestor) LastUpdate() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Synced returns whether the bindings are up to date: the server sent the
+// changes missed since the last update, and now sends them as they happen.
+func (r *ActiveRequestor) Synced() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.synced
+}
+
+// Run sends an ACTIVELEASEQUERY message with query over conn, a TCP
+// connection to port 547 of a server, and applies the updates it receives,
+// until ctx is done, the connection fails or the server ends the query.
+// conn must then be closed.
+//
+// query may be nil to ask about all the bindings of the server. Otherwise,
+// it restricts them to a relay identifier, link address or remote ID; its
+// LQ Start Time option is set by Run.
+//
+// If there was a previous update, the server is first asked about the
+// changes since. If it cannot provide them, Run returns an
+// ErrLeasequeryStatus error with the DataMissing status: the bindings must
+// then be reset with Reset, and reloaded with a bulk leasequery.
+func (r *ActiveRequestor) Run(ctx context.Context, conn net.Conn, query *dhcpv6.OptLQQuery) error {
+	lq := &dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByLinkAddress, LinkAddress: net.IPv6unspecified}
+	if query != nil {
+		c := *query
+		c.Options = dhcpv6.LQQueryOptions{}
+		for _, o := range query.Options.Options {
+			if o.Code() != dhcpv6.OptionLQStartTime {
+				c.Options.Add(o)
+			}
+		}
+		lq = &c
+	}
+	last := r.LastUpdate()
+	r.mu.Lock()
+	r.synced = last.IsZero()
+	r.mu.Unlock()
+	if !last.IsZero() {
+		lq.Options.Add(dhcpv6.OptLQStartTime(last))
+	}
+	var modifiers []dhcpv6.Modifier
+	if r.ClientID != nil {
+		modifiers = append(modifiers, dhcpv6.WithClientID(r.ClientID))
+	}
+	m, err := dhcpv6.NewActiveLeasequery(lq, modifiers...)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	err = r.run(conn, m)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (r *ActiveRequestor) run(conn net.Conn, query *dhcpv6.Message) error {
+	if err := dhcpv6.WriteTCPMessage(conn, query); err != nil {
+		return err
+	}
+	replied := false
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		if err != nil {
+			return err
+		}
+		if m.TransactionID != query.TransactionID {
+			continue
+		}
+		switch {
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryReply && !replied:
+			if err := leasequeryStatus(m); err != nil {
+				return err
+			}
+			replied = true
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryData && replied:
+			if s := m.Options.Status(); s != nil {
+				if s.StatusCode != iana.StatusCatchUpComplete {
+					return &ErrLeasequeryStatus{Status: s}
+				}
+				r.mu.Lock()
+				r.synced = true
+				r.mu.Unlock()
+			}
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryDone && replied:
+			s := m.Options.Status()
+			if s == nil {
+				s = &dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess}
+			}
+			return &ErrLeasequeryStatus{Status: s}
+		default:
+			return fmt.Errorf("unexpected active leasequery reply: %s", m.MessageType)
+		}
+		// The time of the last update only advances once caught up, so
+		// that an interrupted catch-up is started over.
+		if err := r.update(m, r.Synced()); err != nil {
+			return err
+		}
+	}
+}
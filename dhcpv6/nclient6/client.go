@@ -75,6 +75,10 @@ type Client struct {
 	// printDropped logs dropped packets to logger if true.
 	printDropped bool
 
+	// reconfigure receives the RECONFIGURE messages, as received on the
+	// wire. They are dropped when it is full.
+	reconfigure chan []byte
+
 	pendingMu sync.Mutex
 	// pending stores the distribution channels for each pending
 	// TransactionID. receiveLoop uses this map to determine which channel
@@ -155,8 +159,9 @@ func NewWithConn(conn net.PacketConn, ifaceHWAddr net.HardwareAddr, opts ...Clie
 		conn:        conn,
 		logger:      emptyLogger{},
 
-		done:    make(chan struct{}),
-		pending: make(map[dhcpv6.TransactionID]*pendingCh),
+		done:        make(chan struct{}),
+		pending:     make(map[dhcpv6.TransactionID]*pendingCh),
+		reconfigure: make(chan []byte, 5),
 	}
 
 	for _, opt := range opts {
@@ -230,6 +235,17 @@ func (c *Client) receiveLoop() {
 				continue
 			}
 
+			if msg.MessageType == dhcpv6.MessageTypeReconfigure {
+				// RECONFIGURE messages are not replies to the
+				// client messages, they are matched by
+				// WaitReconfigure.
+				select {
+				case c.reconfigure <- b[:n]:
+				default:
+				}
+				continue
+			}
+
 			c.pendingMu.Lock()
 			p, ok := c.pending[msg.TransactionID]
 			if ok {
@@ -392,6 +408,114 @@ func (c *Client) Request(ctx context.Context, advertise *dhcpv6.Message, modifie
 	return c.SendAndRead(ctx, c.serverAddr, request, nil)
 }
 
+// Renew sends a renew message to the server of the given reply, to extend the
+// lifetimes of its leases, and returns the reply of the server.
+func (c *Client) Renew(ctx context.Context, reply *dhcpv6.Message, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	renew, err := dhcpv6.NewRenewFromReply(reply, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAndRead(ctx, c.serverAddr, renew, IsMessageType(dhcpv6.MessageTypeReply))
+}
+
+// Rebind sends a rebind message to any server, to extend the lifetimes of the
+// leases of the given reply, and returns the first reply received.
+func (c *Client) Rebind(ctx context.Context, reply *dhcpv6.Message, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	rebind, err := dhcpv6.NewRebindFromReply(reply, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAndRead(ctx, c.serverAddr, rebind, IsMessageType(dhcpv6.MessageTypeReply))
+}
+
+// InformationRequest sends an information request message and returns the
+// first reply received.
+func (c *Client) InformationRequest(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	req, err := dhcpv6.NewInformationRequest(modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAndRead(ctx, c.serverAddr, req, IsMessageType(dhcpv6.MessageTypeReply))
+}
+
+// ErrNoReconfigureKey is returned by WaitReconfigure if the server did not
+// give a reconfigure key in the reply.
+var ErrNoReconfigureKey = errors.New("reply has no reconfigure key")
+
+// WaitReconfigure waits for a reconfigure message from the server of the
+// given reply, as described in RFC 8415 Section 18.2.11. Messages that are
+// not authenticated with the reconfigure key of the reply, as described in
+// RFC 8415 Section 20.4, or that are replayed, are discarded.
+//
+// Servers only give reconfigure keys to clients that accept reconfigure
+// messages, with dhcpv6.WithReconfigureAccept in their requests.
+func (c *Client) WaitReconfigure(ctx context.Context, reply *dhcpv6.Message) (*dhcpv6.Message, error) {
+	if reply == nil {
+		return nil, fmt.Errorf("reply is nil")
+	}
+	key := reply.Options.ReconfigureKey()
+	if key == nil {
+		return nil, ErrNoReconfigureKey
+	}
+	// Replay detection values must increase from the reply, RFC 8415
+	// Section 20.4.
+	last := reply.Options.Auth().ReplayDetection
+	serverID := reply.Options.ServerID()
+	clientID := reply.Options.ClientID()
+	for {
+		select {
+		case <-c.done:
+			return nil, ErrNoResponse
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case b := <-c.reconfigure:
+			m, err := dhcpv6.VerifyReconfigure(b, key)
+			if err != nil {
+				c.logger.Printf("discarding RECONFIGURE: %v", err)
+				continue
+			}
+			if serverID != nil && !serverID.Equal(m.Options.ServerID()) {
+				continue
+			}
+			if clientID != nil && !clientID.Equal(m.Options.ClientID()) {
+				continue
+			}
+			if m.Options.Auth().ReplayDetection <= last {
+				c.logger.Printf("discarding replayed RECONFIGURE")
+				continue
+			}
+			c.logger.PrintMessage("received message", m)
+			return m, nil
+		}
+	}
+}
+
+// Reconfigure waits for a valid reconfigure message from the server of the
+// given reply with WaitReconfigure, then starts the exchange it asks for:
+// Renew, Rebind or InformationRequest. It returns the reply of that exchange.
+func (c *Client) Reconfigure(ctx context.Context, reply *dhcpv6.Message, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	reconf, err := c.WaitReconfigure(ctx, reply)
+	if err != nil {
+		return nil, err
+	}
+	switch t := reconf.Options.ReconfigureMessage(); t {
+	case dhcpv6.MessageTypeRenew:
+		return c.Renew(ctx, reply, modifiers...)
+	case dhcpv6.MessageTypeRebind:
+		return c.Rebind(ctx, reply, modifiers...)
+	case dhcpv6.MessageTypeInformationRequest:
+		// RFC 8415 Section 18.2.6: an information request sent in
+		// response to a reconfigure message includes the Server ID
+		// of the reconfigure message.
+		return c.InformationRequest(ctx, append([]dhcpv6.Modifier{
+			dhcpv6.WithClientID(reply.Options.ClientID()),
+			dhcpv6.WithServerID(reconf.Options.ServerID()),
+		}, modifiers...)...)
+	default:
+		return nil, fmt.Errorf("cannot reconfigure with message type %s", t)
+	}
+}
+
 // send sends p to destination and returns a response channel.
 //
 // The returned function must be called after all desired responses have been
@@ -0,0 +1,33 @@
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// ErrNoDHCP4o6Servers is returned by DHCP4oDHCP6Servers if the server does
+// not offer DHCPv4 over DHCPv6.
+var ErrNoDHCP4o6Servers = errors.New("no DHCP 4o6 Server Address option in reply")
+
+// DHCP4oDHCP6Servers asks for the DHCP 4o6 Server Address option with an
+// Information-request message, as described in RFC 7341, Section 5, and
+// returns the DHCP 4o6 servers to send DHCPv4-QUERY messages to.
+//
+// The list is empty if queries must be sent to
+// All_DHCP_Relay_Agents_and_Servers, as nclient4.NewDHCP4o6Conn does.
+func (c *Client) DHCP4oDHCP6Servers(ctx context.Context, modifiers ...dhcpv6.Modifier) ([]net.IP, error) {
+	reply, err := c.InformationRequest(ctx, append([]dhcpv6.Modifier{
+		dhcpv6.WithRequestedOptions(dhcpv6.OptionDHCP4oDHCP6Server),
+	}, modifiers...)...)
+	if err != nil {
+		return nil, err
+	}
+	opt := reply.Options.DHCP4oDHCP6Server()
+	if opt == nil {
+		return nil, ErrNoDHCP4o6Servers
+	}
+	return opt.DHCP4oDHCP6Servers, nil
+}
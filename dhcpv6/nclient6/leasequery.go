@@ -0,0 +1,138 @@
+package nclient6
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Leasequery sends the LEASEQUERY message query to the server at dest, as
+// described in RFC 5007, and returns its LEASEQUERY-REPLY.
+//
+// Queries are built with dhcpv6.NewLeasequeryByAddress or
+// dhcpv6.NewLeasequeryByClientID. The reply may carry an error status, a
+// Client Data option, or, for queries about all links, the links of the
+// client in an LQ Client Link option.
+func (c *Client) Leasequery(ctx context.Context, dest *net.UDPAddr, query *dhcpv6.Message) (*dhcpv6.Message, error) {
+	if query.MessageType != dhcpv6.MessageTypeLeaseQuery {
+		return nil, fmt.Errorf("not a LEASEQUERY message: %s", query.MessageType)
+	}
+	return c.SendAndRead(ctx, dest, query, IsMessageType(dhcpv6.MessageTypeLeaseQueryReply))
+}
+
+// requestorID returns the modifier setting the client identifier of
+// leasequeries, derived from the interface hardware address.
+func (c *Client) requestorID() dhcpv6.Modifier {
+	return dhcpv6.WithClientID(&dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: c.ifaceHWAddr})
+}
+
+// LeasequeryByAddress asks the server at dest about the bindings of the
+// client of addr. link may be nil to ask about all links.
+func (c *Client) LeasequeryByAddress(ctx context.Context, dest *net.UDPAddr, addr, link net.IP, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	query, err := dhcpv6.NewLeasequeryByAddress(addr, link, append([]dhcpv6.Modifier{c.requestorID()}, modifiers...)...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Leasequery(ctx, dest, query)
+}
+
+// LeasequeryByClientID asks the server at dest about the bindings of the
+// client with the given DUID. link may be nil to ask about all links.
+func (c *Client) LeasequeryByClientID(ctx context.Context, dest *net.UDPAddr, duid dhcpv6.DUID, link net.IP, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	query, err := dhcpv6.NewLeasequeryByClientID(duid, link, append([]dhcpv6.Modifier{c.requestorID()}, modifiers...)...)
+	if err != nil {
+		return nil, err
+	}
+	return c.Leasequery(ctx, dest, query)
+}
+
+// ErrLeasequeryStatus is returned by BulkLeasequery, StartTLS and
+// ActiveRequestor.Run if the server replies with an error status, or ends an
+// active leasequery.
+type ErrLeasequeryStatus struct {
+	Status *dhcpv6.OptStatusCode
+}
+
+// Error implements error.Error.
+func (e *ErrLeasequeryStatus) Error() string {
+	return fmt.Sprintf("leasequery failed: %s", e.Status)
+}
+
+// BulkLeasequery sends the LEASEQUERY message query over conn, a TCP
+// connection to port 547 of a server, as described in RFC 5460. It calls fn
+// with each reply: the LEASEQUERY-REPLY message, then the LEASEQUERY-DATA
+// messages carrying the bindings of the other clients, if any.
+//
+// Queries are built with dhcpv6.NewLeasequery. Several queries may be sent
+// over the same connection, one after the other. If fn returns an error or
+// ctx is done, BulkLeasequery returns without reading the other replies, and
+// conn must be closed.
+func BulkLeasequery(ctx context.Context, conn net.Conn, query *dhcpv6.Message, fn func(*dhcpv6.Message) error) error {
+	if query.MessageType != dhcpv6.MessageTypeLeaseQuery {
+		return fmt.Errorf("not a LEASEQUERY message: %s", query.MessageType)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock reads and writes.
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	err := bulkLeasequery(conn, query, fn)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func leasequeryStatus(m *dhcpv6.Message) error {
+	if s := m.Options.Status(); s != nil && s.StatusCode != iana.StatusSuccess {
+		return &ErrLeasequeryStatus{Status: s}
+	}
+	return nil
+}
+
+func bulkLeasequery(conn net.Conn, query *dhcpv6.Message, fn func(*dhcpv6.Message) error) error {
+	if err := dhcpv6.WriteTCPMessage(conn, query); err != nil {
+		return err
+	}
+	replied := false
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		if err != nil {
+			return err
+		}
+		if m.TransactionID != query.TransactionID {
+			continue
+		}
+		switch {
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryReply && !replied:
+			if err := leasequeryStatus(m); err != nil {
+				return err
+			}
+			if err := fn(m); err != nil {
+				return err
+			}
+			// Replies without client data are not followed by other
+			// messages, RFC 5460, Section 6.3.
+			if m.Options.ClientData() == nil {
+				return nil
+			}
+			replied = true
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryData && replied:
+			if err := fn(m); err != nil {
+				return err
+			}
+		case m.MessageType == dhcpv6.MessageTypeLeaseQueryDone && replied:
+			return leasequeryStatus(m)
+		default:
+			return fmt.Errorf("unexpected bulk leasequery reply: %s", m.MessageType)
+		}
+	}
+}
@@ -35,7 +35,11 @@ func (op *Opt4RD) LongString(indentSpace int) string {
 // FromBytes builds an Opt4RD structure from a sequence of bytes.
 // The input data does not include option code and length bytes
 func (op *Opt4RD) FromBytes(data []byte) error {
-	return op.Options.FromBytes(data)
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *Opt4RD) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	return op.Options.FromBytesWithParser(data, r.Parser(OptionSpace4RD))
 }
 
 // FourRDOptions are options that can be encapsulated with the 4RD option.
@@ -0,0 +1,44 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+)
+
+// OptAFTRName returns an AFTR-Name option as defined by RFC 6334: the FQDN of
+// the DS-Lite Address Family Transition Router.
+func OptAFTRName(name string) Option {
+	return &optAFTRName{Name: name}
+}
+
+type optAFTRName struct {
+	Name string
+}
+
+func (*optAFTRName) Code() OptionCode {
+	return OptionAFTRName
+}
+
+// ToBytes marshals this option to bytes.
+func (op *optAFTRName) ToBytes() []byte {
+	return (&rfc1035label.Labels{Labels: []string{op.Name}}).ToBytes()
+}
+
+func (op *optAFTRName) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.Name)
+}
+
+// FromBytes builds an optAFTRName structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *optAFTRName) FromBytes(data []byte) error {
+	labels, err := rfc1035label.FromBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(labels.Labels) != 1 {
+		return fmt.Errorf("AFTR-Name option must contain exactly one name, got %d", len(labels.Labels))
+	}
+	op.Name = labels.Labels[0]
+	return nil
+}
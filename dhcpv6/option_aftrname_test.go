@@ -0,0 +1,32 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptAFTRName(t *testing.T) {
+	data := []byte{
+		4, 'a', 'f', 't', 'r',
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
+		3, 'c', 'o', 'm',
+		0,
+	}
+	opt := OptAFTRName("aftr.example.com")
+	require.Equal(t, OptionAFTRName, opt.Code())
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "aftr.example.com")
+
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(append([]byte{0, 64, 0, byte(len(data))}, data...)))
+	require.Equal(t, "aftr.example.com", mo.AFTRName())
+
+	m, err := NewMessage(WithAFTRName("aftr.example.net"))
+	require.NoError(t, err)
+	require.Equal(t, "aftr.example.net", m.Options.AFTRName())
+
+	var o optAFTRName
+	require.Error(t, o.FromBytes(append(data, data...)))
+	require.Error(t, o.FromBytes([]byte{4, 'a'}))
+}
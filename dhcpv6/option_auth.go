@@ -0,0 +1,103 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/u-root/uio/uio"
+)
+
+// AuthProtocol is the protocol field of the Authentication option.
+type AuthProtocol uint8
+
+// Authentication protocols, as registered by IANA.
+const (
+	// AuthProtocolDelayed is the delayed authentication protocol of RFC
+	// 3315, Section 21.4, obsoleted by RFC 8415.
+	AuthProtocolDelayed AuthProtocol = 2
+	// AuthProtocolReconfigureKey is described by RFC 8415, Section 20.4.
+	AuthProtocolReconfigureKey AuthProtocol = 3
+)
+
+// String returns the name of the protocol.
+func (p AuthProtocol) String() string {
+	switch p {
+	case AuthProtocolDelayed:
+		return "Delayed Authentication"
+	case AuthProtocolReconfigureKey:
+		return "Reconfigure Key"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(p))
+}
+
+// AuthAlgorithm is the algorithm field of the Authentication option.
+type AuthAlgorithm uint8
+
+// AuthAlgorithmHMACMD5 is the HMAC-MD5 algorithm, the only algorithm of the
+// reconfigure key protocol.
+const AuthAlgorithmHMACMD5 AuthAlgorithm = 1
+
+// String returns the name of the algorithm.
+func (a AuthAlgorithm) String() string {
+	if a == AuthAlgorithmHMACMD5 {
+		return "HMAC-MD5"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(a))
+}
+
+// AuthRDM is the replay detection method field of the Authentication option.
+type AuthRDM uint8
+
+// AuthRDMMonotonic means the replay detection field is a monotonically
+// increasing counter, RFC 8415, Section 20.3.
+const AuthRDMMonotonic AuthRDM = 0
+
+// String returns the name of the replay detection method.
+func (r AuthRDM) String() string {
+	if r == AuthRDMMonotonic {
+		return "Monotonic"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(r))
+}
+
+// OptAuth implements the Authentication option, as defined by RFC 8415,
+// Section 21.11. The format of AuthInfo depends on the protocol.
+type OptAuth struct {
+	Protocol        AuthProtocol
+	Algorithm       AuthAlgorithm
+	RDM             AuthRDM
+	ReplayDetection uint64
+	AuthInfo        []byte
+}
+
+// Code returns the option code.
+func (op *OptAuth) Code() OptionCode {
+	return OptionAuth
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptAuth) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(uint8(op.Protocol))
+	buf.Write8(uint8(op.Algorithm))
+	buf.Write8(uint8(op.RDM))
+	buf.Write64(op.ReplayDetection)
+	buf.WriteBytes(op.AuthInfo)
+	return buf.Data()
+}
+
+func (op *OptAuth) String() string {
+	return fmt.Sprintf("%s: {Protocol=%s Algorithm=%s RDM=%s ReplayDetection=%#x AuthInfo=%#x}",
+		op.Code(), op.Protocol, op.Algorithm, op.RDM, op.ReplayDetection, op.AuthInfo)
+}
+
+// FromBytes builds an OptAuth structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *OptAuth) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.Protocol = AuthProtocol(buf.Read8())
+	op.Algorithm = AuthAlgorithm(buf.Read8())
+	op.RDM = AuthRDM(buf.Read8())
+	op.ReplayDetection = buf.Read64()
+	op.AuthInfo = buf.ReadAll()
+	return buf.FinError()
+}
@@ -0,0 +1,45 @@
+package dhcpv6
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/u-root/uio/uio"
+)
+
+func TestAuthParseAndGetter(t *testing.T) {
+	buf := []byte{
+		0, 11, // Auth
+		0, 15, // length
+		3, 1, 0, // protocol, algorithm, RDM
+		0, 0, 0, 0, 0, 0, 0x12, 0x34, // replay detection
+		1, 0xaa, 0xbb, 0xcc, // auth info
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(buf))
+	want := &OptAuth{
+		Protocol:        AuthProtocolReconfigureKey,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: 0x1234,
+		AuthInfo:        []byte{1, 0xaa, 0xbb, 0xcc},
+	}
+	require.Equal(t, want, mo.Auth())
+	require.Equal(t, buf, mo.ToBytes())
+	require.Equal(t, "Auth: {Protocol=Reconfigure Key Algorithm=HMAC-MD5 RDM=Monotonic ReplayDetection=0x1234 AuthInfo=0x01aabbcc}", want.String())
+
+	require.Error(t, mo.FromBytes([]byte{0, 11, 0, 3, 3, 1, 0}))
+	mo = MessageOptions{Options{&OptionGeneric{OptionCode: OptionAuth}}}
+	require.Nil(t, mo.Auth())
+
+	var opt OptAuth
+	require.True(t, errors.Is(opt.FromBytes([]byte{3, 1, 0, 0}), uio.ErrBufferTooShort))
+}
+
+func TestAuthStrings(t *testing.T) {
+	require.Equal(t, "Delayed Authentication", AuthProtocolDelayed.String())
+	require.Equal(t, "unknown (9)", AuthProtocol(9).String())
+	require.Equal(t, "unknown (2)", AuthAlgorithm(2).String())
+	require.Equal(t, "unknown (1)", AuthRDM(1).String())
+}
@@ -0,0 +1,43 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// OptCaptivePortal returns a Captive Portal option as defined by RFC 8910,
+// Section 2.2, or an error if uri is not valid, see
+// dhcpv4.ValidCaptivePortalURI.
+func OptCaptivePortal(uri string) (Option, error) {
+	if err := dhcpv4.ValidCaptivePortalURI(uri); err != nil {
+		return nil, err
+	}
+	return &optCaptivePortal{URI: uri}, nil
+}
+
+type optCaptivePortal struct {
+	URI string
+}
+
+func (*optCaptivePortal) Code() OptionCode {
+	return OptionCaptivePortal
+}
+
+func (op *optCaptivePortal) ToBytes() []byte {
+	return []byte(op.URI)
+}
+
+func (op *optCaptivePortal) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.URI)
+}
+
+// FromBytes builds an optCaptivePortal structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+//
+// The URI is not validated here, so that a message with an invalid URI can
+// still be parsed: MessageOptions.CaptivePortal ignores it.
+func (op *optCaptivePortal) FromBytes(data []byte) error {
+	op.URI = string(data)
+	return nil
+}
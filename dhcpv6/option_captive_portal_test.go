@@ -0,0 +1,65 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptCaptivePortal(t *testing.T) {
+	opt, err := OptCaptivePortal("https://portal.example.net/api")
+	require.NoError(t, err)
+	require.Equal(t, OptionCaptivePortal, opt.Code())
+	require.Equal(t, []byte("https://portal.example.net/api"), opt.ToBytes())
+	require.Equal(t, "Captive Portal URI: https://portal.example.net/api", opt.String())
+
+	m, err := NewMessage(WithOption(opt))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, "https://portal.example.net/api", got.Options.CaptivePortal())
+
+	for _, uri := range []string{"http://portal.example.net/api", "/api", "https://%zz"} {
+		require.Error(t, dhcpv4.ValidCaptivePortalURI(uri), uri)
+		_, err := OptCaptivePortal(uri)
+		require.Error(t, err, uri)
+		// Invalid URIs are parsed, and ignored by the accessor.
+		m, err := NewMessage(WithOption(&optCaptivePortal{URI: uri}))
+		require.NoError(t, err)
+		got, err := MessageFromBytes(m.ToBytes())
+		require.NoError(t, err)
+		require.Empty(t, got.Options.CaptivePortal(), uri)
+	}
+}
+
+func TestWithCaptivePortalByClass(t *testing.T) {
+	uris, err := dhcpv4.NewCaptivePortalClasses(map[string]string{
+		"":       "https://portal.example.net/api",
+		"guest":  "https://guest.example.net/api",
+		"vendor": dhcpv4.CaptivePortalUnrestricted,
+	})
+	require.NoError(t, err)
+	vendor := WithOption(&OptVendorClass{EnterpriseNumber: 1, Data: [][]byte{[]byte("vendor")}})
+	requested := WithRequestedOptions(OptionCaptivePortal)
+	for _, tt := range []struct {
+		name      string
+		modifiers []Modifier
+		want      string
+	}{
+		{"default", []Modifier{requested}, "https://portal.example.net/api"},
+		{"user class", []Modifier{requested, WithUserClass([]byte("guest")), vendor}, "https://guest.example.net/api"},
+		{"vendor class", []Modifier{requested, WithUserClass([]byte("other")), vendor}, dhcpv4.CaptivePortalUnrestricted},
+		{"not requested", nil, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sol, err := NewSolicit(net.HardwareAddr{2, 0, 0, 0, 0, 1}, tt.modifiers...)
+			require.NoError(t, err)
+			adv, err := NewAdvertiseFromSolicit(sol, WithServerID(&DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 2}}),
+				WithCaptivePortalByClass(sol, uris))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, adv.Options.CaptivePortal())
+		})
+	}
+}
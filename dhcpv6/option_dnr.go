@@ -0,0 +1,94 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/insomniacslk/dhcp/svcb"
+	"github.com/u-root/uio/uio"
+)
+
+// OptDNR implements the Encrypted DNS option, as defined by RFC 9463,
+// Section 4.1: an encrypted DNS resolver designated by the network.
+//
+// An option without addresses is in ADN-only mode: the client resolves the
+// name of the resolver itself. Otherwise, Params must include the alpn
+// SvcParam.
+type OptDNR struct {
+	// ServicePriority orders the resolvers, lower values first.
+	ServicePriority uint16
+	// ADN is the Authentication Domain Name of the resolver.
+	ADN    string
+	Addrs  []net.IP
+	Params svcb.Params
+}
+
+// Code returns the option code.
+func (op *OptDNR) Code() OptionCode {
+	return OptionV6DNR
+}
+
+// ADNOnly returns whether the option is in ADN-only mode.
+func (op *OptDNR) ADNOnly() bool {
+	return len(op.Addrs) == 0
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptDNR) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(op.ServicePriority)
+	adn := (&rfc1035label.Labels{Labels: []string{op.ADN}}).ToBytes()
+	buf.Write16(uint16(len(adn)))
+	buf.WriteBytes(adn)
+	if op.ADNOnly() {
+		return buf.Data()
+	}
+	buf.Write16(uint16(len(op.Addrs) * net.IPv6len))
+	for _, addr := range op.Addrs {
+		write16(buf, addr)
+	}
+	buf.WriteBytes(op.Params.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptDNR) String() string {
+	return fmt.Sprintf("%s: {ServicePriority=%d ADN=%s Addrs=%v Params=%s}",
+		op.Code(), op.ServicePriority, op.ADN, op.Addrs, op.Params)
+}
+
+// FromBytes builds an OptDNR structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *OptDNR) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.ServicePriority = buf.Read16()
+	adn := buf.Consume(int(buf.Read16()))
+	if err := buf.Error(); err != nil {
+		return err
+	}
+	labels, err := rfc1035label.FromBytes(adn)
+	if err != nil {
+		return err
+	}
+	if len(labels.Labels) != 1 {
+		return fmt.Errorf("DNR option must contain exactly one authentication domain name, got %d", len(labels.Labels))
+	}
+	op.ADN = labels.Labels[0]
+	op.Addrs, op.Params = nil, nil
+	if !buf.Has(1) {
+		// ADN-only mode.
+		return nil
+	}
+	n := int(buf.Read16())
+	if n == 0 || n%net.IPv6len != 0 {
+		return fmt.Errorf("invalid DNR option address length %d", n)
+	}
+	for i := 0; i < n/net.IPv6len; i++ {
+		op.Addrs = append(op.Addrs, net.IP(buf.CopyN(net.IPv6len)))
+	}
+	if err := buf.Error(); err != nil {
+		return err
+	}
+	op.Params, err = svcb.FromBytes(buf.ReadAll())
+	return err
+}
@@ -0,0 +1,66 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/svcb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptDNR(t *testing.T) {
+	data := []byte{
+		0, 1, // service priority
+		0, 17, // ADN length
+		3, 'd', 'n', 's', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'n', 'e', 't', 0,
+		0, 16, // addr length
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x53,
+		0, 1, 0, 4, 3, 'd', 'o', 't', // alpn=dot
+		0, 3, 0, 2, 0x03, 0x55, // port=853
+	}
+	var opt OptDNR
+	require.NoError(t, opt.FromBytes(data))
+	require.Equal(t, uint16(1), opt.ServicePriority)
+	require.Equal(t, "dns.example.net", opt.ADN)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, opt.Addrs)
+	require.False(t, opt.ADNOnly())
+	require.Equal(t, []string{"dot"}, opt.Params.ALPN())
+	port, ok := opt.Params.Port()
+	require.True(t, ok)
+	require.Equal(t, uint16(853), port)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "alpn=dot port=853")
+
+	// ADN-only mode.
+	var adnOnly OptDNR
+	require.NoError(t, adnOnly.FromBytes(data[:21]))
+	require.True(t, adnOnly.ADNOnly())
+	require.Nil(t, adnOnly.Params)
+	require.Equal(t, data[:21], adnOnly.ToBytes())
+
+	// Invalid address lengths.
+	require.Error(t, opt.FromBytes(append(data[:21:21], 0, 0)))
+	require.Error(t, opt.FromBytes(append(data[:21:21], 0, 4, 192, 0, 2, 1)))
+	// Two names.
+	require.Error(t, opt.FromBytes([]byte{0, 1, 0, 6, 1, 'a', 0, 1, 'b', 0}))
+}
+
+func TestWithDNR(t *testing.T) {
+	params := make(svcb.Params)
+	params.SetALPN("h2")
+	params.SetDoHPath("/dns-query{?dns}")
+	m, err := NewMessage(WithDNR(
+		&OptDNR{ServicePriority: 20, ADN: "doh.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::1")}, Params: params},
+		&OptDNR{ServicePriority: 10, ADN: "dot.example.net"},
+	))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	dnrs := got.Options.DNR()
+	require.Len(t, dnrs, 2)
+	require.Equal(t, "dot.example.net", dnrs[0].ADN)
+	require.Equal(t, "doh.example.net", dnrs[1].ADN)
+	path, ok := dnrs[1].Params.DoHPath()
+	require.True(t, ok)
+	require.Equal(t, "/dns-query{?dns}", path)
+}
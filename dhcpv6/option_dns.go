@@ -9,37 +9,50 @@ import (
 
 // OptDNS returns a DNS Recursive Name Server option as defined by RFC 3646.
 func OptDNS(ip ...net.IP) Option {
-	return &optDNS{NameServers: ip}
+	return &optAddressList{OptionCode: OptionDNSRecursiveNameServer, Addrs: ip}
 }
 
-type optDNS struct {
-	NameServers []net.IP
+// optAddressList is an option that holds a list of IPv6 addresses, like the
+// DNS Recursive Name Server, SIP Servers and NIS Servers options.
+type optAddressList struct {
+	OptionCode OptionCode
+	Addrs      []net.IP
 }
 
-// Code returns the option code
-func (op *optDNS) Code() OptionCode {
-	return OptionDNSRecursiveNameServer
+func (op *optAddressList) Code() OptionCode {
+	return op.OptionCode
 }
 
-// ToBytes returns the option serialized to bytes.
-func (op *optDNS) ToBytes() []byte {
+func (op *optAddressList) ToBytes() []byte {
 	buf := uio.NewBigEndianBuffer(nil)
-	for _, ns := range op.NameServers {
-		buf.WriteBytes(ns.To16())
+	for _, addr := range op.Addrs {
+		write16(buf, addr)
 	}
 	return buf.Data()
 }
 
-func (op *optDNS) String() string {
-	return fmt.Sprintf("%s: %v", op.Code(), op.NameServers)
+func (op *optAddressList) String() string {
+	return fmt.Sprintf("%s: %v", op.Code(), op.Addrs)
 }
 
-// FromBytes builds an optDNS structure from a sequence of bytes. The input
-// data does not include option code and length bytes.
-func (op *optDNS) FromBytes(data []byte) error {
+// FromBytes builds an optAddressList structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *optAddressList) FromBytes(data []byte) error {
+	if len(data)%net.IPv6len != 0 {
+		return fmt.Errorf("%w: %s length %d is not a multiple of %d", uio.ErrUnreadBytes, op.OptionCode, len(data), net.IPv6len)
+	}
 	buf := uio.NewBigEndianBuffer(data)
+	op.Addrs = nil
 	for buf.Has(net.IPv6len) {
-		op.NameServers = append(op.NameServers, buf.CopyN(net.IPv6len))
+		op.Addrs = append(op.Addrs, buf.CopyN(net.IPv6len))
 	}
 	return buf.FinError()
 }
+
+// addressList returns the addresses of the first option with code in o.
+func addressList(o Options, code OptionCode) []net.IP {
+	if opt, ok := o.GetOne(code).(*optAddressList); ok {
+		return opt.Addrs
+	}
+	return nil
+}
@@ -7,6 +7,17 @@ import (
 	"github.com/u-root/uio/uio"
 )
 
+// FQDN option flags, as described in RFC 4704, Section 4.1.
+const (
+	// FQDNFlagS is set if the server should perform the AAAA RR updates.
+	FQDNFlagS uint8 = 1 << 0
+	// FQDNFlagO is set by the server if it overrode the client's
+	// preference for the S flag.
+	FQDNFlagO uint8 = 1 << 1
+	// FQDNFlagN is set if the server should not perform any DNS updates.
+	FQDNFlagN uint8 = 1 << 2
+)
+
 // OptFQDN implements OptionFQDN option.
 //
 // https://tools.ietf.org/html/rfc4704
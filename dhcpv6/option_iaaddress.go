@@ -72,6 +72,10 @@ func (op *OptIAAddress) LongString(indent int) string {
 // FromBytes builds an OptIAAddress structure from a sequence of bytes. The
 // input data does not include option code and length bytes.
 func (op *OptIAAddress) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptIAAddress) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 	op.IPv6Addr = net.IP(buf.CopyN(net.IPv6len))
 
@@ -81,7 +85,7 @@ func (op *OptIAAddress) FromBytes(data []byte) error {
 	op.PreferredLifetime = t1.Duration
 	op.ValidLifetime = t2.Duration
 
-	if err := op.Options.FromBytes(buf.ReadAll()); err != nil {
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceIAAddress)); err != nil {
 		return err
 	}
 	return buf.FinError()
@@ -85,6 +85,10 @@ func (op *OptIAPD) LongString(indentSpace int) string {
 // FromBytes builds an OptIAPD structure from a sequence of bytes. The input
 // data does not include option code and length bytes.
 func (op *OptIAPD) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptIAPD) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 	buf.ReadBytes(op.IaId[:])
 
@@ -94,7 +98,7 @@ func (op *OptIAPD) FromBytes(data []byte) error {
 	op.T1 = t1.Duration
 	op.T2 = t2.Duration
 
-	if err := op.Options.FromBytes(buf.ReadAll()); err != nil {
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceIAPD)); err != nil {
 		return err
 	}
 	return buf.FinError()
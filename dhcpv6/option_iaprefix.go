@@ -77,6 +77,10 @@ func (op *OptIAPrefix) String() string {
 // FromBytes an OptIAPrefix structure from a sequence of bytes. The input data
 // does not include option code and length bytes.
 func (op *OptIAPrefix) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptIAPrefix) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 
 	var t1, t2 Duration
@@ -96,7 +100,7 @@ func (op *OptIAPrefix) FromBytes(data []byte) error {
 			IP:   ip,
 		}
 	}
-	if err := op.Options.FromBytes(buf.ReadAll()); err != nil {
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceIAPrefix)); err != nil {
 		return err
 	}
 	return buf.FinError()
@@ -0,0 +1,533 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// LQQueryType is the query type of an LQ Query option.
+type LQQueryType uint8
+
+// Query types, as defined by RFC 5007, Section 4.1.2.1 and RFC 5460, Section
+// 5.2.
+const (
+	LQQueryByAddress     LQQueryType = 1
+	LQQueryByClientID    LQQueryType = 2
+	LQQueryByRelayID     LQQueryType = 3
+	LQQueryByLinkAddress LQQueryType = 4
+	LQQueryByRemoteID    LQQueryType = 5
+)
+
+var lqQueryTypeToString = map[LQQueryType]string{
+	LQQueryByAddress:     "QUERY_BY_ADDRESS",
+	LQQueryByClientID:    "QUERY_BY_CLIENTID",
+	LQQueryByRelayID:     "QUERY_BY_RELAY_ID",
+	LQQueryByLinkAddress: "QUERY_BY_LINK_ADDRESS",
+	LQQueryByRemoteID:    "QUERY_BY_REMOTE_ID",
+}
+
+// String returns the name of the query type.
+func (t LQQueryType) String() string {
+	if s, ok := lqQueryTypeToString[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(t))
+}
+
+// LQQueryOptions are the options of a query: the Client Identifier, IA
+// Address, Relay ID or Remote ID option the query is about, and the Option
+// Request option.
+type LQQueryOptions struct {
+	Options
+}
+
+// ClientID returns the client identifier of a query by client identifier.
+func (lo LQQueryOptions) ClientID() DUID {
+	if opt, ok := lo.Options.GetOne(OptionClientID).(*optClientID); ok {
+		return opt.DUID
+	}
+	return nil
+}
+
+// IAAddress returns the address of a query by address.
+func (lo LQQueryOptions) IAAddress() *OptIAAddress {
+	if opt, ok := lo.Options.GetOne(OptionIAAddr).(*OptIAAddress); ok {
+		return opt
+	}
+	return nil
+}
+
+// RelayID returns the relay agent DUID of a query by relay ID.
+func (lo LQQueryOptions) RelayID() DUID {
+	if opt, ok := lo.Options.GetOne(OptionRelayID).(*optRelayID); ok {
+		return opt.DUID
+	}
+	return nil
+}
+
+// RemoteID returns the remote ID of a query by remote ID.
+func (lo LQQueryOptions) RemoteID() *OptRemoteID {
+	if opt, ok := lo.Options.GetOne(OptionRemoteID).(*OptRemoteID); ok {
+		return opt
+	}
+	return nil
+}
+
+// RequestedOptions returns the options the requestor asks for.
+func (lo LQQueryOptions) RequestedOptions() OptionCodes {
+	if opt, ok := lo.Options.GetOne(OptionORO).(*optRequestedOption); ok {
+		return opt.OptionCodes
+	}
+	return nil
+}
+
+// StartTime returns the query start time of an active or bulk leasequery,
+// if present.
+func (lo LQQueryOptions) StartTime() (time.Time, bool) {
+	if opt, ok := lo.Options.GetOne(OptionLQStartTime).(*optLQTime); ok {
+		return opt.Time, true
+	}
+	return time.Time{}, false
+}
+
+// EndTime returns the query end time of a bulk leasequery, if present.
+func (lo LQQueryOptions) EndTime() (time.Time, bool) {
+	if opt, ok := lo.Options.GetOne(OptionLQEndTime).(*optLQTime); ok {
+		return opt.Time, true
+	}
+	return time.Time{}, false
+}
+
+// OptLQQuery implements the LQ Query option, which carries a leasequery, as
+// defined by RFC 5007, Section 4.1.2.1.
+type OptLQQuery struct {
+	QueryType LQQueryType
+	// LinkAddress is the link the query is about. It may be nil, or the
+	// unspecified address, for queries about all links.
+	LinkAddress net.IP
+	Options     LQQueryOptions
+}
+
+// Code returns the option code.
+func (op *OptLQQuery) Code() OptionCode {
+	return OptionLQQuery
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptLQQuery) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(uint8(op.QueryType))
+	write16(buf, op.LinkAddress)
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptLQQuery) String() string {
+	return fmt.Sprintf("%s: {QueryType=%s LinkAddress=%v Options=%v}",
+		op.Code(), op.QueryType, op.LinkAddress, op.Options)
+}
+
+// LongString returns a multi-line string representation of the option.
+func (op *OptLQQuery) LongString(indent int) string {
+	return fmt.Sprintf("%s: {QueryType=%s LinkAddress=%v Options=%v}",
+		op.Code(), op.QueryType, op.LinkAddress, op.Options.LongString(indent))
+}
+
+// FromBytes builds an OptLQQuery structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *OptLQQuery) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptLQQuery) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.QueryType = LQQueryType(buf.Read8())
+	op.LinkAddress = net.IP(buf.CopyN(net.IPv6len))
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceLQQuery)); err != nil {
+		return err
+	}
+	return buf.FinError()
+}
+
+// ClientDataOptions are the options of a Client Data option: the bindings of
+// a client.
+type ClientDataOptions struct {
+	Options
+}
+
+// ClientID returns the client identifier of the client.
+func (co ClientDataOptions) ClientID() DUID {
+	if opt, ok := co.Options.GetOne(OptionClientID).(*optClientID); ok {
+		return opt.DUID
+	}
+	return nil
+}
+
+// Addresses returns the addresses bound to the client.
+func (co ClientDataOptions) Addresses() []*OptIAAddress {
+	var addrs []*OptIAAddress
+	for _, o := range co.Options.Get(OptionIAAddr) {
+		if a, ok := o.(*OptIAAddress); ok {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
+
+// Prefixes returns the prefixes delegated to the client.
+func (co ClientDataOptions) Prefixes() []*OptIAPrefix {
+	var prefixes []*OptIAPrefix
+	for _, o := range co.Options.Get(OptionIAPrefix) {
+		if p, ok := o.(*OptIAPrefix); ok {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// CLTTime returns the time elapsed since the last transaction with the
+// client, if present.
+func (co ClientDataOptions) CLTTime() (time.Duration, bool) {
+	if opt, ok := co.Options.GetOne(OptionCLTTime).(*optCLTTime); ok {
+		return opt.Time, true
+	}
+	return 0, false
+}
+
+// LQRelayData returns the relay agent information of the client.
+func (co ClientDataOptions) LQRelayData() *OptLQRelayData {
+	if opt, ok := co.Options.GetOne(OptionLQRelayData).(*OptLQRelayData); ok {
+		return opt
+	}
+	return nil
+}
+
+// OptClientData implements the Client Data option, which carries the
+// bindings of a client in leasequery replies, as defined by RFC 5007, Section
+// 4.1.2.2.
+type OptClientData struct {
+	Options ClientDataOptions
+}
+
+// Code returns the option code.
+func (op *OptClientData) Code() OptionCode {
+	return OptionClientData
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptClientData) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptClientData) String() string {
+	return fmt.Sprintf("%s: {Options=%v}", op.Code(), op.Options)
+}
+
+// LongString returns a multi-line string representation of the option.
+func (op *OptClientData) LongString(indent int) string {
+	return fmt.Sprintf("%s: {Options=%v}", op.Code(), op.Options.LongString(indent))
+}
+
+// FromBytes builds an OptClientData structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *OptClientData) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptClientData) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	return op.Options.FromBytesWithParser(data, r.Parser(OptionSpaceClientData))
+}
+
+// OptCLTTime returns a Client Last Transaction Time option, as defined by RFC
+// 5007, Section 4.1.2.3: the time elapsed since the server last heard from
+// the client.
+func OptCLTTime(d time.Duration) Option {
+	return &optCLTTime{Time: d}
+}
+
+type optCLTTime struct {
+	Time time.Duration
+}
+
+func (*optCLTTime) Code() OptionCode {
+	return OptionCLTTime
+}
+
+func (op *optCLTTime) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	Duration{op.Time}.Marshal(buf)
+	return buf.Data()
+}
+
+func (op *optCLTTime) String() string {
+	return fmt.Sprintf("%s: %v", op.Code(), op.Time)
+}
+
+// FromBytes builds an optCLTTime structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optCLTTime) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	var d Duration
+	d.Unmarshal(buf)
+	op.Time = d.Duration
+	return buf.FinError()
+}
+
+// OptLQRelayData implements the LQ Relay Data option, as defined by RFC 5007,
+// Section 4.1.2.4: the last relay message received from a client, without
+// the client message itself.
+type OptLQRelayData struct {
+	// PeerAddress is the address of the relay agent the relay message was
+	// received from.
+	PeerAddress  net.IP
+	RelayMessage *RelayMessage
+}
+
+// Code returns the option code.
+func (op *OptLQRelayData) Code() OptionCode {
+	return OptionLQRelayData
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptLQRelayData) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	write16(buf, op.PeerAddress)
+	if op.RelayMessage != nil {
+		buf.WriteBytes(op.RelayMessage.ToBytes())
+	}
+	return buf.Data()
+}
+
+func (op *OptLQRelayData) String() string {
+	return fmt.Sprintf("%s: {PeerAddress=%v RelayMessage=%v}", op.Code(), op.PeerAddress, op.RelayMessage)
+}
+
+// FromBytes builds an OptLQRelayData structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *OptLQRelayData) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptLQRelayData) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.PeerAddress = net.IP(buf.CopyN(net.IPv6len))
+	if err := buf.Error(); err != nil {
+		return err
+	}
+	var err error
+	op.RelayMessage, err = relayMessageFromBytes(buf.ReadAll(), r)
+	return err
+}
+
+// OptRelayID returns a Relay-ID option, as defined by RFC 5460, Section
+// 5.4.1: the DUID of a relay agent.
+func OptRelayID(d DUID) Option {
+	return &optRelayID{d}
+}
+
+type optRelayID struct {
+	DUID
+}
+
+func (*optRelayID) Code() OptionCode {
+	return OptionRelayID
+}
+
+func (op *optRelayID) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.DUID)
+}
+
+// FromBytes builds an optRelayID structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optRelayID) FromBytes(data []byte) error {
+	var err error
+	op.DUID, err = DUIDFromBytes(data)
+	return err
+}
+
+// OptLQClientLink returns an LQ Client Link option, as defined by RFC 5007,
+// Section 4.1.2.5: the links on which a client has bindings.
+func OptLQClientLink(links ...net.IP) Option {
+	return &optLQClientLink{LinkAddresses: links}
+}
+
+type optLQClientLink struct {
+	LinkAddresses []net.IP
+}
+
+func (*optLQClientLink) Code() OptionCode {
+	return OptionLQClientLink
+}
+
+func (op *optLQClientLink) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, l := range op.LinkAddresses {
+		write16(buf, l)
+	}
+	return buf.Data()
+}
+
+func (op *optLQClientLink) String() string {
+	return fmt.Sprintf("%s: %v", op.Code(), op.LinkAddresses)
+}
+
+// FromBytes builds an optLQClientLink structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *optLQClientLink) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(net.IPv6len) {
+		op.LinkAddresses = append(op.LinkAddresses, buf.CopyN(net.IPv6len))
+	}
+	return buf.FinError()
+}
+
+// OptLQBaseTime returns an LQ Base Time option, as defined by RFC 7653,
+// Section 7.1: the current time of the server sending a leasequery reply.
+func OptLQBaseTime(t time.Time) Option {
+	return &optLQTime{code: OptionLQBaseTime, Time: t}
+}
+
+// OptLQStartTime returns an LQ Start Time option, as defined by RFC 7653,
+// Section 7.2: the server time from which a leasequery asks for the changes
+// of the bindings. It is carried in the LQ Query option.
+func OptLQStartTime(t time.Time) Option {
+	return &optLQTime{code: OptionLQStartTime, Time: t}
+}
+
+// OptLQEndTime returns an LQ End Time option, as defined by RFC 7653, Section
+// 7.3: the server time until which a bulk leasequery asks for the changes of
+// the bindings. It is carried in the LQ Query option.
+func OptLQEndTime(t time.Time) Option {
+	return &optLQTime{code: OptionLQEndTime, Time: t}
+}
+
+// optLQTime is an option carrying a time, in seconds since the epoch.
+type optLQTime struct {
+	code OptionCode
+	Time time.Time
+}
+
+func (op *optLQTime) Code() OptionCode {
+	return op.code
+}
+
+func (op *optLQTime) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write32(uint32(op.Time.Unix()))
+	return buf.Data()
+}
+
+func (op *optLQTime) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.Time.UTC().Format(time.RFC3339))
+}
+
+// FromBytes builds an optLQTime structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optLQTime) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.Time = time.Unix(int64(buf.Read32()), 0)
+	return buf.FinError()
+}
+
+// NewLeasequery creates a new LEASEQUERY message carrying query, as described
+// in RFC 5007, Section 4.2 and RFC 5460, Section 6.1.
+//
+// Requestors identify themselves with WithClientID. Bulk leasequeries are
+// sent over TCP with WriteTCPMessage.
+func NewLeasequery(query *OptLQQuery, modifiers ...Modifier) (*Message, error) {
+	if query == nil {
+		return nil, fmt.Errorf("LQ Query option cannot be nil")
+	}
+	m, err := NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = MessageTypeLeaseQuery
+	m.AddOption(query)
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+// NewActiveLeasequery creates a new ACTIVELEASEQUERY message carrying query,
+// as described in RFC 7653, Section 8.1. The query may be restricted to a
+// relay identifier, link address or remote ID, and may ask for the changes
+// since a start time with an LQ Start Time option.
+//
+// Active leasequeries are sent over TCP with WriteTCPMessage.
+func NewActiveLeasequery(query *OptLQQuery, modifiers ...Modifier) (*Message, error) {
+	m, err := NewLeasequery(query, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = MessageTypeActiveLeaseQuery
+	return m, nil
+}
+
+// NewStartTLS creates a new STARTTLS message, which asks a server to secure a
+// leasequery connection with TLS, as described in RFC 7653, Section 8.2.
+func NewStartTLS(modifiers ...Modifier) (*Message, error) {
+	m, err := NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = MessageTypeStartTLS
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+// NewLeasequeryReply creates a LEASEQUERY-REPLY message answering the
+// LEASEQUERY, ACTIVELEASEQUERY or STARTTLS message query, as described in RFC
+// 5007, Section 4.3.3. The client identifier of the requestor is copied from
+// the query.
+func NewLeasequeryReply(query *Message, modifiers ...Modifier) (*Message, error) {
+	if query == nil {
+		return nil, fmt.Errorf("LEASEQUERY cannot be nil")
+	}
+	switch query.MessageType {
+	case MessageTypeLeaseQuery, MessageTypeActiveLeaseQuery, MessageTypeStartTLS:
+	default:
+		return nil, fmt.Errorf("cannot create LEASEQUERY-REPLY from %s", query.MessageType)
+	}
+	m := &Message{
+		MessageType:   MessageTypeLeaseQueryReply,
+		TransactionID: query.TransactionID,
+	}
+	if cid := query.GetOneOption(OptionClientID); cid != nil {
+		m.AddOption(cid)
+	}
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+// NewLeasequeryByAddress creates a LEASEQUERY message asking about the
+// bindings of the client of addr, as described in RFC 5007, Section 4.1.2.1.
+// link may be nil to query all links.
+func NewLeasequeryByAddress(addr, link net.IP, modifiers ...Modifier) (*Message, error) {
+	if addr.To16() == nil {
+		return nil, fmt.Errorf("invalid address %v", addr)
+	}
+	lq := &OptLQQuery{QueryType: LQQueryByAddress, LinkAddress: link}
+	lq.Options.Add(&OptIAAddress{IPv6Addr: addr})
+	return NewLeasequery(lq, modifiers...)
+}
+
+// NewLeasequeryByClientID creates a LEASEQUERY message asking about the
+// bindings of the client with the given DUID, as described in RFC 5007,
+// Section 4.1.2.1. link may be nil to query all links.
+func NewLeasequeryByClientID(duid DUID, link net.IP, modifiers ...Modifier) (*Message, error) {
+	if duid == nil {
+		return nil, fmt.Errorf("client DUID cannot be nil")
+	}
+	lq := &OptLQQuery{QueryType: LQQueryByClientID, LinkAddress: link}
+	lq.Options.Add(OptClientID(duid))
+	return NewLeasequery(lq, modifiers...)
+}
@@ -0,0 +1,194 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptLQQuery(t *testing.T) {
+	duid := &DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	lq := &OptLQQuery{
+		QueryType:   LQQueryByAddress,
+		LinkAddress: net.ParseIP("2001:db8::1"),
+	}
+	lq.Options.Add(&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::10")})
+	lq.Options.Add(OptRequestedOption(OptionClientData))
+
+	var got Options
+	require.NoError(t, got.FromBytes(Options{lq}.ToBytes()))
+	q, ok := got.GetOne(OptionLQQuery).(*OptLQQuery)
+	require.True(t, ok)
+	require.Equal(t, LQQueryByAddress, q.QueryType)
+	require.True(t, q.LinkAddress.Equal(lq.LinkAddress))
+	require.True(t, q.Options.IAAddress().IPv6Addr.Equal(net.ParseIP("2001:db8::10")))
+	require.Equal(t, OptionCodes{OptionClientData}, q.Options.RequestedOptions())
+	require.Nil(t, q.Options.ClientID())
+	require.Nil(t, q.Options.RelayID())
+	require.Nil(t, q.Options.RemoteID())
+	require.Equal(t, "QUERY_BY_ADDRESS", q.QueryType.String())
+	require.Equal(t, "unknown (9)", LQQueryType(9).String())
+
+	q = &OptLQQuery{QueryType: LQQueryByRelayID}
+	q.Options.Add(OptRelayID(duid))
+	q.Options.Add(&OptRemoteID{EnterpriseNumber: 16, RemoteID: []byte("r1")})
+	q.Options.Add(OptClientID(duid))
+	got = nil
+	require.NoError(t, got.FromBytes(Options{q}.ToBytes()))
+	q = got.GetOne(OptionLQQuery).(*OptLQQuery)
+	require.True(t, q.LinkAddress.IsUnspecified())
+	require.True(t, duid.Equal(q.Options.RelayID()))
+	require.True(t, duid.Equal(q.Options.ClientID()))
+	require.Equal(t, []byte("r1"), q.Options.RemoteID().RemoteID)
+
+	require.Error(t, (&OptLQQuery{}).FromBytes([]byte{1, 0, 0}))
+}
+
+func TestOptClientData(t *testing.T) {
+	duid := &DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	relay := &RelayMessage{
+		MessageType: MessageTypeRelayForward,
+		LinkAddr:    net.ParseIP("2001:db8::1"),
+		PeerAddr:    net.ParseIP("fe80::1"),
+	}
+	relay.Options.Add(OptRelayID(duid))
+
+	cd := &OptClientData{}
+	cd.Options.Add(OptClientID(duid))
+	cd.Options.Add(&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::10"), PreferredLifetime: time.Minute, ValidLifetime: time.Hour})
+	cd.Options.Add(&OptIAPrefix{Prefix: &net.IPNet{IP: net.ParseIP("2001:db8:1::"), Mask: net.CIDRMask(48, 128)}, ValidLifetime: time.Hour})
+	cd.Options.Add(OptCLTTime(30 * time.Second))
+	cd.Options.Add(&OptLQRelayData{PeerAddress: net.ParseIP("2001:db8::2"), RelayMessage: relay})
+
+	m := &Message{MessageType: MessageTypeLeaseQueryReply}
+	m.AddOption(cd)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	c := got.Options.ClientData()
+	require.NotNil(t, c)
+	require.True(t, duid.Equal(c.Options.ClientID()))
+	require.Len(t, c.Options.Addresses(), 1)
+	require.Equal(t, time.Hour, c.Options.Addresses()[0].ValidLifetime)
+	require.Len(t, c.Options.Prefixes(), 1)
+	require.Equal(t, "2001:db8:1::/48", c.Options.Prefixes()[0].Prefix.String())
+	cltt, ok := c.Options.CLTTime()
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, cltt)
+
+	rd := c.Options.LQRelayData()
+	require.NotNil(t, rd)
+	require.True(t, rd.PeerAddress.Equal(net.ParseIP("2001:db8::2")))
+	require.True(t, rd.RelayMessage.LinkAddr.Equal(relay.LinkAddr))
+	require.True(t, duid.Equal(rd.RelayMessage.Options.RelayID()))
+
+	_, ok = (&OptClientData{}).Options.CLTTime()
+	require.False(t, ok)
+	require.Error(t, (&OptLQRelayData{}).FromBytes([]byte{0, 1}))
+}
+
+func TestNewLeasequery(t *testing.T) {
+	duid := &DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	_, err := NewLeasequery(nil)
+	require.Error(t, err)
+
+	lq := &OptLQQuery{QueryType: LQQueryByClientID}
+	lq.Options.Add(OptClientID(duid))
+	query, err := NewLeasequery(lq, WithClientID(duid))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeLeaseQuery, query.MessageType)
+	require.Equal(t, lq, query.Options.LQQuery())
+
+	reply, err := NewLeasequeryReply(query, WithServerID(duid))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeLeaseQueryReply, reply.MessageType)
+	require.Equal(t, query.TransactionID, reply.TransactionID)
+	require.True(t, duid.Equal(reply.Options.ClientID()))
+	require.True(t, duid.Equal(reply.Options.ServerID()))
+	require.Nil(t, reply.Options.LQQuery())
+
+	_, err = NewLeasequeryReply(reply)
+	require.Error(t, err)
+	_, err = NewLeasequeryReply(nil)
+	require.Error(t, err)
+}
+
+func TestOptLQClientLink(t *testing.T) {
+	links := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8:1::1")}
+	m := &Message{MessageType: MessageTypeLeaseQueryReply}
+	m.AddOption(OptLQClientLink(links...))
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Len(t, got.Options.LQClientLink(), 2)
+	for i, l := range got.Options.LQClientLink() {
+		require.True(t, links[i].Equal(l))
+	}
+	require.Nil(t, (&Message{}).Options.LQClientLink())
+
+	var o optLQClientLink
+	require.Error(t, o.FromBytes(make([]byte, 17)))
+}
+
+func TestNewLeasequeryBy(t *testing.T) {
+	duid := &DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	link := net.ParseIP("2001:db8::1")
+
+	m, err := NewLeasequeryByAddress(net.ParseIP("2001:db8::10"), link, WithClientID(duid))
+	require.NoError(t, err)
+	lq := m.Options.LQQuery()
+	require.Equal(t, LQQueryByAddress, lq.QueryType)
+	require.True(t, link.Equal(lq.LinkAddress))
+	require.True(t, net.ParseIP("2001:db8::10").Equal(lq.Options.IAAddress().IPv6Addr))
+	require.True(t, duid.Equal(m.Options.ClientID()))
+	_, err = NewLeasequeryByAddress(nil, nil)
+	require.Error(t, err)
+
+	m, err = NewLeasequeryByClientID(duid, nil)
+	require.NoError(t, err)
+	lq = m.Options.LQQuery()
+	require.Equal(t, LQQueryByClientID, lq.QueryType)
+	require.True(t, duid.Equal(lq.Options.ClientID()))
+	_, err = NewLeasequeryByClientID(nil, nil)
+	require.Error(t, err)
+}
+
+func TestOptLQTime(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	lq := &OptLQQuery{QueryType: LQQueryByLinkAddress, LinkAddress: net.IPv6unspecified}
+	lq.Options.Add(OptLQStartTime(base.Add(-time.Hour)))
+	lq.Options.Add(OptLQEndTime(base))
+	m, err := NewActiveLeasequery(lq)
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeActiveLeaseQuery, m.MessageType)
+	m.AddOption(OptLQBaseTime(base))
+
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	bt, ok := got.Options.LQBaseTime()
+	require.True(t, ok)
+	require.True(t, base.Equal(bt))
+	st, ok := got.Options.LQQuery().Options.StartTime()
+	require.True(t, ok)
+	require.True(t, base.Add(-time.Hour).Equal(st))
+	et, ok := got.Options.LQQuery().Options.EndTime()
+	require.True(t, ok)
+	require.True(t, base.Equal(et))
+
+	_, ok = (&Message{}).Options.LQBaseTime()
+	require.False(t, ok)
+	_, ok = LQQueryOptions{}.StartTime()
+	require.False(t, ok)
+	require.Error(t, (&optLQTime{}).FromBytes([]byte{1, 2, 3}))
+}
+
+func TestNewStartTLS(t *testing.T) {
+	m, err := NewStartTLS()
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeStartTLS, m.MessageType)
+	require.Equal(t, "STARTTLS", m.MessageType.String())
+
+	reply, err := NewLeasequeryReply(m)
+	require.NoError(t, err)
+	require.Equal(t, m.TransactionID, reply.TransactionID)
+}
@@ -0,0 +1,65 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// The range of the SOL_MAX_RT and INF_MAX_RT options, RFC 8415, Sections
+// 21.24 and 21.25. Clients ignore values outside of it.
+const (
+	minMaxRT = 60 * time.Second
+	maxMaxRT = 86400 * time.Second
+)
+
+// OptSolMaxRT returns a SOL_MAX_RT option as defined by RFC 8415, Section
+// 21.24: the maximum retransmission time of Solicit messages.
+func OptSolMaxRT(d time.Duration) Option {
+	return &optMaxRT{OptionCode: OptionSolMaxRT, MaxRT: d}
+}
+
+// OptInfMaxRT returns an INF_MAX_RT option as defined by RFC 8415, Section
+// 21.25: the maximum retransmission time of Information-request messages.
+func OptInfMaxRT(d time.Duration) Option {
+	return &optMaxRT{OptionCode: OptionInfMaxRT, MaxRT: d}
+}
+
+type optMaxRT struct {
+	OptionCode OptionCode
+	MaxRT      time.Duration
+}
+
+func (op *optMaxRT) Code() OptionCode {
+	return op.OptionCode
+}
+
+func (op *optMaxRT) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	Duration{op.MaxRT}.Marshal(buf)
+	return buf.Data()
+}
+
+func (op *optMaxRT) String() string {
+	return fmt.Sprintf("%s: %v", op.Code(), op.MaxRT)
+}
+
+// FromBytes builds an optMaxRT structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optMaxRT) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	var d Duration
+	d.Unmarshal(buf)
+	op.MaxRT = d.Duration
+	return buf.FinError()
+}
+
+// maxRT returns the value of the first option with code in o, and whether it
+// is present and in range.
+func maxRT(o Options, code OptionCode) (time.Duration, bool) {
+	if opt, ok := o.GetOne(code).(*optMaxRT); ok && opt.MaxRT >= minMaxRT && opt.MaxRT <= maxMaxRT {
+		return opt.MaxRT, true
+	}
+	return 0, false
+}
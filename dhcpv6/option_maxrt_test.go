@@ -0,0 +1,41 @@
+package dhcpv6
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptMaxRT(t *testing.T) {
+	data := []byte{
+		0, 82, // SOL_MAX_RT
+		0, 4, // length
+		0, 0, 0x0e, 0x10, // 3600
+		0, 83, // INF_MAX_RT
+		0, 4, // length
+		0, 0, 0, 10, // 10, out of range
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(data))
+	sol, ok := mo.SolMaxRT()
+	require.True(t, ok)
+	require.Equal(t, time.Hour, sol)
+	_, ok = mo.InfMaxRT()
+	require.False(t, ok)
+	require.Equal(t, data, mo.ToBytes())
+
+	m, err := NewMessage(WithSolMaxRT(2*time.Minute), WithInfMaxRT(24*time.Hour))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	sol, ok = got.Options.SolMaxRT()
+	require.True(t, ok)
+	require.Equal(t, 2*time.Minute, sol)
+	inf, ok := got.Options.InfMaxRT()
+	require.True(t, ok)
+	require.Equal(t, 24*time.Hour, inf)
+	require.Contains(t, got.LongString(0), "2m0s")
+
+	require.Error(t, mo.FromBytes([]byte{0, 82, 0, 2, 0, 1}))
+}
@@ -0,0 +1,72 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+)
+
+// OptNISServers returns a Network Information Service (NIS) Servers option
+// as defined by RFC 3898, Section 3.
+func OptNISServers(ip ...net.IP) Option {
+	return &optAddressList{OptionCode: OptionNISServers, Addrs: ip}
+}
+
+// OptNISPServers returns a Network Information Service V2 (NIS+) Servers
+// option as defined by RFC 3898, Section 4.
+func OptNISPServers(ip ...net.IP) Option {
+	return &optAddressList{OptionCode: OptionNISPServers, Addrs: ip}
+}
+
+// OptNISDomainName returns a NIS Domain Name option as defined by RFC 3898,
+// Section 5.
+func OptNISDomainName(name string) Option {
+	return &optDomainName{OptionCode: OptionNISDomainName, Name: name}
+}
+
+// OptNISPDomainName returns a NIS+ Domain Name option as defined by RFC 3898,
+// Section 6.
+func OptNISPDomainName(name string) Option {
+	return &optDomainName{OptionCode: OptionNISPDomainName, Name: name}
+}
+
+// optDomainName is an option that holds a single domain name.
+type optDomainName struct {
+	OptionCode OptionCode
+	Name       string
+}
+
+func (op *optDomainName) Code() OptionCode {
+	return op.OptionCode
+}
+
+func (op *optDomainName) ToBytes() []byte {
+	return (&rfc1035label.Labels{Labels: []string{op.Name}}).ToBytes()
+}
+
+func (op *optDomainName) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.Name)
+}
+
+// FromBytes builds an optDomainName structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *optDomainName) FromBytes(data []byte) error {
+	labels, err := rfc1035label.FromBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(labels.Labels) != 1 {
+		return fmt.Errorf("%s option must contain exactly one name, got %d", op.OptionCode, len(labels.Labels))
+	}
+	op.Name = labels.Labels[0]
+	return nil
+}
+
+// domainName returns the name of the first option with code in o, or "".
+func domainName(o Options, code OptionCode) string {
+	if opt, ok := o.GetOne(code).(*optDomainName); ok {
+		return opt.Name
+	}
+	return ""
+}
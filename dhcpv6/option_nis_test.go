@@ -0,0 +1,41 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptNIS(t *testing.T) {
+	nis := []net.IP{net.ParseIP("2001:db8::1")}
+	nisp := []net.IP{net.ParseIP("2001:db8::2"), net.ParseIP("2001:db8::3")}
+	m, err := NewMessage(
+		WithNISServers(nis...),
+		WithNISPServers(nisp...),
+		WithNISDomainName("nis.example.net"),
+		WithNISPDomainName("nisplus.example.net"),
+	)
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, nis, got.Options.NISServers())
+	require.Equal(t, nisp, got.Options.NISPServers())
+	require.Equal(t, "nis.example.net", got.Options.NISDomainName())
+	require.Equal(t, "nisplus.example.net", got.Options.NISPDomainName())
+	require.Equal(t, m.ToBytes(), got.ToBytes())
+	require.Contains(t, got.LongString(0), "NIS Domain Name: nis.example.net")
+}
+
+func TestOptNISDomainName(t *testing.T) {
+	data := []byte{3, 'n', 'i', 's', 0}
+	opt := OptNISDomainName("nis")
+	require.Equal(t, OptionNISDomainName, opt.Code())
+	require.Equal(t, data, opt.ToBytes())
+
+	o := &optDomainName{OptionCode: OptionNISDomainName}
+	require.NoError(t, o.FromBytes(data))
+	require.Equal(t, "nis", o.Name)
+	require.Error(t, o.FromBytes(append(data, data...)))
+	require.Error(t, o.FromBytes([]byte{3, 'n'}))
+}
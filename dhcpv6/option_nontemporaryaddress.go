@@ -105,6 +105,10 @@ func (op *OptIANA) LongString(indentSpace int) string {
 // FromBytes builds an OptIANA structure from a sequence of bytes.  The
 // input data does not include option code and length bytes.
 func (op *OptIANA) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptIANA) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 	buf.ReadBytes(op.IaId[:])
 
@@ -114,7 +118,7 @@ func (op *OptIANA) FromBytes(data []byte) error {
 	op.T1 = t1.Duration
 	op.T2 = t2.Duration
 
-	if err := op.Options.FromBytes(buf.ReadAll()); err != nil {
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceIANA)); err != nil {
 		return err
 	}
 	return buf.FinError()
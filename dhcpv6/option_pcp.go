@@ -0,0 +1,10 @@
+package dhcpv6
+
+import "net"
+
+// OptPCPServer returns a PCP Server option as defined by RFC 7291, Section 4:
+// the addresses of one Port Control Protocol server. Each PCP server is sent
+// in its own option.
+func OptPCPServer(ip ...net.IP) Option {
+	return &optAddressList{OptionCode: OptionV6PCPServer, Addrs: ip}
+}
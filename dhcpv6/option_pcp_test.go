@@ -0,0 +1,22 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptPCPServer(t *testing.T) {
+	first := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("::ffff:192.0.2.1")}
+	second := []net.IP{net.ParseIP("2001:db8::2")}
+	m, err := NewMessage(WithPCPServer(first...), WithPCPServer(second...))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, [][]net.IP{first, second}, got.Options.PCPServers())
+	require.Contains(t, got.LongString(0), "Port Control Protocol Server: [2001:db8::2]")
+
+	var empty MessageOptions
+	require.Nil(t, empty.PCPServers())
+}
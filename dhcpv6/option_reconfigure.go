@@ -0,0 +1,62 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/u-root/uio/uio"
+)
+
+// OptReconfigureMessage implements the Reconfigure Message option, as defined
+// by RFC 8415, Section 21.19. It tells the client which exchange to start.
+type OptReconfigureMessage struct {
+	// MessageType is MessageTypeRenew, MessageTypeRebind or
+	// MessageTypeInformationRequest.
+	MessageType MessageType
+}
+
+// Code returns the option code.
+func (op *OptReconfigureMessage) Code() OptionCode {
+	return OptionReconfMessage
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptReconfigureMessage) ToBytes() []byte {
+	return []byte{uint8(op.MessageType)}
+}
+
+func (op *OptReconfigureMessage) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.MessageType)
+}
+
+// FromBytes builds an OptReconfigureMessage structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func (op *OptReconfigureMessage) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.MessageType = MessageType(buf.Read8())
+	return buf.FinError()
+}
+
+// OptReconfigureAccept returns a Reconfigure Accept option, as defined by RFC
+// 8415, Section 21.20. Clients send it to tell they accept Reconfigure
+// messages, and servers to tell they may send them.
+func OptReconfigureAccept() Option {
+	return &optReconfigureAccept{}
+}
+
+type optReconfigureAccept struct{}
+
+func (*optReconfigureAccept) Code() OptionCode {
+	return OptionReconfAccept
+}
+
+func (*optReconfigureAccept) ToBytes() []byte {
+	return nil
+}
+
+func (op *optReconfigureAccept) String() string {
+	return op.Code().String()
+}
+
+func (*optReconfigureAccept) FromBytes(data []byte) error {
+	return uio.NewBigEndianBuffer(data).FinError()
+}
@@ -0,0 +1,37 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconfigureMessageParseAndGetter(t *testing.T) {
+	buf := []byte{
+		0, 19, // Reconfigure Message
+		0, 1, // length
+		5, // RENEW
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(buf))
+	require.Equal(t, MessageTypeRenew, mo.ReconfigureMessage())
+	require.Equal(t, buf, mo.ToBytes())
+	require.Equal(t, "Reconfig Message: RENEW", mo.GetOne(OptionReconfMessage).String())
+
+	require.Error(t, mo.FromBytes([]byte{0, 19, 0, 2, 5, 6}))
+	mo = MessageOptions{Options{&OptionGeneric{OptionCode: OptionReconfMessage}}}
+	require.Equal(t, MessageTypeNone, mo.ReconfigureMessage())
+}
+
+func TestReconfigureAccept(t *testing.T) {
+	m, err := NewMessage(WithReconfigureAccept)
+	require.NoError(t, err)
+	require.True(t, m.Options.ReconfigureAccept())
+	require.Equal(t, []byte{0, 20, 0, 0}, m.Options.ToBytes())
+
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes([]byte{0, 20, 0, 0}))
+	require.True(t, mo.ReconfigureAccept())
+	require.Equal(t, "Reconfig Accept", mo.GetOne(OptionReconfAccept).String())
+	require.Error(t, mo.FromBytes([]byte{0, 20, 0, 1, 0}))
+}
@@ -0,0 +1,166 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"sync"
+)
+
+// OptionSpace identifies the context an option is parsed in: the top level of
+// a message, the options encapsulated in another option, or the options of a
+// vendor.
+//
+// All spaces but the vendor ones share the option codes of RFC 8415, so a
+// constructor registered in OptionSpaceMessage is also used in the nested
+// spaces unless the nested space has its own constructor for the code.
+type OptionSpace struct {
+	name             string
+	enterpriseNumber uint32
+	vendor           bool
+}
+
+// Option spaces known to this package.
+var (
+	// OptionSpaceMessage contains the options of messages and relay
+	// messages.
+	OptionSpaceMessage = OptionSpace{name: "Message"}
+	// OptionSpaceIANA contains the options encapsulated in IA_NA.
+	OptionSpaceIANA = OptionSpace{name: "IA_NA"}
+	// OptionSpaceIATA contains the options encapsulated in IA_TA.
+	OptionSpaceIATA = OptionSpace{name: "IA_TA"}
+	// OptionSpaceIAAddress contains the options encapsulated in IA Address.
+	OptionSpaceIAAddress = OptionSpace{name: "IA Address"}
+	// OptionSpaceIAPD contains the options encapsulated in IA_PD.
+	OptionSpaceIAPD = OptionSpace{name: "IA_PD"}
+	// OptionSpaceIAPrefix contains the options encapsulated in IA Prefix.
+	OptionSpaceIAPrefix = OptionSpace{name: "IA Prefix"}
+	// OptionSpace4RD contains the options encapsulated in OPTION_4RD.
+	OptionSpace4RD = OptionSpace{name: "4RD"}
+	// OptionSpaceLQQuery contains the options encapsulated in
+	// OPTION_LQ_QUERY.
+	OptionSpaceLQQuery = OptionSpace{name: "LQ Query"}
+	// OptionSpaceClientData contains the options encapsulated in
+	// OPTION_CLIENT_DATA.
+	OptionSpaceClientData = OptionSpace{name: "Client Data"}
+	// OptionSpaceS46Container contains the options encapsulated in
+	// OPTION_S46_CONT_MAPE, OPTION_S46_CONT_MAPT and OPTION_S46_CONT_LW.
+	OptionSpaceS46Container = OptionSpace{name: "S46 Container"}
+	// OptionSpaceS46Rule contains the options encapsulated in
+	// OPTION_S46_RULE and OPTION_S46_V4V6BIND.
+	OptionSpaceS46Rule = OptionSpace{name: "S46 Rule"}
+)
+
+// VendorOptionSpace returns the option space of the Vendor-specific
+// Information option (RFC 8415, Section 21.17) for the given enterprise
+// number.
+//
+// Vendor option codes do not overlap with the codes of the other spaces:
+// options without a registered constructor are parsed as OptionGeneric.
+func VendorOptionSpace(enterpriseNumber uint32) OptionSpace {
+	return OptionSpace{name: "Vendor", enterpriseNumber: enterpriseNumber, vendor: true}
+}
+
+// String returns the name of the option space.
+func (s OptionSpace) String() string {
+	if s.vendor {
+		return fmt.Sprintf("%s (enterprise number %d)", s.name, s.enterpriseNumber)
+	}
+	return s.name
+}
+
+// OptionConstructor returns a new, empty option. The option's FromBytes
+// method is called with the option data after construction.
+type OptionConstructor func() Option
+
+// registryParser is implemented by options that contain other options, so
+// that the registry used to parse a message is also used for the options
+// nested in it.
+type registryParser interface {
+	fromBytesWithRegistry(data []byte, r *OptionRegistry) error
+}
+
+// OptionRegistry maps option codes to the constructors used to parse them.
+//
+// Lookups that find no constructor in a registry fall back to the global
+// registry (see RegisterOption), and then to the options built into this
+// package. An OptionRegistry is safe for concurrent use.
+type OptionRegistry struct {
+	mu     sync.RWMutex
+	parent *OptionRegistry
+	ctors  map[OptionSpace]map[OptionCode]OptionConstructor
+}
+
+// defaultOptionRegistry holds the options registered with RegisterOption. It
+// is used by FromBytes, ParseOption and all other parsing functions that do
+// not take a registry.
+var defaultOptionRegistry = &OptionRegistry{}
+
+// NewOptionRegistry returns an empty registry that falls back to the global
+// registry. Use it with FromBytesWithRegistry or Parser to customize the
+// parsing of a single message without affecting the rest of the program.
+func NewOptionRegistry() *OptionRegistry {
+	return &OptionRegistry{parent: defaultOptionRegistry}
+}
+
+// RegisterOption registers ctor globally as the constructor for code in the
+// given option space, overriding the built-in parser, if any.
+//
+// RegisterOption is typically called from an init function.
+func RegisterOption(space OptionSpace, code OptionCode, ctor OptionConstructor) {
+	defaultOptionRegistry.Register(space, code, ctor)
+}
+
+// Register registers ctor as the constructor for code in the given option
+// space. A nil ctor removes a previous registration.
+func (r *OptionRegistry) Register(space OptionSpace, code OptionCode, ctor OptionConstructor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ctor == nil {
+		delete(r.ctors[space], code)
+		return
+	}
+	if r.ctors == nil {
+		r.ctors = make(map[OptionSpace]map[OptionCode]OptionConstructor)
+	}
+	if r.ctors[space] == nil {
+		r.ctors[space] = make(map[OptionCode]OptionConstructor)
+	}
+	r.ctors[space][code] = ctor
+}
+
+func (r *OptionRegistry) lookup(space OptionSpace, code OptionCode) OptionConstructor {
+	r.mu.RLock()
+	ctor := r.ctors[space][code]
+	if ctor == nil && !space.vendor && space != OptionSpaceMessage {
+		ctor = r.ctors[OptionSpaceMessage][code]
+	}
+	r.mu.RUnlock()
+	if ctor == nil && r.parent != nil {
+		return r.parent.lookup(space, code)
+	}
+	return ctor
+}
+
+// ParseOption parses data as a single option of the given space.
+func (r *OptionRegistry) ParseOption(space OptionSpace, code OptionCode, data []byte) (Option, error) {
+	var opt Option
+	if ctor := r.lookup(space, code); ctor != nil {
+		opt = ctor()
+	} else if space.vendor {
+		opt = &OptionGeneric{OptionCode: code}
+	} else {
+		opt = newOption(code)
+	}
+	if rp, ok := opt.(registryParser); ok {
+		return opt, rp.fromBytesWithRegistry(data, r)
+	}
+	return opt, opt.FromBytes(data)
+}
+
+// Parser returns an OptionParser for the given option space, to be used with
+// Options.FromBytesWithParser. Options nested in the parsed options are
+// parsed with r as well.
+func (r *OptionRegistry) Parser(space OptionSpace) OptionParser {
+	return func(code OptionCode, data []byte) (Option, error) {
+		return r.ParseOption(space, code, data)
+	}
+}
@@ -0,0 +1,152 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const optionTestUnknown OptionCode = 65000
+
+// optTest is a custom option used to test the registry.
+type optTest struct {
+	code  OptionCode
+	Value string
+}
+
+func (op *optTest) Code() OptionCode         { return op.code }
+func (op *optTest) ToBytes() []byte          { return []byte(op.Value) }
+func (op *optTest) String() string           { return fmt.Sprintf("%s: %s", op.code, op.Value) }
+func (op *optTest) FromBytes(p []byte) error { op.Value = string(p); return nil }
+
+func newOptTest(code OptionCode) OptionConstructor {
+	return func() Option { return &optTest{code: code} }
+}
+
+func testRegistryMessage(t *testing.T) []byte {
+	iana := &OptIANA{IaId: [4]byte{1, 2, 3, 4}}
+	iana.Options.Add(&OptIAAddress{
+		IPv6Addr: net.ParseIP("2001:db8::1"),
+		Options:  AddressOptions{Options{&OptionGeneric{OptionCode: optionTestUnknown, OptionData: []byte("addr")}}},
+	})
+	iana.Options.Add(&OptionGeneric{OptionCode: optionTestUnknown, OptionData: []byte("iana")})
+	iapd := &OptIAPD{IaId: [4]byte{5, 6, 7, 8}}
+	iapd.Options.Add(&OptionGeneric{OptionCode: optionTestUnknown, OptionData: []byte("iapd")})
+	vendor := &OptVendorOpts{
+		EnterpriseNumber: 4242,
+		VendorOpts:       Options{&OptionGeneric{OptionCode: 1, OptionData: []byte("vendor")}},
+	}
+
+	m, err := NewMessage(
+		WithOption(iana),
+		WithOption(iapd),
+		WithOption(vendor),
+		WithOption(&OptionGeneric{OptionCode: optionTestUnknown, OptionData: []byte("top")}),
+	)
+	require.NoError(t, err)
+	relay, err := EncapsulateRelay(m, MessageTypeRelayForward, net.IPv6loopback, net.IPv6loopback)
+	require.NoError(t, err)
+	return relay.ToBytes()
+}
+
+func TestOptionRegistryNested(t *testing.T) {
+	r := NewOptionRegistry()
+	r.Register(OptionSpaceMessage, optionTestUnknown, newOptTest(optionTestUnknown))
+	r.Register(VendorOptionSpace(4242), 1, newOptTest(1))
+
+	d, err := FromBytesWithRegistry(testRegistryMessage(t), r)
+	require.NoError(t, err)
+	m, err := d.GetInnerMessage()
+	require.NoError(t, err)
+
+	require.Equal(t, &optTest{code: optionTestUnknown, Value: "top"}, m.GetOneOption(optionTestUnknown))
+
+	iana := m.Options.OneIANA()
+	require.NotNil(t, iana)
+	require.Equal(t, &optTest{code: optionTestUnknown, Value: "iana"}, iana.Options.GetOne(optionTestUnknown))
+	addr := iana.Options.OneAddress()
+	require.NotNil(t, addr)
+	require.Equal(t, &optTest{code: optionTestUnknown, Value: "addr"}, addr.Options.GetOne(optionTestUnknown))
+
+	iapd := m.Options.OneIAPD()
+	require.NotNil(t, iapd)
+	require.Equal(t, &optTest{code: optionTestUnknown, Value: "iapd"}, iapd.Options.GetOne(optionTestUnknown))
+
+	vendor := m.GetOneOption(OptionVendorOpts).(*OptVendorOpts)
+	require.Equal(t, &optTest{code: 1, Value: "vendor"}, vendor.VendorOpts.GetOne(1))
+
+	// The global registry is not affected.
+	d, err = FromBytes(testRegistryMessage(t))
+	require.NoError(t, err)
+	m, err = d.GetInnerMessage()
+	require.NoError(t, err)
+	require.IsType(t, &OptionGeneric{}, m.GetOneOption(optionTestUnknown))
+}
+
+func TestOptionRegistrySpaces(t *testing.T) {
+	r := NewOptionRegistry()
+	// Only registered for IA_PD: other spaces keep the built-in parser.
+	r.Register(OptionSpaceIAPD, optionTestUnknown, newOptTest(optionTestUnknown))
+	// Another enterprise number does not match.
+	r.Register(VendorOptionSpace(1), 1, newOptTest(1))
+
+	d, err := FromBytesWithRegistry(testRegistryMessage(t), r)
+	require.NoError(t, err)
+	m, err := d.GetInnerMessage()
+	require.NoError(t, err)
+
+	require.IsType(t, &OptionGeneric{}, m.GetOneOption(optionTestUnknown))
+	require.IsType(t, &OptionGeneric{}, m.Options.OneIANA().Options.GetOne(optionTestUnknown))
+	require.IsType(t, &optTest{}, m.Options.OneIAPD().Options.GetOne(optionTestUnknown))
+	vendor := m.GetOneOption(OptionVendorOpts).(*OptVendorOpts)
+	require.IsType(t, &OptionGeneric{}, vendor.VendorOpts.GetOne(1))
+
+	// Unregistering restores the built-in parser.
+	r.Register(OptionSpaceIAPD, optionTestUnknown, nil)
+	d, err = FromBytesWithRegistry(testRegistryMessage(t), r)
+	require.NoError(t, err)
+	m, err = d.GetInnerMessage()
+	require.NoError(t, err)
+	require.IsType(t, &OptionGeneric{}, m.Options.OneIAPD().Options.GetOne(optionTestUnknown))
+}
+
+func TestOptionRegistryOverrideBuiltin(t *testing.T) {
+	r := NewOptionRegistry()
+	r.Register(OptionSpaceMessage, OptionBootfileURL, func() Option {
+		return &OptionGeneric{OptionCode: OptionBootfileURL}
+	})
+
+	var opts Options
+	data := []byte{0, 59, 0, 3, 'u', 'r', 'l'}
+	require.NoError(t, opts.FromBytesWithParser(data, r.Parser(OptionSpaceMessage)))
+	require.Equal(t, Options{&OptionGeneric{OptionCode: OptionBootfileURL, OptionData: []byte("url")}}, opts)
+
+	opts = nil
+	require.NoError(t, opts.FromBytes(data))
+	require.IsType(t, &optBootFileURL{}, opts[0])
+}
+
+func TestRegisterOption(t *testing.T) {
+	RegisterOption(OptionSpaceMessage, optionTestUnknown, newOptTest(optionTestUnknown))
+	defer RegisterOption(OptionSpaceMessage, optionTestUnknown, nil)
+
+	opt, err := ParseOption(optionTestUnknown, []byte("global"))
+	require.NoError(t, err)
+	require.Equal(t, &optTest{code: optionTestUnknown, Value: "global"}, opt)
+
+	// Per-parse registries fall back to the global one, including in
+	// nested spaces.
+	d, err := FromBytesWithRegistry(testRegistryMessage(t), NewOptionRegistry())
+	require.NoError(t, err)
+	m, err := d.GetInnerMessage()
+	require.NoError(t, err)
+	require.IsType(t, &optTest{}, m.Options.OneIANA().Options.OneAddress().Options.GetOne(optionTestUnknown))
+}
+
+func TestOptionSpaceString(t *testing.T) {
+	require.Equal(t, "IA_NA", OptionSpaceIANA.String())
+	require.Equal(t, "Vendor (enterprise number 4242)", VendorOptionSpace(4242).String())
+	require.NotEqual(t, VendorOptionSpace(1), VendorOptionSpace(2))
+}
@@ -36,7 +36,11 @@ func (op *optRelayMsg) LongString(indent int) string {
 // FromBytes build an optRelayMsg structure from a sequence of bytes. The input
 // data does not include option code and length bytes.
 func (op *optRelayMsg) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *optRelayMsg) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	var err error
-	op.Msg, err = FromBytes(data)
+	op.Msg, err = FromBytesWithRegistry(data, r)
 	return err
 }
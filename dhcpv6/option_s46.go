@@ -0,0 +1,550 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/u-root/uio/uio"
+)
+
+// s46FMRMask is the mask of the F flag of OPTION_S46_RULE: the rule is a
+// Forwarding Mapping Rule.
+const s46FMRMask = 1 << 0
+
+// writeVariablePrefix6 writes the length of p, and the bytes of p its length
+// covers, as in the variable-length IPv6 prefixes of RFC 7598 and RFC 8115.
+func writeVariablePrefix6(buf *uio.Lexer, p net.IPNet) {
+	ones, _ := p.Mask.Size()
+	buf.Write8(uint8(ones))
+	ip := p.IP.To16()
+	if ip == nil {
+		ip = net.IPv6zero
+	}
+	buf.WriteBytes(ip[:(ones+7)/8])
+}
+
+// readVariablePrefix6 reads a variable-length IPv6 prefix written by
+// writeVariablePrefix6.
+func readVariablePrefix6(buf *uio.Lexer) (net.IPNet, error) {
+	ones := int(buf.Read8())
+	if ones > 128 {
+		return net.IPNet{}, fmt.Errorf("invalid IPv6 prefix length %d", ones)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, buf.Consume((ones+7)/8))
+	mask := net.CIDRMask(ones, 128)
+	return net.IPNet{IP: ip.Mask(mask), Mask: mask}, buf.Error()
+}
+
+// S46RuleOptions are the options encapsulated in the S46 Rule and S46
+// IPv4/IPv6 Address Binding options.
+type S46RuleOptions struct {
+	Options
+}
+
+// PortParams returns the port parameters of the rule or binding, if any.
+func (so S46RuleOptions) PortParams() *OptS46PortParams {
+	if opt, ok := so.Options.GetOne(OptionS46PortParams).(*OptS46PortParams); ok {
+		return opt
+	}
+	return nil
+}
+
+// OptS46Rule implements the S46 Rule option, as defined by RFC 7598, Section
+// 4.1: a MAP-E or MAP-T mapping rule.
+type OptS46Rule struct {
+	// FMR is whether the rule is also a Forwarding Mapping Rule.
+	FMR bool
+	// EABitsLength is the number of Embedded Address bits of the rule:
+	// the bits of the delegated prefix that follow Prefix6, and give the
+	// IPv4 address suffix and the PSID of a CE.
+	EABitsLength uint8
+	Prefix4      net.IPNet
+	Prefix6      net.IPNet
+	Options      S46RuleOptions
+}
+
+// Code returns the option code.
+func (op *OptS46Rule) Code() OptionCode {
+	return OptionS46Rule
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptS46Rule) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	var flags uint8
+	if op.FMR {
+		flags |= s46FMRMask
+	}
+	buf.Write8(flags)
+	buf.Write8(op.EABitsLength)
+	p4Len, _ := op.Prefix4.Mask.Size()
+	buf.Write8(uint8(p4Len))
+	if ip := op.Prefix4.IP.To4(); ip != nil {
+		buf.WriteBytes(ip)
+	} else {
+		buf.WriteBytes(net.IPv4zero.To4())
+	}
+	writeVariablePrefix6(buf, op.Prefix6)
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptS46Rule) String() string {
+	return fmt.Sprintf("%s: {FMR=%t EABitsLength=%d Prefix4=%s Prefix6=%s Options=%v}",
+		op.Code(), op.FMR, op.EABitsLength, op.Prefix4.String(), op.Prefix6.String(), op.Options)
+}
+
+// FromBytes builds an OptS46Rule structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *OptS46Rule) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptS46Rule) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.FMR = buf.Read8()&s46FMRMask != 0
+	op.EABitsLength = buf.Read8()
+	p4Len := int(buf.Read8())
+	if p4Len > 32 {
+		return fmt.Errorf("invalid IPv4 prefix length %d", p4Len)
+	}
+	op.Prefix4.Mask = net.CIDRMask(p4Len, 32)
+	op.Prefix4.IP = net.IP(buf.CopyN(net.IPv4len))
+	var err error
+	if op.Prefix6, err = readVariablePrefix6(buf); err != nil {
+		return err
+	}
+	return op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceS46Rule))
+}
+
+// OptS46BR returns an S46 Border Relay option, as defined by RFC 7598,
+// Section 4.2: the IPv6 address of a MAP-E or Lightweight 4over6 border
+// relay.
+func OptS46BR(addr net.IP) Option {
+	return &optS46BR{Addr: addr}
+}
+
+type optS46BR struct {
+	Addr net.IP
+}
+
+func (*optS46BR) Code() OptionCode {
+	return OptionS46BR
+}
+
+func (op *optS46BR) ToBytes() []byte {
+	return op.Addr.To16()
+}
+
+func (op *optS46BR) String() string {
+	return fmt.Sprintf("%s: %v", op.Code(), op.Addr)
+}
+
+// FromBytes builds an optS46BR structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optS46BR) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.Addr = net.IP(buf.CopyN(net.IPv6len))
+	return buf.FinError()
+}
+
+// OptS46DMR returns an S46 Default Mapping Rule option, as defined by RFC
+// 7598, Section 4.3: the IPv6 prefix MAP-T CEs map the IPv4 destinations
+// outside of the MAP domain to.
+func OptS46DMR(prefix net.IPNet) Option {
+	return &optS46DMR{Prefix: prefix}
+}
+
+type optS46DMR struct {
+	Prefix net.IPNet
+}
+
+func (*optS46DMR) Code() OptionCode {
+	return OptionS46DMR
+}
+
+func (op *optS46DMR) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	writeVariablePrefix6(buf, op.Prefix)
+	return buf.Data()
+}
+
+func (op *optS46DMR) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.Prefix.String())
+}
+
+// FromBytes builds an optS46DMR structure from a sequence of bytes. The input
+// data does not include option code and length bytes.
+func (op *optS46DMR) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	var err error
+	if op.Prefix, err = readVariablePrefix6(buf); err != nil {
+		return err
+	}
+	return buf.FinError()
+}
+
+// OptS46V4V6Bind implements the S46 IPv4/IPv6 Address Binding option, as
+// defined by RFC 7598, Section 4.4: the IPv4 address, and usually the port
+// set, of a Lightweight 4over6 CE.
+type OptS46V4V6Bind struct {
+	IPv4Addr net.IP
+	// BindPrefix6 is the IPv6 prefix the CE must use as the source of its
+	// softwire.
+	BindPrefix6 net.IPNet
+	Options     S46RuleOptions
+}
+
+// Code returns the option code.
+func (op *OptS46V4V6Bind) Code() OptionCode {
+	return OptionS46V4V6Bind
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptS46V4V6Bind) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	if ip := op.IPv4Addr.To4(); ip != nil {
+		buf.WriteBytes(ip)
+	} else {
+		buf.WriteBytes(net.IPv4zero.To4())
+	}
+	writeVariablePrefix6(buf, op.BindPrefix6)
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptS46V4V6Bind) String() string {
+	return fmt.Sprintf("%s: {IPv4Addr=%v BindPrefix6=%s Options=%v}",
+		op.Code(), op.IPv4Addr, op.BindPrefix6.String(), op.Options)
+}
+
+// FromBytes builds an OptS46V4V6Bind structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *OptS46V4V6Bind) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptS46V4V6Bind) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.IPv4Addr = net.IP(buf.CopyN(net.IPv4len))
+	var err error
+	if op.BindPrefix6, err = readVariablePrefix6(buf); err != nil {
+		return err
+	}
+	return op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceS46Rule))
+}
+
+// OptS46PortParams implements the S46 Port Parameters option, as defined by
+// RFC 7598, Section 4.5: the layout of the port sets of the CEs sharing an
+// IPv4 address, and the port set of one CE.
+type OptS46PortParams struct {
+	// Offset is the number of high bits of a port that precede the PSID,
+	// and select one of the ranges of a port set.
+	Offset uint8
+	// PSIDLength is the length of the PSID, in bits.
+	PSIDLength uint8
+	// PSID is the Port Set Identifier, right-aligned.
+	PSID uint16
+}
+
+// Code returns the option code.
+func (op *OptS46PortParams) Code() OptionCode {
+	return OptionS46PortParams
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptS46PortParams) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(op.Offset)
+	buf.Write8(op.PSIDLength)
+	// The PSID is left-aligned on the wire.
+	var psid uint16
+	if op.PSIDLength > 0 && op.PSIDLength <= 16 {
+		psid = op.PSID << (16 - op.PSIDLength)
+	}
+	buf.Write16(psid)
+	return buf.Data()
+}
+
+func (op *OptS46PortParams) String() string {
+	return fmt.Sprintf("%s: {Offset=%d PSIDLength=%d PSID=%#x}", op.Code(), op.Offset, op.PSIDLength, op.PSID)
+}
+
+// FromBytes builds an OptS46PortParams structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func (op *OptS46PortParams) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	op.Offset = buf.Read8()
+	op.PSIDLength = buf.Read8()
+	psid := buf.Read16()
+	if err := buf.FinError(); err != nil {
+		return err
+	}
+	if op.Offset > 15 || op.PSIDLength > 16 {
+		return fmt.Errorf("invalid port parameters: offset %d, PSID length %d", op.Offset, op.PSIDLength)
+	}
+	if op.PSIDLength > 0 {
+		op.PSID = psid >> (16 - op.PSIDLength)
+	}
+	return nil
+}
+
+// S46ContainerOptions are the options encapsulated in the S46 container
+// options.
+type S46ContainerOptions struct {
+	Options
+}
+
+// Rules returns the mapping rules of a MAP-E or MAP-T container.
+func (so S46ContainerOptions) Rules() []*OptS46Rule {
+	var rules []*OptS46Rule
+	for _, o := range so.Options.Get(OptionS46Rule) {
+		if r, ok := o.(*OptS46Rule); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// BRs returns the border relay addresses of a MAP-E or Lightweight 4over6
+// container.
+func (so S46ContainerOptions) BRs() []net.IP {
+	var addrs []net.IP
+	for _, o := range so.Options.Get(OptionS46BR) {
+		if br, ok := o.(*optS46BR); ok {
+			addrs = append(addrs, br.Addr)
+		}
+	}
+	return addrs
+}
+
+// DMR returns the default mapping rule prefix of a MAP-T container, if
+// present.
+func (so S46ContainerOptions) DMR() (net.IPNet, bool) {
+	if opt, ok := so.Options.GetOne(OptionS46DMR).(*optS46DMR); ok {
+		return opt.Prefix, true
+	}
+	return net.IPNet{}, false
+}
+
+// V4V6Bind returns the address binding of a Lightweight 4over6 container.
+func (so S46ContainerOptions) V4V6Bind() *OptS46V4V6Bind {
+	if opt, ok := so.Options.GetOne(OptionS46V4V6Bind).(*OptS46V4V6Bind); ok {
+		return opt
+	}
+	return nil
+}
+
+// BMR returns the Basic Mapping Rule of the CE with the delegated prefix, as
+// described in RFC 7597, Section 5: the rule with the longest IPv6 prefix
+// that contains it. It returns nil if there is none.
+func (so S46ContainerOptions) BMR(delegated net.IPNet) *OptS46Rule {
+	var (
+		best    *OptS46Rule
+		bestLen = -1
+	)
+	dLen, _ := delegated.Mask.Size()
+	for _, r := range so.Rules() {
+		rLen, _ := r.Prefix6.Mask.Size()
+		if rLen <= dLen && rLen > bestLen && r.Prefix6.Contains(delegated.IP) {
+			best, bestLen = r, rLen
+		}
+	}
+	return best
+}
+
+// OptS46Container implements the S46 MAP-E, MAP-T and Lightweight 4over6
+// container options, as defined by RFC 7598, Section 5.
+type OptS46Container struct {
+	// OptionCode is OptionS46ContMapE, OptionS46ContMapT or
+	// OptionS46ContLW.
+	OptionCode OptionCode
+	Options    S46ContainerOptions
+}
+
+// OptS46ContMapE returns an S46 MAP-E Container option with opts: S46 Rule,
+// S46 Border Relay and S46 Priority options.
+func OptS46ContMapE(opts ...Option) *OptS46Container {
+	return &OptS46Container{OptionCode: OptionS46ContMapE, Options: S46ContainerOptions{opts}}
+}
+
+// OptS46ContMapT returns an S46 MAP-T Container option with opts: S46 Rule
+// and S46 Default Mapping Rule options.
+func OptS46ContMapT(opts ...Option) *OptS46Container {
+	return &OptS46Container{OptionCode: OptionS46ContMapT, Options: S46ContainerOptions{opts}}
+}
+
+// OptS46ContLW returns an S46 Lightweight 4over6 Container option with opts:
+// S46 Border Relay and S46 IPv4/IPv6 Address Binding options.
+func OptS46ContLW(opts ...Option) *OptS46Container {
+	return &OptS46Container{OptionCode: OptionS46ContLW, Options: S46ContainerOptions{opts}}
+}
+
+// Code returns the option code.
+func (op *OptS46Container) Code() OptionCode {
+	return op.OptionCode
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptS46Container) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptS46Container) String() string {
+	return fmt.Sprintf("%s: {Options=%v}", op.Code(), op.Options)
+}
+
+// LongString returns a multi-line string representation of the option.
+func (op *OptS46Container) LongString(indent int) string {
+	return fmt.Sprintf("%s: {Options=%v}", op.Code(), op.Options.LongString(indent))
+}
+
+// FromBytes builds an OptS46Container structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *OptS46Container) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptS46Container) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
+	return op.Options.FromBytesWithParser(data, r.Parser(OptionSpaceS46Container))
+}
+
+// S46PortRange is a range of ports, bounds included.
+type S46PortRange struct {
+	First, Last uint16
+}
+
+// S46CE is the IPv4 configuration of a MAP or Lightweight 4over6 CE.
+type S46CE struct {
+	// IPv4Prefix is the IPv4 address of the CE, as a /32, or the IPv4
+	// prefix of the CE if its rule has fewer EA bits than IPv4 suffix
+	// bits.
+	IPv4Prefix net.IPNet
+	// IPv6Addr is the MAP IPv6 address of the CE, RFC 7597, Section 6. It
+	// is nil for Lightweight 4over6 CEs.
+	IPv6Addr net.IP
+	// Offset, PSIDLength and PSID give the port set of the CE, as in
+	// OptS46PortParams. The CE has all the ports if PSIDLength is 0.
+	Offset     uint8
+	PSIDLength uint8
+	PSID       uint16
+}
+
+// defaultS46Offset is the default PSID offset of MAP, RFC 7597, Section 5.1.
+const defaultS46Offset = 6
+
+// MapCE returns the configuration of the MAP CE with the delegated prefix,
+// for which op is the Basic Mapping Rule, as described in RFC 7597, Section
+// 5.2: the IPv4 address and PSID are given by the EA bits of the delegated
+// prefix. The PSID offset is the one of the rule's port parameters, if any,
+// and 6 otherwise.
+func (op *OptS46Rule) MapCE(delegated net.IPNet) (*S46CE, error) {
+	r6, _ := op.Prefix6.Mask.Size()
+	d6, _ := delegated.Mask.Size()
+	p4, _ := op.Prefix4.Mask.Size()
+	ea := int(op.EABitsLength)
+	if !op.Prefix6.Contains(delegated.IP) || d6 < r6 {
+		return nil, fmt.Errorf("delegated prefix %s is not in rule prefix %s", delegated.String(), op.Prefix6.String())
+	}
+	if ea > 48 || r6+ea > 64 || r6+ea > d6 {
+		return nil, fmt.Errorf("invalid EA bits length %d for rule prefix %s and delegated prefix %s",
+			ea, op.Prefix6.String(), delegated.String())
+	}
+	prefix4 := op.Prefix4.IP.To4()
+	if prefix4 == nil {
+		return nil, errors.New("rule has no IPv4 prefix")
+	}
+	ce := &S46CE{Offset: defaultS46Offset}
+	params := op.Options.PortParams()
+	if params != nil {
+		ce.Offset = params.Offset
+	}
+
+	// The EA bits of the delegated prefix.
+	eaBits := bitsAt(delegated.IP.To16(), r6, ea)
+	suffix := 32 - p4
+	v4 := binary.BigEndian.Uint32(prefix4.Mask(op.Prefix4.Mask))
+	switch {
+	case ea >= suffix:
+		ce.PSIDLength = uint8(ea - suffix)
+		ce.PSID = uint16(eaBits & (1<<ce.PSIDLength - 1))
+		if suffix > 0 {
+			v4 |= uint32(eaBits >> ce.PSIDLength)
+		}
+		ce.IPv4Prefix.Mask = net.CIDRMask(32, 32)
+	default:
+		v4 |= uint32(eaBits) << (suffix - ea)
+		ce.IPv4Prefix.Mask = net.CIDRMask(p4+ea, 32)
+	}
+	if ea == 0 && params != nil {
+		ce.PSIDLength, ce.PSID = params.PSIDLength, params.PSID
+	}
+	if int(ce.Offset)+int(ce.PSIDLength) > 16 {
+		return nil, fmt.Errorf("invalid port set: offset %d, PSID length %d", ce.Offset, ce.PSIDLength)
+	}
+	ce.IPv4Prefix.IP = make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ce.IPv4Prefix.IP, v4)
+
+	// The end-user IPv6 prefix, subnet ID 0, and the interface ID.
+	ce.IPv6Addr = delegated.IP.To16().Mask(net.CIDRMask(r6+ea, 128))
+	copy(ce.IPv6Addr[10:14], ce.IPv4Prefix.IP)
+	binary.BigEndian.PutUint16(ce.IPv6Addr[14:], ce.PSID)
+	return ce, nil
+}
+
+// CE returns the configuration of the Lightweight 4over6 CE the binding is
+// for, as described in RFC 7596, Section 5.1.
+func (op *OptS46V4V6Bind) CE() *S46CE {
+	ce := &S46CE{IPv4Prefix: net.IPNet{IP: op.IPv4Addr.To4(), Mask: net.CIDRMask(32, 32)}}
+	if params := op.Options.PortParams(); params != nil {
+		ce.Offset, ce.PSIDLength, ce.PSID = params.Offset, params.PSIDLength, params.PSID
+	}
+	return ce
+}
+
+// PortRanges returns the port set of the CE, as described in RFC 7597,
+// Section 5.1. Ports whose Offset high bits are all zero, including the
+// well-known ports, are excluded if Offset is not 0.
+func (ce *S46CE) PortRanges() []S46PortRange {
+	if ce.PSIDLength == 0 {
+		return []S46PortRange{{First: 0, Last: 0xffff}}
+	}
+	a, k := uint(ce.Offset), uint(ce.PSIDLength)
+	if a+k > 16 {
+		return nil
+	}
+	m := 16 - a - k
+	size := uint32(1) << m
+	base := uint32(ce.PSID) << m
+	if a == 0 {
+		return []S46PortRange{{First: uint16(base), Last: uint16(base + size - 1)}}
+	}
+	var ranges []S46PortRange
+	for i := uint32(1); i < 1<<a; i++ {
+		first := i<<(16-a) | base
+		ranges = append(ranges, S46PortRange{First: uint16(first), Last: uint16(first + size - 1)})
+	}
+	return ranges
+}
+
+// Contains returns whether port is in the port set of the CE.
+func (ce *S46CE) Contains(port uint16) bool {
+	for _, r := range ce.PortRanges() {
+		if port >= r.First && port <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// bitsAt returns the n bits of b starting at bit offset start, n <= 64.
+func bitsAt(b []byte, start, n int) uint64 {
+	var v uint64
+	for i := start; i < start+n; i++ {
+		v = v<<1 | uint64(b[i/8]>>(7-uint(i%8))&1)
+	}
+	return v
+}
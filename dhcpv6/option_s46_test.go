@@ -0,0 +1,136 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustCIDR(t *testing.T, s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return *n
+}
+
+func TestOptS46ContMapT(t *testing.T) {
+	buf := []byte{
+		0, 95, // S46 MAP-T container
+		0, 38, // length
+		0, 89, // S46 Rule
+		0, 21, // length
+		1,            // flags: FMR
+		16,           // ea-len
+		24,           // prefix4-len
+		192, 0, 2, 0, // ipv4-prefix
+		40,                        // prefix6-len
+		0x20, 0x01, 0x0d, 0xb8, 0, // ipv6-prefix
+		0, 93, // S46 Port Parameters
+		0, 4, // length
+		6,    // offset
+		0,    // PSID-len
+		0, 0, // PSID
+		0, 91, // S46 DMR
+		0, 9, // length
+		64, // prefix6-len
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0x64,
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(buf))
+	c := mo.S46ContMapT()
+	require.NotNil(t, c)
+	require.Nil(t, mo.S46ContMapE())
+
+	rules := c.Options.Rules()
+	require.Len(t, rules, 1)
+	require.True(t, rules[0].FMR)
+	require.Equal(t, uint8(16), rules[0].EABitsLength)
+	require.Equal(t, "192.0.2.0/24", rules[0].Prefix4.String())
+	require.Equal(t, "2001:db8::/40", rules[0].Prefix6.String())
+	require.Equal(t, &OptS46PortParams{Offset: 6}, rules[0].Options.PortParams())
+	dmr, ok := c.Options.DMR()
+	require.True(t, ok)
+	require.Equal(t, "2001:db8:0:64::/64", dmr.String())
+	require.Equal(t, buf, mo.ToBytes())
+
+	built := OptS46ContMapT(
+		&OptS46Rule{
+			FMR:          true,
+			EABitsLength: 16,
+			Prefix4:      mustCIDR(t, "192.0.2.0/24"),
+			Prefix6:      mustCIDR(t, "2001:db8::/40"),
+			Options:      S46RuleOptions{Options{&OptS46PortParams{Offset: 6}}},
+		},
+		OptS46DMR(mustCIDR(t, "2001:db8:0:64::/64")),
+	)
+	require.Equal(t, buf[4:], built.ToBytes())
+}
+
+func TestOptS46ContLW(t *testing.T) {
+	bind := &OptS46V4V6Bind{
+		IPv4Addr:    net.IPv4(192, 0, 2, 1),
+		BindPrefix6: mustCIDR(t, "2001:db8:1::/48"),
+		Options:     S46RuleOptions{Options{&OptS46PortParams{Offset: 6, PSIDLength: 4, PSID: 0xa}}},
+	}
+	lw := OptS46ContLW(OptS46BR(net.ParseIP("2001:db8::1")), bind)
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(Options{lw}.ToBytes()))
+	got := mo.S46ContLW()
+	require.NotNil(t, got)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, got.Options.BRs())
+	b := got.Options.V4V6Bind()
+	require.NotNil(t, b)
+	require.True(t, b.IPv4Addr.Equal(net.IPv4(192, 0, 2, 1)))
+	require.Equal(t, "2001:db8:1::/48", b.BindPrefix6.String())
+	// The PSID is left-aligned on the wire.
+	require.Equal(t, []byte{6, 4, 0xa0, 0}, b.Options.PortParams().ToBytes())
+	require.Equal(t, uint16(0xa), b.Options.PortParams().PSID)
+
+	ce := b.CE()
+	require.Equal(t, "192.0.2.1/32", ce.IPv4Prefix.String())
+	ranges := ce.PortRanges()
+	require.Len(t, ranges, 63)
+	// m = 16 - 6 - 4 = 6 bits of ports per range.
+	require.Equal(t, S46PortRange{First: 1<<10 | 0xa<<6, Last: 1<<10 | 0xa<<6 | 63}, ranges[0])
+	require.True(t, ce.Contains(1<<10|0xa<<6))
+	require.False(t, ce.Contains(1<<10|0xb<<6))
+	require.False(t, ce.Contains(0xa<<6))
+}
+
+func TestOptS46RuleMapCE(t *testing.T) {
+	// RFC 7597, Appendix A, Example 1.
+	rule := &OptS46Rule{
+		EABitsLength: 16,
+		Prefix4:      mustCIDR(t, "192.0.2.0/24"),
+		Prefix6:      mustCIDR(t, "2001:db8::/40"),
+		Options:      S46RuleOptions{Options{&OptS46PortParams{Offset: 6}}},
+	}
+	cont := OptS46ContMapT(rule)
+	delegated := mustCIDR(t, "2001:db8:12:3400::/56")
+	require.Equal(t, rule, cont.Options.BMR(delegated))
+	require.Nil(t, cont.Options.BMR(mustCIDR(t, "2001:db9::/56")))
+
+	ce, err := rule.MapCE(delegated)
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.18/32", ce.IPv4Prefix.String())
+	require.Equal(t, uint8(8), ce.PSIDLength)
+	require.Equal(t, uint16(0x34), ce.PSID)
+	require.Equal(t, net.ParseIP("2001:db8:12:3400:0:c000:212:34"), ce.IPv6Addr)
+	ranges := ce.PortRanges()
+	require.Len(t, ranges, 63)
+	require.Equal(t, S46PortRange{First: 1232, Last: 1235}, ranges[0])
+	require.Equal(t, S46PortRange{First: 64720, Last: 64723}, ranges[62])
+
+	// Fewer EA bits than IPv4 suffix bits: the CE gets an IPv4 prefix.
+	rule.EABitsLength = 4
+	ce, err = rule.MapCE(mustCIDR(t, "2001:db8:10::/44"))
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.16/28", ce.IPv4Prefix.String())
+	require.Equal(t, []S46PortRange{{First: 0, Last: 0xffff}}, ce.PortRanges())
+
+	_, err = rule.MapCE(mustCIDR(t, "2001:db9::/56"))
+	require.Error(t, err)
+	rule.EABitsLength = 30
+	_, err = rule.MapCE(delegated)
+	require.Error(t, err)
+}
@@ -0,0 +1,44 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+)
+
+// OptSIPServersDomainNameList returns a SIP Servers Domain Name List option
+// as defined by RFC 3319, Section 3.1.
+func OptSIPServersDomainNameList(labels *rfc1035label.Labels) Option {
+	return &optSIPServersDomainNameList{DomainNameList: labels}
+}
+
+type optSIPServersDomainNameList struct {
+	DomainNameList *rfc1035label.Labels
+}
+
+func (*optSIPServersDomainNameList) Code() OptionCode {
+	return OptionSIPServersDomainNameList
+}
+
+func (op *optSIPServersDomainNameList) ToBytes() []byte {
+	return op.DomainNameList.ToBytes()
+}
+
+func (op *optSIPServersDomainNameList) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.DomainNameList)
+}
+
+// FromBytes builds an optSIPServersDomainNameList structure from a sequence
+// of bytes. The input data does not include option code and length bytes.
+func (op *optSIPServersDomainNameList) FromBytes(data []byte) error {
+	var err error
+	op.DomainNameList, err = rfc1035label.FromBytes(data)
+	return err
+}
+
+// OptSIPServers returns a SIP Servers IPv6 Address List option as defined by
+// RFC 3319, Section 3.2.
+func OptSIPServers(ip ...net.IP) Option {
+	return &optAddressList{OptionCode: OptionSIPServersIPv6AddressList, Addrs: ip}
+}
@@ -0,0 +1,69 @@
+package dhcpv6
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/u-root/uio/uio"
+)
+
+func TestOptSIPServersDomainNameList(t *testing.T) {
+	data := []byte{
+		0, 21, // SIP Servers Domain Name List
+		0, 17, // length
+		3, 's', 'i', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'n', 'e', 't', 0,
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(data))
+	require.Equal(t, []string{"sip.example.net"}, mo.SIPServersDomainNameList().Labels)
+	require.Equal(t, data, mo.ToBytes())
+
+	m, err := NewMessage(WithSIPServersDomainNameList("sip.example.net", "sip.example.org"))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{"sip.example.net", "sip.example.org"}, got.Options.SIPServersDomainNameList().Labels)
+	require.Contains(t, got.LongString(0), "SIP Servers Domain Name List: [sip.example.net sip.example.org]")
+}
+
+func TestOptSIPServers(t *testing.T) {
+	data := []byte{
+		0, 22, // SIP Servers IPv6 Address List
+		0, 32, // length
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(data))
+	want := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}
+	require.Equal(t, want, mo.SIPServers())
+	require.Equal(t, data, mo.ToBytes())
+
+	m, err := NewMessage(WithSIPServers(want...))
+	require.NoError(t, err)
+	require.Equal(t, want, m.Options.SIPServers())
+	require.Contains(t, m.LongString(0), "SIP Servers IPv6 Address List: [2001:db8::1 2001:db8::2]")
+
+	require.Error(t, mo.FromBytes(data[:20]))
+	require.Error(t, mo.FromBytes([]byte{0, 22, 0, 3, 1, 2, 3}))
+}
+
+func TestOptAddressListLength(t *testing.T) {
+	for _, code := range []OptionCode{
+		OptionDNSRecursiveNameServer,
+		OptionSIPServersIPv6AddressList,
+		OptionNISServers,
+		OptionNISPServers,
+		OptionV6PCPServer,
+	} {
+		// One address and 4 trailing bytes.
+		data := append([]byte{0, byte(code), 0, 20}, make([]byte, 20)...)
+		var mo MessageOptions
+		err := mo.FromBytes(data)
+		require.True(t, errors.Is(err, uio.ErrUnreadBytes), "%s: got %v", code, err)
+		data = append([]byte{0, byte(code), 0, 16}, make([]byte, 16)...)
+		require.NoError(t, mo.FromBytes(data), code)
+	}
+}
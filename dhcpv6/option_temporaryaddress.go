@@ -41,10 +41,14 @@ func (op *OptIATA) LongString(indentSpace int) string {
 // FromBytes builds an OptIATA structure from a sequence of bytes.  The input
 // data does not include option code and length bytes.
 func (op *OptIATA) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptIATA) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 	buf.ReadBytes(op.IaId[:])
 
-	if err := op.Options.FromBytes(buf.ReadAll()); err != nil {
+	if err := op.Options.FromBytesWithParser(buf.ReadAll(), r.Parser(OptionSpaceIATA)); err != nil {
 		return err
 	}
 	return buf.FinError()
@@ -0,0 +1,40 @@
+package dhcpv6
+
+import "fmt"
+
+// OptPOSIXTimezone returns a New POSIX Timezone option as defined by RFC 4833,
+// Section 3: a TZ environment variable string, e.g.
+// "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00".
+func OptPOSIXTimezone(tz string) Option {
+	return &optTimezone{OptionCode: OptionNewPOSIXTimezone, Timezone: tz}
+}
+
+// OptTZDBTimezone returns a New TZDB Timezone option as defined by RFC 4833,
+// Section 3: the name of a tz database entry, e.g. "Europe/Zurich".
+func OptTZDBTimezone(tz string) Option {
+	return &optTimezone{OptionCode: OptionNewTZDBTimezone, Timezone: tz}
+}
+
+type optTimezone struct {
+	OptionCode OptionCode
+	Timezone   string
+}
+
+func (op *optTimezone) Code() OptionCode {
+	return op.OptionCode
+}
+
+func (op *optTimezone) ToBytes() []byte {
+	return []byte(op.Timezone)
+}
+
+func (op *optTimezone) String() string {
+	return fmt.Sprintf("%s: %s", op.Code(), op.Timezone)
+}
+
+// FromBytes builds an optTimezone structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *optTimezone) FromBytes(data []byte) error {
+	op.Timezone = string(data)
+	return nil
+}
@@ -0,0 +1,33 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptTimezone(t *testing.T) {
+	data := []byte{
+		0, 41, // New POSIX Timezone
+		0, 7, // length
+		'C', 'E', 'T', '-', '1', 'C', 'E',
+		0, 42, // New TZDB Timezone
+		0, 13, // length
+		'E', 'u', 'r', 'o', 'p', 'e', '/', 'Z', 'u', 'r', 'i', 'c', 'h',
+	}
+	var mo MessageOptions
+	require.NoError(t, mo.FromBytes(data))
+	require.Equal(t, "CET-1CE", mo.POSIXTimezone())
+	require.Equal(t, "Europe/Zurich", mo.TZDBTimezone())
+	require.Equal(t, data, mo.ToBytes())
+
+	m, err := NewMessage(WithPOSIXTimezone("EST5EDT4,M3.2.0/02:00,M11.1.0/02:00"), WithTZDBTimezone("America/New_York"))
+	require.NoError(t, err)
+	require.Equal(t, "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00", m.Options.POSIXTimezone())
+	require.Equal(t, "America/New_York", m.Options.TZDBTimezone())
+	require.Contains(t, m.LongString(0), "America/New_York")
+
+	var empty MessageOptions
+	require.Empty(t, empty.POSIXTimezone())
+	require.Empty(t, empty.TZDBTimezone())
+}
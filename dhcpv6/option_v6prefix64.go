@@ -0,0 +1,57 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/uio/uio"
+)
+
+// OptV6Prefix64 implements the V6 Prefix64 option, as defined by RFC 8115:
+// the IPv4-embedded IPv6 prefixes used to deliver IPv4 multicast over IPv6,
+// and to synthesize the IPv6 addresses of IPv4 multicast sources.
+//
+// Prefixes with a zero length are absent.
+type OptV6Prefix64 struct {
+	// ASMPrefix64 is the multicast prefix of Any-Source Multicast
+	// groups.
+	ASMPrefix64 net.IPNet
+	// SSMPrefix64 is the multicast prefix of Source-Specific Multicast
+	// groups.
+	SSMPrefix64 net.IPNet
+	// UnicastPrefix64 is the unicast prefix IPv4 multicast sources are
+	// mapped to.
+	UnicastPrefix64 net.IPNet
+}
+
+// Code returns the option code.
+func (op *OptV6Prefix64) Code() OptionCode {
+	return OptionV6Prefix64
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes.
+func (op *OptV6Prefix64) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	writeVariablePrefix6(buf, op.ASMPrefix64)
+	writeVariablePrefix6(buf, op.SSMPrefix64)
+	writeVariablePrefix6(buf, op.UnicastPrefix64)
+	return buf.Data()
+}
+
+func (op *OptV6Prefix64) String() string {
+	return fmt.Sprintf("%s: {ASMPrefix64=%s SSMPrefix64=%s UnicastPrefix64=%s}",
+		op.Code(), op.ASMPrefix64.String(), op.SSMPrefix64.String(), op.UnicastPrefix64.String())
+}
+
+// FromBytes builds an OptV6Prefix64 structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func (op *OptV6Prefix64) FromBytes(data []byte) error {
+	buf := uio.NewBigEndianBuffer(data)
+	for _, p := range []*net.IPNet{&op.ASMPrefix64, &op.SSMPrefix64, &op.UnicastPrefix64} {
+		var err error
+		if *p, err = readVariablePrefix6(buf); err != nil {
+			return err
+		}
+	}
+	return buf.FinError()
+}
@@ -0,0 +1,35 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptV6Prefix64(t *testing.T) {
+	data := []byte{
+		96, // asm-length
+		0xff, 0x3e, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0,  // ssm-length
+		64, // unicast-length
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0,
+	}
+	var opt OptV6Prefix64
+	require.NoError(t, opt.FromBytes(data))
+	require.Equal(t, "ff3e::/96", opt.ASMPrefix64.String())
+	ones, bits := opt.SSMPrefix64.Mask.Size()
+	require.Equal(t, 0, ones)
+	require.Equal(t, 128, bits)
+	require.Equal(t, "2001:db8::/64", opt.UnicastPrefix64.String())
+	require.Equal(t, data, opt.ToBytes())
+
+	m, err := NewMessage(WithV6Prefix64(opt.ASMPrefix64, net.IPNet{}, opt.UnicastPrefix64))
+	require.NoError(t, err)
+	got, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, &opt, got.Options.V6Prefix64())
+
+	require.Error(t, opt.FromBytes(data[:5]))
+	require.Error(t, opt.FromBytes([]byte{129}))
+}
@@ -40,19 +40,19 @@ func (op *OptVendorOpts) LongString(indent int) string {
 
 // FromBytes builds an OptVendorOpts structure from a sequence of bytes. The
 // input data does not include option code and length bytes.
+//
+// The vendor options are parsed in VendorOptionSpace(EnterpriseNumber), so
+// they are OptionGeneric unless a constructor was registered for the
+// enterprise number: vendor codes overlap with the RFC standard codes.
 func (op *OptVendorOpts) FromBytes(data []byte) error {
+	return op.fromBytesWithRegistry(data, defaultOptionRegistry)
+}
+
+func (op *OptVendorOpts) fromBytesWithRegistry(data []byte, r *OptionRegistry) error {
 	buf := uio.NewBigEndianBuffer(data)
 	op.EnterpriseNumber = buf.Read32()
-	if err := op.VendorOpts.FromBytesWithParser(buf.ReadAll(), vendParseOption); err != nil {
+	if err := op.VendorOpts.FromBytesWithParser(buf.ReadAll(), r.Parser(VendorOptionSpace(op.EnterpriseNumber))); err != nil {
 		return err
 	}
 	return buf.FinError()
 }
-
-// vendParseOption builds a GenericOption from a slice of bytes
-// We cannot use the existing ParseOption function in options.go because the
-// sub-options include codes specific to each vendor. There are overlaps in these
-// codes with RFC standard codes.
-func vendParseOption(code OptionCode, data []byte) (Option, error) {
-	return &OptionGeneric{OptionCode: code, OptionData: data}, nil
-}
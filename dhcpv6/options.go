@@ -45,7 +45,15 @@ func (og *OptionGeneric) FromBytes(p []byte) error {
 //
 // Parse a sequence of bytes as a single DHCPv6 option.
 // Returns the option structure, or an error if any.
+//
+// Options registered with RegisterOption take precedence over the built-in
+// ones.
 func ParseOption(code OptionCode, optData []byte) (Option, error) {
+	return defaultOptionRegistry.ParseOption(OptionSpaceMessage, code, optData)
+}
+
+// newOption returns an empty built-in option for the given code.
+func newOption(code OptionCode) Option {
 	var opt Option
 	switch code {
 	case OptionClientID:
@@ -74,8 +82,6 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt = &OptVendorOpts{}
 	case OptionInterfaceID:
 		opt = &optInterfaceID{}
-	case OptionDNSRecursiveNameServer:
-		opt = &optDNS{}
 	case OptionDomainSearchList:
 		opt = &optDomainSearchList{}
 	case OptionIAPD:
@@ -106,6 +112,36 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt = &OptDHCPv4Msg{}
 	case OptionDHCP4oDHCP6Server:
 		opt = &OptDHCP4oDHCP6Server{}
+	case OptionSIPServersDomainNameList:
+		opt = &optSIPServersDomainNameList{}
+	case OptionDNSRecursiveNameServer, OptionSIPServersIPv6AddressList, OptionNISServers, OptionNISPServers, OptionV6PCPServer:
+		opt = &optAddressList{OptionCode: code}
+	case OptionNISDomainName, OptionNISPDomainName:
+		opt = &optDomainName{OptionCode: code}
+	case OptionNewPOSIXTimezone, OptionNewTZDBTimezone:
+		opt = &optTimezone{OptionCode: code}
+	case OptionSolMaxRT, OptionInfMaxRT:
+		opt = &optMaxRT{OptionCode: code}
+	case OptionAFTRName:
+		opt = &optAFTRName{}
+	case OptionV6DNR:
+		opt = &OptDNR{}
+	case OptionCaptivePortal:
+		opt = &optCaptivePortal{}
+	case OptionV6Prefix64:
+		opt = &OptV6Prefix64{}
+	case OptionS46Rule:
+		opt = &OptS46Rule{}
+	case OptionS46BR:
+		opt = &optS46BR{}
+	case OptionS46DMR:
+		opt = &optS46DMR{}
+	case OptionS46V4V6Bind:
+		opt = &OptS46V4V6Bind{}
+	case OptionS46PortParams:
+		opt = &OptS46PortParams{}
+	case OptionS46ContMapE, OptionS46ContMapT, OptionS46ContLW:
+		opt = &OptS46Container{OptionCode: code}
 	case Option4RD:
 		opt = &Opt4RD{}
 	case Option4RDMapRule:
@@ -114,10 +150,30 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt = &Opt4RDNonMapRule{}
 	case OptionRelayPort:
 		opt = &optRelayPort{}
+	case OptionAuth:
+		opt = &OptAuth{}
+	case OptionReconfMessage:
+		opt = &OptReconfigureMessage{}
+	case OptionReconfAccept:
+		opt = &optReconfigureAccept{}
+	case OptionLQQuery:
+		opt = &OptLQQuery{}
+	case OptionClientData:
+		opt = &OptClientData{}
+	case OptionCLTTime:
+		opt = &optCLTTime{}
+	case OptionLQRelayData:
+		opt = &OptLQRelayData{}
+	case OptionRelayID:
+		opt = &optRelayID{}
+	case OptionLQClientLink:
+		opt = &optLQClientLink{}
+	case OptionLQBaseTime, OptionLQStartTime, OptionLQEndTime:
+		opt = &optLQTime{code: code}
 	default:
 		opt = &OptionGeneric{OptionCode: code}
 	}
-	return opt, opt.FromBytes(optData)
+	return opt
 }
 
 type longStringer interface {
@@ -0,0 +1,269 @@
+package dhcpv6
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/internal/replay"
+)
+
+// Errors returned when authenticating Reconfigure messages.
+var (
+	ErrAuthMissing  = errors.New("dhcpv6: message is not authenticated")
+	ErrAuthProtocol = errors.New("dhcpv6: unsupported authentication protocol")
+	ErrAuthInvalid  = errors.New("dhcpv6: invalid authentication")
+)
+
+// Types of the authentication information of the reconfigure key protocol,
+// RFC 8415 Section 20.4.1.
+const (
+	// ReconfigureKeyValue is the type of the information carrying the
+	// key, sent by the server in a Reply.
+	ReconfigureKeyValue uint8 = 1
+	// ReconfigureKeyHMACMD5 is the type of the information carrying the
+	// HMAC-MD5 digest of a Reconfigure message.
+	ReconfigureKeyHMACMD5 uint8 = 2
+)
+
+// ReconfigureKeyLen is the length of reconfigure keys.
+const ReconfigureKeyLen = 16
+
+// ReplayCounter generates replay detection values with the monotonic
+// method. Values are NTP timestamps, so that they keep increasing across
+// restarts, as suggested by RFC 8415 Section 20.3. The zero value is ready to
+// use.
+type ReplayCounter struct {
+	c replay.Counter
+}
+
+// Next returns a value greater than any value previously returned.
+func (c *ReplayCounter) Next() uint64 {
+	return c.c.Next()
+}
+
+// NewReconfigureKey returns a new random reconfigure key.
+func NewReconfigureKey() ([]byte, error) {
+	key := make([]byte, ReconfigureKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// OptReconfigureKey returns an Authentication option that gives the
+// reconfigure key to the client, as described in RFC 8415 Section 20.4.2.
+func OptReconfigureKey(key []byte, replay uint64) *OptAuth {
+	return &OptAuth{
+		Protocol:        AuthProtocolReconfigureKey,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: replay,
+		AuthInfo:        append([]byte{ReconfigureKeyValue}, key...),
+	}
+}
+
+// ReconfigureKey returns the reconfigure key of the Authentication option, or
+// nil if the option does not carry a reconfigure key.
+func (mo MessageOptions) ReconfigureKey() []byte {
+	auth := mo.Auth()
+	if auth == nil || auth.Protocol != AuthProtocolReconfigureKey ||
+		auth.Algorithm != AuthAlgorithmHMACMD5 || auth.RDM != AuthRDMMonotonic ||
+		len(auth.AuthInfo) != 1+ReconfigureKeyLen || auth.AuthInfo[0] != ReconfigureKeyValue {
+		return nil
+	}
+	return auth.AuthInfo[1:]
+}
+
+// NewReconfigure creates a new RECONFIGURE message, asking the client to
+// start an exchange of the given type: MessageTypeRenew, MessageTypeRebind or
+// MessageTypeInformationRequest.
+//
+// The message must then be signed with SignReconfigure.
+func NewReconfigure(serverID, clientID DUID, msgType MessageType, modifiers ...Modifier) (*Message, error) {
+	if serverID == nil || clientID == nil {
+		return nil, errors.New("server and client IDs cannot be nil when building RECONFIGURE")
+	}
+	if !isReconfigureMessageType(msgType) {
+		return nil, fmt.Errorf("cannot reconfigure with message type %s", msgType)
+	}
+	// RFC 8415 Section 16.11: the transaction ID is 0.
+	m := &Message{MessageType: MessageTypeReconfigure}
+	m.AddOption(OptServerID(serverID))
+	m.AddOption(OptClientID(clientID))
+	m.AddOption(&OptReconfigureMessage{MessageType: msgType})
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+func isReconfigureMessageType(t MessageType) bool {
+	switch t {
+	case MessageTypeRenew, MessageTypeRebind, MessageTypeInformationRequest:
+		return true
+	}
+	return false
+}
+
+// SignReconfigure adds an Authentication option with the HMAC-MD5 of m,
+// computed with the reconfigure key, as described in RFC 8415 Section
+// 20.4.3. It must be the last change made to m.
+func SignReconfigure(m *Message, key []byte, replay uint64) error {
+	auth := &OptAuth{
+		Protocol:        AuthProtocolReconfigureKey,
+		Algorithm:       AuthAlgorithmHMACMD5,
+		RDM:             AuthRDMMonotonic,
+		ReplayDetection: replay,
+		AuthInfo:        make([]byte, 1+md5.Size),
+	}
+	auth.AuthInfo[0] = ReconfigureKeyHMACMD5
+	m.UpdateOption(auth)
+	mac, err := reconfigureMAC(m.ToBytes(), key)
+	if err != nil {
+		return err
+	}
+	copy(auth.AuthInfo[1:], mac)
+	return nil
+}
+
+// VerifyReconfigure parses a RECONFIGURE message as received on the wire and
+// checks its HMAC-MD5 with the reconfigure key. Replay detection is left to
+// the caller, which knows the last value received from the server.
+func VerifyReconfigure(msg []byte, key []byte) (*Message, error) {
+	m, err := MessageFromBytes(msg)
+	if err != nil {
+		return nil, err
+	}
+	if m.MessageType != MessageTypeReconfigure {
+		return nil, fmt.Errorf("not a RECONFIGURE message: %s", m.MessageType)
+	}
+	auth := m.Options.Auth()
+	if auth == nil {
+		return nil, ErrAuthMissing
+	}
+	if auth.Protocol != AuthProtocolReconfigureKey || auth.Algorithm != AuthAlgorithmHMACMD5 || auth.RDM != AuthRDMMonotonic {
+		return nil, fmt.Errorf("%w: %s", ErrAuthProtocol, auth)
+	}
+	if len(auth.AuthInfo) != 1+md5.Size || auth.AuthInfo[0] != ReconfigureKeyHMACMD5 {
+		return nil, ErrAuthInvalid
+	}
+	mac, err := reconfigureMAC(msg, key)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, auth.AuthInfo[1:]) {
+		return nil, ErrAuthInvalid
+	}
+	if !isReconfigureMessageType(m.Options.ReconfigureMessage()) {
+		return nil, errors.New("RECONFIGURE without a valid Reconfigure Message option")
+	}
+	return m, nil
+}
+
+// reconfigureMAC computes the HMAC-MD5 of msg with the digest of the
+// Authentication option set to zero.
+func reconfigureMAC(msg []byte, key []byte) ([]byte, error) {
+	b := append([]byte(nil), msg...)
+	// Message type and transaction ID.
+	const optionsOffset = 4
+	// Protocol, algorithm, RDM, replay detection, type and digest.
+	const authLen = 3 + 8 + 1 + md5.Size
+	for i := optionsOffset; i+4 <= len(b); {
+		code := OptionCode(uint16(b[i])<<8 | uint16(b[i+1]))
+		length := int(b[i+2])<<8 | int(b[i+3])
+		if i+4+length > len(b) {
+			return nil, ErrAuthInvalid
+		}
+		if code == OptionAuth {
+			if length != authLen {
+				return nil, ErrAuthInvalid
+			}
+			digest := b[i+4+length-md5.Size : i+4+length]
+			for j := range digest {
+				digest[j] = 0
+			}
+			h := hmac.New(md5.New, key)
+			h.Write(b)
+			return h.Sum(nil), nil
+		}
+		i += 4 + length
+	}
+	return nil, ErrAuthMissing
+}
+
+// NewRenewFromReply creates a new RENEW message to extend the lifetimes of
+// the leases of a REPLY, as described in RFC 8415 Section 18.2.4.
+func NewRenewFromReply(reply *Message, modifiers ...Modifier) (*Message, error) {
+	return newFromReply(reply, MessageTypeRenew, true, modifiers...)
+}
+
+// NewRebindFromReply creates a new REBIND message to extend the lifetimes of
+// the leases of a REPLY with any server, as described in RFC 8415 Section
+// 18.2.5.
+func NewRebindFromReply(reply *Message, modifiers ...Modifier) (*Message, error) {
+	return newFromReply(reply, MessageTypeRebind, false, modifiers...)
+}
+
+func newFromReply(reply *Message, msgType MessageType, withServerID bool, modifiers ...Modifier) (*Message, error) {
+	if reply == nil {
+		return nil, errors.New("REPLY cannot be nil")
+	}
+	if reply.MessageType != MessageTypeReply {
+		return nil, fmt.Errorf("The passed REPLY must have REPLY type set")
+	}
+	m, err := NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = msgType
+	cid := reply.GetOneOption(OptionClientID)
+	if cid == nil {
+		return nil, fmt.Errorf("Client ID cannot be nil in REPLY when building %s", msgType)
+	}
+	m.AddOption(cid)
+	if withServerID {
+		sid := reply.GetOneOption(OptionServerID)
+		if sid == nil {
+			return nil, fmt.Errorf("Server ID cannot be nil in REPLY when building %s", msgType)
+		}
+		m.AddOption(sid)
+	}
+	m.AddOption(OptElapsedTime(0))
+	for _, iana := range reply.Options.IANA() {
+		m.AddOption(iana)
+	}
+	for _, iapd := range reply.Options.IAPD() {
+		m.AddOption(iapd)
+	}
+	m.AddOption(OptRequestedOption(
+		OptionDNSRecursiveNameServer,
+		OptionDomainSearchList,
+	))
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+// NewInformationRequest creates a new INFORMATION-REQUEST message, to get
+// configuration parameters without addresses, as described in RFC 8415
+// Section 18.2.6.
+func NewInformationRequest(modifiers ...Modifier) (*Message, error) {
+	m, err := NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = MessageTypeInformationRequest
+	m.AddOption(OptElapsedTime(0))
+	m.AddOption(OptRequestedOption(
+		OptionDNSRecursiveNameServer,
+		OptionDomainSearchList,
+	))
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
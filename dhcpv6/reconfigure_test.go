@@ -0,0 +1,120 @@
+package dhcpv6
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testServerID = &DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+	testClientID = &DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 2}}
+)
+
+func TestReplayCounter(t *testing.T) {
+	var c ReplayCounter
+	last := c.Next()
+	for i := 0; i < 100; i++ {
+		v := c.Next()
+		require.Greater(t, v, last)
+		last = v
+	}
+}
+
+func TestReconfigureKey(t *testing.T) {
+	key, err := NewReconfigureKey()
+	require.NoError(t, err)
+	require.Len(t, key, ReconfigureKeyLen)
+
+	reply, err := NewMessage(WithOption(OptReconfigureKey(key, 5)))
+	require.NoError(t, err)
+	require.Equal(t, key, reply.Options.ReconfigureKey())
+	require.Equal(t, uint64(5), reply.Options.Auth().ReplayDetection)
+
+	reply.UpdateOption(&OptAuth{Protocol: AuthProtocolDelayed, Algorithm: AuthAlgorithmHMACMD5, AuthInfo: key})
+	require.Nil(t, reply.Options.ReconfigureKey())
+}
+
+func TestReconfigure(t *testing.T) {
+	key := []byte("0123456789abcdef")
+
+	_, err := NewReconfigure(testServerID, testClientID, MessageTypeRequest)
+	require.Error(t, err)
+	_, err = NewReconfigure(nil, testClientID, MessageTypeRenew)
+	require.Error(t, err)
+
+	m, err := NewReconfigure(testServerID, testClientID, MessageTypeRebind)
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeReconfigure, m.MessageType)
+	require.Equal(t, TransactionID{}, m.TransactionID)
+	require.True(t, testServerID.Equal(m.Options.ServerID()))
+	require.True(t, testClientID.Equal(m.Options.ClientID()))
+	require.Equal(t, MessageTypeRebind, m.Options.ReconfigureMessage())
+
+	_, err = VerifyReconfigure(m.ToBytes(), key)
+	require.Equal(t, ErrAuthMissing, err)
+
+	require.NoError(t, SignReconfigure(m, key, 10))
+	auth := m.Options.Auth()
+	require.Equal(t, AuthProtocolReconfigureKey, auth.Protocol)
+	require.Equal(t, ReconfigureKeyHMACMD5, auth.AuthInfo[0])
+	got, err := VerifyReconfigure(m.ToBytes(), key)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), got.Options.Auth().ReplayDetection)
+
+	_, err = VerifyReconfigure(m.ToBytes(), []byte("fedcba9876543210"))
+	require.Equal(t, ErrAuthInvalid, err)
+
+	b := m.ToBytes()
+	b[len(b)-1] ^= 1
+	_, err = VerifyReconfigure(b, key)
+	require.Equal(t, ErrAuthInvalid, err)
+
+	m.UpdateOption(&OptAuth{Protocol: AuthProtocolDelayed, Algorithm: AuthAlgorithmHMACMD5})
+	_, err = VerifyReconfigure(m.ToBytes(), key)
+	require.True(t, errors.Is(err, ErrAuthProtocol))
+
+	reply, err := NewMessage()
+	require.NoError(t, err)
+	_, err = VerifyReconfigure(reply.ToBytes(), key)
+	require.Error(t, err)
+}
+
+func TestNewFromReply(t *testing.T) {
+	reply := &Message{MessageType: MessageTypeReply}
+	reply.AddOption(OptClientID(testClientID))
+	reply.AddOption(OptServerID(testServerID))
+	reply.AddOption(&OptIANA{IaId: [4]byte{1, 2, 3, 4}})
+
+	renew, err := NewRenewFromReply(reply)
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeRenew, renew.MessageType)
+	require.True(t, testClientID.Equal(renew.Options.ClientID()))
+	require.True(t, testServerID.Equal(renew.Options.ServerID()))
+	require.Equal(t, [4]byte{1, 2, 3, 4}, renew.Options.OneIANA().IaId)
+
+	rebind, err := NewRebindFromReply(reply, WithRapidCommit)
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeRebind, rebind.MessageType)
+	require.Nil(t, rebind.Options.ServerID())
+	require.NotNil(t, rebind.GetOneOption(OptionRapidCommit))
+
+	_, err = NewRenewFromReply(nil)
+	require.Error(t, err)
+	_, err = NewRenewFromReply(renew)
+	require.Error(t, err)
+	reply.Options.Del(OptionServerID)
+	_, err = NewRenewFromReply(reply)
+	require.Error(t, err)
+	_, err = NewRebindFromReply(reply)
+	require.NoError(t, err)
+
+	inf, err := NewInformationRequest(WithClientID(testClientID))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeInformationRequest, inf.MessageType)
+	require.True(t, testClientID.Equal(inf.Options.ClientID()))
+	require.True(t, inf.IsOptionRequested(OptionDNSRecursiveNameServer))
+}
@@ -0,0 +1,485 @@
+package server6
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// activeQueueLen is the number of changes queued for a requestor. Requestors
+// that fall further behind are disconnected.
+const activeQueueLen = 256
+
+// DefaultActiveHistory is the default ActiveLeasequery.History.
+const DefaultActiveHistory = 24 * time.Hour
+
+// ActiveLeasequery streams the changes of the bindings of a LeaseStore to
+// requestors, as described in RFC 7653. It is a LeaseStore itself, wrapping
+// Store: the changes made through it, typically by a LeaseRecorder, are sent
+// to the requestors connected with Serve.
+//
+// Requestors may ask for the changes since a given time, to catch up after a
+// disconnection. Bindings that expire are not sent: requestors expire them
+// from the lifetimes of the last update.
+type ActiveLeasequery struct {
+	Store LeaseStore
+	// ServerID is the server identifier of the replies.
+	ServerID dhcpv6.DUID
+	// TLSConfig, if not nil, is used to secure the connections of the
+	// requestors that ask for it with a STARTTLS message. Such requests
+	// are refused otherwise.
+	TLSConfig *tls.Config
+	// Allow, if not nil, returns whether to answer the query m sent by
+	// peer. Other queries are refused with the NotAllowed status.
+	Allow func(peer net.Addr, m *dhcpv6.Message) bool
+	// Logger, if not nil, logs failed connections.
+	Logger Printfer
+	// History is how long deleted bindings are remembered for the
+	// requestors that catch up. Queries that start earlier are answered
+	// with the DataMissing status. If zero, DefaultActiveHistory is used.
+	History time.Duration
+
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu sync.Mutex
+	// since is the time from which all the changes of the bindings are
+	// known.
+	since time.Time
+	// deleted holds the leases deleted from Store since then, by address
+	// or prefix, with zero lifetimes and the time of deletion as update
+	// time.
+	deleted map[string]*Lease
+	// deletions holds the same leases, and those deleted again or stored
+	// since, in order of deletion.
+	deletions []*Lease
+	subs      map[*activeSub]struct{}
+	closed    bool
+}
+
+// NewActiveLeasequery returns an ActiveLeasequery that wraps store.
+func NewActiveLeasequery(store LeaseStore, serverID dhcpv6.DUID) *ActiveLeasequery {
+	return &ActiveLeasequery{
+		Store:    store,
+		ServerID: serverID,
+		since:    time.Now(),
+		deleted:  make(map[string]*Lease),
+		subs:     make(map[*activeSub]struct{}),
+	}
+}
+
+func (a *ActiveLeasequery) time() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+func (a *ActiveLeasequery) printf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+	}
+}
+
+// Get implements LeaseStore.Get.
+func (a *ActiveLeasequery) Get(ip net.IP) (*Lease, error) {
+	return a.Store.Get(ip)
+}
+
+// All implements LeaseStore.All.
+func (a *ActiveLeasequery) All() ([]*Lease, error) {
+	return a.Store.All()
+}
+
+// Put implements LeaseStore.Put, and sends the lease to the requestors.
+func (a *ActiveLeasequery) Put(l *Lease) error {
+	// The lock is held across the update of Store, so that the changes are
+	// sent in the order they are made.
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.Store.Put(l); err != nil {
+		return err
+	}
+	delete(a.deleted, l.key())
+	a.publish(l)
+	return nil
+}
+
+// Delete implements LeaseStore.Delete, and sends the binding with zero
+// lifetimes to the requestors.
+func (a *ActiveLeasequery) Delete(l *Lease) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old, err := a.Store.Get(leaseIP(l))
+	if errors.Is(err, ErrNoLease) {
+		return a.Store.Delete(l)
+	} else if err != nil {
+		return err
+	}
+	if err := a.Store.Delete(l); err != nil {
+		return err
+	}
+	if old.key() != l.key() {
+		return nil
+	}
+	old.Updated = a.time()
+	old.PreferredLifetime, old.ValidLifetime = 0, 0
+	a.forget(old.Updated)
+	a.deleted[old.key()] = old
+	a.deletions = append(a.deletions, old)
+	a.publish(old)
+	return nil
+}
+
+// forget drops the deleted bindings older than the history, and moves since
+// forward accordingly. It must be called with a.mu held.
+func (a *ActiveLeasequery) forget(now time.Time) {
+	history := a.History
+	if history == 0 {
+		history = DefaultActiveHistory
+	}
+	cutoff := now.Add(-history)
+	for len(a.deletions) > 0 && a.deletions[0].Updated.Before(cutoff) {
+		l := a.deletions[0]
+		a.deletions[0] = nil
+		a.deletions = a.deletions[1:]
+		if a.deleted[l.key()] == l {
+			delete(a.deleted, l.key())
+		}
+		if a.since.Before(cutoff) {
+			a.since = cutoff
+		}
+	}
+}
+
+// publish queues l for the requestors. It must be called with a.mu held.
+func (a *ActiveLeasequery) publish(l *Lease) {
+	for sub := range a.subs {
+		if !sub.match(l) {
+			continue
+		}
+		select {
+		case sub.updates <- l.Clone():
+		default:
+			sub.end(iana.StatusDataMissing)
+		}
+	}
+}
+
+// Close ends the active leasequeries, with the QueryTerminated status, and
+// refuses new ones. It does not close the listeners given to Serve.
+func (a *ActiveLeasequery) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	for sub := range a.subs {
+		sub.end(iana.StatusQueryTerminated)
+	}
+}
+
+// activeSub is an active leasequery.
+type activeSub struct {
+	match   func(*Lease) bool
+	updates chan *Lease
+	// done is closed, after status is set, when the query must end.
+	done   chan struct{}
+	status iana.StatusCode
+}
+
+// end ends the query with the given status. It must be called with the
+// ActiveLeasequery mutex held.
+func (s *activeSub) end(status iana.StatusCode) {
+	select {
+	case <-s.done:
+	default:
+		s.status = status
+		close(s.done)
+	}
+}
+
+func (a *ActiveLeasequery) subscribe(match func(*Lease) bool) (*activeSub, time.Time, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil, time.Time{}, false
+	}
+	a.forget(a.time())
+	sub := &activeSub{match: match, updates: make(chan *Lease, activeQueueLen), done: make(chan struct{})}
+	a.subs[sub] = struct{}{}
+	return sub, a.since, true
+}
+
+func (a *ActiveLeasequery) unsubscribe(sub *activeSub) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.subs, sub)
+}
+
+// Serve accepts active leasequery connections on l, and serves each of them
+// with ServeConn, until l fails. Servers listen on TCP port 547.
+func (a *ActiveLeasequery) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.ServeConn(conn)
+	}
+}
+
+// ServeConn serves an active leasequery connection, as described in RFC 7653,
+// Section 8, and closes it.
+//
+// The requestor may first secure the connection with a STARTTLS message. Its
+// ACTIVELEASEQUERY message is answered with a LEASEQUERY-REPLY message, and,
+// if it has a query start time, with a LEASEQUERY-DATA message for each
+// binding that changed since, followed by a LEASEQUERY-DATA message with the
+// CatchUpComplete status. The changes are then sent as they happen, in
+// LEASEQUERY-DATA messages, until the requestor closes the connection or the
+// query ends with a LEASEQUERY-DONE message.
+func (a *ActiveLeasequery) ServeConn(conn net.Conn) {
+	defer func() { conn.Close() }()
+	peer := conn.RemoteAddr()
+	secured := false
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				a.printf("active leasequery: cannot read from %s: %v", peer, err)
+			}
+			return
+		}
+		switch t := m.MessageType; {
+		case t == dhcpv6.MessageTypeStartTLS && !secured:
+			c, err := a.startTLS(conn, m)
+			if err != nil {
+				a.printf("active leasequery: cannot start TLS with %s: %v", peer, err)
+				return
+			}
+			conn, secured = c, a.TLSConfig != nil
+		case t == dhcpv6.MessageTypeActiveLeaseQuery:
+			if err := a.active(conn, peer, m); err != nil {
+				a.printf("active leasequery: cannot reply to %s: %v", peer, err)
+			}
+			return
+		default:
+			a.printf("active leasequery: ignoring %s from %s", t, peer)
+		}
+	}
+}
+
+// statusReply returns the LEASEQUERY-REPLY message answering m with status.
+func (a *ActiveLeasequery) statusReply(m *dhcpv6.Message, code iana.StatusCode, message string) (*dhcpv6.Message, error) {
+	return dhcpv6.NewLeasequeryReply(m, dhcpv6.WithServerID(a.ServerID),
+		dhcpv6.WithOption(&dhcpv6.OptStatusCode{StatusCode: code, StatusMessage: message}))
+}
+
+// startTLS answers the STARTTLS message m, and returns the connection to use
+// from then on.
+func (a *ActiveLeasequery) startTLS(conn net.Conn, m *dhcpv6.Message) (net.Conn, error) {
+	if a.TLSConfig == nil {
+		reply, err := a.statusReply(m, iana.StatusTLSConnectionRefused, "")
+		if err != nil {
+			return nil, err
+		}
+		return conn, dhcpv6.WriteTCPMessage(conn, reply)
+	}
+	reply := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeStartTLS, TransactionID: m.TransactionID}
+	reply.AddOption(dhcpv6.OptServerID(a.ServerID))
+	if err := dhcpv6.WriteTCPMessage(conn, reply); err != nil {
+		return nil, err
+	}
+	tc := tls.Server(conn, a.TLSConfig)
+	if err := tc.Handshake(); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// active answers the ACTIVELEASEQUERY message m.
+func (a *ActiveLeasequery) active(conn net.Conn, peer net.Addr, m *dhcpv6.Message) error {
+	var mu sync.Mutex
+	send := func(reply *dhcpv6.Message) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return dhcpv6.WriteTCPMessage(conn, reply)
+	}
+	refuse := func(q *dhcpv6.Message, code iana.StatusCode, message string) error {
+		reply, err := a.statusReply(q, code, message)
+		if err != nil {
+			return err
+		}
+		return send(reply)
+	}
+	if a.Allow != nil && !a.Allow(peer, m) {
+		a.printf("active leasequery: refusing query from %s", peer)
+		return refuse(m, iana.StatusNotAllowed, "")
+	}
+	lq := m.Options.LQQuery()
+	if lq == nil {
+		return refuse(m, iana.StatusMalformedQuery, "missing LQ Query option")
+	}
+	match, status := activeFilter(lq)
+	if status != nil {
+		return refuse(m, status.StatusCode, status.StatusMessage)
+	}
+	sub, since, ok := a.subscribe(match)
+	if !ok {
+		return refuse(m, iana.StatusQueryTerminated, "")
+	}
+	defer a.unsubscribe(sub)
+
+	start, hasStart := lq.Options.StartTime()
+	// RFC 7653, Section 8.3.
+	if hasStart && start.Before(since.Truncate(time.Second)) {
+		return refuse(m, iana.StatusDataMissing, fmt.Sprintf("no data before %s", since.UTC().Format(time.RFC3339)))
+	}
+	now := a.time()
+	reply, err := dhcpv6.NewLeasequeryReply(m, dhcpv6.WithServerID(a.ServerID), dhcpv6.WithOption(dhcpv6.OptLQBaseTime(now)))
+	if err != nil {
+		return err
+	}
+	if err := send(reply); err != nil {
+		return err
+	}
+
+	// The connection is only read to refuse other queries, and to find
+	// out when the requestor closes it.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			q, err := dhcpv6.ReadTCPMessage(conn)
+			if err != nil {
+				return
+			}
+			if q.MessageType == dhcpv6.MessageTypeActiveLeaseQuery || q.MessageType == dhcpv6.MessageTypeLeaseQuery {
+				_ = refuse(q, iana.StatusNotAllowed, "connection has an active query")
+			}
+		}
+	}()
+
+	data := func(l *Lease, now time.Time) *dhcpv6.Message {
+		d := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeLeaseQueryData, TransactionID: m.TransactionID}
+		d.AddOption(dhcpv6.OptLQBaseTime(now))
+		if l != nil {
+			d.AddOption(clientData([]*Lease{l}, now))
+		}
+		return d
+	}
+	if hasStart {
+		changes, err := a.changesSince(start, match)
+		if err != nil {
+			return a.done(send, m, iana.StatusUnspecFail, err.Error())
+		}
+		for _, l := range changes {
+			if err := send(data(l, now)); err != nil {
+				return err
+			}
+		}
+		d := data(nil, now)
+		d.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusCatchUpComplete})
+		if err := send(d); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case l := <-sub.updates:
+			if err := send(data(l, a.time())); err != nil {
+				return err
+			}
+		case <-sub.done:
+			return a.done(send, m, sub.status, "")
+		case <-gone:
+			return nil
+		}
+	}
+}
+
+// done sends the LEASEQUERY-DONE message that ends the replies to m.
+func (a *ActiveLeasequery) done(send func(*dhcpv6.Message) error, m *dhcpv6.Message, code iana.StatusCode, message string) error {
+	d := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeLeaseQueryDone, TransactionID: m.TransactionID}
+	d.AddOption(dhcpv6.OptServerID(a.ServerID))
+	d.AddOption(&dhcpv6.OptStatusCode{StatusCode: code, StatusMessage: message})
+	return send(d)
+}
+
+// changesSince returns the bindings that changed since start, and match,
+// ordered by time of change.
+func (a *ActiveLeasequery) changesSince(start time.Time, match func(*Lease) bool) ([]*Lease, error) {
+	all, err := a.Store.All()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	for _, l := range a.deleted {
+		all = append(all, l.Clone())
+	}
+	a.mu.Unlock()
+	var leases []*Lease
+	for _, l := range all {
+		if !l.Updated.Before(start) && match(l) {
+			leases = append(leases, l)
+		}
+	}
+	sort.SliceStable(leases, func(i, j int) bool {
+		ti, tj := leases[i].Updated, leases[j].Updated
+		if ti.Equal(tj) {
+			return bytes.Compare(leaseIP(leases[i]), leaseIP(leases[j])) < 0
+		}
+		return ti.Before(tj)
+	})
+	return leases, nil
+}
+
+// activeFilter returns whether bindings match the LQ Query option of an
+// active leasequery, RFC 7653, Section 8.1: the query may be restricted to a
+// relay identifier, a remote ID or a link address. Queries by link address
+// with the unspecified address are about all links. If the query is
+// invalid, the status to reply with is returned instead.
+func activeFilter(lq *dhcpv6.OptLQQuery) (func(*Lease) bool, *dhcpv6.OptStatusCode) {
+	var match func(*Lease) bool
+	switch lq.QueryType {
+	case dhcpv6.LQQueryByRelayID:
+		duid := lq.Options.RelayID()
+		if duid == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Relay ID option"}
+		}
+		match = func(l *Lease) bool {
+			return relayHas(l.Relay, func(ro dhcpv6.RelayOptions) bool {
+				id := ro.RelayID()
+				return id != nil && id.Equal(duid)
+			})
+		}
+	case dhcpv6.LQQueryByRemoteID:
+		rid := lq.Options.RemoteID()
+		if rid == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Remote ID option"}
+		}
+		match = func(l *Lease) bool {
+			return relayHas(l.Relay, func(ro dhcpv6.RelayOptions) bool {
+				o := ro.RemoteID()
+				return o != nil && o.EnterpriseNumber == rid.EnterpriseNumber && bytes.Equal(o.RemoteID, rid.RemoteID)
+			})
+		}
+	case dhcpv6.LQQueryByLinkAddress:
+		match = func(*Lease) bool { return true }
+	default:
+		return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusUnknownQueryType}
+	}
+	if lq.LinkAddress == nil || lq.LinkAddress.IsUnspecified() {
+		return match, nil
+	}
+	return func(l *Lease) bool { return l.LinkAddr.Equal(lq.LinkAddress) && match(l) }, nil
+}
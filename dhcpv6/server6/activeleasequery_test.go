@@ -0,0 +1,281 @@
+package server6
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+// testTLSConfigs returns the TLS configurations of a server with a
+// self-signed certificate, and of a client trusting it.
+func testTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dhcp.example.com"},
+		DNSNames:     []string{"dhcp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool, ServerName: "dhcp.example.com"}
+	return server, client
+}
+
+// newTestActiveLeasequery returns an ActiveLeasequery configured by setup, if
+// not nil, and serving on a TCP listener, and the address of the listener.
+func newTestActiveLeasequery(t *testing.T, setup func(*ActiveLeasequery)) (*ActiveLeasequery, string) {
+	a := NewActiveLeasequery(NewMemoryLeaseStore(), testServerID)
+	if setup != nil {
+		setup(a)
+	}
+	// Catch-up starts from whole seconds.
+	a.since = a.since.Add(-time.Second)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() { _ = a.Serve(l) }()
+	return a, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) net.Conn {
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// runRequestor runs r on conn with query, until the returned function is
+// called. The function closes conn, and returns the error of Run.
+func runRequestor(t *testing.T, r *nclient6.ActiveRequestor, conn net.Conn, query *dhcpv6.OptLQQuery) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- r.Run(ctx, conn, query) }()
+	return func() error {
+		cancel()
+		err := <-errc
+		conn.Close()
+		return err
+	}
+}
+
+// waitQueries waits until a has n active queries.
+func waitQueries(t *testing.T, a *ActiveLeasequery, n int) {
+	require.Eventually(t, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return len(a.subs) == n
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func bound(addr net.IP, duid dhcpv6.DUID, now time.Time) *Lease {
+	return &Lease{ClientID: duid, Addr: addr, PreferredLifetime: 30 * time.Minute, ValidLifetime: time.Hour, Updated: now, LinkAddr: testLink}
+}
+
+func TestActiveLeasequery(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, nil)
+	ip1, ip2 := net.ParseIP("2001:db8::10"), net.ParseIP("2001:db8::11")
+	require.NoError(t, a.Put(bound(ip1, testClient1, time.Now())))
+
+	// The requestor loads the bindings with a bulk leasequery.
+	r := nclient6.NewActiveRequestor(testRelayID1)
+	client, server := net.Pipe()
+	defer client.Close()
+	go NewLeasequery(a, a.ServerID).ServeConn(server)
+	query, err := dhcpv6.NewLeasequery(&dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByLinkAddress, LinkAddress: testLink},
+		dhcpv6.WithClientID(testRelayID1))
+	require.NoError(t, err)
+	require.NoError(t, nclient6.BulkLeasequery(context.Background(), client, query, r.Update))
+	require.NotNil(t, r.Get(ip1))
+	require.False(t, r.LastUpdate().IsZero())
+
+	stop := runRequestor(t, r, dial(t, addr), nil)
+	require.Eventually(t, r.Synced, 5*time.Second, 10*time.Millisecond)
+	p := &Lease{ClientID: testClient2, Prefix: testPrefix, ValidLifetime: time.Hour, Updated: time.Now()}
+	require.NoError(t, a.Put(bound(ip2, testClient2, time.Now())))
+	require.NoError(t, a.Put(p))
+	require.Eventually(t, func() bool { return r.Get(net.ParseIP("2001:db8:1::1")) != nil }, 5*time.Second, 10*time.Millisecond)
+	require.True(t, testClient2.Equal(r.Get(ip2).ClientID))
+	require.Len(t, r.Bindings(), 3)
+
+	require.NoError(t, a.Delete(&Lease{Addr: ip1}))
+	require.Eventually(t, func() bool { return r.Get(ip1) == nil }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, context.Canceled, stop())
+
+	// The changes made while disconnected are caught up.
+	require.NoError(t, a.Delete(&Lease{Addr: ip2}))
+	require.NoError(t, a.Put(bound(ip1, testClient1, time.Now())))
+	stop = runRequestor(t, r, dial(t, addr), nil)
+	require.Eventually(t, func() bool {
+		return r.Synced() && r.Get(ip1) != nil && r.Get(ip2) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, r.Bindings(), 2)
+	require.Equal(t, context.Canceled, stop())
+	waitQueries(t, a, 0)
+
+	// Other queries on an active connection are refused.
+	conn := dial(t, addr)
+	lq := &dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByLinkAddress, LinkAddress: net.IPv6unspecified}
+	var queries []*dhcpv6.Message
+	for i := 0; i < 2; i++ {
+		query, err := dhcpv6.NewActiveLeasequery(lq, dhcpv6.WithClientID(testRelayID1))
+		require.NoError(t, err)
+		require.NoError(t, dhcpv6.WriteTCPMessage(conn, query))
+		queries = append(queries, query)
+	}
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		require.NoError(t, err)
+		if m.TransactionID == queries[1].TransactionID {
+			require.Equal(t, dhcpv6.MessageTypeLeaseQueryReply, m.MessageType)
+			require.Equal(t, iana.StatusNotAllowed, m.Options.Status().StatusCode)
+			break
+		}
+	}
+
+	// Queries are ended when the server closes.
+	r = nclient6.NewActiveRequestor(testRelayID1)
+	errc := make(chan error, 1)
+	go func() { errc <- r.Run(context.Background(), dial(t, addr), nil) }()
+	waitQueries(t, a, 2)
+	a.Close()
+	var status *nclient6.ErrLeasequeryStatus
+	err = <-errc
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusQueryTerminated, status.Status.StatusCode)
+}
+
+func TestActiveLeasequeryDataMissing(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, nil)
+	r := nclient6.NewActiveRequestor(testRelayID1)
+	old := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeLeaseQueryReply}
+	old.AddOption(dhcpv6.OptLQBaseTime(time.Now().Add(-time.Hour)))
+	require.NoError(t, r.Update(old))
+
+	err := r.Run(context.Background(), dial(t, addr), nil)
+	var status *nclient6.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusDataMissing, status.Status.StatusCode)
+
+	r.Reset()
+	require.True(t, r.LastUpdate().IsZero())
+	require.NoError(t, a.Put(bound(net.ParseIP("2001:db8::10"), testClient1, time.Now())))
+	// Without a previous update, only the new changes are sent.
+	stop := runRequestor(t, r, dial(t, addr), nil)
+	waitQueries(t, a, 1)
+	require.Eventually(t, r.Synced, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, a.Put(bound(net.ParseIP("2001:db8::11"), testClient1, time.Now())))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Nil(t, r.Get(net.ParseIP("2001:db8::10")))
+	require.Equal(t, context.Canceled, stop())
+}
+
+func TestActiveLeasequeryHistory(t *testing.T) {
+	a := NewActiveLeasequery(NewMemoryLeaseStore(), testServerID)
+	a.History = time.Hour
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	ip1, ip2 := net.ParseIP("2001:db8::10"), net.ParseIP("2001:db8::11")
+	for _, ip := range []net.IP{ip1, ip2} {
+		l := bound(ip, testClient1, now)
+		require.NoError(t, a.Put(l))
+		require.NoError(t, a.Delete(l))
+	}
+	// ip2 is deleted again later, its first deletion is not forgotten
+	// alone.
+	now = now.Add(30 * time.Minute)
+	l := bound(ip2, testClient1, now)
+	require.NoError(t, a.Put(l))
+	require.NoError(t, a.Delete(l))
+	require.Len(t, a.deleted, 2)
+
+	now = now.Add(45 * time.Minute)
+	sub, since, ok := a.subscribe(func(*Lease) bool { return true })
+	require.True(t, ok)
+	a.unsubscribe(sub)
+	require.Equal(t, now.Add(-time.Hour), since)
+	require.Len(t, a.deleted, 1)
+	require.NotNil(t, a.deleted[l.key()])
+	require.Len(t, a.deletions, 1)
+}
+
+func TestActiveLeasequeryFilter(t *testing.T) {
+	a, addr := newTestActiveLeasequery(t, func(a *ActiveLeasequery) {
+		a.Allow = func(peer net.Addr, m *dhcpv6.Message) bool {
+			return m.Options.LQQuery().QueryType != dhcpv6.LQQueryByRemoteID
+		}
+	})
+	ctx := context.Background()
+	err := nclient6.NewActiveRequestor(testRelayID1).Run(ctx, dial(t, addr),
+		&dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByRemoteID})
+	var status *nclient6.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusNotAllowed, status.Status.StatusCode)
+
+	err = nclient6.NewActiveRequestor(testRelayID1).Run(ctx, dial(t, addr),
+		&dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByClientID})
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusUnknownQueryType, status.Status.StatusCode)
+
+	lq := &dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByRelayID}
+	lq.Options.Add(dhcpv6.OptRelayID(testRelayID1))
+	r := nclient6.NewActiveRequestor(testRelayID1)
+	stop := runRequestor(t, r, dial(t, addr), lq)
+	waitQueries(t, a, 1)
+	m, err := dhcpv6.NewMessage()
+	require.NoError(t, err)
+	l := bound(net.ParseIP("2001:db8::10"), testClient1, time.Now())
+	l.LinkAddr, l.Relay = relayData(newTestRelayed(t, m, testLink, testRelayID1, []byte("r1")))
+	require.NoError(t, a.Put(bound(net.ParseIP("2001:db8::11"), testClient2, time.Now())))
+	require.NoError(t, a.Put(l))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	b := r.Get(l.Addr)
+	require.NotNil(t, b)
+	require.NotNil(t, b.ClientData.Options.LQRelayData())
+	require.Equal(t, context.Canceled, stop())
+}
+
+func TestActiveLeasequeryTLS(t *testing.T) {
+	serverConfig, clientConfig := testTLSConfigs(t)
+	ctx := context.Background()
+
+	// TLS is refused without a configuration.
+	_, addr := newTestActiveLeasequery(t, nil)
+	_, err := nclient6.StartTLS(ctx, dial(t, addr), clientConfig)
+	var status *nclient6.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusTLSConnectionRefused, status.Status.StatusCode)
+
+	a, addr := newTestActiveLeasequery(t, func(a *ActiveLeasequery) { a.TLSConfig = serverConfig })
+	conn, err := nclient6.StartTLS(ctx, dial(t, addr), clientConfig)
+	require.NoError(t, err)
+	r := nclient6.NewActiveRequestor(testRelayID1)
+	stop := runRequestor(t, r, conn, nil)
+	waitQueries(t, a, 1)
+	require.NoError(t, a.Put(bound(net.ParseIP("2001:db8::10"), testClient1, time.Now())))
+	require.Eventually(t, func() bool { return len(r.Bindings()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, context.Canceled, stop())
+}
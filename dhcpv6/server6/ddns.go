@@ -0,0 +1,455 @@
+package server6
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/rfc1035label"
+)
+
+// DDNS updates the DNS records of DHCPv6 clients, as described in RFC 4704
+// and RFC 4703. It wraps a Handler, and acts on the replies the handler
+// sends: it adds AAAA, PTR and DHCID records for the IA_NA addresses bound to
+// clients that send the Client FQDN option, and removes them when the
+// clients release or decline them, or when their valid lifetime ends.
+//
+// The PTR records are updated whenever Updater has a ReverseZone.
+type DDNS struct {
+	Updater *ddns.Updater
+	// Domain is appended to partial client names. If empty, the forward
+	// zone of Updater is used.
+	Domain string
+	// AllowClientUpdates lets clients update their AAAA records
+	// themselves when they ask to. Otherwise, the server overrides their
+	// preference.
+	AllowClientUpdates bool
+	// DelegatedPrefixes enables records for delegated prefixes too: the
+	// address made of the prefix and PrefixInterfaceID is registered.
+	DelegatedPrefixes bool
+	// PrefixInterfaceID is the interface identifier of the addresses
+	// registered for delegated prefixes. If nil, ::1 is used.
+	PrefixInterfaceID net.IP
+	// Logger, if not nil, logs failed updates.
+	Logger Printfer
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	closed bool
+	// pending are the updates to send, by address, and order the
+	// addresses in the order they were queued. Only the latest update of
+	// an address is kept, see update.merge.
+	pending map[string]*update
+	order   []string
+	wg      sync.WaitGroup
+	// regs are the registered names, by address. They are only accessed
+	// by the worker goroutine.
+	regs map[string]*registration
+}
+
+type registration struct {
+	name    string
+	duid    []byte
+	dhcid   []byte
+	forward bool
+	reverse bool
+	timer   *time.Timer
+}
+
+// update is a change of the binding of an address.
+type update struct {
+	duid []byte
+	// in is nil for renewals without the Client FQDN option.
+	in    *intent
+	valid time.Duration
+	// unbind is set when the client no longer has the address.
+	unbind bool
+	// expired, if not nil, is the registration whose valid lifetime
+	// ended.
+	expired *registration
+}
+
+// keep returns whether u keeps the records of the client as they are, and
+// only extends their lifetime.
+func (u *update) keep() bool {
+	return !u.unbind && u.expired == nil && (u.in == nil || u.in.name == "" || !(u.in.forward || u.in.reverse))
+}
+
+// merge returns the update to send when u is queued while p is pending for
+// the same address.
+func (p *update) merge(u *update) *update {
+	switch {
+	case u.expired != nil:
+		// p is more recent than the registration that expired.
+		return p
+	case u.keep():
+		if !p.unbind && p.expired == nil && bytes.Equal(p.duid, u.duid) {
+			m := *p
+			m.valid = u.valid
+			return &m
+		}
+		return p
+	}
+	return u
+}
+
+// NewDDNS returns a DDNS that uses u. Updates are sent in the background, in
+// order, until Close is called. When the DNS server is slower than clients,
+// the pending updates of an address are merged, so that only its latest
+// state is sent.
+func NewDDNS(u *ddns.Updater) *DDNS {
+	d := &DDNS{
+		Updater: u,
+		pending: make(map[string]*update),
+		regs:    make(map[string]*registration),
+	}
+	d.cond = sync.NewCond(&d.mu)
+	d.wg.Add(1)
+	go d.work()
+	return d
+}
+
+func (d *DDNS) work() {
+	defer d.wg.Done()
+	for {
+		d.mu.Lock()
+		for len(d.order) == 0 {
+			if d.closed {
+				d.mu.Unlock()
+				return
+			}
+			d.cond.Wait()
+		}
+		key := d.order[0]
+		d.order = d.order[1:]
+		u := d.pending[key]
+		delete(d.pending, key)
+		d.mu.Unlock()
+		d.apply(net.IP(key), u)
+	}
+}
+
+// Close waits for pending updates and stops d. Records are left in place.
+func (d *DDNS) Close() {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+	d.wg.Wait()
+	for _, r := range d.regs {
+		r.timer.Stop()
+	}
+}
+
+// enqueue queues the update u of ip, merged with the pending update of ip,
+// if any. It never waits for DNS servers.
+func (d *DDNS) enqueue(ip net.IP, u *update) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	key := string(ip.To16())
+	if p, ok := d.pending[key]; ok {
+		d.pending[key] = p.merge(u)
+		return
+	}
+	d.order = append(d.order, key)
+	d.pending[key] = u
+	d.cond.Signal()
+}
+
+func (d *DDNS) printf(format string, v ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Printf(format, v...)
+	}
+}
+
+// intent is what the server does for a client.
+type intent struct {
+	name    string
+	forward bool
+	reverse bool
+	// flags are the flags of the FQDN option in replies.
+	flags uint8
+}
+
+func (d *DDNS) intent(f *dhcpv6.OptFQDN) *intent {
+	if f == nil {
+		return nil
+	}
+	in := &intent{name: d.qualify(f.DomainName)}
+	switch {
+	case f.Flags&dhcpv6.FQDNFlagN != 0:
+		// RFC 4704, Section 5: the client asked for no updates at all.
+		in.flags = dhcpv6.FQDNFlagN
+		return in
+	case f.Flags&dhcpv6.FQDNFlagS != 0:
+		in.forward = true
+		in.flags = dhcpv6.FQDNFlagS
+	case !d.AllowClientUpdates:
+		in.forward = true
+		in.flags = dhcpv6.FQDNFlagS | dhcpv6.FQDNFlagO
+	}
+	in.reverse = d.Updater.ReverseZone != ""
+	return in
+}
+
+// qualify returns the fully qualified form of the name in the FQDN option
+// of a client, or an empty string if the name cannot be registered.
+func (d *DDNS) qualify(l *rfc1035label.Labels) string {
+	if l == nil || len(l.Labels) == 0 || l.Labels[0] == "" {
+		return ""
+	}
+	name := strings.ToLower(l.Labels[0])
+	// Partial names lack the terminating zero-length label, RFC 4704,
+	// Section 4.2.
+	if b := l.ToBytes(); b[len(b)-1] == 0 {
+		name = ddns.Fqdn(name)
+	} else {
+		domain := d.Domain
+		if domain == "" {
+			domain = d.Updater.ForwardZone
+		}
+		name = strings.ToLower(name + "." + ddns.Fqdn(domain))
+	}
+	if err := ddns.ValidateName(name); err != nil {
+		d.printf("ddns: ignoring invalid client name %q: %v", l.Labels[0], err)
+		return ""
+	}
+	return name
+}
+
+// Handler returns a Handler that calls next, and updates DNS records for the
+// bindings in the replies next sends.
+func (d *DDNS) Handler(next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		req, err := m.GetInnerMessage()
+		if err != nil {
+			next(conn, peer, m)
+			return
+		}
+		next(&ddnsConn{PacketConn: conn, d: d, req: req}, peer, m)
+	}
+}
+
+// ddnsConn inspects and amends the replies to req.
+type ddnsConn struct {
+	net.PacketConn
+	d   *DDNS
+	req *dhcpv6.Message
+}
+
+func (c *ddnsConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if reply, err := dhcpv6.FromBytes(b); err == nil {
+		if inner, err := reply.GetInnerMessage(); err == nil && c.d.handle(c.req, inner) {
+			b = reply.ToBytes()
+		}
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// handle acts on a reply to req, and returns whether it modified the reply.
+func (d *DDNS) handle(req, reply *dhcpv6.Message) bool {
+	duid := req.Options.ClientID()
+	if duid == nil {
+		return false
+	}
+	in := d.intent(req.Options.FQDN())
+	modified := false
+	if in != nil && (reply.Type() == dhcpv6.MessageTypeAdvertise || reply.Type() == dhcpv6.MessageTypeReply) {
+		// RFC 4704, Section 6.
+		reply.UpdateOption(&dhcpv6.OptFQDN{
+			Flags:      in.flags,
+			DomainName: &rfc1035label.Labels{Labels: []string{strings.TrimSuffix(in.name, ".")}},
+		})
+		modified = true
+	}
+	if reply.Type() != dhcpv6.MessageTypeReply {
+		return modified
+	}
+
+	switch req.Type() {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest,
+		dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind:
+		for _, b := range d.bindings(reply) {
+			if b.valid > 0 {
+				d.bind(duid.ToBytes(), in, b.ip, b.valid)
+			} else {
+				d.unbind(duid.ToBytes(), b.ip)
+			}
+		}
+	case dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+		for _, b := range d.bindings(req) {
+			d.unbind(duid.ToBytes(), b.ip)
+		}
+	}
+	return modified
+}
+
+type binding struct {
+	ip    net.IP
+	valid time.Duration
+}
+
+// bindings returns the addresses to register for the IA_NA and, if enabled,
+// IA_PD options of m.
+func (d *DDNS) bindings(m *dhcpv6.Message) []binding {
+	var out []binding
+	for _, iana := range m.Options.IANA() {
+		for _, a := range iana.Options.Addresses() {
+			out = append(out, binding{ip: a.IPv6Addr, valid: a.ValidLifetime})
+		}
+	}
+	if !d.DelegatedPrefixes {
+		return out
+	}
+	id := d.PrefixInterfaceID
+	if id == nil {
+		id = net.ParseIP("::1")
+	}
+	for _, iapd := range m.Options.IAPD() {
+		for _, p := range iapd.Options.Prefixes() {
+			if p.Prefix == nil {
+				continue
+			}
+			ip := make(net.IP, net.IPv6len)
+			for i := range ip {
+				ip[i] = p.Prefix.IP.To16()[i]&p.Prefix.Mask[i] | id.To16()[i]&^p.Prefix.Mask[i]
+			}
+			out = append(out, binding{ip: ip, valid: p.ValidLifetime})
+		}
+	}
+	return out
+}
+
+func (d *DDNS) bind(duid []byte, in *intent, ip net.IP, valid time.Duration) {
+	d.enqueue(ip, &update{duid: duid, in: in, valid: valid})
+}
+
+func (d *DDNS) unbind(duid []byte, ip net.IP) {
+	d.enqueue(ip, &update{duid: duid, unbind: true})
+}
+
+// apply brings the records of ip in line with u. It must be called by the
+// worker.
+func (d *DDNS) apply(ip net.IP, u *update) {
+	key := string(ip.To16())
+	old := d.regs[key]
+	switch {
+	case u.expired != nil:
+		if old == u.expired {
+			d.remove(ip, old)
+		}
+		return
+	case u.unbind:
+		if old != nil && bytes.Equal(old.duid, u.duid) {
+			d.remove(ip, old)
+		}
+		return
+	case u.keep():
+		// Renewals without the FQDN option keep the records.
+		if old != nil && bytes.Equal(old.duid, u.duid) {
+			d.expireAfter(key, old, u.valid)
+		}
+		return
+	}
+	in := u.in
+	if old != nil && bytes.Equal(old.duid, u.duid) && old.name == in.name && old.forward == in.forward && old.reverse == in.reverse {
+		d.expireAfter(key, old, u.valid)
+		return
+	}
+	if old != nil {
+		d.remove(ip, old)
+	}
+	reg := &registration{
+		name:    in.name,
+		duid:    u.duid,
+		dhcid:   ddns.DHCIDFromDUID(u.duid, in.name),
+		forward: in.forward,
+		reverse: in.reverse,
+	}
+	if d.add(ip, reg) {
+		d.regs[key] = reg
+		d.expireAfter(key, reg, u.valid)
+	}
+}
+
+// expireAfter schedules the removal of reg when the valid lifetime ends.
+// It must be called by the worker.
+func (d *DDNS) expireAfter(key string, reg *registration, valid time.Duration) {
+	if reg.timer != nil {
+		reg.timer.Stop()
+	}
+	if valid == time.Duration(0xffffffff)*time.Second {
+		// Infinite lifetime.
+		return
+	}
+	ip := net.IP(key)
+	reg.timer = time.AfterFunc(valid, func() {
+		d.enqueue(ip, &update{expired: reg})
+	})
+}
+
+// add adds the records of reg for ip. It must be called by the worker.
+func (d *DDNS) add(ip net.IP, reg *registration) bool {
+	ctx := context.Background()
+	if reg.forward {
+		if err := d.Updater.AddForwardAddrs(ctx, reg.name, d.addrs(ip, reg), reg.dhcid); err != nil {
+			d.printf("ddns: unable to add %s for %s: %v", reg.name, ip, err)
+			// RFC 4703, Section 5.3.2: the PTR record is not updated
+			// for names of other clients.
+			if errors.Is(err, ddns.ErrConflict) {
+				return false
+			}
+			reg.forward = false
+		}
+	}
+	if reg.reverse {
+		if err := d.Updater.AddReverse(ctx, ip, reg.name); err != nil {
+			d.printf("ddns: unable to add PTR for %s: %v", ip, err)
+			reg.reverse = false
+		}
+	}
+	return reg.forward || reg.reverse
+}
+
+// addrs returns ip and the other addresses registered under the name of
+// reg by the same client, sorted. It must be called by the worker.
+func (d *DDNS) addrs(ip net.IP, reg *registration) []net.IP {
+	ips := []net.IP{ip.To16()}
+	for key, r := range d.regs {
+		if key != string(ip.To16()) && r.forward && r.name == reg.name && bytes.Equal(r.duid, reg.duid) {
+			ips = append(ips, net.IP(key))
+		}
+	}
+	sort.Slice(ips, func(i, j int) bool { return bytes.Compare(ips[i], ips[j]) < 0 })
+	return ips
+}
+
+// remove removes the records of reg, and forgets it. It must be called by
+// the worker.
+func (d *DDNS) remove(ip net.IP, reg *registration) {
+	ctx := context.Background()
+	if reg.timer != nil {
+		reg.timer.Stop()
+	}
+	delete(d.regs, string(ip.To16()))
+	if reg.forward {
+		if err := d.Updater.RemoveForward(ctx, reg.name, ip, reg.dhcid); err != nil {
+			d.printf("ddns: unable to remove %s for %s: %v", reg.name, ip, err)
+		}
+	}
+	if reg.reverse {
+		if err := d.Updater.RemoveReverse(ctx, ip, reg.name); err != nil {
+			d.printf("ddns: unable to remove PTR for %s: %v", ip, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package server6
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/ddns"
+	"github.com/insomniacslk/dhcp/ddns/ddnstest"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testAddr   = net.ParseIP("2001:db8::10")
+	testPrefix = &net.IPNet{IP: net.ParseIP("2001:db8:1::"), Mask: net.CIDRMask(56, 128)}
+)
+
+func newTestDDNS(t *testing.T) (*DDNS, *ddnstest.Server) {
+	key := &ddns.Key{Name: "dhcp-key.", Secret: []byte("0123456789abcdef")}
+	s, err := ddnstest.NewServer(key, "example.com.", "8.b.d.0.1.0.0.2.ip6.arpa.")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	d := NewDDNS(&ddns.Updater{
+		Client:      &ddns.Client{Server: s.Addr, Key: key, Timeout: time.Second},
+		ForwardZone: "example.com.",
+		ReverseZone: "8.b.d.0.1.0.0.2.ip6.arpa.",
+	})
+	t.Cleanup(d.Close)
+	return d, s
+}
+
+// partialName returns the labels of a partial domain name.
+func partialName(t *testing.T, name string) *rfc1035label.Labels {
+	l, err := rfc1035label.FromBytes(append([]byte{byte(len(name))}, name...))
+	require.NoError(t, err)
+	return l
+}
+
+// encodeName returns the wire format of name.
+func encodeName(t *testing.T, name string) []byte {
+	b, err := ddns.EncodeName(name)
+	require.NoError(t, err)
+	return b
+}
+
+func newTestRequest(t *testing.T, typ dhcpv6.MessageType, hwaddr net.HardwareAddr, modifiers ...dhcpv6.Modifier) *dhcpv6.Message {
+	m, err := dhcpv6.NewSolicit(hwaddr, modifiers...)
+	require.NoError(t, err)
+	m.MessageType = typ
+	return m
+}
+
+// testHandler replies to requests with the addresses and prefixes of
+// the requests, with the given valid lifetime.
+func testHandler(valid time.Duration) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		msg := m.(*dhcpv6.Message)
+		var reply *dhcpv6.Message
+		var err error
+		if msg.Type() == dhcpv6.MessageTypeSolicit && msg.GetOneOption(dhcpv6.OptionRapidCommit) == nil {
+			reply, err = dhcpv6.NewAdvertiseFromSolicit(msg)
+		} else {
+			reply, err = dhcpv6.NewReplyFromMessage(msg)
+		}
+		if err != nil {
+			return
+		}
+		switch msg.Type() {
+		case dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+		default:
+			for _, iana := range msg.Options.IANA() {
+				for _, a := range iana.Options.Addresses() {
+					reply.AddOption(&dhcpv6.OptIANA{
+						IaId: iana.IaId,
+						Options: dhcpv6.IdentityOptions{Options: dhcpv6.Options{
+							&dhcpv6.OptIAAddress{IPv6Addr: a.IPv6Addr, PreferredLifetime: valid, ValidLifetime: valid},
+						}},
+					})
+				}
+			}
+			for _, iapd := range msg.Options.IAPD() {
+				for _, p := range iapd.Options.Prefixes() {
+					reply.AddOption(&dhcpv6.OptIAPD{
+						IaId: iapd.IaId,
+						Options: dhcpv6.PDOptions{Options: dhcpv6.Options{
+							&dhcpv6.OptIAPrefix{Prefix: p.Prefix, PreferredLifetime: valid, ValidLifetime: valid},
+						}},
+					})
+				}
+			}
+		}
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+func TestDDNSReply(t *testing.T) {
+	d, _ := newTestDDNS(t)
+	h := d.Handler(testHandler(time.Hour))
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	for _, tt := range []struct {
+		name       string
+		allow      bool
+		fqdn       *dhcpv6.OptFQDN
+		wantFlags  uint8
+		wantDomain string
+	}{
+		{
+			name:       "server updates",
+			fqdn:       &dhcpv6.OptFQDN{Flags: dhcpv6.FQDNFlagS, DomainName: partialName(t, "host")},
+			wantFlags:  dhcpv6.FQDNFlagS,
+			wantDomain: "host.example.com.",
+		},
+		{
+			name:       "override",
+			fqdn:       &dhcpv6.OptFQDN{DomainName: &rfc1035label.Labels{Labels: []string{"Host.Example.Com"}}},
+			wantFlags:  dhcpv6.FQDNFlagS | dhcpv6.FQDNFlagO,
+			wantDomain: "host.example.com.",
+		},
+		{
+			name:       "client updates",
+			allow:      true,
+			fqdn:       &dhcpv6.OptFQDN{DomainName: partialName(t, "host")},
+			wantDomain: "host.example.com.",
+		},
+		{
+			name:       "no updates",
+			fqdn:       &dhcpv6.OptFQDN{Flags: dhcpv6.FQDNFlagN, DomainName: partialName(t, "host")},
+			wantFlags:  dhcpv6.FQDNFlagN,
+			wantDomain: "host.example.com.",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			d.AllowClientUpdates = tt.allow
+			conn := &recordConn{}
+			h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeSolicit, hwaddr, dhcpv6.WithOption(tt.fqdn)))
+			require.Len(t, conn.sent, 1)
+			require.Equal(t, dhcpv6.MessageTypeAdvertise, conn.sent[0].Type())
+			fqdn := conn.sent[0].Options.FQDN()
+			require.NotNil(t, fqdn)
+			require.Equal(t, tt.wantFlags, fqdn.Flags)
+			require.Equal(t, encodeName(t, tt.wantDomain), fqdn.DomainName.ToBytes())
+		})
+	}
+
+	// Clients without the option do not get it.
+	conn := &recordConn{}
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeSolicit, hwaddr))
+	require.Len(t, conn.sent, 1)
+	require.Nil(t, conn.sent[0].Options.FQDN())
+}
+
+func TestDDNSBindings(t *testing.T) {
+	d, s := newTestDDNS(t)
+	h := d.Handler(testHandler(time.Hour))
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	fqdn := dhcpv6.WithFQDN(dhcpv6.FQDNFlagS, "host.example.com")
+	addr := dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: testAddr})
+
+	conn := &recordConn{}
+	req := newTestRequest(t, dhcpv6.MessageTypeRequest, hwaddr, fqdn, addr)
+	h(conn, &net.UDPAddr{}, req)
+	// Renewals, with or without the option, do not cause updates.
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRenew, hwaddr, fqdn, addr))
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRenew, hwaddr, addr))
+	require.Len(t, conn.sent, 3)
+
+	// Another client does not take the name.
+	other := net.ParseIP("2001:db8::11")
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, 2}, fqdn,
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: other})))
+	d.Close()
+
+	duid := req.Options.ClientID().ToBytes()
+	require.Equal(t, [][]byte{testAddr.To16()}, s.Records("host.example.com.", ddns.TypeAAAA))
+	require.Equal(t, [][]byte{ddns.DHCIDFromDUID(duid, "host.example.com.")}, s.Records("host.example.com.", ddns.TypeDHCID))
+	require.Equal(t, [][]byte{encodeName(t, "host.example.com.")}, s.Records(ddns.ReverseName(testAddr), ddns.TypePTR))
+	require.Empty(t, s.Records(ddns.ReverseName(other), ddns.TypePTR))
+	// Forward and reverse adds, then the conflicting add and its retry.
+	require.Len(t, s.Requests(), 4)
+}
+
+func TestDDNSInvalidNames(t *testing.T) {
+	d, s := newTestDDNS(t)
+	h := d.Handler(testHandler(time.Hour))
+	var logs strings.Builder
+	d.Logger = log.New(&logs, "", 0)
+
+	for i, name := range []string{
+		"a..evil",
+		strings.Repeat("a", 64),
+		strings.Repeat("a", 200),
+	} {
+		fqdn := dhcpv6.WithOption(&dhcpv6.OptFQDN{Flags: dhcpv6.FQDNFlagS, DomainName: partialName(t, name)})
+		addr := dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP(fmt.Sprintf("2001:db8::%x", 0x10+i))})
+		conn := &recordConn{}
+		h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, byte(i)}, fqdn, addr))
+		require.Len(t, conn.sent, 1)
+	}
+	d.Close()
+
+	require.Empty(t, s.Requests())
+	require.Contains(t, logs.String(), "invalid client name")
+	require.NotContains(t, logs.String(), "unable to add")
+}
+
+// printfFunc is a Printfer that calls itself.
+type printfFunc func(format string, v ...interface{})
+
+func (f printfFunc) Printf(format string, v ...interface{}) { f(format, v...) }
+
+func TestDDNSCoalesce(t *testing.T) {
+	d, s := newTestDDNS(t)
+	duid1, duid2 := []byte{0, 3, 0, 1, 1}, []byte{0, 3, 0, 1, 2}
+	ip1, ip2, ip3, ip4 := testAddr, net.ParseIP("2001:db8::11"), net.ParseIP("2001:db8::12"), net.ParseIP("2001:db8::13")
+	name := func(n string) *intent {
+		return &intent{name: n + ".example.com.", forward: true, reverse: true}
+	}
+	d.bind(duid1, name("taken"), ip3, time.Hour)
+	d.bind(duid2, name("other"), ip2, time.Hour)
+	require.Eventually(t, func() bool {
+		return len(s.Records(ddns.ReverseName(ip2), ddns.TypePTR)) == 1
+	}, time.Second, time.Millisecond)
+
+	// Block the worker on the log of a conflict, and change the bindings
+	// much more often than the DNS server can follow: handlers must not
+	// wait for it, and the last state of each address must be sent.
+	blocked, unblock := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	d.Logger = printfFunc(func(string, ...interface{}) {
+		once.Do(func() {
+			close(blocked)
+			<-unblock
+		})
+	})
+	d.bind(duid2, name("taken"), ip4, time.Hour)
+	<-blocked
+	sent := len(s.Requests())
+	for i := 0; i < 200; i++ {
+		d.bind(duid1, name(fmt.Sprintf("host%d", i)), ip1, time.Hour)
+		d.unbind(duid1, ip1)
+		d.unbind(duid2, ip2)
+		d.bind(duid2, name("other"), ip2, time.Hour)
+	}
+	d.bind(duid1, name("last"), ip1, time.Hour)
+	// Renewals without the option do not replace pending updates.
+	d.bind(duid1, nil, ip1, 2*time.Hour)
+	d.unbind(duid2, ip2)
+	close(unblock)
+	d.Close()
+
+	require.Equal(t, [][]byte{ip1.To16()}, s.Records("last.example.com.", ddns.TypeAAAA))
+	require.Empty(t, s.Records("other.example.com.", ddns.TypeAAAA))
+	require.Empty(t, s.Records(ddns.ReverseName(ip2), ddns.TypePTR))
+	require.Equal(t, [][]byte{ip3.To16()}, s.Records("taken.example.com.", ddns.TypeAAAA))
+	// Add last, forward then reverse records, and remove other: address,
+	// DHCID and reverse records.
+	require.Len(t, s.Requests(), sent+5)
+}
+
+func TestDDNSMerge(t *testing.T) {
+	bind := &update{duid: []byte{1}, in: &intent{name: "host.example.com.", forward: true}, valid: time.Hour}
+	keep := &update{duid: []byte{1}, valid: 2 * time.Hour}
+	unbind := &update{duid: []byte{1}, unbind: true}
+	expired := &update{expired: &registration{}}
+
+	require.Equal(t, &update{duid: bind.duid, in: bind.in, valid: 2 * time.Hour}, bind.merge(keep))
+	require.Equal(t, unbind, unbind.merge(keep))
+	require.Equal(t, bind, bind.merge(&update{duid: []byte{2}}))
+	require.Equal(t, bind, bind.merge(expired))
+	require.Equal(t, unbind, bind.merge(unbind))
+	require.Equal(t, bind, unbind.merge(bind))
+	require.Equal(t, bind, expired.merge(bind))
+}
+
+func TestDDNSRelease(t *testing.T) {
+	d, s := newTestDDNS(t)
+	d.DelegatedPrefixes = true
+	h := d.Handler(testHandler(time.Hour))
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	mods := []dhcpv6.Modifier{
+		dhcpv6.WithFQDN(dhcpv6.FQDNFlagS, "host.example.com"),
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: testAddr}),
+		dhcpv6.WithIAPD([4]byte{0, 0, 0, 1}, &dhcpv6.OptIAPrefix{Prefix: testPrefix}),
+	}
+
+	conn := &recordConn{}
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRequest, hwaddr, mods...))
+	require.Eventually(t, func() bool {
+		return len(s.Records("host.example.com.", ddns.TypeAAAA)) == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, [][]byte{testAddr.To16(), net.ParseIP("2001:db8:1::1").To16()}, s.Records("host.example.com.", ddns.TypeAAAA))
+
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRelease, hwaddr, mods...))
+	d.Close()
+	require.Len(t, conn.sent, 2)
+	require.Empty(t, s.Names())
+}
+
+func TestDDNSExpiry(t *testing.T) {
+	d, s := newTestDDNS(t)
+	h := d.Handler(testHandler(time.Second))
+	conn := &recordConn{}
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		dhcpv6.WithFQDN(dhcpv6.FQDNFlagS, "host.example.com"),
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: testAddr})))
+	require.Eventually(t, func() bool {
+		return len(s.Requests()) > 0 && len(s.Names()) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// recordConn is a net.PacketConn that records the DHCPv6 messages written
+// to it.
+type recordConn struct {
+	net.PacketConn
+	sent []*dhcpv6.Message
+}
+
+func (c *recordConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	m, err := dhcpv6.MessageFromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	c.sent = append(c.sent, m)
+	return len(b), nil
+}
@@ -0,0 +1,74 @@
+package server6
+
+import (
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/server4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// DHCP4o6Handler returns a Handler that answers the DHCPv4-QUERY messages,
+// received directly or through relay agents, with the DHCPv4 handler h, as
+// described in RFC 7341, and passes the other messages to next.
+//
+// h is called with the DHCPv4 message of the query, and with the IPv6 address
+// of the peer. The DHCPv4 messages it writes to its connection are sent back
+// to the peer in DHCPv4-RESPONSE messages, whatever their destination.
+// The DHCPv4-QUERY message, and whether the client would have sent the
+// DHCPv4 message by unicast, is available to h with DHCP4o6Query.
+func DHCP4o6Handler(h server4.Handler, next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		query, err := m.GetInnerMessage()
+		if err != nil || query.MessageType != dhcpv6.MessageTypeDHCPv4Query {
+			next(conn, peer, m)
+			return
+		}
+		m4 := query.Options.DHCPv4Msg()
+		if m4 == nil {
+			return
+		}
+		h(&dhcp4o6Conn{PacketConn: conn, peer: peer, m: m, query: query}, peer, m4)
+	}
+}
+
+// DHCP4o6Query returns the DHCPv4-QUERY message that a DHCPv4 handler called
+// by DHCP4o6Handler answers, given the connection it is called with, or nil
+// if the DHCPv4 message was not received over DHCPv6.
+func DHCP4o6Query(conn net.PacketConn) *dhcpv6.Message {
+	if c, ok := conn.(*dhcp4o6Conn); ok {
+		return c.query
+	}
+	return nil
+}
+
+// dhcp4o6Conn sends the DHCPv4 messages written to it in replies to the
+// DHCPv4-QUERY message query, received from peer in m.
+type dhcp4o6Conn struct {
+	net.PacketConn
+	peer  net.Addr
+	m     dhcpv6.DHCPv6
+	query *dhcpv6.Message
+}
+
+// WriteTo sends the DHCPv4 message b to the peer of the query, whatever addr.
+func (c *dhcp4o6Conn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	m4, err := dhcpv4.FromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := dhcpv6.NewDHCPv4Response(c.query, m4)
+	if err != nil {
+		return 0, err
+	}
+	var reply dhcpv6.DHCPv6 = resp
+	if relay, ok := c.m.(*dhcpv6.RelayMessage); ok {
+		if reply, err = dhcpv6.NewRelayReplFromRelayForw(relay, resp); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := c.PacketConn.WriteTo(reply.ToBytes(), c.peer); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
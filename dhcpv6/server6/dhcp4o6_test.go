@@ -0,0 +1,137 @@
+package server6
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv4/nclient4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/stretchr/testify/require"
+)
+
+// dhcp4Handler is a DHCPv4 handler offering 192.0.2.10 to all clients. It
+// records whether the messages it answers were sent by unicast.
+type dhcp4Handler struct {
+	mu      sync.Mutex
+	unicast []bool
+}
+
+func (h *dhcp4Handler) handle(conn net.PacketConn, peer net.Addr, m *dhcpv4.DHCPv4) {
+	if q := DHCP4o6Query(conn); q != nil {
+		h.mu.Lock()
+		h.unicast = append(h.unicast, q.IsDHCPv4Unicast())
+		h.mu.Unlock()
+	}
+	t := dhcpv4.MessageTypeOffer
+	if m.MessageType() == dhcpv4.MessageTypeRequest {
+		t = dhcpv4.MessageTypeAck
+	}
+	reply, err := dhcpv4.NewReplyFromRequest(m,
+		dhcpv4.WithMessageType(t),
+		dhcpv4.WithYourIP(net.IPv4(192, 0, 2, 10)),
+		dhcpv4.WithServerIP(net.IPv4(192, 0, 2, 1)),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(net.IPv4(192, 0, 2, 1))),
+		dhcpv4.WithLeaseTime(3600),
+	)
+	if err != nil {
+		return
+	}
+	_, _ = conn.WriteTo(reply.ToBytes(), &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort})
+}
+
+func TestDHCP4o6Handler(t *testing.T) {
+	h := &dhcp4Handler{}
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	s, err := NewServer("", nil, DHCP4o6Handler(h.handle, reconfigureHandler), WithConn(serverRawConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, err := nclient4.NewWithConn(nclient4.NewDHCP4o6Conn(clientRawConn, ""), net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		nclient4.WithRetry(1), nclient4.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+	lease, err := c.Request(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv4.MessageTypeAck, lease.ACK.MessageType())
+	require.True(t, net.IPv4(192, 0, 2, 10).Equal(lease.ACK.YourIPAddr))
+	h.mu.Lock()
+	require.Equal(t, []bool{false, false}, h.unicast)
+	h.mu.Unlock()
+}
+
+func TestDHCP4o6HandlerRelayed(t *testing.T) {
+	h := &dhcp4Handler{}
+	passed := false
+	handler := DHCP4o6Handler(h.handle, func(net.PacketConn, net.Addr, dhcpv6.DHCPv6) { passed = true })
+
+	m4, err := dhcpv4.NewDiscovery(net.HardwareAddr{2, 0, 0, 0, 0, 1})
+	require.NoError(t, err)
+	q, err := dhcpv6.NewDHCPv4Query(m4, false)
+	require.NoError(t, err)
+	relay, err := dhcpv6.EncapsulateRelay(q, dhcpv6.MessageTypeRelayForward, testLink, net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	conn := &rawConn{}
+	handler(conn, &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: dhcpv6.DefaultServerPort}, relay)
+	require.False(t, passed)
+	require.Len(t, conn.sent, 1)
+	reply, err := dhcpv6.FromBytes(conn.sent[0])
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeRelayReply, reply.Type())
+	inner, err := reply.GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeDHCPv4Response, inner.MessageType)
+	require.Equal(t, dhcpv4.MessageTypeOffer, inner.Options.DHCPv4Msg().MessageType())
+	require.Equal(t, m4.TransactionID, inner.Options.DHCPv4Msg().TransactionID)
+
+	// Other messages are passed on.
+	sol, err := dhcpv6.NewMessage()
+	require.NoError(t, err)
+	handler(conn, nil, sol)
+	require.True(t, passed)
+	require.Nil(t, DHCP4o6Query(conn))
+}
+
+func TestDHCP4oDHCP6Servers(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		servers = []net.IP{net.ParseIP("2001:db8::1")}
+	)
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	s, err := NewServer("", nil, func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		msg := m.(*dhcpv6.Message)
+		reply := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeReply, TransactionID: msg.TransactionID}
+		mu.Lock()
+		if msg.IsOptionRequested(dhcpv6.OptionDHCP4oDHCP6Server) && servers != nil {
+			dhcpv6.WithDHCP4oDHCP6Server(servers...)(reply)
+		}
+		mu.Unlock()
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+	}, WithConn(serverRawConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+	c, err := nclient6.NewWithConn(clientRawConn, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		nclient6.WithRetry(1), nclient6.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+
+	got, err := c.DHCP4oDHCP6Servers(context.Background())
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.True(t, servers[0].Equal(got[0]))
+
+	mu.Lock()
+	servers = nil
+	mu.Unlock()
+	_, err = c.DHCP4oDHCP6Servers(context.Background())
+	require.Equal(t, nclient6.ErrNoDHCP4o6Servers, err)
+}
@@ -0,0 +1,327 @@
+package server6
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Lease is the binding of an address, or of a delegated prefix, to a client.
+type Lease struct {
+	ClientID dhcpv6.DUID
+	// IAID is the identifier of the IA_NA or IA_PD of the binding.
+	IAID [4]byte
+	// Addr is the bound address of an IA_NA binding.
+	Addr net.IP
+	// Prefix is the delegated prefix of an IA_PD binding.
+	Prefix            *net.IPNet
+	PreferredLifetime time.Duration
+	ValidLifetime     time.Duration
+	// Updated is the time of the last transaction with the client.
+	Updated time.Time
+	// LinkAddr is the link-address of the relay agent closest to the
+	// client, or nil if the client is not relayed.
+	LinkAddr net.IP
+	// RelayPeer is the address of the relay agent the last message of the
+	// client was received from, if relayed.
+	RelayPeer net.IP
+	// Relay is the last Relay-forward message the client was received in,
+	// without the message of the client, as described in RFC 5007,
+	// Section 4.1.2.4.
+	Relay *dhcpv6.RelayMessage
+}
+
+// Clone returns a deep copy of l.
+func (l *Lease) Clone() *Lease {
+	c := *l
+	c.Addr = append(net.IP(nil), l.Addr...)
+	if l.Prefix != nil {
+		c.Prefix = &net.IPNet{
+			IP:   append(net.IP(nil), l.Prefix.IP...),
+			Mask: append(net.IPMask(nil), l.Prefix.Mask...),
+		}
+	}
+	c.LinkAddr = append(net.IP(nil), l.LinkAddr...)
+	c.RelayPeer = append(net.IP(nil), l.RelayPeer...)
+	if l.Relay != nil {
+		if r, err := dhcpv6.RelayMessageFromBytes(l.Relay.ToBytes()); err == nil {
+			c.Relay = r
+		}
+	}
+	return &c
+}
+
+// Expiry returns when the valid lifetime of l ends.
+func (l *Lease) Expiry() time.Time {
+	return l.Updated.Add(l.ValidLifetime)
+}
+
+// Active returns whether l is valid at time now.
+func (l *Lease) Active(now time.Time) bool {
+	return now.Before(l.Expiry())
+}
+
+// Contains returns whether ip is the address of l, or is in its prefix.
+func (l *Lease) Contains(ip net.IP) bool {
+	if l.Prefix != nil {
+		return l.Prefix.Contains(ip)
+	}
+	return l.Addr.Equal(ip)
+}
+
+// key identifies the binding of l in a store.
+func (l *Lease) key() string {
+	if l.Prefix != nil {
+		return "prefix:" + l.Prefix.String()
+	}
+	return "addr:" + string(l.Addr.To16())
+}
+
+// ErrNoLease is returned by LeaseStore methods if there is no matching lease.
+var ErrNoLease = errors.New("no such lease")
+
+// LeaseStore stores leases, keyed by address or delegated prefix.
+//
+// Implementations must be safe for concurrent use, and must not retain or
+// return leases that callers can modify.
+type LeaseStore interface {
+	// Get returns the lease of ip, or of the delegated prefix that
+	// contains ip, or ErrNoLease.
+	Get(ip net.IP) (*Lease, error)
+	// Put adds or replaces the lease of l.Addr or l.Prefix.
+	Put(l *Lease) error
+	// Delete removes the lease of l.Addr or l.Prefix.
+	Delete(l *Lease) error
+	// All returns all the leases, ordered by address.
+	All() ([]*Lease, error)
+}
+
+// MemoryLeaseStore is a LeaseStore that keeps leases in memory.
+type MemoryLeaseStore struct {
+	mu     sync.RWMutex
+	leases map[string]*Lease
+}
+
+// NewMemoryLeaseStore returns an empty MemoryLeaseStore.
+func NewMemoryLeaseStore() *MemoryLeaseStore {
+	return &MemoryLeaseStore{leases: make(map[string]*Lease)}
+}
+
+// Get implements LeaseStore.Get.
+func (s *MemoryLeaseStore) Get(ip net.IP) (*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if l, ok := s.leases[(&Lease{Addr: ip}).key()]; ok {
+		return l.Clone(), nil
+	}
+	for _, l := range s.leases {
+		if l.Prefix != nil && l.Prefix.Contains(ip) {
+			return l.Clone(), nil
+		}
+	}
+	return nil, ErrNoLease
+}
+
+// Put implements LeaseStore.Put.
+func (s *MemoryLeaseStore) Put(l *Lease) error {
+	if (l.Addr == nil) == (l.Prefix == nil) {
+		return errors.New("lease must have either an address or a prefix")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.leases[l.key()] = l.Clone()
+	return nil
+}
+
+// Delete implements LeaseStore.Delete.
+func (s *MemoryLeaseStore) Delete(l *Lease) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.leases, l.key())
+	return nil
+}
+
+// All implements LeaseStore.All.
+func (s *MemoryLeaseStore) All() ([]*Lease, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	leases := make([]*Lease, 0, len(s.leases))
+	for _, l := range s.leases {
+		leases = append(leases, l.Clone())
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		return bytes.Compare(leaseIP(leases[i]), leaseIP(leases[j])) < 0
+	})
+	return leases, nil
+}
+
+func leaseIP(l *Lease) net.IP {
+	if l.Prefix != nil {
+		return l.Prefix.IP.To16()
+	}
+	return l.Addr.To16()
+}
+
+// LeaseRecorder records the bindings made by a server in a LeaseStore, for
+// leasequery. It wraps a Handler, and acts on the replies the handler sends:
+// the addresses and prefixes bound by replies to Solicit, Request, Renew and
+// Rebind messages are stored, and the ones released or declined by clients,
+// or given a zero valid lifetime, are removed.
+type LeaseRecorder struct {
+	Store LeaseStore
+	// Logger, if not nil, logs failed updates of Store.
+	Logger Printfer
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewLeaseRecorder returns a LeaseRecorder that records bindings in store.
+func NewLeaseRecorder(store LeaseStore) *LeaseRecorder {
+	return &LeaseRecorder{Store: store}
+}
+
+func (r *LeaseRecorder) printf(format string, v ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, v...)
+	}
+}
+
+// Handler returns a Handler that calls next, and records the bindings in the
+// replies next sends.
+func (r *LeaseRecorder) Handler(next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		req, err := m.GetInnerMessage()
+		if err != nil {
+			next(conn, peer, m)
+			return
+		}
+		next(&leaseConn{PacketConn: conn, r: r, m: m, peer: peer, req: req}, peer, m)
+	}
+}
+
+// leaseConn inspects the replies to req, received from peer in m.
+type leaseConn struct {
+	net.PacketConn
+	r    *LeaseRecorder
+	m    dhcpv6.DHCPv6
+	peer net.Addr
+	req  *dhcpv6.Message
+}
+
+func (c *leaseConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if reply, err := dhcpv6.FromBytes(b); err == nil {
+		if inner, err := reply.GetInnerMessage(); err == nil {
+			c.r.record(c, inner)
+		}
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// record updates the store with a reply sent through c.
+func (r *LeaseRecorder) record(c *leaseConn, reply *dhcpv6.Message) {
+	duid := c.req.Options.ClientID()
+	if duid == nil || reply.Type() != dhcpv6.MessageTypeReply {
+		return
+	}
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+
+	var put, del []*Lease
+	switch c.req.Type() {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind:
+		base := Lease{ClientID: duid, Updated: now}
+		if relay, ok := c.m.(*dhcpv6.RelayMessage); ok {
+			base.LinkAddr, base.Relay = relayData(relay)
+			if ua, ok := c.peer.(*net.UDPAddr); ok {
+				base.RelayPeer = ua.IP
+			}
+		}
+		for _, ia := range reply.Options.IANA() {
+			if s := ia.Options.Status(); s != nil && s.StatusCode != iana.StatusSuccess {
+				continue
+			}
+			for _, a := range ia.Options.Addresses() {
+				l := base
+				l.IAID = ia.IaId
+				l.Addr = a.IPv6Addr
+				l.PreferredLifetime, l.ValidLifetime = a.PreferredLifetime, a.ValidLifetime
+				if l.ValidLifetime == 0 {
+					del = append(del, &l)
+				} else {
+					put = append(put, &l)
+				}
+			}
+		}
+		for _, ia := range reply.Options.IAPD() {
+			if s := ia.Options.Status(); s != nil && s.StatusCode != iana.StatusSuccess {
+				continue
+			}
+			for _, p := range ia.Options.Prefixes() {
+				l := base
+				l.IAID = ia.IaId
+				l.Prefix = p.Prefix
+				l.PreferredLifetime, l.ValidLifetime = p.PreferredLifetime, p.ValidLifetime
+				if l.ValidLifetime == 0 {
+					del = append(del, &l)
+				} else {
+					put = append(put, &l)
+				}
+			}
+		}
+	case dhcpv6.MessageTypeRelease, dhcpv6.MessageTypeDecline:
+		for _, ia := range c.req.Options.IANA() {
+			for _, a := range ia.Options.Addresses() {
+				del = append(del, &Lease{ClientID: duid, Addr: a.IPv6Addr})
+			}
+		}
+		for _, ia := range c.req.Options.IAPD() {
+			for _, p := range ia.Options.Prefixes() {
+				del = append(del, &Lease{ClientID: duid, Prefix: p.Prefix})
+			}
+		}
+	}
+
+	for _, l := range put {
+		if err := r.Store.Put(l); err != nil {
+			r.printf("lease recorder: cannot store lease of %s: %v", leaseIP(l), err)
+		}
+	}
+	for _, l := range del {
+		// Only the bindings of the client are removed.
+		cur, err := r.Store.Get(leaseIP(l))
+		if err != nil || cur.key() != l.key() || !cur.ClientID.Equal(duid) {
+			continue
+		}
+		if err := r.Store.Delete(l); err != nil {
+			r.printf("lease recorder: cannot remove lease of %s: %v", leaseIP(l), err)
+		}
+	}
+}
+
+// relayData returns the link-address of the relay agent closest to the
+// client, and a copy of relay without the client message.
+func relayData(relay *dhcpv6.RelayMessage) (net.IP, *dhcpv6.RelayMessage) {
+	c, err := dhcpv6.RelayMessageFromBytes(relay.ToBytes())
+	if err != nil {
+		return nil, nil
+	}
+	inner := c
+	for {
+		next, ok := inner.Options.RelayMessage().(*dhcpv6.RelayMessage)
+		if !ok {
+			break
+		}
+		inner = next
+	}
+	inner.Options.Del(dhcpv6.OptionRelayMsg)
+	return inner.LinkAddr, c
+}
@@ -0,0 +1,159 @@
+package server6
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLeaseStore(t *testing.T) {
+	s := NewMemoryLeaseStore()
+	duid := &dhcpv6.DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+	_, err := s.Get(testAddr)
+	require.Equal(t, ErrNoLease, err)
+	require.Error(t, s.Put(&Lease{ClientID: duid}))
+
+	l := &Lease{ClientID: duid, Addr: append(net.IP(nil), testAddr...), ValidLifetime: time.Hour}
+	require.NoError(t, s.Put(l))
+	require.NoError(t, s.Put(&Lease{ClientID: duid, Prefix: testPrefix, ValidLifetime: time.Hour}))
+	// Stored leases are copies.
+	l.Addr[15] = 0x20
+
+	got, err := s.Get(testAddr)
+	require.NoError(t, err)
+	require.True(t, got.Addr.Equal(testAddr))
+	got, err = s.Get(net.ParseIP("2001:db8:1:10::1"))
+	require.NoError(t, err)
+	require.Equal(t, testPrefix.String(), got.Prefix.String())
+	require.True(t, got.Contains(net.ParseIP("2001:db8:1::1")))
+	require.False(t, got.Contains(testAddr))
+
+	all, err := s.All()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.True(t, all[0].Addr.Equal(testAddr))
+	require.Equal(t, testPrefix.String(), all[1].Prefix.String())
+
+	require.NoError(t, s.Delete(&Lease{Prefix: testPrefix}))
+	_, err = s.Get(net.ParseIP("2001:db8:1::1"))
+	require.Equal(t, ErrNoLease, err)
+}
+
+func TestLeaseRecorder(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryLeaseStore()
+	r := NewLeaseRecorder(s)
+	r.now = func() time.Time { return now }
+	h := r.Handler(testHandler(time.Hour))
+	hwaddr := net.HardwareAddr{2, 0, 0, 0, 0, 1}
+	mods := []dhcpv6.Modifier{
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: testAddr}),
+		dhcpv6.WithIAPD([4]byte{0, 0, 0, 1}, &dhcpv6.OptIAPrefix{Prefix: testPrefix}),
+	}
+
+	conn := &recordConn{}
+	req := newTestRequest(t, dhcpv6.MessageTypeRequest, hwaddr, mods...)
+	h(conn, &net.UDPAddr{}, req)
+	require.Len(t, conn.sent, 1)
+	all, err := s.All()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	for _, l := range all {
+		require.True(t, l.ClientID.Equal(req.Options.ClientID()))
+		require.Equal(t, time.Hour, l.ValidLifetime)
+		require.True(t, now.Equal(l.Updated))
+		require.True(t, l.Active(now))
+		require.False(t, l.Active(now.Add(time.Hour)))
+		require.Nil(t, l.LinkAddr)
+		require.Nil(t, l.Relay)
+	}
+	require.Equal(t, [4]byte{0, 0, 0, 1}, all[1].IAID)
+
+	// Advertised bindings are not recorded.
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeSolicit, net.HardwareAddr{2, 0, 0, 0, 0, 2},
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::11")})))
+	all, err = s.All()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	// Other clients cannot release the bindings.
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRelease, net.HardwareAddr{2, 0, 0, 0, 0, 2}, mods...))
+	all, err = s.All()
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	h(conn, &net.UDPAddr{}, newTestRequest(t, dhcpv6.MessageTypeRelease, hwaddr, mods...))
+	all, err = s.All()
+	require.NoError(t, err)
+	require.Empty(t, all)
+}
+
+// relayHandler replies to relayed messages with the address of the client
+// message, with the given valid lifetime.
+func relayHandler(valid time.Duration) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		relay := m.(*dhcpv6.RelayMessage)
+		msg, err := relay.GetInnerMessage()
+		if err != nil {
+			return
+		}
+		reply, err := dhcpv6.NewReplyFromMessage(msg)
+		if err != nil {
+			return
+		}
+		for _, ia := range msg.Options.IANA() {
+			for _, a := range ia.Options.Addresses() {
+				reply.AddOption(&dhcpv6.OptIANA{
+					IaId: ia.IaId,
+					Options: dhcpv6.IdentityOptions{Options: dhcpv6.Options{
+						&dhcpv6.OptIAAddress{IPv6Addr: a.IPv6Addr, PreferredLifetime: valid, ValidLifetime: valid},
+					}},
+				})
+			}
+		}
+		resp, err := dhcpv6.NewRelayReplFromRelayForw(relay, reply)
+		if err != nil {
+			return
+		}
+		_, _ = conn.WriteTo(resp.ToBytes(), peer)
+	}
+}
+
+// newTestRelayed returns msg relayed by two relay agents, the one closest
+// to the client having relayID.
+func newTestRelayed(t *testing.T, msg *dhcpv6.Message, link net.IP, relayID dhcpv6.DUID, remoteID []byte) *dhcpv6.RelayMessage {
+	inner, err := dhcpv6.EncapsulateRelay(msg, dhcpv6.MessageTypeRelayForward, link, net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	inner.Options.Add(dhcpv6.OptRelayID(relayID))
+	inner.Options.Add(&dhcpv6.OptRemoteID{EnterpriseNumber: 16, RemoteID: remoteID})
+	outer, err := dhcpv6.EncapsulateRelay(inner, dhcpv6.MessageTypeRelayForward, net.ParseIP("2001:db8:ffff::1"), link)
+	require.NoError(t, err)
+	return outer
+}
+
+func TestLeaseRecorderRelayed(t *testing.T) {
+	s := NewMemoryLeaseStore()
+	h := NewLeaseRecorder(s).Handler(relayHandler(time.Hour))
+	relayID := &dhcpv6.DUIDLL{HWType: 1, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 0xff}}
+	link := net.ParseIP("2001:db8::1")
+	req := newTestRequest(t, dhcpv6.MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: testAddr}))
+
+	peer := &net.UDPAddr{IP: net.ParseIP("2001:db8:ffff::2"), Port: dhcpv6.DefaultServerPort}
+	h(&recordConn{}, peer, newTestRelayed(t, req, link, relayID, []byte("r1")))
+
+	l, err := s.Get(testAddr)
+	require.NoError(t, err)
+	require.True(t, link.Equal(l.LinkAddr))
+	require.True(t, peer.IP.Equal(l.RelayPeer))
+	require.NotNil(t, l.Relay)
+	require.True(t, net.ParseIP("2001:db8:ffff::1").Equal(l.Relay.LinkAddr))
+	// The client message is removed from the innermost relay message.
+	inner, ok := l.Relay.Options.RelayMessage().(*dhcpv6.RelayMessage)
+	require.True(t, ok)
+	require.True(t, relayID.Equal(inner.Options.RelayID()))
+	require.Nil(t, inner.Options.RelayMessage())
+}
@@ -0,0 +1,355 @@
+package server6
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Leasequery answers LEASEQUERY messages from a LeaseStore, as described in
+// RFC 5007 and RFC 5460. The store is typically filled by a LeaseRecorder.
+//
+// Queries received over UDP may be by address or client identifier. Bulk
+// queries, received over TCP, may also be by relay identifier, link address
+// or remote ID. They are answered with the active bindings of the matching
+// clients, one Client Data option per client.
+type Leasequery struct {
+	Store LeaseStore
+	// ServerID is the server identifier of the replies.
+	ServerID dhcpv6.DUID
+	// Allow, if not nil, returns whether to answer the query m sent by
+	// peer. Other queries are refused with the NotAllowed status.
+	Allow func(peer net.Addr, m *dhcpv6.Message) bool
+	// Logger, if not nil, logs failed queries and connections.
+	Logger Printfer
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewLeasequery returns a Leasequery that answers from store.
+func NewLeasequery(store LeaseStore, serverID dhcpv6.DUID) *Leasequery {
+	return &Leasequery{Store: store, ServerID: serverID}
+}
+
+func (q *Leasequery) time() time.Time {
+	if q.now != nil {
+		return q.now()
+	}
+	return time.Now()
+}
+
+func (q *Leasequery) printf(format string, v ...interface{}) {
+	if q.Logger != nil {
+		q.Logger.Printf(format, v...)
+	}
+}
+
+// Handler returns a Handler that answers LEASEQUERY messages, and passes the
+// other messages to next. Replies are sent to the requestor.
+func (q *Leasequery) Handler(next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		msg, ok := m.(*dhcpv6.Message)
+		if !ok || msg.MessageType != dhcpv6.MessageTypeLeaseQuery {
+			next(conn, peer, m)
+			return
+		}
+		var (
+			reply *dhcpv6.Message
+			err   error
+		)
+		if q.Allow != nil && !q.Allow(peer, msg) {
+			reply, err = q.statusReply(msg, &dhcpv6.OptStatusCode{StatusCode: iana.StatusNotAllowed})
+		} else {
+			reply, err = q.Reply(msg)
+		}
+		if err != nil {
+			q.printf("leasequery: cannot answer query from %s: %v", peer, err)
+			return
+		}
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+// Reply returns the LEASEQUERY-REPLY message answering the LEASEQUERY message
+// m, as described in RFC 5007, Section 4.3.3. It carries the bindings of the
+// client the query is about, if any. If the query is not restricted to a
+// link and the client has bindings on several links, it carries the list of
+// links instead, and the query should be sent again for one of them.
+func (q *Leasequery) Reply(m *dhcpv6.Message) (*dhcpv6.Message, error) {
+	// Other query types are only valid over TCP, RFC 5460, Section 5.2.
+	if lq := m.Options.LQQuery(); lq != nil && lq.QueryType != dhcpv6.LQQueryByAddress && lq.QueryType != dhcpv6.LQQueryByClientID {
+		return q.statusReply(m, &dhcpv6.OptStatusCode{StatusCode: iana.StatusUnknownQueryType})
+	}
+	now := q.time()
+	clients, status := q.lookup(m, now)
+	if status != nil {
+		return q.statusReply(m, status)
+	}
+	reply, err := dhcpv6.NewLeasequeryReply(m, dhcpv6.WithServerID(q.ServerID))
+	if err != nil {
+		return nil, err
+	}
+	if len(clients) == 0 {
+		return reply, nil
+	}
+	if links := leaseLinks(clients[0]); len(links) > 1 {
+		reply.AddOption(dhcpv6.OptLQClientLink(links...))
+	} else {
+		reply.AddOption(clientData(clients[0], now))
+	}
+	return reply, nil
+}
+
+// statusReply returns the LEASEQUERY-REPLY message answering m with status.
+func (q *Leasequery) statusReply(m *dhcpv6.Message, status *dhcpv6.OptStatusCode) (*dhcpv6.Message, error) {
+	return dhcpv6.NewLeasequeryReply(m, dhcpv6.WithServerID(q.ServerID), dhcpv6.WithOption(status))
+}
+
+// ServeBulk accepts bulk leasequery connections on l, as described in RFC
+// 5460, and serves each of them with ServeConn, until l fails. Servers listen
+// on TCP port 547.
+func (q *Leasequery) ServeBulk(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go q.ServeConn(conn)
+	}
+}
+
+// ServeConn answers the LEASEQUERY messages received on conn, one at a time,
+// until the requestor closes the connection. It then closes conn.
+//
+// The replies to a query are streamed, as described in RFC 5460, Section
+// 6.3: a LEASEQUERY-REPLY carrying the bindings of the first client, a
+// LEASEQUERY-DATA message for each other client, and a LEASEQUERY-DONE
+// message. Queries without bindings, or that fail, only get a
+// LEASEQUERY-REPLY message.
+func (q *Leasequery) ServeConn(conn net.Conn) {
+	defer conn.Close()
+	peer := conn.RemoteAddr()
+	for {
+		m, err := dhcpv6.ReadTCPMessage(conn)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				q.printf("bulk leasequery: cannot read from %s: %v", peer, err)
+			}
+			return
+		}
+		if m.MessageType != dhcpv6.MessageTypeLeaseQuery {
+			q.printf("bulk leasequery: ignoring %s from %s", m.MessageType, peer)
+			continue
+		}
+		if err := q.bulk(peer, m, func(reply *dhcpv6.Message) error {
+			return dhcpv6.WriteTCPMessage(conn, reply)
+		}); err != nil {
+			q.printf("bulk leasequery: cannot reply to %s: %v", peer, err)
+			return
+		}
+	}
+}
+
+// bulk sends the replies to the LEASEQUERY message m received from peer.
+func (q *Leasequery) bulk(peer net.Addr, m *dhcpv6.Message, send func(*dhcpv6.Message) error) error {
+	var (
+		now     = q.time()
+		clients [][]*Lease
+		status  *dhcpv6.OptStatusCode
+	)
+	if q.Allow != nil && !q.Allow(peer, m) {
+		status = &dhcpv6.OptStatusCode{StatusCode: iana.StatusNotAllowed}
+	} else {
+		clients, status = q.lookup(m, now)
+	}
+	if status != nil {
+		reply, err := q.statusReply(m, status)
+		if err != nil {
+			return err
+		}
+		return send(reply)
+	}
+	// The base time lets requestors ask for the changes since with an
+	// active leasequery, RFC 7653, Section 7.1.
+	reply, err := dhcpv6.NewLeasequeryReply(m, dhcpv6.WithServerID(q.ServerID), dhcpv6.WithOption(dhcpv6.OptLQBaseTime(now)))
+	if err != nil {
+		return err
+	}
+	if len(clients) == 0 {
+		return send(reply)
+	}
+	reply.AddOption(clientData(clients[0], now))
+	if err := send(reply); err != nil {
+		return err
+	}
+	for _, leases := range clients[1:] {
+		data := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeLeaseQueryData, TransactionID: m.TransactionID}
+		data.AddOption(dhcpv6.OptLQBaseTime(now))
+		data.AddOption(clientData(leases, now))
+		if err := send(data); err != nil {
+			return err
+		}
+	}
+	return send(&dhcpv6.Message{MessageType: dhcpv6.MessageTypeLeaseQueryDone, TransactionID: m.TransactionID})
+}
+
+// lookup returns the active bindings matching the LEASEQUERY message m,
+// grouped by client, or the status to reply with if the query cannot be
+// answered.
+func (q *Leasequery) lookup(m *dhcpv6.Message, now time.Time) ([][]*Lease, *dhcpv6.OptStatusCode) {
+	lq := m.Options.LQQuery()
+	if lq == nil {
+		return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing LQ Query option"}
+	}
+	// RFC 5007, Section 4.2.
+	if m.Options.ClientID() == nil {
+		return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Client ID option"}
+	}
+
+	var match func(l *Lease) bool
+	switch lq.QueryType {
+	case dhcpv6.LQQueryByAddress:
+		addr := lq.Options.IAAddress()
+		if addr == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing IA Address option"}
+		}
+		l, err := q.Store.Get(addr.IPv6Addr)
+		if errors.Is(err, ErrNoLease) {
+			return nil, nil
+		} else if err != nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusUnspecFail, StatusMessage: err.Error()}
+		}
+		// All the bindings of the client are returned.
+		match = func(o *Lease) bool { return o.ClientID.Equal(l.ClientID) }
+	case dhcpv6.LQQueryByClientID:
+		duid := lq.Options.ClientID()
+		if duid == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Client ID option"}
+		}
+		match = func(l *Lease) bool { return l.ClientID.Equal(duid) }
+	case dhcpv6.LQQueryByRelayID:
+		duid := lq.Options.RelayID()
+		if duid == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Relay ID option"}
+		}
+		match = func(l *Lease) bool {
+			return relayHas(l.Relay, func(ro dhcpv6.RelayOptions) bool {
+				id := ro.RelayID()
+				return id != nil && id.Equal(duid)
+			})
+		}
+	case dhcpv6.LQQueryByLinkAddress:
+		if lq.LinkAddress == nil || lq.LinkAddress.IsUnspecified() {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing link address"}
+		}
+		match = func(l *Lease) bool { return l.LinkAddr.Equal(lq.LinkAddress) }
+	case dhcpv6.LQQueryByRemoteID:
+		rid := lq.Options.RemoteID()
+		if rid == nil {
+			return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusMalformedQuery, StatusMessage: "missing Remote ID option"}
+		}
+		match = func(l *Lease) bool {
+			return relayHas(l.Relay, func(ro dhcpv6.RelayOptions) bool {
+				o := ro.RemoteID()
+				return o != nil && o.EnterpriseNumber == rid.EnterpriseNumber && bytes.Equal(o.RemoteID, rid.RemoteID)
+			})
+		}
+	default:
+		return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusUnknownQueryType}
+	}
+
+	all, err := q.Store.All()
+	if err != nil {
+		return nil, &dhcpv6.OptStatusCode{StatusCode: iana.StatusUnspecFail, StatusMessage: err.Error()}
+	}
+	var (
+		clients [][]*Lease
+		index   = make(map[string]int)
+	)
+	for _, l := range all {
+		if !l.Active(now) || !match(l) {
+			continue
+		}
+		// Other queries than by link address may be restricted to a
+		// link, RFC 5007, Section 4.1.2.1.
+		if lq.QueryType != dhcpv6.LQQueryByLinkAddress && lq.LinkAddress != nil &&
+			!lq.LinkAddress.IsUnspecified() && !lq.LinkAddress.Equal(l.LinkAddr) {
+			continue
+		}
+		key := string(l.ClientID.ToBytes())
+		i, ok := index[key]
+		if !ok {
+			i = len(clients)
+			index[key] = i
+			clients = append(clients, nil)
+		}
+		clients[i] = append(clients[i], l)
+	}
+	return clients, nil
+}
+
+// leaseLinks returns the links of leases, nil for the leases of clients that
+// are not relayed.
+func leaseLinks(leases []*Lease) []net.IP {
+	var links []net.IP
+	for _, l := range leases {
+		known := false
+		for _, link := range links {
+			known = known || link.Equal(l.LinkAddr)
+		}
+		if !known {
+			links = append(links, l.LinkAddr)
+		}
+	}
+	return links
+}
+
+// relayHas returns whether the options of relay, or of a relay message
+// nested in it, match.
+func relayHas(relay *dhcpv6.RelayMessage, match func(dhcpv6.RelayOptions) bool) bool {
+	for relay != nil {
+		if match(relay.Options) {
+			return true
+		}
+		relay, _ = relay.Options.RelayMessage().(*dhcpv6.RelayMessage)
+	}
+	return false
+}
+
+// clientData returns the Client Data option with the bindings of a client,
+// as described in RFC 5007, Section 4.1.2.2. Bindings that are no longer
+// valid get zero lifetimes.
+func clientData(leases []*Lease, now time.Time) *dhcpv6.OptClientData {
+	cd := &dhcpv6.OptClientData{}
+	cd.Options.Add(dhcpv6.OptClientID(leases[0].ClientID))
+	last := leases[0]
+	for _, l := range leases {
+		preferred := l.Updated.Add(l.PreferredLifetime).Sub(now)
+		if preferred < 0 {
+			preferred = 0
+		}
+		valid := l.Expiry().Sub(now)
+		if valid < 0 {
+			valid = 0
+		}
+		if l.Prefix != nil {
+			cd.Options.Add(&dhcpv6.OptIAPrefix{Prefix: l.Prefix, PreferredLifetime: preferred, ValidLifetime: valid})
+		} else {
+			cd.Options.Add(&dhcpv6.OptIAAddress{IPv6Addr: l.Addr, PreferredLifetime: preferred, ValidLifetime: valid})
+		}
+		if l.Updated.After(last.Updated) {
+			last = l
+		}
+	}
+	cd.Options.Add(dhcpv6.OptCLTTime(now.Sub(last.Updated)))
+	if last.Relay != nil {
+		cd.Options.Add(&dhcpv6.OptLQRelayData{PeerAddress: last.RelayPeer, RelayMessage: last.Relay})
+	}
+	return cd
+}
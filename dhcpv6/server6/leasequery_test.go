@@ -0,0 +1,320 @@
+package server6
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testLink     = net.ParseIP("2001:db8::1")
+	testClient1  = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 1}}
+	testClient2  = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 2}}
+	testRelayID1 = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 0xf1}}
+	testRelayID2 = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 0xf2}}
+	testServerID = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 0xff}}
+)
+
+// newTestLeasequery returns a Leasequery answering from a store with:
+//   - the address 2001:db8::10 and a prefix bound to testClient1, relayed
+//     by testRelayID1, on testLink;
+//   - the address 2001:db8::11 bound to testClient2, relayed by
+//     testRelayID2, on testLink;
+//   - the expired address 2001:db8::12 of testClient2.
+func newTestLeasequery(t *testing.T, now time.Time) *Leasequery {
+	s := NewMemoryLeaseStore()
+	relayed := func(relayID dhcpv6.DUID, remoteID string) (net.IP, *dhcpv6.RelayMessage) {
+		m, err := dhcpv6.NewMessage()
+		require.NoError(t, err)
+		return relayData(newTestRelayed(t, m, testLink, relayID, []byte(remoteID)))
+	}
+	l := Lease{ClientID: testClient1, PreferredLifetime: 30 * time.Minute, ValidLifetime: time.Hour, Updated: now.Add(-10 * time.Second)}
+	l.LinkAddr, l.Relay = relayed(testRelayID1, "r1")
+	l.RelayPeer = net.ParseIP("2001:db8:ffff::2")
+	l1, l2 := l, l
+	l1.Addr = testAddr
+	l2.Prefix = testPrefix
+	l2.Updated = now.Add(-time.Minute)
+
+	l = Lease{ClientID: testClient2, PreferredLifetime: 30 * time.Minute, ValidLifetime: time.Hour, Updated: now.Add(-time.Minute)}
+	l.LinkAddr, l.Relay = relayed(testRelayID2, "r2")
+	l3, l4 := l, l
+	l3.Addr = net.ParseIP("2001:db8::11")
+	l4.Addr = net.ParseIP("2001:db8::12")
+	l4.Updated = now.Add(-2 * time.Hour)
+	for _, l := range []*Lease{&l1, &l2, &l3, &l4} {
+		require.NoError(t, s.Put(l))
+	}
+	q := NewLeasequery(s, testServerID)
+	q.now = func() time.Time { return now }
+	return q
+}
+
+// leasequery sends a query of type typ with opts to conn, and returns the
+// replies.
+func leasequery(t *testing.T, conn net.Conn, typ dhcpv6.LQQueryType, link net.IP, opts ...dhcpv6.Option) ([]*dhcpv6.Message, error) {
+	lq := &dhcpv6.OptLQQuery{QueryType: typ, LinkAddress: link}
+	for _, o := range opts {
+		lq.Options.Add(o)
+	}
+	query, err := dhcpv6.NewLeasequery(lq, dhcpv6.WithClientID(testRelayID1))
+	require.NoError(t, err)
+	var replies []*dhcpv6.Message
+	err = nclient6.BulkLeasequery(context.Background(), conn, query, func(m *dhcpv6.Message) error {
+		require.Equal(t, query.TransactionID, m.TransactionID)
+		replies = append(replies, m)
+		return nil
+	})
+	return replies, err
+}
+
+// replyClients returns the clients of the replies.
+func replyClients(replies []*dhcpv6.Message) []dhcpv6.DUID {
+	var clients []dhcpv6.DUID
+	for _, m := range replies {
+		if cd := m.Options.ClientData(); cd != nil {
+			clients = append(clients, cd.Options.ClientID())
+		}
+	}
+	return clients
+}
+
+func TestLeasequeryHandler(t *testing.T) {
+	now := time.Now()
+	q := newTestLeasequery(t, now)
+	otherLink := net.ParseIP("2001:db8:2::1")
+	require.NoError(t, q.Store.Put(&Lease{
+		ClientID:      testClient1,
+		Addr:          net.ParseIP("2001:db8:2::10"),
+		ValidLifetime: time.Hour,
+		Updated:       now,
+		LinkAddr:      otherLink,
+	}))
+	q.Allow = func(peer net.Addr, m *dhcpv6.Message) bool {
+		duid := m.Options.LQQuery().Options.ClientID()
+		return duid == nil || !duid.Equal(testClient2)
+	}
+
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	s, err := NewServer("", nil, q.Handler(reconfigureHandler), WithConn(serverRawConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+	c, err := nclient6.NewWithConn(clientRawConn, net.HardwareAddr{2, 0, 0, 0, 0, 0xf1},
+		nclient6.WithRetry(1), nclient6.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	reply, err := c.LeasequeryByAddress(ctx, nil, testAddr, testLink)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeLeaseQueryReply, reply.MessageType)
+	require.True(t, testServerID.Equal(reply.Options.ServerID()))
+	require.True(t, testRelayID1.Equal(reply.Options.ClientID()))
+	require.Nil(t, reply.Options.Status())
+	cd := reply.Options.ClientData()
+	require.NotNil(t, cd)
+	require.True(t, testClient1.Equal(cd.Options.ClientID()))
+	require.Len(t, cd.Options.Addresses(), 1)
+	require.Len(t, cd.Options.Prefixes(), 1)
+	require.NotNil(t, cd.Options.LQRelayData())
+
+	// The client has bindings on two links.
+	reply, err = c.LeasequeryByClientID(ctx, nil, testClient1, nil)
+	require.NoError(t, err)
+	require.Nil(t, reply.Options.ClientData())
+	links := reply.Options.LQClientLink()
+	require.Len(t, links, 2)
+	require.True(t, testLink.Equal(links[0]))
+	require.True(t, otherLink.Equal(links[1]))
+
+	reply, err = c.LeasequeryByClientID(ctx, nil, testClient1, otherLink)
+	require.NoError(t, err)
+	cd = reply.Options.ClientData()
+	require.NotNil(t, cd)
+	require.Len(t, cd.Options.Addresses(), 1)
+	require.Nil(t, cd.Options.LQRelayData())
+
+	reply, err = c.LeasequeryByAddress(ctx, nil, net.ParseIP("2001:db8::13"), nil)
+	require.NoError(t, err)
+	require.Nil(t, reply.Options.ClientData())
+	require.Nil(t, reply.Options.Status())
+
+	reply, err = c.LeasequeryByClientID(ctx, nil, testClient2, nil)
+	require.NoError(t, err)
+	require.Equal(t, iana.StatusNotAllowed, reply.Options.Status().StatusCode)
+
+	// Bulk query types are refused over UDP.
+	lq := &dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByLinkAddress, LinkAddress: testLink}
+	query, err := dhcpv6.NewLeasequery(lq, dhcpv6.WithClientID(testRelayID1))
+	require.NoError(t, err)
+	reply, err = c.Leasequery(ctx, nil, query)
+	require.NoError(t, err)
+	require.Equal(t, iana.StatusUnknownQueryType, reply.Options.Status().StatusCode)
+}
+
+func TestBulkLeasequery(t *testing.T) {
+	now := time.Now()
+	q := newTestLeasequery(t, now)
+	client, server := net.Pipe()
+	defer client.Close()
+	go q.ServeConn(server)
+
+	replies, err := leasequery(t, client, dhcpv6.LQQueryByAddress, nil, &dhcpv6.OptIAAddress{IPv6Addr: testAddr})
+	require.NoError(t, err)
+	require.Len(t, replies, 1)
+	reply := replies[0]
+	require.Equal(t, dhcpv6.MessageTypeLeaseQueryReply, reply.MessageType)
+	require.True(t, testServerID.Equal(reply.Options.ServerID()))
+	require.True(t, testRelayID1.Equal(reply.Options.ClientID()))
+	cd := reply.Options.ClientData()
+	require.NotNil(t, cd)
+	require.True(t, testClient1.Equal(cd.Options.ClientID()))
+	require.Len(t, cd.Options.Addresses(), 1)
+	require.True(t, testAddr.Equal(cd.Options.Addresses()[0].IPv6Addr))
+	require.Equal(t, 30*time.Minute-10*time.Second, cd.Options.Addresses()[0].PreferredLifetime)
+	require.Equal(t, time.Hour-10*time.Second, cd.Options.Addresses()[0].ValidLifetime)
+	require.Len(t, cd.Options.Prefixes(), 1)
+	require.Equal(t, testPrefix.String(), cd.Options.Prefixes()[0].Prefix.String())
+	cltt, ok := cd.Options.CLTTime()
+	require.True(t, ok)
+	require.Equal(t, 10*time.Second, cltt)
+	rd := cd.Options.LQRelayData()
+	require.NotNil(t, rd)
+	require.True(t, net.ParseIP("2001:db8:ffff::2").Equal(rd.PeerAddress))
+	inner, ok := rd.RelayMessage.Options.RelayMessage().(*dhcpv6.RelayMessage)
+	require.True(t, ok)
+	require.True(t, testLink.Equal(inner.LinkAddr))
+
+	for _, tt := range []struct {
+		name string
+		typ  dhcpv6.LQQueryType
+		link net.IP
+		opts []dhcpv6.Option
+		want []dhcpv6.DUID
+	}{
+		{
+			name: "delegated prefix",
+			typ:  dhcpv6.LQQueryByAddress,
+			opts: []dhcpv6.Option{&dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8:1::1")}},
+			want: []dhcpv6.DUID{testClient1},
+		},
+		{
+			name: "unbound address",
+			typ:  dhcpv6.LQQueryByAddress,
+			opts: []dhcpv6.Option{&dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::13")}},
+		},
+		{
+			name: "other link",
+			typ:  dhcpv6.LQQueryByAddress,
+			link: net.ParseIP("2001:db8:2::1"),
+			opts: []dhcpv6.Option{&dhcpv6.OptIAAddress{IPv6Addr: testAddr}},
+		},
+		{
+			name: "client identifier",
+			typ:  dhcpv6.LQQueryByClientID,
+			opts: []dhcpv6.Option{dhcpv6.OptClientID(testClient2)},
+			want: []dhcpv6.DUID{testClient2},
+		},
+		{
+			name: "relay identifier",
+			typ:  dhcpv6.LQQueryByRelayID,
+			opts: []dhcpv6.Option{dhcpv6.OptRelayID(testRelayID2)},
+			want: []dhcpv6.DUID{testClient2},
+		},
+		{
+			name: "unknown relay identifier",
+			typ:  dhcpv6.LQQueryByRelayID,
+			opts: []dhcpv6.Option{dhcpv6.OptRelayID(testServerID)},
+		},
+		{
+			name: "link address",
+			typ:  dhcpv6.LQQueryByLinkAddress,
+			link: testLink,
+			want: []dhcpv6.DUID{testClient1, testClient2},
+		},
+		{
+			name: "remote ID",
+			typ:  dhcpv6.LQQueryByRemoteID,
+			opts: []dhcpv6.Option{&dhcpv6.OptRemoteID{EnterpriseNumber: 16, RemoteID: []byte("r1")}},
+			want: []dhcpv6.DUID{testClient1},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			replies, err := leasequery(t, client, tt.typ, tt.link, tt.opts...)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, replyClients(replies))
+			require.Equal(t, dhcpv6.MessageTypeLeaseQueryReply, replies[0].MessageType)
+			for _, m := range replies[1:] {
+				require.Equal(t, dhcpv6.MessageTypeLeaseQueryData, m.MessageType)
+			}
+		})
+	}
+
+	// The expired binding is not returned.
+	replies, err = leasequery(t, client, dhcpv6.LQQueryByClientID, nil, dhcpv6.OptClientID(testClient2))
+	require.NoError(t, err)
+	require.Len(t, replies[0].Options.ClientData().Options.Addresses(), 1)
+
+	for _, tt := range []struct {
+		name string
+		typ  dhcpv6.LQQueryType
+		want iana.StatusCode
+	}{
+		{name: "unknown query type", typ: 9, want: iana.StatusUnknownQueryType},
+		{name: "missing option", typ: dhcpv6.LQQueryByRelayID, want: iana.StatusMalformedQuery},
+		{name: "missing link address", typ: dhcpv6.LQQueryByLinkAddress, want: iana.StatusMalformedQuery},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := leasequery(t, client, tt.typ, nil)
+			var status *nclient6.ErrLeasequeryStatus
+			require.True(t, errors.As(err, &status), "got %v", err)
+			require.Equal(t, tt.want, status.Status.StatusCode)
+		})
+	}
+}
+
+func TestServeBulk(t *testing.T) {
+	q := newTestLeasequery(t, time.Now())
+	q.Allow = func(peer net.Addr, m *dhcpv6.Message) bool {
+		return m.Options.LQQuery().QueryType != dhcpv6.LQQueryByLinkAddress
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() { _ = q.ServeBulk(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	replies, err := leasequery(t, conn, dhcpv6.LQQueryByRelayID, nil, dhcpv6.OptRelayID(testRelayID1))
+	require.NoError(t, err)
+	require.Equal(t, []dhcpv6.DUID{testClient1}, replyClients(replies))
+
+	_, err = leasequery(t, conn, dhcpv6.LQQueryByLinkAddress, testLink)
+	var status *nclient6.ErrLeasequeryStatus
+	require.True(t, errors.As(err, &status), "got %v", err)
+	require.Equal(t, iana.StatusNotAllowed, status.Status.StatusCode)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	query, err := dhcpv6.NewLeasequery(&dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByLinkAddress})
+	require.NoError(t, err)
+	err = nclient6.BulkLeasequery(ctx, conn, query, func(*dhcpv6.Message) error { return nil })
+	require.Equal(t, context.Canceled, err)
+
+	solicit, err := dhcpv6.NewMessage()
+	require.NoError(t, err)
+	err = nclient6.BulkLeasequery(context.Background(), conn, solicit, func(*dhcpv6.Message) error { return nil })
+	require.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package server6
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// ErrNoReconfigureKey is returned by Reconfigurer.Reconfigure if the client
+// did not get a reconfigure key.
+var ErrNoReconfigureKey = errors.New("client has no reconfigure key")
+
+// Reconfigurer sends Reconfigure messages to clients, as described in RFC
+// 8415 Section 18.3.11, to make them renew, rebind or request information
+// immediately, e.g. after a configuration change.
+//
+// It wraps a Handler: the replies the handler sends to clients that accept
+// Reconfigure messages carry the Reconfigure Accept option and the
+// reconfigure key of the client, which authenticates the Reconfigure
+// messages as described in RFC 8415 Section 20.4.
+type Reconfigurer struct {
+	counter dhcpv6.ReplayCounter
+
+	mu sync.Mutex
+	// clients are the clients that got a reconfigure key, by DUID.
+	clients map[string]*reconfigureClient
+}
+
+type reconfigureClient struct {
+	key      []byte
+	serverID dhcpv6.DUID
+	conn     net.PacketConn
+	peer     net.Addr
+	// relay is the last Relay-forward message the client was reached
+	// through, if any.
+	relay *dhcpv6.RelayMessage
+}
+
+// NewReconfigurer returns a new Reconfigurer.
+func NewReconfigurer() *Reconfigurer {
+	return &Reconfigurer{clients: make(map[string]*reconfigureClient)}
+}
+
+// Handler returns a Handler that calls next, and adds the reconfigure key to
+// the replies next sends.
+func (r *Reconfigurer) Handler(next Handler) Handler {
+	return func(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+		req, err := m.GetInnerMessage()
+		if err != nil {
+			next(conn, peer, m)
+			return
+		}
+		relay, _ := m.(*dhcpv6.RelayMessage)
+		next(&reconfigureConn{PacketConn: conn, r: r, req: req, relay: relay}, peer, m)
+	}
+}
+
+// reconfigureConn amends the replies to req.
+type reconfigureConn struct {
+	net.PacketConn
+	r     *Reconfigurer
+	req   *dhcpv6.Message
+	relay *dhcpv6.RelayMessage
+}
+
+func (c *reconfigureConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if reply, err := dhcpv6.FromBytes(b); err == nil {
+		if inner, err := reply.GetInnerMessage(); err == nil && c.r.handle(c, addr, inner) {
+			b = reply.ToBytes()
+		}
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// handle acts on a reply sent through c to addr, and returns whether it
+// modified the reply.
+func (r *Reconfigurer) handle(c *reconfigureConn, addr net.Addr, reply *dhcpv6.Message) bool {
+	duid := c.req.Options.ClientID()
+	if duid == nil || reply.Type() != dhcpv6.MessageTypeReply {
+		return false
+	}
+	key := string(duid.ToBytes())
+
+	switch c.req.Type() {
+	case dhcpv6.MessageTypeRelease:
+		r.mu.Lock()
+		delete(r.clients, key)
+		r.mu.Unlock()
+		return false
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest, dhcpv6.MessageTypeRenew,
+		dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeInformationRequest:
+	default:
+		return false
+	}
+	serverID := reply.Options.ServerID()
+	if !c.req.Options.ReconfigureAccept() || serverID == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	cl, ok := r.clients[key]
+	if !ok {
+		k, err := dhcpv6.NewReconfigureKey()
+		if err != nil {
+			r.mu.Unlock()
+			return false
+		}
+		cl = &reconfigureClient{key: k}
+		r.clients[key] = cl
+	}
+	cl.serverID = serverID
+	cl.conn = c.PacketConn
+	cl.peer = addr
+	cl.relay = c.relay
+	r.mu.Unlock()
+
+	// RFC 8415 Section 18.3.1: the server includes Reconfigure Accept if
+	// it wants the client to accept Reconfigure messages.
+	reply.UpdateOption(dhcpv6.OptReconfigureAccept())
+	reply.UpdateOption(dhcpv6.OptReconfigureKey(cl.key, r.counter.Next()))
+	return true
+}
+
+// Reconfigure sends a Reconfigure message to the client with the given DUID,
+// asking it to start an exchange of the given type: dhcpv6.MessageTypeRenew,
+// dhcpv6.MessageTypeRebind or dhcpv6.MessageTypeInformationRequest.
+//
+// The message is sent to the address, or through the relay agents, of the
+// last reply sent to the client. Retransmissions are left to the caller.
+func (r *Reconfigurer) Reconfigure(clientID dhcpv6.DUID, msgType dhcpv6.MessageType) error {
+	r.mu.Lock()
+	cl, ok := r.clients[string(clientID.ToBytes())]
+	var c reconfigureClient
+	if ok {
+		c = *cl
+	}
+	r.mu.Unlock()
+	if !ok {
+		return ErrNoReconfigureKey
+	}
+
+	m, err := dhcpv6.NewReconfigure(c.serverID, clientID, msgType)
+	if err != nil {
+		return err
+	}
+	if err := dhcpv6.SignReconfigure(m, c.key, r.counter.Next()); err != nil {
+		return err
+	}
+	var out dhcpv6.DHCPv6 = m
+	if c.relay != nil {
+		if out, err = dhcpv6.NewRelayReplFromRelayForw(c.relay, m); err != nil {
+			return err
+		}
+	}
+	_, err = c.conn.WriteTo(out.ToBytes(), c.peer)
+	return err
+}
@@ -0,0 +1,146 @@
+package server6
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+var testServerDUID = &dhcpv6.DUIDLL{HWType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{2, 0, 0, 0, 0, 0xff}}
+
+// reconfigureHandler replies to requests with the server ID, directly or
+// through the relay agents the requests came through.
+func reconfigureHandler(conn net.PacketConn, peer net.Addr, m dhcpv6.DHCPv6) {
+	msg, err := m.GetInnerMessage()
+	if err != nil {
+		return
+	}
+	reply, err := dhcpv6.NewReplyFromMessage(msg, dhcpv6.WithServerID(testServerDUID))
+	if err != nil {
+		return
+	}
+	var out dhcpv6.DHCPv6 = reply
+	if relay, ok := m.(*dhcpv6.RelayMessage); ok {
+		if out, err = dhcpv6.NewRelayReplFromRelayForw(relay, reply); err != nil {
+			return
+		}
+	}
+	_, _ = conn.WriteTo(out.ToBytes(), peer)
+}
+
+func TestReconfigurer(t *testing.T) {
+	clientRawConn, serverRawConn, err := socketpair.PacketSocketPair()
+	require.NoError(t, err)
+	r := NewReconfigurer()
+	s, err := NewServer("", nil, r.Handler(reconfigureHandler), WithConn(serverRawConn))
+	require.NoError(t, err)
+	go func() { _ = s.Serve() }()
+	defer s.Close()
+
+	c, err := nclient6.NewWithConn(clientRawConn, net.HardwareAddr{2, 0, 0, 0, 0, 1},
+		nclient6.WithRetry(1), nclient6.WithTimeout(time.Second))
+	require.NoError(t, err)
+	defer c.Close()
+
+	reply, err := c.RapidSolicit(context.Background(), dhcpv6.WithReconfigureAccept)
+	require.NoError(t, err)
+	require.True(t, reply.Options.ReconfigureAccept())
+	key := reply.Options.ReconfigureKey()
+	require.Len(t, key, dhcpv6.ReconfigureKeyLen)
+	clientID := reply.Options.ClientID()
+
+	// Unauthenticated and replayed messages are discarded.
+	forged, err := dhcpv6.NewReconfigure(testServerDUID, clientID, dhcpv6.MessageTypeRenew)
+	require.NoError(t, err)
+	require.NoError(t, dhcpv6.SignReconfigure(forged, []byte("0123456789abcdef"), ^uint64(0)))
+	_, err = serverRawConn.WriteTo(forged.ToBytes(), nil)
+	require.NoError(t, err)
+	replayed, err := dhcpv6.NewReconfigure(testServerDUID, clientID, dhcpv6.MessageTypeRenew)
+	require.NoError(t, err)
+	require.NoError(t, dhcpv6.SignReconfigure(replayed, key, reply.Options.Auth().ReplayDetection))
+	_, err = serverRawConn.WriteTo(replayed.ToBytes(), nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitReconfigure(ctx, reply)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	for _, typ := range []dhcpv6.MessageType{dhcpv6.MessageTypeRenew, dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeInformationRequest} {
+		require.NoError(t, r.Reconfigure(clientID, typ))
+		got, err := c.Reconfigure(context.Background(), reply, dhcpv6.WithReconfigureAccept)
+		require.NoError(t, err)
+		require.Equal(t, dhcpv6.MessageTypeReply, got.MessageType)
+		// The key does not change.
+		require.Equal(t, key, got.Options.ReconfigureKey())
+	}
+	require.Error(t, r.Reconfigure(clientID, dhcpv6.MessageTypeRequest))
+
+	// Clients that release their leases, or that do not accept Reconfigure
+	// messages, cannot be reconfigured.
+	release, err := dhcpv6.NewRenewFromReply(reply)
+	require.NoError(t, err)
+	release.MessageType = dhcpv6.MessageTypeRelease
+	_, err = c.SendAndRead(context.Background(), nclient6.AllDHCPRelayAgentsAndServers, release, nil)
+	require.NoError(t, err)
+	require.Equal(t, ErrNoReconfigureKey, r.Reconfigure(clientID, dhcpv6.MessageTypeRenew))
+	reply, err = c.RapidSolicit(context.Background())
+	require.NoError(t, err)
+	require.False(t, reply.Options.ReconfigureAccept())
+	require.Nil(t, reply.Options.ReconfigureKey())
+	require.Equal(t, ErrNoReconfigureKey, r.Reconfigure(clientID, dhcpv6.MessageTypeRenew))
+	_, err = c.WaitReconfigure(context.Background(), reply)
+	require.Equal(t, nclient6.ErrNoReconfigureKey, err)
+}
+
+// rawConn records the messages written to it.
+type rawConn struct {
+	net.PacketConn
+	sent [][]byte
+	addr []net.Addr
+}
+
+func (c *rawConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.sent = append(c.sent, b)
+	c.addr = append(c.addr, addr)
+	return len(b), nil
+}
+
+func TestReconfigurerRelay(t *testing.T) {
+	r := NewReconfigurer()
+	h := r.Handler(reconfigureHandler)
+	req := newTestRequest(t, dhcpv6.MessageTypeRequest, net.HardwareAddr{2, 0, 0, 0, 0, 1}, dhcpv6.WithReconfigureAccept)
+	relay, err := dhcpv6.EncapsulateRelay(req, dhcpv6.MessageTypeRelayForward, net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	agent := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: dhcpv6.DefaultServerPort}
+
+	conn := &rawConn{}
+	h(conn, agent, relay)
+	require.Len(t, conn.sent, 1)
+	replyRelay, err := dhcpv6.RelayMessageFromBytes(conn.sent[0])
+	require.NoError(t, err)
+	reply, err := replyRelay.GetInnerMessage()
+	require.NoError(t, err)
+	key := reply.Options.ReconfigureKey()
+	require.NotNil(t, key)
+
+	require.NoError(t, r.Reconfigure(req.Options.ClientID(), dhcpv6.MessageTypeRenew))
+	require.Len(t, conn.sent, 2)
+	require.Equal(t, agent, conn.addr[1])
+	out, err := dhcpv6.RelayMessageFromBytes(conn.sent[1])
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeRelayReply, out.MessageType)
+	require.True(t, out.PeerAddr.Equal(net.ParseIP("fe80::1")))
+	inner := out.Options.RelayMessage()
+	require.NotNil(t, inner)
+	m, err := dhcpv6.VerifyReconfigure(inner.ToBytes(), key)
+	require.NoError(t, err)
+	require.True(t, testServerDUID.Equal(m.Options.ServerID()))
+	require.Equal(t, dhcpv6.MessageTypeRenew, m.Options.ReconfigureMessage())
+}
@@ -40,8 +40,8 @@ const (
 	_                             MessageType = 19
 	MessageTypeDHCPv4Query        MessageType = 20
 	MessageTypeDHCPv4Response     MessageType = 21
-	_                             MessageType = 22
-	_                             MessageType = 23
+	MessageTypeActiveLeaseQuery   MessageType = 22
+	MessageTypeStartTLS           MessageType = 23
 	_                             MessageType = 24
 	_                             MessageType = 25
 	_                             MessageType = 26
@@ -88,6 +88,8 @@ var messageTypeToStringMap = map[MessageType]string{
 	MessageTypeLeaseQueryData:     "LEASEQUERY-DATA",
 	MessageTypeDHCPv4Query:        "DHCPv4-QUERY",
 	MessageTypeDHCPv4Response:     "DHCPv4-RESPONSE",
+	MessageTypeActiveLeaseQuery:   "ACTIVELEASEQUERY",
+	MessageTypeStartTLS:           "STARTTLS",
 	MessageTypeAddrRegInform:      "ADDR-REG-INFORM",
 	MessageTypeAddrRegReply:       "ADDR-REG-REPLY",
 }
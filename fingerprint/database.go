@@ -0,0 +1,248 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Weights of the individual attributes when computing the confidence of a
+// guess. They add up to 1.
+const (
+	weightRequestedOptions = 0.6
+	weightVendorClass      = 0.3
+	weightUserClass        = 0.1
+)
+
+// minSimilarity is the minimum similarity between the requested options of a
+// signature and of a fingerprint for them to be considered a match.
+const minSimilarity = 0.8
+
+// Signature describes the fingerprint of a known device. Empty attributes
+// match any fingerprint.
+type Signature struct {
+	// Protocol restricts the signature to DHCPv4 or DHCPv6. ProtocolAny
+	// (0) matches both.
+	Protocol Protocol `json:"protocol,omitempty"`
+
+	// RequestedOptions is a comma-separated list of decimal option codes,
+	// in the order the device requests them.
+	RequestedOptions string `json:"requested_options,omitempty"`
+
+	// VendorClass is matched as a prefix of the fingerprint vendor class.
+	VendorClass string `json:"vendor_class,omitempty"`
+
+	// EnterpriseNumber, if not 0, must match the DHCPv6 vendor class
+	// enterprise number.
+	EnterpriseNumber uint32 `json:"enterprise_number,omitempty"`
+
+	// UserClass must be equal to one of the fingerprint user classes.
+	UserClass string `json:"user_class,omitempty"`
+
+	OS         string `json:"os"`
+	DeviceType string `json:"device_type,omitempty"`
+
+	codes []uint16
+}
+
+// Guess is a possible identification of a device.
+type Guess struct {
+	OS         string
+	DeviceType string
+
+	// Confidence is between 0 and 1.
+	Confidence float64
+
+	// Signature is the signature the guess was derived from.
+	Signature *Signature
+}
+
+// Matcher matches fingerprints against known devices.
+//
+// Database is the implementation provided by this package, but callers may
+// plug in their own, e.g. backed by a remote service.
+type Matcher interface {
+	// Match returns all plausible guesses for fp, best first.
+	Match(fp *Fingerprint) []Guess
+}
+
+// Identify returns the best guess of m for fp, if any.
+func Identify(m Matcher, fp *Fingerprint) (Guess, bool) {
+	guesses := m.Match(fp)
+	if len(guesses) == 0 {
+		return Guess{}, false
+	}
+	return guesses[0], true
+}
+
+// Database is an in-memory signature database. It is safe for concurrent
+// use.
+type Database struct {
+	mu         sync.RWMutex
+	signatures []*Signature
+}
+
+// NewDatabase returns a database holding the given signatures.
+func NewDatabase(sigs ...Signature) (*Database, error) {
+	db := &Database{}
+	for _, s := range sigs {
+		if err := db.Add(s); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Load reads a JSON signature database from the file at path.
+//
+// The file holds a JSON array of signatures, for example:
+//
+//	[
+//	  {"requested_options": "1,3,6,15,31,33,43,44,46,47,119,121,249,252",
+//	   "vendor_class": "MSFT 5.0", "os": "Windows", "device_type": "PC"},
+//	  {"protocol": 6, "requested_options": "23,24,39,242,243", "os": "macOS"}
+//	]
+func Load(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads a JSON signature database from r. See Load for the format.
+func Read(r io.Reader) (*Database, error) {
+	var sigs []Signature
+	if err := json.NewDecoder(r).Decode(&sigs); err != nil {
+		return nil, fmt.Errorf("cannot decode signature database: %w", err)
+	}
+	return NewDatabase(sigs...)
+}
+
+// Add adds a signature to the database.
+func (db *Database) Add(s Signature) error {
+	if s.OS == "" && s.DeviceType == "" {
+		return fmt.Errorf("signature %q has neither OS nor device type", s.RequestedOptions)
+	}
+	codes, err := ParseCodes(s.RequestedOptions)
+	if err != nil {
+		return err
+	}
+	s.codes = codes
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.signatures = append(db.signatures, &s)
+	return nil
+}
+
+// Len returns the number of signatures in the database.
+func (db *Database) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return len(db.signatures)
+}
+
+// Match implements Matcher.Match.
+//
+// A signature matches a fingerprint if all of its non-empty attributes match.
+// The confidence reflects both how many attributes the signature specifies
+// and how closely the requested options match: an exact match of the
+// requested options, vendor class and user class yields a confidence of 1.
+func (db *Database) Match(fp *Fingerprint) []Guess {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var guesses []Guess
+	for _, s := range db.signatures {
+		if c, ok := s.score(fp); ok {
+			guesses = append(guesses, Guess{
+				OS:         s.OS,
+				DeviceType: s.DeviceType,
+				Confidence: c,
+				Signature:  s,
+			})
+		}
+	}
+	sort.SliceStable(guesses, func(i, j int) bool {
+		return guesses[i].Confidence > guesses[j].Confidence
+	})
+	return guesses
+}
+
+func (s *Signature) score(fp *Fingerprint) (float64, bool) {
+	if s.Protocol != ProtocolAny && s.Protocol != fp.Protocol {
+		return 0, false
+	}
+	if s.EnterpriseNumber != 0 && s.EnterpriseNumber != fp.EnterpriseNumber {
+		return 0, false
+	}
+
+	var (
+		score     float64
+		specified bool
+	)
+	if len(s.codes) > 0 {
+		sim := similarity(s.codes, fp.RequestedOptions)
+		if sim < minSimilarity {
+			return 0, false
+		}
+		score += weightRequestedOptions * sim
+		specified = true
+	}
+	if s.VendorClass != "" {
+		if !strings.HasPrefix(fp.VendorClass, s.VendorClass) {
+			return 0, false
+		}
+		score += weightVendorClass
+		specified = true
+	}
+	if s.UserClass != "" {
+		found := false
+		for _, uc := range fp.UserClasses {
+			if uc == s.UserClass {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+		score += weightUserClass
+		specified = true
+	}
+	return score, specified
+}
+
+// similarity returns how similar two ordered lists of option codes are, as
+// the length of their longest common subsequence divided by the length of
+// the longest list. Equal lists have a similarity of 1.
+func similarity(a, b []uint16) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return float64(prev[len(b)]) / float64(longest)
+}
@@ -0,0 +1,160 @@
+// Package fingerprint extracts device fingerprints from DHCPv4 and DHCPv6
+// client messages and matches them against a local signature database.
+//
+// Clients tend to be very consistent about which options they request and in
+// which order, and about the vendor and user classes they send. The
+// combination of these attributes is usually enough to tell the operating
+// system or type of a device.
+//
+// Example usage:
+//
+//	db, err := fingerprint.Load("/etc/dhcp/fingerprints.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fp := fingerprint.FromDHCPv4(discover)
+//	if g, ok := fingerprint.Identify(db, fp); ok {
+//		log.Printf("%s looks like %s (%s), confidence %.2f", discover.ClientHWAddr, g.OS, g.DeviceType, g.Confidence)
+//	}
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// Protocol identifies the DHCP version a fingerprint was extracted from.
+type Protocol uint8
+
+// Protocols that fingerprints can be extracted from.
+const (
+	ProtocolAny    Protocol = 0
+	ProtocolDHCPv4 Protocol = 4
+	ProtocolDHCPv6 Protocol = 6
+)
+
+// String returns the protocol name.
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolAny:
+		return "any"
+	case ProtocolDHCPv4:
+		return "dhcpv4"
+	case ProtocolDHCPv6:
+		return "dhcpv6"
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(p))
+}
+
+// Fingerprint is the canonical set of client attributes used to identify a
+// device.
+type Fingerprint struct {
+	Protocol Protocol
+
+	// RequestedOptions is the DHCPv4 Parameter Request List (option 55) or
+	// the DHCPv6 Option Request Option, in the order sent by the client.
+	RequestedOptions []uint16
+
+	// VendorClass is the DHCPv4 Vendor Class Identifier (option 60), or
+	// the data of the first DHCPv6 Vendor Class option.
+	VendorClass string
+
+	// EnterpriseNumber is the enterprise number of the first DHCPv6 Vendor
+	// Class option. It is always 0 for DHCPv4.
+	EnterpriseNumber uint32
+
+	// UserClasses are the DHCPv4 User Class (option 77) or DHCPv6 User
+	// Class values.
+	UserClasses []string
+}
+
+// FromDHCPv4 extracts the fingerprint of a DHCPv4 client message.
+func FromDHCPv4(d *dhcpv4.DHCPv4) *Fingerprint {
+	fp := &Fingerprint{
+		Protocol:    ProtocolDHCPv4,
+		VendorClass: d.ClassIdentifier(),
+		UserClasses: d.UserClass(),
+	}
+	for _, c := range d.ParameterRequestList() {
+		fp.RequestedOptions = append(fp.RequestedOptions, uint16(c.Code()))
+	}
+	return fp
+}
+
+// FromDHCPv6 extracts the fingerprint of a DHCPv6 client message. Relay
+// messages are decapsulated first.
+func FromDHCPv6(d dhcpv6.DHCPv6) (*Fingerprint, error) {
+	m, err := d.GetInnerMessage()
+	if err != nil {
+		return nil, err
+	}
+	fp := &Fingerprint{Protocol: ProtocolDHCPv6}
+	for _, c := range m.Options.RequestedOptions() {
+		fp.RequestedOptions = append(fp.RequestedOptions, uint16(c))
+	}
+	if vcs := m.Options.VendorClasses(); len(vcs) > 0 {
+		fp.EnterpriseNumber = vcs[0].EnterpriseNumber
+		var data []string
+		for _, d := range vcs[0].Data {
+			data = append(data, string(d))
+		}
+		fp.VendorClass = strings.Join(data, " ")
+	}
+	for _, uc := range m.Options.UserClasses() {
+		fp.UserClasses = append(fp.UserClasses, string(uc))
+	}
+	return fp, nil
+}
+
+// RequestedOptionsString returns the requested options as a comma-separated
+// list of decimal codes, e.g. "1,3,6,15". This is the format commonly used by
+// fingerprint databases.
+func (fp *Fingerprint) RequestedOptionsString() string {
+	return formatCodes(fp.RequestedOptions)
+}
+
+// String returns the canonical representation of the fingerprint.
+func (fp *Fingerprint) String() string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%s prl=%s", fp.Protocol, fp.RequestedOptionsString())
+	if fp.EnterpriseNumber != 0 {
+		fmt.Fprintf(&s, " enterprise=%d", fp.EnterpriseNumber)
+	}
+	if fp.VendorClass != "" {
+		fmt.Fprintf(&s, " vendor=%q", fp.VendorClass)
+	}
+	if len(fp.UserClasses) > 0 {
+		fmt.Fprintf(&s, " user=%q", strings.Join(fp.UserClasses, ","))
+	}
+	return s.String()
+}
+
+func formatCodes(codes []uint16) string {
+	s := make([]string, 0, len(codes))
+	for _, c := range codes {
+		s = append(s, strconv.Itoa(int(c)))
+	}
+	return strings.Join(s, ",")
+}
+
+// ParseCodes parses a comma-separated list of decimal option codes as
+// returned by Fingerprint.RequestedOptionsString.
+func ParseCodes(s string) ([]uint16, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var codes []uint16
+	for _, f := range strings.Split(s, ",") {
+		c, err := strconv.ParseUint(strings.TrimSpace(f), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid option code %q: %w", f, err)
+		}
+		codes = append(codes, uint16(c))
+	}
+	return codes, nil
+}
@@ -0,0 +1,158 @@
+package fingerprint
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+const windowsPRL = "1,3,6,15,31,33,43,44,46,47,119,121,249,252"
+
+var testDatabase = `[
+  {"protocol": 4, "requested_options": "` + windowsPRL + `", "vendor_class": "MSFT 5.0", "os": "Windows", "device_type": "PC"},
+  {"requested_options": "1,121,3,6,15,119,252,95,44,46", "os": "macOS", "device_type": "Laptop"},
+  {"vendor_class": "android-dhcp-", "os": "Android", "device_type": "Phone"},
+  {"protocol": 6, "requested_options": "23,24,39", "enterprise_number": 311, "os": "Windows", "device_type": "PC"}
+]`
+
+func windowsDiscover(t *testing.T) *dhcpv4.DHCPv4 {
+	codes, err := ParseCodes(windowsPRL)
+	require.NoError(t, err)
+	var prl []dhcpv4.OptionCode
+	for _, c := range codes {
+		prl = append(prl, dhcpv4.GenericOptionCode(c))
+	}
+	d, err := dhcpv4.NewDiscovery(net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		dhcpv4.WithOption(dhcpv4.OptParameterRequestList(prl...)),
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("MSFT 5.0")),
+	)
+	require.NoError(t, err)
+	return d
+}
+
+func TestFromDHCPv4(t *testing.T) {
+	d := windowsDiscover(t)
+	d.UpdateOption(dhcpv4.OptRFC3004UserClass([]string{"corp"}))
+
+	fp := FromDHCPv4(d)
+	require.Equal(t, ProtocolDHCPv4, fp.Protocol)
+	require.Equal(t, windowsPRL, fp.RequestedOptionsString())
+	require.Equal(t, "MSFT 5.0", fp.VendorClass)
+	require.Equal(t, []string{"corp"}, fp.UserClasses)
+	require.Equal(t, `dhcpv4 prl=`+windowsPRL+` vendor="MSFT 5.0" user="corp"`, fp.String())
+}
+
+func TestFromDHCPv6(t *testing.T) {
+	m, err := dhcpv6.NewMessage(
+		dhcpv6.WithRequestedOptions(dhcpv6.OptionDNSRecursiveNameServer, dhcpv6.OptionDomainSearchList, dhcpv6.OptionFQDN),
+		dhcpv6.WithOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 311, Data: [][]byte{[]byte("MSFT 5.0")}}),
+	)
+	require.NoError(t, err)
+	relay, err := dhcpv6.EncapsulateRelay(m, dhcpv6.MessageTypeRelayForward, net.IPv6loopback, net.IPv6loopback)
+	require.NoError(t, err)
+
+	fp, err := FromDHCPv6(relay)
+	require.NoError(t, err)
+	require.Equal(t, ProtocolDHCPv6, fp.Protocol)
+	require.Equal(t, []uint16{23, 24, 39}, fp.RequestedOptions)
+	require.Equal(t, uint32(311), fp.EnterpriseNumber)
+	require.Equal(t, "MSFT 5.0", fp.VendorClass)
+	require.Equal(t, `dhcpv6 prl=23,24,39 enterprise=311 vendor="MSFT 5.0"`, fp.String())
+}
+
+func TestParseCodes(t *testing.T) {
+	codes, err := ParseCodes(" 1, 3,6 ")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{1, 3, 6}, codes)
+
+	codes, err = ParseCodes("")
+	require.NoError(t, err)
+	require.Nil(t, codes)
+
+	_, err = ParseCodes("1,x")
+	require.Error(t, err)
+	_, err = ParseCodes("70000")
+	require.Error(t, err)
+}
+
+func TestDatabaseMatch(t *testing.T) {
+	db, err := Read(strings.NewReader(testDatabase))
+	require.NoError(t, err)
+	require.Equal(t, 4, db.Len())
+
+	// Exact match on requested options and vendor class.
+	g, ok := Identify(db, FromDHCPv4(windowsDiscover(t)))
+	require.True(t, ok)
+	require.Equal(t, "Windows", g.OS)
+	require.Equal(t, "PC", g.DeviceType)
+	require.InDelta(t, 0.9, g.Confidence, 1e-9)
+
+	// Vendor class prefix only.
+	g, ok = Identify(db, &Fingerprint{Protocol: ProtocolDHCPv4, VendorClass: "android-dhcp-13", RequestedOptions: []uint16{1, 3}})
+	require.True(t, ok)
+	require.Equal(t, "Android", g.OS)
+	require.InDelta(t, 0.3, g.Confidence, 1e-9)
+
+	// Near match of the requested options: one option missing out of ten.
+	g, ok = Identify(db, &Fingerprint{Protocol: ProtocolDHCPv4, RequestedOptions: []uint16{1, 121, 3, 6, 15, 119, 252, 95, 44}})
+	require.True(t, ok)
+	require.Equal(t, "macOS", g.OS)
+	require.InDelta(t, 0.6*0.9, g.Confidence, 1e-9)
+
+	// Protocol and enterprise number restrictions.
+	_, ok = Identify(db, &Fingerprint{Protocol: ProtocolDHCPv6, RequestedOptions: []uint16{23, 24, 39}})
+	require.False(t, ok)
+	g, ok = Identify(db, &Fingerprint{Protocol: ProtocolDHCPv6, RequestedOptions: []uint16{23, 24, 39}, EnterpriseNumber: 311})
+	require.True(t, ok)
+	require.Equal(t, "Windows", g.OS)
+
+	// Nothing similar.
+	require.Empty(t, db.Match(&Fingerprint{Protocol: ProtocolDHCPv4, RequestedOptions: []uint16{1, 2}}))
+}
+
+func TestDatabaseMatchOrdering(t *testing.T) {
+	db, err := NewDatabase(
+		Signature{VendorClass: "MSFT", OS: "Windows"},
+		Signature{RequestedOptions: windowsPRL, VendorClass: "MSFT", UserClass: "corp", OS: "Windows", DeviceType: "Corporate PC"},
+	)
+	require.NoError(t, err)
+	fp := FromDHCPv4(windowsDiscover(t))
+	fp.UserClasses = []string{"corp"}
+
+	guesses := db.Match(fp)
+	require.Len(t, guesses, 2)
+	require.Equal(t, "Corporate PC", guesses[0].DeviceType)
+	require.InDelta(t, 1, guesses[0].Confidence, 1e-9)
+	require.InDelta(t, 0.3, guesses[1].Confidence, 1e-9)
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fingerprints.json")
+	require.NoError(t, os.WriteFile(path, []byte(testDatabase), 0o644))
+	db, err := Load(path)
+	require.NoError(t, err)
+	require.Equal(t, 4, db.Len())
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+
+	_, err = Read(strings.NewReader(`{"os": "not a list"}`))
+	require.Error(t, err)
+	_, err = Read(strings.NewReader(`[{"requested_options": "1,3"}]`))
+	require.Error(t, err)
+	_, err = Read(strings.NewReader(`[{"requested_options": "1,a", "os": "x"}]`))
+	require.Error(t, err)
+}
+
+func TestSimilarity(t *testing.T) {
+	require.Equal(t, 1.0, similarity(nil, nil))
+	require.Equal(t, 1.0, similarity([]uint16{1, 2, 3}, []uint16{1, 2, 3}))
+	require.Equal(t, 0.0, similarity([]uint16{1}, nil))
+	require.InDelta(t, 2.0/3, similarity([]uint16{1, 2, 3}, []uint16{3, 1, 2}), 1e-9)
+}
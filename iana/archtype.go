@@ -100,6 +100,18 @@ func (a Arch) String() string {
 	return "unknown"
 }
 
+// IsHTTP returns whether a is one of the architecture types of clients that
+// boot from HTTP rather than TFTP, as defined by the UEFI specification.
+func (a Arch) IsHTTP() bool {
+	switch a {
+	case EFI_X86_HTTP, EFI_X86_64_HTTP, EFI_BC_HTTP, EFI_ARM32_HTTP,
+		EFI_ARM64_HTTP, INTEL_X86PC_HTTP, UBOOT_ARM32_HTTP, UBOOT_ARM64_HTTP,
+		EFI_RISCV32_HTTP, EFI_RISCV64_HTTP, EFI_RISCV128_HTTP:
+		return true
+	}
+	return false
+}
+
 // Archs represents multiple Arch values.
 type Archs []Arch
 
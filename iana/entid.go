@@ -6,13 +6,19 @@ type EnterpriseID int
 // See https://www.iana.org/assignments/enterprise-numbers/enterprise-numbers for values
 const (
 	EnterpriseIDCiscoSystems            EnterpriseID = 9
+	EnterpriseIDMicrosoft               EnterpriseID = 311
+	EnterpriseIDIntel                   EnterpriseID = 343
 	EnterpriseIDCienaCorporation        EnterpriseID = 1271
+	EnterpriseIDAristaNetworks          EnterpriseID = 30065
 	EnterpriseIDMellanoxTechnologiesLTD EnterpriseID = 33049
 )
 
 var enterpriseIDToStringMap = map[EnterpriseID]string{
 	EnterpriseIDCiscoSystems:            "Cisco Systems",
+	EnterpriseIDMicrosoft:               "Microsoft",
+	EnterpriseIDIntel:                   "Intel Corporation",
 	EnterpriseIDCienaCorporation:        "Ciena Corporation",
+	EnterpriseIDAristaNetworks:          "Arista Networks",
 	EnterpriseIDMellanoxTechnologiesLTD: "Mellanox Technologies LTD",
 }
 
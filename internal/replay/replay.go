@@ -0,0 +1,32 @@
+// Package replay generates the monotonic replay detection values of the
+// DHCPv4 and DHCPv6 authentication protocols.
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// ntpEpoch is the NTP epoch, 1900-01-01, in Unix seconds.
+const ntpEpoch = -2208988800
+
+// Counter generates replay detection values with the monotonic method.
+// Values are NTP timestamps, so that they keep increasing across restarts.
+// The zero value is ready to use.
+type Counter struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+// Next returns a value greater than any value previously returned.
+func (c *Counter) Next() uint64 {
+	now := time.Now()
+	v := uint64(now.Unix()-ntpEpoch)<<32 | uint64(now.Nanosecond())<<32/uint64(time.Second)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v <= c.last {
+		v = c.last + 1
+	}
+	c.last = v
+	return v
+}
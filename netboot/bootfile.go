@@ -0,0 +1,120 @@
+package netboot
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// ErrNoBootFile is returned by BootFileSelector when no boot file is
+// configured for a client.
+var ErrNoBootFile = errors.New("no boot file configured for client")
+
+// optionIPXEEncapsulated is the option iPXE uses for its own settings. Its
+// presence in a request identifies iPXE even if the user class was changed.
+const optionIPXEEncapsulated = dhcpv4.GenericOptionCode(175)
+
+// BootFileSelector picks the boot file to offer to a DHCPv4 netboot client
+// based on its architecture (RFC 4578, Section 2.1).
+//
+// The usual setup is to chainload iPXE: firmware clients get an iPXE binary
+// matching their architecture, and iPXE, which identifies itself with the
+// "iPXE" user class, gets a script instead. Without IPXEScript, iPXE clients
+// would download iPXE again and loop forever.
+type BootFileSelector struct {
+	// BIOS is the boot file for legacy PC BIOS clients, e.g.
+	// "undionly.kpxe". Clients that do not send a client system
+	// architecture option are assumed to be legacy BIOS clients.
+	BIOS string
+	// UEFI is the boot file for x86-64 UEFI clients, e.g. "ipxe.efi".
+	UEFI string
+	// UEFIARM64 is the boot file for ARM64 UEFI clients, e.g.
+	// "ipxe-arm64.efi".
+	UEFIARM64 string
+	// HTTP is the boot file URL for x86-64 UEFI HTTP boot clients, e.g.
+	// "http://boot.example.com/ipxe.efi".
+	HTTP string
+	// HTTPARM64 is the boot file URL for ARM64 UEFI HTTP boot clients.
+	HTTPARM64 string
+
+	// Arch overrides the boot file for specific architectures.
+	Arch map[iana.Arch]string
+
+	// IPXEScript is the boot file for clients that are already running
+	// iPXE, usually the URL of an iPXE script.
+	IPXEScript string
+}
+
+// IsIPXE returns whether the client that sent req is iPXE, i.e. whether it
+// has the "iPXE" user class or sent iPXE's encapsulated options.
+func IsIPXE(req *dhcpv4.DHCPv4) bool {
+	for _, uc := range req.UserClass() {
+		if uc == "iPXE" {
+			return true
+		}
+	}
+	return req.Options.Has(optionIPXEEncapsulated)
+}
+
+// ForArch returns the boot file configured for the given architecture, or
+// the empty string.
+func (s *BootFileSelector) ForArch(arch iana.Arch) string {
+	if f, ok := s.Arch[arch]; ok {
+		return f
+	}
+	switch arch {
+	case iana.INTEL_X86PC:
+		return s.BIOS
+	case iana.EFI_X86_64, iana.EFI_BC:
+		return s.UEFI
+	case iana.EFI_ARM64:
+		return s.UEFIARM64
+	case iana.EFI_X86_64_HTTP, iana.EFI_BC_HTTP:
+		return s.HTTP
+	case iana.EFI_ARM64_HTTP:
+		return s.HTTPARM64
+	}
+	return ""
+}
+
+// Select returns the boot file for the client that sent req.
+//
+// If the client lists several architectures, the first one with a
+// configured boot file wins. Select returns ErrNoBootFile if there is none.
+func (s *BootFileSelector) Select(req *dhcpv4.DHCPv4) (string, error) {
+	if IsIPXE(req) && s.IPXEScript != "" {
+		return s.IPXEScript, nil
+	}
+	archs := req.ClientArch()
+	if len(archs) == 0 {
+		archs = []iana.Arch{iana.INTEL_X86PC}
+	}
+	for _, arch := range archs {
+		if f := s.ForArch(arch); f != "" {
+			return f, nil
+		}
+	}
+	return "", ErrNoBootFile
+}
+
+// Apply sets the boot file name of resp, the reply to req, to the boot file
+// selected for req. PXE clients ignore offers without a "PXEClient" class
+// identifier, so Apply also sets it if req is from a PXE client. Replies to
+// HTTP boot clients are set up with WithHTTPBootURLv4.
+func (s *BootFileSelector) Apply(req, resp *dhcpv4.DHCPv4) error {
+	f, err := s.Select(req)
+	if err != nil {
+		return err
+	}
+	if IsHTTPBootv4(req) {
+		WithHTTPBootURLv4(f)(resp)
+		return nil
+	}
+	resp.BootFileName = f
+	if strings.HasPrefix(req.ClassIdentifier(), "PXEClient") {
+		resp.UpdateOption(dhcpv4.OptClassIdentifier("PXEClient"))
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package netboot
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+var testSelector = BootFileSelector{
+	BIOS:       "undionly.kpxe",
+	UEFI:       "ipxe.efi",
+	UEFIARM64:  "ipxe-arm64.efi",
+	HTTP:       "http://boot/ipxe.efi",
+	HTTPARM64:  "http://boot/ipxe-arm64.efi",
+	Arch:       map[iana.Arch]string{iana.EFI_IA32: "ipxe-i386.efi"},
+	IPXEScript: "http://boot/boot.ipxe",
+}
+
+func newNetbootRequest(t *testing.T, modifiers ...dhcpv4.Modifier) *dhcpv4.DHCPv4 {
+	d, err := dhcpv4.NewDiscovery(net.HardwareAddr{0, 1, 2, 3, 4, 5}, modifiers...)
+	require.NoError(t, err)
+	return d
+}
+
+func TestBootFileSelectorSelect(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		archs []iana.Arch
+		want  string
+	}{
+		{"no arch", nil, "undionly.kpxe"},
+		{"bios", []iana.Arch{iana.INTEL_X86PC}, "undionly.kpxe"},
+		{"uefi", []iana.Arch{iana.EFI_X86_64}, "ipxe.efi"},
+		{"uefi bc", []iana.Arch{iana.EFI_BC}, "ipxe.efi"},
+		{"arm64", []iana.Arch{iana.EFI_ARM64}, "ipxe-arm64.efi"},
+		{"http", []iana.Arch{iana.EFI_X86_64_HTTP}, "http://boot/ipxe.efi"},
+		{"http arm64", []iana.Arch{iana.EFI_ARM64_HTTP}, "http://boot/ipxe-arm64.efi"},
+		{"override", []iana.Arch{iana.EFI_IA32}, "ipxe-i386.efi"},
+		{"first configured", []iana.Arch{iana.EFI_RISCV64, iana.EFI_ARM64}, "ipxe-arm64.efi"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var mods []dhcpv4.Modifier
+			if tt.archs != nil {
+				mods = append(mods, dhcpv4.WithOption(dhcpv4.OptClientArch(tt.archs...)))
+			}
+			f, err := testSelector.Select(newNetbootRequest(t, mods...))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, f)
+		})
+	}
+
+	_, err := testSelector.Select(newNetbootRequest(t, dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_RISCV64))))
+	require.Equal(t, ErrNoBootFile, err)
+}
+
+func TestBootFileSelectorIPXE(t *testing.T) {
+	req := newNetbootRequest(t,
+		dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_X86_64)),
+		dhcpv4.WithOption(dhcpv4.OptUserClass("iPXE")),
+	)
+	require.True(t, IsIPXE(req))
+	f, err := testSelector.Select(req)
+	require.NoError(t, err)
+	require.Equal(t, "http://boot/boot.ipxe", f)
+
+	// iPXE encapsulated options identify iPXE too.
+	req = newNetbootRequest(t, dhcpv4.WithGeneric(dhcpv4.GenericOptionCode(175), []byte{19, 1, 1}))
+	require.True(t, IsIPXE(req))
+
+	// Without a script, iPXE gets the regular boot file.
+	s := testSelector
+	s.IPXEScript = ""
+	f, err = s.Select(req)
+	require.NoError(t, err)
+	require.Equal(t, "undionly.kpxe", f)
+
+	require.False(t, IsIPXE(newNetbootRequest(t, dhcpv4.WithOption(dhcpv4.OptRFC3004UserClass([]string{"other"})))))
+}
+
+func TestBootFileSelectorApply(t *testing.T) {
+	req := newNetbootRequest(t,
+		dhcpv4.WithOption(dhcpv4.OptClassIdentifier("PXEClient:Arch:00007:UNDI:003016")),
+		dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_X86_64)),
+	)
+	resp, err := dhcpv4.NewReplyFromRequest(req)
+	require.NoError(t, err)
+	require.NoError(t, testSelector.Apply(req, resp))
+	require.Equal(t, "ipxe.efi", resp.BootFileName)
+	require.Equal(t, "PXEClient", resp.ClassIdentifier())
+
+	req = newNetbootRequest(t, dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_RISCV64)))
+	resp, err = dhcpv4.NewReplyFromRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, ErrNoBootFile, testSelector.Apply(req, resp))
+	require.Equal(t, "", resp.BootFileName)
+}
@@ -0,0 +1,252 @@
+package netboot
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	// Register the hashes supported by ParseChecksum.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+
+	"github.com/insomniacslk/dhcp/netboot/tftp"
+)
+
+var (
+	// ErrFileTooLarge is returned by Fetcher when a boot file is larger
+	// than its MaxSize.
+	ErrFileTooLarge = errors.New("boot file exceeds the maximum size")
+	// ErrChecksumMismatch is returned by Fetcher when a boot file does not
+	// match the expected checksum.
+	ErrChecksumMismatch = errors.New("boot file checksum mismatch")
+)
+
+// Checksum is the expected digest of a boot file.
+type Checksum struct {
+	Hash crypto.Hash
+	Sum  []byte
+}
+
+var checksumAlgorithms = map[string]crypto.Hash{
+	"sha1":   crypto.SHA1,
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// ParseChecksum parses a checksum in the "algorithm:hex digest" form, e.g.
+// "sha256:e3b0c442...". The supported algorithms are sha1, sha256, sha384
+// and sha512.
+func ParseChecksum(s string) (*Checksum, error) {
+	alg, digest, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("checksum %q is not in the algorithm:digest form", s)
+	}
+	h, ok := checksumAlgorithms[strings.ToLower(alg)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", alg)
+	}
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum digest: %v", err)
+	}
+	if len(sum) != h.Size() {
+		return nil, fmt.Errorf("%s digest must be %d bytes, got %d", alg, h.Size(), len(sum))
+	}
+	return &Checksum{Hash: h, Sum: sum}, nil
+}
+
+// Fetcher downloads boot files over TFTP, HTTP or HTTPS. The zero value is
+// ready to use.
+type Fetcher struct {
+	// HTTPClient is used for http and https URLs. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// TFTPClient is used for tftp URLs. The zero value is used if nil.
+	TFTPClient *tftp.Client
+
+	// MaxSize is the maximum size of a boot file in bytes, or 0 for no
+	// limit. Files announced as larger are rejected before downloading.
+	MaxSize int64
+	// Progress, if set, is called as data is received with the number of
+	// bytes received so far and the size of the file, or -1 if the size is
+	// not known.
+	Progress func(done, total int64)
+}
+
+// Fetch downloads the file at rawURL and writes it to w. The URL scheme must
+// be tftp, http or https. It returns the number of bytes written.
+//
+// Use ctx to set a timeout on the download.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string, w io.Writer) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	return f.fetch(ctx, u, w, nil)
+}
+
+// FetchVerified is like Fetch, but also checks that the file matches sum.
+// Since data is written to w as it is received, callers must discard it if
+// FetchVerified returns ErrChecksumMismatch.
+func (f *Fetcher) FetchVerified(ctx context.Context, rawURL string, w io.Writer, sum Checksum) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, err
+	}
+	return f.fetch(ctx, u, w, &sum)
+}
+
+// FetchBootFile downloads the boot file of bc and writes it to w. See
+// BootConf.BootfileLocation.
+func (f *Fetcher) FetchBootFile(ctx context.Context, bc *BootConf, w io.Writer) (int64, error) {
+	u, err := bc.BootfileLocation()
+	if err != nil {
+		return 0, err
+	}
+	return f.fetch(ctx, u, w, nil)
+}
+
+// BootfileLocation returns the URL of the boot file of bc. A BootfileURL that
+// is a plain file name, as sent in DHCPv4 replies, is turned into a tftp URL
+// on BootfileServer.
+func (bc *BootConf) BootfileLocation() (*url.URL, error) {
+	if bc.BootfileURL == "" {
+		return nil, errors.New("no boot file URL")
+	}
+	u, err := url.Parse(bc.BootfileURL)
+	if err == nil && u.Scheme != "" {
+		return u, nil
+	}
+	if bc.BootfileServer == "" {
+		return nil, fmt.Errorf("boot file %q has no scheme and no server", bc.BootfileURL)
+	}
+	host := bc.BootfileServer
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		host = "[" + host + "]"
+	}
+	return &url.URL{
+		Scheme: "tftp",
+		Host:   host,
+		Path:   "/" + strings.TrimPrefix(bc.BootfileURL, "/"),
+	}, nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, u *url.URL, w io.Writer, sum *Checksum) (int64, error) {
+	pw := &progressWriter{w: w, max: f.MaxSize, total: -1, progress: f.Progress}
+	if sum != nil {
+		if !sum.Hash.Available() {
+			return 0, fmt.Errorf("checksum algorithm %v is not available", sum.Hash)
+		}
+		pw.hash = sum.Hash.New()
+	}
+	var err error
+	switch u.Scheme {
+	case "tftp":
+		err = f.fetchTFTP(ctx, u, pw)
+	case "http", "https":
+		err = f.fetchHTTP(ctx, u, pw)
+	default:
+		return 0, fmt.Errorf("unsupported boot file URL scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return pw.done, err
+	}
+	if sum != nil && !bytes.Equal(pw.hash.Sum(nil), sum.Sum) {
+		return pw.done, ErrChecksumMismatch
+	}
+	return pw.done, nil
+}
+
+func (f *Fetcher) fetchTFTP(ctx context.Context, u *url.URL, pw *progressWriter) error {
+	if u.Host == "" {
+		return fmt.Errorf("tftp URL %q has no host", u)
+	}
+	var c tftp.Client
+	if f.TFTPClient != nil {
+		c = *f.TFTPClient
+	}
+	onSize := c.OnTransferSize
+	c.OnTransferSize = func(size int64) error {
+		if err := pw.setTotal(size); err != nil {
+			return err
+		}
+		if onSize != nil {
+			return onSize(size)
+		}
+		return nil
+	}
+	// RFC 3617: the file name is the path without the leading slash.
+	_, err := c.Get(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), pw)
+	return err
+}
+
+func (f *Fetcher) fetchHTTP(ctx context.Context, u *url.URL, pw *progressWriter) error {
+	client := f.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+	if resp.ContentLength >= 0 {
+		if err := pw.setTotal(resp.ContentLength); err != nil {
+			return err
+		}
+	}
+	_, err = io.Copy(pw, resp.Body)
+	return err
+}
+
+// progressWriter enforces the size limit, hashes and reports progress on the
+// data written to w.
+type progressWriter struct {
+	w        io.Writer
+	hash     hash.Hash
+	done     int64
+	total    int64
+	max      int64
+	progress func(done, total int64)
+}
+
+func (pw *progressWriter) setTotal(total int64) error {
+	if pw.max > 0 && total > pw.max {
+		return ErrFileTooLarge
+	}
+	pw.total = total
+	return nil
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	if pw.max > 0 && pw.done+int64(len(p)) > pw.max {
+		return 0, ErrFileTooLarge
+	}
+	n, err := pw.w.Write(p)
+	if pw.hash != nil {
+		pw.hash.Write(p[:n])
+	}
+	pw.done += int64(n)
+	if pw.progress != nil {
+		pw.progress(pw.done, pw.total)
+	}
+	return n, err
+}
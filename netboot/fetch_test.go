@@ -0,0 +1,166 @@
+package netboot
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/insomniacslk/dhcp/netboot/tftp"
+	"github.com/stretchr/testify/require"
+)
+
+var testKernel = bytes.Repeat([]byte("kernel"), 2000)
+
+func startTFTPServer(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := tftp.NewServer(tftp.FSHandler(fstest.MapFS{
+		"boot/vmlinuz": {Data: testKernel},
+	}))
+	go func() { _ = s.Serve(conn) }()
+	t.Cleanup(func() { s.Close() })
+	return conn.LocalAddr().String()
+}
+
+func startHTTPServer(t *testing.T) string {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/boot/vmlinuz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(testKernel)))
+		_, _ = w.Write(testKernel)
+	}))
+	t.Cleanup(s.Close)
+	return s.URL
+}
+
+func testFetcher() *Fetcher {
+	return &Fetcher{TFTPClient: &tftp.Client{Timeout: 100 * time.Millisecond}}
+}
+
+func TestFetch(t *testing.T) {
+	for _, u := range []string{
+		"tftp://" + startTFTPServer(t) + "/boot/vmlinuz",
+		startHTTPServer(t) + "/boot/vmlinuz",
+	} {
+		t.Run(u, func(t *testing.T) {
+			var lastDone, lastTotal int64
+			f := testFetcher()
+			f.Progress = func(done, total int64) {
+				lastDone, lastTotal = done, total
+			}
+			var buf bytes.Buffer
+			n, err := f.Fetch(context.Background(), u, &buf)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(testKernel)), n)
+			require.Equal(t, testKernel, buf.Bytes())
+			require.Equal(t, n, lastDone)
+			require.Equal(t, n, lastTotal)
+		})
+	}
+}
+
+func TestFetchMaxSize(t *testing.T) {
+	for _, u := range []string{
+		"tftp://" + startTFTPServer(t) + "/boot/vmlinuz",
+		startHTTPServer(t) + "/boot/vmlinuz",
+	} {
+		t.Run(u, func(t *testing.T) {
+			f := testFetcher()
+			f.MaxSize = 1000
+			var buf bytes.Buffer
+			_, err := f.Fetch(context.Background(), u, &buf)
+			require.True(t, errors.Is(err, ErrFileTooLarge), err)
+			require.Zero(t, buf.Len())
+		})
+	}
+}
+
+func TestFetchVerified(t *testing.T) {
+	u := startHTTPServer(t) + "/boot/vmlinuz"
+	digest := sha256.Sum256(testKernel)
+	sum, err := ParseChecksum("sha256:" + hex.EncodeToString(digest[:]))
+	require.NoError(t, err)
+	require.Equal(t, crypto.SHA256, sum.Hash)
+
+	f := testFetcher()
+	_, err = f.FetchVerified(context.Background(), u, &bytes.Buffer{}, *sum)
+	require.NoError(t, err)
+
+	sum.Sum[0] ^= 0xff
+	_, err = f.FetchVerified(context.Background(), u, &bytes.Buffer{}, *sum)
+	require.Equal(t, ErrChecksumMismatch, err)
+}
+
+func TestFetchErrors(t *testing.T) {
+	f := testFetcher()
+	_, err := f.Fetch(context.Background(), "ftp://example.com/vmlinuz", &bytes.Buffer{})
+	require.Error(t, err)
+
+	_, err = f.Fetch(context.Background(), startHTTPServer(t)+"/missing", &bytes.Buffer{})
+	require.Error(t, err)
+
+	_, err = f.Fetch(context.Background(), "tftp://"+startTFTPServer(t)+"/missing", &bytes.Buffer{})
+	var terr *tftp.Error
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, tftp.ErrorFileNotFound, terr.Code)
+}
+
+func TestParseChecksum(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"sha256",
+		"md4:00",
+		"sha256:zz",
+		"sha256:0011",
+	} {
+		_, err := ParseChecksum(s)
+		require.Error(t, err, s)
+	}
+	sum, err := ParseChecksum("SHA1:da39a3ee5e6b4b0d3255bfef95601890afd80709")
+	require.NoError(t, err)
+	require.Equal(t, crypto.SHA1, sum.Hash)
+}
+
+func TestBootfileLocation(t *testing.T) {
+	for _, tt := range []struct {
+		bc   BootConf
+		want string
+	}{
+		{BootConf{BootfileURL: "http://10.0.0.1/ipxe.efi"}, "http://10.0.0.1/ipxe.efi"},
+		{BootConf{BootfileURL: "pxelinux.0", BootfileServer: "10.0.0.1"}, "tftp://10.0.0.1/pxelinux.0"},
+		{BootConf{BootfileURL: "/boot/vmlinuz", BootfileServer: "tftp.example.com"}, "tftp://tftp.example.com/boot/vmlinuz"},
+		{BootConf{BootfileURL: "vmlinuz", BootfileServer: "2001:db8::1"}, "tftp://[2001:db8::1]/vmlinuz"},
+	} {
+		u, err := tt.bc.BootfileLocation()
+		require.NoError(t, err)
+		require.Equal(t, tt.want, u.String())
+	}
+
+	_, err := (&BootConf{BootfileURL: "pxelinux.0"}).BootfileLocation()
+	require.Error(t, err)
+	_, err = (&BootConf{}).BootfileLocation()
+	require.Error(t, err)
+}
+
+func TestFetchBootFile(t *testing.T) {
+	addr := startTFTPServer(t)
+	bc := &BootConf{BootfileURL: "boot/vmlinuz", BootfileServer: addr}
+	var buf bytes.Buffer
+	_, err := testFetcher().FetchBootFile(context.Background(), bc, &buf)
+	require.NoError(t, err)
+	require.Equal(t, testKernel, buf.Bytes())
+}
@@ -0,0 +1,147 @@
+package netboot
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// httpClientClass is the vendor class of UEFI HTTP boot clients and of the
+// replies to them, as defined by the UEFI specification, Section 24.7.
+const httpClientClass = "HTTPClient"
+
+// maxBootFileNameLen is the longest boot file name that fits in the
+// NUL-terminated, 128 bytes long boot file name field of DHCPv4 messages.
+const maxBootFileNameLen = 127
+
+// httpClientClassID returns the full vendor class sent by HTTP boot clients
+// of the given architecture.
+func httpClientClassID(arch iana.Arch) string {
+	return fmt.Sprintf("%s:Arch:%05d:UNDI:003016", httpClientClass, uint16(arch))
+}
+
+// WithHTTPBootv4 makes a DHCPv4 request look like it was sent by a UEFI HTTP
+// boot client of the given architecture, e.g. iana.EFI_X86_64_HTTP: it sets
+// the "HTTPClient" class identifier and the client architecture, and
+// requests the boot file and DNS options.
+func WithHTTPBootv4(arch iana.Arch) dhcpv4.Modifier {
+	return func(d *dhcpv4.DHCPv4) {
+		d.UpdateOption(dhcpv4.OptClassIdentifier(httpClientClassID(arch)))
+		d.UpdateOption(dhcpv4.OptClientArch(arch))
+		dhcpv4.WithRequestedOptions(
+			dhcpv4.OptionBootfileName,
+			dhcpv4.OptionClassIdentifier,
+			dhcpv4.OptionDomainNameServer,
+			dhcpv4.OptionDomainName,
+			dhcpv4.OptionDNSDomainSearchList,
+		)(d)
+	}
+}
+
+// WithHTTPBootv6 makes a DHCPv6 request look like it was sent by a UEFI HTTP
+// boot client of the given architecture: it adds the "HTTPClient" vendor
+// class and the client architecture, and requests the boot file URL and DNS
+// options.
+func WithHTTPBootv6(arch iana.Arch) dhcpv6.Modifier {
+	return func(d dhcpv6.DHCPv6) {
+		d.UpdateOption(&dhcpv6.OptVendorClass{
+			EnterpriseNumber: uint32(iana.EnterpriseIDIntel),
+			Data:             [][]byte{[]byte(httpClientClassID(arch))},
+		})
+		d.UpdateOption(dhcpv6.OptClientArchType(arch))
+		dhcpv6.WithRequestedOptions(
+			dhcpv6.OptionBootfileURL,
+			dhcpv6.OptionBootfileParam,
+			dhcpv6.OptionVendorClass,
+			dhcpv6.OptionDNSRecursiveNameServer,
+			dhcpv6.OptionDomainSearchList,
+		)(d)
+	}
+}
+
+// IsHTTPBootv4 returns whether req was sent by a UEFI HTTP boot client, i.e.
+// whether it has the "HTTPClient" class identifier or an HTTP boot
+// architecture.
+func IsHTTPBootv4(req *dhcpv4.DHCPv4) bool {
+	if strings.HasPrefix(req.ClassIdentifier(), httpClientClass) {
+		return true
+	}
+	for _, arch := range req.ClientArch() {
+		if arch.IsHTTP() {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHTTPBootv6 returns whether msg was sent by a UEFI HTTP boot client, i.e.
+// whether it has the "HTTPClient" vendor class or an HTTP boot architecture.
+func IsHTTPBootv6(msg *dhcpv6.Message) bool {
+	for _, vc := range msg.Options.VendorClasses() {
+		for _, data := range vc.Data {
+			if strings.HasPrefix(string(data), httpClientClass) {
+				return true
+			}
+		}
+	}
+	for _, arch := range msg.Options.ArchTypes() {
+		if arch.IsHTTP() {
+			return true
+		}
+	}
+	return false
+}
+
+// WithHTTPBootURLv4 sets the boot file of a reply to an HTTP boot client.
+//
+// UEFI HTTP boot clients ignore offers without the "HTTPClient" class
+// identifier, so it is set as well. The URL is written in the Bootfile Name
+// option, and in the boot file name field only if it fits there: a truncated
+// URL would point clients that read the field to the wrong file.
+func WithHTTPBootURLv4(u string) dhcpv4.Modifier {
+	return func(d *dhcpv4.DHCPv4) {
+		d.UpdateOption(dhcpv4.OptClassIdentifier(httpClientClass))
+		d.UpdateOption(dhcpv4.OptBootFileName(u))
+		if len(u) <= maxBootFileNameLen {
+			d.BootFileName = u
+		} else {
+			d.BootFileName = ""
+		}
+	}
+}
+
+// WithHTTPBootURLv6 sets the boot file URL of a reply to an HTTP boot client,
+// together with the "HTTPClient" vendor class the client expects.
+func WithHTTPBootURLv6(u string) dhcpv6.Modifier {
+	return func(d dhcpv6.DHCPv6) {
+		d.UpdateOption(&dhcpv6.OptVendorClass{
+			EnterpriseNumber: uint32(iana.EnterpriseIDIntel),
+			Data:             [][]byte{[]byte(httpClientClass)},
+		})
+		d.UpdateOption(dhcpv6.OptBootFileURL(u))
+	}
+}
+
+// HTTPBootURL returns the boot file URL of bc, parsed. It returns an error if
+// the URL is not an absolute HTTP or HTTPS URL.
+func (bc *BootConf) HTTPBootURL() (*url.URL, error) {
+	if bc.BootfileURL == "" {
+		return nil, errors.New("no boot file URL")
+	}
+	u, err := url.Parse(bc.BootfileURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("boot file URL %q is not an HTTP(S) URL", bc.BootfileURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("boot file URL %q has no host", bc.BootfileURL)
+	}
+	return u, nil
+}
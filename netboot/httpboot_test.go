@@ -0,0 +1,97 @@
+package netboot
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPBootv4(t *testing.T) {
+	discover := newNetbootRequest(t, WithHTTPBootv4(iana.EFI_X86_64_HTTP))
+	require.Equal(t, "HTTPClient:Arch:00016:UNDI:003016", discover.ClassIdentifier())
+	require.Equal(t, []iana.Arch{iana.EFI_X86_64_HTTP}, discover.ClientArch())
+	require.True(t, discover.IsOptionRequested(dhcpv4.OptionBootfileName))
+	require.True(t, discover.IsOptionRequested(dhcpv4.OptionDomainNameServer))
+	require.True(t, IsHTTPBootv4(discover))
+
+	// A long URL that does not fit in the boot file name field.
+	longURL := "https://boot.example.com/" + strings.Repeat("a", 150) + "/ipxe.efi"
+	s := BootFileSelector{HTTP: longURL, UEFI: "ipxe.efi"}
+	offer, err := dhcpv4.NewReplyFromRequest(discover,
+		dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer),
+		dhcpv4.WithYourIP(net.IP{10, 0, 0, 2}),
+		dhcpv4.WithNetmask(net.IPv4Mask(255, 255, 255, 0)),
+		dhcpv4.WithDNS(net.IP{10, 0, 0, 1}),
+		dhcpv4.WithDomainSearchList("example.com"),
+	)
+	require.NoError(t, err)
+	require.NoError(t, s.Apply(discover, offer))
+	require.Equal(t, "HTTPClient", offer.ClassIdentifier())
+
+	// The boot file name field would truncate the URL, the option holds
+	// it.
+	require.Empty(t, offer.BootFileName)
+	offer, err = dhcpv4.FromBytes(offer.ToBytes())
+	require.NoError(t, err)
+
+	bc, err := ConversationToNetconfv4([]*dhcpv4.DHCPv4{discover, offer})
+	require.NoError(t, err)
+	require.Equal(t, longURL, bc.BootfileURL)
+	require.Equal(t, []net.IP{{10, 0, 0, 1}}, bc.DNSServers)
+	require.Equal(t, []string{"example.com"}, bc.DNSSearchList)
+	u, err := bc.HTTPBootURL()
+	require.NoError(t, err)
+	require.Equal(t, "boot.example.com", u.Host)
+
+	// Short URLs are in the boot file name field too.
+	shortURL := "http://10.0.0.1/" + strings.Repeat("a", maxBootFileNameLen-len("http://10.0.0.1/"))
+	WithHTTPBootURLv4(shortURL)(offer)
+	offer, err = dhcpv4.FromBytes(offer.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, shortURL, offer.BootFileName)
+	require.Equal(t, shortURL, offer.BootFileNameOption())
+
+	// Firmware PXE clients are not HTTP boot clients.
+	require.False(t, IsHTTPBootv4(newNetbootRequest(t, dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_X86_64)))))
+	require.True(t, IsHTTPBootv4(newNetbootRequest(t, dhcpv4.WithOption(dhcpv4.OptClientArch(iana.EFI_ARM64_HTTP)))))
+}
+
+func TestHTTPBootv6(t *testing.T) {
+	solicit, err := dhcpv6.NewSolicit(net.HardwareAddr{0, 1, 2, 3, 4, 5}, WithHTTPBootv6(iana.EFI_ARM64_HTTP), dhcpv6.WithRapidCommit)
+	require.NoError(t, err)
+	require.True(t, IsHTTPBootv6(solicit))
+	require.Equal(t, [][]byte{[]byte("HTTPClient:Arch:00019:UNDI:003016")}, solicit.Options.VendorClass(uint32(iana.EnterpriseIDIntel)))
+	require.True(t, solicit.IsOptionRequested(dhcpv6.OptionBootfileURL))
+
+	reply, err := dhcpv6.NewReplyFromMessage(solicit,
+		WithHTTPBootURLv6("http://[2001:db8::1]/ipxe.efi"),
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::2"), ValidLifetime: time.Hour}),
+		dhcpv6.WithDNS(net.ParseIP("2001:db8::53")),
+	)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("HTTPClient")}, reply.Options.VendorClass(uint32(iana.EnterpriseIDIntel)))
+
+	bc, err := ConversationToNetconf([]dhcpv6.DHCPv6{solicit, reply})
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, bc.DNSServers)
+	u, err := bc.HTTPBootURL()
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8::1", u.Hostname())
+
+	solicit, err = dhcpv6.NewSolicit(net.HardwareAddr{0, 1, 2, 3, 4, 5}, dhcpv6.WithArchType(iana.EFI_X86_64))
+	require.NoError(t, err)
+	require.False(t, IsHTTPBootv6(solicit))
+}
+
+func TestBootConfHTTPBootURL(t *testing.T) {
+	for _, u := range []string{"", "tftp://10.0.0.1/pxelinux.0", "pxelinux.0", "http:///path", "http://%zz"} {
+		_, err := (&BootConf{BootfileURL: u}).HTTPBootURL()
+		require.Error(t, err, u)
+	}
+}
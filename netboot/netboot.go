@@ -25,6 +25,12 @@ type BootConf struct {
 	// See RFC5970 section 3.1 for IPv6 and RFC2132 section 9.5 ("Bootfile name") for IPv4
 	BootfileURL string
 
+	// BootfileServer is the TFTP server to download BootfileURL from when
+	// it is a plain file name, as is usual with DHCPv4. It is the TFTP
+	// server name option (RFC2132 section 9.4) if present, or else the
+	// next server address.
+	BootfileServer string
+
 	// BootfileParam is "what arguments should we pass (cmdline)".
 	// See RFC5970 section 3.2 for IPv6.
 	BootfileParam []string
@@ -154,7 +160,18 @@ func ConversationToNetconfv4(conversation []*dhcpv4.DHCPv4) (*BootConf, error) {
 	}
 	bootconf.NetConf = *netconf
 
-	bootconf.BootfileURL = reply.BootFileName
+	// The boot file name field is only 128 bytes long, so HTTP boot URLs
+	// are often in the Bootfile Name option instead, and the field may
+	// hold a truncated copy.
+	bootconf.BootfileURL = reply.BootFileNameOption()
+	if bootconf.BootfileURL == "" {
+		bootconf.BootfileURL = reply.BootFileName
+	}
+	if name := reply.TFTPServerName(); name != "" {
+		bootconf.BootfileServer = name
+	} else if ip := reply.ServerIPAddr; ip != nil && !ip.IsUnspecified() {
+		bootconf.BootfileServer = ip.String()
+	}
 	// TODO: should we support bootfile parameters here somehow? (see netconf.BootfileParam)
 	return bootconf, nil
 }
@@ -3,17 +3,21 @@ package netboot
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/svcb"
 	"github.com/jsimonetti/rtnetlink"
 	"github.com/jsimonetti/rtnetlink/rtnl"
 	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
 )
 
 // AddrConf holds a single IP address configuration for a NIC
@@ -23,6 +27,63 @@ type AddrConf struct {
 	ValidLifetime     time.Duration
 }
 
+// RouteConf holds a single route configuration for a NIC
+type RouteConf struct {
+	Dst net.IPNet
+	// Gateway is the next hop, or nil if Dst is on-link.
+	Gateway net.IP
+	// Unreachable makes the route reject packets instead of forwarding
+	// them. It is used for delegated prefixes, see RFC 7084, WPD-5.
+	Unreachable bool
+}
+
+// EncryptedDNSServer is an encrypted DNS resolver designated by the network
+// with DNR, RFC 9463.
+type EncryptedDNSServer struct {
+	// Priority orders the resolvers, lower values first.
+	Priority uint16
+	// Name is the authentication domain name of the resolver, to be
+	// matched by its certificate.
+	Name string
+	// Addrs are the addresses of the resolver. If empty, they must be
+	// looked up from Name.
+	Addrs []net.IP
+	// ALPN are the protocols the resolver supports, e.g. "dot" for DNS
+	// over TLS and "h2" for DNS over HTTPS.
+	ALPN []string
+	// Port is the port of the resolver, or 0 for the default port of the
+	// protocol.
+	Port uint16
+	// DoHPath is the URI template of a DNS over HTTPS resolver, e.g.
+	// "/dns-query{?dns}".
+	DoHPath string
+}
+
+// newEncryptedDNSServer returns the resolver of a DNR option, or nil if it is
+// invalid: resolvers with addresses must have an alpn SvcParam, RFC 9463,
+// Section 3.1.5.
+func newEncryptedDNSServer(priority uint16, name string, addrs []net.IP, params svcb.Params) *EncryptedDNSServer {
+	s := &EncryptedDNSServer{Priority: priority, Name: name, Addrs: addrs}
+	if len(addrs) == 0 {
+		return s
+	}
+	if s.ALPN = params.ALPN(); len(s.ALPN) == 0 {
+		return nil
+	}
+	s.Port, _ = params.Port()
+	s.DoHPath, _ = params.DoHPath()
+	return s
+}
+
+// captivePortal returns the captive portal API URI uri, or "" if it states
+// that there is no captive portal.
+func captivePortal(uri string) string {
+	if uri == dhcpv4.CaptivePortalUnrestricted {
+		return ""
+	}
+	return uri
+}
+
 // NetConf holds multiple IP configuration for a NIC, and DNS configuration
 type NetConf struct {
 	Addresses     []AddrConf
@@ -30,6 +91,29 @@ type NetConf struct {
 	DNSSearchList []string
 	Routers       []net.IP
 	NTPServers    []net.IP
+	// Routes are configured in addition to the default route through
+	// Routers.
+	Routes []RouteConf
+	// MTU is the interface MTU, or 0 to leave it unchanged.
+	MTU int
+	// EncryptedDNSServers are ordered by priority. ConfigureInterface
+	// does not configure them.
+	EncryptedDNSServers []EncryptedDNSServer
+	// CaptivePortal is the captive portal API URI, RFC 8910, or "" if
+	// the network has no captive portal.
+	CaptivePortal string
+
+	// The following IPv4/IPv6 transition configuration is exposed for
+	// the caller to act upon; ConfigureInterface ignores it.
+
+	// AFTRName is the name of the DS-Lite Address Family Transition
+	// Router, RFC 6334.
+	AFTRName string
+	// V6Prefix64 holds the IPv4-embedded IPv6 prefixes for IPv4
+	// multicast, RFC 8115.
+	V6Prefix64 *dhcpv6.OptV6Prefix64
+	// SixRD is the 6rd configuration, RFC 5969.
+	SixRD *dhcpv4.SixRD
 }
 
 // GetNetConfFromPacketv6 extracts network configuration information from a DHCPv6
@@ -63,6 +147,16 @@ func GetNetConfFromPacketv6(d *dhcpv6.Message) (*NetConf, error) {
 	// get DNS configuration
 	netconf.DNSServers = d.Options.DNS()
 
+	// get encrypted DNS configuration
+	for _, dnr := range d.Options.DNR() {
+		if s := newEncryptedDNSServer(dnr.ServicePriority, dnr.ADN, dnr.Addrs, dnr.Params); s != nil {
+			netconf.EncryptedDNSServers = append(netconf.EncryptedDNSServers, *s)
+		}
+	}
+
+	// get captive portal
+	netconf.CaptivePortal = captivePortal(d.Options.CaptivePortal())
+
 	// get domain search list
 	domains := d.Options.DomainSearchList()
 	if domains != nil {
@@ -72,6 +166,25 @@ func GetNetConfFromPacketv6(d *dhcpv6.Message) (*NetConf, error) {
 	// get NTP servers
 	netconf.NTPServers = d.Options.NTPServers()
 
+	// Delegated prefixes are meant for downstream links. Reject traffic to
+	// the parts of them not routed elsewhere, to avoid routing loops with
+	// the upstream router.
+	for _, iapd := range d.Options.IAPD() {
+		for _, prefix := range iapd.Options.Prefixes() {
+			if prefix.Prefix == nil || prefix.ValidLifetime == 0 {
+				continue
+			}
+			netconf.Routes = append(netconf.Routes, RouteConf{
+				Dst:         *prefix.Prefix,
+				Unreachable: true,
+			})
+		}
+	}
+
+	// get IPv4/IPv6 transition configuration
+	netconf.AFTRName = d.Options.AFTRName()
+	netconf.V6Prefix64 = d.Options.V6Prefix64()
+
 	return &netconf, nil
 }
 
@@ -113,6 +226,16 @@ func GetNetConfFromPacketv4(d *dhcpv4.DHCPv4) (*NetConf, error) {
 	// get DNS configuration
 	netconf.DNSServers = d.DNS()
 
+	// get encrypted DNS configuration
+	for _, dnr := range d.DNR() {
+		if s := newEncryptedDNSServer(dnr.ServicePriority, dnr.ADN, dnr.Addrs, dnr.Params); s != nil {
+			netconf.EncryptedDNSServers = append(netconf.EncryptedDNSServers, *s)
+		}
+	}
+
+	// get captive portal
+	netconf.CaptivePortal = captivePortal(d.CaptivePortal())
+
 	// get domain search list
 	dnsSearchList := d.DomainSearch()
 	if dnsSearchList != nil {
@@ -122,12 +245,32 @@ func GetNetConfFromPacketv4(d *dhcpv4.DHCPv4) (*NetConf, error) {
 		netconf.DNSSearchList = dnsSearchList.Labels
 	}
 
-	// get default gateway (empty is perfectly fine)
-	netconf.Routers = d.Router()
+	// get classless static routes. If they are present, the Router option
+	// must be ignored, as per RFC 3442.
+	if routes := d.ClasslessStaticRoute(); len(routes) > 0 {
+		for _, r := range routes {
+			rc := RouteConf{Dst: *r.Dest}
+			if !r.Router.IsUnspecified() {
+				rc.Gateway = r.Router
+			}
+			netconf.Routes = append(netconf.Routes, rc)
+		}
+	} else {
+		// get default gateway (empty is perfectly fine)
+		netconf.Routers = d.Router()
+	}
+
+	// get interface MTU (not present is perfectly fine)
+	if mtu, err := d.InterfaceMTU(); err == nil {
+		netconf.MTU = int(mtu)
+	}
 
 	// get NTP servers
 	netconf.NTPServers = d.NTPServers()
 
+	// get 6rd configuration
+	netconf.SixRD = d.SixRD()
+
 	return &netconf, nil
 }
 
@@ -175,8 +318,16 @@ func IfUp(ifname string, timeout time.Duration) (_ *net.Interface, err error) {
 	return nil, fmt.Errorf("timed out while waiting for %s to come up", ifname)
 }
 
+// resolvConfPath is the resolver configuration file written by
+// ConfigureInterface.
+var resolvConfPath = "/etc/resolv.conf"
+
 // ConfigureInterface configures a network interface with the configuration held by a
-// NetConf structure
+// NetConf structure: MTU, addresses, default route, additional routes and
+// resolver configuration, in this order.
+//
+// If a step fails, the changes made by the previous steps are undone on a best
+// effort basis, and the error is returned.
 func ConfigureInterface(ifname string, netconf *NetConf) (err error) {
 	iface, err := net.InterfaceByName(ifname)
 	if err != nil {
@@ -187,32 +338,47 @@ func ConfigureInterface(ifname string, netconf *NetConf) (err error) {
 		return err
 	}
 	defer func() {
-		if cerr := rt.Close(); err != nil {
+		if cerr := rt.Close(); cerr != nil && err == nil {
 			err = cerr
 		}
 	}()
+
+	// undo holds the functions reverting each successful step, run in
+	// reverse order on failure.
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if uerr := undo[i](); uerr != nil {
+				log.Printf("could not roll back configuration of %s: %v", ifname, uerr)
+			}
+		}
+	}()
+
+	// configure MTU
+	if netconf.MTU > 0 && netconf.MTU != iface.MTU {
+		if err := setMTU(rt, iface, netconf.MTU); err != nil {
+			return fmt.Errorf("cannot set MTU %d on %s: %v", netconf.MTU, ifname, err)
+		}
+		oldMTU := iface.MTU
+		undo = append(undo, func() error { return setMTU(rt, iface, oldMTU) })
+	}
+
 	// configure interfaces
 	for _, addr := range netconf.Addresses {
+		addr := addr
 		if err := rt.AddrAdd(iface, &addr.IPNet); err != nil {
 			return fmt.Errorf("cannot configure %s on %s: %v", ifname, addr.IPNet, err)
 		}
-	}
-	// configure /etc/resolv.conf
-	resolvconf := ""
-	for _, ns := range netconf.DNSServers {
-		resolvconf += fmt.Sprintf("nameserver %s\n", ns)
-	}
-	if len(netconf.DNSSearchList) > 0 {
-		resolvconf += fmt.Sprintf("search %s\n", strings.Join(netconf.DNSSearchList, " "))
-	}
-	if err = os.WriteFile("/etc/resolv.conf", []byte(resolvconf), 0644); err != nil {
-		return fmt.Errorf("could not write resolv.conf file %v", err)
+		undo = append(undo, func() error { return rt.AddrDel(iface, &addr.IPNet) })
 	}
 
 	// FIXME wut? No IPv6 here?
 	// add default route information for v4 space. only one default route is allowed
 	// so ignore the others if there are multiple ones
-	if len(netconf.Routers) > 0 {
+	if len(netconf.Routers) > 0 || hasDefaultRoutev4(netconf.Routes) {
 		// if there is a default v4 route, remove it, as we want to add the one we just got during
 		// the dhcp transaction. if the route is not present, which is the final state we want,
 		// an error is returned so ignore it
@@ -234,14 +400,138 @@ func ConfigureInterface(ifname string, netconf *NetConf) (err error) {
 			default:
 				return fmt.Errorf("could not delete default route on interface %s: %v", ifname, err)
 			}
+		} else {
+			undo = append(undo, func() error { return rt.RouteAdd(iface, dst, nil) })
 		}
 
-		src := netconf.Addresses[0].IPNet
-		// TODO handle the remaining Routers if more than one
-		if err := rt.RouteAdd(iface, dst, netconf.Routers[0], rtnl.WithRouteSrc(&src)); err != nil {
-			return fmt.Errorf("could not add gateway %s for src %s dst %s to interface %s: %v", netconf.Routers[0], src, dst, ifname, err)
+		if len(netconf.Routers) > 0 {
+			src := netconf.Addresses[0].IPNet
+			// TODO handle the remaining Routers if more than one
+			if err := rt.RouteAdd(iface, dst, netconf.Routers[0], rtnl.WithRouteSrc(&src)); err != nil {
+				return fmt.Errorf("could not add gateway %s for src %s dst %s to interface %s: %v", netconf.Routers[0], src, dst, ifname, err)
+			}
+			undo = append(undo, func() error { return rt.RouteDel(iface, dst) })
+		}
+	}
+
+	// configure additional routes. On-link routes go first, since other
+	// routes may use gateways reachable through them (RFC 3442, Section 3).
+	routes := make([]RouteConf, 0, len(netconf.Routes))
+	for _, r := range netconf.Routes {
+		if r.Gateway == nil && !r.Unreachable {
+			routes = append(routes, r)
+		}
+	}
+	for _, r := range netconf.Routes {
+		if r.Gateway != nil || r.Unreachable {
+			routes = append(routes, r)
+		}
+	}
+	for _, r := range routes {
+		r := r
+		if r.Unreachable {
+			if err := rt.Conn.Route.Add(unreachableRoute(r.Dst)); err != nil {
+				return fmt.Errorf("could not add unreachable route to %s: %v", &r.Dst, err)
+			}
+			undo = append(undo, func() error { return rt.Conn.Route.Delete(unreachableRoute(r.Dst)) })
+			continue
 		}
+		if err := rt.RouteAdd(iface, r.Dst, r.Gateway); err != nil {
+			return fmt.Errorf("could not add route to %s via %s on interface %s: %v", &r.Dst, r.Gateway, ifname, err)
+		}
+		undo = append(undo, func() error { return rt.RouteDel(iface, r.Dst) })
+	}
+
+	// configure /etc/resolv.conf
+	resolvconf := ""
+	for _, ns := range netconf.DNSServers {
+		resolvconf += fmt.Sprintf("nameserver %s\n", ns)
+	}
+	if len(netconf.DNSSearchList) > 0 {
+		resolvconf += fmt.Sprintf("search %s\n", strings.Join(netconf.DNSSearchList, " "))
+	}
+	if err = writeFileAtomic(resolvConfPath, []byte(resolvconf), 0644); err != nil {
+		return fmt.Errorf("could not write resolv.conf file %v", err)
 	}
 
 	return nil
 }
+
+func hasDefaultRoutev4(routes []RouteConf) bool {
+	for _, r := range routes {
+		if ones, _ := r.Dst.Mask.Size(); ones == 0 && r.Dst.IP.To4() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// setMTU sets the MTU of iface.
+func setMTU(rt *rtnl.Conn, iface *net.Interface, mtu int) error {
+	return rt.Conn.Link.Set(&rtnetlink.LinkMessage{
+		Family: unix.AF_UNSPEC,
+		Index:  uint32(iface.Index),
+		Attributes: &rtnetlink.LinkAttributes{
+			MTU: uint32(mtu),
+		},
+	})
+}
+
+// unreachableRoute returns a route rejecting packets to dst.
+func unreachableRoute(dst net.IPNet) *rtnetlink.RouteMessage {
+	family := unix.AF_INET6
+	if dst.IP.To4() != nil {
+		family = unix.AF_INET
+	}
+	ones, _ := dst.Mask.Size()
+	return &rtnetlink.RouteMessage{
+		Family:    uint8(family),
+		Table:     unix.RT_TABLE_MAIN,
+		Protocol:  unix.RTPROT_BOOT,
+		Type:      unix.RTN_UNREACHABLE,
+		Scope:     unix.RT_SCOPE_UNIVERSE,
+		DstLength: uint8(ones),
+		Attributes: rtnetlink.RouteAttributes{
+			Dst: dst.IP,
+		},
+	}
+}
+
+// writeFileAtomic replaces the file at path with data, so that readers see
+// either the old or the new content. If path is a symbolic link, the file it
+// points to is replaced, and the link is kept.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	// /etc/resolv.conf is often a link to a file managed by a resolver
+	// daemon. The temporary file is created next to the target, so that
+	// renaming it stays within a file system.
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
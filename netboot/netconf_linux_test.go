@@ -0,0 +1,161 @@
+package netboot
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jsimonetti/rtnetlink"
+	"github.com/jsimonetti/rtnetlink/rtnl"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+// inNetNS moves the calling goroutine to a new network namespace, and returns
+// a connection to it and its loopback interface, which is up. The test is
+// skipped if namespaces cannot be created.
+//
+// The goroutine stays locked to its thread, so that the thread, and with it
+// the namespace, is discarded when the test ends.
+func inNetNS(t *testing.T) (*rtnl.Conn, *net.Interface) {
+	runtime.LockOSThread()
+	if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		t.Skipf("cannot create network namespace: %v", err)
+	}
+	rt, err := rtnl.Dial(nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { rt.Close() })
+	lo, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+	require.NoError(t, rt.LinkUp(lo))
+
+	resolvConfPath = filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(resolvConfPath, []byte("nameserver 192.0.2.53\n"), 0644))
+	t.Cleanup(func() { resolvConfPath = "/etc/resolv.conf" })
+	return rt, lo
+}
+
+func mustParseCIDR(s string) net.IPNet {
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	ipnet.IP = ip
+	return *ipnet
+}
+
+// routes returns the routes of the main table of the given family, keyed by
+// destination.
+func routes(t *testing.T, rt *rtnl.Conn, family int) map[string]rtnetlink.RouteMessage {
+	msgs, err := rt.Conn.Route.List()
+	require.NoError(t, err)
+	out := make(map[string]rtnetlink.RouteMessage)
+	for _, m := range msgs {
+		if int(m.Family) != family || m.Table != unix.RT_TABLE_MAIN {
+			continue
+		}
+		dst := "default"
+		if m.Attributes.Dst != nil {
+			dst = (&net.IPNet{IP: m.Attributes.Dst, Mask: net.CIDRMask(int(m.DstLength), 8*len(m.Attributes.Dst))}).String()
+		}
+		out[dst] = m
+	}
+	return out
+}
+
+func TestConfigureInterfaceNetNS(t *testing.T) {
+	rt, lo := inNetNS(t)
+
+	err := ConfigureInterface("lo", &NetConf{
+		Addresses:     []AddrConf{{IPNet: mustParseCIDR("10.20.30.40/24")}},
+		DNSServers:    []net.IP{net.ParseIP("10.20.30.1")},
+		DNSSearchList: []string{"example.com"},
+		Routers:       []net.IP{net.ParseIP("10.20.30.1")},
+		Routes: []RouteConf{
+			{Dst: mustParseCIDR("192.168.0.0/16"), Gateway: net.ParseIP("172.16.0.1")},
+			{Dst: mustParseCIDR("172.16.0.0/24")},
+			{Dst: mustParseCIDR("2001:db8:1::/48"), Unreachable: true},
+		},
+		MTU: 1400,
+	})
+	require.NoError(t, err)
+
+	iface, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+	require.Equal(t, 1400, iface.MTU)
+
+	addrs, err := rt.Addrs(lo, unix.AF_INET)
+	require.NoError(t, err)
+	require.Contains(t, addrs, &net.IPNet{IP: net.ParseIP("10.20.30.40").To4(), Mask: net.CIDRMask(24, 32)})
+
+	v4 := routes(t, rt, unix.AF_INET)
+	require.Contains(t, v4, "default")
+	require.Equal(t, net.ParseIP("10.20.30.1").To4(), v4["default"].Attributes.Gateway.To4())
+	require.Contains(t, v4, "192.168.0.0/16")
+	require.Equal(t, net.ParseIP("172.16.0.1").To4(), v4["192.168.0.0/16"].Attributes.Gateway.To4())
+	require.Contains(t, v4, "172.16.0.0/24")
+	v6 := routes(t, rt, unix.AF_INET6)
+	require.Contains(t, v6, "2001:db8:1::/48")
+	require.Equal(t, uint8(unix.RTN_UNREACHABLE), v6["2001:db8:1::/48"].Type)
+
+	data, err := os.ReadFile(resolvConfPath)
+	require.NoError(t, err)
+	require.Equal(t, "nameserver 10.20.30.1\nsearch example.com\n", string(data))
+}
+
+func TestConfigureInterfaceNetNSSymlink(t *testing.T) {
+	_, lo := inNetNS(t)
+	// resolvConfPath is a link to the file of a resolver daemon.
+	target := resolvConfPath
+	resolvConfPath = filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.Symlink(target, resolvConfPath))
+
+	err := ConfigureInterface(lo.Name, &NetConf{
+		DNSServers: []net.IP{net.ParseIP("10.20.30.1")},
+	})
+	require.NoError(t, err)
+
+	dest, err := os.Readlink(resolvConfPath)
+	require.NoError(t, err)
+	require.Equal(t, target, dest)
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, "nameserver 10.20.30.1\n", string(data))
+}
+
+func TestConfigureInterfaceNetNSRollback(t *testing.T) {
+	rt, lo := inNetNS(t)
+	origMTU := lo.MTU
+
+	err := ConfigureInterface("lo", &NetConf{
+		Addresses:  []AddrConf{{IPNet: mustParseCIDR("10.20.30.40/24")}},
+		DNSServers: []net.IP{net.ParseIP("10.20.30.1")},
+		Routers:    []net.IP{net.ParseIP("10.20.30.1")},
+		Routes: []RouteConf{
+			{Dst: mustParseCIDR("2001:db8:1::/48"), Unreachable: true},
+			// The gateway is not reachable, so this route fails.
+			{Dst: mustParseCIDR("192.168.0.0/16"), Gateway: net.ParseIP("198.51.100.1")},
+		},
+		MTU: 1400,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "192.168.0.0/16")
+
+	iface, err := net.InterfaceByName("lo")
+	require.NoError(t, err)
+	require.Equal(t, origMTU, iface.MTU)
+
+	addrs, err := rt.Addrs(lo, unix.AF_INET)
+	require.NoError(t, err)
+	require.NotContains(t, addrs, &net.IPNet{IP: net.ParseIP("10.20.30.40").To4(), Mask: net.CIDRMask(24, 32)})
+
+	require.NotContains(t, routes(t, rt, unix.AF_INET), "default")
+	require.NotContains(t, routes(t, rt, unix.AF_INET6), "2001:db8:1::/48")
+
+	data, err := os.ReadFile(resolvConfPath)
+	require.NoError(t, err)
+	require.Equal(t, "nameserver 192.0.2.53\n", string(data))
+}
@@ -3,11 +3,14 @@ package netboot
 import (
 	"log"
 	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/insomniacslk/dhcp/dhcpv4"
 	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/svcb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -130,3 +133,184 @@ func TestGetNetConfFromPacketv4(t *testing.T) {
 	require.Equal(t, 1, len(netconf.Routers))
 	require.Equal(t, net.ParseIP("10.0.0.254").To4(), netconf.Routers[0])
 }
+
+func TestGetNetConfFromPacketv4Routes(t *testing.T) {
+	_, dst, _ := net.ParseCIDR("192.168.0.0/16")
+	_, onlink, _ := net.ParseCIDR("10.1.0.0/16")
+	d, _ := dhcpv4.New(
+		dhcpv4.WithNetmask(net.IPv4Mask(255, 255, 255, 0)),
+		dhcpv4.WithYourIP(net.ParseIP("10.0.0.1")),
+		dhcpv4.WithRouter(net.ParseIP("10.0.0.254")),
+		dhcpv4.WithOption(dhcpv4.OptClasslessStaticRoute(
+			&dhcpv4.Route{Dest: dst, Router: net.ParseIP("10.0.0.253")},
+			&dhcpv4.Route{Dest: onlink, Router: net.IPv4zero},
+		)),
+		dhcpv4.WithOption(dhcpv4.OptInterfaceMTU(1400)),
+	)
+
+	netconf, err := GetNetConfFromPacketv4(d)
+	require.NoError(t, err)
+	require.Equal(t, 1400, netconf.MTU)
+	// the router option is ignored when classless static routes are present
+	require.Empty(t, netconf.Routers)
+	require.Equal(t, []RouteConf{
+		{Dst: *dst, Gateway: net.ParseIP("10.0.0.253").To4()},
+		{Dst: *onlink},
+	}, netconf.Routes)
+}
+
+func TestGetNetConfFromPacketv6DelegatedPrefixes(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/48")
+	_, expired, _ := net.ParseCIDR("2001:db8:2::/48")
+	adv := getAdv(
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), ValidLifetime: time.Hour}),
+		dhcpv6.WithIAPD([4]byte{1, 2, 3, 4},
+			&dhcpv6.OptIAPrefix{Prefix: prefix, ValidLifetime: time.Hour},
+			&dhcpv6.OptIAPrefix{Prefix: expired},
+		),
+	)
+	netconf, err := GetNetConfFromPacketv6(adv)
+	require.NoError(t, err)
+	require.Equal(t, []RouteConf{{Dst: *prefix, Unreachable: true}}, netconf.Routes)
+}
+
+func TestGetNetConfFromPacketv6Transition(t *testing.T) {
+	_, unicast, _ := net.ParseCIDR("64:ff9b::/96")
+	adv := getAdv(
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), ValidLifetime: time.Hour}),
+		dhcpv6.WithAFTRName("aftr.example.com"),
+		dhcpv6.WithV6Prefix64(net.IPNet{}, net.IPNet{}, *unicast),
+	)
+	netconf, err := GetNetConfFromPacketv6(adv)
+	require.NoError(t, err)
+	require.Equal(t, "aftr.example.com", netconf.AFTRName)
+	require.NotNil(t, netconf.V6Prefix64)
+	require.Equal(t, unicast.String(), netconf.V6Prefix64.UnicastPrefix64.String())
+	require.Nil(t, netconf.SixRD)
+}
+
+func TestGetNetConfFromPacketv4SixRD(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8::/32")
+	sixrd := &dhcpv4.SixRD{IPv4MaskLen: 8, Prefix: *prefix, BRAddrs: []net.IP{net.IPv4(192, 0, 2, 1)}}
+	d, _ := dhcpv4.New(
+		dhcpv4.WithNetmask(net.IPv4Mask(255, 255, 255, 0)),
+		dhcpv4.WithYourIP(net.ParseIP("10.0.0.1")),
+		dhcpv4.With6RD(sixrd),
+	)
+	netconf, err := GetNetConfFromPacketv4(d)
+	require.NoError(t, err)
+	require.NotNil(t, netconf.SixRD)
+	require.Equal(t, "2001:db8::/32", netconf.SixRD.Prefix.String())
+	require.True(t, net.IPv4(192, 0, 2, 1).Equal(netconf.SixRD.BRAddrs[0]))
+	require.Empty(t, netconf.AFTRName)
+}
+
+func TestGetNetConfFromPacketv6EncryptedDNS(t *testing.T) {
+	doh := make(svcb.Params)
+	doh.SetALPN("h2")
+	doh.SetDoHPath("/dns-query{?dns}")
+	adv := getAdv(
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), ValidLifetime: time.Hour}),
+		dhcpv6.WithDNR(
+			&dhcpv6.OptDNR{ServicePriority: 2, ADN: "doh.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::53")}, Params: doh},
+			// Invalid without alpn.
+			&dhcpv6.OptDNR{ServicePriority: 1, ADN: "bad.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::54")}},
+			&dhcpv6.OptDNR{ServicePriority: 3, ADN: "dot.example.net"},
+		),
+	)
+	netconf, err := GetNetConfFromPacketv6(adv)
+	require.NoError(t, err)
+	require.Equal(t, []EncryptedDNSServer{
+		{Priority: 2, Name: "doh.example.net", Addrs: []net.IP{net.ParseIP("2001:db8::53")}, ALPN: []string{"h2"}, DoHPath: "/dns-query{?dns}"},
+		{Priority: 3, Name: "dot.example.net"},
+	}, netconf.EncryptedDNSServers)
+}
+
+func TestGetNetConfFromPacketv4EncryptedDNS(t *testing.T) {
+	dot := make(svcb.Params)
+	dot.SetALPN("dot")
+	dot.SetPort(8853)
+	d, _ := dhcpv4.New(
+		dhcpv4.WithNetmask(net.IPv4Mask(255, 255, 255, 0)),
+		dhcpv4.WithYourIP(net.ParseIP("10.0.0.1")),
+		dhcpv4.WithDNR(&dhcpv4.DNR{ServicePriority: 1, ADN: "dot.example.net", Addrs: []net.IP{net.IPv4(10, 0, 0, 53)}, Params: dot}),
+	)
+	netconf, err := GetNetConfFromPacketv4(d)
+	require.NoError(t, err)
+	require.Len(t, netconf.EncryptedDNSServers, 1)
+	s := netconf.EncryptedDNSServers[0]
+	require.Equal(t, "dot.example.net", s.Name)
+	require.True(t, net.IPv4(10, 0, 0, 53).Equal(s.Addrs[0]))
+	require.Equal(t, []string{"dot"}, s.ALPN)
+	require.Equal(t, uint16(8853), s.Port)
+}
+
+func TestGetNetConfFromPacketCaptivePortal(t *testing.T) {
+	opt6, err := dhcpv6.OptCaptivePortal("https://portal.example.net/api")
+	require.NoError(t, err)
+	adv := getAdv(
+		dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), ValidLifetime: time.Hour}),
+		dhcpv6.WithOption(opt6),
+	)
+	netconf, err := GetNetConfFromPacketv6(adv)
+	require.NoError(t, err)
+	require.Equal(t, "https://portal.example.net/api", netconf.CaptivePortal)
+
+	opt4, err := dhcpv4.OptCaptivePortal(dhcpv4.CaptivePortalUnrestricted)
+	require.NoError(t, err)
+	d, _ := dhcpv4.New(
+		dhcpv4.WithNetmask(net.IPv4Mask(255, 255, 255, 0)),
+		dhcpv4.WithYourIP(net.ParseIP("10.0.0.1")),
+		dhcpv4.WithOption(opt4),
+	)
+	netconf, err = GetNetConfFromPacketv4(d)
+	require.NoError(t, err)
+	require.Empty(t, netconf.CaptivePortal)
+
+	opt4, err = dhcpv4.OptCaptivePortal("https://portal.example.net/v4")
+	require.NoError(t, err)
+	d.UpdateOption(opt4)
+	netconf, err = GetNetConfFromPacketv4(d)
+	require.NoError(t, err)
+	require.Equal(t, "https://portal.example.net/v4", netconf.CaptivePortal)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(path, []byte("nameserver 10.0.0.1\n"), 0600))
+	require.NoError(t, writeFileAtomic(path, []byte("nameserver 10.0.0.2\n"), 0644))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "nameserver 10.0.0.2\n", string(data))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), fi.Mode().Perm())
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.Error(t, writeFileAtomic(filepath.Join(path, "nodir", "file"), nil, 0644))
+}
+
+func TestWriteFileAtomicSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "run", "stub-resolv.conf")
+	require.NoError(t, os.Mkdir(filepath.Dir(target), 0755))
+	require.NoError(t, os.WriteFile(target, []byte("nameserver 10.0.0.1\n"), 0644))
+	link := filepath.Join(dir, "resolv.conf")
+	require.NoError(t, os.Symlink(target, link))
+
+	require.NoError(t, writeFileAtomic(link, []byte("nameserver 10.0.0.2\n"), 0644))
+	fi, err := os.Lstat(link)
+	require.NoError(t, err)
+	require.Equal(t, os.ModeSymlink, fi.Mode()&os.ModeSymlink)
+	data, err := os.ReadFile(target)
+	require.NoError(t, err)
+	require.Equal(t, "nameserver 10.0.0.2\n", string(data))
+	// the temporary file was created next to the target
+	entries, err := os.ReadDir(filepath.Dir(target))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
@@ -0,0 +1,240 @@
+package tftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// Client downloads files from TFTP servers. The zero value is ready to use.
+type Client struct {
+	// BlockSize is the block size requested from the server. It defaults
+	// to 1468 bytes, which fits a packet in an Ethernet frame. Servers
+	// that do not support option negotiation use 512 bytes blocks.
+	BlockSize int
+	// Timeout is how long to wait for a packet before retransmitting the
+	// last one. It defaults to one second.
+	Timeout time.Duration
+	// Retries is the number of retransmissions before giving up. It
+	// defaults to 5.
+	Retries int
+
+	// OnTransferSize, if set, is called with the file size announced by
+	// the server before any data is received. If it returns an error,
+	// the transfer is aborted with that error.
+	OnTransferSize func(size int64) error
+}
+
+func (c *Client) blockSize() int {
+	if c.BlockSize == 0 {
+		return defaultRequestBlockSize
+	}
+	return c.BlockSize
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.Timeout == 0 {
+		return defaultTimeout
+	}
+	return c.Timeout
+}
+
+func (c *Client) retries() int {
+	if c.Retries == 0 {
+		return defaultRetries
+	}
+	return c.Retries
+}
+
+// Get downloads filename from the TFTP server at addr and writes it to w. The
+// port in addr defaults to 69. Get returns the number of bytes written.
+//
+// If writing to w fails, the transfer is aborted and the error is returned.
+// Errors sent by the server are returned as *Error.
+func (c *Client) Get(ctx context.Context, addr, filename string, w io.Writer) (int64, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	blksize := c.blockSize()
+	if blksize < MinBlockSize || blksize > MaxBlockSize {
+		return 0, fmt.Errorf("tftp: invalid block size %d", blksize)
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	t := &transfer{
+		conn:    conn,
+		peer:    raddr,
+		timeout: c.timeout(),
+		retries: c.retries(),
+	}
+	n, err := t.receive(c, filename, blksize, w)
+	if err != nil && ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	return n, err
+}
+
+// transfer is the state of a download.
+type transfer struct {
+	conn    *net.UDPConn
+	peer    *net.UDPAddr
+	tidSet  bool
+	timeout time.Duration
+	retries int
+	last    []byte
+}
+
+// abort sends an error to the peer and returns it.
+func (t *transfer) abort(code ErrorCode, err error) error {
+	_, _ = t.conn.WriteToUDP(packetERROR(code, err.Error()), t.peer)
+	return err
+}
+
+func (t *transfer) send(p []byte) error {
+	t.last = p
+	_, err := t.conn.WriteToUDP(p, t.peer)
+	return err
+}
+
+// read returns the next packet from the peer, retransmitting the last
+// packet sent on timeout.
+func (t *transfer) read(buf []byte) ([]byte, error) {
+	for tries := 0; ; {
+		if err := t.conn.SetReadDeadline(time.Now().Add(t.timeout)); err != nil {
+			return nil, err
+		}
+		n, from, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			var nerr net.Error
+			if !errors.As(err, &nerr) || !nerr.Timeout() {
+				return nil, err
+			}
+			if tries++; tries > t.retries {
+				return nil, errors.New("tftp: timed out")
+			}
+			if _, err := t.conn.WriteToUDP(t.last, t.peer); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !t.tidSet {
+			// RFC 1350, Section 4: the server answers from a new
+			// port, which identifies the transfer from then on.
+			if !from.IP.Equal(t.peer.IP) {
+				continue
+			}
+			t.peer = from
+			t.tidSet = true
+		} else if from.Port != t.peer.Port || !from.IP.Equal(t.peer.IP) {
+			_, _ = t.conn.WriteToUDP(packetERROR(ErrorUnknownTID, "unknown transfer ID"), from)
+			continue
+		}
+		return buf[:n], nil
+	}
+}
+
+func (t *transfer) receive(c *Client, filename string, blksize int, w io.Writer) (int64, error) {
+	req := options{
+		optBlockSize: strconv.Itoa(blksize),
+		optTSize:     "0",
+	}
+	if secs := int(t.timeout / time.Second); secs >= 1 && secs <= 255 {
+		req[optTimeout] = strconv.Itoa(secs)
+	}
+	if err := t.send(packetRRQ(filename, req)); err != nil {
+		return 0, err
+	}
+
+	// Until the server acknowledges options, blocks are 512 bytes.
+	size := DefaultBlockSize
+	buf := make([]byte, 4+MaxBlockSize)
+	var (
+		written  int64
+		expected uint16 = 1
+	)
+	for {
+		p, err := t.read(buf)
+		if err != nil {
+			return written, err
+		}
+		pkt := uio.NewBigEndianBuffer(p)
+		op := opcode(pkt.Read16())
+		if pkt.Error() != nil {
+			continue
+		}
+		switch op {
+		case opOACK:
+			if expected != 1 || written != 0 {
+				continue
+			}
+			o, err := readOptions(pkt)
+			if err != nil {
+				return 0, t.abort(ErrorOptionNegotiation, err)
+			}
+			size = DefaultBlockSize
+			if n, ok := o.int(optBlockSize); ok {
+				if n < MinBlockSize || n > int64(blksize) {
+					return 0, t.abort(ErrorOptionNegotiation, fmt.Errorf("tftp: server chose invalid block size %d", n))
+				}
+				size = int(n)
+			}
+			if n, ok := o.int(optTSize); ok && c.OnTransferSize != nil {
+				if err := c.OnTransferSize(n); err != nil {
+					return 0, t.abort(ErrorDiskFull, err)
+				}
+			}
+			if err := t.send(packetACK(0)); err != nil {
+				return 0, err
+			}
+		case opDATA:
+			block := pkt.Read16()
+			data := pkt.ReadAll()
+			if block != expected {
+				// A duplicate of the previous block means our
+				// acknowledgment was lost.
+				if block == expected-1 {
+					if err := t.send(packetACK(block)); err != nil {
+						return written, err
+					}
+				}
+				continue
+			}
+			if len(data) > size {
+				return written, t.abort(ErrorIllegalOperation, fmt.Errorf("tftp: block %d is larger than the block size %d", block, size))
+			}
+			n, err := w.Write(data)
+			written += int64(n)
+			if err != nil {
+				return written, t.abort(ErrorUndefined, err)
+			}
+			if err := t.send(packetACK(block)); err != nil {
+				return written, err
+			}
+			if len(data) < size {
+				return written, nil
+			}
+			// Block numbers wrap around for large files.
+			expected++
+		case opERROR:
+			return written, parseError(pkt)
+		default:
+			return written, t.abort(ErrorIllegalOperation, fmt.Errorf("tftp: unexpected opcode %d", op))
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package tftp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/u-root/uio/uio"
+)
+
+// startServer serves fsys on a loopback port and returns its address.
+func startServer(t *testing.T, fsys fstest.MapFS) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := NewServer(FSHandler(fsys))
+	s.Timeout = 100 * time.Millisecond
+	go func() { _ = s.Serve(conn) }()
+	t.Cleanup(func() { s.Close() })
+	return conn.LocalAddr().String()
+}
+
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestClientGet(t *testing.T) {
+	files := fstest.MapFS{
+		"pxelinux.0":  {Data: pattern(100000)},
+		"exact.bin":   {Data: pattern(1024)},
+		"empty":       {Data: nil},
+		"boot/kernel": {Data: pattern(3000)},
+	}
+	addr := startServer(t, files)
+
+	for _, tt := range []struct {
+		name      string
+		blockSize int
+	}{
+		{"pxelinux.0", 0},
+		{"exact.bin", 512},
+		{"empty", 0},
+		{"/boot/kernel", 8},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var announced int64 = -1
+			c := &Client{
+				BlockSize: tt.blockSize,
+				Timeout:   100 * time.Millisecond,
+				OnTransferSize: func(size int64) error {
+					announced = size
+					return nil
+				},
+			}
+			var buf bytes.Buffer
+			n, err := c.Get(context.Background(), addr, tt.name, &buf)
+			require.NoError(t, err)
+			want := files[strings.TrimPrefix(tt.name, "/")]
+			require.Equal(t, int64(len(want.Data)), n)
+			require.Equal(t, int64(len(want.Data)), announced)
+			require.True(t, bytes.Equal(want.Data, buf.Bytes()))
+		})
+	}
+}
+
+func TestClientGetNotFound(t *testing.T) {
+	addr := startServer(t, fstest.MapFS{})
+	c := &Client{Timeout: 100 * time.Millisecond}
+	_, err := c.Get(context.Background(), addr, "missing", &bytes.Buffer{})
+	var terr *Error
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, ErrorFileNotFound, terr.Code)
+}
+
+func TestClientGetTransferSizeRejected(t *testing.T) {
+	addr := startServer(t, fstest.MapFS{"big": {Data: pattern(5000)}})
+	tooBig := errors.New("too big")
+	c := &Client{
+		Timeout:        100 * time.Millisecond,
+		OnTransferSize: func(size int64) error { return tooBig },
+	}
+	var buf bytes.Buffer
+	_, err := c.Get(context.Background(), addr, "big", &buf)
+	require.True(t, errors.Is(err, tooBig))
+	require.Zero(t, buf.Len())
+}
+
+// TestClientGetNoOptions checks that the client retransmits its request and
+// falls back to 512 bytes blocks with a server that does not negotiate
+// options.
+func TestClientGetNoOptions(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	data := pattern(700)
+
+	go func() {
+		buf := make([]byte, 1024)
+		// Drop the first request.
+		if _, _, err := conn.ReadFrom(buf); err != nil {
+			return
+		}
+		_, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		for block, off := uint16(1), 0; ; block++ {
+			end := off + DefaultBlockSize
+			if end > len(data) {
+				end = len(data)
+			}
+			if _, err := conn.WriteTo(packetDATA(block, data[off:end]), peer); err != nil {
+				return
+			}
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pkt := uio.NewBigEndianBuffer(buf[:n])
+			if opcode(pkt.Read16()) != opACK || pkt.Read16() != block {
+				return
+			}
+			if end == len(data) {
+				return
+			}
+			off = end
+		}
+	}()
+
+	c := &Client{Timeout: 50 * time.Millisecond}
+	var buf bytes.Buffer
+	n, err := c.Get(context.Background(), conn.LocalAddr().String(), "file", &buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), n)
+	require.Equal(t, data, buf.Bytes())
+}
+
+func TestClientGetContextCanceled(t *testing.T) {
+	// Nothing answers on this socket.
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := &Client{Timeout: time.Second}
+	_, err = c.Get(ctx, conn.LocalAddr().String(), "file", &bytes.Buffer{})
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestClientGetInvalidBlockSize(t *testing.T) {
+	c := &Client{BlockSize: 4}
+	_, err := c.Get(context.Background(), "127.0.0.1", "file", &bytes.Buffer{})
+	require.Error(t, err)
+}
@@ -0,0 +1,277 @@
+package tftp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// Handler returns the content of the file requested by a client. size is the
+// length of the file, or -1 if it is not known in advance. Errors of type
+// *Error are sent to the client as is; fs.ErrNotExist is reported as "file
+// not found" and other errors as "access violation".
+type Handler func(filename string) (r io.ReadCloser, size int64, err error)
+
+// FSHandler returns a Handler serving files from fsys. Leading slashes in
+// file names are ignored.
+func FSHandler(fsys fs.FS) Handler {
+	return func(filename string) (io.ReadCloser, int64, error) {
+		for len(filename) > 0 && filename[0] == '/' {
+			filename = filename[1:]
+		}
+		f, err := fsys.Open(filename)
+		if err != nil {
+			return nil, 0, err
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		if fi.IsDir() {
+			f.Close()
+			return nil, 0, &Error{Code: ErrorAccessViolation, Message: "is a directory"}
+		}
+		return f, fi.Size(), nil
+	}
+}
+
+// Server is a read-only TFTP server. Each transfer is served from its own
+// UDP port, as required by RFC 1350.
+type Server struct {
+	Handler Handler
+	// Timeout is how long to wait for an acknowledgment before
+	// retransmitting. It defaults to one second, and clients can
+	// negotiate it with the timeout option.
+	Timeout time.Duration
+	// Retries is the number of retransmissions before giving up on a
+	// client. It defaults to 5.
+	Retries int
+	// Logger, if set, receives one line per failed transfer.
+	Logger *log.Logger
+
+	mu     sync.Mutex
+	conn   net.PacketConn
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer returns a server answering requests with h.
+func NewServer(h Handler) *Server {
+	return &Server{Handler: h}
+}
+
+// ListenAndServe listens on the UDP address addr and serves requests until
+// Close is called.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve answers the requests received on conn until Close is called. Serve
+// takes ownership of conn.
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return net.ErrClosed
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	buf := make([]byte, 4+MaxBlockSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		peer, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		req := make([]byte, n)
+		copy(req, buf[:n])
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := s.serveRequest(conn.LocalAddr(), peer, req); err != nil {
+				s.logf("tftp: transfer to %s failed: %v", peer, err)
+			}
+		}()
+	}
+}
+
+// Close stops the server and waits for running transfers to end.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	conn := s.conn
+	s.mu.Unlock()
+	var err error
+	if conn != nil {
+		err = conn.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, args...)
+	}
+}
+
+func (s *Server) serveRequest(laddr net.Addr, peer *net.UDPAddr, req []byte) error {
+	var ip net.IP
+	if a, ok := laddr.(*net.UDPAddr); ok {
+		ip = a.IP
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: ip})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	t := &transfer{
+		conn:    conn,
+		peer:    peer,
+		tidSet:  true,
+		timeout: s.Timeout,
+		retries: s.Retries,
+	}
+	if t.timeout == 0 {
+		t.timeout = defaultTimeout
+	}
+	if t.retries == 0 {
+		t.retries = defaultRetries
+	}
+
+	pkt := uio.NewBigEndianBuffer(req)
+	switch op := opcode(pkt.Read16()); op {
+	case opRRQ:
+	case opWRQ:
+		return t.abort(ErrorIllegalOperation, errors.New("write requests are not supported"))
+	default:
+		return t.abort(ErrorIllegalOperation, fmt.Errorf("unexpected opcode %d", op))
+	}
+	filename, err := readString(pkt)
+	if err != nil {
+		return t.abort(ErrorIllegalOperation, err)
+	}
+	mode, err := readString(pkt)
+	if err != nil {
+		return t.abort(ErrorIllegalOperation, err)
+	}
+	if lower(mode) != "octet" {
+		return t.abort(ErrorIllegalOperation, fmt.Errorf("unsupported transfer mode %q", mode))
+	}
+	reqOpts, err := readOptions(pkt)
+	if err != nil {
+		return t.abort(ErrorIllegalOperation, err)
+	}
+
+	if s.Handler == nil {
+		return t.abort(ErrorFileNotFound, errors.New("no handler"))
+	}
+	r, size, err := s.Handler(filename)
+	if err != nil {
+		var terr *Error
+		switch {
+		case errors.As(err, &terr):
+			_, _ = conn.WriteToUDP(packetERROR(terr.Code, terr.Message), peer)
+			return err
+		case errors.Is(err, fs.ErrNotExist):
+			return t.abort(ErrorFileNotFound, err)
+		default:
+			return t.abort(ErrorAccessViolation, err)
+		}
+	}
+	defer r.Close()
+
+	blksize := DefaultBlockSize
+	ack := make(options)
+	if n, ok := reqOpts.int(optBlockSize); ok && n >= MinBlockSize {
+		if n > MaxBlockSize {
+			n = MaxBlockSize
+		}
+		blksize = int(n)
+		ack[optBlockSize] = strconv.Itoa(blksize)
+	}
+	if n, ok := reqOpts.int(optTimeout); ok && n >= 1 && n <= 255 {
+		t.timeout = time.Duration(n) * time.Second
+		ack[optTimeout] = strconv.FormatInt(n, 10)
+	}
+	if _, ok := reqOpts[optTSize]; ok && size >= 0 {
+		ack[optTSize] = strconv.FormatInt(size, 10)
+	}
+
+	buf := make([]byte, 4+MaxBlockSize)
+	if len(ack) > 0 {
+		if err := t.send(packetOACK(ack)); err != nil {
+			return err
+		}
+		if err := t.waitACK(buf, 0); err != nil {
+			return err
+		}
+	}
+
+	data := make([]byte, blksize)
+	for block := uint16(1); ; block++ {
+		n, err := io.ReadFull(r, data)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return t.abort(ErrorUndefined, err)
+		}
+		if err := t.send(packetDATA(block, data[:n])); err != nil {
+			return err
+		}
+		if err := t.waitACK(buf, block); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// waitACK waits for the acknowledgment of block, retransmitting the last
+// packet as needed.
+func (t *transfer) waitACK(buf []byte, block uint16) error {
+	for {
+		p, err := t.read(buf)
+		if err != nil {
+			return err
+		}
+		pkt := uio.NewBigEndianBuffer(p)
+		switch op := opcode(pkt.Read16()); op {
+		case opACK:
+			// Ignore duplicate acknowledgments of previous blocks,
+			// to avoid the Sorcerer's Apprentice syndrome.
+			if pkt.Read16() == block && pkt.Error() == nil {
+				return nil
+			}
+		case opERROR:
+			return parseError(pkt)
+		default:
+			return t.abort(ErrorIllegalOperation, fmt.Errorf("unexpected opcode %d", op))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package tftp
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/u-root/uio/uio"
+)
+
+func TestFSHandler(t *testing.T) {
+	h := FSHandler(fstest.MapFS{
+		"boot/ipxe.efi": {Data: []byte("ipxe")},
+	})
+
+	r, size, err := h("/boot/ipxe.efi")
+	require.NoError(t, err)
+	defer r.Close()
+	require.Equal(t, int64(4), size)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, []byte("ipxe"), data)
+
+	_, _, err = h("missing")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, _, err = h("boot")
+	var terr *Error
+	require.True(t, errors.As(err, &terr))
+	require.Equal(t, ErrorAccessViolation, terr.Code)
+}
+
+func TestServerRejectsWrite(t *testing.T) {
+	addr := startServer(t, fstest.MapFS{})
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	require.NoError(t, err)
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(opWRQ))
+	writeString(buf, "upload")
+	writeString(buf, "octet")
+	_, err = conn.WriteTo(buf.Data(), raddr)
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	p := make([]byte, 512)
+	n, _, err := conn.ReadFrom(p)
+	require.NoError(t, err)
+	pkt := uio.NewBigEndianBuffer(p[:n])
+	require.Equal(t, opERROR, opcode(pkt.Read16()))
+	require.Equal(t, ErrorIllegalOperation, parseError(pkt).Code)
+}
+
+func TestServerClose(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := NewServer(FSHandler(fstest.MapFS{}))
+	done := make(chan error)
+	go func() { done <- s.Serve(conn) }()
+	// Wait for Serve to pick up the connection.
+	for {
+		s.mu.Lock()
+		started := s.conn != nil
+		s.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, s.Close())
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,197 @@
+// Package tftp implements a minimal TFTP client and server (RFC 1350) for
+// netbooting, with support for option negotiation (RFC 2347) and the block
+// size (RFC 2348), timeout interval and transfer size (RFC 2349) options.
+//
+// Only downloads in octet mode are supported.
+package tftp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/u-root/uio/uio"
+)
+
+// DefaultPort is the well-known TFTP server port.
+const DefaultPort = 69
+
+// Block sizes allowed by RFC 2348.
+const (
+	// DefaultBlockSize is the RFC 1350 block size, used when the peer
+	// does not negotiate options.
+	DefaultBlockSize = 512
+	MinBlockSize     = 8
+	MaxBlockSize     = 65464
+)
+
+// Defaults used by Client and Server when the respective field is not set.
+const (
+	// defaultRequestBlockSize fits a data packet in a 1500 bytes
+	// Ethernet frame.
+	defaultRequestBlockSize = 1468
+	defaultTimeout          = time.Second
+	defaultRetries          = 5
+)
+
+type opcode uint16
+
+const (
+	opRRQ   opcode = 1
+	opWRQ   opcode = 2
+	opDATA  opcode = 3
+	opACK   opcode = 4
+	opERROR opcode = 5
+	opOACK  opcode = 6
+)
+
+// ErrorCode is a TFTP error code.
+type ErrorCode uint16
+
+// TFTP error codes, RFC 1350 and RFC 2347.
+const (
+	ErrorUndefined         ErrorCode = 0
+	ErrorFileNotFound      ErrorCode = 1
+	ErrorAccessViolation   ErrorCode = 2
+	ErrorDiskFull          ErrorCode = 3
+	ErrorIllegalOperation  ErrorCode = 4
+	ErrorUnknownTID        ErrorCode = 5
+	ErrorFileExists        ErrorCode = 6
+	ErrorNoSuchUser        ErrorCode = 7
+	ErrorOptionNegotiation ErrorCode = 8
+)
+
+// Error is an error sent by the TFTP peer, or sent to it.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("tftp error %d: %s", e.Code, e.Message)
+}
+
+// errShortPacket is returned when parsing a truncated packet.
+var errShortPacket = errors.New("short TFTP packet")
+
+// options holds the options of a request or an option acknowledgment, in
+// lower case.
+type options map[string]string
+
+const (
+	optBlockSize = "blksize"
+	optTimeout   = "timeout"
+	optTSize     = "tsize"
+)
+
+func (o options) int(name string) (int64, bool) {
+	v, ok := o[name]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// writeOptions writes the options in a stable order.
+func writeOptions(buf *uio.Lexer, o options) {
+	for _, name := range []string{optBlockSize, optTimeout, optTSize} {
+		if v, ok := o[name]; ok {
+			writeString(buf, name)
+			writeString(buf, v)
+		}
+	}
+}
+
+func writeString(buf *uio.Lexer, s string) {
+	buf.WriteBytes([]byte(s))
+	buf.Write8(0)
+}
+
+func readString(buf *uio.Lexer) (string, error) {
+	data := buf.Data()
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return "", errShortPacket
+	}
+	s := string(data[:i])
+	buf.Consume(i + 1)
+	return s, nil
+}
+
+func readOptions(buf *uio.Lexer) (options, error) {
+	o := make(options)
+	for buf.Len() > 0 {
+		name, err := readString(buf)
+		if err != nil {
+			return nil, err
+		}
+		val, err := readString(buf)
+		if err != nil {
+			return nil, err
+		}
+		o[lower(name)] = val
+	}
+	return o, nil
+}
+
+func lower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+func packetRRQ(filename string, o options) []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(opRRQ))
+	writeString(buf, filename)
+	writeString(buf, "octet")
+	writeOptions(buf, o)
+	return buf.Data()
+}
+
+func packetDATA(block uint16, data []byte) []byte {
+	buf := uio.NewBigEndianBuffer(make([]byte, 0, 4+len(data)))
+	buf.Write16(uint16(opDATA))
+	buf.Write16(block)
+	buf.WriteBytes(data)
+	return buf.Data()
+}
+
+func packetACK(block uint16) []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(opACK))
+	buf.Write16(block)
+	return buf.Data()
+}
+
+func packetOACK(o options) []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(opOACK))
+	writeOptions(buf, o)
+	return buf.Data()
+}
+
+func packetERROR(code ErrorCode, msg string) []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write16(uint16(opERROR))
+	buf.Write16(uint16(code))
+	writeString(buf, msg)
+	return buf.Data()
+}
+
+func parseError(buf *uio.Lexer) *Error {
+	code := ErrorCode(buf.Read16())
+	msg, _ := readString(buf)
+	return &Error{Code: code, Message: msg}
+}
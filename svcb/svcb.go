@@ -0,0 +1,182 @@
+// Package svcb implements the SvcParams of service bindings, as defined by
+// RFC 9460, Section 2.2, and carried by the DHCP Discovery of Network-designated
+// Resolvers options of RFC 9463.
+package svcb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/u-root/uio/uio"
+)
+
+// Key is a SvcParamKey.
+type Key uint16
+
+// SvcParamKeys, as registered by IANA.
+//
+// See https://www.iana.org/assignments/dns-svcb/dns-svcb.xhtml
+const (
+	KeyMandatory     Key = 0
+	KeyALPN          Key = 1
+	KeyNoDefaultALPN Key = 2
+	KeyPort          Key = 3
+	KeyIPv4Hint      Key = 4
+	KeyECH           Key = 5
+	KeyIPv6Hint      Key = 6
+	KeyDoHPath       Key = 7
+	KeyOHTTP         Key = 8
+)
+
+var keyToString = map[Key]string{
+	KeyMandatory:     "mandatory",
+	KeyALPN:          "alpn",
+	KeyNoDefaultALPN: "no-default-alpn",
+	KeyPort:          "port",
+	KeyIPv4Hint:      "ipv4hint",
+	KeyECH:           "ech",
+	KeyIPv6Hint:      "ipv6hint",
+	KeyDoHPath:       "dohpath",
+	KeyOHTTP:         "ohttp",
+}
+
+// String returns the presentation name of k, RFC 9460, Section 2.1.
+func (k Key) String() string {
+	if s, ok := keyToString[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("key%d", uint16(k))
+}
+
+// ErrUnorderedKeys is returned when parsing SvcParams whose keys are not in
+// strictly increasing order, RFC 9460, Section 2.2.
+var ErrUnorderedKeys = errors.New("svcb: SvcParamKeys not in strictly increasing order")
+
+// Params are the SvcParams of a service binding: the wire format values of
+// its SvcParamKeys.
+type Params map[Key][]byte
+
+// FromBytes parses SvcParams from data.
+func FromBytes(data []byte) (Params, error) {
+	p := make(Params)
+	buf := uio.NewBigEndianBuffer(data)
+	first := true
+	var last Key
+	for buf.Has(1) {
+		k := Key(buf.Read16())
+		v := buf.CopyN(int(buf.Read16()))
+		if err := buf.Error(); err != nil {
+			return nil, err
+		}
+		if !first && k <= last {
+			return nil, ErrUnorderedKeys
+		}
+		first, last = false, k
+		if v == nil {
+			v = []byte{}
+		}
+		p[k] = v
+	}
+	return p, buf.FinError()
+}
+
+// keys returns the keys of p, in increasing order.
+func (p Params) keys() []Key {
+	keys := make([]Key, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// ToBytes returns the wire format of p, in increasing key order.
+func (p Params) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, k := range p.keys() {
+		buf.Write16(uint16(k))
+		buf.Write16(uint16(len(p[k])))
+		buf.WriteBytes(p[k])
+	}
+	return buf.Data()
+}
+
+// String returns p in presentation format, e.g. `alpn=dot port=853`.
+func (p Params) String() string {
+	var s []string
+	for _, k := range p.keys() {
+		switch k {
+		case KeyALPN:
+			s = append(s, fmt.Sprintf("%s=%s", k, strings.Join(p.ALPN(), ",")))
+		case KeyNoDefaultALPN:
+			s = append(s, k.String())
+		case KeyPort:
+			port, _ := p.Port()
+			s = append(s, fmt.Sprintf("%s=%d", k, port))
+		case KeyDoHPath:
+			path, _ := p.DoHPath()
+			s = append(s, fmt.Sprintf("%s=%s", k, path))
+		default:
+			s = append(s, fmt.Sprintf("%s=%x", k, p[k]))
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+// ALPN returns the protocol identifiers of the alpn SvcParam, e.g. "dot" or
+// "h2", or nil if absent or invalid.
+func (p Params) ALPN() []string {
+	buf := uio.NewBigEndianBuffer(p[KeyALPN])
+	var ids []string
+	for buf.Has(1) {
+		id := buf.CopyN(int(buf.Read8()))
+		if buf.Error() != nil || len(id) == 0 {
+			return nil
+		}
+		ids = append(ids, string(id))
+	}
+	return ids
+}
+
+// SetALPN sets the alpn SvcParam to the given protocol identifiers.
+func (p Params) SetALPN(ids ...string) {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, id := range ids {
+		buf.Write8(uint8(len(id)))
+		buf.WriteBytes([]byte(id))
+	}
+	p[KeyALPN] = buf.Data()
+}
+
+// Port returns the value of the port SvcParam, and whether it is present and
+// valid.
+func (p Params) Port() (uint16, bool) {
+	v, ok := p[KeyPort]
+	if !ok || len(v) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(v), true
+}
+
+// SetPort sets the port SvcParam.
+func (p Params) SetPort(port uint16) {
+	v := make([]byte, 2)
+	binary.BigEndian.PutUint16(v, port)
+	p[KeyPort] = v
+}
+
+// DoHPath returns the URI template of the dohpath SvcParam, RFC 9461, and
+// whether it is present.
+func (p Params) DoHPath() (string, bool) {
+	v, ok := p[KeyDoHPath]
+	return string(v), ok
+}
+
+// SetDoHPath sets the dohpath SvcParam to the URI template path, e.g.
+// "/dns-query{?dns}".
+func (p Params) SetDoHPath(path string) {
+	p[KeyDoHPath] = []byte(path)
+}
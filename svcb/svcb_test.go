@@ -0,0 +1,56 @@
+package svcb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParams(t *testing.T) {
+	p := make(Params)
+	p.SetDoHPath("/dns-query{?dns}")
+	p.SetPort(8443)
+	p.SetALPN("h2", "h3")
+	data := []byte{
+		0, 1, 0, 6, 2, 'h', '2', 2, 'h', '3',
+		0, 3, 0, 2, 0x20, 0xfb,
+		0, 7, 0, 16, '/', 'd', 'n', 's', '-', 'q', 'u', 'e', 'r', 'y', '{', '?', 'd', 'n', 's', '}',
+	}
+	require.Equal(t, data, p.ToBytes())
+	require.Equal(t, "alpn=h2,h3 port=8443 dohpath=/dns-query{?dns}", p.String())
+
+	got, err := FromBytes(data)
+	require.NoError(t, err)
+	require.Equal(t, p, got)
+	require.Equal(t, []string{"h2", "h3"}, got.ALPN())
+	port, ok := got.Port()
+	require.True(t, ok)
+	require.Equal(t, uint16(8443), port)
+	path, ok := got.DoHPath()
+	require.True(t, ok)
+	require.Equal(t, "/dns-query{?dns}", path)
+
+	empty, err := FromBytes(nil)
+	require.NoError(t, err)
+	require.Empty(t, empty)
+	_, ok = empty.Port()
+	require.False(t, ok)
+	require.Nil(t, empty.ALPN())
+}
+
+func TestParamsFromBytesInvalid(t *testing.T) {
+	// Keys out of order.
+	_, err := FromBytes([]byte{0, 3, 0, 2, 0, 53, 0, 1, 0, 4, 3, 'd', 'o', 't'})
+	require.Equal(t, ErrUnorderedKeys, err)
+	// Duplicate keys.
+	_, err = FromBytes([]byte{0, 3, 0, 2, 0, 53, 0, 3, 0, 2, 0, 53})
+	require.Equal(t, ErrUnorderedKeys, err)
+	// Truncated value.
+	_, err = FromBytes([]byte{0, 3, 0, 2, 0})
+	require.Error(t, err)
+}
+
+func TestKeyString(t *testing.T) {
+	require.Equal(t, "alpn", KeyALPN.String())
+	require.Equal(t, "key65000", Key(65000).String())
+}